@@ -89,7 +89,7 @@ func (i *bootInspector) Inspect(reference string) (*pb.InspectionResults, error)
 	}
 	encodedProto, err := i.worker.RunAndReadSerialValue("inspect_pb", vars)
 	if err != nil {
-		return i.assembleErrors(reference, results, pb.InspectionResults_RUNNING_WORKER, err, startTime)
+		return assembleErrors(reference, results, pb.InspectionResults_RUNNING_WORKER, err, startTime)
 	}
 
 	// Decode the base64-encoded proto.
@@ -98,17 +98,17 @@ func (i *bootInspector) Inspect(reference string) (*pb.InspectionResults, error)
 		err = proto.Unmarshal(bytes, results)
 	}
 	if err != nil {
-		return i.assembleErrors(reference, results, pb.InspectionResults_DECODING_WORKER_RESPONSE, err, startTime)
+		return assembleErrors(reference, results, pb.InspectionResults_DECODING_WORKER_RESPONSE, err, startTime)
 	}
 	i.logger.Debug(fmt.Sprintf("Detection results: %s", results.String()))
 
 	// Validate the results.
-	if err = i.validate(results); err != nil {
-		return i.assembleErrors(reference, results, pb.InspectionResults_INTERPRETING_INSPECTION_RESULTS, err, startTime)
+	if err = validate(results); err != nil {
+		return assembleErrors(reference, results, pb.InspectionResults_INTERPRETING_INSPECTION_RESULTS, err, startTime)
 	}
 
-	if err = i.populate(results); err != nil {
-		return i.assembleErrors(reference, results, pb.InspectionResults_INTERPRETING_INSPECTION_RESULTS, err, startTime)
+	if err = populate(results, i.logger); err != nil {
+		return assembleErrors(reference, results, pb.InspectionResults_INTERPRETING_INSPECTION_RESULTS, err, startTime)
 	}
 
 	results.ElapsedTimeMs = time.Now().Sub(startTime).Milliseconds()
@@ -117,7 +117,7 @@ func (i *bootInspector) Inspect(reference string) (*pb.InspectionResults, error)
 }
 
 // assembleErrors sets the errorWhen field, and generates an error object.
-func (i *bootInspector) assembleErrors(reference string, results *pb.InspectionResults,
+func assembleErrors(reference string, results *pb.InspectionResults,
 	errorWhen pb.InspectionResults_ErrorWhen, err error, startTime time.Time) (*pb.InspectionResults, error) {
 	results.ErrorWhen = errorWhen
 	if err != nil {
@@ -131,7 +131,7 @@ func (i *bootInspector) assembleErrors(reference string, results *pb.InspectionR
 
 // validate checks the fields from a pb.InspectionResults object for consistency, returning
 // an error if an issue is found.
-func (i *bootInspector) validate(results *pb.InspectionResults) error {
+func validate(results *pb.InspectionResults) error {
 	// Only populate OsRelease when one OS is found.
 	if results.OsCount != 1 {
 		if results.OsRelease != nil {
@@ -171,7 +171,7 @@ func (i *bootInspector) validate(results *pb.InspectionResults) error {
 // populate fills the fields in the pb.InspectionResults that are not returned by the worker.
 // This is required since the worker is unaware of import-specific idioms, such as the formatting
 // used by gcloud's --os argument.
-func (i *bootInspector) populate(results *pb.InspectionResults) error {
+func populate(results *pb.InspectionResults, logger logging.Logger) error {
 	if results.ErrorWhen == pb.InspectionResults_NO_ERROR && results.OsCount == 1 {
 		distroEnum, major, minor := results.OsRelease.DistroId,
 			results.OsRelease.MajorVersion, results.OsRelease.MinorVersion
@@ -182,7 +182,7 @@ func (i *bootInspector) populate(results *pb.InspectionResults) error {
 		version, err := distro.FromComponents(distroName, major, minor,
 			results.OsRelease.Architecture.String())
 		if err != nil {
-			i.logger.Trace(
+			logger.Trace(
 				fmt.Sprintf("Failed to interpret version distro=%q, major=%q, minor=%q: %v",
 					distroEnum, major, minor, err))
 		} else {
@@ -0,0 +1,418 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package offline
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// The ext2, ext3, and ext4 on-disk format is documented at
+// https://www.kernel.org/doc/html/latest/filesystems/ext4/index.html
+const (
+	extSuperblockOffset = 1024
+	extMagic            = 0xef53
+	extRootInode        = 2
+
+	extCompatHasJournal = 0x4
+
+	extIncompatFiletype   = 0x2
+	extIncompatExtents    = 0x40
+	extIncompat64Bit      = 0x80
+	extIncompatMetaBG     = 0x10
+	extIncompatFlexBG     = 0x200
+	extIncompatInlineData = 0x8000
+
+	extRoCompatSparseSuper = 0x1
+
+	extInodeFlagEncrypted  = 0x800
+	extInodeFlagExtents    = 0x80000
+	extInodeFlagInlineData = 0x10000000
+
+	extExtentMagic      = 0xf30a
+	extMaxExtentDepth   = 5
+	extInitializedLimit = 32768
+
+	extDirectBlocks = 12
+	inodeBlockSize  = 60
+
+	modeTypeMask = 0xf000
+	modeDir      = 0x4000
+	modeFile     = 0x8000
+	modeSymlink  = 0xa000
+)
+
+// extFS reads ext2, ext3, and ext4 filesystems.
+type extFS struct {
+	r               io.ReaderAt
+	blockSize       int64
+	inodeSize       int64
+	inodesPerGroup  uint32
+	blocksPerGroup  uint32
+	firstDataBlock  uint32
+	descSize        int64
+	compat          uint32
+	incompat        uint32
+	roCompat        uint32
+	firstMetaBG     uint32
+	groupTableStart int64
+}
+
+// extInode is an inode read from the inode table.
+type extInode struct {
+	number uint32
+	mode   uint16
+	size   int64
+	flags  uint32
+	block  []byte
+}
+
+func (i *extInode) kind() nodeKind {
+	switch i.mode & modeTypeMask {
+	case modeDir:
+		return dirNode
+	case modeFile:
+		return fileNode
+	case modeSymlink:
+		return symlinkNode
+	}
+	return otherNode
+}
+
+func newExtFS(r io.ReaderAt) (*extFS, error) {
+	sb := make([]byte, 1024)
+	if err := readFull(r, sb, extSuperblockOffset); err != nil {
+		return nil, fmt.Errorf("failed to read ext superblock: %w", err)
+	}
+	logBlockSize := binary.LittleEndian.Uint32(sb[0x18:])
+	if logBlockSize > 6 {
+		return nil, fmt.Errorf("ext block size 2^%d is invalid", logBlockSize+10)
+	}
+	fs := &extFS{
+		r:              r,
+		blockSize:      1024 << logBlockSize,
+		inodeSize:      128,
+		firstDataBlock: binary.LittleEndian.Uint32(sb[0x14:]),
+		blocksPerGroup: binary.LittleEndian.Uint32(sb[0x20:]),
+		inodesPerGroup: binary.LittleEndian.Uint32(sb[0x28:]),
+		compat:         binary.LittleEndian.Uint32(sb[0x5c:]),
+		incompat:       binary.LittleEndian.Uint32(sb[0x60:]),
+		roCompat:       binary.LittleEndian.Uint32(sb[0x64:]),
+		firstMetaBG:    binary.LittleEndian.Uint32(sb[0x104:]),
+		descSize:       32,
+	}
+	if revision := binary.LittleEndian.Uint32(sb[0x4c:]); revision > 0 {
+		fs.inodeSize = int64(binary.LittleEndian.Uint16(sb[0x58:]))
+	}
+	if fs.incompat&extIncompat64Bit != 0 {
+		fs.descSize = int64(binary.LittleEndian.Uint16(sb[0xfe:]))
+	}
+	if fs.inodeSize < 128 || fs.inodesPerGroup == 0 || fs.blocksPerGroup == 0 || fs.descSize < 32 {
+		return nil, errors.New("ext superblock is invalid")
+	}
+	fs.groupTableStart = (int64(fs.firstDataBlock) + 1) * fs.blockSize
+	return fs, nil
+}
+
+func (fs *extFS) fsType() string {
+	switch {
+	case fs.incompat&(extIncompatExtents|extIncompat64Bit|extIncompatFlexBG) != 0:
+		return "ext4"
+	case fs.compat&extCompatHasJournal != 0:
+		return "ext3"
+	}
+	return "ext2"
+}
+
+func (fs *extFS) root() (node, error) {
+	return fs.inode(extRootInode)
+}
+
+func (fs *extFS) inode(number uint32) (*extInode, error) {
+	if number == 0 {
+		return nil, errors.New("ext inode 0 is invalid")
+	}
+	group := (number - 1) / fs.inodesPerGroup
+	index := int64((number - 1) % fs.inodesPerGroup)
+	desc, err := fs.groupDescriptor(group)
+	if err != nil {
+		return nil, err
+	}
+	inodeTable := int64(binary.LittleEndian.Uint32(desc[0x8:]))
+	if fs.descSize >= 64 {
+		inodeTable |= int64(binary.LittleEndian.Uint32(desc[0x28:])) << 32
+	}
+	raw := make([]byte, 128)
+	if err := readFull(fs.r, raw, inodeTable*fs.blockSize+index*fs.inodeSize); err != nil {
+		return nil, fmt.Errorf("failed to read ext inode %d: %w", number, err)
+	}
+	return &extInode{
+		number: number,
+		mode:   binary.LittleEndian.Uint16(raw[0x0:]),
+		size: int64(binary.LittleEndian.Uint32(raw[0x4:])) |
+			int64(binary.LittleEndian.Uint32(raw[0x6c:]))<<32,
+		flags: binary.LittleEndian.Uint32(raw[0x20:]),
+		block: raw[0x28 : 0x28+inodeBlockSize],
+	}, nil
+}
+
+// groupDescriptor returns the descriptor for a block group. When the meta_bg
+// feature is enabled, descriptors are spread across the filesystem, with one
+// block of descriptors stored at the start of each meta group.
+func (fs *extFS) groupDescriptor(group uint32) ([]byte, error) {
+	offset := fs.groupTableStart + int64(group)*fs.descSize
+	descPerBlock := uint32(fs.blockSize / fs.descSize)
+	if fs.incompat&extIncompatMetaBG != 0 && group/descPerBlock >= fs.firstMetaBG {
+		firstGroup := group / descPerBlock * descPerBlock
+		block := int64(firstGroup)*int64(fs.blocksPerGroup) + int64(fs.firstDataBlock)
+		if fs.groupHasSuperblock(firstGroup) {
+			block++
+		}
+		offset = block*fs.blockSize + int64(group%descPerBlock)*fs.descSize
+	}
+	desc := make([]byte, fs.descSize)
+	if err := readFull(fs.r, desc, offset); err != nil {
+		return nil, fmt.Errorf("failed to read ext group descriptor %d: %w", group, err)
+	}
+	return desc, nil
+}
+
+// groupHasSuperblock returns whether a block group contains a backup
+// of the superblock. With sparse_super, backups are only stored in
+// groups 0, 1, and powers of 3, 5, and 7.
+func (fs *extFS) groupHasSuperblock(group uint32) bool {
+	if fs.roCompat&extRoCompatSparseSuper == 0 || group <= 1 {
+		return true
+	}
+	for _, base := range []uint32{3, 5, 7} {
+		n := base
+		for n < group {
+			n *= base
+		}
+		if n == group {
+			return true
+		}
+	}
+	return false
+}
+
+func (fs *extFS) lookup(dir node, name string) (node, error) {
+	var found uint32
+	err := fs.entries(dir.(*extInode), func(entry string, number uint32) bool {
+		if entry == name {
+			found = number
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == 0 {
+		return nil, os.ErrNotExist
+	}
+	return fs.inode(found)
+}
+
+func (fs *extFS) list(dir node) ([]string, error) {
+	var names []string
+	err := fs.entries(dir.(*extInode), func(entry string, number uint32) bool {
+		names = append(names, entry)
+		return true
+	})
+	return names, err
+}
+
+// entries calls visit for each entry of a directory, until visit returns
+// false. Directories are scanned linearly, since htree indexes are stored
+// as deleted entries that are skipped by the scan.
+func (fs *extFS) entries(dir *extInode, visit func(name string, number uint32) bool) error {
+	content, err := fs.readAll(dir)
+	if err != nil {
+		return err
+	}
+	for pos := 0; pos+8 <= len(content); {
+		number := binary.LittleEndian.Uint32(content[pos:])
+		recLen := int(binary.LittleEndian.Uint16(content[pos+4:]))
+		nameLen := int(binary.LittleEndian.Uint16(content[pos+6:]))
+		if fs.incompat&extIncompatFiletype != 0 {
+			nameLen = int(content[pos+6])
+		}
+		if recLen < 8 || pos+8+nameLen > len(content) {
+			return errors.New("ext directory entry is invalid")
+		}
+		if number != 0 && !visit(string(content[pos+8:pos+8+nameLen]), number) {
+			return nil
+		}
+		pos += recLen
+	}
+	return nil
+}
+
+func (fs *extFS) readlink(n node) (string, error) {
+	inode := n.(*extInode)
+	// Targets shorter than the inode's block array are stored within it.
+	if inode.size < inodeBlockSize && inode.flags&extInodeFlagExtents == 0 {
+		return string(inode.block[:inode.size]), nil
+	}
+	target, err := fs.readAll(inode)
+	return string(target), err
+}
+
+func (fs *extFS) open(n node) (File, error) {
+	inode := n.(*extInode)
+	if inode.flags&extInodeFlagEncrypted != 0 {
+		return nil, fmt.Errorf("ext inode %d is encrypted", inode.number)
+	}
+	if inode.flags&extInodeFlagInlineData != 0 {
+		if inode.size > inodeBlockSize {
+			return nil, fmt.Errorf("ext inode %d: inline data larger than %d bytes is not supported",
+				inode.number, inodeBlockSize)
+		}
+		return newBytesFile(inode.block[:inode.size]), nil
+	}
+	var extents []extent
+	var err error
+	if inode.flags&extInodeFlagExtents != 0 {
+		extents, err = fs.extentTree(inode.block, extMaxExtentDepth)
+	} else {
+		extents, err = fs.blockMap(inode)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ext inode %d: %w", inode.number, err)
+	}
+	return newExtentFile(fs.r, extents, inode.size), nil
+}
+
+func (fs *extFS) readAll(inode *extInode) ([]byte, error) {
+	if inode.flags&extInodeFlagInlineData != 0 && inode.kind() == dirNode {
+		// Inline directories start with the parent's inode number.
+		if inode.size > inodeBlockSize {
+			return nil, fmt.Errorf("ext inode %d: inline data larger than %d bytes is not supported",
+				inode.number, inodeBlockSize)
+		}
+		return inode.block[4:inode.size], nil
+	}
+	f, err := fs.open(inode)
+	if err != nil {
+		return nil, err
+	}
+	if f.Size() > maxFileSize {
+		return nil, fmt.Errorf("ext inode %d is larger than %d bytes", inode.number, maxFileSize)
+	}
+	content := make([]byte, f.Size())
+	if err := readFull(f, content, 0); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// extentTree reads the extents referenced by an extent tree node.
+func (fs *extFS) extentTree(nodeData []byte, maxDepth int) ([]extent, error) {
+	if binary.LittleEndian.Uint16(nodeData) != extExtentMagic {
+		return nil, errors.New("extent header not found")
+	}
+	entries := int(binary.LittleEndian.Uint16(nodeData[2:]))
+	depth := int(binary.LittleEndian.Uint16(nodeData[6:]))
+	if depth > maxDepth || 12+entries*12 > len(nodeData) {
+		return nil, errors.New("extent tree is invalid")
+	}
+	var extents []extent
+	for i := 0; i < entries; i++ {
+		entry := nodeData[12+i*12:]
+		if depth == 0 {
+			length := int64(binary.LittleEndian.Uint16(entry[4:]))
+			physical := int64(binary.LittleEndian.Uint32(entry[8:])) |
+				int64(binary.LittleEndian.Uint16(entry[6:]))<<32
+			e := extent{
+				logical:  int64(binary.LittleEndian.Uint32(entry)) * fs.blockSize,
+				physical: physical * fs.blockSize,
+				length:   length * fs.blockSize,
+			}
+			if length > extInitializedLimit {
+				// Uninitialized extents are preallocated, and read as zeros.
+				e.physical = -1
+				e.length = (length - extInitializedLimit) * fs.blockSize
+			}
+			extents = append(extents, e)
+			continue
+		}
+		child := int64(binary.LittleEndian.Uint32(entry[4:])) |
+			int64(binary.LittleEndian.Uint16(entry[8:]))<<32
+		childData := make([]byte, fs.blockSize)
+		if err := readFull(fs.r, childData, child*fs.blockSize); err != nil {
+			return nil, err
+		}
+		childExtents, err := fs.extentTree(childData, depth-1)
+		if err != nil {
+			return nil, err
+		}
+		extents = append(extents, childExtents...)
+	}
+	return extents, nil
+}
+
+// blockMap reads the direct and indirect block pointers used by ext2 and ext3.
+func (fs *extFS) blockMap(inode *extInode) ([]extent, error) {
+	blocks := (inode.size + fs.blockSize - 1) / fs.blockSize
+	var extents []extent
+	var logical int64
+	add := func(physical uint32) {
+		if physical != 0 {
+			extents = append(extents, extent{logical * fs.blockSize, int64(physical) * fs.blockSize, fs.blockSize})
+		}
+		logical++
+	}
+	var walk func(block uint32, level int) error
+	walk = func(block uint32, level int) error {
+		perBlock := fs.blockSize / 4
+		covered := int64(1)
+		for i := 0; i < level; i++ {
+			covered *= perBlock
+		}
+		if block == 0 {
+			// The whole range is sparse.
+			logical += covered
+			return nil
+		}
+		if level == 0 {
+			add(block)
+			return nil
+		}
+		pointers := make([]byte, fs.blockSize)
+		if err := readFull(fs.r, pointers, int64(block)*fs.blockSize); err != nil {
+			return err
+		}
+		for i := int64(0); i < perBlock && logical < blocks; i++ {
+			if err := walk(binary.LittleEndian.Uint32(pointers[i*4:]), level-1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for i := 0; i < extDirectBlocks+3 && logical < blocks; i++ {
+		level := 0
+		if i >= extDirectBlocks {
+			level = i - extDirectBlocks + 1
+		}
+		if err := walk(binary.LittleEndian.Uint32(inode.block[i*4:]), level); err != nil {
+			return nil, err
+		}
+	}
+	return extents, nil
+}
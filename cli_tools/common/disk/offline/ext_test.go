@@ -0,0 +1,101 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package offline
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExt4_ReadsFiles(t *testing.T) {
+	fs := openFixture(t, "ubuntu-ext4.img.gz")
+	assert.Equal(t, "ext4", fs.Type())
+
+	// /etc/os-release is a relative symlink to /usr/lib/os-release.
+	content, err := ReadFile(fs, "/etc/os-release")
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `VERSION_ID="20.04"`)
+
+	// /bin is a symlink to a directory.
+	assert.True(t, fs.IsDir("/bin"))
+	assert.True(t, fs.IsFile("/bin/bash"))
+	assert.True(t, fs.IsFile("/usr/bin/../lib/os-release"))
+	assert.False(t, fs.IsFile("/etc"))
+	assert.False(t, fs.IsDir("/etc/debian_version"))
+
+	// /etc's entries span multiple blocks.
+	for _, i := range []int{1, 100, 200} {
+		content, err := ReadFile(fs, fmt.Sprintf("/etc/config-file-%d.conf", i))
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("%d\n", i), string(content))
+	}
+
+	names, err := fs.ReadDir("/etc")
+	assert.NoError(t, err)
+	assert.Contains(t, names, "config-file-200.conf")
+	assert.NotContains(t, names, ".")
+	assert.NotContains(t, names, "..")
+
+	_, err = fs.Open("/etc/missing")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	_, err = fs.Open("/etc/debian_version/child")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestExt2_ReadsFiles(t *testing.T) {
+	fs := openFixture(t, "centos-ext2.img.gz")
+	assert.Equal(t, "ext2", fs.Type())
+
+	content, err := ReadFile(fs, "/etc/redhat-release")
+	assert.NoError(t, err)
+	assert.Equal(t, "CentOS Linux release 7.9.2009 (Core)\n", string(content))
+
+	// large-file uses an indirect block.
+	content, err = ReadFile(fs, "/etc/large-file")
+	assert.NoError(t, err)
+	var expected strings.Builder
+	for i := 1; i <= 5000; i++ {
+		fmt.Fprintf(&expected, "%d\n", i)
+	}
+	assert.Equal(t, expected.String(), string(content))
+}
+
+// openFixture opens a gzipped filesystem image from testdata. The
+// images are created by testdata/generate.sh.
+func openFixture(t *testing.T, name string) Filesystem {
+	content := readFixture(t, name)
+	fs, err := OpenFilesystem(bytes.NewReader(content), int64(len(content)))
+	assert.NoError(t, err)
+	return fs
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	compressed, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	assert.NoError(t, err)
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	assert.NoError(t, err)
+	content, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	return content
+}
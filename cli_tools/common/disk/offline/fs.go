@@ -0,0 +1,337 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package offline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	// Matches Linux's MAXSYMLINKS.
+	maxSymlinkHops = 40
+
+	// Upper bound for files that are read into memory.
+	maxFileSize = 512 << 20
+)
+
+// errUnknownFilesystem is returned by OpenFilesystem when the partition
+// doesn't contain a supported filesystem.
+var errUnknownFilesystem = errors.New("filesystem not recognized")
+
+// Filesystem is a read-only view of a filesystem.
+type Filesystem interface {
+	// Type is the name of the filesystem, using blkid's naming,
+	// such as ext4, xfs, or ntfs.
+	Type() string
+
+	// Open returns the content of the regular file at path. Paths are
+	// absolute, use forward slashes, and symlinks are followed.
+	// An error satisfying errors.Is(err, os.ErrNotExist) is returned when the
+	// path is not found.
+	Open(path string) (File, error)
+
+	// IsFile returns whether path resolves to a regular file.
+	IsFile(path string) bool
+
+	// IsDir returns whether path resolves to a directory.
+	IsDir(path string) bool
+
+	// ReadDir returns the sorted names of the entries in the directory at
+	// path, excluding "." and "..".
+	ReadDir(path string) ([]string, error)
+}
+
+// File is the content of a regular file.
+type File interface {
+	io.ReaderAt
+	Size() int64
+}
+
+// OpenFilesystem detects the filesystem stored in r, and returns
+// a Filesystem to read it. size is the size of the partition in bytes.
+func OpenFilesystem(r io.ReaderAt, size int64) (Filesystem, error) {
+	probe := make([]byte, 2048)
+	if err := readFull(r, probe, 0); err != nil {
+		return nil, err
+	}
+	var nodes nodeFS
+	var err error
+	switch {
+	case binary.LittleEndian.Uint16(probe[extSuperblockOffset+0x38:]) == extMagic:
+		nodes, err = newExtFS(r)
+	case bytes.HasPrefix(probe, []byte(xfsMagic)):
+		nodes, err = newXfsFS(r)
+	case bytes.Equal(probe[3:11], []byte(ntfsOEMID)):
+		nodes, err = newNtfsFS(r)
+	default:
+		return nil, errUnknownFilesystem
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &filesystem{nodes}, nil
+}
+
+// ReadFile reads the regular file at path into memory.
+func ReadFile(fs Filesystem, path string) ([]byte, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if f.Size() > maxFileSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", path, maxFileSize)
+	}
+	content := make([]byte, f.Size())
+	if err := readFull(f, content, 0); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return content, nil
+}
+
+type nodeKind int
+
+const (
+	otherNode nodeKind = iota
+	fileNode
+	dirNode
+	symlinkNode
+)
+
+// node is a file, directory, or other object in a filesystem,
+// such as an inode or an MFT record.
+type node interface {
+	kind() nodeKind
+}
+
+// nodeFS is implemented by each filesystem. Path resolution is shared,
+// and implemented by filesystem.
+type nodeFS interface {
+	fsType() string
+	root() (node, error)
+	// lookup returns the child of dir with name, or os.ErrNotExist.
+	lookup(dir node, name string) (node, error)
+	// list returns the names of dir's entries, in any order.
+	list(dir node) ([]string, error)
+	readlink(n node) (string, error)
+	open(n node) (File, error)
+}
+
+// filesystem implements Filesystem using a nodeFS.
+type filesystem struct {
+	nodes nodeFS
+}
+
+func (fs *filesystem) Type() string {
+	return fs.nodes.fsType()
+}
+
+func (fs *filesystem) Open(path string) (File, error) {
+	n, err := fs.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	if n.kind() != fileNode {
+		return nil, fmt.Errorf("%s is not a regular file", path)
+	}
+	return fs.nodes.open(n)
+}
+
+func (fs *filesystem) IsFile(path string) bool {
+	n, err := fs.resolve(path)
+	return err == nil && n.kind() == fileNode
+}
+
+func (fs *filesystem) IsDir(path string) bool {
+	n, err := fs.resolve(path)
+	return err == nil && n.kind() == dirNode
+}
+
+func (fs *filesystem) ReadDir(path string) ([]string, error) {
+	n, err := fs.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	if n.kind() != dirNode {
+		return nil, fmt.Errorf("%s is not a directory", path)
+	}
+	entries, err := fs.nodes.list(n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var names []string
+	for _, name := range entries {
+		if name != "." && name != ".." {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// resolve walks path from the root directory, following symlinks.
+// Symlinks are resolved relative to the filesystem's root, which
+// matches how they're interpreted when the filesystem is mounted at /.
+func (fs *filesystem) resolve(path string) (node, error) {
+	root, err := fs.nodes.root()
+	if err != nil {
+		return nil, err
+	}
+	dirs := []node{root}
+	components := strings.Split(path, "/")
+	hops := 0
+	for len(components) > 0 {
+		name := components[0]
+		components = components[1:]
+		switch name {
+		case "", ".":
+			continue
+		case "..":
+			if len(dirs) > 1 {
+				dirs = dirs[:len(dirs)-1]
+			}
+			continue
+		}
+		parent := dirs[len(dirs)-1]
+		if parent.kind() != dirNode {
+			return nil, fmt.Errorf("%s: %w", path, os.ErrNotExist)
+		}
+		child, err := fs.nodes.lookup(parent, name)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("%s: %w", path, os.ErrNotExist)
+			}
+			return nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		if child.kind() != symlinkNode {
+			dirs = append(dirs, child)
+			continue
+		}
+		if hops++; hops > maxSymlinkHops {
+			return nil, fmt.Errorf("failed to resolve %s: too many levels of symbolic links", path)
+		}
+		target, err := fs.nodes.readlink(child)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		if strings.HasPrefix(target, "/") {
+			dirs = dirs[:1]
+		}
+		components = append(strings.Split(target, "/"), components...)
+	}
+	return dirs[len(dirs)-1], nil
+}
+
+// extent maps a range of a file to a range of the partition. All
+// fields are in bytes. Extents with a negative physical offset are
+// sparse, and read as zeros.
+type extent struct {
+	logical  int64
+	physical int64
+	length   int64
+}
+
+// extentFile is a File whose content is stored in extents.
+type extentFile struct {
+	r       io.ReaderAt
+	extents []extent
+	size    int64
+}
+
+// newExtentFile returns a File for extents. extents may be unordered
+// and may contain adjacent ranges.
+func newExtentFile(r io.ReaderAt, extents []extent, size int64) *extentFile {
+	sort.Slice(extents, func(i, j int) bool {
+		return extents[i].logical < extents[j].logical
+	})
+	var merged []extent
+	for _, e := range extents {
+		if e.length <= 0 {
+			continue
+		}
+		if len(merged) > 0 {
+			last := &merged[len(merged)-1]
+			if last.logical+last.length == e.logical && last.physical >= 0 && e.physical >= 0 &&
+				last.physical+last.length == e.physical {
+				last.length += e.length
+				continue
+			}
+		}
+		merged = append(merged, e)
+	}
+	return &extentFile{r, merged, size}
+}
+
+func (f *extentFile) Size() int64 {
+	return f.size
+}
+
+func (f *extentFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= f.size {
+		return 0, io.EOF
+	}
+	var err error
+	if remaining := f.size - off; int64(len(p)) > remaining {
+		p = p[:remaining]
+		err = io.EOF
+	}
+	read := 0
+	for read < len(p) {
+		pos := off + int64(read)
+		// The first extent that ends after pos.
+		i := sort.Search(len(f.extents), func(i int) bool {
+			return f.extents[i].logical+f.extents[i].length > pos
+		})
+		n := int64(len(p) - read)
+		if i == len(f.extents) || f.extents[i].logical > pos {
+			// A hole in the file.
+			if i < len(f.extents) && f.extents[i].logical-pos < n {
+				n = f.extents[i].logical - pos
+			}
+			zero(p[read : read+int(n)])
+		} else {
+			e := f.extents[i]
+			if e.logical+e.length-pos < n {
+				n = e.logical + e.length - pos
+			}
+			if e.physical < 0 {
+				zero(p[read : read+int(n)])
+			} else if readErr := readFull(f.r, p[read:read+int(n)], e.physical+pos-e.logical); readErr != nil {
+				return read, readErr
+			}
+		}
+		read += int(n)
+	}
+	return read, err
+}
+
+// bytesFile is a File whose content is stored in the filesystem's
+// metadata, such as NTFS resident data or XFS local symlinks.
+type bytesFile struct {
+	*bytes.Reader
+}
+
+func newBytesFile(b []byte) bytesFile {
+	return bytesFile{bytes.NewReader(b)}
+}
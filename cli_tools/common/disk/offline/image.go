@@ -0,0 +1,150 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+// Package offline inspects disk image files by reading them directly, without
+// inflating them to a persistent disk or running a worker instance.
+package offline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	sectorSize = 512
+
+	vhdFooterSize   = 512
+	vhdCookie       = "conectix"
+	vhdDiskTypeFlat = 2
+)
+
+// Image is the virtual disk contained in a disk image file. Reads are in
+// terms of the guest's view of the disk, after the file format is decoded.
+type Image interface {
+	io.ReaderAt
+
+	// Size is the virtual size of the disk, in bytes.
+	Size() int64
+
+	// Format is the name of the file format, using qemu-img's
+	// naming: raw, qcow2, vmdk, or vpc.
+	Format() string
+}
+
+// NewImage detects the format of the disk image file in r, and returns
+// an Image that decodes it. fileSize is the size of the file in bytes.
+//
+// Supported formats are:
+//   - raw
+//   - qcow2 (versions 2 and 3), without a backing file or encryption
+//   - vmdk with a single hosted sparse extent, including streamOptimized
+//   - vpc (VHD) with a fixed-size disk
+func NewImage(r io.ReaderAt, fileSize int64) (Image, error) {
+	header := make([]byte, sectorSize)
+	n, err := r.ReadAt(header, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte(qcow2Magic)):
+		return newQcow2Image(r)
+	case bytes.HasPrefix(header, []byte(vmdkSparseMagic)):
+		return newVmdkImage(r, fileSize)
+	case bytes.Contains(header, []byte(vmdkDescriptorSignature)):
+		return nil, errors.New("vmdk files with a separate descriptor are not supported")
+	case bytes.HasPrefix(header, []byte(vhdCookie)):
+		return nil, errors.New("dynamic and differencing vpc (VHD) files are not supported")
+	}
+
+	if fileSize >= vhdFooterSize {
+		footer := make([]byte, vhdFooterSize)
+		if _, err := r.ReadAt(footer, fileSize-vhdFooterSize); err != nil {
+			return nil, err
+		}
+		if bytes.HasPrefix(footer, []byte(vhdCookie)) {
+			if diskType := binary.BigEndian.Uint32(footer[60:]); diskType != vhdDiskTypeFlat {
+				return nil, fmt.Errorf("vpc (VHD) disk type %d is not supported", diskType)
+			}
+			return &rawImage{io.NewSectionReader(r, 0, fileSize-vhdFooterSize), "vpc"}, nil
+		}
+	}
+	return &rawImage{io.NewSectionReader(r, 0, fileSize), "raw"}, nil
+}
+
+// rawImage is an Image where the file's content is the disk's content.
+type rawImage struct {
+	*io.SectionReader
+	format string
+}
+
+func (i *rawImage) Format() string {
+	return i.format
+}
+
+// readFull reads len(p) bytes from r at off. It differs from io.ReaderAt's
+// contract by treating a short read at the end of r as an error.
+func readFull(r io.ReaderAt, p []byte, off int64) error {
+	n, err := r.ReadAt(p, off)
+	if n == len(p) {
+		return nil
+	}
+	if err == nil || errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readChunked implements io.ReaderAt for formats that store a disk as a
+// sequence of equally sized chunks, such as clusters or grains. readChunk
+// fills p with the content of the chunk at index, starting at offset.
+func readChunked(p []byte, off int64, size int64, chunkSize int64,
+	readChunk func(p []byte, index int64, offset int64) error) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= size {
+		return 0, io.EOF
+	}
+	var err error
+	if remaining := size - off; int64(len(p)) > remaining {
+		p = p[:remaining]
+		err = io.EOF
+	}
+	read := 0
+	for read < len(p) {
+		index, offset := off/chunkSize, off%chunkSize
+		n := int(chunkSize - offset)
+		if n > len(p)-read {
+			n = len(p) - read
+		}
+		if chunkErr := readChunk(p[read:read+n], index, offset); chunkErr != nil {
+			return read, chunkErr
+		}
+		read += n
+		off += int64(n)
+	}
+	return read, err
+}
+
+// zero fills p with zeros.
+func zero(p []byte) {
+	for i := range p {
+		p[i] = 0
+	}
+}
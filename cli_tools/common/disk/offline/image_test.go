@@ -0,0 +1,267 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package offline
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewImage_DecodesFormats(t *testing.T) {
+	disk := newTestDiskContent(300 * 1024)
+	for _, tt := range []struct {
+		name           string
+		file           []byte
+		expectedFormat string
+	}{
+		{"raw", disk, "raw"},
+		{"vpc", append(append([]byte{}, disk...), newVHDFooter(vhdDiskTypeFlat)...), "vpc"},
+		{"qcow2 v2", newQcow2(t, disk, qcow2Options{version: 2}), "qcow2"},
+		{"qcow2 v3", newQcow2(t, disk, qcow2Options{version: 3}), "qcow2"},
+		{"qcow2 zero clusters", newQcow2(t, disk, qcow2Options{version: 3, zeroFlag: true}), "qcow2"},
+		{"qcow2 deflate", newQcow2(t, disk, qcow2Options{version: 3, compression: "deflate"}), "qcow2"},
+		{"qcow2 zstd", newQcow2(t, disk, qcow2Options{version: 3, compression: "zstd"}), "qcow2"},
+		{"vmdk monolithicSparse", newVmdk(t, disk, false), "vmdk"},
+		{"vmdk streamOptimized", newVmdk(t, disk, true), "vmdk"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := NewImage(bytes.NewReader(tt.file), int64(len(tt.file)))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedFormat, img.Format())
+			assert.Equal(t, int64(len(disk)), img.Size())
+
+			actual := make([]byte, len(disk))
+			n, err := img.ReadAt(actual, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, len(disk), n)
+			assert.Equal(t, disk, actual)
+
+			// Unaligned reads that span multiple clusters.
+			actual = make([]byte, 10000)
+			_, err = img.ReadAt(actual, 4000)
+			assert.NoError(t, err)
+			assert.Equal(t, disk[4000:14000], actual)
+
+			// Reads past the end.
+			n, err = img.ReadAt(actual, int64(len(disk))-100)
+			assert.Equal(t, io.EOF, err)
+			assert.Equal(t, 100, n)
+			assert.Equal(t, disk[len(disk)-100:], actual[:100])
+		})
+	}
+}
+
+func TestNewImage_RejectsUnsupportedFiles(t *testing.T) {
+	disk := newTestDiskContent(64 * 1024)
+	backingFile := newQcow2(t, disk, qcow2Options{version: 3})
+	binary.BigEndian.PutUint64(backingFile[8:], 1024)
+	encrypted := newQcow2(t, disk, qcow2Options{version: 3})
+	binary.BigEndian.PutUint32(encrypted[32:], 2)
+
+	for _, tt := range []struct {
+		name          string
+		file          []byte
+		expectedError string
+	}{
+		{"qcow2 with backing file", backingFile, "backing file"},
+		{"encrypted qcow2", encrypted, "encrypted"},
+		{"vmdk descriptor", []byte("# Disk DescriptorFile\nversion=1\n"), "separate descriptor"},
+		{"dynamic vpc", newVHDFooter(3), "dynamic"},
+		{"vpc with unknown type", append(append([]byte{}, disk...), newVHDFooter(5)...), "type 5"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewImage(bytes.NewReader(tt.file), int64(len(tt.file)))
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedError)
+		})
+	}
+}
+
+// newTestDiskContent returns a disk with random content, where every third
+// 4KiB chunk is zero.
+func newTestDiskContent(size int) []byte {
+	disk := make([]byte, size)
+	rand.New(rand.NewSource(0)).Read(disk)
+	for offset := 0; offset < size; offset += 3 * 4096 {
+		end := offset + 4096
+		if end > size {
+			end = size
+		}
+		zero(disk[offset:end])
+	}
+	return disk
+}
+
+func newVHDFooter(diskType uint32) []byte {
+	footer := make([]byte, vhdFooterSize)
+	copy(footer, vhdCookie)
+	binary.BigEndian.PutUint32(footer[60:], diskType)
+	return footer
+}
+
+type qcow2Options struct {
+	version uint32
+	// compression is empty, deflate, or zstd.
+	compression string
+	// zeroFlag marks zero clusters with the zero flag, rather than
+	// leaving them unallocated.
+	zeroFlag bool
+}
+
+// newQcow2 encodes disk as a qcow2 file with 4KiB clusters. Clusters
+// containing only zeros are not allocated.
+func newQcow2(t *testing.T, disk []byte, opts qcow2Options) []byte {
+	const clusterBits = 12
+	const clusterSize = 1 << clusterBits
+	clusters := (len(disk) + clusterSize - 1) / clusterSize
+	l2Tables := (clusters + clusterSize/8 - 1) / (clusterSize / 8)
+
+	// Header, L1 table, and L2 tables each use whole clusters.
+	file := make([]byte, (2+l2Tables)*clusterSize)
+	copy(file, qcow2Magic)
+	binary.BigEndian.PutUint32(file[4:], opts.version)
+	binary.BigEndian.PutUint32(file[20:], clusterBits)
+	binary.BigEndian.PutUint64(file[24:], uint64(len(disk)))
+	binary.BigEndian.PutUint32(file[36:], uint32(l2Tables))
+	binary.BigEndian.PutUint64(file[40:], clusterSize)
+	if opts.version == 3 {
+		binary.BigEndian.PutUint32(file[100:], 112)
+		if opts.compression == "zstd" {
+			binary.BigEndian.PutUint64(file[72:], qcow2IncompatibleCompressionType)
+			file[104] = qcow2CompressionZstd
+		}
+	}
+	for i := 0; i < l2Tables; i++ {
+		binary.BigEndian.PutUint64(file[clusterSize+i*8:], uint64((2+i)*clusterSize)|1<<63)
+	}
+
+	for i := 0; i < clusters; i++ {
+		cluster := make([]byte, clusterSize)
+		copy(cluster, disk[i*clusterSize:])
+		var entry uint64
+		switch {
+		case isZero(cluster) && opts.zeroFlag:
+			entry = qcow2ZeroFlag
+		case isZero(cluster):
+			continue
+		case opts.compression != "":
+			// Compressed clusters aren't aligned to sectors.
+			file = append(file, make([]byte, 100)...)
+			host := uint64(len(file))
+			compressed := compress(t, opts.compression, cluster)
+			file = append(file, compressed...)
+			additionalSectors := (host%512 + uint64(len(compressed)) - 1) / 512
+			offsetBits := uint(62 - (clusterBits - 8))
+			entry = qcow2CompressedFlag | additionalSectors<<offsetBits | host
+		default:
+			entry = uint64(len(file)) | 1<<63
+			file = append(file, cluster...)
+		}
+		binary.BigEndian.PutUint64(file[2*clusterSize+i*8:], entry)
+	}
+	return file
+}
+
+func compress(t *testing.T, algorithm string, data []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+	switch algorithm {
+	case "deflate":
+		w, err = flate.NewWriter(&buf, flate.BestCompression)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	case "zstd":
+		w, err = zstd.NewWriter(&buf)
+	}
+	assert.NoError(t, err)
+	_, err = w.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+// newVmdk encodes disk as a vmdk hosted sparse extent with 4KiB grains.
+// Grains containing only zeros are not allocated. When streamOptimized is
+// true, grains are compressed, and the grain directory's location is
+// stored in a footer.
+func newVmdk(t *testing.T, disk []byte, streamOptimized bool) []byte {
+	const grainSectors = 8
+	const grainSize = grainSectors * sectorSize
+	const gtesPerGT = 512
+	grains := (len(disk) + grainSize - 1) / grainSize
+	gts := (grains + gtesPerGT - 1) / gtesPerGT
+
+	header := make([]byte, sectorSize)
+	copy(header, vmdkSparseMagic)
+	binary.LittleEndian.PutUint32(header[4:], 3)
+	binary.LittleEndian.PutUint64(header[12:], uint64(len(disk)/sectorSize))
+	binary.LittleEndian.PutUint64(header[20:], grainSectors)
+	binary.LittleEndian.PutUint32(header[44:], gtesPerGT)
+	if streamOptimized {
+		binary.LittleEndian.PutUint32(header[8:], vmdkFlagCompressed|1<<17)
+		binary.LittleEndian.PutUint16(header[77:], vmdkCompressionDeflate)
+	}
+
+	// Grain table entries for sector 1 indicate zero grains, so the
+	// first grain is placed after the space used for a descriptor.
+	file := append(append([]byte{}, header...), make([]byte, sectorSize)...)
+	gt := make([]byte, gts*gtesPerGT*4)
+	for i := 0; i < grains; i++ {
+		grain := make([]byte, grainSize)
+		copy(grain, disk[i*grainSize:])
+		if isZero(grain) {
+			continue
+		}
+		binary.LittleEndian.PutUint32(gt[i*4:], uint32(len(file)/sectorSize))
+		if streamOptimized {
+			compressed := compress(t, "zlib", grain)
+			marker := make([]byte, 12)
+			binary.LittleEndian.PutUint64(marker, uint64(i*grainSectors))
+			binary.LittleEndian.PutUint32(marker[8:], uint32(len(compressed)))
+			grain = append(marker, compressed...)
+			grain = append(grain, make([]byte, (sectorSize-len(grain)%sectorSize)%sectorSize)...)
+		}
+		file = append(file, grain...)
+	}
+
+	gtOffset := len(file) / sectorSize
+	file = append(file, gt...)
+	gdOffset := len(file) / sectorSize
+	gd := make([]byte, sectorSize)
+	for i := 0; i < gts; i++ {
+		binary.LittleEndian.PutUint32(gd[i*4:], uint32(gtOffset+i*gtesPerGT*4/sectorSize))
+	}
+	file = append(file, gd...)
+
+	if !streamOptimized {
+		binary.LittleEndian.PutUint64(file[56:], uint64(gdOffset))
+		return file
+	}
+	binary.LittleEndian.PutUint64(file[56:], vmdkGDAtEnd)
+	footer := append([]byte{}, header...)
+	binary.LittleEndian.PutUint64(footer[56:], uint64(gdOffset))
+	file = append(file, make([]byte, sectorSize)...) // footer marker
+	file = append(file, footer...)
+	return append(file, make([]byte, sectorSize)...) // end-of-stream marker
+}
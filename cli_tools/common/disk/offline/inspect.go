@@ -0,0 +1,210 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package offline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/proto/go/pb"
+)
+
+// Inspect finds the operating system and boot-related properties of the
+// disk image file at filename.
+//
+// The returned results follow the conventions of the Python boot-inspect
+// package: OsRelease is only populated when exactly one operating system is
+// found, and when an error occurs, ErrorWhen records the stage that failed.
+// Reads fail once ctx is done.
+func Inspect(ctx context.Context, filename string) (*pb.InspectionResults, error) {
+	results := &pb.InspectionResults{}
+	f, err := os.Open(filename)
+	if err != nil {
+		results.ErrorWhen = pb.InspectionResults_MOUNTING_GUEST
+		return results, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		results.ErrorWhen = pb.InspectionResults_MOUNTING_GUEST
+		return results, err
+	}
+	img, err := NewImage(&contextReader{ctx, f}, stat.Size())
+	if err != nil {
+		results.ErrorWhen = pb.InspectionResults_MOUNTING_GUEST
+		return results, err
+	}
+	return InspectImage(img)
+}
+
+// InspectImage finds the operating system and boot-related properties of img.
+func InspectImage(img Image) (*pb.InspectionResults, error) {
+	results := &pb.InspectionResults{}
+	table, err := ReadPartitionTable(img, img.Size())
+	if err != nil {
+		results.ErrorWhen = pb.InspectionResults_INSPECTING_BOOTLOADER
+		return results, err
+	}
+	results.BiosBootable = table.IsBIOSBootable()
+	results.UefiBootable = table.IsUEFIBootable()
+
+	volumes := []volume{}
+	if table.Scheme == NoPartitions {
+		volumes = append(volumes, volume{File: io.NewSectionReader(img, 0, img.Size())})
+	}
+	for _, p := range table.Partitions {
+		volumes = append(volumes, volume{File: io.NewSectionReader(img, p.Start, p.Size)})
+	}
+
+	// Logical volumes are appended to volumes as they're found.
+	for i := 0; i < len(volumes); i++ {
+		v := volumes[i]
+		encryption, err := detectEncryption(v)
+		if err == nil && encryption != pb.InspectionResults_ENCRYPTION_UNKNOWN {
+			results.EncryptedVolumes = append(results.EncryptedVolumes, encryption)
+			continue
+		}
+		var logicalVolumes []LogicalVolume
+		if err == nil {
+			logicalVolumes, err = ReadLogicalVolumes(v, v.Size())
+		}
+		if err == nil && logicalVolumes != nil {
+			for _, lv := range logicalVolumes {
+				volumes = append(volumes, volume{File: lv, onLVM: true})
+			}
+			continue
+		}
+		var fs Filesystem
+		if err == nil {
+			fs, err = OpenFilesystem(v, v.Size())
+		}
+		if err != nil {
+			if isCanceled(err) {
+				results.ErrorWhen = pb.InspectionResults_MOUNTING_GUEST
+				return results, err
+			}
+			// Unrecognized and unreadable partitions are skipped, since they
+			// typically don't contain an operating system, such as swap.
+			continue
+		}
+		guest, err := inspectOS(fs)
+		if err != nil {
+			results.ErrorWhen = pb.InspectionResults_INSPECTING_OS
+			return results, err
+		}
+		if guest == nil {
+			continue
+		}
+		results.OsCount++
+		results.OsRelease = guest.release
+		results.RootFs = fs.Type()
+		results.RootOnLvm = v.onLVM
+		results.KernelVersions = guest.kernelVersions
+		results.VirtioDrivers = guest.virtioDrivers
+		results.CloudInitInstalled = guest.cloudInitInstalled
+	}
+	if results.OsCount != 1 {
+		results.OsRelease = nil
+		results.RootFs = ""
+		results.RootOnLvm = false
+		results.KernelVersions = nil
+		results.VirtioDrivers = nil
+		results.CloudInitInstalled = false
+	}
+	return results, nil
+}
+
+// volume is a partition or logical volume that may contain a filesystem.
+type volume struct {
+	File
+	onLVM bool
+}
+
+// Encrypted volumes are identified by their headers. LUKS is documented at
+// https://gitlab.com/cryptsetup/cryptsetup/-/wikis/Specification, and
+// BitLocker at https://github.com/libyal/libbde/blob/main/documentation/.
+const (
+	luksMagic      = "LUKS\xba\xbe"
+	bitLockerOEMID = "-FVE-FS-"
+)
+
+// detectEncryption returns the encryption used by v, or ENCRYPTION_UNKNOWN
+// if v isn't encrypted.
+func detectEncryption(v io.ReaderAt) (pb.InspectionResults_Encryption, error) {
+	header := make([]byte, 16)
+	if err := readFull(v, header, 0); err != nil {
+		return pb.InspectionResults_ENCRYPTION_UNKNOWN, err
+	}
+	switch {
+	case string(header[:len(luksMagic)]) == luksMagic:
+		return pb.InspectionResults_LUKS, nil
+	case string(header[3:3+len(bitLockerOEMID)]) == bitLockerOEMID:
+		return pb.InspectionResults_BITLOCKER, nil
+	}
+	return pb.InspectionResults_ENCRYPTION_UNKNOWN, nil
+}
+
+// guestOS is an operating system that's installed on a filesystem.
+type guestOS struct {
+	release            *pb.OsRelease
+	kernelVersions     []string
+	virtioDrivers      []string
+	cloudInitInstalled bool
+}
+
+// inspectOS returns the operating system installed on fs,
+// or nil if a supported operating system isn't found.
+func inspectOS(fs Filesystem) (*guestOS, error) {
+	osRelease, err := inspectLinux(fs)
+	if err != nil {
+		return nil, err
+	}
+	if osRelease != nil {
+		guest := &guestOS{release: osRelease, cloudInitInstalled: linuxCloudInitInstalled(fs)}
+		if guest.kernelVersions, err = linuxKernelVersions(fs); err != nil {
+			return nil, err
+		}
+		if guest.virtioDrivers, err = linuxVirtioDrivers(fs, guest.kernelVersions); err != nil {
+			return nil, err
+		}
+		return guest, nil
+	}
+	osRelease, err = inspectWindows(fs)
+	if err != nil || osRelease == nil {
+		return nil, err
+	}
+	return &guestOS{release: osRelease, virtioDrivers: windowsInstalledDrivers(fs)}, nil
+}
+
+// contextReader fails reads once its context is done, which allows
+// inspection to be canceled.
+type contextReader struct {
+	ctx context.Context
+	r   io.ReaderAt
+}
+
+func (c *contextReader) ReadAt(p []byte, off int64) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, fmt.Errorf("inspection canceled: %w", err)
+	}
+	return c.r.ReadAt(p, off)
+}
+
+func isCanceled(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,163 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package offline
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/proto/go/pb"
+)
+
+func TestInspect_GPTInQcow2(t *testing.T) {
+	// An empty ESP, followed by a 2MiB root partition.
+	disk := newGPTDisk(512, []string{espGUID, linuxDataGUID})
+	binary.LittleEndian.PutUint64(disk[2*512+128+40:], 4<<20/512-1)
+	copy(disk[2<<20:], readFixture(t, "ubuntu-ext4.img.gz"))
+
+	path := writeTempFile(t, newQcow2(t, disk, qcow2Options{version: 3}))
+	defer os.Remove(path)
+	results, err := Inspect(context.Background(), path)
+	assert.NoError(t, err)
+	assert.Equal(t, &pb.InspectionResults{
+		OsRelease: &pb.OsRelease{
+			MajorVersion: "20",
+			MinorVersion: "04",
+			DistroId:     pb.Distro_UBUNTU,
+			Architecture: pb.Architecture_X64,
+		},
+		OsCount:      1,
+		UefiBootable: true,
+		RootFs:       "ext4",
+	}, results)
+}
+
+func TestInspect_MBR(t *testing.T) {
+	disk := make([]byte, testDiskSize)
+	copy(disk, []byte{0xeb, 0x63, 0x90})
+	writeMBREntry(disk, 0, 0, mbrActiveFlag, 0x83, 2048, 2048)
+	copy(disk[2048*sectorSize:], readFixture(t, "centos-ext2.img.gz"))
+	// A swap partition, which is skipped.
+	writeMBREntry(disk, 0, 1, 0, 0x82, 4096, 2048)
+
+	results, err := InspectImage(newRawImage(disk))
+	assert.NoError(t, err)
+	assert.Equal(t, &pb.InspectionResults{
+		OsRelease: &pb.OsRelease{
+			MajorVersion: "7",
+			MinorVersion: "9",
+			DistroId:     pb.Distro_CENTOS,
+			Architecture: pb.Architecture_X86,
+		},
+		OsCount:      1,
+		BiosBootable: true,
+		RootFs:       "ext2",
+	}, results)
+}
+
+func TestInspect_LVMAndEncryptedVolumes(t *testing.T) {
+	disk := make([]byte, 3*testDiskSize)
+	// The root filesystem is split across two logical extents.
+	pv := newTestPV(lvmTestMetadata, false)
+	fixture := readFixture(t, "centos-ext2.img.gz")
+	copy(pv[lvmTestExtentOffset(3):], fixture[:lvmTestExtentSize])
+	copy(pv[lvmTestExtentOffset(1):], fixture[lvmTestExtentSize:])
+	writeMBREntry(disk, 0, 0, 0, 0x8e, 2048, testDiskSize/sectorSize)
+	copy(disk[2048*sectorSize:], pv)
+
+	luksStart := 2048 + testDiskSize/sectorSize
+	writeMBREntry(disk, 0, 1, 0, 0x83, uint32(luksStart), 2048)
+	copy(disk[luksStart*sectorSize:], luksMagic)
+	writeMBREntry(disk, 0, 2, 0, 0x07, uint32(luksStart+2048), 2048)
+	copy(disk[(luksStart+2048)*sectorSize+3:], bitLockerOEMID)
+
+	results, err := InspectImage(newRawImage(disk))
+	assert.NoError(t, err)
+	assert.Equal(t, &pb.InspectionResults{
+		OsRelease: &pb.OsRelease{
+			MajorVersion: "7",
+			MinorVersion: "9",
+			DistroId:     pb.Distro_CENTOS,
+			Architecture: pb.Architecture_X86,
+		},
+		OsCount:          1,
+		RootFs:           "ext2",
+		RootOnLvm:        true,
+		EncryptedVolumes: []pb.InspectionResults_Encryption{pb.InspectionResults_LUKS, pb.InspectionResults_BITLOCKER},
+	}, results)
+}
+
+func TestInspect_MultipleOperatingSystems(t *testing.T) {
+	ntfs, _ := newTestNtfs()
+	disk := make([]byte, testDiskSize)
+	writeMBREntry(disk, 0, 0, mbrActiveFlag, 0x83, 2048, 2048)
+	copy(disk[2048*sectorSize:], readFixture(t, "centos-ext2.img.gz"))
+	writeMBREntry(disk, 0, 1, 0, 0x07, 4096, uint32(len(ntfs)/sectorSize))
+	copy(disk[4096*sectorSize:], ntfs)
+
+	results, err := InspectImage(newRawImage(disk))
+	assert.NoError(t, err)
+	assert.Equal(t, &pb.InspectionResults{OsCount: 2}, results)
+}
+
+func TestInspect_NoOperatingSystem(t *testing.T) {
+	results, err := InspectImage(newRawImage(make([]byte, testDiskSize)))
+	assert.NoError(t, err)
+	assert.Equal(t, &pb.InspectionResults{}, results)
+}
+
+func TestInspect_ReportsFailedStage(t *testing.T) {
+	results, err := Inspect(context.Background(), "/does/not/exist.vmdk")
+	assert.Error(t, err)
+	assert.Equal(t, pb.InspectionResults_MOUNTING_GUEST, results.ErrorWhen)
+
+	disk := newGPTDisk(512, []string{linuxDataGUID})
+	binary.LittleEndian.PutUint64(disk[2*512+40:], testDiskSize)
+	results, err = InspectImage(newRawImage(disk))
+	assert.Error(t, err)
+	assert.Equal(t, pb.InspectionResults_INSPECTING_BOOTLOADER, results.ErrorWhen)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	path := writeTempFile(t, readFixture(t, "ubuntu-ext4.img.gz"))
+	defer os.Remove(path)
+	results, err = Inspect(ctx, path)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "inspection canceled")
+	assert.Equal(t, pb.InspectionResults_MOUNTING_GUEST, results.ErrorWhen)
+}
+
+func newRawImage(disk []byte) Image {
+	img, err := NewImage(bytes.NewReader(disk), int64(len(disk)))
+	if err != nil {
+		panic(err)
+	}
+	return img
+}
+
+func writeTempFile(t *testing.T, content []byte) string {
+	f, err := ioutil.TempFile("", "disk")
+	assert.NoError(t, err)
+	_, err = f.Write(content)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	return f.Name()
+}
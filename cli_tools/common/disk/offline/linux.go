@@ -0,0 +1,325 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package offline
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/proto/go/pb"
+)
+
+// The ELF header is documented in elf(5).
+const (
+	elfMagic          = "\x7fELF"
+	elfDataMSB        = 2
+	elfMachine386     = 3
+	elfMachineX64     = 62
+	elfMachineAArch64 = 183
+)
+
+// Binaries that are checked to determine a Linux system's architecture.
+var linuxArchitectureBinaries = []string{"/bin/bash", "/bin/ls", "/bin/echo", "/bin/rm", "/bin/sh"}
+
+// Kernel modules for GCE's paravirtualized devices.
+var linuxVirtioModules = []string{"gve", "virtio_blk", "virtio_net", "virtio_pci", "virtio_scsi"}
+
+// Files that are installed by cloud-init's packages.
+var cloudInitFiles = []string{"/usr/bin/cloud-init", "/etc/cloud/cloud.cfg"}
+
+const linuxModulesDir = "/lib/modules"
+
+// fingerprint identifies a Linux distro. Matches are performed against
+// the ID in /etc/os-release, with fallback to legacy metadata files.
+//
+// This is a port of the fingerprints in the Python boot-inspect package, at
+// daisy_workflows/image_import/inspection/src/boot_inspect/inspection.py.
+type fingerprint struct {
+	distro pb.Distro
+
+	// aliases are additional values of ID that indicate a match.
+	aliases []string
+
+	// requireFiles must all be present, and disallowFiles must all
+	// be absent, for the system to match.
+	requireFiles  []string
+	disallowFiles []string
+
+	// legacyVersionFile is a pre-systemd metadata file whose content
+	// is searched for the version.
+	legacyVersionFile string
+}
+
+var legacyVersionPattern = regexp.MustCompile(`\d+\.\d+`)
+
+// linuxFingerprints are searched in order.
+var linuxFingerprints = []fingerprint{
+	{distro: pb.Distro_ALMALINUX},
+	{distro: pb.Distro_AMAZON, aliases: []string{"amzn", "amazonlinux"}},
+	{
+		distro:            pb.Distro_CENTOS,
+		requireFiles:      []string{"/etc/centos-release"},
+		disallowFiles:     []string{"/etc/fedora-release", "/etc/oracle-release"},
+		legacyVersionFile: "/etc/centos-release",
+	},
+	{distro: pb.Distro_DEBIAN, legacyVersionFile: "/etc/debian_version"},
+	{distro: pb.Distro_FEDORA},
+	{distro: pb.Distro_KALI},
+	{
+		distro:            pb.Distro_RHEL,
+		requireFiles:      []string{"/etc/redhat-release"},
+		disallowFiles:     []string{"/etc/fedora-release", "/etc/oracle-release", "/etc/centos-release"},
+		legacyVersionFile: "/etc/redhat-release",
+	},
+	// Depending on the version, SLES for SAP has a variety of identifiers in
+	// /etc/os-release. To match, one of those identifiers must be seen
+	// *and* the file /etc/products.d/SLES_SAP.prod must exist.
+	//
+	// This is documented here:
+	//   https://www.suse.com/support/kb/doc/?id=000019341
+	{
+		distro:       pb.Distro_SLES_SAP,
+		aliases:      []string{"sles", "sles_sap"},
+		requireFiles: []string{"/etc/products.d/SLES_SAP.prod"},
+	},
+	{distro: pb.Distro_SLES},
+	{distro: pb.Distro_OPENSUSE, aliases: []string{"opensuse-leap"}},
+	{distro: pb.Distro_ORACLE, aliases: []string{"ol", "oraclelinux"}},
+	{distro: pb.Distro_ROCKY},
+	{distro: pb.Distro_UBUNTU},
+}
+
+// inspectLinux returns the Linux distro installed on fs, or nil if a
+// supported distro isn't found.
+func inspectLinux(fs Filesystem) (*pb.OsRelease, error) {
+	osRelease := map[string]string{}
+	if fs.IsFile("/etc/os-release") {
+		content, err := ReadFile(fs, "/etc/os-release")
+		if err != nil {
+			return nil, err
+		}
+		osRelease = parseConfigFile(string(content))
+	}
+	for _, f := range linuxFingerprints {
+		matched, err := f.match(fs, osRelease)
+		if err != nil {
+			return nil, err
+		}
+		if matched == nil {
+			continue
+		}
+		if matched.Architecture, err = linuxArchitecture(fs); err != nil {
+			return nil, err
+		}
+		return matched, nil
+	}
+	return nil, nil
+}
+
+func (f fingerprint) match(fs Filesystem, osRelease map[string]string) (*pb.OsRelease, error) {
+	var matches bool
+	if id, found := osRelease["ID"]; found {
+		matches = f.matchesName(id) && f.matchesFiles(fs)
+	} else if len(f.requireFiles) > 0 || len(f.disallowFiles) > 0 {
+		matches = f.matchesFiles(fs)
+	}
+	if !matches {
+		return nil, nil
+	}
+
+	major, minor := splitVersion(osRelease["VERSION_ID"])
+	if f.legacyVersionFile != "" && fs.IsFile(f.legacyVersionFile) {
+		content, err := ReadFile(fs, f.legacyVersionFile)
+		if err != nil {
+			return nil, err
+		}
+		// The assumption here is that the longer version is better. For
+		// example, on Debian 8.8, /etc/os-release's version is '8', while
+		// /etc/debian_version's version is 8.8.
+		if legacy := legacyVersionPattern.FindString(string(content)); len(legacy) > len(osRelease["VERSION_ID"]) {
+			major, minor = splitVersion(legacy)
+		}
+	}
+	return &pb.OsRelease{
+		MajorVersion: major,
+		MinorVersion: minor,
+		DistroId:     f.distro,
+	}, nil
+}
+
+func (f fingerprint) matchesName(id string) bool {
+	for _, name := range append([]string{f.distro.String()}, f.aliases...) {
+		if strings.EqualFold(name, id) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fingerprint) matchesFiles(fs Filesystem) bool {
+	for _, path := range f.requireFiles {
+		if !fs.IsFile(path) {
+			return false
+		}
+	}
+	for _, path := range f.disallowFiles {
+		if fs.IsFile(path) {
+			return false
+		}
+	}
+	return true
+}
+
+// linuxArchitecture determines the architecture using the ELF
+// header of common binaries.
+func linuxArchitecture(fs Filesystem) (pb.Architecture, error) {
+	for _, path := range linuxArchitectureBinaries {
+		f, err := fs.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return pb.Architecture_ARCHITECTURE_UNKNOWN, err
+		}
+		header := make([]byte, 20)
+		if err := readFull(f, header, 0); err != nil || string(header[:4]) != elfMagic {
+			continue
+		}
+		var machine uint16
+		if header[5] == elfDataMSB {
+			machine = binary.BigEndian.Uint16(header[18:])
+		} else {
+			machine = binary.LittleEndian.Uint16(header[18:])
+		}
+		switch machine {
+		case elfMachine386:
+			return pb.Architecture_X86, nil
+		case elfMachineX64:
+			return pb.Architecture_X64, nil
+		case elfMachineAArch64:
+			return pb.Architecture_ARM64, nil
+		}
+		return pb.Architecture_ARCHITECTURE_UNKNOWN, nil
+	}
+	return pb.Architecture_ARCHITECTURE_UNKNOWN, nil
+}
+
+// linuxKernelVersions returns the versions of the kernels that have modules
+// installed. Directories without modules.dep are skipped, since they're
+// typically left behind when a kernel is removed.
+func linuxKernelVersions(fs Filesystem) ([]string, error) {
+	if !fs.IsDir(linuxModulesDir) {
+		return nil, nil
+	}
+	names, err := fs.ReadDir(linuxModulesDir)
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, name := range names {
+		if fs.IsFile(path.Join(linuxModulesDir, name, "modules.dep")) {
+			versions = append(versions, name)
+		}
+	}
+	return versions, nil
+}
+
+// linuxVirtioDrivers returns the modules in linuxVirtioModules that are
+// available to every kernel, either as a loadable module or built in.
+func linuxVirtioDrivers(fs Filesystem, kernels []string) ([]string, error) {
+	if len(kernels) == 0 {
+		return nil, nil
+	}
+	counts := map[string]int{}
+	for _, kernel := range kernels {
+		modules, err := linuxKernelModules(fs, kernel)
+		if err != nil {
+			return nil, err
+		}
+		for module := range modules {
+			counts[module]++
+		}
+	}
+	var drivers []string
+	for _, module := range linuxVirtioModules {
+		if counts[module] == len(kernels) {
+			drivers = append(drivers, module)
+		}
+	}
+	return drivers, nil
+}
+
+// linuxKernelModules returns the names of the modules listed in a kernel's
+// modules.dep and modules.builtin. Names are normalized to use underscores,
+// matching the output of lsmod.
+func linuxKernelModules(fs Filesystem, kernel string) (map[string]bool, error) {
+	modules := map[string]bool{}
+	for _, index := range []string{"modules.dep", "modules.builtin"} {
+		indexPath := path.Join(linuxModulesDir, kernel, index)
+		if !fs.IsFile(indexPath) {
+			continue
+		}
+		content, err := ReadFile(fs, indexPath)
+		if err != nil {
+			return nil, err
+		}
+		// modules.dep lines are `{module}: {dependencies}`, and
+		// modules.builtin lines are `{module}`.
+		for _, line := range strings.Split(string(content), "\n") {
+			module := strings.TrimSpace(strings.SplitN(line, ":", 2)[0])
+			if i := strings.Index(module, ".ko"); i > 0 {
+				name := path.Base(module[:i])
+				modules[strings.ReplaceAll(name, "-", "_")] = true
+			}
+		}
+	}
+	return modules, nil
+}
+
+// linuxCloudInitInstalled returns whether cloud-init is installed on fs.
+func linuxCloudInitInstalled(fs Filesystem) bool {
+	for _, f := range cloudInitFiles {
+		if fs.IsFile(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitVersion splits a version encoded as {major}.{minor} or {major}.
+func splitVersion(version string) (major, minor string) {
+	if i := strings.Index(version, "."); i >= 0 {
+		return version[:i], version[i+1:]
+	}
+	return version, ""
+}
+
+// parseConfigFile parses an ini-style config file, such as /etc/os-release,
+// into a map. Lines without `=` are ignored.
+func parseConfigFile(content string) map[string]string {
+	kv := map[string]string{}
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, "#") || !strings.Contains(line, "=") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		kv[parts[0]] = strings.Trim(strings.TrimSpace(parts[1]), `"'`)
+	}
+	return kv
+}
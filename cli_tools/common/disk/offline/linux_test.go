@@ -0,0 +1,225 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package offline
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/proto/go/pb"
+)
+
+const (
+	elfX64 = "\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\x3e\x00"
+	elf386 = "\x7fELF\x01\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\x03\x00"
+	elfARM = "\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\xb7\x00"
+)
+
+func TestInspectLinux(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		files    mapFS
+		expected *pb.OsRelease
+	}{
+		{
+			name: "ubuntu",
+			files: mapFS{
+				"/etc/os-release":     "ID=ubuntu\nVERSION_ID=\"18.04\"\n",
+				"/etc/debian_version": "buster/sid\n",
+				"/bin/bash":           elfX64,
+			},
+			expected: &pb.OsRelease{MajorVersion: "18", MinorVersion: "04", DistroId: pb.Distro_UBUNTU, Architecture: pb.Architecture_X64},
+		},
+		{
+			name: "debian version from legacy file",
+			files: mapFS{
+				"/etc/os-release":     "ID=debian\nVERSION_ID=\"8\"\n",
+				"/etc/debian_version": "8.8\n",
+				"/bin/ls":             elf386,
+			},
+			expected: &pb.OsRelease{MajorVersion: "8", MinorVersion: "8", DistroId: pb.Distro_DEBIAN, Architecture: pb.Architecture_X86},
+		},
+		{
+			name: "ubuntu arm64",
+			files: mapFS{
+				"/etc/os-release":     "ID=ubuntu\nVERSION_ID=\"20.04\"\n",
+				"/etc/debian_version": "bullseye/sid\n",
+				"/bin/bash":           elfARM,
+			},
+			expected: &pb.OsRelease{MajorVersion: "20", MinorVersion: "04", DistroId: pb.Distro_UBUNTU, Architecture: pb.Architecture_ARM64},
+		},
+		{
+			name: "centos 6 without os-release",
+			files: mapFS{
+				"/etc/centos-release": "CentOS release 6.10 (Final)\n",
+				"/etc/redhat-release": "CentOS release 6.10 (Final)\n",
+			},
+			expected: &pb.OsRelease{MajorVersion: "6", MinorVersion: "10", DistroId: pb.Distro_CENTOS},
+		},
+		{
+			name: "rhel",
+			files: mapFS{
+				"/etc/os-release":     "ID=\"rhel\"\nVERSION_ID=\"8.2\"\n",
+				"/etc/redhat-release": "Red Hat Enterprise Linux release 8.2 (Ootpa)\n",
+			},
+			expected: &pb.OsRelease{MajorVersion: "8", MinorVersion: "2", DistroId: pb.Distro_RHEL},
+		},
+		{
+			name: "oracle isn't rhel",
+			files: mapFS{
+				"/etc/os-release":     "ID=\"ol\"\nVERSION_ID=\"7.9\"\n",
+				"/etc/oracle-release": "Oracle Linux Server release 7.9\n",
+				"/etc/redhat-release": "Red Hat Enterprise Linux Server release 7.9 (Maipo)\n",
+			},
+			expected: &pb.OsRelease{MajorVersion: "7", MinorVersion: "9", DistroId: pb.Distro_ORACLE},
+		},
+		{
+			name: "sles for sap",
+			files: mapFS{
+				"/etc/os-release":               "ID=\"sles\"\nVERSION_ID=\"15.1\"\n",
+				"/etc/products.d/SLES_SAP.prod": "",
+			},
+			expected: &pb.OsRelease{MajorVersion: "15", MinorVersion: "1", DistroId: pb.Distro_SLES_SAP},
+		},
+		{
+			name:     "sles",
+			files:    mapFS{"/etc/os-release": "ID=\"sles\"\nVERSION_ID=\"12.5\"\n"},
+			expected: &pb.OsRelease{MajorVersion: "12", MinorVersion: "5", DistroId: pb.Distro_SLES},
+		},
+		{
+			name:     "opensuse leap",
+			files:    mapFS{"/etc/os-release": "ID=\"opensuse-leap\"\nVERSION_ID=\"15.2\"\n"},
+			expected: &pb.OsRelease{MajorVersion: "15", MinorVersion: "2", DistroId: pb.Distro_OPENSUSE},
+		},
+		{
+			name:     "amazon linux",
+			files:    mapFS{"/etc/os-release": "ID=\"amzn\"\nVERSION_ID=\"2\"\n"},
+			expected: &pb.OsRelease{MajorVersion: "2", DistroId: pb.Distro_AMAZON},
+		},
+		{
+			name: "rocky isn't rhel",
+			files: mapFS{
+				"/etc/os-release":     "ID=\"rocky\"\nVERSION_ID=\"8.4\"\n",
+				"/etc/redhat-release": "Rocky Linux release 8.4 (Green Obsidian)\n",
+			},
+			expected: &pb.OsRelease{MajorVersion: "8", MinorVersion: "4", DistroId: pb.Distro_ROCKY},
+		},
+		{
+			name:     "almalinux",
+			files:    mapFS{"/etc/os-release": "ID=\"almalinux\"\nVERSION_ID=\"8.5\"\n"},
+			expected: &pb.OsRelease{MajorVersion: "8", MinorVersion: "5", DistroId: pb.Distro_ALMALINUX},
+		},
+		{
+			name:  "unsupported distro",
+			files: mapFS{"/etc/os-release": "ID=arch\n"},
+		},
+		{
+			name:  "not linux",
+			files: mapFS{"/Windows/System32/ntoskrnl.exe": ""},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := inspectLinux(tt.files)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestInspectOS_LinuxKernelsAndDrivers(t *testing.T) {
+	guest, err := inspectOS(mapFS{
+		"/etc/os-release":     "ID=ubuntu\nVERSION_ID=\"20.04\"\n",
+		"/usr/bin/cloud-init": "",
+		"/lib/modules/5.4.0-42-generic/modules.dep": "kernel/drivers/scsi/virtio_scsi.ko: kernel/drivers/virtio/virtio.ko\n" +
+			"kernel/drivers/net/ethernet/google/gve/gve.ko:\n",
+		"/lib/modules/5.4.0-42-generic/modules.builtin": "kernel/drivers/virtio/virtio_pci.ko\nkernel/drivers/block/virtio_blk.ko\n",
+		// virtio_blk is missing from the second kernel.
+		"/lib/modules/5.8.0-1-generic/modules.dep": "kernel/drivers/scsi/virtio_scsi.ko.xz:\nkernel/drivers/net/ethernet/google/gve/gve.ko.xz:\n" +
+			"kernel/drivers/virtio/virtio-pci.ko.xz:\n",
+		// Left behind after a kernel was removed.
+		"/lib/modules/4.15.0-1-generic/extra/module.ko": "",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"5.4.0-42-generic", "5.8.0-1-generic"}, guest.kernelVersions)
+	assert.Equal(t, []string{"gve", "virtio_pci", "virtio_scsi"}, guest.virtioDrivers)
+	assert.True(t, guest.cloudInitInstalled)
+
+	guest, err = inspectOS(mapFS{"/etc/os-release": "ID=ubuntu\nVERSION_ID=\"20.04\"\n"})
+	assert.NoError(t, err)
+	assert.Empty(t, guest.kernelVersions)
+	assert.Empty(t, guest.virtioDrivers)
+	assert.False(t, guest.cloudInitInstalled)
+}
+
+func TestParseConfigFile(t *testing.T) {
+	assert.Equal(t, map[string]string{
+		"NAME":       "Ubuntu",
+		"ID":         "ubuntu",
+		"VERSION_ID": "20.04",
+		"EMPTY":      "",
+	}, parseConfigFile("# comment\nNAME=\"Ubuntu\"\nID=ubuntu\nVERSION_ID='20.04'\nEMPTY=\n\ninvalid\n"))
+}
+
+// mapFS is a Filesystem whose regular files are the map's keys.
+type mapFS map[string]string
+
+func (m mapFS) Type() string {
+	return "map"
+}
+
+func (m mapFS) Open(path string) (File, error) {
+	content, found := m[path]
+	if !found {
+		return nil, fmt.Errorf("%s: %w", path, os.ErrNotExist)
+	}
+	return newBytesFile([]byte(content)), nil
+}
+
+func (m mapFS) IsFile(path string) bool {
+	_, found := m[path]
+	return found
+}
+
+func (m mapFS) ReadDir(path string) ([]string, error) {
+	if !m.IsDir(path) {
+		return nil, fmt.Errorf("%s: %w", path, os.ErrNotExist)
+	}
+	found := map[string]bool{}
+	for file := range m {
+		if strings.HasPrefix(file, path+"/") {
+			found[strings.SplitN(strings.TrimPrefix(file, path+"/"), "/", 2)[0]] = true
+		}
+	}
+	var names []string
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (m mapFS) IsDir(path string) bool {
+	for file := range m {
+		if strings.HasPrefix(file, path+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,391 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package offline
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LVM2's on-disk format is described in lvm2's lib/format_text, and at
+// https://github.com/libyal/libvslvm/blob/main/documentation/.
+const (
+	lvmLabelMagic    = "LABELONE"
+	lvmLabelType     = "LVM2 001"
+	lvmLabelSectors  = 4
+	lvmMetadataMagic = " LVM2 x[5A%r0N*>"
+	lvmMDAHeaderSize = 512
+	lvmSectorSize    = 512
+
+	// Upper bound for the text metadata that is read into memory.
+	maxLVMMetadataSize = 16 << 20
+)
+
+// LogicalVolume is an LVM logical volume whose extents are all stored
+// on a single physical volume.
+type LogicalVolume struct {
+	VolumeGroup string
+	Name        string
+	File
+}
+
+// ReadLogicalVolumes returns the logical volumes of the LVM physical volume
+// stored in r. When r isn't a physical volume, nil is returned. Logical
+// volumes that span multiple physical volumes, or that use segment types
+// other than linear, are omitted, since they can't be read from r alone.
+func ReadLogicalVolumes(r io.ReaderAt, size int64) ([]LogicalVolume, error) {
+	label := make([]byte, lvmLabelSectors*lvmSectorSize)
+	if err := readFull(r, label, 0); err != nil {
+		return nil, err
+	}
+	var header []byte
+	for i := 0; i < lvmLabelSectors; i++ {
+		sector := label[i*lvmSectorSize:][:lvmSectorSize]
+		if string(sector[:8]) == lvmLabelMagic && string(sector[0x18:0x20]) == lvmLabelType {
+			header = sector
+			break
+		}
+	}
+	if header == nil {
+		return nil, nil
+	}
+
+	// The PV header follows the label header, and records the PV's UUID,
+	// followed by two zero-terminated lists of {offset, size}: the first for
+	// data areas, and the second for metadata areas.
+	pos := int(binary.LittleEndian.Uint32(header[0x14:]))
+	if pos+32+8 > len(header) {
+		return nil, errors.New("LVM physical volume header is invalid")
+	}
+	uuid := string(header[pos : pos+32])
+	pos += 32 + 8
+	var metadataAreas []int64
+	for list := 0; list < 2; list++ {
+		for {
+			if pos+16 > len(header) {
+				return nil, errors.New("LVM physical volume header is invalid")
+			}
+			offset := int64(binary.LittleEndian.Uint64(header[pos:]))
+			pos += 16
+			if offset == 0 {
+				break
+			}
+			if list == 1 {
+				metadataAreas = append(metadataAreas, offset)
+			}
+		}
+	}
+	if len(metadataAreas) == 0 {
+		return nil, errors.New("LVM physical volume doesn't have metadata")
+	}
+
+	metadata, err := readLVMMetadata(r, metadataAreas[0])
+	if err != nil {
+		return nil, err
+	}
+	return metadata.logicalVolumes(r, size, uuid)
+}
+
+// readLVMMetadata reads the current text metadata from the metadata
+// area at offset.
+func readLVMMetadata(r io.ReaderAt, offset int64) (lvmSection, error) {
+	header := make([]byte, lvmMDAHeaderSize)
+	if err := readFull(r, header, offset); err != nil {
+		return nil, err
+	}
+	if string(header[4:20]) != lvmMetadataMagic {
+		return nil, errors.New("LVM metadata area is invalid")
+	}
+	areaSize := int64(binary.LittleEndian.Uint64(header[0x20:]))
+	// The first location is the current metadata, stored in a circular
+	// buffer that follows the header.
+	textOffset := int64(binary.LittleEndian.Uint64(header[0x28:]))
+	textSize := int64(binary.LittleEndian.Uint64(header[0x30:]))
+	if textSize == 0 || textSize > maxLVMMetadataSize || textOffset < lvmMDAHeaderSize || textOffset >= areaSize {
+		return nil, errors.New("LVM metadata area is invalid")
+	}
+	text := make([]byte, textSize)
+	first := textSize
+	if textOffset+textSize > areaSize {
+		first = areaSize - textOffset
+	}
+	if err := readFull(r, text[:first], offset+textOffset); err != nil {
+		return nil, err
+	}
+	if first < textSize {
+		if err := readFull(r, text[first:], offset+lvmMDAHeaderSize); err != nil {
+			return nil, err
+		}
+	}
+	return parseLVMMetadata(string(text))
+}
+
+// lvmSection is a block of LVM's text metadata. Values are strings,
+// int64s, nested sections, or []interface{} for arrays.
+type lvmSection map[string]interface{}
+
+func (s lvmSection) section(key string) lvmSection {
+	v, _ := s[key].(lvmSection)
+	return v
+}
+
+func (s lvmSection) string(key string) string {
+	v, _ := s[key].(string)
+	return v
+}
+
+func (s lvmSection) int(key string) int64 {
+	v, _ := s[key].(int64)
+	return v
+}
+
+// logicalVolumes interprets the metadata of the volume group that contains
+// the physical volume pvUUID, which is stored in r.
+func (s lvmSection) logicalVolumes(r io.ReaderAt, size int64, pvUUID string) ([]LogicalVolume, error) {
+	// The metadata's only section is the volume group.
+	var vgName string
+	var vg lvmSection
+	for k, v := range s {
+		if section, ok := v.(lvmSection); ok {
+			vgName, vg = k, section
+		}
+	}
+	extentSize := vg.int("extent_size") * lvmSectorSize
+	if vg == nil || extentSize <= 0 {
+		return nil, errors.New("LVM metadata doesn't contain a volume group")
+	}
+	var pvName string
+	var peStart int64
+	for name, v := range vg.section("physical_volumes") {
+		pv, _ := v.(lvmSection)
+		if strings.ReplaceAll(pv.string("id"), "-", "") == pvUUID {
+			pvName, peStart = name, pv.int("pe_start")*lvmSectorSize
+		}
+	}
+	if pvName == "" {
+		return nil, fmt.Errorf("LVM volume group %s doesn't contain physical volume %s", vgName, pvUUID)
+	}
+
+	var volumes []LogicalVolume
+	for name, v := range vg.section("logical_volumes") {
+		lv, _ := v.(lvmSection)
+		if lv == nil || !lvmHasFlag(lv["status"], "VISIBLE") {
+			continue
+		}
+		extents, ok := lv.linearExtents(pvName, extentSize, peStart)
+		if !ok {
+			continue
+		}
+		var lvSize int64
+		for _, e := range extents {
+			if e.logical+e.length > lvSize {
+				lvSize = e.logical + e.length
+			}
+			if e.physical+e.length > size {
+				return nil, fmt.Errorf("LVM logical volume %s/%s extends past its physical volume", vgName, name)
+			}
+		}
+		volumes = append(volumes, LogicalVolume{
+			VolumeGroup: vgName,
+			Name:        name,
+			File:        newExtentFile(r, extents, lvSize),
+		})
+	}
+	sort.Slice(volumes, func(i, j int) bool {
+		return volumes[i].Name < volumes[j].Name
+	})
+	return volumes, nil
+}
+
+// linearExtents returns the extents of a logical volume whose segments
+// are all linear, and stored on pvName.
+func (s lvmSection) linearExtents(pvName string, extentSize, peStart int64) ([]extent, bool) {
+	count := s.int("segment_count")
+	if count == 0 {
+		return nil, false
+	}
+	var extents []extent
+	for i := int64(1); i <= count; i++ {
+		segment := s.section(fmt.Sprintf("segment%d", i))
+		if segment == nil || segment.string("type") != "striped" || segment.int("stripe_count") != 1 {
+			return nil, false
+		}
+		// stripes is a list of {physical volume, starting extent}.
+		stripes, _ := segment["stripes"].([]interface{})
+		if len(stripes) != 2 || stripes[0] != pvName {
+			return nil, false
+		}
+		start, _ := stripes[1].(int64)
+		extents = append(extents, extent{
+			logical:  segment.int("start_extent") * extentSize,
+			physical: peStart + start*extentSize,
+			length:   segment.int("extent_count") * extentSize,
+		})
+	}
+	return extents, true
+}
+
+func lvmHasFlag(flags interface{}, flag string) bool {
+	list, _ := flags.([]interface{})
+	for _, f := range list {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLVMMetadata parses LVM's text metadata format, which consists of
+// `key = value` assignments and `name { ... }` sections.
+func parseLVMMetadata(text string) (lvmSection, error) {
+	p := &lvmParser{text: text}
+	root, err := p.section()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.next(); tok != "" {
+		return nil, fmt.Errorf("unexpected %q in LVM metadata", tok)
+	}
+	return root, nil
+}
+
+type lvmParser struct {
+	text string
+	pos  int
+}
+
+// section parses assignments and nested sections until a closing
+// brace or the end of the text.
+func (p *lvmParser) section() (lvmSection, error) {
+	s := lvmSection{}
+	for {
+		key := p.peek()
+		if key == "" || key == "}" {
+			return s, nil
+		}
+		p.next()
+		switch tok := p.next(); tok {
+		case "{":
+			child, err := p.section()
+			if err != nil {
+				return nil, err
+			}
+			if p.next() != "}" {
+				return nil, fmt.Errorf("unterminated section %q in LVM metadata", key)
+			}
+			s[key] = child
+		case "=":
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			s[key] = v
+		default:
+			return nil, fmt.Errorf("unexpected %q after %q in LVM metadata", tok, key)
+		}
+	}
+}
+
+func (p *lvmParser) value() (interface{}, error) {
+	tok := p.next()
+	switch {
+	case tok == "[":
+		list := []interface{}{}
+		for {
+			if p.peek() == "]" {
+				p.next()
+				return list, nil
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			if p.peek() == "," {
+				p.next()
+			}
+		}
+	case strings.HasPrefix(tok, `"`):
+		return unquoteLVMString(tok), nil
+	default:
+		n, err := strconv.ParseInt(tok, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected %q in LVM metadata", tok)
+		}
+		return n, nil
+	}
+}
+
+func (p *lvmParser) peek() string {
+	pos := p.pos
+	tok := p.next()
+	p.pos = pos
+	return tok
+}
+
+// next returns the next token, or "" at the end of the text. Strings are
+// returned with their quotes, and comments are skipped.
+func (p *lvmParser) next() string {
+	for p.pos < len(p.text) {
+		switch c := p.text[p.pos]; {
+		case c == '#':
+			for p.pos < len(p.text) && p.text[p.pos] != '\n' {
+				p.pos++
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == 0:
+			p.pos++
+		case strings.IndexByte("{}[]=,", c) >= 0:
+			p.pos++
+			return string(c)
+		case c == '"':
+			start := p.pos
+			for p.pos++; p.pos < len(p.text) && p.text[p.pos] != '"'; p.pos++ {
+				if p.text[p.pos] == '\\' {
+					p.pos++
+				}
+			}
+			p.pos++
+			if p.pos > len(p.text) {
+				p.pos = len(p.text)
+			}
+			return p.text[start:p.pos]
+		default:
+			start := p.pos
+			for p.pos < len(p.text) && strings.IndexByte("{}[]=,\"# \t\r\n", p.text[p.pos]) < 0 {
+				p.pos++
+			}
+			return p.text[start:p.pos]
+		}
+	}
+	return ""
+}
+
+func unquoteLVMString(tok string) string {
+	tok = strings.TrimPrefix(tok, `"`)
+	tok = strings.TrimSuffix(tok, `"`)
+	var b strings.Builder
+	for i := 0; i < len(tok); i++ {
+		if tok[i] == '\\' && i+1 < len(tok) {
+			i++
+		}
+		b.WriteByte(tok[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,215 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package offline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadLogicalVolumes(t *testing.T) {
+	for _, wrap := range []bool{false, true} {
+		t.Run(fmt.Sprintf("wrap=%v", wrap), func(t *testing.T) {
+			pv := newTestPV(lvmTestMetadata, wrap)
+			copy(pv[lvmTestExtentOffset(3):], bytes.Repeat([]byte{1}, lvmTestExtentSize))
+			copy(pv[lvmTestExtentOffset(1):], bytes.Repeat([]byte{2}, lvmTestExtentSize))
+			copy(pv[lvmTestExtentOffset(0):], bytes.Repeat([]byte{3}, lvmTestExtentSize))
+
+			volumes, err := ReadLogicalVolumes(bytes.NewReader(pv), int64(len(pv)))
+			assert.NoError(t, err)
+			// thin-pool isn't visible, and thin isn't linear.
+			assert.Len(t, volumes, 2)
+
+			root := volumes[0]
+			assert.Equal(t, "vg0", root.VolumeGroup)
+			assert.Equal(t, "root", root.Name)
+			assert.Equal(t, int64(2*lvmTestExtentSize), root.Size())
+			content := make([]byte, root.Size())
+			assert.NoError(t, readFull(root, content, 0))
+			assert.Equal(t, append(bytes.Repeat([]byte{1}, lvmTestExtentSize), bytes.Repeat([]byte{2}, lvmTestExtentSize)...), content)
+
+			swap := volumes[1]
+			assert.Equal(t, "swap", swap.Name)
+			content = make([]byte, swap.Size())
+			assert.NoError(t, readFull(swap, content, 0))
+			assert.Equal(t, bytes.Repeat([]byte{3}, lvmTestExtentSize), content)
+		})
+	}
+}
+
+func TestReadLogicalVolumes_NotPhysicalVolume(t *testing.T) {
+	volumes, err := ReadLogicalVolumes(bytes.NewReader(make([]byte, testDiskSize)), testDiskSize)
+	assert.NoError(t, err)
+	assert.Nil(t, volumes)
+}
+
+func TestReadLogicalVolumes_RejectsInvalidMetadata(t *testing.T) {
+	for _, tt := range []struct {
+		metadata string
+		expected string
+	}{
+		{`vg0 { extent_size = 1024`, `unterminated section "vg0" in LVM metadata`},
+		{`vg0 { extent_size = abc }`, `unexpected "abc" in LVM metadata`},
+		{`vg0 { extent_size = 1024 }`, "LVM volume group vg0 doesn't contain physical volume " + lvmTestPVUUID},
+		{`contents = "Text Format Volume Group"`, "LVM metadata doesn't contain a volume group"},
+	} {
+		t.Run(tt.expected, func(t *testing.T) {
+			pv := newTestPV(tt.metadata, false)
+			_, err := ReadLogicalVolumes(bytes.NewReader(pv), int64(len(pv)))
+			assert.EqualError(t, err, tt.expected)
+		})
+	}
+}
+
+const (
+	lvmTestPVUUID       = "aaaabbbbccccddddeeeeffffgggghhhh"
+	lvmTestMDAOffset    = 4096
+	lvmTestMDASize      = 8192
+	lvmTestExtentSize   = 512 << 10
+	lvmTestDataAreaSize = 1 << 20
+)
+
+// lvmTestMetadata is a volume group with extents of 512KiB, where data
+// starts at 1MiB.
+const lvmTestMetadata = `vg0 {
+id = "pUPc2A-ZEuE-0Hp2-NQ3j-3CVV-RwPg-Vkk8oD"
+seqno = 4
+format = "lvm2" # informational
+status = ["RESIZEABLE", "READ", "WRITE"]
+flags = []
+extent_size = 1024
+max_lv = 0
+
+physical_volumes {
+
+pv0 {
+id = "aaaabb-bbcc-ccdd-ddee-eeff-ffgg-gghhhh"
+device = "/dev/sda2"	# Hint only
+status = ["ALLOCATABLE"]
+dev_size = 16384
+pe_start = 2048
+pe_count = 14
+}
+}
+
+logical_volumes {
+
+root {
+status = ["READ", "WRITE", "VISIBLE"]
+segment_count = 2
+
+segment1 {
+start_extent = 0
+extent_count = 1
+type = "striped"
+stripe_count = 1	# linear
+stripes = [
+"pv0", 3
+]
+}
+segment2 {
+start_extent = 1
+extent_count = 1
+type = "striped"
+stripe_count = 1
+stripes = ["pv0", 1]
+}
+}
+
+swap {
+status = ["READ", "WRITE", "VISIBLE"]
+segment_count = 1
+segment1 {
+start_extent = 0
+extent_count = 1
+type = "striped"
+stripe_count = 1
+stripes = ["pv0", 0]
+}
+}
+
+thin-pool {
+status = ["READ", "WRITE"]
+segment_count = 1
+segment1 {
+start_extent = 0
+extent_count = 2
+type = "striped"
+stripe_count = 1
+stripes = ["pv0", 4]
+}
+}
+
+thin {
+status = ["READ", "WRITE", "VISIBLE"]
+segment_count = 1
+segment1 {
+start_extent = 0
+extent_count = 4
+type = "thin"
+thin_pool = "thin-pool"
+}
+}
+}
+}
+# Generated by LVM2 version 2.02.187(2)-RHEL7 (2020-03-24): Sun Sep 13 12:26:40 2020
+
+contents = "Text Format Volume Group"
+version = 1
+description = "Created *after* executing 'lvcreate -n \"root\" vg0'"
+creation_host = "localhost"	# Linux localhost 3.10.0 #1 SMP x86_64
+`
+
+func lvmTestExtentOffset(extent int) int {
+	return lvmTestDataAreaSize + extent*lvmTestExtentSize
+}
+
+// newTestPV returns a physical volume whose label is in its second sector.
+// When wrap is set, the metadata wraps around the end of the metadata area.
+func newTestPV(metadata string, wrap bool) []byte {
+	pv := make([]byte, testDiskSize)
+	label := pv[sectorSize:][:sectorSize]
+	copy(label, lvmLabelMagic)
+	binary.LittleEndian.PutUint64(label[8:], 1)
+	binary.LittleEndian.PutUint32(label[0x14:], 0x20)
+	copy(label[0x18:], lvmLabelType)
+
+	// UUID and device size, followed by the data and metadata areas.
+	pvHeader := label[0x20:]
+	copy(pvHeader, lvmTestPVUUID)
+	binary.LittleEndian.PutUint64(pvHeader[0x20:], testDiskSize)
+	binary.LittleEndian.PutUint64(pvHeader[0x28:], lvmTestDataAreaSize)
+	binary.LittleEndian.PutUint64(pvHeader[0x48:], lvmTestMDAOffset)
+	binary.LittleEndian.PutUint64(pvHeader[0x50:], lvmTestMDASize)
+
+	mda := pv[lvmTestMDAOffset:][:lvmTestMDASize]
+	copy(mda[4:], lvmMetadataMagic)
+	binary.LittleEndian.PutUint32(mda[0x14:], 1)
+	binary.LittleEndian.PutUint64(mda[0x18:], lvmTestMDAOffset)
+	binary.LittleEndian.PutUint64(mda[0x20:], lvmTestMDASize)
+	textOffset := lvmMDAHeaderSize
+	if wrap {
+		textOffset = lvmTestMDASize - len(metadata)/2
+	}
+	binary.LittleEndian.PutUint64(mda[0x28:], uint64(textOffset))
+	binary.LittleEndian.PutUint64(mda[0x30:], uint64(len(metadata)))
+	n := copy(mda[textOffset:], metadata)
+	copy(mda[lvmMDAHeaderSize:], metadata[n:])
+	return pv
+}
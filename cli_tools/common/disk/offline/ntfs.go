@@ -0,0 +1,505 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package offline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+// NTFS structures are described in the Linux-NTFS project's documentation,
+// at https://flatcap.github.io/linux-ntfs/ntfs/.
+const (
+	ntfsOEMID = "NTFS    "
+
+	ntfsMFTRecord     = 0
+	ntfsRootRecord    = 5
+	ntfsRecordMagic   = "FILE"
+	ntfsIndexMagic    = "INDX"
+	ntfsFixupStride   = 512
+	ntfsRecordInUse   = 0x1
+	ntfsRecordIsDir   = 0x2
+	ntfsReferenceMask = 1<<48 - 1
+
+	ntfsAttrAttributeList  = 0x20
+	ntfsAttrData           = 0x80
+	ntfsAttrIndexRoot      = 0x90
+	ntfsAttrIndexAlloc     = 0xa0
+	ntfsAttrReparsePoint   = 0xc0
+	ntfsAttrEnd            = 0xffffffff
+	ntfsAttrFlagCompressed = 0x1
+	ntfsAttrFlagEncrypted  = 0x4000
+
+	ntfsNamespaceDOS      = 2
+	ntfsIndexEntrySubnode = 0x1
+	ntfsIndexEntryLast    = 0x2
+	ntfsMaxIndexDepth     = 16
+
+	// Name of the attributes that index a directory's filenames.
+	ntfsFilenameIndex = "$I30"
+)
+
+// ntfsFS reads NTFS filesystems.
+type ntfsFS struct {
+	r           io.ReaderAt
+	clusterSize int64
+	recordSize  int64
+	indexSize   int64
+	mft         File
+}
+
+// ntfsAttribute is an attribute of an MFT record.
+type ntfsAttribute struct {
+	attrType    uint32
+	name        string
+	flags       uint16
+	resident    bool
+	value       []byte
+	lowestVCN   int64
+	runs        []extent
+	dataSize    int64
+	initialized int64
+}
+
+// ntfsRecord is an MFT record, with the attributes of its extension records.
+type ntfsRecord struct {
+	number     uint64
+	flags      uint16
+	attributes []ntfsAttribute
+}
+
+func (r *ntfsRecord) kind() nodeKind {
+	// Reparse points include symlinks and junctions, whose targets
+	// are recorded using Windows paths; they're not followed.
+	if r.find(ntfsAttrReparsePoint, "") != nil {
+		return otherNode
+	}
+	if r.flags&ntfsRecordIsDir != 0 {
+		return dirNode
+	}
+	return fileNode
+}
+
+// find returns the first attribute with attrType and name.
+func (r *ntfsRecord) find(attrType uint32, name string) *ntfsAttribute {
+	for i, attr := range r.attributes {
+		if attr.attrType == attrType && attr.name == name {
+			return &r.attributes[i]
+		}
+	}
+	return nil
+}
+
+func newNtfsFS(r io.ReaderAt) (*ntfsFS, error) {
+	boot := make([]byte, sectorSize)
+	if err := readFull(r, boot, 0); err != nil {
+		return nil, fmt.Errorf("failed to read ntfs boot sector: %w", err)
+	}
+	bytesPerSector := int64(binary.LittleEndian.Uint16(boot[0x0b:]))
+	sectorsPerCluster := int64(boot[0x0d])
+	if sectorsPerCluster > 0x80 {
+		sectorsPerCluster = 1 << (256 - sectorsPerCluster)
+	}
+	fs := &ntfsFS{r: r, clusterSize: bytesPerSector * sectorsPerCluster}
+	if fs.clusterSize < sectorSize {
+		return nil, errors.New("ntfs boot sector is invalid")
+	}
+	// Sizes are either a positive number of clusters, or a negative
+	// power of two in bytes.
+	sizeOf := func(v byte) int64 {
+		if int8(v) < 0 {
+			return 1 << uint(-int8(v))
+		}
+		return int64(v) * fs.clusterSize
+	}
+	fs.recordSize = sizeOf(boot[0x40])
+	fs.indexSize = sizeOf(boot[0x44])
+	if fs.recordSize < ntfsFixupStride || fs.indexSize < ntfsFixupStride {
+		return nil, errors.New("ntfs boot sector is invalid")
+	}
+
+	// The $MFT's first record describes where the remainder is stored.
+	mftOffset := int64(binary.LittleEndian.Uint64(boot[0x30:])) * fs.clusterSize
+	fs.mft = io.NewSectionReader(r, mftOffset, fs.recordSize)
+	mft, err := fs.readRecord(ntfsMFTRecord)
+	if err != nil {
+		return nil, err
+	}
+	if fs.mft, err = fs.openAttribute(mft, ntfsAttrData, ""); err != nil {
+		return nil, fmt.Errorf("failed to read ntfs $MFT: %w", err)
+	}
+	// A heavily fragmented $MFT stores its remaining runs in extension
+	// records, which are found using the runs from the first record.
+	if mft.find(ntfsAttrAttributeList, "") != nil {
+		if mft, err = fs.record(ntfsMFTRecord); err != nil {
+			return nil, err
+		}
+		if fs.mft, err = fs.openAttribute(mft, ntfsAttrData, ""); err != nil {
+			return nil, fmt.Errorf("failed to read ntfs $MFT: %w", err)
+		}
+	}
+	return fs, nil
+}
+
+func (fs *ntfsFS) fsType() string {
+	return "ntfs"
+}
+
+func (fs *ntfsFS) root() (node, error) {
+	return fs.record(ntfsRootRecord)
+}
+
+// record reads an MFT record, including the attributes that are stored
+// in extension records.
+func (fs *ntfsFS) record(number uint64) (*ntfsRecord, error) {
+	record, err := fs.readRecord(number)
+	if err != nil {
+		return nil, err
+	}
+	list := record.find(ntfsAttrAttributeList, "")
+	if list == nil {
+		return record, nil
+	}
+	content, err := fs.readAttribute(list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ntfs attribute list of %d: %w", number, err)
+	}
+	loaded := map[uint64]bool{number: true}
+	for pos := 0; pos+0x1a <= len(content); {
+		length := int(binary.LittleEndian.Uint16(content[pos+4:]))
+		if length == 0 {
+			break
+		}
+		extension := binary.LittleEndian.Uint64(content[pos+0x10:]) & ntfsReferenceMask
+		pos += length
+		if loaded[extension] {
+			continue
+		}
+		loaded[extension] = true
+		extensionRecord, err := fs.readRecord(extension)
+		if err != nil {
+			return nil, err
+		}
+		record.attributes = append(record.attributes, extensionRecord.attributes...)
+	}
+	return record, nil
+}
+
+// readRecord reads the attributes that are stored in a single MFT record.
+func (fs *ntfsFS) readRecord(number uint64) (*ntfsRecord, error) {
+	raw := make([]byte, fs.recordSize)
+	if err := readFull(fs.mft, raw, int64(number)*fs.recordSize); err != nil {
+		return nil, fmt.Errorf("failed to read ntfs record %d: %w", number, err)
+	}
+	if !bytes.HasPrefix(raw, []byte(ntfsRecordMagic)) {
+		return nil, fmt.Errorf("ntfs record %d is invalid", number)
+	}
+	if err := applyFixups(raw); err != nil {
+		return nil, fmt.Errorf("ntfs record %d is invalid: %w", number, err)
+	}
+	record := &ntfsRecord{number: number, flags: binary.LittleEndian.Uint16(raw[0x16:])}
+	if record.flags&ntfsRecordInUse == 0 {
+		return nil, fmt.Errorf("ntfs record %d is not in use", number)
+	}
+	for pos := int(binary.LittleEndian.Uint16(raw[0x14:])); pos+8 <= len(raw); {
+		attrType := binary.LittleEndian.Uint32(raw[pos:])
+		length := int(binary.LittleEndian.Uint32(raw[pos+4:]))
+		if attrType == ntfsAttrEnd {
+			break
+		}
+		if length < 0x18 || pos+length > len(raw) {
+			return nil, fmt.Errorf("ntfs record %d has an invalid attribute", number)
+		}
+		attr, err := fs.parseAttribute(raw[pos : pos+length])
+		if err != nil {
+			return nil, fmt.Errorf("ntfs record %d has an invalid attribute: %w", number, err)
+		}
+		record.attributes = append(record.attributes, attr)
+		pos += length
+	}
+	return record, nil
+}
+
+func (fs *ntfsFS) parseAttribute(raw []byte) (ntfsAttribute, error) {
+	attr := ntfsAttribute{
+		attrType: binary.LittleEndian.Uint32(raw),
+		resident: raw[8] == 0,
+		flags:    binary.LittleEndian.Uint16(raw[0x0c:]),
+	}
+	nameLength := int(raw[9])
+	nameOffset := int(binary.LittleEndian.Uint16(raw[0x0a:]))
+	if nameOffset+nameLength*2 > len(raw) {
+		return attr, errors.New("name out of bounds")
+	}
+	attr.name = decodeUTF16(raw[nameOffset : nameOffset+nameLength*2])
+
+	if attr.resident {
+		valueLength := int(binary.LittleEndian.Uint32(raw[0x10:]))
+		valueOffset := int(binary.LittleEndian.Uint16(raw[0x14:]))
+		if valueOffset+valueLength > len(raw) {
+			return attr, errors.New("value out of bounds")
+		}
+		attr.value = raw[valueOffset : valueOffset+valueLength]
+		return attr, nil
+	}
+
+	if len(raw) < 0x40 {
+		return attr, errors.New("non-resident header is truncated")
+	}
+	attr.lowestVCN = int64(binary.LittleEndian.Uint64(raw[0x10:]))
+	attr.dataSize = int64(binary.LittleEndian.Uint64(raw[0x30:]))
+	attr.initialized = int64(binary.LittleEndian.Uint64(raw[0x38:]))
+	runs, err := fs.decodeRuns(raw[binary.LittleEndian.Uint16(raw[0x20:]):], attr.lowestVCN)
+	attr.runs = runs
+	return attr, err
+}
+
+// decodeRuns decodes a runlist, which stores the clusters of a non-resident
+// attribute as a sequence of variable length (length, offset) pairs. Offsets
+// are relative to the previous run, and runs without an offset are sparse.
+func (fs *ntfsFS) decodeRuns(runlist []byte, vcn int64) ([]extent, error) {
+	var runs []extent
+	var lcn int64
+	for pos := 0; pos < len(runlist) && runlist[pos] != 0; {
+		lengthSize := int(runlist[pos] & 0xf)
+		offsetSize := int(runlist[pos] >> 4)
+		pos++
+		if lengthSize == 0 || lengthSize > 8 || offsetSize > 8 || pos+lengthSize+offsetSize > len(runlist) {
+			return nil, errors.New("runlist is invalid")
+		}
+		length := decodeLittleEndian(runlist[pos:pos+lengthSize], false)
+		pos += lengthSize
+		run := extent{logical: vcn * fs.clusterSize, physical: -1, length: length * fs.clusterSize}
+		if offsetSize > 0 {
+			lcn += decodeLittleEndian(runlist[pos:pos+offsetSize], true)
+			pos += offsetSize
+			run.physical = lcn * fs.clusterSize
+		}
+		runs = append(runs, run)
+		vcn += length
+	}
+	return runs, nil
+}
+
+// openAttribute returns the value of an attribute. Non-resident attributes
+// may be split across multiple extension records; their runlists are combined.
+func (fs *ntfsFS) openAttribute(record *ntfsRecord, attrType uint32, name string) (File, error) {
+	var first *ntfsAttribute
+	var runs []extent
+	for i, attr := range record.attributes {
+		if attr.attrType != attrType || attr.name != name {
+			continue
+		}
+		if attr.resident {
+			return newBytesFile(attr.value), nil
+		}
+		if attr.flags&(ntfsAttrFlagCompressed|ntfsAttrFlagEncrypted) != 0 {
+			return nil, errors.New("compressed and encrypted attributes are not supported")
+		}
+		if attr.lowestVCN == 0 {
+			first = &record.attributes[i]
+		}
+		runs = append(runs, attr.runs...)
+	}
+	if first == nil {
+		return nil, fmt.Errorf("attribute %#x %q not found in record %d", attrType, name, record.number)
+	}
+	// Content beyond the initialized size reads as zeros.
+	var initialized []extent
+	for _, run := range runs {
+		if run.logical >= first.initialized {
+			continue
+		}
+		if run.logical+run.length > first.initialized {
+			run.length = first.initialized - run.logical
+		}
+		initialized = append(initialized, run)
+	}
+	return newExtentFile(fs.r, initialized, first.dataSize), nil
+}
+
+func (fs *ntfsFS) readAttribute(attr *ntfsAttribute) ([]byte, error) {
+	if attr.resident {
+		return attr.value, nil
+	}
+	f := newExtentFile(fs.r, attr.runs, attr.dataSize)
+	if f.Size() > maxFileSize {
+		return nil, fmt.Errorf("attribute is larger than %d bytes", maxFileSize)
+	}
+	content := make([]byte, f.Size())
+	return content, readFull(f, content, 0)
+}
+
+func (fs *ntfsFS) lookup(dir node, name string) (node, error) {
+	var found uint64
+	var matched bool
+	err := fs.entries(dir.(*ntfsRecord), func(entry string, namespace byte, number uint64) bool {
+		if strings.EqualFold(entry, name) {
+			found, matched = number, true
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !matched {
+		return nil, os.ErrNotExist
+	}
+	return fs.record(found)
+}
+
+func (fs *ntfsFS) list(dir node) ([]string, error) {
+	var names []string
+	err := fs.entries(dir.(*ntfsRecord), func(entry string, namespace byte, number uint64) bool {
+		// Files with long names have an additional entry for their 8.3 name.
+		if namespace != ntfsNamespaceDOS {
+			names = append(names, entry)
+		}
+		return true
+	})
+	return names, err
+}
+
+// entries calls visit for each filename in a directory's index, until visit
+// returns false.
+func (fs *ntfsFS) entries(record *ntfsRecord, visit func(name string, namespace byte, number uint64) bool) error {
+	root := record.find(ntfsAttrIndexRoot, ntfsFilenameIndex)
+	if root == nil || len(root.value) < 0x20 {
+		return fmt.Errorf("ntfs directory %d doesn't have an index", record.number)
+	}
+	var allocation File
+	if record.find(ntfsAttrIndexAlloc, ntfsFilenameIndex) != nil {
+		var err error
+		if allocation, err = fs.openAttribute(record, ntfsAttrIndexAlloc, ntfsFilenameIndex); err != nil {
+			return err
+		}
+	}
+	// Index records are addressed by VCN, in units of clusters, or
+	// 512 byte blocks when index records are smaller than a cluster.
+	vcnSize := fs.clusterSize
+	if fs.indexSize < fs.clusterSize {
+		vcnSize = ntfsFixupStride
+	}
+	// Filenames are collated using the volume's uppercase table; rather than
+	// reproducing that order, all nodes of the index are visited.
+	var walk func(header []byte, depth int) (bool, error)
+	walk = func(header []byte, depth int) (bool, error) {
+		if depth > ntfsMaxIndexDepth || len(header) < 0x10 {
+			return false, errors.New("ntfs index is invalid")
+		}
+		entriesOffset := int(binary.LittleEndian.Uint32(header))
+		entriesEnd := int(binary.LittleEndian.Uint32(header[4:]))
+		if entriesEnd > len(header) {
+			return false, errors.New("ntfs index is invalid")
+		}
+		for pos := entriesOffset; pos+0x10 <= entriesEnd; {
+			entryLength := int(binary.LittleEndian.Uint16(header[pos+8:]))
+			keyLength := int(binary.LittleEndian.Uint16(header[pos+10:]))
+			flags := binary.LittleEndian.Uint16(header[pos+12:])
+			if entryLength < 0x10 || pos+entryLength > entriesEnd {
+				return false, errors.New("ntfs index entry is invalid")
+			}
+			if flags&ntfsIndexEntrySubnode != 0 && allocation != nil {
+				vcn := int64(binary.LittleEndian.Uint64(header[pos+entryLength-8:]))
+				block := make([]byte, fs.indexSize)
+				if err := readFull(allocation, block, vcn*vcnSize); err != nil {
+					return false, fmt.Errorf("failed to read ntfs index: %w", err)
+				}
+				if !bytes.HasPrefix(block, []byte(ntfsIndexMagic)) {
+					return false, errors.New("ntfs index record is invalid")
+				}
+				if err := applyFixups(block); err != nil {
+					return false, err
+				}
+				if more, err := walk(block[0x18:], depth+1); !more || err != nil {
+					return more, err
+				}
+			}
+			if flags&ntfsIndexEntryLast != 0 {
+				break
+			}
+			if keyLength >= 0x42 && 0x10+keyLength <= entryLength {
+				key := header[pos+0x10 : pos+0x10+keyLength]
+				nameLength := int(key[0x40])
+				if 0x42+nameLength*2 <= len(key) &&
+					!visit(decodeUTF16(key[0x42:0x42+nameLength*2]), key[0x41],
+						binary.LittleEndian.Uint64(header[pos:])&ntfsReferenceMask) {
+					return false, nil
+				}
+			}
+			pos += entryLength
+		}
+		return true, nil
+	}
+	if _, err := walk(root.value[0x10:], 0); err != nil {
+		return fmt.Errorf("failed to read ntfs directory %d: %w", record.number, err)
+	}
+	return nil
+}
+
+func (fs *ntfsFS) readlink(n node) (string, error) {
+	return "", errors.New("ntfs reparse points are not supported")
+}
+
+func (fs *ntfsFS) open(n node) (File, error) {
+	return fs.openAttribute(n.(*ntfsRecord), ntfsAttrData, "")
+}
+
+// applyFixups restores the last two bytes of each 512 byte stride of a
+// multi-sector structure, which are replaced by a sequence number on disk
+// to detect torn writes.
+func applyFixups(block []byte) error {
+	offset := int(binary.LittleEndian.Uint16(block[4:]))
+	count := int(binary.LittleEndian.Uint16(block[6:]))
+	if count == 0 || offset+count*2 > len(block) || (count-1)*ntfsFixupStride > len(block) {
+		return errors.New("update sequence is invalid")
+	}
+	sequence := block[offset : offset+2]
+	for i := 1; i < count; i++ {
+		end := i * ntfsFixupStride
+		if !bytes.Equal(block[end-2:end], sequence) {
+			return errors.New("update sequence mismatch")
+		}
+		copy(block[end-2:end], block[offset+i*2:offset+i*2+2])
+	}
+	return nil
+}
+
+// decodeLittleEndian decodes a variable width integer.
+func decodeLittleEndian(b []byte, signed bool) int64 {
+	var v int64
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | int64(b[i])
+	}
+	if signed && len(b) < 8 && b[len(b)-1]&0x80 != 0 {
+		v -= 1 << (8 * uint(len(b)))
+	}
+	return v
+}
+
+func decodeUTF16(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	return string(utf16.Decode(u))
+}
@@ -0,0 +1,294 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package offline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/proto/go/pb"
+)
+
+func TestNtfs_ReadsFiles(t *testing.T) {
+	image, hive := newTestNtfs()
+	fs, err := OpenFilesystem(bytes.NewReader(image), int64(len(image)))
+	assert.NoError(t, err)
+	assert.Equal(t, "ntfs", fs.Type())
+
+	// Stored in an index allocation, and in the second run of the $MFT.
+	assert.True(t, fs.IsDir("/Windows"))
+	// Lookups are case-insensitive.
+	kernel, err := ReadFile(fs, "/windows/SYSTEM32/NTOSKRNL.EXE")
+	assert.NoError(t, err)
+	assert.Equal(t, newTestPE(peMachineAMD64), string(kernel))
+	// Stored in two runs, where the second run precedes the first.
+	content, err := ReadFile(fs, windowsSoftwareHive)
+	assert.NoError(t, err)
+	assert.Equal(t, hive, content)
+
+	// A sparse run, followed by a run that's partially initialized.
+	content, err = ReadFile(fs, "/sparse.dat")
+	assert.NoError(t, err)
+	expected := make([]byte, 3*ntfsTestClusterSize)
+	copy(expected, bytes.Repeat([]byte{1}, ntfsTestClusterSize))
+	copy(expected[2*ntfsTestClusterSize:], bytes.Repeat([]byte{2}, ntfsTestClusterSize/2))
+	assert.Equal(t, expected, content)
+
+	// DOS names are omitted from listings.
+	names, err := fs.ReadDir("/Windows")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Fonts", "System32"}, names)
+	_, err = fs.ReadDir("/sparse.dat")
+	assert.EqualError(t, err, "/sparse.dat is not a directory")
+
+	// Reparse points aren't followed.
+	assert.False(t, fs.IsFile("/link"))
+	assert.False(t, fs.IsDir("/link"))
+
+	guest, err := inspectOS(fs)
+	assert.NoError(t, err)
+	assert.Equal(t, &pb.OsRelease{MajorVersion: "2019", DistroId: pb.Distro_WINDOWS, Architecture: pb.Architecture_X64}, guest.release)
+}
+
+func TestNtfs_RejectsTornRecords(t *testing.T) {
+	image, _ := newTestNtfs()
+	// Corrupt the sequence number at the end of the root record's first sector.
+	image[ntfsTestRecordOffset(ntfsRootRecord)+510] ^= 0xff
+	fs, err := OpenFilesystem(bytes.NewReader(image), int64(len(image)))
+	assert.NoError(t, err)
+	_, err = fs.Open("/Windows")
+	assert.EqualError(t, err, "ntfs record 5 is invalid: update sequence mismatch")
+}
+
+const (
+	ntfsTestClusterSize = 4096
+	ntfsTestRecordSize  = 1024
+	ntfsTestClusters    = 64
+)
+
+// ntfsTestRecordOffset returns the location of an MFT record. The $MFT is
+// stored in two runs: clusters 4-5, and 20-21.
+func ntfsTestRecordOffset(number int) int {
+	if number < 8 {
+		return 4*ntfsTestClusterSize + number*ntfsTestRecordSize
+	}
+	return 20*ntfsTestClusterSize + (number-8)*ntfsTestRecordSize
+}
+
+// newTestNtfs creates an NTFS filesystem containing a Windows 2019
+// registry hive and kernel. The hive's content is also returned.
+func newTestNtfs() ([]byte, []byte) {
+	image := make([]byte, ntfsTestClusters*ntfsTestClusterSize)
+	copy(image[3:], ntfsOEMID)
+	binary.LittleEndian.PutUint16(image[0x0b:], sectorSize)
+	image[0x0d] = ntfsTestClusterSize / sectorSize
+	binary.LittleEndian.PutUint64(image[0x30:], 4)
+	image[0x40] = 0xf6 // 2^10 bytes
+	image[0x44] = 1
+
+	hive := newTestHive(&testRegistryKey{subkeys: []*testRegistryKey{
+		{name: "Microsoft", subkeys: []*testRegistryKey{
+			{name: "Windows NT", subkeys: []*testRegistryKey{
+				{name: "CurrentVersion", values: []testRegistryValue{
+					stringRegistryValue("CurrentVersion", "6.3"),
+					dwordRegistryValue("CurrentMajorVersionNumber", 10),
+					dwordRegistryValue("CurrentMinorVersionNumber", 0),
+					stringRegistryValue("InstallationType", "Server"),
+					stringRegistryValue("ProductName", "Windows Server 2019 Datacenter"),
+				}},
+			}},
+		}},
+	}})
+
+	writeNtfsRecord(image, ntfsMFTRecord, ntfsRecordInUse,
+		ntfsNonResidentAttribute(ntfsAttrData, "", 4*ntfsTestClusterSize, 4*ntfsTestClusterSize, [][2]int64{{2, 4}, {2, 20}}))
+
+	writeNtfsRecord(image, ntfsRootRecord, ntfsRecordInUse|ntfsRecordIsDir,
+		ntfsResidentAttribute(ntfsAttrIndexRoot, ntfsFilenameIndex, ntfsIndexRoot(ntfsIndexEntries([]ntfsTestIndexEntry{
+			{name: "link", record: 6}, {name: "sparse.dat", record: 7}, {name: "Windows", record: 11},
+		}, -1))))
+	writeNtfsRecord(image, 6, ntfsRecordInUse,
+		ntfsResidentAttribute(ntfsAttrReparsePoint, "", make([]byte, 8)))
+	copy(image[50*ntfsTestClusterSize:], bytes.Repeat([]byte{1}, ntfsTestClusterSize))
+	copy(image[51*ntfsTestClusterSize:], bytes.Repeat([]byte{2}, ntfsTestClusterSize))
+	writeNtfsRecord(image, 7, ntfsRecordInUse,
+		ntfsNonResidentAttribute(ntfsAttrData, "", 3*ntfsTestClusterSize, 2.5*ntfsTestClusterSize, [][2]int64{{1, 50}, {1, -1}, {1, 51}}))
+
+	// /Windows's entries are stored in an index record at cluster 30. System32
+	// has an additional DOS name.
+	writeNtfsRecord(image, 11, ntfsRecordInUse|ntfsRecordIsDir,
+		ntfsResidentAttribute(ntfsAttrIndexRoot, ntfsFilenameIndex, ntfsIndexRoot(ntfsIndexEntries(nil, 0))),
+		ntfsNonResidentAttribute(ntfsAttrIndexAlloc, ntfsFilenameIndex, ntfsTestClusterSize, ntfsTestClusterSize, [][2]int64{{1, 30}}))
+	indexRecord := image[30*ntfsTestClusterSize:][:ntfsTestClusterSize]
+	copy(indexRecord, ntfsIndexMagic)
+	entries := ntfsIndexEntries([]ntfsTestIndexEntry{
+		{name: "Fonts", record: 8}, {name: "System32", record: 12}, {name: "SYSTEM~1", record: 12, namespace: ntfsNamespaceDOS},
+	}, -1)
+	binary.LittleEndian.PutUint32(indexRecord[0x18:], 0x40)
+	binary.LittleEndian.PutUint32(indexRecord[0x1c:], uint32(0x40+len(entries)))
+	copy(indexRecord[0x58:], entries)
+	applyTestFixups(indexRecord, 0x28)
+
+	writeNtfsRecord(image, 12, ntfsRecordInUse|ntfsRecordIsDir,
+		ntfsResidentAttribute(ntfsAttrIndexRoot, ntfsFilenameIndex, ntfsIndexRoot(ntfsIndexEntries([]ntfsTestIndexEntry{
+			{name: "config", record: 13}, {name: "ntoskrnl.exe", record: 14},
+		}, -1))))
+	writeNtfsRecord(image, 13, ntfsRecordInUse|ntfsRecordIsDir,
+		ntfsResidentAttribute(ntfsAttrIndexRoot, ntfsFilenameIndex, ntfsIndexRoot(ntfsIndexEntries([]ntfsTestIndexEntry{
+			{name: "SOFTWARE", record: 15},
+		}, -1))))
+	writeNtfsRecord(image, 14, ntfsRecordInUse,
+		ntfsResidentAttribute(ntfsAttrData, "", []byte(newTestPE(peMachineAMD64))))
+	copy(image[41*ntfsTestClusterSize:], hive[:ntfsTestClusterSize])
+	copy(image[40*ntfsTestClusterSize:], hive[ntfsTestClusterSize:])
+	writeNtfsRecord(image, 15, ntfsRecordInUse,
+		ntfsNonResidentAttribute(ntfsAttrData, "", int64(len(hive)), int64(len(hive)), [][2]int64{{1, 41}, {1, 40}}))
+	return image, hive
+}
+
+func writeNtfsRecord(image []byte, number int, flags uint16, attributes ...[]byte) {
+	record := image[ntfsTestRecordOffset(number):][:ntfsTestRecordSize]
+	copy(record, ntfsRecordMagic)
+	binary.LittleEndian.PutUint16(record[0x14:], 0x38)
+	binary.LittleEndian.PutUint16(record[0x16:], flags)
+	pos := 0x38
+	for _, attr := range attributes {
+		pos += copy(record[pos:], attr)
+	}
+	binary.LittleEndian.PutUint32(record[pos:], ntfsAttrEnd)
+	applyTestFixups(record, 0x30)
+}
+
+// applyTestFixups replaces the end of each sector with a sequence number, and
+// records the original bytes in the update sequence array at offset.
+func applyTestFixups(block []byte, offset int) {
+	count := len(block)/ntfsFixupStride + 1
+	binary.LittleEndian.PutUint16(block[4:], uint16(offset))
+	binary.LittleEndian.PutUint16(block[6:], uint16(count))
+	copy(block[offset:], "\x01\x00")
+	for i := 1; i < count; i++ {
+		end := i * ntfsFixupStride
+		copy(block[offset+i*2:], block[end-2:end])
+		copy(block[end-2:end], "\x01\x00")
+	}
+}
+
+func ntfsResidentAttribute(attrType uint32, name string, value []byte) []byte {
+	encodedName := encodeUTF16(name)
+	valueOffset := (0x18 + len(encodedName) + 7) &^ 7
+	attr := make([]byte, (valueOffset+len(value)+7)&^7)
+	binary.LittleEndian.PutUint32(attr, attrType)
+	binary.LittleEndian.PutUint32(attr[4:], uint32(len(attr)))
+	attr[9] = byte(len(name))
+	binary.LittleEndian.PutUint16(attr[0x0a:], 0x18)
+	binary.LittleEndian.PutUint32(attr[0x10:], uint32(len(value)))
+	binary.LittleEndian.PutUint16(attr[0x14:], uint16(valueOffset))
+	copy(attr[0x18:], encodedName)
+	copy(attr[valueOffset:], value)
+	return attr
+}
+
+// ntfsNonResidentAttribute creates an attribute stored in runs of {length, lcn}
+// clusters. Runs with a negative lcn are sparse.
+func ntfsNonResidentAttribute(attrType uint32, name string, dataSize, initialized int64, runs [][2]int64) []byte {
+	var runlist []byte
+	var previous int64
+	for _, run := range runs {
+		length := encodeTestInt(run[0])
+		if run[1] < 0 {
+			runlist = append(append(runlist, byte(len(length))), length...)
+			continue
+		}
+		offset := encodeTestInt(run[1] - previous)
+		previous = run[1]
+		runlist = append(append(append(runlist, byte(len(offset)<<4|len(length))), length...), offset...)
+	}
+	runlist = append(runlist, 0)
+
+	encodedName := encodeUTF16(name)
+	runlistOffset := (0x40 + len(encodedName) + 7) &^ 7
+	attr := make([]byte, (runlistOffset+len(runlist)+7)&^7)
+	binary.LittleEndian.PutUint32(attr, attrType)
+	binary.LittleEndian.PutUint32(attr[4:], uint32(len(attr)))
+	attr[8] = 1
+	attr[9] = byte(len(name))
+	binary.LittleEndian.PutUint16(attr[0x0a:], 0x40)
+	binary.LittleEndian.PutUint16(attr[0x20:], uint16(runlistOffset))
+	binary.LittleEndian.PutUint64(attr[0x30:], uint64(dataSize))
+	binary.LittleEndian.PutUint64(attr[0x38:], uint64(initialized))
+	copy(attr[0x40:], encodedName)
+	copy(attr[runlistOffset:], runlist)
+	return attr
+}
+
+// encodeTestInt encodes v using the fewest bytes that preserve its sign.
+func encodeTestInt(v int64) []byte {
+	var b []byte
+	for {
+		b = append(b, byte(v))
+		v >>= 8
+		if (v == 0 && b[len(b)-1]&0x80 == 0) || (v == -1 && b[len(b)-1]&0x80 != 0) {
+			return b
+		}
+	}
+}
+
+type ntfsTestIndexEntry struct {
+	name      string
+	record    uint64
+	namespace byte
+}
+
+// ntfsIndexEntries encodes index entries, followed by the last entry. When
+// subnode isn't negative, the last entry points to that index record.
+func ntfsIndexEntries(entries []ntfsTestIndexEntry, subnode int64) []byte {
+	var encoded []byte
+	for _, e := range entries {
+		name := encodeUTF16(e.name)
+		key := make([]byte, 0x42+len(name))
+		key[0x40] = byte(len(e.name))
+		key[0x41] = e.namespace
+		copy(key[0x42:], name)
+		entry := make([]byte, (0x10+len(key)+7)&^7)
+		binary.LittleEndian.PutUint64(entry, e.record)
+		binary.LittleEndian.PutUint16(entry[8:], uint16(len(entry)))
+		binary.LittleEndian.PutUint16(entry[10:], uint16(len(key)))
+		copy(entry[0x10:], key)
+		encoded = append(encoded, entry...)
+	}
+	last := make([]byte, 0x10)
+	flags := uint16(ntfsIndexEntryLast)
+	if subnode >= 0 {
+		flags |= ntfsIndexEntrySubnode
+		last = append(last, make([]byte, 8)...)
+		binary.LittleEndian.PutUint64(last[0x10:], uint64(subnode))
+	}
+	binary.LittleEndian.PutUint16(last[8:], uint16(len(last)))
+	binary.LittleEndian.PutUint16(last[12:], flags)
+	return append(encoded, last...)
+}
+
+func ntfsIndexRoot(entries []byte) []byte {
+	root := make([]byte, 0x20)
+	binary.LittleEndian.PutUint32(root, 0x30)
+	binary.LittleEndian.PutUint32(root[8:], ntfsTestClusterSize)
+	binary.LittleEndian.PutUint32(root[0x10:], 0x10)
+	binary.LittleEndian.PutUint32(root[0x14:], uint32(0x10+len(entries)))
+	binary.LittleEndian.PutUint32(root[0x18:], uint32(0x10+len(entries)))
+	return append(root, entries...)
+}
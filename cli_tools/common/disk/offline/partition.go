@@ -0,0 +1,286 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package offline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PartitionScheme is the layout used for partitioning a disk.
+type PartitionScheme string
+
+const (
+	// NoPartitions indicates that the disk doesn't have a partition table.
+	NoPartitions PartitionScheme = ""
+	// MBR indicates a DOS partition table.
+	MBR PartitionScheme = "mbr"
+	// GPT indicates a GUID partition table.
+	GPT PartitionScheme = "gpt"
+)
+
+const (
+	// Partition type GUIDs.
+	espGUID      = "C12A7328-F81F-11D2-BA4B-00A0C93EC93B"
+	biosBootGUID = "21686148-6449-6E6F-744E-656564454649"
+
+	// MBR partition types.
+	mbrTypeProtective = 0xee
+	mbrTypeESP        = 0xef
+
+	mbrBootCodeSize  = 440
+	mbrEntriesOffset = 446
+	mbrEntrySize     = 16
+	mbrEntryCount    = 4
+	mbrActiveFlag    = 0x80
+
+	gptSignature      = "EFI PART"
+	gptMaxEntries     = 1024
+	gptMaxEntrySize   = 4096
+	maxLogicalEntries = 128
+)
+
+var mbrExtendedTypes = map[byte]bool{0x05: true, 0x0f: true, 0x85: true}
+
+// Partition is a contiguous range of a disk, as recorded in its partition table.
+type Partition struct {
+	// Number is the partition's one-based index, using Linux's numbering.
+	// For MBR, logical partitions start at five.
+	Number int
+
+	// Start and Size are in bytes.
+	Start int64
+	Size  int64
+
+	// TypeGUID is the partition type for GPT partitions, formatted
+	// as an upper case UUID string.
+	TypeGUID string
+
+	// MBRType is the partition type for MBR partitions.
+	MBRType byte
+
+	// Active is true when the MBR boot indicator is set.
+	Active bool
+}
+
+// PartitionTable describes how a disk is partitioned.
+type PartitionTable struct {
+	Scheme     PartitionScheme
+	Partitions []Partition
+
+	// HybridMBR is true when a GPT disk's protective MBR also describes
+	// partitions, which allows BIOS bootloaders to use them.
+	HybridMBR bool
+
+	// HasMBRBootCode is true when the first sector contains bootstrap code.
+	HasMBRBootCode bool
+}
+
+// IsBIOSBootable returns whether the partition table allows booting with BIOS.
+func (t *PartitionTable) IsBIOSBootable() bool {
+	switch t.Scheme {
+	case MBR:
+		return t.HasMBRBootCode
+	case GPT:
+		if t.HybridMBR {
+			return true
+		}
+		for _, p := range t.Partitions {
+			if p.TypeGUID == biosBootGUID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsUEFIBootable returns whether the partition table has an EFI system partition.
+func (t *PartitionTable) IsUEFIBootable() bool {
+	for _, p := range t.Partitions {
+		if p.TypeGUID == espGUID || p.MBRType == mbrTypeESP {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadPartitionTable reads the MBR or GPT partition table of a disk.
+// A disk without a partition table returns a table with the NoPartitions scheme.
+func ReadPartitionTable(disk io.ReaderAt, diskSize int64) (*PartitionTable, error) {
+	mbr := make([]byte, sectorSize)
+	if err := readFull(disk, mbr, 0); err != nil {
+		return nil, fmt.Errorf("failed to read MBR: %w", err)
+	}
+	table := &PartitionTable{}
+	if mbr[510] != 0x55 || mbr[511] != 0xaa || isFilesystemBootSector(mbr) {
+		return table, nil
+	}
+	table.HasMBRBootCode = !isZero(mbr[:mbrBootCodeSize])
+
+	var primaries []Partition
+	protective := false
+	for i := 0; i < mbrEntryCount; i++ {
+		entry := mbr[mbrEntriesOffset+i*mbrEntrySize:][:mbrEntrySize]
+		p := Partition{
+			Number:  i + 1,
+			Active:  entry[0] == mbrActiveFlag,
+			MBRType: entry[4],
+			Start:   int64(binary.LittleEndian.Uint32(entry[8:])) * sectorSize,
+			Size:    int64(binary.LittleEndian.Uint32(entry[12:])) * sectorSize,
+		}
+		if p.MBRType == 0 || p.Size == 0 {
+			continue
+		}
+		if p.MBRType == mbrTypeProtective {
+			protective = true
+			continue
+		}
+		primaries = append(primaries, p)
+	}
+
+	if protective {
+		partitions, err := readGPT(disk, diskSize)
+		if err != nil {
+			return nil, err
+		}
+		table.Scheme = GPT
+		table.Partitions = partitions
+		table.HybridMBR = len(primaries) > 0
+		return table, nil
+	}
+
+	table.Scheme = MBR
+	for _, p := range primaries {
+		if !mbrExtendedTypes[p.MBRType] {
+			table.Partitions = append(table.Partitions, p)
+			continue
+		}
+		logical, err := readLogicalPartitions(disk, p.Start)
+		if err != nil {
+			return nil, err
+		}
+		table.Partitions = append(table.Partitions, logical...)
+	}
+	return table, nil
+}
+
+// readLogicalPartitions follows the chain of extended boot records that
+// starts at the extended partition's first sector.
+func readLogicalPartitions(disk io.ReaderAt, extendedStart int64) ([]Partition, error) {
+	var partitions []Partition
+	ebr := make([]byte, sectorSize)
+	next := extendedStart
+	for i := 0; i < maxLogicalEntries; i++ {
+		if err := readFull(disk, ebr, next); err != nil {
+			return nil, fmt.Errorf("failed to read extended boot record: %w", err)
+		}
+		if ebr[510] != 0x55 || ebr[511] != 0xaa {
+			return partitions, nil
+		}
+		entry := ebr[mbrEntriesOffset:][:mbrEntrySize]
+		if size := binary.LittleEndian.Uint32(entry[12:]); entry[4] != 0 && size != 0 {
+			partitions = append(partitions, Partition{
+				Number:  len(partitions) + 5,
+				Active:  entry[0] == mbrActiveFlag,
+				MBRType: entry[4],
+				Start:   next + int64(binary.LittleEndian.Uint32(entry[8:]))*sectorSize,
+				Size:    int64(size) * sectorSize,
+			})
+		}
+		link := ebr[mbrEntriesOffset+mbrEntrySize:][:mbrEntrySize]
+		if link[4] == 0 {
+			return partitions, nil
+		}
+		// Links are relative to the start of the extended partition.
+		next = extendedStart + int64(binary.LittleEndian.Uint32(link[8:]))*sectorSize
+	}
+	return nil, fmt.Errorf("more than %d logical partitions found", maxLogicalEntries)
+}
+
+// readGPT reads the partitions from the primary GPT header. Disks that use
+// 4096 byte logical sectors place the header at byte 4096 rather than 512.
+func readGPT(disk io.ReaderAt, diskSize int64) ([]Partition, error) {
+	header := make([]byte, 92)
+	for _, blockSize := range []int64{512, 4096} {
+		if err := readFull(disk, header, blockSize); err != nil {
+			continue
+		}
+		if !bytes.HasPrefix(header, []byte(gptSignature)) {
+			continue
+		}
+		entriesLBA := int64(binary.LittleEndian.Uint64(header[72:]))
+		count := int64(binary.LittleEndian.Uint32(header[80:]))
+		entrySize := int64(binary.LittleEndian.Uint32(header[84:]))
+		if count > gptMaxEntries || entrySize < 128 || entrySize > gptMaxEntrySize {
+			return nil, fmt.Errorf("GPT header has invalid partition entries: count=%d, size=%d", count, entrySize)
+		}
+		if entriesLBA < 0 || entriesLBA > diskSize/blockSize || entriesLBA*blockSize+count*entrySize > diskSize {
+			return nil, fmt.Errorf("GPT partition entries are outside of the disk: lba=%d, count=%d, size=%d", entriesLBA, count, entrySize)
+		}
+		entries := make([]byte, count*entrySize)
+		if err := readFull(disk, entries, entriesLBA*blockSize); err != nil {
+			return nil, fmt.Errorf("failed to read GPT partition entries: %w", err)
+		}
+		var partitions []Partition
+		for i := int64(0); i < count; i++ {
+			entry := entries[i*entrySize:][:entrySize]
+			if isZero(entry[:16]) {
+				continue
+			}
+			first := int64(binary.LittleEndian.Uint64(entry[32:]))
+			last := int64(binary.LittleEndian.Uint64(entry[40:]))
+			if last < first || (last+1)*blockSize > diskSize {
+				return nil, fmt.Errorf("GPT partition %d has an invalid range: %d-%d", i+1, first, last)
+			}
+			partitions = append(partitions, Partition{
+				Number:   int(i) + 1,
+				TypeGUID: formatGUID(entry[:16]),
+				Start:    first * blockSize,
+				Size:     (last - first + 1) * blockSize,
+			})
+		}
+		return partitions, nil
+	}
+	return nil, fmt.Errorf("disk has a protective MBR, but GPT header not found")
+}
+
+// formatGUID formats a GUID that's stored using Microsoft's mixed-endian encoding.
+func formatGUID(b []byte) string {
+	return strings.ToUpper(fmt.Sprintf("%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(b[0:]), binary.LittleEndian.Uint16(b[4:]),
+		binary.LittleEndian.Uint16(b[6:]), b[8:10], b[10:16]))
+}
+
+// isFilesystemBootSector returns whether the first sector of a disk is the
+// boot sector of a filesystem, rather than an MBR. Filesystems such
+// as NTFS and FAT use the same signature as an MBR.
+func isFilesystemBootSector(sector []byte) bool {
+	return bytes.Equal(sector[3:11], []byte(ntfsOEMID)) ||
+		bytes.Equal(sector[54:59], []byte("FAT12")) ||
+		bytes.Equal(sector[54:59], []byte("FAT16")) ||
+		bytes.Equal(sector[82:87], []byte("FAT32"))
+}
+
+func isZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,206 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package offline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testDiskSize = 8 << 20
+
+func TestReadPartitionTable_MBR(t *testing.T) {
+	disk := make([]byte, testDiskSize)
+	copy(disk, []byte{0xeb, 0x63, 0x90})
+	writeMBREntry(disk, 0, 0, mbrActiveFlag, 0x83, 2048, 4096)
+	writeMBREntry(disk, 0, 1, 0, 0x05, 6144, 8192)
+	// The first logical partition, and a link to the second.
+	writeMBREntry(disk, 6144*sectorSize, 0, 0, 0x82, 2048, 1024)
+	writeMBREntry(disk, 6144*sectorSize, 1, 0, 0x05, 4096, 4096)
+	writeMBREntry(disk, 10240*sectorSize, 0, 0, 0x83, 2048, 2048)
+
+	table, err := ReadPartitionTable(bytes.NewReader(disk), testDiskSize)
+	assert.NoError(t, err)
+	assert.Equal(t, &PartitionTable{
+		Scheme: MBR,
+		Partitions: []Partition{
+			{Number: 1, Start: 2048 * sectorSize, Size: 4096 * sectorSize, MBRType: 0x83, Active: true},
+			{Number: 5, Start: 8192 * sectorSize, Size: 1024 * sectorSize, MBRType: 0x82},
+			{Number: 6, Start: 12288 * sectorSize, Size: 2048 * sectorSize, MBRType: 0x83},
+		},
+		HasMBRBootCode: true,
+	}, table)
+	assert.True(t, table.IsBIOSBootable())
+	assert.False(t, table.IsUEFIBootable())
+}
+
+func TestReadPartitionTable_MBRWithoutBootCode(t *testing.T) {
+	disk := make([]byte, testDiskSize)
+	writeMBREntry(disk, 0, 0, 0, mbrTypeESP, 2048, 4096)
+
+	table, err := ReadPartitionTable(bytes.NewReader(disk), testDiskSize)
+	assert.NoError(t, err)
+	assert.Equal(t, MBR, table.Scheme)
+	assert.False(t, table.IsBIOSBootable())
+	assert.True(t, table.IsUEFIBootable())
+}
+
+func TestReadPartitionTable_GPT(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		blockSize    int64
+		partitions   []string
+		hybrid       bool
+		expectedBIOS bool
+		expectedUEFI bool
+	}{
+		{"UEFI only", 512, []string{espGUID, linuxDataGUID}, false, false, true},
+		{"BIOS boot partition", 512, []string{biosBootGUID, espGUID, linuxDataGUID}, false, true, true},
+		{"hybrid MBR", 512, []string{linuxDataGUID}, true, true, false},
+		{"4096 byte sectors", 4096, []string{espGUID, linuxDataGUID}, false, false, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			disk := newGPTDisk(tt.blockSize, tt.partitions)
+			if tt.hybrid {
+				writeMBREntry(disk, 0, 1, 0, 0x83, 2048, 2048)
+			}
+			table, err := ReadPartitionTable(bytes.NewReader(disk), testDiskSize)
+			assert.NoError(t, err)
+			assert.Equal(t, GPT, table.Scheme)
+			assert.Equal(t, tt.hybrid, table.HybridMBR)
+			assert.Equal(t, tt.expectedBIOS, table.IsBIOSBootable())
+			assert.Equal(t, tt.expectedUEFI, table.IsUEFIBootable())
+			assert.Len(t, table.Partitions, len(tt.partitions))
+			for i, p := range table.Partitions {
+				assert.Equal(t, i+1, p.Number)
+				assert.Equal(t, tt.partitions[i], p.TypeGUID)
+				assert.Equal(t, int64(i+1)<<20, p.Start)
+				assert.Equal(t, int64(1)<<20, p.Size)
+			}
+		})
+	}
+}
+
+func TestReadPartitionTable_NoPartitions(t *testing.T) {
+	ntfs := make([]byte, testDiskSize)
+	copy(ntfs[3:], ntfsOEMID)
+	ntfs[510], ntfs[511] = 0x55, 0xaa
+
+	for _, tt := range []struct {
+		name string
+		disk []byte
+	}{
+		{"empty disk", make([]byte, testDiskSize)},
+		{"ntfs boot sector", ntfs},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			table, err := ReadPartitionTable(bytes.NewReader(tt.disk), testDiskSize)
+			assert.NoError(t, err)
+			assert.Equal(t, &PartitionTable{Scheme: NoPartitions}, table)
+			assert.False(t, table.IsBIOSBootable())
+			assert.False(t, table.IsUEFIBootable())
+		})
+	}
+}
+
+func TestReadPartitionTable_RejectsInvalidGPT(t *testing.T) {
+	disk := newGPTDisk(512, []string{linuxDataGUID})
+	// Move the partition's last LBA past the end of the disk.
+	binary.LittleEndian.PutUint64(disk[2*512+40:], testDiskSize)
+
+	_, err := ReadPartitionTable(bytes.NewReader(disk), testDiskSize)
+	assert.EqualError(t, err, "GPT partition 1 has an invalid range: 2048-8388608")
+
+	_, err = ReadPartitionTable(bytes.NewReader(newGPTDisk(512, nil)[:512]), 512)
+	assert.EqualError(t, err, "disk has a protective MBR, but GPT header not found")
+}
+
+func TestReadPartitionTable_RejectsMalformedGPTHeader(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		entriesLBA uint64
+		count      uint32
+		entrySize  uint32
+		wantErr    string
+	}{
+		{"entry size too small", 2, 128, 64, "GPT header has invalid partition entries: count=128, size=64"},
+		{"entry size too large", 2, 1, 1 << 20, "GPT header has invalid partition entries: count=1, size=1048576"},
+		{"too many entries", 2, 1 << 16, 128, "GPT header has invalid partition entries: count=65536, size=128"},
+		{"entries past end of disk", testDiskSize/512 - 1, 128, 128, "GPT partition entries are outside of the disk: lba=16383, count=128, size=128"},
+		{"entries LBA past end of disk", 1 << 62, 128, 128, "GPT partition entries are outside of the disk: lba=4611686018427387904, count=128, size=128"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			disk := newGPTDisk(512, []string{linuxDataGUID})
+			binary.LittleEndian.PutUint64(disk[512+72:], tt.entriesLBA)
+			binary.LittleEndian.PutUint32(disk[512+80:], tt.count)
+			binary.LittleEndian.PutUint32(disk[512+84:], tt.entrySize)
+
+			_, err := ReadPartitionTable(bytes.NewReader(disk), testDiskSize)
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+const linuxDataGUID = "0FC63DAF-8483-4772-8E79-3D69D8477DE4"
+
+// writeMBREntry writes a partition entry to the MBR or EBR at sector.
+func writeMBREntry(disk []byte, sector int64, index int, status, partitionType byte, start, size uint32) {
+	entry := disk[sector+mbrEntriesOffset+int64(index*mbrEntrySize):][:mbrEntrySize]
+	entry[0] = status
+	entry[4] = partitionType
+	binary.LittleEndian.PutUint32(entry[8:], start)
+	binary.LittleEndian.PutUint32(entry[12:], size)
+	disk[sector+510], disk[sector+511] = 0x55, 0xaa
+}
+
+// newGPTDisk creates a GPT disk with a partition for each type. Partitions
+// are 1MiB, starting at 1MiB.
+func newGPTDisk(blockSize int64, types []string) []byte {
+	disk := make([]byte, testDiskSize)
+	writeMBREntry(disk, 0, 0, 0, mbrTypeProtective, 1, uint32(testDiskSize/blockSize-1))
+	header := disk[blockSize:]
+	copy(header, gptSignature)
+	binary.LittleEndian.PutUint64(header[72:], 2)
+	binary.LittleEndian.PutUint32(header[80:], 128)
+	binary.LittleEndian.PutUint32(header[84:], 128)
+	for i, guid := range types {
+		entry := disk[2*blockSize+int64(i)*128:]
+		copy(entry, parseGUID(guid))
+		copy(entry[16:], parseGUID(guid)) // Unique partition GUID.
+		binary.LittleEndian.PutUint64(entry[32:], uint64(int64(i+1)<<20/blockSize))
+		binary.LittleEndian.PutUint64(entry[40:], uint64(int64(i+2)<<20/blockSize-1))
+	}
+	return disk
+}
+
+// parseGUID is the inverse of formatGUID.
+func parseGUID(guid string) []byte {
+	var a uint32
+	var b, c uint16
+	var d, e []byte
+	fmt.Sscanf(strings.ReplaceAll(guid, "-", " "), "%x %x %x %x %x", &a, &b, &c, &d, &e)
+	encoded := make([]byte, 16)
+	binary.LittleEndian.PutUint32(encoded, a)
+	binary.LittleEndian.PutUint16(encoded[4:], b)
+	binary.LittleEndian.PutUint16(encoded[6:], c)
+	copy(encoded[8:], d)
+	copy(encoded[10:], e)
+	return encoded
+}
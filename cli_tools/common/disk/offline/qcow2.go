@@ -0,0 +1,229 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package offline
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// The qcow2 format is documented at
+// https://github.com/qemu/qemu/blob/master/docs/interop/qcow2.txt
+const (
+	qcow2Magic = "QFI\xfb"
+
+	qcow2IncompatibleDirty           = 1 << 0
+	qcow2IncompatibleCompressionType = 1 << 3
+
+	qcow2CompressionZlib = 0
+	qcow2CompressionZstd = 1
+
+	qcow2OffsetMask     = 0x00fffffffffffe00
+	qcow2CompressedFlag = 1 << 62
+	qcow2ZeroFlag       = 1 << 0
+
+	// Keeps memory bounded for very large images, while allowing
+	// the L2 tables that cover the partitions and filesystem metadata
+	// to remain in memory during inspection.
+	qcow2MaxCachedL2Tables = 64
+)
+
+// qcow2Image is an Image backed by a qcow2 file.
+type qcow2Image struct {
+	r               io.ReaderAt
+	size            int64
+	clusterBits     uint32
+	clusterSize     int64
+	l1Table         []uint64
+	compressionType uint8
+
+	mu       sync.Mutex
+	l2Cache  map[uint64][]uint64
+	lastHost uint64
+	lastData []byte
+}
+
+func newQcow2Image(r io.ReaderAt) (*qcow2Image, error) {
+	header := make([]byte, 112)
+	if err := readFull(r, header[:72], 0); err != nil {
+		return nil, fmt.Errorf("failed to read qcow2 header: %w", err)
+	}
+	version := binary.BigEndian.Uint32(header[4:])
+	if version != 2 && version != 3 {
+		return nil, fmt.Errorf("qcow2 version %d is not supported", version)
+	}
+	if backingFileOffset := binary.BigEndian.Uint64(header[8:]); backingFileOffset != 0 {
+		return nil, errors.New("qcow2 files with a backing file are not supported")
+	}
+	if cryptMethod := binary.BigEndian.Uint32(header[32:]); cryptMethod != 0 {
+		return nil, errors.New("encrypted qcow2 files are not supported")
+	}
+	img := &qcow2Image{
+		r:           r,
+		size:        int64(binary.BigEndian.Uint64(header[24:])),
+		clusterBits: binary.BigEndian.Uint32(header[20:]),
+		l2Cache:     map[uint64][]uint64{},
+	}
+	if img.clusterBits < 9 || img.clusterBits > 21 {
+		return nil, fmt.Errorf("qcow2 cluster bits %d is invalid", img.clusterBits)
+	}
+	img.clusterSize = int64(1) << img.clusterBits
+
+	if version == 3 {
+		if err := readFull(r, header[72:104], 72); err != nil {
+			return nil, fmt.Errorf("failed to read qcow2 header: %w", err)
+		}
+		incompatible := binary.BigEndian.Uint64(header[72:])
+		if incompatible&qcow2IncompatibleCompressionType != 0 {
+			headerLength := binary.BigEndian.Uint32(header[100:])
+			if headerLength > 104 {
+				if err := readFull(r, header[104:105], 104); err != nil {
+					return nil, fmt.Errorf("failed to read qcow2 header: %w", err)
+				}
+				img.compressionType = header[104]
+			}
+		}
+		if unknown := incompatible &^ (qcow2IncompatibleDirty | qcow2IncompatibleCompressionType); unknown != 0 {
+			return nil, fmt.Errorf("qcow2 incompatible features %#x are not supported", unknown)
+		}
+		if img.compressionType != qcow2CompressionZlib && img.compressionType != qcow2CompressionZstd {
+			return nil, fmt.Errorf("qcow2 compression type %d is not supported", img.compressionType)
+		}
+	}
+
+	l1Size := binary.BigEndian.Uint32(header[36:])
+	l1Offset := int64(binary.BigEndian.Uint64(header[40:]))
+	entriesPerL2 := img.clusterSize / 8
+	if needed := (img.size + img.clusterSize*entriesPerL2 - 1) / (img.clusterSize * entriesPerL2); int64(l1Size) < needed {
+		return nil, fmt.Errorf("qcow2 L1 table has %d entries; %d are required for the disk size", l1Size, needed)
+	}
+	l1 := make([]byte, int64(l1Size)*8)
+	if err := readFull(r, l1, l1Offset); err != nil {
+		return nil, fmt.Errorf("failed to read qcow2 L1 table: %w", err)
+	}
+	img.l1Table = make([]uint64, l1Size)
+	for i := range img.l1Table {
+		img.l1Table[i] = binary.BigEndian.Uint64(l1[i*8:])
+	}
+	return img, nil
+}
+
+func (img *qcow2Image) Size() int64 {
+	return img.size
+}
+
+func (img *qcow2Image) Format() string {
+	return "qcow2"
+}
+
+func (img *qcow2Image) ReadAt(p []byte, off int64) (int, error) {
+	return readChunked(p, off, img.size, img.clusterSize, img.readCluster)
+}
+
+func (img *qcow2Image) readCluster(p []byte, index int64, offset int64) error {
+	entriesPerL2 := img.clusterSize / 8
+	l2Offset := img.l1Table[index/entriesPerL2] & qcow2OffsetMask
+	if l2Offset == 0 {
+		zero(p)
+		return nil
+	}
+	l2Table, err := img.l2Table(l2Offset)
+	if err != nil {
+		return err
+	}
+	entry := l2Table[index%entriesPerL2]
+
+	if entry&qcow2CompressedFlag != 0 {
+		cluster, err := img.compressedCluster(entry)
+		if err != nil {
+			return err
+		}
+		copy(p, cluster[offset:])
+		return nil
+	}
+	host := entry & qcow2OffsetMask
+	if host == 0 || entry&qcow2ZeroFlag != 0 {
+		zero(p)
+		return nil
+	}
+	return readFull(img.r, p, int64(host)+offset)
+}
+
+func (img *qcow2Image) l2Table(offset uint64) ([]uint64, error) {
+	img.mu.Lock()
+	defer img.mu.Unlock()
+	if table, found := img.l2Cache[offset]; found {
+		return table, nil
+	}
+	buf := make([]byte, img.clusterSize)
+	if err := readFull(img.r, buf, int64(offset)); err != nil {
+		return nil, fmt.Errorf("failed to read qcow2 L2 table: %w", err)
+	}
+	table := make([]uint64, img.clusterSize/8)
+	for i := range table {
+		table[i] = binary.BigEndian.Uint64(buf[i*8:])
+	}
+	if len(img.l2Cache) >= qcow2MaxCachedL2Tables {
+		img.l2Cache = map[uint64][]uint64{}
+	}
+	img.l2Cache[offset] = table
+	return table, nil
+}
+
+// compressedCluster returns the decompressed content of the cluster
+// referenced by a compressed L2 entry.
+func (img *qcow2Image) compressedCluster(entry uint64) ([]byte, error) {
+	img.mu.Lock()
+	defer img.mu.Unlock()
+	if img.lastData != nil && img.lastHost == entry {
+		return img.lastData, nil
+	}
+
+	offsetBits := 62 - (img.clusterBits - 8)
+	host := entry & (1<<offsetBits - 1)
+	sectors := (entry>>offsetBits)&(1<<(img.clusterBits-8)-1) + 1
+	compressed := make([]byte, sectors*sectorSize-host%sectorSize)
+	n, err := img.r.ReadAt(compressed, int64(host))
+	// The last compressed cluster may end before the sector boundary.
+	if n == 0 && err != nil {
+		return nil, fmt.Errorf("failed to read qcow2 compressed cluster: %w", err)
+	}
+	compressed = compressed[:n]
+
+	var decompressor io.ReadCloser
+	if img.compressionType == qcow2CompressionZstd {
+		decoder, err := zstd.NewReader(bytes.NewReader(compressed), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		decompressor = decoder.IOReadCloser()
+	} else {
+		decompressor = flate.NewReader(bytes.NewReader(compressed))
+	}
+	defer decompressor.Close()
+	cluster := make([]byte, img.clusterSize)
+	if _, err := io.ReadFull(decompressor, cluster); err != nil {
+		return nil, fmt.Errorf("failed to decompress qcow2 cluster: %w", err)
+	}
+	img.lastHost, img.lastData = entry, cluster
+	return cluster, nil
+}
@@ -0,0 +1,259 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package offline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// The Windows registry file format is documented at
+// https://github.com/msuhanov/regf/blob/master/Windows%20registry%20file%20format%20specification.md
+const (
+	regfMagic         = "regf"
+	regfHiveBinsStart = 4096
+	regfMaxCellSize   = 1 << 20
+	regfMaxListDepth  = 4
+
+	regKeyCompressedName   = 0x20
+	regValueCompressedName = 0x1
+	regValueDataInline     = 0x80000000
+
+	regSZ       = 1
+	regExpandSZ = 2
+	regDWORD    = 4
+)
+
+// registryHive reads keys and values from a registry hive file, such as
+// Windows/System32/config/SOFTWARE.
+type registryHive struct {
+	r    io.ReaderAt
+	root uint32
+}
+
+// registryKey is a key's cell within a hive.
+type registryKey struct {
+	hive *registryHive
+	cell []byte
+}
+
+func openRegistryHive(r io.ReaderAt) (*registryHive, error) {
+	header := make([]byte, 0x28)
+	if err := readFull(r, header, 0); err != nil {
+		return nil, fmt.Errorf("failed to read registry header: %w", err)
+	}
+	if !bytes.HasPrefix(header, []byte(regfMagic)) {
+		return nil, errors.New("registry header not found")
+	}
+	return &registryHive{r, binary.LittleEndian.Uint32(header[0x24:])}, nil
+}
+
+// cell returns the data of the cell at offset, which is relative to
+// the start of the hive bins.
+func (h *registryHive) cell(offset uint32) ([]byte, error) {
+	sizeBytes := make([]byte, 4)
+	if err := readFull(h.r, sizeBytes, regfHiveBinsStart+int64(offset)); err != nil {
+		return nil, fmt.Errorf("failed to read registry cell: %w", err)
+	}
+	// Allocated cells have a negative size.
+	size := -int32(binary.LittleEndian.Uint32(sizeBytes))
+	if size < 4 || size > regfMaxCellSize {
+		return nil, fmt.Errorf("registry cell at %#x is invalid", offset)
+	}
+	data := make([]byte, size-4)
+	if err := readFull(h.r, data, regfHiveBinsStart+int64(offset)+4); err != nil {
+		return nil, fmt.Errorf("failed to read registry cell: %w", err)
+	}
+	return data, nil
+}
+
+// key returns the key at path, relative to the hive's root key.
+// Paths are separated with backslashes, and matched case-insensitively.
+func (h *registryHive) key(path string) (*registryKey, error) {
+	cell, err := h.cell(h.root)
+	if err != nil {
+		return nil, err
+	}
+	key := &registryKey{h, cell}
+	for _, name := range strings.Split(path, `\`) {
+		if name == "" {
+			continue
+		}
+		if key, err = key.subkey(name); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+func (k *registryKey) subkey(name string) (*registryKey, error) {
+	if len(k.cell) < 0x4c || !bytes.HasPrefix(k.cell, []byte("nk")) {
+		return nil, errors.New("registry key is invalid")
+	}
+	if binary.LittleEndian.Uint32(k.cell[0x14:]) == 0 {
+		return nil, fmt.Errorf("registry key %s: %w", name, os.ErrNotExist)
+	}
+	return k.searchList(binary.LittleEndian.Uint32(k.cell[0x1c:]), name, 0)
+}
+
+// searchList searches a subkey list. Index roots ("ri") reference other
+// lists, while leaves ("li", "lf", and "lh") reference keys.
+func (k *registryKey) searchList(offset uint32, name string, depth int) (*registryKey, error) {
+	list, err := k.hive.cell(offset)
+	if err != nil {
+		return nil, err
+	}
+	if depth > regfMaxListDepth || len(list) < 4 {
+		return nil, errors.New("registry subkey list is invalid")
+	}
+	count := int(binary.LittleEndian.Uint16(list[2:]))
+	stride := 4
+	switch string(list[:2]) {
+	case "lf", "lh":
+		// Each entry is followed by a hash of the name.
+		stride = 8
+	case "li", "ri":
+	default:
+		return nil, errors.New("registry subkey list is invalid")
+	}
+	if 4+count*stride > len(list) {
+		return nil, errors.New("registry subkey list is invalid")
+	}
+	for i := 0; i < count; i++ {
+		child := binary.LittleEndian.Uint32(list[4+i*stride:])
+		if string(list[:2]) == "ri" {
+			key, err := k.searchList(child, name, depth+1)
+			if err == nil || !errors.Is(err, os.ErrNotExist) {
+				return key, err
+			}
+			continue
+		}
+		cell, err := k.hive.cell(child)
+		if err != nil {
+			return nil, err
+		}
+		if len(cell) < 0x4c || !bytes.HasPrefix(cell, []byte("nk")) {
+			return nil, errors.New("registry key is invalid")
+		}
+		nameLength := int(binary.LittleEndian.Uint16(cell[0x48:]))
+		if 0x4c+nameLength > len(cell) {
+			return nil, errors.New("registry key is invalid")
+		}
+		keyName := decodeRegistryName(cell[0x4c:0x4c+nameLength],
+			binary.LittleEndian.Uint16(cell[2:])&regKeyCompressedName != 0)
+		if strings.EqualFold(keyName, name) {
+			return &registryKey{k.hive, cell}, nil
+		}
+	}
+	return nil, fmt.Errorf("registry key %s: %w", name, os.ErrNotExist)
+}
+
+// value returns the type and data of the value with name.
+func (k *registryKey) value(name string) (uint32, []byte, error) {
+	if len(k.cell) < 0x4c || !bytes.HasPrefix(k.cell, []byte("nk")) {
+		return 0, nil, errors.New("registry key is invalid")
+	}
+	count := int(binary.LittleEndian.Uint32(k.cell[0x24:]))
+	if count == 0 {
+		return 0, nil, fmt.Errorf("registry value %s: %w", name, os.ErrNotExist)
+	}
+	list, err := k.hive.cell(binary.LittleEndian.Uint32(k.cell[0x28:]))
+	if err != nil {
+		return 0, nil, err
+	}
+	if count*4 > len(list) {
+		return 0, nil, errors.New("registry value list is invalid")
+	}
+	for i := 0; i < count; i++ {
+		cell, err := k.hive.cell(binary.LittleEndian.Uint32(list[i*4:]))
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(cell) < 0x14 || !bytes.HasPrefix(cell, []byte("vk")) {
+			return 0, nil, errors.New("registry value is invalid")
+		}
+		nameLength := int(binary.LittleEndian.Uint16(cell[2:]))
+		if 0x14+nameLength > len(cell) {
+			return 0, nil, errors.New("registry value is invalid")
+		}
+		valueName := decodeRegistryName(cell[0x14:0x14+nameLength],
+			binary.LittleEndian.Uint16(cell[0x10:])&regValueCompressedName != 0)
+		if !strings.EqualFold(valueName, name) {
+			continue
+		}
+		valueType := binary.LittleEndian.Uint32(cell[0xc:])
+		size := binary.LittleEndian.Uint32(cell[4:])
+		if size&regValueDataInline != 0 {
+			size &^= regValueDataInline
+			if size > 4 {
+				return 0, nil, errors.New("registry value is invalid")
+			}
+			return valueType, cell[8 : 8+size], nil
+		}
+		data, err := k.hive.cell(binary.LittleEndian.Uint32(cell[8:]))
+		if err != nil {
+			return 0, nil, err
+		}
+		// Values larger than a cell are stored using "db" records,
+		// which aren't needed for the values that are used during inspection.
+		if int(size) > len(data) {
+			return 0, nil, fmt.Errorf("registry value %s is too large", name)
+		}
+		return valueType, data[:size], nil
+	}
+	return 0, nil, fmt.Errorf("registry value %s: %w", name, os.ErrNotExist)
+}
+
+// stringValue returns the value with name, which must be REG_SZ or REG_EXPAND_SZ.
+func (k *registryKey) stringValue(name string) (string, error) {
+	valueType, data, err := k.value(name)
+	if err != nil {
+		return "", err
+	}
+	if valueType != regSZ && valueType != regExpandSZ {
+		return "", fmt.Errorf("registry value %s has type %d; expected a string", name, valueType)
+	}
+	return strings.TrimRight(decodeUTF16(data), "\x00"), nil
+}
+
+// dwordValue returns the value with name, which must be REG_DWORD.
+func (k *registryKey) dwordValue(name string) (uint32, error) {
+	valueType, data, err := k.value(name)
+	if err != nil {
+		return 0, err
+	}
+	if valueType != regDWORD || len(data) != 4 {
+		return 0, fmt.Errorf("registry value %s has type %d; expected a DWORD", name, valueType)
+	}
+	return binary.LittleEndian.Uint32(data), nil
+}
+
+// decodeRegistryName decodes the name of a key or value, which is either
+// stored as Latin-1, or UTF-16.
+func decodeRegistryName(b []byte, compressed bool) string {
+	if !compressed {
+		return decodeUTF16(b)
+	}
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
@@ -0,0 +1,216 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package offline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryHive_ReadsValues(t *testing.T) {
+	hive := newTestHive(&testRegistryKey{subkeys: []*testRegistryKey{
+		{name: "Classes"},
+		{name: "Microsoft", listType: "ri", subkeys: []*testRegistryKey{
+			{name: "Office"},
+			{name: "Windows"},
+			{name: "Windows NT", listType: "li", utf16Name: true, subkeys: []*testRegistryKey{
+				{name: "CurrentVersion", values: []testRegistryValue{
+					stringRegistryValue("ProductName", "Windows Server 2019 Datacenter"),
+					stringRegistryValue("CurrentVersion", "6.3"),
+					dwordRegistryValue("CurrentMajorVersionNumber", 10),
+				}},
+			}},
+		}},
+	}})
+
+	h, err := openRegistryHive(bytes.NewReader(hive))
+	assert.NoError(t, err)
+	key, err := h.key(`microsoft\WINDOWS NT\CurrentVersion`)
+	assert.NoError(t, err)
+
+	product, err := key.stringValue("ProductName")
+	assert.NoError(t, err)
+	assert.Equal(t, "Windows Server 2019 Datacenter", product)
+	major, err := key.dwordValue("currentmajorversionnumber")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(10), major)
+
+	_, err = key.dwordValue("ProductName")
+	assert.EqualError(t, err, "registry value ProductName has type 1; expected a DWORD")
+	_, err = key.stringValue("InstallationType")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	_, err = h.key(`Microsoft\Windows NT\Missing`)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	_, err = h.key(`Classes\Missing`)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestRegistryHive_RejectsInvalidFiles(t *testing.T) {
+	_, err := openRegistryHive(bytes.NewReader(make([]byte, 4096)))
+	assert.EqualError(t, err, "registry header not found")
+
+	hive := newTestHive(&testRegistryKey{})
+	// Mark the root key's cell as unallocated.
+	root := binary.LittleEndian.Uint32(hive[0x24:])
+	binary.LittleEndian.PutUint32(hive[regfHiveBinsStart+root:], 0x100)
+	h, err := openRegistryHive(bytes.NewReader(hive))
+	assert.NoError(t, err)
+	_, err = h.key(`Microsoft`)
+	assert.EqualError(t, err, fmt.Sprintf("registry cell at %#x is invalid", root))
+}
+
+type testRegistryKey struct {
+	name string
+	// listType is the subkey list's type: lh (default), li, or ri. When
+	// ri is used, subkeys are split between two li lists.
+	listType  string
+	utf16Name bool
+	subkeys   []*testRegistryKey
+	values    []testRegistryValue
+}
+
+type testRegistryValue struct {
+	name      string
+	valueType uint32
+	data      []byte
+}
+
+func stringRegistryValue(name, value string) testRegistryValue {
+	return testRegistryValue{name, regSZ, encodeUTF16(value + "\x00")}
+}
+
+func dwordRegistryValue(name string, value uint32) testRegistryValue {
+	data := make([]byte, 4)
+	binary.LittleEndian.PutUint32(data, value)
+	return testRegistryValue{name, regDWORD, data}
+}
+
+// newTestHive encodes root and its descendants as a registry hive file.
+func newTestHive(root *testRegistryKey) []byte {
+	var b testHiveBuilder
+	// The hive bin header.
+	b.bins = make([]byte, 0x20)
+	copy(b.bins, "hbin")
+	rootOffset := b.writeKey(root)
+
+	hive := make([]byte, regfHiveBinsStart)
+	copy(hive, regfMagic)
+	binary.LittleEndian.PutUint32(hive[0x24:], rootOffset)
+	return append(hive, b.bins...)
+}
+
+type testHiveBuilder struct {
+	bins []byte
+}
+
+// allocate writes data to a new cell, and returns the cell's offset.
+func (b *testHiveBuilder) allocate(data []byte) uint32 {
+	offset := uint32(len(b.bins))
+	size := (len(data) + 4 + 7) &^ 7
+	cell := make([]byte, size)
+	binary.LittleEndian.PutUint32(cell, uint32(-int32(size)))
+	copy(cell[4:], data)
+	b.bins = append(b.bins, cell...)
+	return offset
+}
+
+func (b *testHiveBuilder) writeKey(k *testRegistryKey) uint32 {
+	var children []uint32
+	for _, subkey := range k.subkeys {
+		children = append(children, b.writeKey(subkey))
+	}
+	var values []uint32
+	for _, v := range k.values {
+		vk := make([]byte, 0x14+len(v.name))
+		copy(vk, "vk")
+		binary.LittleEndian.PutUint16(vk[2:], uint16(len(v.name)))
+		binary.LittleEndian.PutUint32(vk[0xc:], v.valueType)
+		binary.LittleEndian.PutUint16(vk[0x10:], regValueCompressedName)
+		copy(vk[0x14:], v.name)
+		if len(v.data) <= 4 {
+			binary.LittleEndian.PutUint32(vk[4:], uint32(len(v.data))|regValueDataInline)
+			copy(vk[8:], v.data)
+		} else {
+			binary.LittleEndian.PutUint32(vk[4:], uint32(len(v.data)))
+			binary.LittleEndian.PutUint32(vk[8:], b.allocate(v.data))
+		}
+		values = append(values, b.allocate(vk))
+	}
+
+	nk := make([]byte, 0x4c)
+	copy(nk, "nk")
+	name := []byte(k.name)
+	if k.utf16Name {
+		name = encodeUTF16(k.name)
+	} else {
+		binary.LittleEndian.PutUint16(nk[2:], regKeyCompressedName)
+	}
+	binary.LittleEndian.PutUint16(nk[0x48:], uint16(len(name)))
+	nk = append(nk, name...)
+	if len(children) > 0 {
+		binary.LittleEndian.PutUint32(nk[0x14:], uint32(len(children)))
+		binary.LittleEndian.PutUint32(nk[0x1c:], b.writeSubkeyList(k.listType, children))
+	}
+	if len(values) > 0 {
+		list := make([]byte, len(values)*4)
+		for i, v := range values {
+			binary.LittleEndian.PutUint32(list[i*4:], v)
+		}
+		binary.LittleEndian.PutUint32(nk[0x24:], uint32(len(values)))
+		binary.LittleEndian.PutUint32(nk[0x28:], b.allocate(list))
+	}
+	return b.allocate(nk)
+}
+
+func (b *testHiveBuilder) writeSubkeyList(listType string, children []uint32) uint32 {
+	switch listType {
+	case "ri":
+		half := len(children) / 2
+		return b.writeList("ri", 4, []uint32{
+			b.writeList("li", 4, children[:half]),
+			b.writeList("li", 4, children[half:]),
+		})
+	case "li":
+		return b.writeList("li", 4, children)
+	default:
+		return b.writeList("lh", 8, children)
+	}
+}
+
+func (b *testHiveBuilder) writeList(signature string, stride int, offsets []uint32) uint32 {
+	list := make([]byte, 4+len(offsets)*stride)
+	copy(list, signature)
+	binary.LittleEndian.PutUint16(list[2:], uint16(len(offsets)))
+	for i, offset := range offsets {
+		binary.LittleEndian.PutUint32(list[4+i*stride:], offset)
+	}
+	return b.allocate(list)
+}
+
+func encodeUTF16(s string) []byte {
+	encoded := utf16.Encode([]rune(s))
+	b := make([]byte, len(encoded)*2)
+	for i, c := range encoded {
+		binary.LittleEndian.PutUint16(b[i*2:], c)
+	}
+	return b
+}
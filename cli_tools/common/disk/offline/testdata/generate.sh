@@ -0,0 +1,72 @@
+#!/bin/bash
+# Copyright 2021 Google Inc. All Rights Reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# Generates the filesystem fixtures used by the offline inspector's tests.
+# Requires e2fsprogs 1.43 or later, for `mke2fs -d`.
+
+set -euo pipefail
+
+cd "$(dirname "$0")"
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+
+# elf_header writes the first 20 bytes of an ELF executable, which
+# include the machine type.
+function elf_header() {
+  local class=$1 machine=$2
+  printf '\x7fELF\x'"$class"'\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\x'"$machine"'\x00'
+}
+
+# Ubuntu 20.04 on ext4, using a merged /usr, and with /etc/os-release
+# linked to /usr/lib/os-release.
+root="$tmp/ubuntu"
+mkdir -p "$root/etc" "$root/usr/lib" "$root/usr/bin"
+ln -s usr/bin "$root/bin"
+ln -s ../usr/lib/os-release "$root/etc/os-release"
+cat > "$root/usr/lib/os-release" <<'OS'
+NAME="Ubuntu"
+VERSION="20.04.2 LTS (Focal Fossa)"
+ID=ubuntu
+ID_LIKE=debian
+PRETTY_NAME="Ubuntu 20.04.2 LTS"
+VERSION_ID="20.04"
+OS
+echo "bullseye/sid" > "$root/etc/debian_version"
+elf_header 02 3e > "$root/usr/bin/bash"
+# Enough entries that /etc spans multiple blocks, and uses an htree index.
+for i in $(seq 1 200); do
+  echo "$i" > "$root/etc/config-file-$i.conf"
+done
+mke2fs -q -F -t ext4 -b 1024 -O ^has_journal -E root_owner=0:0 -d "$root" "$tmp/ubuntu-ext4.img" 2M
+gzip -9 -n -c "$tmp/ubuntu-ext4.img" > ubuntu-ext4.img.gz
+
+# CentOS 7 on ext2, which uses block maps rather than extents.
+root="$tmp/centos"
+mkdir -p "$root/etc" "$root/bin"
+cat > "$root/etc/os-release" <<'OS'
+NAME="CentOS Linux"
+VERSION="7 (Core)"
+ID="centos"
+ID_LIKE="rhel fedora"
+VERSION_ID="7"
+OS
+echo "CentOS Linux release 7.9.2009 (Core)" > "$root/etc/centos-release"
+ln -s centos-release "$root/etc/redhat-release"
+elf_header 01 03 > "$root/bin/bash"
+# Larger than the twelve direct blocks of an inode, so that it uses an
+# indirect block.
+seq 1 5000 > "$root/etc/large-file"
+mke2fs -q -F -t ext2 -b 1024 -E root_owner=0:0 -d "$root" "$tmp/centos-ext2.img" 1M
+gzip -9 -n -c "$tmp/centos-ext2.img" > centos-ext2.img.gz
@@ -0,0 +1,202 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package offline
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// The hosted sparse extent format is documented in VMware's
+// "Virtual Disk Format 5.0" specification.
+const (
+	vmdkSparseMagic         = "KDMV"
+	vmdkDescriptorSignature = "# Disk DescriptorFile"
+	vmdkHeaderSize          = 79
+
+	vmdkFlagCompressed = 1 << 16
+
+	vmdkCompressionDeflate = 1
+
+	// gdOffset is set to this value when the grain directory's location
+	// is only recorded in the footer, as in streamOptimized files.
+	vmdkGDAtEnd = 0xffffffffffffffff
+
+	// Grain table entry for a grain that is allocated, but only contains zeros.
+	vmdkZeroGrain = 1
+
+	// The footer is followed by an end-of-stream marker.
+	vmdkFooterOffsetFromEnd = 2 * sectorSize
+)
+
+// vmdkImage is an Image backed by a single hosted sparse extent.
+type vmdkImage struct {
+	r              io.ReaderAt
+	size           int64
+	grainSize      int64
+	gtesPerGT      int64
+	grainDirectory []uint32
+	compressed     bool
+
+	mu        sync.Mutex
+	gtCache   map[uint32][]uint32
+	lastGrain uint32
+	lastData  []byte
+}
+
+func newVmdkImage(r io.ReaderAt, fileSize int64) (*vmdkImage, error) {
+	header := make([]byte, vmdkHeaderSize)
+	if err := readFull(r, header, 0); err != nil {
+		return nil, fmt.Errorf("failed to read vmdk header: %w", err)
+	}
+	if gdOffset := binary.LittleEndian.Uint64(header[56:]); gdOffset == vmdkGDAtEnd {
+		// The footer has the same layout as the header, with the
+		// grain directory's offset populated.
+		if fileSize < vmdkFooterOffsetFromEnd {
+			return nil, errors.New("vmdk footer not found")
+		}
+		if err := readFull(r, header, fileSize-vmdkFooterOffsetFromEnd); err != nil {
+			return nil, fmt.Errorf("failed to read vmdk footer: %w", err)
+		}
+		if !bytes.HasPrefix(header, []byte(vmdkSparseMagic)) {
+			return nil, errors.New("vmdk footer not found")
+		}
+	}
+
+	flags := binary.LittleEndian.Uint32(header[8:])
+	capacity := binary.LittleEndian.Uint64(header[12:])
+	grainSize := binary.LittleEndian.Uint64(header[20:])
+	gtesPerGT := binary.LittleEndian.Uint32(header[44:])
+	gdOffset := binary.LittleEndian.Uint64(header[56:])
+	compressAlgorithm := binary.LittleEndian.Uint16(header[77:])
+	if grainSize == 0 || grainSize&(grainSize-1) != 0 || gtesPerGT == 0 {
+		return nil, fmt.Errorf("vmdk grain size %d or grain table size %d is invalid", grainSize, gtesPerGT)
+	}
+	img := &vmdkImage{
+		r:          r,
+		size:       int64(capacity) * sectorSize,
+		grainSize:  int64(grainSize) * sectorSize,
+		gtesPerGT:  int64(gtesPerGT),
+		compressed: flags&vmdkFlagCompressed != 0,
+		gtCache:    map[uint32][]uint32{},
+	}
+	if img.compressed && compressAlgorithm != vmdkCompressionDeflate {
+		return nil, fmt.Errorf("vmdk compression algorithm %d is not supported", compressAlgorithm)
+	}
+
+	grains := (int64(capacity) + int64(grainSize) - 1) / int64(grainSize)
+	gdEntries := (grains + img.gtesPerGT - 1) / img.gtesPerGT
+	gd := make([]byte, gdEntries*4)
+	if err := readFull(r, gd, int64(gdOffset)*sectorSize); err != nil {
+		return nil, fmt.Errorf("failed to read vmdk grain directory: %w", err)
+	}
+	img.grainDirectory = make([]uint32, gdEntries)
+	for i := range img.grainDirectory {
+		img.grainDirectory[i] = binary.LittleEndian.Uint32(gd[i*4:])
+	}
+	return img, nil
+}
+
+func (img *vmdkImage) Size() int64 {
+	return img.size
+}
+
+func (img *vmdkImage) Format() string {
+	return "vmdk"
+}
+
+func (img *vmdkImage) ReadAt(p []byte, off int64) (int, error) {
+	return readChunked(p, off, img.size, img.grainSize, img.readGrain)
+}
+
+func (img *vmdkImage) readGrain(p []byte, index int64, offset int64) error {
+	gtSector := img.grainDirectory[index/img.gtesPerGT]
+	if gtSector == 0 {
+		zero(p)
+		return nil
+	}
+	gt, err := img.grainTable(gtSector)
+	if err != nil {
+		return err
+	}
+	grainSector := gt[index%img.gtesPerGT]
+	if grainSector == 0 || grainSector == vmdkZeroGrain {
+		zero(p)
+		return nil
+	}
+	if !img.compressed {
+		return readFull(img.r, p, int64(grainSector)*sectorSize+offset)
+	}
+	grain, err := img.compressedGrain(grainSector)
+	if err != nil {
+		return err
+	}
+	copy(p, grain[offset:])
+	return nil
+}
+
+func (img *vmdkImage) grainTable(sector uint32) ([]uint32, error) {
+	img.mu.Lock()
+	defer img.mu.Unlock()
+	if gt, found := img.gtCache[sector]; found {
+		return gt, nil
+	}
+	buf := make([]byte, img.gtesPerGT*4)
+	if err := readFull(img.r, buf, int64(sector)*sectorSize); err != nil {
+		return nil, fmt.Errorf("failed to read vmdk grain table: %w", err)
+	}
+	gt := make([]uint32, img.gtesPerGT)
+	for i := range gt {
+		gt[i] = binary.LittleEndian.Uint32(buf[i*4:])
+	}
+	img.gtCache[sector] = gt
+	return gt, nil
+}
+
+// compressedGrain returns the decompressed content of the grain at sector.
+// A compressed grain is prefixed with its logical block address and size.
+func (img *vmdkImage) compressedGrain(sector uint32) ([]byte, error) {
+	img.mu.Lock()
+	defer img.mu.Unlock()
+	if img.lastData != nil && img.lastGrain == sector {
+		return img.lastData, nil
+	}
+	offset := int64(sector) * sectorSize
+	prefix := make([]byte, 12)
+	if err := readFull(img.r, prefix, offset); err != nil {
+		return nil, fmt.Errorf("failed to read vmdk grain: %w", err)
+	}
+	compressed := make([]byte, binary.LittleEndian.Uint32(prefix[8:]))
+	if err := readFull(img.r, compressed, offset+int64(len(prefix))); err != nil {
+		return nil, fmt.Errorf("failed to read vmdk grain: %w", err)
+	}
+	decompressor, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress vmdk grain: %w", err)
+	}
+	defer decompressor.Close()
+	grain := make([]byte, img.grainSize)
+	// The last grain of a disk may be partially filled.
+	if _, err := io.ReadFull(decompressor, grain); err != nil && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("failed to decompress vmdk grain: %w", err)
+	}
+	img.lastGrain, img.lastData = sector, grain
+	return grain, nil
+}
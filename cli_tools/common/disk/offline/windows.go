@@ -0,0 +1,181 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package offline
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/proto/go/pb"
+)
+
+const (
+	windowsSoftwareHive = "/Windows/System32/config/SOFTWARE"
+	windowsKernel       = "/Windows/System32/ntoskrnl.exe"
+	windowsVersionKey   = `Microsoft\Windows NT\CurrentVersion`
+	windowsDriversDir   = "/Windows/System32/drivers"
+
+	peMachineI386  = 0x14c
+	peMachineAMD64 = 0x8664
+)
+
+// Drivers for GCE's paravirtualized devices, excluding the .sys suffix.
+var windowsVirtioDrivers = []string{"gvnic", "netkvm", "vioscsi", "viostor"}
+
+type ntVersion struct {
+	major, minor uint32
+}
+
+// Mappings of NT version to marketing versions.
+// Source: https://wikipedia.org/wiki/List_of_Microsoft_Windows_versions
+var (
+	windowsServerVersions = map[ntVersion][2]string{
+		{6, 0}: {"2008", ""},
+		{6, 1}: {"2008", "r2"},
+		{6, 2}: {"2012", ""},
+		{6, 3}: {"2012", "r2"},
+		// 10.0 is resolved using the product name, since it's used for
+		// both Windows 2016 and Windows 2019.
+	}
+	windowsClientVersions = map[ntVersion][2]string{
+		{6, 0}:  {"Vista", ""},
+		{6, 1}:  {"7", ""},
+		{6, 2}:  {"8", ""},
+		{6, 3}:  {"8", "1"},
+		{10, 0}: {"10", ""},
+	}
+)
+
+// inspectWindows returns the version of Windows installed on fs, or nil
+// if Windows isn't found. The version is read from the SOFTWARE registry hive.
+func inspectWindows(fs Filesystem) (*pb.OsRelease, error) {
+	hiveFile, err := fs.Open(windowsSoftwareHive)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	hive, err := openRegistryHive(hiveFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hive.key(windowsVersionKey)
+	if err != nil {
+		return nil, err
+	}
+	version, err := readNTVersion(key)
+	if err != nil {
+		return nil, err
+	}
+	productName, _ := key.stringValue("ProductName")
+	// InstallationType was added in Windows 2008 R2 and 7.
+	variant, err := key.stringValue("InstallationType")
+	if err != nil {
+		variant = productName
+	}
+
+	var marketing [2]string
+	var found bool
+	switch {
+	case strings.Contains(strings.ToLower(variant), "client"):
+		marketing, found = windowsClientVersions[version]
+	case strings.Contains(strings.ToLower(variant), "server"):
+		marketing, found = windowsServerVersions[version]
+		if version == (ntVersion{10, 0}) {
+			for _, year := range []string{"2016", "2019"} {
+				if strings.Contains(productName, year) {
+					marketing, found = [2]string{year, ""}, true
+				}
+			}
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+	arch, err := windowsArchitecture(fs)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.OsRelease{
+		MajorVersion: marketing[0],
+		MinorVersion: marketing[1],
+		DistroId:     pb.Distro_WINDOWS,
+		Architecture: arch,
+	}, nil
+}
+
+// readNTVersion reads the NT version. Starting with NT 10.0, CurrentVersion
+// is fixed at 6.3, and the actual version is recorded in separate values.
+func readNTVersion(key *registryKey) (ntVersion, error) {
+	major, majorErr := key.dwordValue("CurrentMajorVersionNumber")
+	minor, minorErr := key.dwordValue("CurrentMinorVersionNumber")
+	if majorErr == nil && minorErr == nil {
+		return ntVersion{major, minor}, nil
+	}
+	current, err := key.stringValue("CurrentVersion")
+	if err != nil {
+		return ntVersion{}, err
+	}
+	var version ntVersion
+	if _, err := fmt.Sscanf(current, "%d.%d", &version.major, &version.minor); err != nil {
+		return ntVersion{}, fmt.Errorf("CurrentVersion %q is invalid", current)
+	}
+	return version, nil
+}
+
+// windowsArchitecture determines the architecture using the PE header of
+// the kernel.
+func windowsArchitecture(fs Filesystem) (pb.Architecture, error) {
+	f, err := fs.Open(windowsKernel)
+	if errors.Is(err, os.ErrNotExist) {
+		return pb.Architecture_ARCHITECTURE_UNKNOWN, nil
+	}
+	if err != nil {
+		return pb.Architecture_ARCHITECTURE_UNKNOWN, err
+	}
+	dosHeader := make([]byte, 64)
+	if err := readFull(f, dosHeader, 0); err != nil || string(dosHeader[:2]) != "MZ" {
+		return pb.Architecture_ARCHITECTURE_UNKNOWN, nil
+	}
+	peHeader := make([]byte, 6)
+	if err := readFull(f, peHeader, int64(binary.LittleEndian.Uint32(dosHeader[0x3c:]))); err != nil ||
+		string(peHeader[:4]) != "PE\x00\x00" {
+		return pb.Architecture_ARCHITECTURE_UNKNOWN, nil
+	}
+	switch binary.LittleEndian.Uint16(peHeader[4:]) {
+	case peMachineI386:
+		return pb.Architecture_X86, nil
+	case peMachineAMD64:
+		return pb.Architecture_X64, nil
+	}
+	return pb.Architecture_ARCHITECTURE_UNKNOWN, nil
+}
+
+// windowsInstalledDrivers returns the drivers in windowsVirtioDrivers
+// that are installed on fs.
+func windowsInstalledDrivers(fs Filesystem) []string {
+	var drivers []string
+	for _, driver := range windowsVirtioDrivers {
+		if fs.IsFile(path.Join(windowsDriversDir, driver+".sys")) {
+			drivers = append(drivers, driver)
+		}
+	}
+	return drivers
+}
@@ -0,0 +1,135 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package offline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/proto/go/pb"
+)
+
+func TestInspectWindows(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		values   []testRegistryValue
+		machine  uint16
+		expected *pb.OsRelease
+	}{
+		{
+			name: "windows 2019",
+			values: []testRegistryValue{
+				stringRegistryValue("CurrentVersion", "6.3"),
+				dwordRegistryValue("CurrentMajorVersionNumber", 10),
+				dwordRegistryValue("CurrentMinorVersionNumber", 0),
+				stringRegistryValue("InstallationType", "Server"),
+				stringRegistryValue("ProductName", "Windows Server 2019 Datacenter"),
+			},
+			machine:  peMachineAMD64,
+			expected: &pb.OsRelease{MajorVersion: "2019", DistroId: pb.Distro_WINDOWS, Architecture: pb.Architecture_X64},
+		},
+		{
+			name: "windows 2012 r2 core",
+			values: []testRegistryValue{
+				stringRegistryValue("CurrentVersion", "6.3"),
+				stringRegistryValue("InstallationType", "Server Core"),
+				stringRegistryValue("ProductName", "Windows Server 2012 R2 Standard"),
+			},
+			machine:  peMachineAMD64,
+			expected: &pb.OsRelease{MajorVersion: "2012", MinorVersion: "r2", DistroId: pb.Distro_WINDOWS, Architecture: pb.Architecture_X64},
+		},
+		{
+			name: "windows 2008 without InstallationType",
+			values: []testRegistryValue{
+				stringRegistryValue("CurrentVersion", "6.0"),
+				stringRegistryValue("ProductName", "Windows Server (R) 2008 Standard"),
+			},
+			machine:  peMachineI386,
+			expected: &pb.OsRelease{MajorVersion: "2008", DistroId: pb.Distro_WINDOWS, Architecture: pb.Architecture_X86},
+		},
+		{
+			name: "windows 8.1",
+			values: []testRegistryValue{
+				stringRegistryValue("CurrentVersion", "6.3"),
+				stringRegistryValue("InstallationType", "Client"),
+				stringRegistryValue("ProductName", "Windows 8.1 Pro"),
+			},
+			machine:  peMachineAMD64,
+			expected: &pb.OsRelease{MajorVersion: "8", MinorVersion: "1", DistroId: pb.Distro_WINDOWS, Architecture: pb.Architecture_X64},
+		},
+		{
+			name: "unsupported version",
+			values: []testRegistryValue{
+				stringRegistryValue("CurrentVersion", "5.2"),
+				stringRegistryValue("ProductName", "Windows Server 2003"),
+			},
+			machine: peMachineI386,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := mapFS{
+				windowsSoftwareHive: string(newTestHive(&testRegistryKey{subkeys: []*testRegistryKey{
+					{name: "Microsoft", subkeys: []*testRegistryKey{
+						{name: "Windows NT", subkeys: []*testRegistryKey{
+							{name: "CurrentVersion", values: tt.values},
+						}},
+					}},
+				}})),
+				windowsKernel: newTestPE(tt.machine),
+			}
+			actual, err := inspectWindows(fs)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestInspectWindows_NotWindows(t *testing.T) {
+	actual, err := inspectWindows(mapFS{"/etc/os-release": "ID=ubuntu\n"})
+	assert.NoError(t, err)
+	assert.Nil(t, actual)
+}
+
+func TestInspectOS_WindowsDrivers(t *testing.T) {
+	image, hive := newTestNtfs()
+	fs, err := OpenFilesystem(bytes.NewReader(image), int64(len(image)))
+	assert.NoError(t, err)
+	guest, err := inspectOS(fs)
+	assert.NoError(t, err)
+	assert.Empty(t, guest.virtioDrivers)
+
+	guest, err = inspectOS(mapFS{
+		windowsSoftwareHive:                      string(hive),
+		"/Windows/System32/drivers/netkvm.sys":   "",
+		"/Windows/System32/drivers/vioscsi.sys":  "",
+		"/Windows/System32/drivers/storport.sys": "",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"netkvm", "vioscsi"}, guest.virtioDrivers)
+	assert.Empty(t, guest.kernelVersions)
+}
+
+// newTestPE returns the headers of a PE executable for machine.
+func newTestPE(machine uint16) string {
+	pe := make([]byte, 0x86)
+	copy(pe, "MZ")
+	binary.LittleEndian.PutUint32(pe[0x3c:], 0x80)
+	copy(pe[0x80:], "PE\x00\x00")
+	binary.LittleEndian.PutUint16(pe[0x84:], machine)
+	return string(pe)
+}
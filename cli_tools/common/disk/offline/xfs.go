@@ -0,0 +1,440 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package offline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// The XFS on-disk format is documented in the "XFS Algorithms & Data
+// Structures" guide at https://xfs.wiki.kernel.org.
+const (
+	xfsMagic = "XFSB"
+
+	xfsVersionMask = 0xf
+	xfsVersion5    = 5
+
+	xfsFeatures2Ftype  = 0x200
+	xfsIncompatFtype   = 0x1
+	xfsIncompatNrext64 = 0x20
+
+	xfsInodeMagic    = "IN"
+	xfsInodeCoreV2   = 100
+	xfsInodeCoreV3   = 176
+	xfsFormatLocal   = 1
+	xfsFormatExtents = 2
+	xfsFormatBtree   = 3
+
+	xfsBmapMagicV4      = "BMAP"
+	xfsBmapMagicV5      = "BMA3"
+	xfsBmapHeaderV4     = 24
+	xfsBmapHeaderV5     = 72
+	xfsMaxBtreeLevels   = 9
+	xfsExtentRecordSize = 16
+
+	xfsDirBlockMagicV4 = "XD2B"
+	xfsDirBlockMagicV5 = "XDB3"
+	xfsDirDataMagicV4  = "XD2D"
+	xfsDirDataMagicV5  = "XDD3"
+	xfsDirHeaderV4     = 16
+	xfsDirHeaderV5     = 64
+	xfsDirFreeTag      = 0xffff
+	// Directory blocks at and after this offset hold the hash index,
+	// rather than entries.
+	xfsDirLeafOffset = 32 << 30
+
+	xfsSymlinkMagic    = "XSLM"
+	xfsSymlinkHeaderV5 = 56
+)
+
+// xfsFS reads XFS filesystems, version 4 and 5.
+type xfsFS struct {
+	r          io.ReaderAt
+	blockSize  int64
+	dirBlock   int64
+	agBlocks   int64
+	agBlockLog uint
+	inodeSize  int64
+	inoPBLog   uint
+	rootIno    uint64
+	v5         bool
+	ftype      bool
+	nrext64    bool
+}
+
+// xfsInode is an inode, with its data fork.
+type xfsInode struct {
+	number   uint64
+	mode     uint16
+	format   uint8
+	size     int64
+	extents  uint64
+	dataFork []byte
+}
+
+func (i *xfsInode) kind() nodeKind {
+	switch i.mode & modeTypeMask {
+	case modeDir:
+		return dirNode
+	case modeFile:
+		return fileNode
+	case modeSymlink:
+		return symlinkNode
+	}
+	return otherNode
+}
+
+func newXfsFS(r io.ReaderAt) (*xfsFS, error) {
+	sb := make([]byte, 264)
+	if err := readFull(r, sb, 0); err != nil {
+		return nil, fmt.Errorf("failed to read xfs superblock: %w", err)
+	}
+	fs := &xfsFS{
+		r:          r,
+		blockSize:  int64(binary.BigEndian.Uint32(sb[4:])),
+		rootIno:    binary.BigEndian.Uint64(sb[56:]),
+		agBlocks:   int64(binary.BigEndian.Uint32(sb[84:])),
+		inodeSize:  int64(binary.BigEndian.Uint16(sb[104:])),
+		inoPBLog:   uint(sb[123]),
+		agBlockLog: uint(sb[124]),
+		v5:         binary.BigEndian.Uint16(sb[100:])&xfsVersionMask == xfsVersion5,
+	}
+	fs.dirBlock = fs.blockSize << sb[192]
+	if fs.v5 {
+		incompat := binary.BigEndian.Uint32(sb[216:])
+		fs.ftype = incompat&xfsIncompatFtype != 0
+		fs.nrext64 = incompat&xfsIncompatNrext64 != 0
+	} else {
+		fs.ftype = binary.BigEndian.Uint32(sb[200:])&xfsFeatures2Ftype != 0
+	}
+	if fs.blockSize < 512 || (fs.v5 && fs.inodeSize < 512) || fs.inodeSize < 256 || fs.agBlocks == 0 {
+		return nil, errors.New("xfs superblock is invalid")
+	}
+	return fs, nil
+}
+
+func (fs *xfsFS) fsType() string {
+	return "xfs"
+}
+
+func (fs *xfsFS) root() (node, error) {
+	return fs.inode(fs.rootIno)
+}
+
+// blockOffset converts a filesystem block number, which encodes the
+// allocation group in its high bits, to a byte offset.
+func (fs *xfsFS) blockOffset(fsBlock uint64) int64 {
+	ag := int64(fsBlock >> fs.agBlockLog)
+	agBlock := int64(fsBlock & (1<<fs.agBlockLog - 1))
+	return (ag*fs.agBlocks + agBlock) * fs.blockSize
+}
+
+func (fs *xfsFS) inode(number uint64) (*xfsInode, error) {
+	block := fs.blockOffset(number >> fs.inoPBLog)
+	offset := block + int64(number&(1<<fs.inoPBLog-1))*fs.inodeSize
+	raw := make([]byte, fs.inodeSize)
+	if err := readFull(fs.r, raw, offset); err != nil {
+		return nil, fmt.Errorf("failed to read xfs inode %d: %w", number, err)
+	}
+	if !bytes.HasPrefix(raw, []byte(xfsInodeMagic)) {
+		return nil, fmt.Errorf("xfs inode %d is invalid", number)
+	}
+	coreSize := int64(xfsInodeCoreV2)
+	if raw[4] >= 3 {
+		coreSize = xfsInodeCoreV3
+	}
+	forkSize := fs.inodeSize - coreSize
+	if forkOffset := int64(raw[82]) * 8; forkOffset != 0 && forkOffset < forkSize {
+		forkSize = forkOffset
+	}
+	inode := &xfsInode{
+		number:   number,
+		mode:     binary.BigEndian.Uint16(raw[2:]),
+		format:   raw[5],
+		size:     int64(binary.BigEndian.Uint64(raw[56:])),
+		extents:  uint64(binary.BigEndian.Uint32(raw[76:])),
+		dataFork: raw[coreSize : coreSize+forkSize],
+	}
+	if fs.nrext64 && raw[4] >= 3 {
+		inode.extents = binary.BigEndian.Uint64(raw[24:])
+	}
+	return inode, nil
+}
+
+// extents returns the data fork's extents, in bytes.
+func (fs *xfsFS) extents(inode *xfsInode) ([]extent, error) {
+	switch inode.format {
+	case xfsFormatExtents:
+		if inode.extents*xfsExtentRecordSize > uint64(len(inode.dataFork)) {
+			return nil, fmt.Errorf("xfs inode %d has an invalid extent count", inode.number)
+		}
+		return fs.decodeExtents(inode.dataFork, int(inode.extents)), nil
+	case xfsFormatBtree:
+		// The root of the btree is stored in the inode, and doesn't
+		// have a block header.
+		level := int(binary.BigEndian.Uint16(inode.dataFork))
+		records := int(binary.BigEndian.Uint16(inode.dataFork[2:]))
+		maxRecords := (len(inode.dataFork) - 4) / 16
+		return fs.btreeNode(inode.dataFork[4:], level, records, maxRecords)
+	}
+	return nil, fmt.Errorf("xfs inode %d has unsupported format %d", inode.number, inode.format)
+}
+
+func (fs *xfsFS) btreeNode(data []byte, level int, records int, maxRecords int) ([]extent, error) {
+	if level > xfsMaxBtreeLevels || records > maxRecords {
+		return nil, errors.New("xfs bmap btree is invalid")
+	}
+	if level == 0 {
+		return fs.decodeExtents(data, records), nil
+	}
+	// Keys are followed by pointers, each array sized for maxRecords.
+	var extents []extent
+	for i := 0; i < records; i++ {
+		child := binary.BigEndian.Uint64(data[maxRecords*8+i*8:])
+		block := make([]byte, fs.blockSize)
+		if err := readFull(fs.r, block, fs.blockOffset(child)); err != nil {
+			return nil, fmt.Errorf("failed to read xfs bmap btree: %w", err)
+		}
+		headerSize, magic := xfsBmapHeaderV4, xfsBmapMagicV4
+		if fs.v5 {
+			headerSize, magic = xfsBmapHeaderV5, xfsBmapMagicV5
+		}
+		if !bytes.HasPrefix(block, []byte(magic)) {
+			return nil, errors.New("xfs bmap btree block is invalid")
+		}
+		childExtents, err := fs.btreeNode(block[headerSize:],
+			int(binary.BigEndian.Uint16(block[4:])), int(binary.BigEndian.Uint16(block[6:])),
+			(int(fs.blockSize)-headerSize)/16)
+		if err != nil {
+			return nil, err
+		}
+		extents = append(extents, childExtents...)
+	}
+	return extents, nil
+}
+
+// decodeExtents decodes packed 128-bit extent records.
+func (fs *xfsFS) decodeExtents(data []byte, count int) []extent {
+	var extents []extent
+	for i := 0; i < count; i++ {
+		l0 := binary.BigEndian.Uint64(data[i*xfsExtentRecordSize:])
+		l1 := binary.BigEndian.Uint64(data[i*xfsExtentRecordSize+8:])
+		e := extent{
+			logical:  int64((l0&(1<<63-1))>>9) * fs.blockSize,
+			physical: fs.blockOffset((l0&0x1ff)<<43 | l1>>21),
+			length:   int64(l1&(1<<21-1)) * fs.blockSize,
+		}
+		// The high bit marks unwritten extents, which read as zeros.
+		if l0>>63 != 0 {
+			e.physical = -1
+		}
+		extents = append(extents, e)
+	}
+	return extents
+}
+
+func (fs *xfsFS) lookup(dir node, name string) (node, error) {
+	var found uint64
+	var matched bool
+	err := fs.entries(dir.(*xfsInode), func(entry string, number uint64) bool {
+		if entry == name {
+			found, matched = number, true
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !matched {
+		return nil, os.ErrNotExist
+	}
+	return fs.inode(found)
+}
+
+func (fs *xfsFS) list(dir node) ([]string, error) {
+	var names []string
+	err := fs.entries(dir.(*xfsInode), func(entry string, number uint64) bool {
+		names = append(names, entry)
+		return true
+	})
+	return names, err
+}
+
+// entries calls visit for each entry of a directory, until visit returns false.
+func (fs *xfsFS) entries(inode *xfsInode, visit func(name string, number uint64) bool) error {
+	if inode.format == xfsFormatLocal {
+		return fs.shortformEntries(inode, visit)
+	}
+	extents, err := fs.extents(inode)
+	if err != nil {
+		return err
+	}
+	var dataExtents []extent
+	for _, e := range extents {
+		if e.logical < xfsDirLeafOffset {
+			dataExtents = append(dataExtents, e)
+		}
+	}
+	content := newExtentFile(fs.r, dataExtents, inode.size)
+	block := make([]byte, fs.dirBlock)
+	for offset := int64(0); offset < inode.size; offset += fs.dirBlock {
+		if err := readFull(content, block, offset); err != nil {
+			return fmt.Errorf("failed to read xfs directory %d: %w", inode.number, err)
+		}
+		if !fs.dataBlockEntries(block, visit) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// shortformEntries reads a directory that's stored within its inode.
+func (fs *xfsFS) shortformEntries(inode *xfsInode, visit func(name string, number uint64) bool) error {
+	data := inode.dataFork
+	count := int(data[0])
+	// When any entry requires a 64-bit inode number, all entries use them.
+	inoSize := 4
+	if data[1] > 0 {
+		inoSize = 8
+	}
+	pos := 2 + inoSize
+	for i := 0; i < count; i++ {
+		if pos+3 > len(data) {
+			return errors.New("xfs shortform directory is invalid")
+		}
+		nameLen := int(data[pos])
+		nameEnd := pos + 3 + nameLen
+		inoPos := nameEnd
+		if fs.ftype {
+			inoPos++
+		}
+		if inoPos+inoSize > len(data) {
+			return errors.New("xfs shortform directory is invalid")
+		}
+		number := uint64(binary.BigEndian.Uint32(data[inoPos:]))
+		if inoSize == 8 {
+			number = binary.BigEndian.Uint64(data[inoPos:])
+		}
+		if !visit(string(data[pos+3:nameEnd]), number) {
+			return nil
+		}
+		pos = inoPos + inoSize
+	}
+	return nil
+}
+
+// dataBlockEntries reads the entries of a directory data block. It
+// returns false when visit stops the iteration.
+func (fs *xfsFS) dataBlockEntries(block []byte, visit func(name string, number uint64) bool) bool {
+	end := len(block)
+	pos := xfsDirHeaderV4
+	switch string(block[:4]) {
+	case xfsDirBlockMagicV4, xfsDirBlockMagicV5:
+		// Single-block directories end with their hash index and a tail
+		// recording the number of index entries.
+		leafCount := int(binary.BigEndian.Uint32(block[end-8:]))
+		end -= 8 + leafCount*8
+		if string(block[:4]) == xfsDirBlockMagicV5 {
+			pos = xfsDirHeaderV5
+		}
+	case xfsDirDataMagicV4:
+	case xfsDirDataMagicV5:
+		pos = xfsDirHeaderV5
+	default:
+		return true
+	}
+	for pos+8 < end && end <= len(block) {
+		if binary.BigEndian.Uint16(block[pos:]) == xfsDirFreeTag {
+			length := int(binary.BigEndian.Uint16(block[pos+2:]))
+			if length == 0 {
+				return true
+			}
+			pos += length
+			continue
+		}
+		number := binary.BigEndian.Uint64(block[pos:])
+		nameLen := int(block[pos+8])
+		if pos+9+nameLen > end {
+			return true
+		}
+		if !visit(string(block[pos+9:pos+9+nameLen]), number) {
+			return false
+		}
+		// Entries end with an optional file type, and a two byte tag,
+		// padded to eight bytes.
+		length := 9 + nameLen + 2
+		if fs.ftype {
+			length++
+		}
+		pos += (length + 7) &^ 7
+	}
+	return true
+}
+
+func (fs *xfsFS) readlink(n node) (string, error) {
+	inode := n.(*xfsInode)
+	if inode.format == xfsFormatLocal {
+		if inode.size > int64(len(inode.dataFork)) {
+			return "", fmt.Errorf("xfs symlink %d is invalid", inode.number)
+		}
+		return string(inode.dataFork[:inode.size]), nil
+	}
+	extents, err := fs.extents(inode)
+	if err != nil {
+		return "", err
+	}
+	// Each block of a remote symlink has a header on version 5 filesystems.
+	var target []byte
+	for _, e := range extents {
+		for offset := int64(0); offset < e.length && e.physical >= 0; offset += fs.blockSize {
+			block := make([]byte, fs.blockSize)
+			if err := readFull(fs.r, block, e.physical+offset); err != nil {
+				return "", err
+			}
+			if fs.v5 {
+				if !bytes.HasPrefix(block, []byte(xfsSymlinkMagic)) {
+					return "", fmt.Errorf("xfs symlink %d is invalid", inode.number)
+				}
+				block = block[xfsSymlinkHeaderV5:]
+			}
+			target = append(target, block...)
+		}
+	}
+	if int64(len(target)) < inode.size {
+		return "", fmt.Errorf("xfs symlink %d is invalid", inode.number)
+	}
+	return string(target[:inode.size]), nil
+}
+
+func (fs *xfsFS) open(n node) (File, error) {
+	inode := n.(*xfsInode)
+	if inode.format == xfsFormatLocal {
+		if inode.size > int64(len(inode.dataFork)) {
+			return nil, fmt.Errorf("xfs inode %d is invalid", inode.number)
+		}
+		return newBytesFile(inode.dataFork[:inode.size]), nil
+	}
+	extents, err := fs.extents(inode)
+	if err != nil {
+		return nil, err
+	}
+	return newExtentFile(fs.r, extents, inode.size), nil
+}
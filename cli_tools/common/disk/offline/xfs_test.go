@@ -0,0 +1,216 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package offline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXfs_ReadsFiles(t *testing.T) {
+	fs, err := OpenFilesystem(bytes.NewReader(newTestXfs()), xfsTestBlocks*xfsTestBlockSize)
+	assert.NoError(t, err)
+	assert.Equal(t, "xfs", fs.Type())
+
+	for _, tt := range []struct {
+		path     string
+		expected string
+	}{
+		// Stored in two extents.
+		{"/etc/os-release", xfsTestOsRelease},
+		// Stored in the inode.
+		{"/etc/hostname", "xfs-host\n"},
+		// /bin is a local symlink, and /usr/bin is a directory with two data
+		// blocks. bash's extents are stored in a btree.
+		{"/bin/bash", elfX64},
+		// A remote symlink.
+		{"/etc/release-link", xfsTestOsRelease},
+	} {
+		t.Run(tt.path, func(t *testing.T) {
+			content, err := ReadFile(fs, tt.path)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(content))
+		})
+	}
+
+	assert.True(t, fs.IsDir("/usr/bin"))
+	assert.True(t, fs.IsFile("/usr/bin/file-0"))
+	names, err := fs.ReadDir("/etc")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"hostname", "os-release", "release-link"}, names)
+	names, err = fs.ReadDir("/usr/bin")
+	assert.NoError(t, err)
+	assert.Len(t, names, 151)
+	names, err = fs.ReadDir("/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bin", "etc", "usr"}, names)
+	_, err = fs.Open("/usr/bin/missing")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestXfs_RejectsInvalidSuperblock(t *testing.T) {
+	image := newTestXfs()
+	// Version 5 requires 512 byte inodes.
+	binary.BigEndian.PutUint16(image[104:], 256)
+	_, err := OpenFilesystem(bytes.NewReader(image), int64(len(image)))
+	assert.EqualError(t, err, "xfs superblock is invalid")
+}
+
+const (
+	xfsTestBlockSize = 4096
+	xfsTestBlocks    = 16
+	// Each block holds 8 inodes, so the inodes in block 2 are numbered 16-23.
+	xfsTestRoot = 16
+)
+
+var xfsTestOsRelease = "ID=rhel\nVERSION_ID=\"8.3\"\n" + strings.Repeat("#\n", 2500)
+
+// newTestXfs creates a version 5 filesystem with a single allocation group.
+func newTestXfs() []byte {
+	image := make([]byte, xfsTestBlocks*xfsTestBlockSize)
+	copy(image, xfsMagic)
+	binary.BigEndian.PutUint32(image[4:], xfsTestBlockSize)
+	binary.BigEndian.PutUint64(image[56:], xfsTestRoot)
+	binary.BigEndian.PutUint32(image[84:], xfsTestBlocks)
+	binary.BigEndian.PutUint16(image[100:], xfsVersion5)
+	binary.BigEndian.PutUint16(image[104:], 512)
+	image[123] = 3
+	image[124] = 4
+	binary.BigEndian.PutUint32(image[216:], xfsIncompatFtype)
+
+	// Root directory: etc, bin, and usr.
+	writeXfsInode(image, 16, modeDir, xfsFormatLocal, 0,
+		xfsShortformDir(map[string]uint32{"etc": 17, "bin": 18, "usr": 19}))
+	writeXfsInode(image, 18, modeSymlink, xfsFormatLocal, 0, []byte("usr/bin"))
+	writeXfsInode(image, 19, modeDir, xfsFormatLocal, 0, xfsShortformDir(map[string]uint32{"bin": 22}))
+
+	// /etc is a single-block directory, in block 4.
+	writeXfsInode(image, 17, modeDir, xfsFormatExtents, xfsTestBlockSize, xfsExtents(xfsExtentRecord(0, 4, 1)))
+	writeXfsDirBlock(image[4*xfsTestBlockSize:], xfsDirBlockMagicV5, []xfsTestDirEntry{
+		{"os-release", 20}, {"hostname", 21}, {"release-link", 24},
+	})
+	writeXfsInode(image, 20, modeFile, xfsFormatExtents, int64(len(xfsTestOsRelease)),
+		xfsExtents(xfsExtentRecord(0, 8, 1), xfsExtentRecord(1, 10, 1)))
+	copy(image[8*xfsTestBlockSize:], xfsTestOsRelease[:xfsTestBlockSize])
+	copy(image[10*xfsTestBlockSize:], xfsTestOsRelease[xfsTestBlockSize:])
+	writeXfsInode(image, 21, modeFile, xfsFormatLocal, 0, []byte("xfs-host\n"))
+	target := "../etc/os-release"
+	writeXfsInode(image, 24, modeSymlink, xfsFormatExtents, int64(len(target)), xfsExtents(xfsExtentRecord(0, 13, 1)))
+	copy(image[13*xfsTestBlockSize:], xfsSymlinkMagic)
+	copy(image[13*xfsTestBlockSize+xfsSymlinkHeaderV5:], target)
+
+	// /usr/bin has two data blocks, followed by a leaf block. bash is in the second.
+	var first, second []xfsTestDirEntry
+	for i := 0; i < 150; i++ {
+		first = append(first, xfsTestDirEntry{fmt.Sprintf("file-%d", i), 23})
+	}
+	second = append(second, xfsTestDirEntry{"bash", 23})
+	writeXfsInode(image, 22, modeDir, xfsFormatExtents, 2*xfsTestBlockSize, xfsExtents(
+		xfsExtentRecord(0, 5, 2), xfsExtentRecord(xfsDirLeafOffset/xfsTestBlockSize, 7, 1)))
+	writeXfsDirBlock(image[5*xfsTestBlockSize:], xfsDirDataMagicV5, first)
+	writeXfsDirBlock(image[6*xfsTestBlockSize:], xfsDirDataMagicV5, second)
+	copy(image[7*xfsTestBlockSize:], "garbage")
+
+	// bash uses a btree whose root, in the inode, points to the leaf in block 11.
+	root := make([]byte, 512-xfsInodeCoreV3)
+	maxRecords := (len(root) - 4) / 16
+	binary.BigEndian.PutUint16(root, 1)
+	binary.BigEndian.PutUint16(root[2:], 1)
+	binary.BigEndian.PutUint64(root[4+maxRecords*8:], 11)
+	writeXfsInode(image, 23, modeFile, xfsFormatBtree, int64(len(elfX64)), root)
+	leaf := image[11*xfsTestBlockSize:]
+	copy(leaf, xfsBmapMagicV5)
+	binary.BigEndian.PutUint16(leaf[6:], 1)
+	copy(leaf[xfsBmapHeaderV5:], xfsExtentRecord(0, 12, 1))
+	copy(image[12*xfsTestBlockSize:], elfX64)
+	return image
+}
+
+// writeXfsInode writes a version 3 inode. When size is zero, the size
+// of the data fork is used.
+func writeXfsInode(image []byte, number int, mode uint16, format uint8, size int64, dataFork []byte) {
+	inode := image[number*512:][:512]
+	copy(inode, xfsInodeMagic)
+	binary.BigEndian.PutUint16(inode[2:], mode)
+	inode[4] = 3
+	inode[5] = format
+	if size == 0 {
+		size = int64(len(dataFork))
+	}
+	binary.BigEndian.PutUint64(inode[56:], uint64(size))
+	if format == xfsFormatExtents {
+		binary.BigEndian.PutUint32(inode[76:], uint32(len(dataFork)/xfsExtentRecordSize))
+	}
+	copy(inode[xfsInodeCoreV3:], dataFork)
+}
+
+func xfsShortformDir(entries map[string]uint32) []byte {
+	dir := []byte{byte(len(entries)), 0, 0, 0, 0, xfsTestRoot}
+	for name, ino := range entries {
+		dir = append(dir, byte(len(name)), 0, 0)
+		dir = append(dir, name...)
+		dir = append(dir, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(dir[len(dir)-4:], ino)
+	}
+	return dir
+}
+
+func xfsExtentRecord(logical, start, length uint64) []byte {
+	record := make([]byte, xfsExtentRecordSize)
+	binary.BigEndian.PutUint64(record, logical<<9|start>>43)
+	binary.BigEndian.PutUint64(record[8:], start<<21|length)
+	return record
+}
+
+func xfsExtents(records ...[]byte) []byte {
+	return bytes.Join(records, nil)
+}
+
+type xfsTestDirEntry struct {
+	name string
+	ino  uint64
+}
+
+// writeXfsDirBlock writes a directory data block. Entries are preceded by
+// an unused entry, and single-block directories end with two leaf entries.
+func writeXfsDirBlock(block []byte, magic string, entries []xfsTestDirEntry) {
+	copy(block, magic)
+	pos := xfsDirHeaderV5
+	binary.BigEndian.PutUint16(block[pos:], xfsDirFreeTag)
+	binary.BigEndian.PutUint16(block[pos+2:], 16)
+	pos += 16
+	for _, e := range entries {
+		binary.BigEndian.PutUint64(block[pos:], e.ino)
+		block[pos+8] = byte(len(e.name))
+		copy(block[pos+9:], e.name)
+		pos += (9 + len(e.name) + 3 + 7) &^ 7
+	}
+	end := len(block)
+	if magic == xfsDirBlockMagicV5 {
+		binary.BigEndian.PutUint32(block[len(block)-8:], 2)
+		copy(block[len(block)-24:], "\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff")
+		end -= 24
+	}
+	// The remainder of the data area is unused.
+	binary.BigEndian.PutUint16(block[pos:], xfsDirFreeTag)
+	binary.BigEndian.PutUint16(block[pos+2:], uint16(end-pos))
+}
@@ -0,0 +1,92 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package disk
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/disk/offline"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
+	"github.com/GoogleCloudPlatform/compute-image-tools/proto/go/pb"
+)
+
+// NewOfflineInspector creates an Inspector that reads disk image files
+// directly, without a worker instance. This allows an import to be planned
+// before the file is inflated to a disk.
+//
+// Raw, qcow2, vmdk (hosted sparse and streamOptimized), and fixed-size vpc
+// files are supported. Operating systems are found on ext2/3/4, xfs, and
+// ntfs filesystems that are in MBR or GPT partitions, linear LVM logical
+// volumes, or that span the whole disk. LUKS and BitLocker volumes are
+// reported in EncryptedVolumes, but aren't read.
+func NewOfflineInspector(logger logging.Logger) Inspector {
+	return &offlineInspector{logger: logger}
+}
+
+// offlineInspector implements disk.Inspector using the offline package.
+type offlineInspector struct {
+	logger logging.Logger
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+func (i *offlineInspector) Cancel(reason string) bool {
+	i.logger.Debug(fmt.Sprintf("Canceling inspection with reason: %q", reason))
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.cancel == nil {
+		return false
+	}
+	i.cancel()
+	return true
+}
+
+// Inspect finds partition and boot-related properties for a disk image file,
+// and returns an InspectionResult. `reference` is the path to a local file.
+func (i *offlineInspector) Inspect(reference string) (*pb.InspectionResults, error) {
+	startTime := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	i.mu.Lock()
+	i.cancel = cancel
+	i.mu.Unlock()
+	defer func() {
+		i.mu.Lock()
+		i.cancel = nil
+		i.mu.Unlock()
+		cancel()
+	}()
+
+	results, err := offline.Inspect(ctx, reference)
+	if err != nil {
+		return assembleErrors(reference, results, results.ErrorWhen, err, startTime)
+	}
+	i.logger.Debug(fmt.Sprintf("Detection results: %s", results.String()))
+
+	if err = validate(results); err != nil {
+		return assembleErrors(reference, results, pb.InspectionResults_INTERPRETING_INSPECTION_RESULTS, err, startTime)
+	}
+
+	if err = populate(results, i.logger); err != nil {
+		return assembleErrors(reference, results, pb.InspectionResults_INTERPRETING_INSPECTION_RESULTS, err, startTime)
+	}
+
+	results.ElapsedTimeMs = time.Now().Sub(startTime).Milliseconds()
+	i.logger.Metric(&pb.OutputInfo{InspectionResults: results})
+	return results, nil
+}
@@ -0,0 +1,75 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package disk
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
+	"github.com/GoogleCloudPlatform/compute-image-tools/proto/go/pb"
+)
+
+func TestOfflineInspector_Inspect(t *testing.T) {
+	// A whole-disk ext4 filesystem, without a partition table.
+	compressed, err := os.Open("offline/testdata/ubuntu-ext4.img.gz")
+	assert.NoError(t, err)
+	defer compressed.Close()
+	r, err := gzip.NewReader(compressed)
+	assert.NoError(t, err)
+	f, err := ioutil.TempFile("", "disk")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = io.Copy(f, r)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	logger := logging.NewToolLogger(t.Name())
+	actual, err := NewOfflineInspector(logger).Inspect(f.Name())
+	assert.NoError(t, err)
+	expected := &pb.InspectionResults{
+		OsRelease: &pb.OsRelease{
+			CliFormatted: "ubuntu-2004",
+			Distro:       "ubuntu",
+			MajorVersion: "20",
+			MinorVersion: "04",
+			Architecture: pb.Architecture_X64,
+			DistroId:     pb.Distro_UBUNTU,
+		},
+		OsCount: 1,
+		RootFs:  "ext4",
+	}
+	actual.ElapsedTimeMs = 0
+	if diff := cmp.Diff(expected, actual, protocmp.Transform()); diff != "" {
+		t.Errorf("unexpected difference:\n%v", diff)
+	}
+}
+
+func TestOfflineInspector_Inspect_ReportsErrors(t *testing.T) {
+	actual, err := NewOfflineInspector(logging.NewToolLogger(t.Name())).Inspect("/does/not/exist.vmdk")
+	assert.Error(t, err)
+	assert.Equal(t, pb.InspectionResults_MOUNTING_GUEST, actual.ErrorWhen)
+}
+
+func TestOfflineInspector_Cancel_ReturnsFalseWhenIdle(t *testing.T) {
+	assert.False(t, NewOfflineInspector(logging.NewToolLogger(t.Name())).Cancel("reason"))
+}
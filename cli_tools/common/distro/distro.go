@@ -25,17 +25,22 @@ import (
 )
 
 const (
-	centos   = "centos"
-	debian   = "debian"
-	opensuse = "opensuse"
-	rhel     = "rhel"
-	sles     = "sles"
-	slesSAP  = "sles-sap"
-	ubuntu   = "ubuntu"
-	windows  = "windows"
-
-	archX86 = "x86"
-	archX64 = "x64"
+	almaLinux = "almalinux"
+	amazon    = "amazon"
+	centos    = "centos"
+	debian    = "debian"
+	opensuse  = "opensuse"
+	oracle    = "oracle"
+	rhel      = "rhel"
+	rocky     = "rocky"
+	sles      = "sles"
+	slesSAP   = "sles-sap"
+	ubuntu    = "ubuntu"
+	windows   = "windows"
+
+	archX86   = "x86"
+	archX64   = "x64"
+	archARM64 = "arm64"
 
 	r2 = "r2"
 )
@@ -48,8 +53,8 @@ var osFlagExpression = regexp.MustCompile(
 		// Version is required, and is at least one word character.
 		// Examples: 2004, 2008, 2008r2
 		"-(?P<version>[a-z0-9]+)" +
-		// Architecture is optional. The only options are `x86` and `x64`.
-		"(?:-(?P<arch>x86|x64))?" +
+		// Architecture is optional. The only options are `x86`, `x64`, and `arm64`.
+		"(?:-(?P<arch>x86|x64|arm64))?" +
 		// License is optional. The only value is `byol`.
 		"(?:-(?P<license>byol))?$")
 
@@ -60,10 +65,13 @@ var standardArchitectures = []struct {
 }{
 	{
 		archX86,
-		[]string{"amd64", "x86_64"},
+		[]string{"i386", "i686", "x86_32"},
 	}, {
 		archX64,
-		[]string{"i386", "i686", "x86_32"},
+		[]string{"amd64", "x86_64"},
+	}, {
+		archARM64,
+		[]string{"aarch64", "arm64v8"},
 	},
 }
 
@@ -88,6 +96,10 @@ type Release interface {
 	// showing to users in a help message. Notably, it lacks support for
 	// the -byol suffix, as that is not currently modeled.
 	AsGcloudArg() string
+
+	// Architecture returns the standardized CPU architecture of the release,
+	// such as x64 or arm64. It's empty when the architecture isn't known.
+	Architecture() string
 }
 
 // FromComponents interprets the (distro, major, minor) tuple and returns
@@ -108,7 +120,7 @@ func FromComponents(distro string, major string, minor string, architecture stri
 		return newWindowsRelease(major, minor, standardArch)
 	}
 
-	return newLinuxRelease(standardDistro, major, minor)
+	return newLinuxRelease(standardDistro, major, minor, standardArch)
 }
 
 // standardizeArchitecture maps a raw string to a known architecture. It's not an error to
@@ -139,7 +151,8 @@ func standardizeDistro(distro string) (string, error) {
 		return "", errors.New("distro name required")
 	}
 	d := strings.ReplaceAll(strings.ToLower(distro), "_", "-")
-	for _, known := range []string{centos, debian, opensuse, rhel, slesSAP, sles, ubuntu, windows} {
+	for _, known := range []string{
+		almaLinux, amazon, centos, debian, opensuse, oracle, rhel, rocky, slesSAP, sles, ubuntu, windows} {
 		if strings.Contains(d, known) {
 			return known, nil
 		}
@@ -147,7 +160,7 @@ func standardizeDistro(distro string) (string, error) {
 	return "", fmt.Errorf("Unrecognized distro `%s`", distro)
 }
 
-func newLinuxRelease(distro string, major string, minor string) (Release, error) {
+func newLinuxRelease(distro string, major string, minor string, architecture string) (Release, error) {
 	majorInt, e := strconv.Atoi(major)
 	if e != nil || majorInt < 1 {
 		return nil, fmt.Errorf(
@@ -165,19 +178,27 @@ func newLinuxRelease(distro string, major string, minor string) (Release, error)
 	}
 	switch distro {
 	case ubuntu:
-		return newUbuntuRelease(majorInt, minorInt)
+		return newUbuntuRelease(majorInt, minorInt, architecture)
+	case almaLinux:
+		fallthrough
+	case amazon:
+		fallthrough
 	case centos:
 		fallthrough
 	case debian:
 		fallthrough
 	case opensuse:
 		fallthrough
+	case oracle:
+		fallthrough
 	case rhel:
-		return newCommonLinuxRelease(distro, majorInt, minorInt)
+		fallthrough
+	case rocky:
+		return newCommonLinuxRelease(distro, majorInt, minorInt, architecture)
 	case sles:
 		fallthrough
 	case slesSAP:
-		return newSLESRelease(distro, majorInt, minorInt)
+		return newSLESRelease(distro, majorInt, minorInt, architecture)
 	default:
 		return nil, fmt.Errorf("Unrecognized distro `%s`", distro)
 	}
@@ -236,41 +257,64 @@ func FromGcloudOSArgument(osFlagValue string) (r Release, e error) {
 //  2. Compatibility is determined by the major version.
 //  3. There are no variants.
 type commonLinuxRelease struct {
-	distro string
-	major  int
-	minor  int
+	distro       string
+	major        int
+	minor        int
+	architecture string
 }
 
 func (r commonLinuxRelease) AsGcloudArg() string {
-	return fmt.Sprintf("%s-%d", r.distro, r.major)
+	return fmt.Sprintf("%s-%d", r.distro, r.major) + linuxArchSuffix(r.architecture)
 }
 
 func (r commonLinuxRelease) ImportCompatible(other Release) bool {
 	realOther, ok := other.(commonLinuxRelease)
 	return ok &&
 		r.distro == realOther.distro &&
-		r.major == realOther.major
+		r.major == realOther.major &&
+		linuxArchCompatible(r.architecture, realOther.architecture)
+}
+
+func (r commonLinuxRelease) Architecture() string {
+	return r.architecture
 }
 
 func commonLinuxDistros() []string {
-	return []string{centos, debian, opensuse, rhel}
+	return []string{almaLinux, amazon, centos, debian, opensuse, oracle, rhel, rocky}
 }
 
 // The caller is responsible for verifying the syntax of the arguments.
 // Verify the following before calling:
 //   - distro is one of the distros returned by commonLinuxDistros().
 //   - major is >= 1 and minor is >= 0
-func newCommonLinuxRelease(distro string, major, minor int) (Release, error) {
+func newCommonLinuxRelease(distro string, major, minor int, architecture string) (Release, error) {
 	assert.GreaterThanOrEqualTo(major, 1)
 	assert.GreaterThanOrEqualTo(minor, 0)
 	assert.Contains(distro, commonLinuxDistros())
 	return commonLinuxRelease{
-		distro: distro,
-		major:  major,
-		minor:  minor,
+		distro:       distro,
+		major:        major,
+		minor:        minor,
+		architecture: architecture,
 	}, nil
 }
 
+// linuxArchSuffix returns the suffix that's added to the `--os` flag of a
+// Linux release. Only arm64 is included, since x86 is assumed for Linux.
+func linuxArchSuffix(architecture string) string {
+	if architecture == archARM64 {
+		return "-" + archARM64
+	}
+	return ""
+}
+
+// linuxArchCompatible returns whether two Linux releases have compatible
+// architectures. The 32 and 64 bit variants of x86 are treated as compatible,
+// since they're translated with the same workflows.
+func linuxArchCompatible(a, b string) bool {
+	return (a == archARM64) == (b == archARM64)
+}
+
 // windowsRelease uses marketing versions rather than NT versions.
 // Currently the only minor version is "r2". For example, the versions
 // 2012 and 2012r2 and *not* import compatible, and have different
@@ -309,10 +353,17 @@ func (w windowsRelease) AsGcloudArg() string {
 	return arg
 }
 
+func (w windowsRelease) Architecture() string {
+	return w.architecture
+}
+
 func newWindowsRelease(major string, minor string, architecture string) (Release, error) {
 	if !regexp.MustCompile("^\\d+$").MatchString(major) {
 		return nil, fmt.Errorf("`%s` is not a valid major version for Windows", major)
 	}
+	if architecture == archARM64 {
+		return nil, fmt.Errorf("Windows on `%s` is not importable", architecture)
+	}
 	return windowsRelease{major, minor, architecture}, nil
 }
 
@@ -347,9 +398,10 @@ func WindowsServerVersionforNTVersion(major string, minor string) (marketingMajo
 // slesRelease is a Release that represents the SLES distro and its variants (such as SLES for SAP).
 // Compatibility requires the same variant and major version.
 type slesRelease struct {
-	variant string
-	major   int
-	minor   int
+	variant      string
+	major        int
+	minor        int
+	architecture string
 }
 
 // The caller is responsible for verifying the syntax of the arguments.
@@ -360,7 +412,7 @@ type slesRelease struct {
 // A non-nil error is returned if the syntax is correct, but the
 // arguments do not follow SLES's naming system. Specifically:
 //   - variant may not have a hyphen in its name
-func newSLESRelease(distroAndVariant string, major, minor int) (Release, error) {
+func newSLESRelease(distroAndVariant string, major, minor int, architecture string) (Release, error) {
 	assert.GreaterThanOrEqualTo(major, 1)
 	assert.GreaterThanOrEqualTo(minor, 0)
 	var variant string
@@ -372,28 +424,34 @@ func newSLESRelease(distroAndVariant string, major, minor int) (Release, error)
 	default:
 		panic(fmt.Sprintf("%q is not valid for SLES", distroAndVariant))
 	}
-	return slesRelease{variant, major, minor}, nil
+	return slesRelease{variant, major, minor, architecture}, nil
 }
 
 func (r slesRelease) ImportCompatible(other Release) bool {
 	actualOther, ok := other.(slesRelease)
 	return ok &&
 		r.variant == actualOther.variant &&
-		r.major == actualOther.major
+		r.major == actualOther.major &&
+		linuxArchCompatible(r.architecture, actualOther.architecture)
 }
 
 func (r slesRelease) AsGcloudArg() string {
 	if r.variant != "" {
-		return fmt.Sprintf("sles-%s-%d", r.variant, r.major)
+		return fmt.Sprintf("sles-%s-%d", r.variant, r.major) + linuxArchSuffix(r.architecture)
 	}
-	return fmt.Sprintf("sles-%d", r.major)
+	return fmt.Sprintf("sles-%d", r.major) + linuxArchSuffix(r.architecture)
+}
+
+func (r slesRelease) Architecture() string {
+	return r.architecture
 }
 
 // ubuntuRelease is a Release that represents Ubuntu.
 // Compatibility requires the same major and minor versions.
 type ubuntuRelease struct {
-	major int
-	minor int
+	major        int
+	minor        int
+	architecture string
 }
 
 // The caller is responsible for verifying the syntax of the arguments.
@@ -403,11 +461,11 @@ type ubuntuRelease struct {
 // A non-nil error is returned if the syntax is correct, but the
 // arguments do not follow Ubuntu's naming system. Specifically:
 //   - minor version must be 4 or 10
-func newUbuntuRelease(major, minor int) (Release, error) {
+func newUbuntuRelease(major, minor int, architecture string) (Release, error) {
 	assert.GreaterThanOrEqualTo(major, 1)
 	assert.GreaterThanOrEqualTo(minor, 0)
 	if minor == 4 || minor == 10 {
-		return ubuntuRelease{major, minor}, nil
+		return ubuntuRelease{major, minor, architecture}, nil
 	}
 	return nil, fmt.Errorf("Ubuntu version `%d.%d` is not importable", major, minor)
 }
@@ -416,9 +474,14 @@ func (u ubuntuRelease) ImportCompatible(other Release) bool {
 	actualOther, ok := other.(ubuntuRelease)
 	return ok &&
 		u.major == actualOther.major &&
-		u.minor == actualOther.minor
+		u.minor == actualOther.minor &&
+		linuxArchCompatible(u.architecture, actualOther.architecture)
 }
 
 func (u ubuntuRelease) AsGcloudArg() string {
-	return fmt.Sprintf("ubuntu-%d%02d", u.major, u.minor)
+	return fmt.Sprintf("ubuntu-%d%02d", u.major, u.minor) + linuxArchSuffix(u.architecture)
+}
+
+func (u ubuntuRelease) Architecture() string {
+	return u.architecture
 }
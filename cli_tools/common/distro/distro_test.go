@@ -136,6 +136,10 @@ func TestDistroFromComponents_HappyCasesLinux(t *testing.T) {
 		{"rhel", "8", "2", "rhel-8"},
 		{"ubuntu", "14", "04", "ubuntu-1404"},
 		{"ubuntu", "14", "10", "ubuntu-1410"},
+		{"rocky", "8", "4", "rocky-8"},
+		{"almalinux", "8", "5", "almalinux-8"},
+		{"oracle", "7", "9", "oracle-7"},
+		{"amazon", "2", "", "amazon-2"},
 	}
 	for _, tt := range cases {
 		t.Run(fmt.Sprintf("%s-%s-%s", tt.distro, tt.major, tt.minor), func(t *testing.T) {
@@ -231,6 +235,10 @@ func TestDistroFromComponents_ArchitectureValidation(t *testing.T) {
 		{inputArch: "i686", expectedArch: "x86"},
 		{inputArch: "x86_32", expectedArch: "x86"},
 
+		{inputArch: "arm64", expectedArch: "arm64"},
+		{inputArch: "ARM64", expectedArch: "arm64"},
+		{inputArch: "aarch64", expectedArch: "arm64"},
+
 		{inputArch: "", expectedArch: ""},
 		{inputArch: "mips", expectErrorToContain: "Unrecognized architecture `mips`"},
 	}
@@ -240,6 +248,7 @@ func TestDistroFromComponents_ArchitectureValidation(t *testing.T) {
 			if tt.expectErrorToContain == "" {
 				assert.NotNil(t, d)
 				assert.NoError(t, e)
+				assert.Equal(t, tt.expectedArch, d.Architecture())
 			} else {
 				assert.Nil(t, d)
 				assert.Error(t, e)
@@ -249,6 +258,29 @@ func TestDistroFromComponents_ArchitectureValidation(t *testing.T) {
 	}
 }
 
+func TestDistroFromComponents_LinuxARM64(t *testing.T) {
+	for _, tt := range []struct {
+		distro, major, minor, expectedGcloud string
+	}{
+		{"ubuntu", "20", "04", "ubuntu-2004-arm64"},
+		{"rhel", "8", "4", "rhel-8-arm64"},
+		{"sles-sap", "15", "", "sles-sap-15-arm64"},
+	} {
+		t.Run(tt.expectedGcloud, func(t *testing.T) {
+			d, e := FromComponents(tt.distro, tt.major, tt.minor, "aarch64")
+			assert.NoError(t, e)
+			assert.Equal(t, tt.expectedGcloud, d.AsGcloudArg())
+			assert.Equal(t, "arm64", d.Architecture())
+		})
+	}
+}
+
+func TestDistroFromComponents_WindowsARM64IsNotImportable(t *testing.T) {
+	d, e := FromComponents("windows", "10", "", "arm64")
+	assert.Nil(t, d)
+	assert.EqualError(t, e, "Windows on `arm64` is not importable")
+}
+
 func TestDistroFromComponents_LinuxVersionErrors(t *testing.T) {
 	var cases = []struct {
 		name         string
@@ -375,6 +407,25 @@ func TestImportCompatible(t *testing.T) {
 		fromID("rhel-8-byol"),
 		fromComponents("rhel", "8"),
 		fromComponents("rhel", "8", "1"),
+	}, {
+		fromID("rocky-8"),
+		fromComponents("rocky", "8"),
+		fromComponents("rocky", "8", "4"),
+	}, {
+		fromID("almalinux-8"),
+		fromComponents("almalinux", "8"),
+		fromComponents("almalinux", "8", "5"),
+	}, {
+		fromID("oracle-7"),
+		fromComponents("oracle", "7"),
+		fromComponents("oracle", "7", "9"),
+	}, {
+		fromID("oracle-8"),
+		fromComponents("oracle", "8"),
+		fromComponents("oracle", "8", "4"),
+	}, {
+		fromID("amazon-2"),
+		fromComponents("amazon", "2"),
 	}, {
 		fromID("debian-7"),
 		fromComponents("debian", "7"),
@@ -391,6 +442,19 @@ func TestImportCompatible(t *testing.T) {
 		fromID("opensuse-15"),
 		fromComponents("opensuse", "15"),
 		fromComponents("opensuse", "15", "4"),
+	}, {
+		fromID("ubuntu-2004"),
+		fromComponents("ubuntu", "20", "04", "x64"),
+	}, {
+		fromID("ubuntu-2004-arm64"),
+		fromComponents("ubuntu", "20", "04", "aarch64"),
+	}, {
+		fromID("rhel-8-arm64"),
+		fromID("rhel-8-arm64-byol"),
+		fromComponents("rhel", "8", "4", "arm64"),
+	}, {
+		fromID("sles-15-arm64"),
+		fromComponents("sles", "15", "3", "arm64"),
 	}, {
 		fromID("windows-8-x86"),
 		fromComponents("windows", "8", "", "x86"),
@@ -78,7 +78,7 @@ func (inflater *apiInflater) Inflate() (persistentDisk, shadowTestFields, error)
 
 	ctx := context.Background()
 	startTime := time.Now()
-	diskName := "disk-" + inflater.request.ExecutionID
+	diskName := inflater.request.inflatedDiskName()
 
 	cd, err := inflater.createDisk(diskName)
 	if err != nil {
@@ -131,6 +131,11 @@ func (inflater *apiInflater) createDisk(diskName string) (compute.Disk, error) {
 		Name:                diskName,
 		SourceStorageObject: inflater.request.Source.Path(),
 		GuestOsFeatures:     inflater.guestOsFeatures,
+		DiskEncryptionKey:   inflater.request.encryptionKey(),
+	}
+	if diskName == inflater.request.ImageName {
+		cd.Description = inflater.request.Description
+		cd.Labels = inflater.request.outputLabels()
 	}
 	err := inflater.computeClient.CreateDisk(inflater.request.Project, inflater.request.Zone, &cd)
 	return cd, err
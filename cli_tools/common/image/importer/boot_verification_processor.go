@@ -0,0 +1,342 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/compute/v1"
+
+	daisyUtils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/daisy"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
+	"github.com/GoogleCloudPlatform/compute-image-tools/daisy"
+	daisyCompute "github.com/GoogleCloudPlatform/compute-image-tools/daisy/compute"
+	"github.com/GoogleCloudPlatform/compute-image-tools/proto/go/pb"
+)
+
+const (
+	defaultVerifyBootTimeout = 10 * time.Minute
+	verifyBootWaitStep       = "wait-for-boot"
+
+	// Keys of the values that the startup script writes to the serial console.
+	bootTimeKey      = "boot-time-ms"
+	failedScriptsKey = "failed-scripts"
+)
+
+// bootVerificationProcessor boots a temporary instance from the imported image,
+// and waits for the guest environment to run a startup script that runs the
+// user's verification scripts. When verification fails, the image is
+// deprecated or deleted.
+type bootVerificationProcessor struct {
+	request       ImageImportRequest
+	workflow      *daisy.Workflow
+	computeClient daisyCompute.Client
+	logger        logging.Logger
+	scriptNames   []string
+}
+
+func newBootVerificationProcessor(request ImageImportRequest, computeClient daisyCompute.Client,
+	logger logging.Logger, windows bool, architecture string) (processor, error) {
+	var scripts, scriptNames []string
+	for _, path := range request.VerifyBootScripts {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		scripts = append(scripts, string(content))
+		scriptNames = append(scriptNames, filepath.Base(path))
+	}
+	return &bootVerificationProcessor{
+		request:       request,
+		workflow:      createBootVerificationWorkflow(request, scripts, windows, architecture),
+		computeClient: computeClient,
+		logger:        logger,
+		scriptNames:   scriptNames,
+	}, nil
+}
+
+func (p *bootVerificationProcessor) process(pd persistentDisk) (persistentDisk, error) {
+	p.logger.User("Verifying that the image boots on Google Compute Engine")
+	logging.ReportPhase(p.logger, logging.PhaseVerifyBoot)
+	err := p.workflow.RunWithModifiers(context.Background(), p.preValidateFunc(), p.postValidateFunc())
+	if p.workflow.Logger != nil {
+		for _, trace := range p.workflow.Logger.ReadSerialPortLogs() {
+			p.logger.Trace(trace)
+		}
+	}
+	return pd, p.handleResult(err)
+}
+
+func (p *bootVerificationProcessor) cancel(reason string) bool {
+	p.workflow.CancelWithReason(reason)
+	return true
+}
+
+// handleResult interprets the values that the startup script wrote to the
+// serial console, records them in the tool's metrics, and applies the
+// failure action to the image when verification failed.
+func (p *bootVerificationProcessor) handleResult(workflowErr error) error {
+	result := &pb.BootVerification{}
+	bootTime := p.workflow.GetSerialConsoleOutputValue(bootTimeKey)
+	if bootTime != "" {
+		result.BootTimeMs, _ = strconv.ParseInt(bootTime, 10, 64)
+	}
+	for _, index := range strings.Split(p.workflow.GetSerialConsoleOutputValue(failedScriptsKey), ",") {
+		if i, err := strconv.Atoi(index); err == nil && i >= 0 && i < len(p.scriptNames) {
+			result.FailedScripts = append(result.FailedScripts, p.scriptNames[i])
+		}
+	}
+
+	var failure string
+	switch {
+	case workflowErr == nil:
+		result.Result = pb.BootVerification_PASSED
+	case bootTime != "":
+		result.Result = pb.BootVerification_SCRIPT_FAILED
+		failure = fmt.Sprintf("verification scripts failed: %s", strings.Join(result.FailedScripts, ", "))
+	case strings.Contains(workflowErr.Error(), fmt.Sprintf("step %q", verifyBootWaitStep)):
+		result.Result = pb.BootVerification_BOOT_FAILED
+		failure = fmt.Sprintf("the guest environment didn't start within %s: %v", verifyBootTimeout(p.request), workflowErr)
+	default:
+		// The instance wasn't created, so the image wasn't tested.
+		p.logger.Metric(&pb.OutputInfo{BootVerification: result})
+		return daisy.Errf("Boot verification couldn't run: %v", workflowErr)
+	}
+	if result.Result == pb.BootVerification_PASSED {
+		p.logger.User(fmt.Sprintf("Boot verification passed. The guest environment was ready %s after boot.",
+			time.Duration(result.BootTimeMs)*time.Millisecond))
+		p.logger.Metric(&pb.OutputInfo{BootVerification: result})
+		return nil
+	}
+
+	action, err := p.applyFailureAction()
+	result.ImageAction = action
+	p.logger.Metric(&pb.OutputInfo{BootVerification: result})
+	if err != nil {
+		return daisy.Errf("Boot verification failed: %s. The image couldn't be %sd: %v",
+			failure, p.failureAction(), err)
+	}
+	return daisy.Errf("Boot verification failed: %s. The image was %sd.", failure, p.failureAction())
+}
+
+func (p *bootVerificationProcessor) applyFailureAction() (pb.BootVerification_ImageAction, error) {
+	if p.failureAction() == VerifyBootFailureDelete {
+		if err := p.computeClient.DeleteImage(p.request.Project, p.request.ImageName); err != nil {
+			return pb.BootVerification_IMAGE_ACTION_NONE, err
+		}
+		return pb.BootVerification_DELETED, nil
+	}
+	err := p.computeClient.DeprecateImage(p.request.Project, p.request.ImageName,
+		&compute.DeprecationStatus{State: "DEPRECATED"})
+	if err != nil {
+		return pb.BootVerification_IMAGE_ACTION_NONE, err
+	}
+	return pb.BootVerification_DEPRECATED, nil
+}
+
+func (p *bootVerificationProcessor) failureAction() string {
+	if p.request.VerifyBootFailureAction == "" {
+		return VerifyBootFailureDeprecate
+	}
+	return p.request.VerifyBootFailureAction
+}
+
+func verifyBootTimeout(request ImageImportRequest) time.Duration {
+	if request.VerifyBootTimeout > 0 {
+		return request.VerifyBootTimeout
+	}
+	return defaultVerifyBootTimeout
+}
+
+func (p *bootVerificationProcessor) postValidateFunc() daisy.WorkflowModifier {
+	return func(w *daisy.Workflow) {
+		buildID := os.Getenv(daisyUtils.BuildIDOSEnvVarName)
+		rl := &daisyUtils.ResourceLabeler{
+			BuildID:         buildID,
+			UserLabels:      p.request.Labels,
+			BuildIDLabelKey: "gce-image-import-build-id",
+			InstanceLabelKeyRetriever: func(instanceName string) string {
+				return "gce-image-import-tmp"
+			},
+			DiskLabelKeyRetriever: func(disk *daisy.Disk) string {
+				return "gce-image-import-tmp"
+			},
+			ImageLabelKeyRetriever: func(imageName string) string {
+				return "gce-image-import"
+			}}
+		rl.LabelResources(w)
+		daisyUtils.UpdateAllInstanceNoExternalIP(w, p.request.NoExternalIP)
+	}
+}
+
+func (p *bootVerificationProcessor) preValidateFunc() daisy.WorkflowModifier {
+	return func(w *daisy.Workflow) {
+		w.SetLogProcessHook(daisyUtils.RemovePrivacyLogTag)
+	}
+}
+
+// createBootVerificationWorkflow creates a workflow that boots an instance from
+// the imported image. The instance's startup script reports the boot time, and
+// runs scripts, which are passed as metadata. Images of arm64 disks are booted
+// on an arm64 machine type.
+func createBootVerificationWorkflow(request ImageImportRequest, scripts []string, windows bool,
+	architecture string) *daisy.Workflow {
+	startupScriptKey, startupScript := "startup-script", linuxVerificationScript
+	if windows {
+		startupScriptKey, startupScript = "windows-startup-script-ps1", windowsVerificationScript
+	}
+	startupScript = strings.Replace(startupScript, "{{script_count}}", strconv.Itoa(len(scripts)), 1)
+	metadata := []*compute.MetadataItems{{Key: startupScriptKey, Value: &startupScript}}
+	for i := range scripts {
+		metadata = append(metadata, &compute.MetadataItems{
+			Key:   fmt.Sprintf("verification-script-%d", i),
+			Value: &scripts[i],
+		})
+	}
+	machineType := "n1-standard-2"
+	if architecture == daisyUtils.ArchitectureARM64 {
+		machineType = daisyUtils.MachineTypeARM64
+	}
+	instance := compute.Instance{
+		Name:        "inst-verify-${NAME}",
+		Disks:       []*compute.AttachedDisk{{Source: "disk-verify-${NAME}", AutoDelete: true}},
+		MachineType: machineType,
+		Metadata:    &compute.Metadata{Items: metadata},
+		NetworkInterfaces: []*compute.NetworkInterface{{
+			Network:    request.Network,
+			Subnetwork: request.Subnet,
+		}},
+	}
+	if request.ComputeServiceAccount != "" {
+		instance.ServiceAccounts = []*compute.ServiceAccount{{
+			Email:  request.ComputeServiceAccount,
+			Scopes: []string{"https://www.googleapis.com/auth/cloud-platform"},
+		}}
+	}
+
+	w := daisy.New()
+	logPrefix := request.DaisyLogLinePrefix
+	if logPrefix != "" {
+		logPrefix += "-"
+	}
+	w.Name = logPrefix + "verify"
+	w.Steps = map[string]*daisy.Step{
+		"create-disk": {
+			CreateDisks: &daisy.CreateDisks{{
+				Disk: compute.Disk{
+					Name:        "disk-verify-${NAME}",
+					SourceImage: fmt.Sprintf("projects/%s/global/images/%s", request.Project, request.ImageName),
+				},
+			}},
+		},
+		"create-instance": {
+			CreateInstances: &daisy.CreateInstances{
+				Instances: []*daisy.Instance{{Instance: instance}},
+			},
+		},
+		verifyBootWaitStep: {
+			WaitForInstancesSignal: &daisy.WaitForInstancesSignal{{
+				Name: "inst-verify-${NAME}",
+				SerialOutput: &daisy.SerialOutput{
+					Port:         1,
+					SuccessMatch: "BootVerificationSuccess",
+					FailureMatch: []string{"BootVerificationFailed"},
+					StatusMatch:  "BootVerificationStatus",
+				},
+			}},
+		},
+	}
+	w.Dependencies = map[string][]string{
+		"create-instance":  {"create-disk"},
+		verifyBootWaitStep: {"create-instance"},
+	}
+
+	env := request.EnvironmentSettings()
+	env.Timeout = verifyBootTimeout(request).String()
+	env.ApplyToWorkflow(w)
+	return w
+}
+
+// linuxVerificationScript runs as the instance's startup script. Verification
+// scripts are read from the metadata server, and run as executables; they fail
+// when their exit status isn't zero.
+const linuxVerificationScript = `#!/bin/bash
+script_count={{script_count}}
+
+function serialOutputKeyValue() {
+  echo "BootVerificationStatus: <serial-output key:'$1' value:'$2'>"
+}
+
+function runScript() {
+  local path="/tmp/verification-script-$1"
+  curl -sf -H "Metadata-Flavor: Google" -o "$path" \
+    "http://metadata.google.internal/computeMetadata/v1/instance/attributes/verification-script-$1" &&
+    chmod +x "$path" && "$path"
+}
+
+serialOutputKeyValue "boot-time-ms" "$(awk '{printf "%d", $1 * 1000}' /proc/uptime)"
+failed=
+for ((i = 0; i < script_count; i++)); do
+  if ! runScript "$i"; then
+    failed="${failed:+$failed,}$i"
+  fi
+done
+if [[ -n "$failed" ]]; then
+  serialOutputKeyValue "failed-scripts" "$failed"
+  echo "BootVerificationFailed: verification scripts failed"
+else
+  echo "BootVerificationSuccess"
+fi
+`
+
+// windowsVerificationScript runs as the instance's startup script. Verification
+// scripts are read from the metadata server, and run with PowerShell; they fail
+// when their exit code isn't zero.
+const windowsVerificationScript = `$scriptCount = {{script_count}}
+
+function Write-SerialOutputKeyValue($key, $value) {
+  Write-Host "BootVerificationStatus: <serial-output key:'$key' value:'$value'>"
+}
+
+$uptime = (Get-Date) - (Get-CimInstance Win32_OperatingSystem).LastBootUpTime
+Write-SerialOutputKeyValue 'boot-time-ms' ([int64]$uptime.TotalMilliseconds)
+$failed = @()
+for ($i = 0; $i -lt $scriptCount; $i++) {
+  $path = "$env:TEMP\verification-script-$i.ps1"
+  try {
+    $uri = "http://metadata.google.internal/computeMetadata/v1/instance/attributes/verification-script-$i"
+    Invoke-RestMethod -Headers @{'Metadata-Flavor' = 'Google'} -Uri $uri -OutFile $path
+    & powershell.exe -NoProfile -ExecutionPolicy Bypass -File $path
+    if ($LASTEXITCODE -ne 0) {
+      $failed += $i
+    }
+  } catch {
+    $failed += $i
+  }
+}
+if ($failed.Count -gt 0) {
+  Write-SerialOutputKeyValue 'failed-scripts' ($failed -join ',')
+  Write-Host 'BootVerificationFailed: verification scripts failed'
+} else {
+  Write-Host 'BootVerificationSuccess'
+}
+`
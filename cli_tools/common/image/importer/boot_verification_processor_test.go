@@ -0,0 +1,203 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package importer
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/compute/v1"
+
+	daisyUtils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/daisy"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/mocks"
+	"github.com/GoogleCloudPlatform/compute-image-tools/proto/go/pb"
+)
+
+func TestBootVerificationProcessor_CreatesWorkflow(t *testing.T) {
+	script, err := ioutil.TempFile("", "check*.sh")
+	assert.NoError(t, err)
+	defer os.Remove(script.Name())
+	_, err = script.WriteString("systemctl is-active sshd")
+	assert.NoError(t, err)
+	assert.NoError(t, script.Close())
+
+	for _, windows := range []bool{false, true} {
+		request := ImageImportRequest{
+			ImageName:         "image",
+			Project:           "project",
+			Zone:              "us-central1-a",
+			Network:           "global/networks/net",
+			Subnet:            "regions/us-central1/subnetworks/subnet",
+			VerifyBoot:        true,
+			VerifyBootScripts: []string{script.Name()},
+			VerifyBootTimeout: 5 * time.Minute,
+		}
+		p, err := newBootVerificationProcessor(request, nil, logging.NewToolLogger(t.Name()), windows, "")
+		assert.NoError(t, err)
+		verifier := p.(*bootVerificationProcessor)
+		assert.Equal(t, []string{filepath.Base(script.Name())}, verifier.scriptNames)
+
+		w := verifier.workflow
+		assert.Equal(t, "5m0s", w.DefaultTimeout)
+		disk := (*w.Steps["create-disk"].CreateDisks)[0]
+		assert.Equal(t, "projects/project/global/images/image", disk.SourceImage)
+		instance := w.Steps["create-instance"].CreateInstances.Instances[0].Instance
+		assert.Equal(t, "n1-standard-2", instance.MachineType)
+		assert.Equal(t, "global/networks/net", instance.NetworkInterfaces[0].Network)
+		assert.Equal(t, "regions/us-central1/subnetworks/subnet", instance.NetworkInterfaces[0].Subnetwork)
+
+		metadata := map[string]string{}
+		for _, item := range instance.Metadata.Items {
+			metadata[item.Key] = *item.Value
+		}
+		assert.Equal(t, "systemctl is-active sshd", metadata["verification-script-0"])
+		if windows {
+			assert.Contains(t, metadata["windows-startup-script-ps1"], "$scriptCount = 1\n")
+			assert.NotContains(t, metadata, "startup-script")
+		} else {
+			assert.Contains(t, metadata["startup-script"], "script_count=1\n")
+			assert.NotContains(t, metadata, "windows-startup-script-ps1")
+		}
+	}
+}
+
+func TestBootVerificationProcessor_UsesARM64MachineType_WhenArchitectureIsARM64(t *testing.T) {
+	p, err := newBootVerificationProcessor(ImageImportRequest{
+		ImageName:  "image",
+		Project:    "project",
+		VerifyBoot: true,
+	}, nil, logging.NewToolLogger(t.Name()), false, daisyUtils.ArchitectureARM64)
+	assert.NoError(t, err)
+
+	instance := p.(*bootVerificationProcessor).workflow.Steps["create-instance"].CreateInstances.Instances[0].Instance
+	assert.Equal(t, "t2a-standard-2", instance.MachineType)
+}
+
+func TestBootVerificationProcessor_FailsWhenScriptCantBeRead(t *testing.T) {
+	_, err := newBootVerificationProcessor(ImageImportRequest{
+		VerifyBoot:        true,
+		VerifyBootScripts: []string{"/does/not/exist.sh"},
+	}, nil, logging.NewToolLogger(t.Name()), false, "")
+	assert.Error(t, err)
+}
+
+func TestBootVerificationProcessor_HandleResult(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		action        string
+		workflowErr   error
+		serialValues  map[string]string
+		imageErr      error
+		expectedError string
+		expected      *pb.BootVerification
+	}{
+		{
+			name:         "passed",
+			serialValues: map[string]string{bootTimeKey: "42000"},
+			expected:     &pb.BootVerification{Result: pb.BootVerification_PASSED, BootTimeMs: 42000},
+		},
+		{
+			name:          "boot timed out",
+			workflowErr:   errors.New(`step "wait-for-boot" did not complete within the specified timeout of 10m0s`),
+			expectedError: "Boot verification failed: the guest environment didn't start within 10m0s",
+			expected: &pb.BootVerification{
+				Result:      pb.BootVerification_BOOT_FAILED,
+				ImageAction: pb.BootVerification_DEPRECATED,
+			},
+		},
+		{
+			name:          "script failed",
+			action:        VerifyBootFailureDelete,
+			workflowErr:   errors.New(`step "wait-for-boot" run error: BootVerificationFailed`),
+			serialValues:  map[string]string{bootTimeKey: "1000", failedScriptsKey: "1"},
+			expectedError: "Boot verification failed: verification scripts failed: second.sh. The image was deleted.",
+			expected: &pb.BootVerification{
+				Result:        pb.BootVerification_SCRIPT_FAILED,
+				BootTimeMs:    1000,
+				FailedScripts: []string{"second.sh"},
+				ImageAction:   pb.BootVerification_DELETED,
+			},
+		},
+		{
+			name:          "image can't be deprecated",
+			workflowErr:   errors.New(`step "wait-for-boot" run error: BootVerificationFailed`),
+			serialValues:  map[string]string{bootTimeKey: "1000", failedScriptsKey: "0"},
+			imageErr:      errors.New("permission denied"),
+			expectedError: "Boot verification failed: verification scripts failed: first.sh. The image couldn't be deprecated: permission denied",
+			expected: &pb.BootVerification{
+				Result:        pb.BootVerification_SCRIPT_FAILED,
+				BootTimeMs:    1000,
+				FailedScripts: []string{"first.sh"},
+			},
+		},
+		{
+			name:          "instance wasn't created",
+			workflowErr:   errors.New(`step "create-instance" run error: quota exceeded`),
+			expectedError: "Boot verification couldn't run: step \"create-instance\" run error: quota exceeded",
+			expected:      &pb.BootVerification{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockComputeClient := mocks.NewMockClient(mockCtrl)
+			if tt.expected.Result == pb.BootVerification_BOOT_FAILED || tt.expected.Result == pb.BootVerification_SCRIPT_FAILED {
+				if tt.action == VerifyBootFailureDelete {
+					mockComputeClient.EXPECT().DeleteImage("project", "image").Return(tt.imageErr)
+				} else {
+					mockComputeClient.EXPECT().DeprecateImage("project", "image",
+						&compute.DeprecationStatus{State: "DEPRECATED"}).Return(tt.imageErr)
+				}
+			}
+			request := ImageImportRequest{
+				ImageName:               "image",
+				Project:                 "project",
+				VerifyBoot:              true,
+				VerifyBootFailureAction: tt.action,
+			}
+			logger := logging.NewToolLogger(t.Name())
+			p := &bootVerificationProcessor{
+				request:       request,
+				workflow:      createBootVerificationWorkflow(request, nil, false, ""),
+				computeClient: mockComputeClient,
+				logger:        logger,
+				scriptNames:   []string{"first.sh", "second.sh"},
+			}
+			for k, v := range tt.serialValues {
+				p.workflow.AddSerialConsoleOutputValue(k, v)
+			}
+
+			err := p.handleResult(tt.workflowErr)
+			if tt.expectedError == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.True(t, strings.HasPrefix(err.Error(), tt.expectedError), err.Error())
+			}
+			actual := logger.ReadOutputInfo().BootVerification
+			assert.Equal(t, tt.expected.Result, actual.GetResult())
+			assert.Equal(t, tt.expected.BootTimeMs, actual.GetBootTimeMs())
+			assert.Equal(t, tt.expected.FailedScripts, actual.GetFailedScripts())
+			assert.Equal(t, tt.expected.ImageAction, actual.GetImageAction())
+		})
+	}
+}
@@ -28,14 +28,16 @@ import (
 )
 
 type bootableDiskProcessor struct {
-	request    ImageImportRequest
-	workflow   *daisy.Workflow
-	logger     logging.Logger
-	detectedOs distro.Release
+	request      ImageImportRequest
+	workflow     *daisy.Workflow
+	logger       logging.Logger
+	detectedOs   distro.Release
+	architecture string
 }
 
 func (b *bootableDiskProcessor) process(pd persistentDisk) (persistentDisk, error) {
 	b.logger.User("Making disk bootable on Google Compute Engine")
+	logging.ReportPhase(b.logger, logging.PhaseTranslate)
 	b.workflow.AddVar("source_disk", pd.uri)
 	var err error
 	err = b.workflow.RunWithModifiers(context.Background(), b.preValidateFunc(), b.postValidateFunc())
@@ -57,7 +59,8 @@ func (b *bootableDiskProcessor) cancel(reason string) bool {
 	return true
 }
 
-func newBootableDiskProcessor(request ImageImportRequest, wfPath string, logger logging.Logger, detectedOs distro.Release) (processor, error) {
+func newBootableDiskProcessor(request ImageImportRequest, wfPath string, logger logging.Logger,
+	detectedOs distro.Release, architecture string) (processor, error) {
 	vars := map[string]string{
 		"image_name":           request.ImageName,
 		"install_gce_packages": strconv.FormatBool(!request.NoGuestEnvironment),
@@ -79,6 +82,7 @@ func newBootableDiskProcessor(request ImageImportRequest, wfPath string, logger
 	if err != nil {
 		return nil, err
 	}
+	workflow.KmsKey = request.KmsKey
 
 	// Daisy uses the workflow name as the prefix for log lines.
 	logPrefix := request.DaisyLogLinePrefix
@@ -88,10 +92,11 @@ func newBootableDiskProcessor(request ImageImportRequest, wfPath string, logger
 	workflow.Name = logPrefix + "translate"
 
 	return &bootableDiskProcessor{
-		request:    request,
-		workflow:   workflow,
-		logger:     logger,
-		detectedOs: detectedOs,
+		request:      request,
+		workflow:     workflow,
+		logger:       logger,
+		detectedOs:   detectedOs,
+		architecture: architecture,
 	}, err
 }
 
@@ -119,11 +124,17 @@ func (b *bootableDiskProcessor) postValidateFunc() daisy.WorkflowModifier {
 			}}
 		rl.LabelResources(w)
 		daisy_utils.UpdateAllInstanceNoExternalIP(w, b.request.NoExternalIP)
+		if b.architecture == daisy_utils.ArchitectureARM64 {
+			daisy_utils.UpdateToARM64(w)
+		}
 	}
 }
 
 func (b *bootableDiskProcessor) preValidateFunc() daisy.WorkflowModifier {
 	return func(w *daisy.Workflow) {
 		w.SetLogProcessHook(daisy_utils.RemovePrivacyLogTag)
+		if !b.request.outputsImage() {
+			daisy_utils.RemoveCreateImageSteps(w)
+		}
 	}
 }
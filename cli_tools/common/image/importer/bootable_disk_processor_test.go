@@ -43,7 +43,7 @@ func TestBootableDiskProcessor_Process_WritesSourceDiskVar(t *testing.T) {
 		OS: "opensuse-15",
 	}
 	p, err := newBootableDiskProcessor(request, opensuse15workflow, logging.NewToolLogger(t.Name()),
-		distro.FromGcloudOSArgumentMustParse("windows-2008r2"))
+		distro.FromGcloudOSArgumentMustParse("windows-2008r2"), "")
 	assert.NoError(t, err)
 	_, err = p.process(persistentDisk{uri: "uri"})
 	assert.Equal(t, "uri", p.(*bootableDiskProcessor).workflow.Vars["source_disk"].Value)
@@ -55,7 +55,7 @@ func TestBootableDiskProcessor_SetsWorkflowNameToGcloudPrefix(t *testing.T) {
 	args := defaultImportArgs()
 	args.DaisyLogLinePrefix = "disk-1"
 	processor, e := newBootableDiskProcessor(args, opensuse15workflow, logging.NewToolLogger(t.Name()),
-		distro.FromGcloudOSArgumentMustParse("windows-2008r2"))
+		distro.FromGcloudOSArgumentMustParse("windows-2008r2"), "")
 	assert.NoError(t, e)
 	assert.Equal(t, "disk-1-translate", (processor.(*bootableDiskProcessor)).workflow.Name)
 }
@@ -166,10 +166,21 @@ func TestBootableDiskProcessor_PermitsUnsetStorageLocation(t *testing.T) {
 	assert.Empty(t, image.StorageLocations)
 }
 
+func TestBootableDiskProcessor_DoesntCreateImageWhenOutputIsDisk(t *testing.T) {
+	imageSpec := defaultImportArgs()
+	imageSpec.Output = OutputDisk
+	actual := createAndRunPrePostFunctions(t, imageSpec)
+
+	for name, step := range actual.workflow.Steps {
+		assert.Nil(t, step.CreateImages, name)
+	}
+	assert.NotContains(t, actual.workflow.Dependencies, "create-image")
+}
+
 func TestBootableDiskProcessor_SupportsCancel(t *testing.T) {
 	args := defaultImportArgs()
 	processor, e := newBootableDiskProcessor(args, opensuse15workflow, logging.NewToolLogger(t.Name()),
-		distro.FromGcloudOSArgumentMustParse("windows-2008r2"))
+		distro.FromGcloudOSArgumentMustParse("windows-2008r2"), "")
 	assert.NoError(t, e)
 
 	realProcessor := processor.(*bootableDiskProcessor)
@@ -180,7 +191,7 @@ func TestBootableDiskProcessor_SupportsCancel(t *testing.T) {
 
 func createAndRunPrePostFunctions(t *testing.T, request ImageImportRequest) *bootableDiskProcessor {
 	translator, e := newBootableDiskProcessor(request, opensuse15workflow, logging.NewToolLogger(t.Name()),
-		distro.FromGcloudOSArgumentMustParse("windows-2008r2"))
+		distro.FromGcloudOSArgumentMustParse("windows-2008r2"), "")
 	assert.NoError(t, e)
 	realTranslator := translator.(*bootableDiskProcessor)
 	// A concrete logger is required since the import/export logging framework writes a log entry
@@ -86,7 +86,7 @@ func NewDaisyInflater(request ImageImportRequest, fileInspector imagefile.Inspec
 }
 
 func newDaisyInflater(request ImageImportRequest, fileInspector imagefile.Inspector, logger logging.Logger) (*daisyInflater, error) {
-	diskName := "disk-" + request.ExecutionID
+	diskName := request.inflatedDiskName()
 	var wfPath string
 	var vars map[string]string
 	var inflationDiskIndex int
@@ -113,6 +113,7 @@ func newDaisyInflater(request ImageImportRequest, fileInspector imagefile.Inspec
 	for k, v := range vars {
 		wf.AddVar(k, v)
 	}
+	wf.KmsKey = request.KmsKey
 	daisyUtils.UpdateAllInstanceNoExternalIP(wf, request.NoExternalIP)
 	if request.UefiCompatible {
 		addFeatureToDisk(wf, "UEFI_COMPATIBLE", inflationDiskIndex)
@@ -120,6 +121,11 @@ func newDaisyInflater(request ImageImportRequest, fileInspector imagefile.Inspec
 	if strings.Contains(request.OS, "windows") {
 		addFeatureToDisk(wf, "WINDOWS", inflationDiskIndex)
 	}
+	if diskName == request.ImageName {
+		disk := getDisk(wf, inflationDiskIndex)
+		disk.Description = request.Description
+		disk.Labels = request.outputLabels()
+	}
 
 	logPrefix := request.DaisyLogLinePrefix
 	if logPrefix != "" {
@@ -52,6 +52,25 @@ func TestCreateDaisyInflater_Image_HappyCase(t *testing.T) {
 		"projects/compute-image-tools/global/licenses/virtual-disk-import")
 }
 
+func TestCreateDaisyInflater_InflatesToOutputDisk_ForDataDisk(t *testing.T) {
+	inflater := createDaisyInflaterForImageSafe(t, ImageImportRequest{
+		Source:      imageSource{uri: "projects/test/uri/image"},
+		Zone:        "us-west1-b",
+		ExecutionID: "1234",
+		ImageName:   "data",
+		Description: "description",
+		Labels:      map[string]string{"user-key": "user-value"},
+		DataDisk:    true,
+		Output:      OutputDisk,
+	})
+
+	assert.Equal(t, "zones/us-west1-b/disks/data", inflater.inflatedDiskURI)
+	assert.Equal(t, "data", inflater.wf.Vars["disk_name"].Value)
+	inflatedDisk := getDisk(inflater.wf, 0)
+	assert.Equal(t, "description", inflatedDisk.Description)
+	assert.Equal(t, map[string]string{"gce-image-import": "true", "user-key": "user-value"}, inflatedDisk.Labels)
+}
+
 func TestCreateDaisyInflater_Image_Windows(t *testing.T) {
 	inflater := createDaisyInflaterForImageSafe(t, ImageImportRequest{
 		Source: imageSource{uri: "image/uri"},
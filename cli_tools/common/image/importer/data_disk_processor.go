@@ -19,6 +19,7 @@ import (
 
 	"google.golang.org/api/compute/v1"
 
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
 	daisyCompute "github.com/GoogleCloudPlatform/compute-image-tools/daisy/compute"
 )
 
@@ -26,11 +27,13 @@ type dataDiskProcessor struct {
 	computeImageClient daisyCompute.Client
 	project            string
 	request            compute.Image
+	logger             logging.Logger
 }
 
 func newDataDiskProcessor(pd persistentDisk, client daisyCompute.Client, project string,
 	userLabels map[string]string, userStorageLocation string,
-	description string, family string, imageName string) processor {
+	description string, family string, imageName string, encryptionKey *compute.CustomerEncryptionKey,
+	logger logging.Logger) processor {
 	labels := map[string]string{"gce-image-import": "true"}
 	for k, v := range userLabels {
 		labels[k] = v
@@ -44,20 +47,23 @@ func newDataDiskProcessor(pd persistentDisk, client daisyCompute.Client, project
 		computeImageClient: client,
 		project:            project,
 		request: compute.Image{
-			Description:      description,
-			Family:           family,
-			Labels:           labels,
-			Name:             imageName,
-			SourceDisk:       pd.uri,
-			StorageLocations: storageLocation,
-			Licenses:         []string{"projects/compute-image-tools/global/licenses/virtual-disk-import"},
+			Description:        description,
+			Family:             family,
+			Labels:             labels,
+			Name:               imageName,
+			SourceDisk:         pd.uri,
+			StorageLocations:   storageLocation,
+			Licenses:           []string{"projects/compute-image-tools/global/licenses/virtual-disk-import"},
+			ImageEncryptionKey: encryptionKey,
 		},
+		logger: logger,
 	}
 }
 
 func (d dataDiskProcessor) process(pd persistentDisk) (persistentDisk, error) {
 
 	log.Printf("Creating image \"%v\"", d.request.Name)
+	logging.ReportPhase(d.logger, logging.PhaseCreateImage)
 	return pd, d.computeImageClient.CreateImage(d.project, &d.request)
 }
 
@@ -20,6 +20,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/api/compute/v1"
 
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
 	daisyCompute "github.com/GoogleCloudPlatform/compute-image-tools/daisy/compute"
 )
 
@@ -38,7 +39,9 @@ func Test_ImageIncludesTrackingLabelAndLicense(t *testing.T) {
 		"northamerica",
 		"description-content",
 		"family-name",
-		"image-name")
+		"image-name",
+		nil,
+		logging.NewToolLogger(t.Name()))
 
 	_, err := processor.process(persistentDisk{})
 	assert.NoError(t, err)
@@ -68,3 +71,15 @@ func (m *mockComputeClient) CreateImage(project string, i *compute.Image) error
 	m.invocations++
 	return nil
 }
+
+func Test_ImageIsEncryptedWithKmsKey(t *testing.T) {
+	mockClient := mockComputeClient{expectedProject: "project-1234", t: t}
+	key := &compute.CustomerEncryptionKey{KmsKeyName: "projects/p/locations/l/keyRings/r/cryptoKeys/k"}
+
+	processor := newDataDiskProcessor(persistentDisk{uri: "global/projects/pid/pd/id"}, &mockClient,
+		"project-1234", nil, "", "", "", "image-name", key, logging.NewToolLogger(t.Name()))
+
+	_, err := processor.process(persistentDisk{})
+	assert.NoError(t, err)
+	assert.Equal(t, key, mockClient.actualImage.ImageEncryptionKey)
+}
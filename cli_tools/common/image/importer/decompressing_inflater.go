@@ -0,0 +1,293 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package importer
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	gzip "github.com/klauspost/pgzip"
+	"github.com/ulikunitz/xz"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/domain"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/storage"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/daisycommon"
+	"github.com/GoogleCloudPlatform/compute-image-tools/daisy"
+	"github.com/GoogleCloudPlatform/compute-image-tools/proto/go/pb"
+)
+
+// Compression and archive formats of source files, named after their file
+// extensions. A compressed tar archive is reported as "tar.<compression>".
+const (
+	gzipFormat = "gz"
+	xzFormat   = "xz"
+	zstdFormat = "zst"
+	tarFormat  = "tar"
+
+	// Number of bytes required to detect all formats; the magic number
+	// of a tar archive is in its first 512-byte header block.
+	formatHeaderSize = 512
+
+	// gce_export adds this file next to the disk file when licenses
+	// are exported.
+	tarManifestFile = "manifest.json"
+)
+
+var formatMagicNumbers = []struct {
+	format string
+	offset int
+	magic  []byte
+}{
+	{gzipFormat, 0, []byte{0x1f, 0x8b}},
+	{xzFormat, 0, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+	{zstdFormat, 0, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{tarFormat, 257, []byte("ustar")},
+}
+
+// detectFormat returns the compression or archive format of a file from
+// its first bytes, or an empty string when the file is neither compressed
+// nor a tar archive.
+func detectFormat(header []byte) string {
+	for _, m := range formatMagicNumbers {
+		if len(header) >= m.offset+len(m.magic) && bytes.Equal(header[m.offset:m.offset+len(m.magic)], m.magic) {
+			return m.format
+		}
+	}
+	return ""
+}
+
+// decompressingInflater unpacks a compressed or tar-wrapped source file to
+// the scratch bucket, and inflates the unpacked file using an inflater
+// created for it.
+type decompressingInflater struct {
+	request       ImageImportRequest
+	source        fileSource
+	storageClient domain.StorageClientInterface
+	newInflater   func(request ImageImportRequest) (Inflater, error)
+	logger        logging.Logger
+
+	mx        sync.Mutex
+	cancelled bool
+	reader    io.Closer
+	inflater  Inflater
+}
+
+func newDecompressingInflater(request ImageImportRequest, source fileSource, storageClient domain.StorageClientInterface,
+	logger logging.Logger, newInflater func(request ImageImportRequest) (Inflater, error)) *decompressingInflater {
+	return &decompressingInflater{
+		request:       request,
+		source:        source,
+		storageClient: storageClient,
+		newInflater:   newInflater,
+		logger:        logger,
+	}
+}
+
+func (inflater *decompressingInflater) Inflate() (persistentDisk, shadowTestFields, error) {
+	unpacked, cleanup, err := inflater.unpack()
+	if err != nil {
+		return persistentDisk{}, shadowTestFields{}, err
+	}
+	defer cleanup()
+
+	request := inflater.request
+	request.Source = unpacked
+	unpackedInflater, err := inflater.newInflater(request)
+	if err != nil {
+		return persistentDisk{}, shadowTestFields{}, err
+	}
+	inflater.mx.Lock()
+	if inflater.cancelled {
+		inflater.mx.Unlock()
+		return persistentDisk{}, shadowTestFields{}, daisy.Errf("inflation was cancelled")
+	}
+	inflater.inflater = unpackedInflater
+	inflater.mx.Unlock()
+	return unpackedInflater.Inflate()
+}
+
+func (inflater *decompressingInflater) Cancel(reason string) bool {
+	inflater.mx.Lock()
+	defer inflater.mx.Unlock()
+	if inflater.inflater != nil {
+		return inflater.inflater.Cancel(reason)
+	}
+	inflater.cancelled = true
+	if inflater.reader != nil {
+		// Fails the decompression, which is reading from the source file.
+		inflater.reader.Close()
+	}
+	return true
+}
+
+// unpack streams the source file through decompression and tar extraction
+// to the scratch bucket, and returns the unpacked file, and a function
+// deleting it.
+func (inflater *decompressingInflater) unpack() (Source, func(), error) {
+	rc, err := inflater.storageClient.GetObject(inflater.source.bucket, inflater.source.object).NewReader()
+	if err != nil {
+		return nil, nil, daisy.Errf("failed to read source file %v: %v", inflater.source.gcsPath, err)
+	}
+	defer rc.Close()
+	inflater.mx.Lock()
+	if inflater.cancelled {
+		inflater.mx.Unlock()
+		return nil, nil, daisy.Errf("inflation was cancelled")
+	}
+	inflater.reader = rc
+	inflater.mx.Unlock()
+
+	compressed := daisycommon.NewByteCountingReader(rc)
+	dc, err := decompress(compressed, inflater.source.format)
+	if err != nil {
+		return nil, nil, daisy.Errf("failed to decompress source file %v: %v", inflater.source.gcsPath, err)
+	}
+	defer dc.Close()
+	format := inflater.source.format
+	name := trimFormatExtension(path.Base(inflater.source.object))
+
+	// A compressed file may be a tar archive, such as the .tar.gz files
+	// created by gce_export.
+	buffered := bufio.NewReaderSize(dc, formatHeaderSize)
+	header, _ := buffered.Peek(formatHeaderSize)
+	var r io.Reader = buffered
+	var tr *tar.Reader
+	if detectFormat(header) == tarFormat {
+		if format != tarFormat {
+			format = tarFormat + "." + format
+		}
+		tr = tar.NewReader(buffered)
+		diskFile, err := nextTarDiskFile(tr)
+		if err != nil {
+			return nil, nil, daisy.Errf("failed to extract source file %v: %v", inflater.source.gcsPath, err)
+		}
+		if diskFile == "" {
+			return nil, nil, daisy.Errf("the tar archive %v doesn't contain a disk file", inflater.source.gcsPath)
+		}
+		r, name = tr, path.Base(diskFile)
+	}
+
+	bkt, dir, err := storage.SplitGCSPath(inflater.request.ScratchBucketGcsPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	obj := path.Join(dir, "decompressed", name)
+	gcsPath := fmt.Sprintf("gs://%s/%s", bkt, obj)
+	cleanup := func() {
+		if err := inflater.storageClient.DeleteObject(gcsPath); err != nil {
+			log.Printf("Failed to delete decompressed source file %v: %v", gcsPath, err)
+		}
+	}
+	inflater.logger.User(fmt.Sprintf("Decompressing %v to %v", inflater.source.gcsPath, gcsPath))
+	decompressed := daisycommon.NewByteCountingReader(r)
+	if err := inflater.storageClient.WriteToGCS(bkt, obj, decompressed); err != nil {
+		return nil, nil, daisy.Errf("failed to decompress source file %v: %v", inflater.source.gcsPath, err)
+	}
+	if tr != nil {
+		if another, err := nextTarDiskFile(tr); err != nil || another != "" {
+			cleanup()
+			if err != nil {
+				return nil, nil, daisy.Errf("failed to extract source file %v: %v", inflater.source.gcsPath, err)
+			}
+			return nil, nil, daisy.Errf("the tar archive %v contains more than one disk file", inflater.source.gcsPath)
+		}
+	}
+	inflater.logger.Metric(&pb.OutputInfo{
+		SourceCompressionFormat:   format,
+		CompressedSourcesSizeGb:   []int64{bytesToGB(compressed.BytesRead)},
+		DecompressedSourcesSizeGb: []int64{bytesToGB(decompressed.BytesRead)},
+	})
+
+	unpacked, err := newFileSource(gcsPath, inflater.storageClient)
+	if err == nil && unpacked.(fileSource).needsUnpacking() {
+		err = daisy.Errf("the decompressed source file is %s, nested compression or archives are not supported",
+			unpacked.(fileSource).format)
+	}
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return unpacked, cleanup, nil
+}
+
+// decompress returns a reader of the decompressed content of r, which is
+// compressed in format. A tar archive is returned as is.
+func decompress(r io.Reader, format string) (io.ReadCloser, error) {
+	switch format {
+	case gzipFormat:
+		return gzip.NewReader(r)
+	case xzFormat:
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(xr), nil
+	case zstdFormat:
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}
+	return ioutil.NopCloser(r), nil
+}
+
+// nextTarDiskFile advances tr to its next disk file, and returns the file's
+// name, or an empty string when there are no more disk files. Any regular
+// file other than the gce_export manifest is considered a disk file.
+func nextTarDiskFile(tr *tar.Reader) (string, error) {
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if (h.Typeflag == tar.TypeReg || h.Typeflag == tar.TypeRegA || h.Typeflag == tar.TypeGNUSparse) &&
+			path.Base(h.Name) != tarManifestFile {
+			return h.Name, nil
+		}
+	}
+}
+
+// trimFormatExtension removes the extensions of compression and archive
+// formats from a file name, e.g. "disk.vmdk.gz" becomes "disk.vmdk".
+func trimFormatExtension(name string) string {
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar.xz", ".tar.zst", ".gz", ".xz", ".zst", ".tar"} {
+		if strings.HasSuffix(name, ext) && len(name) > len(ext) {
+			return strings.TrimSuffix(name, ext)
+		}
+	}
+	return name
+}
+
+// bytesToGB converts a size in bytes to GB, rounding up.
+func bytesToGB(size int64) int64 {
+	if size <= 0 {
+		return 0
+	}
+	return (size-1)/1073741824 + 1
+}
@@ -0,0 +1,243 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package importer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/ulikunitz/xz"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/mocks"
+	"github.com/GoogleCloudPlatform/compute-image-tools/proto/go/pb"
+)
+
+const diskContent = "disk content"
+
+func TestDetectFormat(t *testing.T) {
+	for _, tt := range []struct {
+		name, content, expected string
+	}{
+		{"gzip", compressGzip(t, diskContent), gzipFormat},
+		{"xz", compressXz(t, diskContent), xzFormat},
+		{"zstd", compressZstd(t, diskContent), zstdFormat},
+		{"tar", createTar(t, "disk.raw", diskContent), tarFormat},
+		{"uncompressed", diskContent, ""},
+		{"short file", "\x1f", ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, detectFormat([]byte(tt.content)))
+		})
+	}
+}
+
+func TestCreateDecompressingInflater_CompressedFile(t *testing.T) {
+	source := fileSource{gcsPath: "gs://bucket/disk.vmdk.gz", format: gzipFormat}
+	inflater, err := newInflater(ImageImportRequest{Source: source}, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	decompressingInflater, ok := inflater.(*decompressingInflater)
+	assert.True(t, ok)
+	assert.Equal(t, source, decompressingInflater.source)
+}
+
+func TestDecompressingInflater_InflatesUnpackedFile(t *testing.T) {
+	for _, tt := range []struct {
+		name, object, content, expectedFormat, expectedObject string
+	}{
+		{"gzip", "disk.vmdk.gz", compressGzip(t, diskContent), gzipFormat, "scratch/decompressed/disk.vmdk"},
+		{"xz", "disk.vmdk.xz", compressXz(t, diskContent), xzFormat, "scratch/decompressed/disk.vmdk"},
+		{"zstd", "disk.vmdk.zst", compressZstd(t, diskContent), zstdFormat, "scratch/decompressed/disk.vmdk"},
+		{"tar", "disk.tar", createTar(t, "disk.raw", diskContent), tarFormat, "scratch/decompressed/disk.raw"},
+		{"tar.gz", "image.tar.gz", compressGzip(t, createTar(t, "disk.raw", diskContent)), "tar.gz", "scratch/decompressed/disk.raw"},
+		{"gce_export with manifest", "image.tar.gz",
+			compressGzip(t, createTar(t, "manifest.json", "{}", "disk.raw", diskContent)), "tar.gz", "scratch/decompressed/disk.raw"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			objects := map[string]string{"bucket/" + tt.object: tt.content}
+			storageClient := newFakeStorageClient(ctrl, objects)
+			mockLogger := mocks.NewMockLogger(ctrl)
+			mockLogger.EXPECT().User(gomock.Any())
+			mockLogger.EXPECT().Metric(&pb.OutputInfo{
+				SourceCompressionFormat:   tt.expectedFormat,
+				CompressedSourcesSizeGb:   []int64{1},
+				DecompressedSourcesSizeGb: []int64{1},
+			})
+
+			source := fileSource{gcsPath: "gs://bucket/" + tt.object, bucket: "bucket", object: tt.object, format: detectFormat([]byte(tt.content))}
+			unpackedInflater := &mockInflater{pd: persistentDisk{uri: "disk-uri"}}
+			var unpacked Source
+			inflater := newDecompressingInflater(ImageImportRequest{ScratchBucketGcsPath: "gs://bucket/scratch", Source: source},
+				source, storageClient, mockLogger, func(request ImageImportRequest) (Inflater, error) {
+					unpacked = request.Source
+					assert.Equal(t, diskContent, objects["bucket/"+tt.expectedObject])
+					return unpackedInflater, nil
+				})
+
+			pd, _, err := inflater.Inflate()
+			assert.NoError(t, err)
+			assert.Equal(t, "disk-uri", pd.uri)
+			assert.Equal(t, 1, unpackedInflater.interactions)
+			assert.Equal(t, fileSource{gcsPath: "gs://bucket/" + tt.expectedObject, bucket: "bucket", object: tt.expectedObject}, unpacked)
+			assert.NotContains(t, objects, "bucket/"+tt.expectedObject, "the unpacked file should be deleted")
+		})
+	}
+}
+
+func TestDecompressingInflater_Failures(t *testing.T) {
+	for _, tt := range []struct {
+		name, content, expectedError string
+	}{
+		{"corrupt gzip", "\x1f\x8bcorrupt", "failed to decompress source file gs://bucket/disk.gz"},
+		{"tar without disk", compressGzip(t, createTar(t, "manifest.json", "{}")), "doesn't contain a disk file"},
+		{"tar with two disks", compressGzip(t, createTar(t, "disk1.raw", diskContent, "disk2.raw", diskContent)), "contains more than one disk file"},
+		{"nested compression", compressGzip(t, compressXz(t, diskContent)), "the decompressed source file is xz"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			objects := map[string]string{"bucket/disk.gz": tt.content}
+			mockLogger := mocks.NewMockLogger(ctrl)
+			mockLogger.EXPECT().User(gomock.Any()).AnyTimes()
+			mockLogger.EXPECT().Metric(gomock.Any()).AnyTimes()
+
+			source := fileSource{gcsPath: "gs://bucket/disk.gz", bucket: "bucket", object: "disk.gz", format: gzipFormat}
+			inflater := newDecompressingInflater(ImageImportRequest{ScratchBucketGcsPath: "gs://bucket/scratch", Source: source},
+				source, newFakeStorageClient(ctrl, objects), mockLogger, func(request ImageImportRequest) (Inflater, error) {
+					t.Fatal("unexpected inflation")
+					return nil, nil
+				})
+
+			_, _, err := inflater.Inflate()
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedError)
+			assert.Len(t, objects, 1, "the unpacked file should be deleted")
+		})
+	}
+}
+
+func TestDecompressingInflater_CancelBeforeUnpacking(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	source := fileSource{gcsPath: "gs://bucket/disk.gz", bucket: "bucket", object: "disk.gz", format: gzipFormat}
+	inflater := newDecompressingInflater(ImageImportRequest{ScratchBucketGcsPath: "gs://bucket/scratch", Source: source},
+		source, newFakeStorageClient(ctrl, map[string]string{"bucket/disk.gz": compressGzip(t, diskContent)}), nil, nil)
+
+	assert.True(t, inflater.Cancel("timed-out"))
+	_, _, err := inflater.Inflate()
+	assert.EqualError(t, err, "inflation was cancelled")
+}
+
+func TestDecompressingInflater_CancelsUnpackedInflater(t *testing.T) {
+	unpackedInflater := &mockInflater{cancelChan: make(chan bool, 1)}
+	inflater := &decompressingInflater{inflater: unpackedInflater}
+	assert.True(t, inflater.Cancel("timed-out"))
+	assert.True(t, <-unpackedInflater.cancelChan)
+}
+
+func TestTrimFormatExtension(t *testing.T) {
+	for name, expected := range map[string]string{
+		"disk.vmdk.gz":  "disk.vmdk",
+		"image.tar.gz":  "image",
+		"image.tgz":     "image",
+		"disk.raw.zst":  "disk.raw",
+		"disk.vhd.xz":   "disk.vhd",
+		"disk.vmdk":     "disk.vmdk",
+		".gz":           ".gz",
+		"gce-image.tar": "gce-image",
+	} {
+		assert.Equal(t, expected, trimFormatExtension(name), name)
+	}
+}
+
+// newFakeStorageClient returns a storage client keeping objects in a map
+// from "<bucket>/<object>" to their content.
+func newFakeStorageClient(ctrl *gomock.Controller, objects map[string]string) *mocks.MockStorageClientInterface {
+	storageClient := mocks.NewMockStorageClientInterface(ctrl)
+	storageClient.EXPECT().GetObject(gomock.Any(), gomock.Any()).DoAndReturn(func(bkt, obj string) *mocks.MockStorageObject {
+		object := mocks.NewMockStorageObject(ctrl)
+		object.EXPECT().NewReader().DoAndReturn(func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(objects[bkt+"/"+obj])), nil
+		}).AnyTimes()
+		return object
+	}).AnyTimes()
+	storageClient.EXPECT().WriteToGCS(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(bkt, obj string, r io.Reader) error {
+		b, err := ioutil.ReadAll(r)
+		objects[bkt+"/"+obj] = string(b)
+		return err
+	}).AnyTimes()
+	storageClient.EXPECT().DeleteObject(gomock.Any()).DoAndReturn(func(gcsPath string) error {
+		delete(objects, strings.TrimPrefix(gcsPath, "gs://"))
+		return nil
+	}).AnyTimes()
+	return storageClient
+}
+
+func compressGzip(t *testing.T, content string) string {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(content))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return buf.String()
+}
+
+func compressXz(t *testing.T, content string) string {
+	var buf bytes.Buffer
+	w, err := xz.NewWriter(&buf)
+	assert.NoError(t, err)
+	_, err = w.Write([]byte(content))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return buf.String()
+}
+
+func compressZstd(t *testing.T, content string) string {
+	var buf bytes.Buffer
+	w, err := zstd.NewWriter(&buf)
+	assert.NoError(t, err)
+	_, err = w.Write([]byte(content))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return buf.String()
+}
+
+// createTar returns a tar archive of files, given as pairs of names and
+// contents.
+func createTar(t *testing.T, files ...string) string {
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for i := 0; i < len(files); i += 2 {
+		assert.NoError(t, w.WriteHeader(&tar.Header{
+			Name:   files[i],
+			Mode:   0600,
+			Size:   int64(len(files[i+1])),
+			Format: tar.FormatGNU,
+		}))
+		_, err := w.Write([]byte(files[i+1]))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, w.Close())
+	return buf.String()
+}
@@ -0,0 +1,195 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/imagefile"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
+)
+
+// ImportPlan describes the resources that an import will use. It's
+// reported by dry runs, in place of creating the image.
+type ImportPlan struct {
+	Source    string `json:"source"`
+	ImageName string `json:"image_name"`
+	DataDisk  bool   `json:"data_disk"`
+
+	// Populated when the import creates a disk or snapshot, rather than an image.
+	Output string `json:"output,omitempty"`
+
+	// Populated when the source is a file.
+	SourceFormat       string `json:"source_format,omitempty"`
+	SourceSizeGb       int64  `json:"source_size_gb,omitempty"`
+	ScratchDiskSizeGb  int64  `json:"scratch_disk_size_gb,omitempty"`
+	InflatedDiskSizeGb int64  `json:"inflated_disk_size_gb,omitempty"`
+
+	// Populated for bootable disks, when the operating system is specified
+	// or detected.
+	Os                      string   `json:"os,omitempty"`
+	DiskInspected           bool     `json:"disk_inspected"`
+	DetectedOs              string   `json:"detected_os,omitempty"`
+	TranslationWorkflowPath string   `json:"translation_workflow_path,omitempty"`
+	Licenses                []string `json:"licenses,omitempty"`
+	GuestOsFeatures         []string `json:"guest_os_features,omitempty"`
+	UefiCompatible          bool     `json:"uefi_compatible"`
+	Architecture            string   `json:"architecture,omitempty"`
+	VerifyBoot              bool     `json:"verify_boot"`
+}
+
+// dryRun holds the dependencies that are used when an import is
+// a dry run.
+type dryRun struct {
+	request       ImageImportRequest
+	fileInspector imagefile.Inspector
+	planner       *defaultPlanner
+}
+
+// runDryRun validates the request and reports an ImportPlan, without creating
+// the image. When DryRunInspectDisk is set, the source is inflated to a
+// temporary disk to run disk inspection, and the disk is deleted afterwards.
+// Local files aren't uploaded: they're inspected in place instead. HTTP(S)
+// sources aren't downloaded, so their format and size aren't reported.
+func (i *importer) runDryRun(ctx context.Context) error {
+	request := i.dryRun.request
+	plan := &ImportPlan{
+		Source:     request.Source.Path(),
+		ImageName:  request.ImageName,
+		DataDisk:   request.DataDisk,
+		Os:         request.OS,
+		VerifyBoot: request.VerifyBoot,
+	}
+	if !request.outputsImage() {
+		plan.Output = request.Output
+	}
+	_, local := request.Source.(localFileSource)
+	_, remote := request.Source.(httpSource)
+	if !isImage(request.Source) && !remote {
+		metadata, err := i.dryRun.fileInspector.Inspect(ctx, request.Source.Path())
+		if err != nil {
+			return fmt.Errorf("failed to inspect %s: %w", request.Source.Path(), err)
+		}
+		plan.SourceFormat = metadata.FileFormat
+		plan.SourceSizeGb = metadata.PhysicalSizeGB
+		plan.ScratchDiskSizeGb = calculateScratchDiskSize(metadata)
+		plan.InflatedDiskSizeGb = calculateInflatedSize(metadata)
+	}
+
+	if !request.DataDisk {
+		var processingPlan *processingPlan
+		var err error
+		switch {
+		case request.DryRunInspectDisk && local:
+			err = i.runStep(ctx, func() error {
+				var err error
+				processingPlan, err = i.dryRun.planner.plan(persistentDisk{uri: request.Source.Path()})
+				return err
+			}, i.dryRun.planner.diskInspector.Cancel)
+			plan.DiskInspected = request.CustomWorkflow == ""
+		case request.DryRunInspectDisk:
+			defer i.deleteDisk()
+			if err = i.runInflate(ctx); err != nil {
+				return err
+			}
+			err = i.runStep(ctx, func() error {
+				var err error
+				processingPlan, err = i.dryRun.planner.plan(i.pd)
+				return err
+			}, i.dryRun.planner.diskInspector.Cancel)
+			plan.DiskInspected = request.CustomWorkflow == ""
+		case request.OS != "" || request.CustomWorkflow != "":
+			processingPlan, err = i.dryRun.planner.planFromInspection(nil, nil)
+		}
+		if err != nil {
+			return err
+		}
+		if processingPlan != nil {
+			plan.addProcessingPlan(processingPlan)
+		}
+	}
+	return reportPlan(plan, i.logger)
+}
+
+func (plan *ImportPlan) addProcessingPlan(processingPlan *processingPlan) {
+	if processingPlan.detectedOs != nil {
+		plan.DetectedOs = processingPlan.detectedOs.AsGcloudArg()
+	}
+	plan.TranslationWorkflowPath = processingPlan.translationWorkflowPath
+	plan.Architecture = processingPlan.architecture
+	plan.Licenses = processingPlan.requiredLicenses
+	for _, feature := range processingPlan.requiredFeatures {
+		plan.GuestOsFeatures = append(plan.GuestOsFeatures, feature.Type)
+		if feature.Type == "UEFI_COMPATIBLE" {
+			plan.UefiCompatible = true
+		}
+	}
+}
+
+// reportPlan writes the plan to the user as text, followed by JSON.
+func reportPlan(plan *ImportPlan, logger logging.Logger) error {
+	encoded, err := json.Marshal(plan)
+	if err != nil {
+		return err
+	}
+	lines := []string{
+		"Dry run: the image was not created. Import plan:",
+		fmt.Sprintf("  Source: %s", plan.Source),
+		fmt.Sprintf("  Image name: %s", plan.ImageName),
+	}
+	if plan.Output != "" {
+		lines = append(lines, fmt.Sprintf("  Output: a %s is created instead of an image", plan.Output))
+	}
+	if plan.SourceFormat != "" {
+		lines = append(lines,
+			fmt.Sprintf("  Source format: %s", plan.SourceFormat),
+			fmt.Sprintf("  Source size: %d GB", plan.SourceSizeGb),
+			fmt.Sprintf("  Scratch disk size: %d GB", plan.ScratchDiskSizeGb),
+			fmt.Sprintf("  Inflated disk size: %d GB", plan.InflatedDiskSizeGb))
+	}
+	switch {
+	case plan.DataDisk:
+		lines = append(lines, "  Data disk: translation is skipped")
+	case plan.TranslationWorkflowPath == "":
+		lines = append(lines, "  Operating system: detected during import. "+
+			"Specify -os, or use -dry_run_inspect_disk, to include translation in the plan")
+	default:
+		if plan.Os != "" {
+			lines = append(lines, fmt.Sprintf("  Operating system: %s", plan.Os))
+		}
+		if plan.DetectedOs != "" {
+			lines = append(lines, fmt.Sprintf("  Detected operating system: %s", plan.DetectedOs))
+		}
+		lines = append(lines,
+			fmt.Sprintf("  Translation workflow: %s", plan.TranslationWorkflowPath),
+			fmt.Sprintf("  Licenses: %s", strings.Join(plan.Licenses, ", ")),
+			fmt.Sprintf("  Guest OS features: %s", strings.Join(plan.GuestOsFeatures, ", ")),
+			fmt.Sprintf("  UEFI: %t", plan.UefiCompatible))
+		if plan.Architecture != "" {
+			lines = append(lines, fmt.Sprintf("  Architecture: %s", plan.Architecture))
+		}
+	}
+	if plan.VerifyBoot {
+		lines = append(lines, "  Boot verification: a temporary instance is booted from the image")
+	}
+	for _, line := range lines {
+		logger.User(line)
+	}
+	logger.User("Import plan JSON: " + string(encoded))
+	return nil
+}
@@ -0,0 +1,255 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	mock_disk "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/disk/mocks"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/imagefile"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
+	"github.com/GoogleCloudPlatform/compute-image-tools/proto/go/pb"
+)
+
+func TestRunDryRun_ReportsPlan_WhenOSIsSpecified(t *testing.T) {
+	request := ImageImportRequest{
+		ImageName:   "image",
+		OS:          "centos-7",
+		Source:      fileSource{gcsPath: "gs://bucket/disk.vmdk"},
+		WorkflowDir: "workflowroot",
+		DryRun:      true,
+	}
+	inflater := &mockInflater{}
+	logger := logging.NewToolLogger(t.Name())
+	importer := newDryRunImporter(t, request, inflater, nil, logger)
+
+	assert.NoError(t, importer.Run(context.Background()))
+	assert.Equal(t, 0, inflater.interactions)
+	assert.Equal(t, &ImportPlan{
+		Source:                  "gs://bucket/disk.vmdk",
+		ImageName:               "image",
+		SourceFormat:            "vmdk",
+		SourceSizeGb:            20,
+		ScratchDiskSizeGb:       23,
+		InflatedDiskSizeGb:      50,
+		Os:                      "centos-7",
+		TranslationWorkflowPath: "workflowroot/image_import/enterprise_linux/translate_centos_7.wf.json",
+		Licenses:                []string{"projects/centos-cloud/global/licenses/centos-7"},
+	}, readReportedPlan(t, logger.ReadOutputInfo().SerialOutputs))
+}
+
+func TestRunDryRun_DoesntTranslate_WhenOSIsUnknown(t *testing.T) {
+	request := ImageImportRequest{
+		ImageName: "image",
+		Source:    imageSource{uri: "global/images/source"},
+		DryRun:    true,
+	}
+	logger := logging.NewToolLogger(t.Name())
+	importer := newDryRunImporter(t, request, &mockInflater{}, nil, logger)
+
+	assert.NoError(t, importer.Run(context.Background()))
+	logs := logger.ReadOutputInfo().SerialOutputs
+	assert.Equal(t, &ImportPlan{
+		Source:    "global/images/source",
+		ImageName: "image",
+	}, readReportedPlan(t, logs))
+	assert.Contains(t, strings.Join(logs, "\n"), "Specify -os, or use -dry_run_inspect_disk")
+}
+
+func TestRunDryRun_SkipsTranslation_ForDataDisk(t *testing.T) {
+	request := ImageImportRequest{
+		ImageName: "image",
+		DataDisk:  true,
+		Source:    imageSource{uri: "global/images/source"},
+		DryRun:    true,
+	}
+	logger := logging.NewToolLogger(t.Name())
+	importer := newDryRunImporter(t, request, &mockInflater{}, nil, logger)
+
+	assert.NoError(t, importer.Run(context.Background()))
+	assert.Equal(t, &ImportPlan{
+		Source:    "global/images/source",
+		ImageName: "image",
+		DataDisk:  true,
+	}, readReportedPlan(t, logger.ReadOutputInfo().SerialOutputs))
+}
+
+func TestRunDryRun_ReportsOutput_WhenOutputIsSnapshot(t *testing.T) {
+	request := ImageImportRequest{
+		ImageName: "snapshot",
+		DataDisk:  true,
+		Source:    imageSource{uri: "global/images/source"},
+		DryRun:    true,
+		Output:    OutputSnapshot,
+	}
+	logger := logging.NewToolLogger(t.Name())
+	importer := newDryRunImporter(t, request, &mockInflater{}, nil, logger)
+
+	assert.NoError(t, importer.Run(context.Background()))
+	logs := logger.ReadOutputInfo().SerialOutputs
+	assert.Equal(t, &ImportPlan{
+		Source:    "global/images/source",
+		ImageName: "snapshot",
+		DataDisk:  true,
+		Output:    "snapshot",
+	}, readReportedPlan(t, logs))
+	assert.Contains(t, strings.Join(logs, "\n"), "Output: a snapshot is created instead of an image")
+}
+
+func TestRunDryRun_InspectsAndDeletesDisk_WhenInspectDiskIsSet(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	pd := persistentDisk{uri: "disk/uri"}
+	mockInspector := mock_disk.NewMockInspector(mockCtrl)
+	mockInspector.EXPECT().Inspect(pd.uri).Return(&pb.InspectionResults{
+		OsCount:   1,
+		OsRelease: &pb.OsRelease{CliFormatted: "centos-7", DistroId: pb.Distro_CENTOS, MajorVersion: "7"},
+	}, nil)
+	request := ImageImportRequest{
+		ImageName:         "image",
+		Source:            imageSource{uri: "global/images/source"},
+		WorkflowDir:       "workflowroot",
+		DryRun:            true,
+		DryRunInspectDisk: true,
+	}
+	inflater := &mockInflater{pd: pd}
+	logger := logging.NewToolLogger(t.Name())
+	importer := newDryRunImporter(t, request, inflater, mockInspector, logger)
+	diskClient := &mockDiskClient{}
+	importer.diskClient = diskClient
+
+	assert.NoError(t, importer.Run(context.Background()))
+	assert.Equal(t, 1, inflater.interactions)
+	assert.Equal(t, 1, diskClient.interactions)
+	assert.Equal(t, "uri", diskClient.uri)
+	plan := readReportedPlan(t, logger.ReadOutputInfo().SerialOutputs)
+	assert.True(t, plan.DiskInspected)
+	assert.Equal(t, "centos-7", plan.DetectedOs)
+	assert.Equal(t, "workflowroot/image_import/enterprise_linux/translate_centos_7.wf.json", plan.TranslationWorkflowPath)
+}
+
+func TestRunDryRun_InspectsLocalFileInPlace_WhenInspectDiskIsSet(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockInspector := mock_disk.NewMockInspector(mockCtrl)
+	mockInspector.EXPECT().Inspect("/images/disk.vmdk").Return(&pb.InspectionResults{
+		OsCount:   1,
+		OsRelease: &pb.OsRelease{CliFormatted: "centos-7", DistroId: pb.Distro_CENTOS, MajorVersion: "7"},
+	}, nil)
+	request := ImageImportRequest{
+		ImageName:         "image",
+		Source:            localFileSource{path: "/images/disk.vmdk"},
+		WorkflowDir:       "workflowroot",
+		DryRun:            true,
+		DryRunInspectDisk: true,
+	}
+	inflater := &mockInflater{}
+	logger := logging.NewToolLogger(t.Name())
+	importer := newDryRunImporter(t, request, inflater, mockInspector, logger)
+	diskClient := &mockDiskClient{}
+	importer.diskClient = diskClient
+
+	assert.NoError(t, importer.Run(context.Background()))
+	assert.Equal(t, 0, inflater.interactions)
+	assert.Equal(t, 0, diskClient.interactions)
+	plan := readReportedPlan(t, logger.ReadOutputInfo().SerialOutputs)
+	assert.Equal(t, "vmdk", plan.SourceFormat)
+	assert.True(t, plan.DiskInspected)
+	assert.Equal(t, "centos-7", plan.DetectedOs)
+	assert.Equal(t, "workflowroot/image_import/enterprise_linux/translate_centos_7.wf.json", plan.TranslationWorkflowPath)
+}
+
+func TestRunDryRun_DoesntInspectHTTPSource(t *testing.T) {
+	request := ImageImportRequest{
+		ImageName: "image",
+		Source:    httpSource{url: "https://example.com/disk.vmdk"},
+		DryRun:    true,
+	}
+	logger := logging.NewToolLogger(t.Name())
+	importer := newDryRunImporter(t, request, &mockInflater{}, nil, logger)
+	importer.dryRun.fileInspector = mockInspector{
+		t:             t,
+		errorToReturn: errors.New("HTTP(S) sources shouldn't be inspected"),
+	}
+
+	assert.NoError(t, importer.Run(context.Background()))
+	assert.Equal(t, &ImportPlan{
+		Source:    "https://example.com/disk.vmdk",
+		ImageName: "image",
+	}, readReportedPlan(t, logger.ReadOutputInfo().SerialOutputs))
+}
+
+func TestRunDryRun_ReturnsError_WhenFileInspectionFails(t *testing.T) {
+	request := ImageImportRequest{
+		ImageName: "image",
+		Source:    fileSource{gcsPath: "gs://bucket/disk.vmdk"},
+		DryRun:    true,
+	}
+	importer := newDryRunImporter(t, request, &mockInflater{}, nil, logging.NewToolLogger(t.Name()))
+	importer.dryRun.fileInspector = mockInspector{
+		t:                 t,
+		expectedReference: "gs://bucket/disk.vmdk",
+		errorToReturn:     errors.New("not a disk image"),
+	}
+
+	assert.EqualError(t, importer.Run(context.Background()),
+		"failed to inspect gs://bucket/disk.vmdk: not a disk image")
+}
+
+func newDryRunImporter(t *testing.T, request ImageImportRequest, inflater *mockInflater,
+	diskInspector *mock_disk.MockInspector, logger logging.Logger) *importer {
+	planner := &defaultPlanner{request: request, logger: logger}
+	if diskInspector != nil {
+		planner.diskInspector = diskInspector
+	}
+	return &importer{
+		preValidator: mockValidator{},
+		inflater:     inflater,
+		logger:       logger,
+		dryRun: &dryRun{
+			request: request,
+			fileInspector: mockInspector{
+				t:                 t,
+				expectedReference: request.Source.Path(),
+				metaToReturn: imagefile.Metadata{
+					FileFormat:     "vmdk",
+					PhysicalSizeGB: 20,
+					VirtualSizeGB:  50,
+				},
+			},
+			planner: planner,
+		},
+	}
+}
+
+// readReportedPlan parses the JSON plan that was written to the user.
+func readReportedPlan(t *testing.T, logs []string) *ImportPlan {
+	for _, line := range logs {
+		if i := strings.Index(line, "Import plan JSON: "); i >= 0 {
+			var plan ImportPlan
+			assert.NoError(t, json.Unmarshal([]byte(line[i+len("Import plan JSON: "):]), &plan))
+			return &plan
+		}
+	}
+	t.Fatal("Import plan wasn't reported")
+	return nil
+}
@@ -46,15 +46,30 @@ func NewImporter(request ImageImportRequest, computeClient compute.Client, stora
 		return nil, err
 	}
 
-	inflater, err := newInflater(request, computeClient, storageClient, imagefile.NewGCSInspector(), logger)
-	if err != nil {
-		return nil, err
+	fileInspector := imagefile.NewGCSInspector()
+	// Dry runs don't stage local and HTTP(S) sources, so they can't be inflated.
+	var inflater Inflater
+	if _, unstaged := request.Source.(StagedSource); !request.DryRun || !unstaged {
+		var err error
+		inflater, err = newInflater(request, computeClient, storageClient, fileInspector, logger)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	inspector, err := disk.NewInspector(request.EnvironmentSettings(), logger)
 	if err != nil {
 		return nil, err
 	}
+	var dr *dryRun
+	if request.DryRun {
+		dr = &dryRun{request, fileInspector, &defaultPlanner{request, inspector, logger}}
+		if _, local := request.Source.(localFileSource); local {
+			// Local files are inspected in place, rather than uploaded and inflated.
+			dr.fileInspector = imagefile.NewLocalInspector()
+			dr.planner = &defaultPlanner{request, disk.NewOfflineInspector(logger), logger}
+		}
+	}
 	return &importer{
 		project:      request.Project,
 		zone:         request.Zone,
@@ -67,8 +82,10 @@ func NewImporter(request ImageImportRequest, computeClient compute.Client, stora
 			newProcessPlanner(request, inspector, logger),
 			logger,
 		},
-		diskClient: computeClient,
-		logger:     logger,
+		diskClient:  computeClient,
+		logger:      logger,
+		outputsDisk: request.Output == OutputDisk,
+		dryRun:      dr,
 	}, nil
 }
 
@@ -83,6 +100,12 @@ type importer struct {
 	diskClient        diskClient
 	logger            logging.Logger
 	timeout           time.Duration
+
+	// outputsDisk is set when the import creates a disk, rather than an image.
+	outputsDisk bool
+
+	// dryRun is set when the import is a dry run.
+	dryRun *dryRun
 }
 
 func (i *importer) Run(ctx context.Context) error {
@@ -95,6 +118,10 @@ func (i *importer) Run(ctx context.Context) error {
 		return err
 	}
 
+	if i.dryRun != nil {
+		return i.runDryRun(ctx)
+	}
+
 	defer i.deleteDisk()
 
 	if err := i.runInflate(ctx); err != nil {
@@ -106,10 +133,15 @@ func (i *importer) Run(ctx context.Context) error {
 		return err
 	}
 
+	// The processed disk is kept when it's the import's output.
+	if i.outputsDisk {
+		i.pd = persistentDisk{}
+	}
 	return err
 }
 
 func (i *importer) runInflate(ctx context.Context) (err error) {
+	logging.ReportPhase(i.logger, logging.PhaseInflate)
 	return i.runStep(ctx, func() error {
 		var err error
 		i.pd, _, err = i.inflater.Inflate()
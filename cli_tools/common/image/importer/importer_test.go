@@ -30,6 +30,7 @@ import (
 	"google.golang.org/api/compute/v1"
 	"google.golang.org/api/googleapi"
 
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/mocks"
 	"github.com/GoogleCloudPlatform/compute-image-tools/proto/go/pb"
 )
@@ -65,6 +66,20 @@ func TestRun_HappyCase_CollectDiskMetrics(t *testing.T) {
 	assert.Equal(t, 1, mockProcessor.interactions)
 }
 
+func TestRun_ReportsInflatePhase(t *testing.T) {
+	logger := logging.NewToolLogger(t.Name())
+	var events bytes.Buffer
+	logger.SetProgressOutput(&events)
+	importer := importer{
+		preValidator:      mockValidator{},
+		inflater:          &mockInflater{},
+		processorProvider: &mockProcessorProvider{},
+		logger:            logger,
+	}
+	assert.NoError(t, importer.Run(context.Background()))
+	assert.Contains(t, events.String(), `"phase":"inflate"}`)
+}
+
 func TestRun_DeletesDisk_AfterImportingImage(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -101,6 +116,53 @@ func TestRun_DeletesDisk_AfterImportingImage(t *testing.T) {
 	assert.Equal(t, diskURI, mockDiskClient.uri)
 }
 
+func TestRun_KeepsDisk_WhenOutputIsDisk(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDiskClient := mockDiskClient{}
+
+	importer := importer{
+		project:      "project",
+		zone:         "zone",
+		diskClient:   &mockDiskClient,
+		preValidator: mockValidator{},
+		inflater: &mockInflater{
+			pd: persistentDisk{uri: "zones/zone/disks/disk-1234"},
+		},
+		processorProvider: &mockProcessorProvider{
+			processors: []processor{&mockProcessor{}},
+		},
+		logger:      mocks.NewMockLogger(ctrl),
+		outputsDisk: true,
+	}
+	assert.NoError(t, importer.Run(context.Background()))
+	assert.Equal(t, 0, mockDiskClient.interactions)
+}
+
+func TestRun_DeletesDisk_WhenOutputIsDiskAndProcessingFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDiskClient := mockDiskClient{}
+
+	importer := importer{
+		project:      "project",
+		zone:         "zone",
+		diskClient:   &mockDiskClient,
+		preValidator: mockValidator{},
+		inflater: &mockInflater{
+			pd: persistentDisk{uri: "zones/zone/disks/disk-1234"},
+		},
+		processorProvider: &mockProcessorProvider{
+			processors: []processor{&mockProcessor{err: errors.New("translation failed")}},
+		},
+		logger:      mocks.NewMockLogger(ctrl),
+		outputsDisk: true,
+	}
+	assert.EqualError(t, importer.Run(context.Background()), "translation failed")
+	assert.Equal(t, 1, mockDiskClient.interactions)
+	assert.Equal(t, "disk-1234", mockDiskClient.uri)
+}
+
 func TestRun_NoErrorLoggedWhenDeletingDiskThatWasNotCreated(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
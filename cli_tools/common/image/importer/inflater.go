@@ -47,6 +47,15 @@ type shadowTestFields struct {
 func newInflater(request ImageImportRequest, computeClient daisyCompute.Client, storageClient domain.StorageClientInterface,
 	inspector imagefile.Inspector, logger logging.Logger) (Inflater, error) {
 
+	// The inflaters are created for the unpacked file, since they inspect the
+	// source file when they're created.
+	if source, ok := request.Source.(fileSource); ok && source.needsUnpacking() {
+		return newDecompressingInflater(request, source, storageClient, logger,
+			func(request ImageImportRequest) (Inflater, error) {
+				return newInflater(request, computeClient, storageClient, inspector, logger)
+			}), nil
+	}
+
 	di, err := newDaisyInflater(request, inspector, logger)
 	if err != nil {
 		return nil, err
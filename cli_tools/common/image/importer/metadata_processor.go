@@ -33,6 +33,9 @@ type metadataProcessor struct {
 
 	requiredLicenses []string
 	requiredFeatures []*compute.GuestOsFeature
+
+	// encryptionKey encrypts the new disk, when it's created.
+	encryptionKey *compute.CustomerEncryptionKey
 }
 
 func newMetadataProcessor(
@@ -84,8 +87,9 @@ func (p *metadataProcessor) stageRequestForNewDisk(pd persistentDisk) (newDisk *
 
 	newDiskName := fmt.Sprintf("%v-1", diskName)
 	newDisk = &compute.Disk{
-		Name:       newDiskName,
-		SourceDisk: pd.uri,
+		Name:              newDiskName,
+		SourceDisk:        pd.uri,
+		DiskEncryptionKey: p.encryptionKey,
 	}
 	if len(currentDisk.GuestOsFeatures) > 0 {
 		newDisk.GuestOsFeatures = make([]*compute.GuestOsFeature, len(currentDisk.GuestOsFeatures))
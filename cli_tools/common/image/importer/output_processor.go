@@ -0,0 +1,106 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package importer
+
+import (
+	"fmt"
+
+	"google.golang.org/api/compute/v1"
+
+	daisyUtils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/daisy"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
+	"github.com/GoogleCloudPlatform/compute-image-tools/daisy"
+	daisyCompute "github.com/GoogleCloudPlatform/compute-image-tools/daisy/compute"
+)
+
+// outputProcessor creates the disk or snapshot that's requested by
+// ImageImportRequest.Output, in place of an image. A snapshot is taken of the
+// processed disk. A disk is created before translation, as a clone of the
+// inflated disk with the required licenses and guest OS features, and the
+// inflated disk is deleted. The translated disk is then kept as the output.
+type outputProcessor struct {
+	request ImageImportRequest
+	client  daisyCompute.Client
+	logger  logging.Logger
+
+	requiredLicenses []string
+	requiredFeatures []*compute.GuestOsFeature
+}
+
+func newOutputProcessor(request ImageImportRequest, client daisyCompute.Client, logger logging.Logger) *outputProcessor {
+	return &outputProcessor{request: request, client: client, logger: logger}
+}
+
+func (o *outputProcessor) process(pd persistentDisk) (persistentDisk, error) {
+	diskName := daisyUtils.GetResourceID(pd.uri)
+	logging.ReportPhase(o.logger, logging.PhaseCreateOutput)
+
+	if o.request.Output == OutputSnapshot {
+		o.logger.User(fmt.Sprintf("Creating snapshot %q", o.request.ImageName))
+		snapshot := &compute.Snapshot{
+			Name:                  o.request.ImageName,
+			Description:           o.request.Description,
+			Labels:                o.request.outputLabels(),
+			SnapshotEncryptionKey: o.request.encryptionKey(),
+		}
+		if o.request.StorageLocation != "" {
+			snapshot.StorageLocations = []string{o.request.StorageLocation}
+		}
+		if err := o.client.CreateSnapshot(o.request.Project, o.request.Zone, diskName, snapshot); err != nil {
+			return pd, daisy.Errf("Failed to create snapshot: %v", err)
+		}
+		return pd, nil
+	}
+
+	// Licenses and guest OS features can't be added to an existing disk, so
+	// they're added to the clone, as in metadataProcessor.
+	currentDisk, err := o.client.GetDisk(o.request.Project, o.request.Zone, diskName)
+	if err != nil {
+		return pd, daisy.Errf("Failed to get disk: %v", err)
+	}
+	newDisk := &compute.Disk{
+		Name:              o.request.ImageName,
+		Description:       o.request.Description,
+		Labels:            o.request.outputLabels(),
+		SourceDisk:        pd.uri,
+		Type:              currentDisk.Type,
+		Licenses:          currentDisk.Licenses,
+		GuestOsFeatures:   currentDisk.GuestOsFeatures,
+		DiskEncryptionKey: o.request.encryptionKey(),
+	}
+	for _, license := range o.requiredLicenses {
+		if !hasLicense(currentDisk, license) {
+			newDisk.Licenses = append(newDisk.Licenses, license)
+		}
+	}
+	for _, feature := range o.requiredFeatures {
+		if !hasGuestOSFeature(currentDisk, feature) {
+			newDisk.GuestOsFeatures = append(newDisk.GuestOsFeatures, feature)
+		}
+	}
+	o.logger.User(fmt.Sprintf("Creating disk %q in %s", o.request.ImageName, o.request.Zone))
+	if err := o.client.CreateDisk(o.request.Project, o.request.Zone, newDisk); err != nil {
+		return pd, daisy.Errf("Failed to create disk: %v", err)
+	}
+
+	deleteDisk(o.client, o.request.Project, o.request.Zone, pd)
+	pd.uri = fmt.Sprintf("zones/%v/disks/%v", o.request.Zone, newDisk.Name)
+	return pd, nil
+}
+
+func (o *outputProcessor) cancel(reason string) bool {
+	// Cancel is not performed since there is only one critical API call.
+	return false
+}
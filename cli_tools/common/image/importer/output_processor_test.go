@@ -0,0 +1,122 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package importer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/compute/v1"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/mocks"
+)
+
+func Test_OutputProcessor_ClonesDiskWithLicensesAndFeatures(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockClient := mocks.NewMockClient(mockCtrl)
+	mockClient.EXPECT().GetDisk("project", "us-west1-a", "disk-1234").Return(&compute.Disk{
+		Type:            "zones/us-west1-a/diskTypes/pd-ssd",
+		Licenses:        []string{"projects/compute-image-tools/global/licenses/virtual-disk-import"},
+		GuestOsFeatures: []*compute.GuestOsFeature{{Type: "UEFI_COMPATIBLE"}},
+	}, nil)
+	mockClient.EXPECT().CreateDisk("project", "us-west1-a", &compute.Disk{
+		Name:        "vm-boot",
+		Description: "description",
+		Labels:      map[string]string{"gce-image-import": "true", "user-key": "user-value"},
+		SourceDisk:  "zones/us-west1-a/disks/disk-1234",
+		Type:        "zones/us-west1-a/diskTypes/pd-ssd",
+		Licenses: []string{"projects/compute-image-tools/global/licenses/virtual-disk-import",
+			"projects/centos-cloud/global/licenses/centos-7"},
+		GuestOsFeatures: []*compute.GuestOsFeature{{Type: "UEFI_COMPATIBLE"}},
+	}).Return(nil)
+	mockClient.EXPECT().DeleteDisk("project", "us-west1-a", "disk-1234").Return(nil)
+
+	processor := newOutputProcessor(ImageImportRequest{
+		Project:     "project",
+		Zone:        "us-west1-a",
+		ImageName:   "vm-boot",
+		Description: "description",
+		Labels:      map[string]string{"user-key": "user-value"},
+		Output:      OutputDisk,
+	}, mockClient, logging.NewToolLogger(t.Name()))
+	processor.requiredLicenses = []string{"projects/centos-cloud/global/licenses/centos-7"}
+	processor.requiredFeatures = []*compute.GuestOsFeature{{Type: "UEFI_COMPATIBLE"}}
+	actual, err := processor.process(persistentDisk{uri: "zones/us-west1-a/disks/disk-1234", sizeGb: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, persistentDisk{uri: "zones/us-west1-a/disks/vm-boot", sizeGb: 10}, actual)
+}
+
+func Test_OutputProcessor_CreatesSnapshot(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockClient := mocks.NewMockClient(mockCtrl)
+	mockClient.EXPECT().CreateSnapshot("project", "us-west1-a", "disk-1234", &compute.Snapshot{
+		Name:             "vm-boot",
+		Labels:           map[string]string{"gce-image-import": "true"},
+		StorageLocations: []string{"us"},
+	}).Return(nil)
+
+	processor := newOutputProcessor(ImageImportRequest{
+		Project:         "project",
+		Zone:            "us-west1-a",
+		ImageName:       "vm-boot",
+		StorageLocation: "us",
+		Output:          OutputSnapshot,
+	}, mockClient, logging.NewToolLogger(t.Name()))
+	_, err := processor.process(persistentDisk{uri: "zones/us-west1-a/disks/disk-1234"})
+	assert.NoError(t, err)
+}
+
+func Test_OutputProcessor_EncryptsSnapshotWithKmsKey(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockClient := mocks.NewMockClient(mockCtrl)
+	mockClient.EXPECT().CreateSnapshot("project", "us-west1-a", "disk-1234", &compute.Snapshot{
+		Name:                  "vm-boot",
+		Labels:                map[string]string{"gce-image-import": "true"},
+		SnapshotEncryptionKey: &compute.CustomerEncryptionKey{KmsKeyName: "projects/p/locations/l/keyRings/r/cryptoKeys/k"},
+	}).Return(nil)
+
+	processor := newOutputProcessor(ImageImportRequest{
+		Project:   "project",
+		Zone:      "us-west1-a",
+		ImageName: "vm-boot",
+		Output:    OutputSnapshot,
+		KmsKey:    "projects/p/locations/l/keyRings/r/cryptoKeys/k",
+	}, mockClient, logging.NewToolLogger(t.Name()))
+	_, err := processor.process(persistentDisk{uri: "zones/us-west1-a/disks/disk-1234"})
+	assert.NoError(t, err)
+}
+
+func Test_OutputProcessor_ReturnsCreationError(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockClient := mocks.NewMockClient(mockCtrl)
+	mockClient.EXPECT().CreateSnapshot("project", "us-west1-a", "disk-1234", gomock.Any()).Return(
+		errors.New("quota exceeded"))
+
+	processor := newOutputProcessor(ImageImportRequest{
+		Project:   "project",
+		Zone:      "us-west1-a",
+		ImageName: "vm-boot",
+		Output:    OutputSnapshot,
+	}, mockClient, logging.NewToolLogger(t.Name()))
+	_, err := processor.process(persistentDisk{uri: "zones/us-west1-a/disks/disk-1234"})
+	assert.EqualError(t, err, "Failed to create snapshot: quota exceeded")
+}
@@ -49,6 +49,9 @@ type processingPlan struct {
 	requiredFeatures        []*compute.GuestOsFeature
 	translationWorkflowPath string
 	detectedOs              distro.Release
+	// architecture is daisy_utils.ArchitectureARM64 when the disk is translated
+	// on arm64 workers, and empty otherwise.
+	architecture string
 }
 
 // metadataChangesRequired returns whether metadata needs to be updated on the
@@ -57,6 +60,16 @@ func (plan *processingPlan) metadataChangesRequired() bool {
 	return len(plan.requiredLicenses) > 0 || len(plan.requiredFeatures) > 0
 }
 
+// isWindows returns whether the disk will be translated as Windows.
+func (plan *processingPlan) isWindows() bool {
+	for _, feature := range plan.requiredFeatures {
+		if feature.Type == "WINDOWS" {
+			return true
+		}
+	}
+	return false
+}
+
 type defaultPlanner struct {
 	request       ImageImportRequest
 	diskInspector disk.Inspector
@@ -69,11 +82,27 @@ func (p *defaultPlanner) plan(pd persistentDisk) (*processingPlan, error) {
 		return &processingPlan{translationWorkflowPath: p.request.CustomWorkflow}, nil
 	}
 
+	logging.ReportPhase(p.logger, logging.PhaseInspect)
 	inspectionResults, inspectionError := p.inspectDisk(pd.uri)
+	return p.planFromInspection(inspectionResults, inspectionError)
+}
+
+// planFromInspection creates a plan using the user's request, and the results
+// of disk inspection. inspectionResults may be nil, in which case the plan is
+// created using the request alone.
+func (p *defaultPlanner) planFromInspection(inspectionResults *pb.InspectionResults, inspectionError error) (*processingPlan, error) {
+	if p.request.CustomWorkflow != "" {
+		return &processingPlan{translationWorkflowPath: p.request.CustomWorkflow}, nil
+	}
+
 	var detectedOs distro.Release
 	osID := p.request.OS
 	requiresUEFI := p.request.UefiCompatible
 	if inspectionError == nil && inspectionResults != nil {
+		if err := checkEncryption(inspectionResults); err != nil {
+			return nil, err
+		}
+		p.warnAboutGuest(inspectionResults)
 		if inspectionResults.GetOsCount() == 1 && inspectionResults.GetOsRelease() != nil {
 			detectedOs, _ = distro.FromGcloudOSArgument(inspectionResults.GetOsRelease().CliFormatted)
 		}
@@ -104,6 +133,11 @@ func (p *defaultPlanner) plan(pd persistentDisk) (*processingPlan, error) {
 		return nil, err
 	}
 
+	// arm64 instances only boot with UEFI.
+	if settings.Architecture == daisy_utils.ArchitectureARM64 {
+		requiresUEFI = true
+	}
+
 	var requiredGuestOSFeatures []*compute.GuestOsFeature
 	if strings.Contains(osID, "windows") {
 		requiredGuestOSFeatures = append(requiredGuestOSFeatures, &compute.GuestOsFeature{Type: "WINDOWS"})
@@ -112,14 +146,81 @@ func (p *defaultPlanner) plan(pd persistentDisk) (*processingPlan, error) {
 		requiredGuestOSFeatures = append(requiredGuestOSFeatures, &compute.GuestOsFeature{Type: "UEFI_COMPATIBLE"})
 	}
 
+	var requiredLicenses []string
+	if settings.LicenseURI != "" {
+		requiredLicenses = append(requiredLicenses, settings.LicenseURI)
+	}
+
 	return &processingPlan{
-		requiredLicenses:        []string{settings.LicenseURI},
+		requiredLicenses:        requiredLicenses,
 		requiredFeatures:        requiredGuestOSFeatures,
 		translationWorkflowPath: path.Join(p.request.WorkflowDir, "image_import", settings.WorkflowPath),
 		detectedOs:              detectedOs,
+		architecture:            settings.Architecture,
 	}, nil
 }
 
+// decryptionHints describe how to disable each type of encryption.
+var decryptionHints = map[pb.InspectionResults_Encryption]string{
+	pb.InspectionResults_LUKS:      "For LUKS, run `cryptsetup reencrypt --decrypt` against the volume.",
+	pb.InspectionResults_BITLOCKER: "For BitLocker, run `manage-bde -off` against the volume.",
+}
+
+// checkEncryption returns an error when an operating system wasn't found,
+// and the disk has encrypted volumes. In that case, the root filesystem is
+// most likely encrypted, which isn't supported by import.
+func checkEncryption(ir *pb.InspectionResults) error {
+	if ir.GetOsCount() > 0 || len(ir.GetEncryptedVolumes()) == 0 {
+		return nil
+	}
+	var hints []string
+	seen := map[pb.InspectionResults_Encryption]bool{}
+	for _, e := range ir.GetEncryptedVolumes() {
+		if hint, found := decryptionHints[e]; found && !seen[e] {
+			hints = append(hints, hint)
+		}
+		seen[e] = true
+	}
+	return fmt.Errorf("The disk has %d encrypted volume(s), and an operating system wasn't found on its unencrypted volumes. "+
+		"Importing a disk with an encrypted root filesystem isn't supported. Disable encryption in the guest, and then re-import. %s",
+		len(ir.GetEncryptedVolumes()), strings.Join(hints, " "))
+}
+
+// warnAboutGuest notifies the user about properties of the guest that
+// commonly cause translation or boot failures.
+func (p *defaultPlanner) warnAboutGuest(ir *pb.InspectionResults) {
+	if ir.GetOsCount() != 1 {
+		return
+	}
+	if len(ir.GetEncryptedVolumes()) > 0 {
+		p.logger.User(fmt.Sprintf("The disk has %d encrypted volume(s). They will be imported without modification, "+
+			"and will need to be unlocked on Compute Engine.", len(ir.GetEncryptedVolumes())))
+	}
+	if len(ir.GetKernelVersions()) > 0 && !contains(ir.GetVirtioDrivers(), "virtio_scsi") {
+		p.logger.User(fmt.Sprintf("The virtio_scsi driver wasn't found for all installed kernels (%s). "+
+			"It's required to boot on Compute Engine. If the imported image doesn't boot, "+
+			"install a kernel that includes virtio_scsi, and then re-import.", strings.Join(ir.GetKernelVersions(), ", ")))
+	}
+	if ir.GetRootOnLvm() {
+		p.logger.User("The root filesystem is on an LVM logical volume. If the imported image doesn't boot, " +
+			"ensure the initramfs includes LVM support, and then re-import.")
+	}
+	// Translation installs and configures cloud-init for Ubuntu.
+	if ir.GetCloudInitInstalled() && ir.GetOsRelease().GetDistroId() != pb.Distro_UBUNTU {
+		p.logger.User("cloud-init is installed. Its configuration may conflict with the Compute Engine guest environment. " +
+			"If the imported image doesn't start as expected, remove cloud-init or configure its GCE datasource, and then re-import.")
+	}
+}
+
+func contains(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *defaultPlanner) inspectDisk(uri string) (*pb.InspectionResults, error) {
 	p.logger.User("Inspecting disk for OS and bootloader")
 	ir, err := p.diskInspector.Inspect(uri)
@@ -16,6 +16,7 @@ package importer
 
 import (
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/golang/mock/gomock"
@@ -190,6 +191,35 @@ func Test_DefaultPlanner_Plan_InspectionSucceeds(t *testing.T) {
 			},
 			expectErrorToContain: "lease re-import with the operating system specified",
 		},
+		{
+			name: "Fail when an OS isn't found, and the disk has encrypted volumes, even when OS is provided.",
+			request: ImageImportRequest{
+				OS:          "debian-8",
+				WorkflowDir: "workflowroot",
+			},
+			inspectionResults: &pb.InspectionResults{
+				EncryptedVolumes: []pb.InspectionResults_Encryption{pb.InspectionResults_LUKS, pb.InspectionResults_LUKS},
+			},
+			expectErrorToContain: "The disk has 2 encrypted volume\\(s\\).*For LUKS, run `cryptsetup reencrypt --decrypt` against the volume.$",
+		},
+		{
+			name: "Allow encrypted volumes when an OS is found on an unencrypted volume.",
+			request: ImageImportRequest{
+				WorkflowDir: "workflowroot",
+			},
+			inspectionResults: &pb.InspectionResults{
+				OsCount: 1,
+				OsRelease: &pb.OsRelease{
+					CliFormatted: "centos-7",
+				},
+				EncryptedVolumes: []pb.InspectionResults_Encryption{pb.InspectionResults_LUKS},
+			},
+			expectedResults: &processingPlan{
+				requiredLicenses:        []string{"projects/centos-cloud/global/licenses/centos-7"},
+				translationWorkflowPath: "workflowroot/image_import/enterprise_linux/translate_centos_7.wf.json",
+				detectedOs:              distro.FromGcloudOSArgumentMustParse("centos-7"),
+			},
+		},
 		{
 			name: "Use provided UEFI argument, even when inspection shows UEFI is not supported.",
 			request: ImageImportRequest{
@@ -206,6 +236,43 @@ func Test_DefaultPlanner_Plan_InspectionSucceeds(t *testing.T) {
 				requiredFeatures:        []*compute.GuestOsFeature{{Type: "UEFI_COMPATIBLE"}},
 			},
 		},
+		{
+			name: "Translate on arm64 workers with UEFI when an arm64 OS is detected.",
+			request: ImageImportRequest{
+				WorkflowDir: "workflowroot",
+			},
+			inspectionResults: &pb.InspectionResults{
+				OsCount: 1,
+				OsRelease: &pb.OsRelease{
+					CliFormatted: "ubuntu-2004-arm64",
+				},
+				BiosBootable: true,
+			},
+			expectedResults: &processingPlan{
+				requiredLicenses:        []string{"projects/ubuntu-os-cloud/global/licenses/ubuntu-2004-lts"},
+				requiredFeatures:        []*compute.GuestOsFeature{{Type: "UEFI_COMPATIBLE"}},
+				translationWorkflowPath: "workflowroot/image_import/ubuntu/translate_ubuntu_2004.wf.json",
+				detectedOs:              distro.FromGcloudOSArgumentMustParse("ubuntu-2004-arm64"),
+				architecture:            "arm64",
+			},
+		},
+		{
+			name: "Don't add a license when the OS doesn't have one.",
+			request: ImageImportRequest{
+				WorkflowDir: "workflowroot",
+			},
+			inspectionResults: &pb.InspectionResults{
+				OsCount: 1,
+				OsRelease: &pb.OsRelease{
+					CliFormatted: "oracle-8",
+				},
+				BiosBootable: true,
+			},
+			expectedResults: &processingPlan{
+				translationWorkflowPath: "workflowroot/image_import/enterprise_linux/translate_oracle_8.wf.json",
+				detectedOs:              distro.FromGcloudOSArgumentMustParse("oracle-8"),
+			},
+		},
 		{
 			name: "Don't use UEFI when disk is GPT and can boot with BIOS or UEFI.",
 			request: ImageImportRequest{
@@ -239,3 +306,59 @@ func Test_DefaultPlanner_Plan_InspectionSucceeds(t *testing.T) {
 		})
 	}
 }
+
+func Test_DefaultPlanner_Plan_WarnsAboutGuest(t *testing.T) {
+	pd := persistentDisk{uri: "disk/uri"}
+	for _, tt := range []struct {
+		name              string
+		inspectionResults *pb.InspectionResults
+		expectedWarnings  []string
+	}{
+		{
+			name: "No warnings",
+			inspectionResults: &pb.InspectionResults{
+				KernelVersions:     []string{"5.4.0-42-generic"},
+				VirtioDrivers:      []string{"virtio_net", "virtio_scsi"},
+				CloudInitInstalled: true,
+				OsRelease:          &pb.OsRelease{CliFormatted: "ubuntu-2004", DistroId: pb.Distro_UBUNTU},
+			},
+		},
+		{
+			name: "All warnings",
+			inspectionResults: &pb.InspectionResults{
+				KernelVersions:     []string{"3.10.0-1160.el7.x86_64", "3.10.0-957.el7.x86_64"},
+				VirtioDrivers:      []string{"virtio_net"},
+				CloudInitInstalled: true,
+				RootOnLvm:          true,
+				EncryptedVolumes:   []pb.InspectionResults_Encryption{pb.InspectionResults_LUKS},
+				OsRelease:          &pb.OsRelease{CliFormatted: "centos-7", DistroId: pb.Distro_CENTOS},
+			},
+			expectedWarnings: []string{
+				"The disk has 1 encrypted volume(s)",
+				"The virtio_scsi driver wasn't found for all installed kernels (3.10.0-1160.el7.x86_64, 3.10.0-957.el7.x86_64)",
+				"The root filesystem is on an LVM logical volume",
+				"cloud-init is installed",
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			tt.inspectionResults.OsCount = 1
+			mockInspector := mock_disk.NewMockInspector(mockCtrl)
+			mockInspector.EXPECT().Inspect(pd.uri).Return(tt.inspectionResults, nil)
+			logger := logging.NewToolLogger("test")
+			processPlanner := newProcessPlanner(ImageImportRequest{WorkflowDir: "workflowroot"}, mockInspector, logger)
+			_, err := processPlanner.plan(pd)
+			assert.NoError(t, err)
+
+			logs := strings.Join(logger.ReadOutputInfo().SerialOutputs, "\n")
+			for _, warning := range tt.expectedWarnings {
+				assert.Contains(t, logs, warning)
+			}
+			if len(tt.expectedWarnings) == 0 {
+				assert.NotContains(t, logs, "re-import")
+			}
+		})
+	}
+}
@@ -21,7 +21,8 @@ import (
 
 // processor represents the second (and final) phase of import. For bootable
 // disks, this means translation and publishing the final image. For data
-// disks, this means publishing the image.
+// disks, this means publishing the image. When the output is a disk or
+// snapshot, it's published instead of the image.
 //
 // Implementers can expose detailed logs using the traceLogs() method.
 type processor interface {
@@ -45,10 +46,17 @@ type defaultProcessorProvider struct {
 func (d defaultProcessorProvider) provide(pd persistentDisk) ([]processor, error) {
 
 	if d.DataDisk {
+		switch d.Output {
+		case OutputDisk:
+			// The data disk was inflated to the requested disk.
+			return nil, nil
+		case OutputSnapshot:
+			return []processor{newOutputProcessor(d.ImageImportRequest, d.computeClient, d.logger)}, nil
+		}
 		return []processor{
 			newDataDiskProcessor(pd, d.computeClient, d.Project,
 				d.Labels, d.StorageLocation, d.Description,
-				d.Family, d.ImageName)}, nil
+				d.Family, d.ImageName, d.encryptionKey(), d.logger)}, nil
 	}
 
 	plan, err := d.planner.plan(pd)
@@ -57,16 +65,38 @@ func (d defaultProcessorProvider) provide(pd persistentDisk) ([]processor, error
 	}
 
 	var processors []processor
-	if plan.metadataChangesRequired() {
+	if d.Output == OutputDisk {
+		// The disk is created before translation, so that the translated disk is kept.
+		p := newOutputProcessor(d.ImageImportRequest, d.computeClient, d.logger)
+		p.requiredLicenses = plan.requiredLicenses
+		p.requiredFeatures = plan.requiredFeatures
+		processors = append(processors, p)
+	} else if plan.metadataChangesRequired() {
 		p := newMetadataProcessor(d.ImageImportRequest.Project, d.ImageImportRequest.Zone, d.computeClient)
 		p.requiredLicenses = plan.requiredLicenses
 		p.requiredFeatures = plan.requiredFeatures
+		p.encryptionKey = d.encryptionKey()
 		processors = append(processors, p)
 	}
 
-	bootableDiskProcessor, err := newBootableDiskProcessor(d.ImageImportRequest, plan.translationWorkflowPath, d.logger, plan.detectedOs)
+	bootableDiskProcessor, err := newBootableDiskProcessor(d.ImageImportRequest, plan.translationWorkflowPath, d.logger,
+		plan.detectedOs, plan.architecture)
 	if err != nil {
 		return nil, err
 	}
-	return append(processors, bootableDiskProcessor), nil
+	processors = append(processors, bootableDiskProcessor)
+
+	if d.Output == OutputSnapshot {
+		processors = append(processors, newOutputProcessor(d.ImageImportRequest, d.computeClient, d.logger))
+	}
+
+	if d.VerifyBoot {
+		bootVerificationProcessor, err := newBootVerificationProcessor(
+			d.ImageImportRequest, d.computeClient, d.logger, plan.isWindows(), plan.architecture)
+		if err != nil {
+			return nil, err
+		}
+		processors = append(processors, bootVerificationProcessor)
+	}
+	return processors, nil
 }
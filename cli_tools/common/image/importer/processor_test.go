@@ -34,6 +34,33 @@ func Test_DefaultProcessorProvider_SkipsPlanningForDataDisk(t *testing.T) {
 	assert.IsType(t, &dataDiskProcessor{}, processors[0])
 }
 
+func Test_DefaultProcessorProvider_CreatesSnapshotOfDataDisk(t *testing.T) {
+	processorProvider := defaultProcessorProvider{
+		ImageImportRequest: ImageImportRequest{
+			DataDisk: true,
+			Output:   OutputSnapshot,
+		},
+	}
+
+	processors, err := processorProvider.provide(persistentDisk{})
+	assert.NoError(t, err)
+	assert.Len(t, processors, 1)
+	assert.IsType(t, &outputProcessor{}, processors[0])
+}
+
+func Test_DefaultProcessorProvider_KeepsInflatedDataDisk_WhenOutputIsDisk(t *testing.T) {
+	processorProvider := defaultProcessorProvider{
+		ImageImportRequest: ImageImportRequest{
+			DataDisk: true,
+			Output:   OutputDisk,
+		},
+	}
+
+	processors, err := processorProvider.provide(persistentDisk{})
+	assert.NoError(t, err)
+	assert.Empty(t, processors)
+}
+
 func Test_DefaultProcessorProvider_IncludesMetadataStepWhenMetadataChangesRequired(t *testing.T) {
 	processorProvider := defaultProcessorProvider{
 		ImageImportRequest: ImageImportRequest{
@@ -70,6 +97,65 @@ func Test_DefaultProcessorProvider_SkipsMetadataStepWhenNoChangesRequired(t *tes
 	assert.IsType(t, &bootableDiskProcessor{}, processors[0])
 }
 
+func Test_DefaultProcessorProvider_AppendsBootVerificationWhenRequested(t *testing.T) {
+	processorProvider := defaultProcessorProvider{
+		ImageImportRequest: ImageImportRequest{
+			WorkflowDir: "../../../../daisy_workflows",
+			VerifyBoot:  true,
+		},
+		planner: mockProcessPlanner{
+			result: &processingPlan{
+				translationWorkflowPath: opensuse15workflow,
+			},
+		},
+	}
+	processors, err := processorProvider.provide(persistentDisk{})
+	assert.NoError(t, err)
+	assert.Len(t, processors, 2)
+	assert.IsType(t, &bootableDiskProcessor{}, processors[0])
+	assert.IsType(t, &bootVerificationProcessor{}, processors[1])
+}
+
+func Test_DefaultProcessorProvider_CreatesOutputDiskBeforeTranslation(t *testing.T) {
+	processorProvider := defaultProcessorProvider{
+		ImageImportRequest: ImageImportRequest{
+			WorkflowDir: "../../../../daisy_workflows",
+			Output:      OutputDisk,
+		},
+		planner: mockProcessPlanner{
+			result: &processingPlan{
+				requiredLicenses:        []string{"url/license"},
+				translationWorkflowPath: opensuse15workflow,
+			},
+		},
+	}
+	processors, err := processorProvider.provide(persistentDisk{})
+	assert.NoError(t, err)
+	assert.Len(t, processors, 2)
+	assert.IsType(t, &outputProcessor{}, processors[0])
+	assert.Equal(t, []string{"url/license"}, processors[0].(*outputProcessor).requiredLicenses)
+	assert.IsType(t, &bootableDiskProcessor{}, processors[1])
+}
+
+func Test_DefaultProcessorProvider_AppendsOutputStepWhenOutputIsSnapshot(t *testing.T) {
+	processorProvider := defaultProcessorProvider{
+		ImageImportRequest: ImageImportRequest{
+			WorkflowDir: "../../../../daisy_workflows",
+			Output:      OutputSnapshot,
+		},
+		planner: mockProcessPlanner{
+			result: &processingPlan{
+				translationWorkflowPath: opensuse15workflow,
+			},
+		},
+	}
+	processors, err := processorProvider.provide(persistentDisk{})
+	assert.NoError(t, err)
+	assert.Len(t, processors, 2)
+	assert.IsType(t, &bootableDiskProcessor{}, processors[0])
+	assert.IsType(t, &outputProcessor{}, processors[1])
+}
+
 func Test_DefaultProcessorProvider_FailsWhenPlanningFails(t *testing.T) {
 	processorProvider := defaultProcessorProvider{
 		planner: mockProcessPlanner{err: errors.New("planning failed")},
@@ -16,9 +16,13 @@ package importer
 
 import (
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/api/compute/v1"
+
 	daisyutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/daisy"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/validation"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/daisycommon"
@@ -32,6 +36,25 @@ const (
 	DataDiskFlag       = "data_disk"
 	OSFlag             = "os"
 	CustomWorkflowFlag = "custom_translate_workflow"
+	DryRunFlag         = "dry_run"
+	DryRunInspectFlag  = "dry_run_inspect_disk"
+	VerifyBootFlag     = "verify_boot"
+	VerifyScriptFlag   = "verify_boot_script"
+	VerifyActionFlag   = "verify_boot_failure_action"
+	OutputFlag         = "output"
+)
+
+// Types of resources that an import creates.
+const (
+	OutputImage    = "image"
+	OutputDisk     = "disk"
+	OutputSnapshot = "snapshot"
+)
+
+// Actions that are applied to the image when boot verification fails.
+const (
+	VerifyBootFailureDeprecate = "deprecate"
+	VerifyBootFailureDelete    = "delete"
 )
 
 func (args *ImageImportRequest) validate() error {
@@ -51,6 +74,18 @@ func (args *ImageImportRequest) validate() error {
 		return fmt.Errorf("-%s and -%s can't be both specified",
 			OSFlag, CustomWorkflowFlag)
 	}
+	if args.DryRunInspectDisk && !args.DryRun {
+		return fmt.Errorf("-%s requires -%s", DryRunInspectFlag, DryRunFlag)
+	}
+	if _, remote := args.Source.(httpSource); remote && args.DryRunInspectDisk {
+		return fmt.Errorf("-%s can't be used with HTTP(S) sources, since they're only downloaded during import", DryRunInspectFlag)
+	}
+	if err := args.validateBootVerification(); err != nil {
+		return err
+	}
+	if err := args.validateOutput(); err != nil {
+		return err
+	}
 	if !strings.HasSuffix(args.ScratchBucketGcsPath, args.ExecutionID) {
 		return fmt.Errorf("Scratch bucket should have been namespaced with execution ID")
 	}
@@ -62,6 +97,74 @@ func (args *ImageImportRequest) validate() error {
 	return nil
 }
 
+func (args *ImageImportRequest) validateBootVerification() error {
+	if !args.VerifyBoot {
+		if len(args.VerifyBootScripts) > 0 {
+			return fmt.Errorf("-%s requires -%s", VerifyScriptFlag, VerifyBootFlag)
+		}
+		return nil
+	}
+	if args.DataDisk {
+		return fmt.Errorf("-%s can't be used with -%s, since data disks aren't bootable", VerifyBootFlag, DataDiskFlag)
+	}
+	if args.NoGuestEnvironment {
+		return fmt.Errorf("-%s requires the guest environment, and can't be used with -no_guest_environment", VerifyBootFlag)
+	}
+	if action := args.VerifyBootFailureAction; action != "" &&
+		action != VerifyBootFailureDeprecate && action != VerifyBootFailureDelete {
+		return fmt.Errorf("-%s must be %s or %s", VerifyActionFlag, VerifyBootFailureDeprecate, VerifyBootFailureDelete)
+	}
+	for _, script := range args.VerifyBootScripts {
+		if _, err := os.Stat(script); err != nil {
+			return fmt.Errorf("verification script %s can't be read: %v", script, err)
+		}
+	}
+	return nil
+}
+
+func (args *ImageImportRequest) validateOutput() error {
+	switch args.Output {
+	case "", OutputImage:
+		return nil
+	case OutputDisk, OutputSnapshot:
+		if args.Family != "" {
+			return fmt.Errorf("-family can only be used when -%s is %s", OutputFlag, OutputImage)
+		}
+		if args.VerifyBoot {
+			return fmt.Errorf("-%s can only be used when -%s is %s", VerifyBootFlag, OutputFlag, OutputImage)
+		}
+		return nil
+	default:
+		return fmt.Errorf("-%s must be %s, %s, or %s", OutputFlag, OutputImage, OutputDisk, OutputSnapshot)
+	}
+}
+
+// outputsImage returns whether the import creates an image, rather
+// than a disk or snapshot.
+func (args ImageImportRequest) outputsImage() bool {
+	return args.Output == "" || args.Output == OutputImage
+}
+
+// inflatedDiskName returns the name of the disk that the source is inflated
+// to. Data disks aren't processed after they're inflated, so when the import
+// creates a disk, they're inflated to that disk.
+func (args ImageImportRequest) inflatedDiskName() string {
+	if args.DataDisk && args.Output == OutputDisk {
+		return args.ImageName
+	}
+	return "disk-" + args.ExecutionID
+}
+
+// outputLabels returns the labels of the disk or snapshot that's created
+// in place of an image.
+func (args ImageImportRequest) outputLabels() map[string]string {
+	labels := map[string]string{"gce-image-import": "true"}
+	for k, v := range args.Labels {
+		labels[k] = v
+	}
+	return labels
+}
+
 func (args *ImageImportRequest) checkRequiredArguments() error {
 	return validation.ValidateStruct(args)
 }
@@ -82,6 +185,8 @@ type ImageImportRequest struct {
 	DataDisk              bool
 	DaisyLogLinePrefix    string
 	Description           string
+	DryRun                bool
+	DryRunInspectDisk     bool
 	Family                string
 	GcsLogsDisabled       bool
 	ImageName             string `name:"image_name" validate:"required,gce_disk_image_name"`
@@ -103,6 +208,38 @@ type ImageImportRequest struct {
 	Timeout               time.Duration `name:"timeout" validate:"required"`
 	UefiCompatible        bool
 	Zone                  string `name:"zone" validate:"required"`
+
+	// When VerifyBoot is set, an instance is booted from the imported image.
+	// VerifyBootScripts are local paths of scripts that are run on the instance;
+	// if the instance doesn't boot, or a script fails, VerifyBootFailureAction
+	// is applied to the image.
+	VerifyBoot              bool
+	VerifyBootFailureAction string
+	VerifyBootScripts       []string
+	VerifyBootTimeout       time.Duration
+
+	// Output is the type of resource that's created: OutputImage when it's
+	// empty, OutputDisk, or OutputSnapshot. The resource is named ImageName,
+	// and disks are created in Zone.
+	Output string
+
+	// KmsKey is the fully qualified name of the Cloud KMS key that encrypts
+	// the disks, images, and snapshots created by the import. Resources are
+	// Google-managed-encrypted when it's empty.
+	KmsKey string
+
+	// TracerProvider traces the import's workflows and their Compute API
+	// calls. The global TracerProvider is used when it's nil.
+	TracerProvider trace.TracerProvider
+}
+
+// encryptionKey returns the key of the resources created by the import,
+// or nil when KmsKey isn't set.
+func (args ImageImportRequest) encryptionKey() *compute.CustomerEncryptionKey {
+	if args.KmsKey == "" {
+		return nil
+	}
+	return &compute.CustomerEncryptionKey{KmsKeyName: args.KmsKey}
 }
 
 // FixBYOLAndOSArguments fixes the user's arguments for the --os and --byol flags
@@ -140,5 +277,7 @@ func (args ImageImportRequest) EnvironmentSettings() daisycommon.EnvironmentSett
 		ComputeServiceAccount: args.ComputeServiceAccount,
 		NoExternalIP:          args.NoExternalIP,
 		WorkflowDirectory:     args.WorkflowDir,
+		KmsKey:                args.KmsKey,
+		TracerProvider:        args.TracerProvider,
 	}
 }
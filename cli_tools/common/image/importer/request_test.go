@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/validation"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/daisycommon"
@@ -234,6 +235,47 @@ func Test_validate_ChecksForConflictingArguments(t *testing.T) {
 			request:       ImageImportRequest{BYOL: true, DataDisk: true},
 			expectedError: "when -byol is specified, -data_disk, -os, and -custom_translate_workflow have to be empty",
 		},
+		{
+			request:       ImageImportRequest{DryRunInspectDisk: true},
+			expectedError: "-dry_run_inspect_disk requires -dry_run",
+		},
+		{
+			request: ImageImportRequest{DryRun: true, DryRunInspectDisk: true,
+				Source: httpSource{url: "https://example.com/disk.vmdk"}},
+			expectedError: "-dry_run_inspect_disk can't be used with HTTP(S) sources, since they're only downloaded during import",
+		},
+		{
+			request:       ImageImportRequest{VerifyBootScripts: []string{"check.sh"}},
+			expectedError: "-verify_boot_script requires -verify_boot",
+		},
+		{
+			request:       ImageImportRequest{VerifyBoot: true, DataDisk: true},
+			expectedError: "-verify_boot can't be used with -data_disk, since data disks aren't bootable",
+		},
+		{
+			request:       ImageImportRequest{VerifyBoot: true, NoGuestEnvironment: true},
+			expectedError: "-verify_boot requires the guest environment, and can't be used with -no_guest_environment",
+		},
+		{
+			request:       ImageImportRequest{VerifyBoot: true, VerifyBootFailureAction: "keep"},
+			expectedError: "-verify_boot_failure_action must be deprecate or delete",
+		},
+		{
+			request:       ImageImportRequest{VerifyBoot: true, VerifyBootScripts: []string{"/does/not/exist.sh"}},
+			expectedError: "verification script /does/not/exist.sh can't be read: stat /does/not/exist.sh: no such file or directory",
+		},
+		{
+			request:       ImageImportRequest{Output: "instance"},
+			expectedError: "-output must be image, disk, or snapshot",
+		},
+		{
+			request:       ImageImportRequest{Output: "disk", Family: "family"},
+			expectedError: "-family can only be used when -output is image",
+		},
+		{
+			request:       ImageImportRequest{Output: "snapshot", VerifyBoot: true},
+			expectedError: "-verify_boot can only be used when -output is image",
+		},
 	}
 	for _, tt := range flagtests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -243,6 +285,17 @@ func Test_validate_ChecksForConflictingArguments(t *testing.T) {
 			toValidate.CustomWorkflow = tt.request.CustomWorkflow
 			toValidate.BYOL = tt.request.BYOL
 			toValidate.DataDisk = tt.request.DataDisk
+			toValidate.DryRun = tt.request.DryRun
+			toValidate.DryRunInspectDisk = tt.request.DryRunInspectDisk
+			toValidate.NoGuestEnvironment = tt.request.NoGuestEnvironment
+			toValidate.VerifyBoot = tt.request.VerifyBoot
+			toValidate.VerifyBootFailureAction = tt.request.VerifyBootFailureAction
+			toValidate.VerifyBootScripts = tt.request.VerifyBootScripts
+			toValidate.Output = tt.request.Output
+			toValidate.Family = tt.request.Family
+			if tt.request.Source != nil {
+				toValidate.Source = tt.request.Source
+			}
 			err := toValidate.validate()
 			assert.EqualError(t, err, tt.expectedError)
 		})
@@ -250,6 +303,7 @@ func Test_validate_ChecksForConflictingArguments(t *testing.T) {
 }
 
 func Test_EnvironmentSettings(t *testing.T) {
+	tracerProvider := trace.NewNoopTracerProvider()
 	request := ImageImportRequest{
 		Project:               "panda",
 		Zone:                  "us-west",
@@ -266,6 +320,8 @@ func Test_EnvironmentSettings(t *testing.T) {
 		ComputeServiceAccount: "email@example.com",
 		NoExternalIP:          true,
 		WorkflowDir:           "workflow-dir",
+		KmsKey:                "projects/p/locations/l/keyRings/r/cryptoKeys/k",
+		TracerProvider:        tracerProvider,
 	}
 	expected := daisycommon.EnvironmentSettings{
 		Project:               "panda",
@@ -283,6 +339,8 @@ func Test_EnvironmentSettings(t *testing.T) {
 		ComputeServiceAccount: "email@example.com",
 		NoExternalIP:          true,
 		WorkflowDirectory:     "workflow-dir",
+		KmsKey:                "projects/p/locations/l/keyRings/r/cryptoKeys/k",
+		TracerProvider:        tracerProvider,
 	}
 	assert.Equal(t, expected, request.EnvironmentSettings())
 }
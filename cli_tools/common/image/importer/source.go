@@ -15,7 +15,8 @@
 package importer
 
 import (
-	"compress/gzip"
+	"io"
+	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
@@ -23,7 +24,6 @@ import (
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/domain"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/param"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/storage"
-	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/daisycommon"
 	"github.com/GoogleCloudPlatform/compute-image-tools/daisy"
 )
 
@@ -38,18 +38,25 @@ type Source interface {
 
 // SourceFactory takes the sourceFile and sourceImage specified by the user
 // and determines which, if any, is importable. It is an error if both sourceFile and
-// sourceImage are specified.
+// sourceImage are specified. sourceFile is a GCS path, a local path, or an HTTP(S)
+// URL; sources outside of GCS are StagedSources.
 type SourceFactory interface {
 	Init(sourceFile, sourceImage string) (Source, error)
 }
 
 // NewSourceFactory returns an instance of SourceFactory.
 func NewSourceFactory(storageClient domain.StorageClientInterface) SourceFactory {
-	return sourceFactory{storageClient: storageClient}
+	return sourceFactory{
+		storageClient: storageClient,
+		uploader:      storage.NewParallelUploader(storageClient, storage.DefaultUploadChunkSize, storage.DefaultUploadWorkers),
+		httpClient:    http.DefaultClient,
+	}
 }
 
 type sourceFactory struct {
 	storageClient domain.StorageClientInterface
+	uploader      uploader
+	httpClient    *http.Client
 }
 
 func (factory sourceFactory) Init(sourceFile, sourceImage string) (Source, error) {
@@ -67,7 +74,14 @@ func (factory sourceFactory) Init(sourceFile, sourceImage string) (Source, error
 	}
 
 	if sourceFile != "" {
-		return newFileSource(sourceFile, factory.storageClient)
+		switch {
+		case isGCSPath(sourceFile):
+			return newFileSource(sourceFile, factory.storageClient)
+		case isHTTPURL(sourceFile):
+			return newHTTPSource(sourceFile, factory.storageClient, factory.uploader, factory.httpClient)
+		default:
+			return newLocalFileSource(sourceFile, factory.storageClient, factory.uploader)
+		}
 	}
 
 	return newImageSource(sourceImage)
@@ -90,11 +104,13 @@ type fileSource struct {
 	gcsPath string
 	bucket  string
 	object  string
+	// The compression or archive format of the file, or empty when the
+	// file is imported as is. See detectFormat.
+	format string
 }
 
 // Create a fileSource from a gcsPath to a disk image. This method uses storageClient
-// to read a few bytes from the file. It is an error if the file is empty, or if
-// the file is compressed with gzip.
+// to read a few bytes from the file. It is an error if the file is empty.
 func newFileSource(gcsPath string, storageClient domain.StorageClientInterface) (Source, error) {
 	sourceBucketName, sourceObjectName, err := storage.GetGCSObjectPathElements(gcsPath)
 	if err != nil {
@@ -105,7 +121,8 @@ func newFileSource(gcsPath string, storageClient domain.StorageClientInterface)
 		bucket:  sourceBucketName,
 		object:  sourceObjectName,
 	}
-	return source, source.validate(storageClient)
+	err = source.validate(storageClient)
+	return source, err
 }
 
 // The resource path for fileSource is its GCS path.
@@ -113,10 +130,16 @@ func (s fileSource) Path() string {
 	return s.gcsPath
 }
 
+// Whether the file has to be decompressed or extracted from an archive
+// before it's inflated.
+func (s fileSource) needsUnpacking() bool {
+	return s.format != ""
+}
+
 // Performs basic validation, focusing on error cases that we've seen in the past.
-// This reads a few bytes from the file in GCS. It is an error if the file
-// is empty, or if the file is compressed with gzip.
-func (s fileSource) validate(storageClient domain.StorageObjectCreatorInterface) error {
+// This reads the header of the file in GCS, to detect whether it's compressed
+// or a tar archive. It is an error if the file is empty.
+func (s *fileSource) validate(storageClient domain.StorageObjectCreatorInterface) error {
 	rc, err := storageClient.GetObject(s.bucket, s.object).NewReader()
 	if err != nil {
 		return daisy.Errf("failed to read GCS file when validating resource file: unable to open "+
@@ -124,23 +147,16 @@ func (s fileSource) validate(storageClient domain.StorageObjectCreatorInterface)
 	}
 	defer rc.Close()
 
-	byteCountingReader := daisycommon.NewByteCountingReader(rc)
-	// Detect whether it's a compressed file by extracting compressed file header
-	if _, err = gzip.NewReader(byteCountingReader); err == nil {
-		return daisy.Errf("the input file is a gzip file, which is not supported by " +
-			"image import. To import a file that was exported from Google Compute " +
-			"Engine, please use image create. To import a file that was exported " +
-			"from a different system, decompress it and run image import on the " +
-			"disk image file directly")
-	}
-
-	// By calling gzip.NewReader above, a few bytes were read from the Reader in
-	// an attempt to decode the compression header. If the Reader represents
-	// an empty file, then BytesRead will be zero.
-	if byteCountingReader.BytesRead <= 0 {
+	header := make([]byte, formatHeaderSize)
+	n, err := io.ReadFull(rc, header)
+	if n == 0 {
 		return daisy.Errf("cannot import an image from an empty file")
 	}
-
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return daisy.Errf("failed to read GCS file when validating resource file: unable to read "+
+			"file from bucket %q, file %q: %v", s.bucket, s.object, err)
+	}
+	s.format = detectFormat(header[:n])
 	return nil
 }
 
@@ -41,7 +41,7 @@ func TestEmptyFilesAreRejected(t *testing.T) {
 	assert.Contains(t, err.Error(), "cannot import an image from an empty file")
 }
 
-func TestGzipCompressedFilesAreRejected(t *testing.T) {
+func TestGzipCompressedFilesAreDetected(t *testing.T) {
 	source := fileSource{
 		gcsPath: "gs://bucket/global/images/ubuntu-1604",
 		bucket:  "bucket",
@@ -51,9 +51,11 @@ func TestGzipCompressedFilesAreRejected(t *testing.T) {
 	fileContent := test.CreateCompressedFile()
 
 	factory := NewSourceFactory(createMockStorageClient(t, source, fileContent, true))
-	_, err := factory.Init(source.Path(), "")
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "the input file is a gzip file, which is not supported")
+	result, err := factory.Init(source.Path(), "")
+	assert.NoError(t, err)
+	source.format = gzipFormat
+	assert.Equal(t, source, result)
+	assert.True(t, result.(fileSource).needsUnpacking())
 }
 
 func TestUncompressedFilesAreAllowed(t *testing.T) {
@@ -72,7 +74,7 @@ func TestUncompressedFilesAreAllowed(t *testing.T) {
 }
 
 func TestGcsFilePathMustBeFullyQualified(t *testing.T) {
-	for _, invalidPath := range []string{"file.vmdk", "gs://bucket", "gs://bucket/"} {
+	for _, invalidPath := range []string{"gs://bucket", "gs://bucket/"} {
 		_, err := NewSourceFactory(nil).Init(invalidPath, "")
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "is not a valid Cloud Storage object path")
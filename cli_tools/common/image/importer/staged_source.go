@@ -0,0 +1,234 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/domain"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/storage"
+	"github.com/GoogleCloudPlatform/compute-image-tools/daisy"
+)
+
+// StagedSource is a Source outside of GCS, such as a local file or an
+// HTTP(S) URL. Before the import it's uploaded to the scratch bucket, and
+// the uploaded file is imported.
+type StagedSource interface {
+	Source
+	// Stage uploads the source to the GCS directory gcsDir, and returns the
+	// uploaded file as a Source, and a function deleting it. The progress of
+	// the upload is reported to logger.
+	Stage(ctx context.Context, gcsDir string, logger logging.Logger) (Source, func(), error)
+}
+
+// Whether the file path is the URL of an HTTP(S) server.
+func isHTTPURL(p string) bool {
+	return strings.HasPrefix(p, "http://") || strings.HasPrefix(p, "https://")
+}
+
+// Whether the file path is a GCS path.
+func isGCSPath(p string) bool {
+	return strings.HasPrefix(p, "gs://")
+}
+
+// uploader is the subset of storage.ParallelUploader used by staged sources.
+type uploader interface {
+	Upload(ctx context.Context, open storage.RangeOpener, size int64, bkt, obj string,
+		progress *logging.TransferProgress) error
+}
+
+// stage uploads a source to gcsDir/name and validates the uploaded file.
+func stage(ctx context.Context, storageClient domain.StorageClientInterface, gcsDir, name string,
+	logger logging.Logger, upload func(bkt, obj string) error) (Source, func(), error) {
+
+	bkt, dir, err := storage.SplitGCSPath(gcsDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	obj := path.Join(dir, "source", name)
+	gcsPath := fmt.Sprintf("gs://%s/%s", bkt, obj)
+	cleanup := func() {
+		if err := storageClient.DeleteObject(gcsPath); err != nil {
+			log.Printf("Failed to delete staged source file %v: %v", gcsPath, err)
+		}
+	}
+	logging.ReportPhase(logger, logging.PhaseUpload)
+	if err := upload(bkt, obj); err != nil {
+		return nil, nil, daisy.Errf("failed to upload source file to %v: %v", gcsPath, err)
+	}
+	source, err := newFileSource(gcsPath, storageClient)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return source, cleanup, nil
+}
+
+// stagedObjectName returns the name of the staged copy of a file, based on
+// the last element of its path.
+func stagedObjectName(p string) string {
+	name := path.Base(p)
+	if name == "." || name == "/" {
+		return "source"
+	}
+	return name
+}
+
+// An importable source backed by a local file.
+type localFileSource struct {
+	path          string
+	size          int64
+	storageClient domain.StorageClientInterface
+	uploader      uploader
+}
+
+// Create a localFileSource from the path of a disk image on the local
+// file system. It is an error if the file doesn't exist or is empty.
+func newLocalFileSource(filePath string, storageClient domain.StorageClientInterface, uploader uploader) (Source, error) {
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return nil, daisy.Errf("failed to read local source file %q: %v", filePath, err)
+	}
+	if fi.IsDir() {
+		return nil, daisy.Errf("local source file %q is a directory", filePath)
+	}
+	if fi.Size() == 0 {
+		return nil, daisy.Errf("cannot import an image from an empty file")
+	}
+	return localFileSource{
+		path:          filePath,
+		size:          fi.Size(),
+		storageClient: storageClient,
+		uploader:      uploader,
+	}, nil
+}
+
+// The resource path for localFileSource is its local path.
+func (s localFileSource) Path() string {
+	return s.path
+}
+
+// Stage uploads the file in parallel chunks.
+func (s localFileSource) Stage(ctx context.Context, gcsDir string, logger logging.Logger) (Source, func(), error) {
+	return stage(ctx, s.storageClient, gcsDir, stagedObjectName(filepath.ToSlash(s.path)), logger, func(bkt, obj string) error {
+		return s.uploader.Upload(ctx, s.open, s.size, bkt, obj,
+			logging.NewTransferProgress(logger, logging.PhaseUpload, s.size))
+	})
+}
+
+func (s localFileSource) open(offset, length int64) (io.ReadCloser, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	return sectionReadCloser{io.NewSectionReader(f, offset, length), f}, nil
+}
+
+type sectionReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// An importable source backed by a file served over HTTP(S).
+type httpSource struct {
+	url           string
+	storageClient domain.StorageClientInterface
+	uploader      uploader
+	httpClient    *http.Client
+}
+
+// Create an httpSource from an HTTP(S) URL of a disk image. The URL is
+// validated, but the file is only requested when it's staged.
+func newHTTPSource(fileURL string, storageClient domain.StorageClientInterface, uploader uploader, httpClient *http.Client) (Source, error) {
+	parsed, err := url.Parse(fileURL)
+	if err != nil || parsed.Host == "" {
+		return nil, daisy.Errf("%q is not a valid HTTP(S) URL", fileURL)
+	}
+	return httpSource{
+		url:           fileURL,
+		storageClient: storageClient,
+		uploader:      uploader,
+		httpClient:    httpClient,
+	}, nil
+}
+
+// The resource path for httpSource is its URL.
+func (s httpSource) Path() string {
+	return s.url
+}
+
+// Stage uploads the file in parallel chunks if the server supports range
+// requests, and streams it otherwise, including when the server doesn't
+// answer HEAD requests. The percent done is only reported when the file is
+// uploaded in chunks.
+func (s httpSource) Stage(ctx context.Context, gcsDir string, logger logging.Logger) (Source, func(), error) {
+	parsed, _ := url.Parse(s.url)
+	return stage(ctx, s.storageClient, gcsDir, stagedObjectName(parsed.Path), logger, func(bkt, obj string) error {
+		resp, err := s.do(ctx, http.MethodHead, "", http.StatusOK)
+		if err != nil {
+			logger.Debug(fmt.Sprintf("Streaming %s, since its size and range support are unknown: %v", s.url, err))
+		} else {
+			resp.Body.Close()
+		}
+		if err == nil && resp.ContentLength > 0 && resp.Header.Get("Accept-Ranges") == "bytes" {
+			return s.uploader.Upload(ctx, func(offset, length int64) (io.ReadCloser, error) {
+				resp, err := s.do(ctx, http.MethodGet, fmt.Sprintf("bytes=%d-%d", offset, offset+length-1), http.StatusPartialContent)
+				if err != nil {
+					return nil, err
+				}
+				return resp.Body, nil
+			}, resp.ContentLength, bkt, obj, logging.NewTransferProgress(logger, logging.PhaseUpload, resp.ContentLength))
+		}
+
+		resp, err = s.do(ctx, http.MethodGet, "", http.StatusOK)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		return s.storageClient.WriteToGCS(bkt, obj, resp.Body)
+	})
+}
+
+// do sends a request for the file, optionally for a byte range, and fails
+// unless the response has the expected status.
+func (s httpSource) do(ctx context.Context, method, byteRange string, status int) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != status {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: unexpected status %q", method, s.url, resp.Status)
+	}
+	return resp, nil
+}
@@ -0,0 +1,221 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package importer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/storage"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/test"
+)
+
+// fakeUploader reads the chunks of an upload, in order, into content.
+type fakeUploader struct {
+	chunkSize int64
+	bkt, obj  string
+	content   string
+}
+
+func (u *fakeUploader) Upload(ctx context.Context, open storage.RangeOpener, size int64, bkt, obj string,
+	progress *logging.TransferProgress) error {
+	u.bkt, u.obj = bkt, obj
+	for offset := int64(0); offset < size; offset += u.chunkSize {
+		length := u.chunkSize
+		if offset+length > size {
+			length = size - offset
+		}
+		r, err := open(offset, length)
+		if err != nil {
+			return err
+		}
+		b, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		u.content += string(b)
+		progress.Add(length)
+	}
+	return nil
+}
+
+// readerMatcher matches readers with the given content.
+type readerMatcher string
+
+func gomockReaderOf(content string) gomock.Matcher {
+	return readerMatcher(content)
+}
+
+func (m readerMatcher) Matches(x interface{}) bool {
+	r, ok := x.(io.Reader)
+	if !ok {
+		return false
+	}
+	b, err := ioutil.ReadAll(r)
+	return err == nil && string(b) == string(m)
+}
+
+func (m readerMatcher) String() string {
+	return fmt.Sprintf("is a reader of %q", string(m))
+}
+
+func writeTempFile(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(dir, "disk.vmdk")
+	if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+var stagedFile = fileSource{
+	gcsPath: "gs://bucket/scratch/source/disk.vmdk",
+	bucket:  "bucket",
+	object:  "scratch/source/disk.vmdk",
+}
+
+func TestLocalFileIsStaged(t *testing.T) {
+	p := writeTempFile(t, "local disk content")
+	defer os.RemoveAll(filepath.Dir(p))
+	storageClient := createMockStorageClient(t, stagedFile, "local disk content", true)
+	u := &fakeUploader{chunkSize: 4}
+
+	source, err := sourceFactory{storageClient: storageClient, uploader: u}.Init(p, "")
+	assert.NoError(t, err)
+	assert.Equal(t, p, source.Path())
+	logger := logging.NewToolLogger(t.Name())
+	var events bytes.Buffer
+	logger.SetProgressOutput(&events)
+	staged, cleanup, err := source.(StagedSource).Stage(context.Background(), "gs://bucket/scratch", logger)
+	assert.NoError(t, err)
+	assert.Contains(t, events.String(), `"phase":"upload"}`)
+	assert.Contains(t, events.String(), `"phase":"upload","percent":100}`)
+	assert.Equal(t, stagedFile, staged)
+	assert.Equal(t, "local disk content", u.content)
+	assert.Equal(t, "bucket", u.bkt)
+	assert.Equal(t, "scratch/source/disk.vmdk", u.obj)
+
+	storageClient.EXPECT().DeleteObject(stagedFile.gcsPath).Return(nil)
+	cleanup()
+}
+
+func TestStagedGzipFilesAreDetected(t *testing.T) {
+	p := writeTempFile(t, "content")
+	defer os.RemoveAll(filepath.Dir(p))
+	storageClient := createMockStorageClient(t, stagedFile, test.CreateCompressedFile(), true)
+
+	source, err := sourceFactory{storageClient: storageClient, uploader: &fakeUploader{chunkSize: 4}}.Init(p, "")
+	assert.NoError(t, err)
+	staged, _, err := source.(StagedSource).Stage(context.Background(), "gs://bucket/scratch", logging.NewToolLogger(t.Name()))
+	assert.NoError(t, err)
+	assert.Equal(t, gzipFormat, staged.(fileSource).format)
+}
+
+func TestLocalFilesAreValidated(t *testing.T) {
+	empty := writeTempFile(t, "")
+	defer os.RemoveAll(filepath.Dir(empty))
+	for p, msg := range map[string]string{
+		"file.vmdk":         "failed to read local source file",
+		filepath.Dir(empty): "is a directory",
+		empty:               "cannot import an image from an empty file",
+	} {
+		_, err := NewSourceFactory(nil).Init(p, "")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), msg)
+	}
+}
+
+func TestHTTPFileIsStagedInRanges(t *testing.T) {
+	content := "remote disk content"
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			ranges = append(ranges, r.Header.Get("Range"))
+		}
+		http.ServeContent(w, r, "disk.vmdk", time.Time{}, strings.NewReader(content))
+	}))
+	defer server.Close()
+	storageClient := createMockStorageClient(t, stagedFile, content, true)
+	u := &fakeUploader{chunkSize: 8}
+
+	source, err := sourceFactory{storageClient: storageClient, uploader: u, httpClient: server.Client()}.Init(server.URL+"/images/disk.vmdk", "")
+	assert.NoError(t, err)
+	staged, _, err := source.(StagedSource).Stage(context.Background(), "gs://bucket/scratch/", logging.NewToolLogger(t.Name()))
+	assert.NoError(t, err)
+	assert.Equal(t, stagedFile, staged)
+	assert.Equal(t, content, u.content)
+	assert.Equal(t, []string{"bytes=0-7", "bytes=8-15", "bytes=16-18"}, ranges)
+}
+
+func TestHTTPFileIsStreamedWithoutRangeSupport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "streamed")
+	}))
+	defer server.Close()
+	storageClient := createMockStorageClient(t, stagedFile, "streamed", true)
+	storageClient.EXPECT().WriteToGCS("bucket", "scratch/source/disk.vmdk", gomockReaderOf("streamed")).Return(nil)
+
+	source, err := sourceFactory{storageClient: storageClient, httpClient: server.Client()}.Init(server.URL+"/disk.vmdk", "")
+	assert.NoError(t, err)
+	_, _, err = source.(StagedSource).Stage(context.Background(), "gs://bucket/scratch", logging.NewToolLogger(t.Name()))
+	assert.NoError(t, err)
+}
+
+func TestHTTPErrorsFailStaging(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	source, err := sourceFactory{httpClient: server.Client()}.Init(server.URL+"/disk.vmdk", "")
+	assert.NoError(t, err)
+	_, _, err = source.(StagedSource).Stage(context.Background(), "gs://bucket/scratch", logging.NewToolLogger(t.Name()))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), fmt.Sprintf("GET %s/disk.vmdk: unexpected status \"404 Not Found\"", server.URL))
+}
+
+func TestHTTPFileIsStreamedWhenHeadFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		io.WriteString(w, "streamed")
+	}))
+	defer server.Close()
+	storageClient := createMockStorageClient(t, stagedFile, "streamed", true)
+	storageClient.EXPECT().WriteToGCS("bucket", "scratch/source/disk.vmdk", gomockReaderOf("streamed")).Return(nil)
+
+	source, err := sourceFactory{storageClient: storageClient, httpClient: server.Client()}.Init(server.URL+"/disk.vmdk", "")
+	assert.NoError(t, err)
+	_, _, err = source.(StagedSource).Stage(context.Background(), "gs://bucket/scratch", logging.NewToolLogger(t.Name()))
+	assert.NoError(t, err)
+}
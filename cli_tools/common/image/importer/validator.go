@@ -25,7 +25,22 @@ type validator interface {
 	validate() error
 }
 
-func newPreValidator(request ImageImportRequest, client getImageClient) validator {
+func newPreValidator(request ImageImportRequest, client preValidatorClient) validator {
+	switch request.Output {
+	case OutputDisk:
+		return validateDiskNameAvailable{
+			project: request.Project,
+			zone:    request.Zone,
+			name:    request.ImageName,
+			client:  client,
+		}
+	case OutputSnapshot:
+		return validateSnapshotNameAvailable{
+			project: request.Project,
+			name:    request.ImageName,
+			client:  client,
+		}
+	}
 	return validateImageNameAvailable{
 		project: request.Project,
 		name:    request.ImageName,
@@ -38,7 +53,7 @@ func newPreValidator(request ImageImportRequest, client getImageClient) validato
 // been used.
 type validateImageNameAvailable struct {
 	project, name string
-	client        getImageClient
+	client        preValidatorClient
 }
 
 func (v validateImageNameAvailable) validate() error {
@@ -52,7 +67,43 @@ func (v validateImageNameAvailable) validate() error {
 	return nil
 }
 
-// diskClient is the subset of the GCP API that is used by validateImageNameAvailable.
-type getImageClient interface {
+// validateDiskNameAvailable is an importer.validator that
+// ensures the user's destination disk name hasn't already
+// been used in the zone.
+type validateDiskNameAvailable struct {
+	project, zone, name string
+	client              preValidatorClient
+}
+
+func (v validateDiskNameAvailable) validate() error {
+	disk, _ := v.client.GetDisk(v.project, v.zone, v.name)
+	if disk != nil {
+		return fmt.Errorf("The resource '%s' already exists. "+
+			"Please pick a disk name that isn't already used.", v.name)
+	}
+	return nil
+}
+
+// validateSnapshotNameAvailable is an importer.validator that
+// ensures the user's destination snapshot name hasn't already
+// been used.
+type validateSnapshotNameAvailable struct {
+	project, name string
+	client        preValidatorClient
+}
+
+func (v validateSnapshotNameAvailable) validate() error {
+	snapshot, _ := v.client.GetSnapshot(v.project, v.name)
+	if snapshot != nil {
+		return fmt.Errorf("The resource '%s' already exists. "+
+			"Please pick a snapshot name that isn't already used.", v.name)
+	}
+	return nil
+}
+
+// preValidatorClient is the subset of the GCP API that is used by the validators.
+type preValidatorClient interface {
 	GetImage(project, name string) (*compute.Image, error)
+	GetDisk(project, zone, name string) (*compute.Disk, error)
+	GetSnapshot(project, name string) (*compute.Snapshot, error)
 }
@@ -16,8 +16,11 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/api/compute/v1"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/mocks"
 )
 
 func TestPreValidator(t *testing.T) {
@@ -68,6 +71,27 @@ func TestPreValidator(t *testing.T) {
 	}
 }
 
+func TestPreValidator_ChecksTheOutputResource(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockClient := mocks.NewMockClient(mockCtrl)
+	mockClient.EXPECT().GetDisk("project-name", "us-west1-a", "disk-name").Return(&compute.Disk{}, nil)
+	mockClient.EXPECT().GetSnapshot("project-name", "snapshot-name").Return(nil, errors.New("not found"))
+
+	assert.EqualError(t, newPreValidator(ImageImportRequest{
+		Project:   "project-name",
+		Zone:      "us-west1-a",
+		ImageName: "disk-name",
+		Output:    OutputDisk,
+	}, mockClient).validate(),
+		"The resource 'disk-name' already exists. Please pick a disk name that isn't already used.")
+	assert.NoError(t, newPreValidator(ImageImportRequest{
+		Project:   "project-name",
+		ImageName: "snapshot-name",
+		Output:    OutputSnapshot,
+	}, mockClient).validate())
+}
+
 type mockGetImageClient struct {
 	t                                  *testing.T
 	expectedProject, expectedImageName string
@@ -80,3 +104,13 @@ func (m mockGetImageClient) GetImage(project, name string) (*compute.Image, erro
 	assert.Equal(m.t, m.expectedProject, project)
 	return m.img, m.err
 }
+
+func (m mockGetImageClient) GetDisk(project, zone, name string) (*compute.Disk, error) {
+	m.t.Fatal("Unexpected call to GetDisk")
+	return nil, nil
+}
+
+func (m mockGetImageClient) GetSnapshot(project, name string) (*compute.Snapshot, error) {
+	m.t.Fatal("Unexpected call to GetSnapshot")
+	return nil, nil
+}
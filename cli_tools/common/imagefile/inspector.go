@@ -87,7 +87,30 @@ func (inspector gcsInspector) inspectOnce(ctx context.Context, gcsURI string) (m
 	if !files.Exists(absPath) {
 		return metadata, fmt.Errorf("the file %q was not found", gcsURI)
 	}
-	imageInfo, err := inspector.qemuClient.GetInfo(ctx, absPath)
+	return inspectFile(ctx, inspector.qemuClient, absPath)
+}
+
+// NewLocalInspector returns an inspector that inspects image files
+// on the local filesystem. The Inspect method expects a path to
+// the file to be inspected.
+func NewLocalInspector() Inspector {
+	return localInspector{qemuClient: NewInfoClient()}
+}
+
+// localInspector implements inspector using qemu-img.
+type localInspector struct {
+	qemuClient InfoClient
+}
+
+func (inspector localInspector) Inspect(ctx context.Context, filePath string) (metadata Metadata, err error) {
+	if !files.Exists(filePath) {
+		return metadata, fmt.Errorf("the file %q was not found", filePath)
+	}
+	return inspectFile(ctx, inspector.qemuClient, filePath)
+}
+
+func inspectFile(ctx context.Context, qemuClient InfoClient, filePath string) (metadata Metadata, err error) {
+	imageInfo, err := qemuClient.GetInfo(ctx, filePath)
 	if err != nil {
 		return metadata, err
 	}
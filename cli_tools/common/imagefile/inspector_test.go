@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"io/ioutil"
+	"os"
 	"path"
 	"testing"
 	"time"
@@ -134,6 +135,32 @@ func TestGCSInspector_PerformRetry_WhenInspectionFails(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestLocalInspector_InspectsFileInPlace(t *testing.T) {
+	file, err := ioutil.TempFile("", "")
+	assert.NoError(t, err)
+	defer os.Remove(file.Name())
+	assert.NoError(t, file.Close())
+
+	inspector := NewLocalInspector().(localInspector)
+	inspector.qemuClient = &mockQemuClient{
+		expectedFilename: file.Name(),
+		t:                t,
+		returnValue: ImageInfo{
+			Format:           "qcow2",
+			ActualSizeBytes:  bytesPerGB + 1,
+			VirtualSizeBytes: bytesPerGB * 10,
+		},
+	}
+	result, err := inspector.Inspect(context.Background(), file.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, Metadata{PhysicalSizeGB: 2, VirtualSizeGB: 10, FileFormat: "qcow2"}, result)
+}
+
+func TestLocalInspector_FailsWhenFileNotFound(t *testing.T) {
+	_, err := NewLocalInspector().Inspect(context.Background(), "/path/not/found.vmdk")
+	assert.EqualError(t, err, `the file "/path/not/found.vmdk" was not found`)
+}
+
 func setupClient(t *testing.T, mountFailures, inspectFailures int, qemuResult ImageInfo) (
 	string, Inspector) {
 	pathToFakeMount, err := ioutil.TempFile("", "")
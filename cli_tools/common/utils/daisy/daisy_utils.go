@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path"
 	"regexp"
 	"sort"
 	"strings"
@@ -46,13 +47,25 @@ type TranslationSettings struct {
 
 	// LicenseURI is the GCP Compute license corresponding to this OS, version, and licensing mode:
 	//  https://cloud.google.com/compute/docs/reference/rest/v1/licenses
+	// It's empty when GCE doesn't have a license for the OS, such as Oracle Linux.
 	LicenseURI string
 
 	// WorkflowPath is the path to a Daisy json workflow, relative to the
 	// `daisy_workflows/image_import` directory.
 	WorkflowPath string
+
+	// Architecture is the CPU architecture of the OS. When it's ArchitectureARM64,
+	// the workflow is run on arm64 workers using UpdateToARM64. Otherwise it's empty,
+	// and the OS runs on x86.
+	Architecture string
 }
 
+// ArchitectureARM64 is the architecture of TranslationSettings for arm64 operating systems.
+const ArchitectureARM64 = "arm64"
+
+// MachineTypeARM64 is the machine type of instances that run arm64 operating systems.
+const MachineTypeARM64 = "t2a-standard-2"
+
 var (
 	supportedOS = []TranslationSettings{
 		// Enterprise Linux
@@ -64,6 +77,11 @@ var (
 			GcloudOsFlag: "centos-8",
 			WorkflowPath: "enterprise_linux/translate_centos_8.wf.json",
 			LicenseURI:   "projects/centos-cloud/global/licenses/centos-8",
+		}, {
+			GcloudOsFlag: "centos-8-arm64",
+			WorkflowPath: "enterprise_linux/translate_centos_8.wf.json",
+			LicenseURI:   "projects/centos-cloud/global/licenses/centos-8",
+			Architecture: ArchitectureARM64,
 		}, {
 			GcloudOsFlag: "rhel-6",
 			WorkflowPath: "enterprise_linux/translate_rhel_6_licensed.wf.json",
@@ -88,6 +106,33 @@ var (
 			GcloudOsFlag: "rhel-8-byol",
 			WorkflowPath: "enterprise_linux/translate_rhel_8_byol.wf.json",
 			LicenseURI:   "projects/rhel-cloud/global/licenses/rhel-8-byos",
+		}, {
+			GcloudOsFlag: "rhel-8-arm64",
+			WorkflowPath: "enterprise_linux/translate_rhel_8_licensed.wf.json",
+			LicenseURI:   "projects/rhel-cloud/global/licenses/rhel-8-server",
+			Architecture: ArchitectureARM64,
+		}, {
+			GcloudOsFlag: "rhel-8-arm64-byol",
+			WorkflowPath: "enterprise_linux/translate_rhel_8_byol.wf.json",
+			LicenseURI:   "projects/rhel-cloud/global/licenses/rhel-8-byos",
+			Architecture: ArchitectureARM64,
+		}, {
+			GcloudOsFlag: "rocky-8",
+			WorkflowPath: "enterprise_linux/translate_rocky_8.wf.json",
+			LicenseURI:   "projects/rocky-linux-cloud/global/licenses/rocky-linux-8",
+		}, {
+			GcloudOsFlag: "almalinux-8",
+			WorkflowPath: "enterprise_linux/translate_almalinux_8.wf.json",
+			LicenseURI:   "projects/almalinux-cloud/global/licenses/almalinux-8",
+		}, {
+			GcloudOsFlag: "oracle-7",
+			WorkflowPath: "enterprise_linux/translate_oracle_7.wf.json",
+		}, {
+			GcloudOsFlag: "oracle-8",
+			WorkflowPath: "enterprise_linux/translate_oracle_8.wf.json",
+		}, {
+			GcloudOsFlag: "amazon-2",
+			WorkflowPath: "enterprise_linux/translate_amazon_2.wf.json",
 		},
 
 		// SUSE
@@ -142,6 +187,11 @@ var (
 			GcloudOsFlag: "debian-10",
 			WorkflowPath: "debian/translate_debian_10.wf.json",
 			LicenseURI:   "projects/debian-cloud/global/licenses/debian-10-buster",
+		}, {
+			GcloudOsFlag: "debian-10-arm64",
+			WorkflowPath: "debian/translate_debian_10.wf.json",
+			LicenseURI:   "projects/debian-cloud/global/licenses/debian-10-buster",
+			Architecture: ArchitectureARM64,
 		},
 
 		// Ubuntu
@@ -161,6 +211,16 @@ var (
 			GcloudOsFlag: "ubuntu-2004",
 			WorkflowPath: "ubuntu/translate_ubuntu_2004.wf.json",
 			LicenseURI:   "projects/ubuntu-os-cloud/global/licenses/ubuntu-2004-lts",
+		}, {
+			GcloudOsFlag: "ubuntu-1804-arm64",
+			WorkflowPath: "ubuntu/translate_ubuntu_1804.wf.json",
+			LicenseURI:   "projects/ubuntu-os-cloud/global/licenses/ubuntu-1804-lts",
+			Architecture: ArchitectureARM64,
+		}, {
+			GcloudOsFlag: "ubuntu-2004-arm64",
+			WorkflowPath: "ubuntu/translate_ubuntu_2004.wf.json",
+			LicenseURI:   "projects/ubuntu-os-cloud/global/licenses/ubuntu-2004-lts",
+			Architecture: ArchitectureARM64,
 		},
 
 		// Windows
@@ -244,6 +304,13 @@ var (
 	debianWorkerRegex = regexp.MustCompile("projects/compute-image-tools/global/images/family/debian-\\d+-worker")
 )
 
+const (
+	// debianWorkerARM64 replaces the x86 Debian workers when translating arm64 disks.
+	debianWorkerARM64 = "projects/compute-image-tools/global/images/family/debian-11-worker-arm64"
+	// imageArchitectureARM64 is the architecture of images that are created from arm64 disks.
+	imageArchitectureARM64 = "ARM64"
+)
+
 // GetSortedOSIDs returns the supported OS identifiers, sorted.
 func GetSortedOSIDs() []string {
 	choices := make([]string, 0, len(supportedOS))
@@ -343,6 +410,64 @@ func UpdateToUEFICompatible(workflow *daisy.Workflow) {
 	})
 }
 
+// UpdateToARM64 runs the workflow's Debian workers on arm64 instances, so that they can
+// chroot into, and translate, disks with an arm64 operating system, and sets the
+// architecture of the images that the workflow creates. It's called after the
+// workflow is validated, since included workflows are loaded during validation.
+func UpdateToARM64(workflow *daisy.Workflow) {
+	workflow.IterateWorkflowSteps(func(step *daisy.Step) {
+		if step.CreateImages != nil {
+			for _, image := range step.CreateImages.Images {
+				image.Architecture = imageArchitectureARM64
+			}
+			for _, image := range step.CreateImages.ImagesAlpha {
+				image.Architecture = imageArchitectureARM64
+			}
+			for _, image := range step.CreateImages.ImagesBeta {
+				image.Architecture = imageArchitectureARM64
+			}
+		}
+		if step.CreateDisks != nil {
+			for _, disk := range *step.CreateDisks {
+				if !strings.HasSuffix(disk.SourceImage, debianWorkerARM64) {
+					disk.SourceImage = debianWorkerRegex.ReplaceAllLiteralString(disk.SourceImage, debianWorkerARM64)
+				}
+			}
+		}
+		if step.CreateInstances != nil {
+			// Validation expands the machine type to a URL, so only its name is replaced.
+			for _, instance := range step.CreateInstances.Instances {
+				instance.MachineType = path.Join(path.Dir(instance.MachineType), MachineTypeARM64)
+			}
+			for _, instance := range step.CreateInstances.InstancesBeta {
+				instance.MachineType = path.Join(path.Dir(instance.MachineType), MachineTypeARM64)
+			}
+		}
+	})
+}
+
+// RemoveCreateImageSteps removes the workflow's steps that create images, for imports
+// whose output is a disk or snapshot. Included workflows aren't modified, so it's
+// called before the workflow is validated.
+func RemoveCreateImageSteps(workflow *daisy.Workflow) {
+	for name, step := range workflow.Steps {
+		if step.CreateImages == nil {
+			continue
+		}
+		delete(workflow.Steps, name)
+		delete(workflow.Dependencies, name)
+		for dependent, dependencies := range workflow.Dependencies {
+			var remaining []string
+			for _, dependency := range dependencies {
+				if dependency != name {
+					remaining = append(remaining, dependency)
+				}
+			}
+			workflow.Dependencies[dependent] = remaining
+		}
+	}
+}
+
 // RemovePrivacyLogInfo removes privacy log information.
 func RemovePrivacyLogInfo(message string) string {
 	// Since translation scripts vary and is hard to predict the output, we have to hide the
@@ -72,6 +72,19 @@ func Test_GetTranslationSettings_ResolveSameWorkflowPathAsOldMap(t *testing.T) {
 		"windows-10-x64-byol": "windows/translate_windows_10_x64_byol.wf.json",
 		"windows-10-x86-byol": "windows/translate_windows_10_x86_byol.wf.json",
 
+		"centos-8-arm64":    "enterprise_linux/translate_centos_8.wf.json",
+		"debian-10-arm64":   "debian/translate_debian_10.wf.json",
+		"rhel-8-arm64":      "enterprise_linux/translate_rhel_8_licensed.wf.json",
+		"rhel-8-arm64-byol": "enterprise_linux/translate_rhel_8_byol.wf.json",
+		"ubuntu-1804-arm64": "ubuntu/translate_ubuntu_1804.wf.json",
+		"ubuntu-2004-arm64": "ubuntu/translate_ubuntu_2004.wf.json",
+
+		"rocky-8":     "enterprise_linux/translate_rocky_8.wf.json",
+		"almalinux-8": "enterprise_linux/translate_almalinux_8.wf.json",
+		"oracle-7":    "enterprise_linux/translate_oracle_7.wf.json",
+		"oracle-8":    "enterprise_linux/translate_oracle_8.wf.json",
+		"amazon-2":    "enterprise_linux/translate_amazon_2.wf.json",
+
 		// Legacy:
 		"windows-7-byol":       "windows/translate_windows_7_x64_byol.wf.json",
 		"windows-8-1-x64-byol": "windows/translate_windows_8_x64_byol.wf.json",
@@ -119,7 +132,6 @@ func Test_GetTranslationSettings_ReturnsSameLicenseAsContainedInJSON(t *testing.
 			settings, err := GetTranslationSettings(osID)
 			assert.NoError(t, err)
 			assert.NotEmpty(t, settings.WorkflowPath)
-			assert.Contains(t, settings.LicenseURI, "licenses/")
 
 			workflowPath := path.Join(workflowDir, settings.WorkflowPath)
 			if _, err := os.Stat(workflowPath); os.IsNotExist(err) {
@@ -145,7 +157,12 @@ func Test_GetTranslationSettings_ReturnsSameLicenseAsContainedInJSON(t *testing.
 					}
 				}
 			}
-			assert.Contains(t, licensesInWorkflow, settings.LicenseURI)
+			if settings.LicenseURI == "" {
+				assert.Empty(t, licensesInWorkflow)
+			} else {
+				assert.Contains(t, settings.LicenseURI, "licenses/")
+				assert.Contains(t, licensesInWorkflow, settings.LicenseURI)
+			}
 		})
 	}
 }
@@ -304,6 +321,51 @@ func TestUpdateToUEFICompatible(t *testing.T) {
 	assert.Equal(t, "UEFI_COMPATIBLE", (*w.Steps["cimg"].CreateImages).ImagesBeta[0].Image.GuestOsFeatures[0].Type)
 }
 
+func TestUpdateToARM64(t *testing.T) {
+	w := createWorkflowWithCreateDiskImageAndIncludeWorkflow()
+	(*w.Steps["cins"].CreateInstances).Instances[0].MachineType = "projects/p/zones/z/machineTypes/n1-standard-2"
+
+	UpdateToARM64(w)
+	UpdateToARM64(w)
+
+	assert.Equal(t, "", (*w.Steps["cd"].CreateDisks)[0].SourceImage)
+	assert.Equal(t, "projects/compute-image-tools/global/images/family/debian-11-worker-arm64",
+		(*w.Steps["cd"].CreateDisks)[1].SourceImage)
+	assert.Equal(t, "projects/p/zones/z/machineTypes/t2a-standard-2", (*w.Steps["cins"].CreateInstances).Instances[0].MachineType)
+	assert.Equal(t, "t2a-standard-2", (*w.Steps["cins"].CreateInstances).InstancesBeta[0].MachineType)
+	assert.Equal(t, "ARM64", (*w.Steps["cimg"].CreateImages).Images[0].Architecture)
+	assert.Equal(t, "ARM64", (*w.Steps["cimg"].CreateImages).ImagesBeta[0].Architecture)
+}
+
+func TestRemoveCreateImageSteps(t *testing.T) {
+	w := daisy.New()
+	w.Steps = map[string]*daisy.Step{
+		"translate":    {},
+		"create-image": {CreateImages: &daisy.CreateImages{}},
+		"cleanup":      {},
+	}
+	w.Dependencies = map[string][]string{
+		"create-image": {"translate"},
+		"cleanup":      {"translate", "create-image"},
+	}
+
+	RemoveCreateImageSteps(w)
+
+	assert.Len(t, w.Steps, 2)
+	assert.NotContains(t, w.Steps, "create-image")
+	assert.Equal(t, map[string][]string{"cleanup": {"translate"}}, w.Dependencies)
+}
+
+func TestGetTranslationSettings_ARM64(t *testing.T) {
+	settings, err := GetTranslationSettings("ubuntu-2004-arm64")
+	assert.NoError(t, err)
+	assert.Equal(t, ArchitectureARM64, settings.Architecture)
+
+	settings, err = GetTranslationSettings("ubuntu-2004")
+	assert.NoError(t, err)
+	assert.Empty(t, settings.Architecture)
+}
+
 func createWorkflowWithCreateInstanceNetworkAccessConfig() *daisy.Workflow {
 	w := daisy.New()
 	w.Steps = map[string]*daisy.Step{
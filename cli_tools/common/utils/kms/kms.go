@@ -0,0 +1,103 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package kms
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	cloudkms "google.golang.org/api/cloudkms/v1"
+	"google.golang.org/api/option"
+)
+
+var keyNameRgx = regexp.MustCompile(`^projects/[^/]+/locations/[^/]+/keyRings/[^/]+/cryptoKeys/[^/]+$`)
+
+// KeyFlags identifies a Cloud KMS key by the flags of a CLI tool. Key is either
+// the fully qualified name of the key, or its ID in Keyring, Location, and Project.
+type KeyFlags struct {
+	Key, Keyring, Location, Project string
+}
+
+// Name returns the fully qualified name of the key, in the format
+// projects/PROJECT/locations/LOCATION/keyRings/KEYRING/cryptoKeys/KEY, or
+// an empty string when no key is specified. The key is in defaultProject
+// when Project is empty.
+func (f KeyFlags) Name(defaultProject string) (string, error) {
+	if f.Key == "" {
+		if f.Keyring != "" || f.Location != "" || f.Project != "" {
+			return "", fmt.Errorf("the KMS keyring, location, and project require the KMS key to be specified")
+		}
+		return "", nil
+	}
+	if keyNameRgx.MatchString(f.Key) {
+		if f.Keyring != "" || f.Location != "" || f.Project != "" {
+			return "", fmt.Errorf("the KMS keyring, location, and project can't be specified "+
+				"when the KMS key %q is a fully qualified name", f.Key)
+		}
+		return f.Key, nil
+	}
+	if f.Keyring == "" || f.Location == "" {
+		return "", fmt.Errorf("the KMS keyring and location are required when the KMS key %q "+
+			"isn't a fully qualified name", f.Key)
+	}
+	project := f.Project
+	if project == "" {
+		project = defaultProject
+	}
+	if project == "" {
+		return "", fmt.Errorf("the KMS project is required when the KMS key %q "+
+			"isn't a fully qualified name, and the project isn't specified", f.Key)
+	}
+	return fmt.Sprintf("projects/%s/locations/%s/keyRings/%s/cryptoKeys/%s",
+		project, f.Location, f.Keyring, f.Key), nil
+}
+
+// KeyValidator checks that a key can encrypt the resources created by a CLI tool.
+type KeyValidator interface {
+	Validate(keyName string) error
+}
+
+// NewKeyValidator returns a KeyValidator that reads keys with the Cloud KMS API.
+func NewKeyValidator(ctx context.Context, oauth string) (KeyValidator, error) {
+	service, err := cloudkms.NewService(ctx, option.WithCredentialsFile(oauth))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud KMS client: %v", err)
+	}
+	return &keyValidator{getKey: func(keyName string) (*cloudkms.CryptoKey, error) {
+		return service.Projects.Locations.KeyRings.CryptoKeys.Get(keyName).Context(ctx).Do()
+	}}, nil
+}
+
+type keyValidator struct {
+	getKey func(keyName string) (*cloudkms.CryptoKey, error)
+}
+
+// Validate checks that the key exists, is used for encryption, and has
+// an enabled primary version.
+func (v *keyValidator) Validate(keyName string) error {
+	key, err := v.getKey(keyName)
+	if err != nil {
+		return fmt.Errorf("KMS key %s can't be used: %v", keyName, err)
+	}
+	if key.Purpose != "ENCRYPT_DECRYPT" {
+		return fmt.Errorf("KMS key %s can't be used, since its purpose is %s rather than ENCRYPT_DECRYPT",
+			keyName, key.Purpose)
+	}
+	if key.Primary == nil || key.Primary.State != "ENABLED" {
+		return fmt.Errorf("KMS key %s can't be used, since its primary version isn't enabled", keyName)
+	}
+	return nil
+}
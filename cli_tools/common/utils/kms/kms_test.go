@@ -0,0 +1,97 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package kms
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	cloudkms "google.golang.org/api/cloudkms/v1"
+)
+
+const keyName = "projects/kms-project/locations/us-west1/keyRings/ring/cryptoKeys/key"
+
+func TestKeyFlags_Name(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		flags         KeyFlags
+		expectedName  string
+		expectedError string
+	}{
+		{name: "no key", flags: KeyFlags{}},
+		{name: "fully qualified", flags: KeyFlags{Key: keyName}, expectedName: keyName},
+		{name: "key ID", flags: KeyFlags{Key: "key", Keyring: "ring", Location: "us-west1", Project: "kms-project"},
+			expectedName: keyName},
+		{name: "default project", flags: KeyFlags{Key: "key", Keyring: "ring", Location: "us-west1"},
+			expectedName: "projects/project/locations/us-west1/keyRings/ring/cryptoKeys/key"},
+		{name: "keyring without key", flags: KeyFlags{Keyring: "ring"},
+			expectedError: "require the KMS key to be specified"},
+		{name: "fully qualified with keyring", flags: KeyFlags{Key: keyName, Keyring: "ring"},
+			expectedError: "can't be specified"},
+		{name: "key ID without location", flags: KeyFlags{Key: "key", Keyring: "ring"},
+			expectedError: "the KMS keyring and location are required"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			name, err := tt.flags.Name("project")
+			if tt.expectedError != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tt.expectedError)
+				}
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedName, name)
+			}
+		})
+	}
+}
+
+func TestKeyFlags_Name_FailsWithoutProject(t *testing.T) {
+	_, err := KeyFlags{Key: "key", Keyring: "ring", Location: "us-west1"}.Name("")
+	assert.EqualError(t, err, `the KMS project is required when the KMS key "key" isn't a fully qualified name, `+
+		"and the project isn't specified")
+}
+
+func TestKeyValidator_Validate(t *testing.T) {
+	enabled := &cloudkms.CryptoKeyVersion{State: "ENABLED"}
+	for _, tt := range []struct {
+		name          string
+		key           *cloudkms.CryptoKey
+		getErr        error
+		expectedError string
+	}{
+		{name: "usable", key: &cloudkms.CryptoKey{Purpose: "ENCRYPT_DECRYPT", Primary: enabled}},
+		{name: "not found", getErr: errors.New("404"), expectedError: "can't be used: 404"},
+		{name: "signing key", key: &cloudkms.CryptoKey{Purpose: "ASYMMETRIC_SIGN", Primary: enabled},
+			expectedError: "its purpose is ASYMMETRIC_SIGN"},
+		{name: "disabled", key: &cloudkms.CryptoKey{Purpose: "ENCRYPT_DECRYPT",
+			Primary: &cloudkms.CryptoKeyVersion{State: "DISABLED"}}, expectedError: "primary version isn't enabled"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &keyValidator{getKey: func(name string) (*cloudkms.CryptoKey, error) {
+				assert.Equal(t, keyName, name)
+				return tt.key, tt.getErr
+			}}
+			err := v.Validate(keyName)
+			if tt.expectedError != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tt.expectedError)
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
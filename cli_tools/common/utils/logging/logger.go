@@ -15,6 +15,7 @@
 package logging
 
 import (
+	"io"
 	"log"
 	"os"
 	"strings"
@@ -79,7 +80,10 @@ type ToolLogger interface {
 	// NewLogger creates a new logger that writes to this ToolLogger, but with a
 	// different User prefix.
 	NewLogger(userPrefix string) Logger
+	// SetProgressOutput sets the writer that progress events are written to.
+	SetProgressOutput(w io.Writer)
 	Logger
+	ProgressReporter
 	OutputInfoReader
 }
 
@@ -97,6 +101,8 @@ type ToolLogger interface {
 //    inclusion in OutputInfo.SerialOutputs.
 // Trace:
 //  - Included in OutputInfo.SerialOutputs
+// Progress:
+//  - Written as a JSON line to the progress output, when it's set.
 type defaultToolLogger struct {
 	// userPrefix and debugPrefix are strings that are prepended to user and debug messages.
 	// The userPrefix string should be kept in sync with the matcher used by gcloud and the
@@ -123,7 +129,10 @@ type defaultToolLogger struct {
 	// timeProvider is a function that returns the current time. Typically time.Now. Exposed for testing.
 	timeProvider func() time.Time
 
-	// mutationLock should be taken when reading or writing trace, userAndDebugBuffer, or outputInfo.
+	// progress: Destination for progress events. Events are dropped when it's nil.
+	progress io.Writer
+
+	// mutationLock should be taken when reading or writing trace, userAndDebugBuffer, outputInfo, or progress.
 	mutationLock sync.Mutex
 }
 
@@ -160,6 +169,29 @@ func (l *defaultToolLogger) Metric(metric *pb.OutputInfo) {
 	proto.Merge(l.outputInfo, metric)
 }
 
+// SetProgressOutput sets the writer that progress events are written to.
+func (l *defaultToolLogger) SetProgressOutput(w io.Writer) {
+	l.mutationLock.Lock()
+	defer l.mutationLock.Unlock()
+
+	l.progress = w
+}
+
+// Progress writes event as a JSON line to the progress output. The time of
+// the event is set when it's missing.
+func (l *defaultToolLogger) Progress(event ProgressEvent) {
+	if event.Time.IsZero() {
+		event.Time = l.timeProvider()
+	}
+	line := encodeProgress(event)
+
+	l.mutationLock.Lock()
+	defer l.mutationLock.Unlock()
+	if l.progress != nil {
+		l.progress.Write(line)
+	}
+}
+
 // Returns a view comprised of:
 //   - Calls to Metric
 //   - All user, debug, and trace logs. User and debug logs are appended into a single
@@ -254,3 +286,7 @@ func (s *customPrefixLogger) Trace(message string) {
 func (s *customPrefixLogger) Metric(metric *pb.OutputInfo) {
 	s.parent.Metric(metric)
 }
+
+func (s *customPrefixLogger) Progress(event ProgressEvent) {
+	s.parent.Progress(event)
+}
@@ -0,0 +1,174 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package logging
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+// Phases of a CLI tool that are reported as ProgressEvent.Phase.
+const (
+	PhaseUpload         = "upload"
+	PhaseCopy           = "copy"
+	PhaseInflate        = "inflate"
+	PhaseInspect        = "inspect"
+	PhaseTranslate      = "translate"
+	PhaseCreateImage    = "create-image"
+	PhaseCreateOutput   = "create-output"
+	PhaseVerifyBoot     = "verify-boot"
+	PhaseExport         = "export"
+	PhaseCreateInstance = "create-instance"
+	PhaseImport         = "import"
+)
+
+// ProgressOutputStdout is the progress output that writes events to stdout.
+const ProgressOutputStdout = "stdout"
+
+// progressInterval is the minimum time between two events of a TransferProgress.
+const progressInterval = 10 * time.Second
+
+// ProgressEvent is a machine-readable update on the progress of a CLI tool.
+// Events are written as JSON lines.
+type ProgressEvent struct {
+	Time  time.Time `json:"time"`
+	Phase string    `json:"phase"`
+	// Percent of the phase that is done. Omitted when it isn't known.
+	Percent float64 `json:"percent,omitempty"`
+	// ETASeconds is the estimated time until the phase is done. Omitted
+	// when it isn't known.
+	ETASeconds int64 `json:"etaSeconds,omitempty"`
+}
+
+// ProgressReporter is implemented by loggers that report progress events.
+// Loggers that are created by NewToolLogger write the events to the writer
+// set with SetProgressOutput, and drop them when it isn't set.
+type ProgressReporter interface {
+	Progress(event ProgressEvent)
+}
+
+// ReportPhase reports that phase started, when logger is a ProgressReporter.
+func ReportPhase(logger Logger, phase string) {
+	ReportProgress(logger, ProgressEvent{Phase: phase})
+}
+
+// ReportProgress sends event to logger, when logger is a ProgressReporter.
+// Other loggers, such as mocks, ignore the event.
+func ReportProgress(logger Logger, event ProgressEvent) {
+	if reporter, ok := logger.(ProgressReporter); ok {
+		reporter.Progress(event)
+	}
+}
+
+// OpenProgressOutput opens the destination of progress events, which is
+// stdout when path is ProgressOutputStdout, and otherwise a file that the
+// events are appended to. The returned writer is nil when path is empty.
+func OpenProgressOutput(path string) (io.WriteCloser, error) {
+	switch path {
+	case "":
+		return nil, nil
+	case ProgressOutputStdout:
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+}
+
+// nopCloser keeps stdout open when the progress output is closed.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// encodeProgress returns event as a JSON line.
+func encodeProgress(event ProgressEvent) []byte {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return nil
+	}
+	return append(line, '\n')
+}
+
+// TransferProgress reports the percent done and ETA of a transfer of a
+// known number of bytes. It is an io.Writer that counts the bytes written
+// to it, so it can be combined with io.MultiWriter and io.TeeReader.
+// A nil TransferProgress ignores the transferred bytes.
+type TransferProgress struct {
+	logger Logger
+	phase  string
+	total  int64
+
+	mutex      sync.Mutex
+	done       int64
+	start      time.Time
+	lastReport time.Time
+
+	// timeProvider is a function that returns the current time. Exposed for testing.
+	timeProvider func() time.Time
+}
+
+// NewTransferProgress creates a TransferProgress of total bytes, whose
+// events are reported to logger with phase.
+func NewTransferProgress(logger Logger, phase string, total int64) *TransferProgress {
+	now := time.Now()
+	return &TransferProgress{
+		logger:       logger,
+		phase:        phase,
+		total:        total,
+		start:        now,
+		lastReport:   now,
+		timeProvider: time.Now,
+	}
+}
+
+// Write counts the bytes of p as transferred.
+func (p *TransferProgress) Write(b []byte) (int, error) {
+	p.Add(int64(len(b)))
+	return len(b), nil
+}
+
+// Add counts n more bytes as transferred. An event is reported at most
+// every progressInterval, and when the transfer is done.
+func (p *TransferProgress) Add(n int64) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	wasDone := p.done >= p.total
+	p.done += n
+	now := p.timeProvider()
+	if wasDone || (p.done < p.total && now.Sub(p.lastReport) < progressInterval) {
+		return
+	}
+	p.lastReport = now
+
+	event := ProgressEvent{Phase: p.phase}
+	if p.total > 0 {
+		event.Percent = math.Min(100, math.Round(float64(p.done)*1000/float64(p.total))/10)
+	}
+	if p.done > 0 && p.done < p.total {
+		elapsed := now.Sub(p.start).Seconds()
+		event.ETASeconds = int64(elapsed * float64(p.total-p.done) / float64(p.done))
+	}
+	ReportProgress(p.logger, event)
+}
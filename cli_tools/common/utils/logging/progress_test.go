@@ -0,0 +1,98 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package logging
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DefaultToolLogger_Progress_WritesJSONLines(t *testing.T) {
+	logger, written := setupTestLogger("[image-import]", dateTime)
+	var progress bytes.Buffer
+	logger.SetProgressOutput(&progress)
+
+	ReportPhase(logger, PhaseInflate)
+	ReportProgress(logger.NewLogger("[child]"), ProgressEvent{Phase: PhaseUpload, Percent: 12.5, ETASeconds: 30})
+
+	assert.Equal(t, `{"time":"2009-11-10T23:10:15Z","phase":"inflate"}
+{"time":"2009-11-10T23:10:15Z","phase":"upload","percent":12.5,"etaSeconds":30}
+`, progress.String())
+	assert.Empty(t, written.String())
+}
+
+func Test_DefaultToolLogger_Progress_DroppedWithoutOutput(t *testing.T) {
+	logger, written := setupTestLogger("[image-import]", dateTime)
+	ReportPhase(logger, PhaseInflate)
+	assert.Empty(t, written.String())
+}
+
+func Test_TransferProgress_ReportsPercentAndETA(t *testing.T) {
+	logger, _ := setupTestLogger("[image-import]", dateTime)
+	var progress bytes.Buffer
+	logger.SetProgressOutput(&progress)
+
+	p := NewTransferProgress(logger, PhaseUpload, 1000)
+	p.start, p.lastReport = dateTime, dateTime
+	now := dateTime
+	p.timeProvider = func() time.Time { return now }
+
+	// Not reported, since the interval didn't pass.
+	now = dateTime.Add(5 * time.Second)
+	p.Write(make([]byte, 100))
+	now = dateTime.Add(20 * time.Second)
+	p.Add(150)
+	now = dateTime.Add(25 * time.Second)
+	p.Add(750)
+	// Not reported, since the transfer is done.
+	p.Add(10)
+
+	assert.Equal(t, `{"time":"2009-11-10T23:10:15Z","phase":"upload","percent":25,"etaSeconds":60}
+{"time":"2009-11-10T23:10:15Z","phase":"upload","percent":100}
+`, progress.String())
+}
+
+func Test_TransferProgress_NilIgnoresBytes(t *testing.T) {
+	var p *TransferProgress
+	p.Add(10)
+}
+
+func Test_OpenProgressOutput(t *testing.T) {
+	w, err := OpenProgressOutput("")
+	assert.NoError(t, err)
+	assert.Nil(t, w)
+
+	w, err = OpenProgressOutput(ProgressOutputStdout)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	f, err := ioutil.TempFile("", "progress")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	f.WriteString("existing\n")
+	f.Close()
+	w, err = OpenProgressOutput(f.Name())
+	assert.NoError(t, err)
+	w.Write([]byte("appended\n"))
+	assert.NoError(t, w.Close())
+	content, err := ioutil.ReadFile(f.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, "existing\nappended\n", string(content))
+}
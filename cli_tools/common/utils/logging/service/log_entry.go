@@ -122,6 +122,10 @@ type ImageExportParams struct {
 	SourceImage           string `json:"source_image,omitempty"`
 	Format                string `json:"format,omitempty"`
 	ComputeServiceAccount string `json:"compute_service_account,omitempty"`
+	HasKmsKey             bool   `json:"has_kms_key"`
+	HasKmsKeyring         bool   `json:"has_kms_keyring"`
+	HasKmsLocation        bool   `json:"has_kms_location"`
+	HasKmsProject         bool   `json:"has_kms_project"`
 }
 
 // OnestepImageImportParams contains all input params for onestep image import
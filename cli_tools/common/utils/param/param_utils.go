@@ -20,6 +20,7 @@ import (
 	"regexp"
 	"strings"
 
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/api/option"
 
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/domain"
@@ -123,14 +124,17 @@ func PopulateRegion(region *string, zone string) error {
 	return nil
 }
 
-// CreateComputeClient creates a new compute client
-func CreateComputeClient(ctx *context.Context, oauth string, ce string) (compute.Client, error) {
+// CreateComputeClient creates a new compute client. Its API calls are traced
+// with tracerProvider, when it's not nil.
+func CreateComputeClient(ctx *context.Context, oauth string, ce string,
+	tracerProvider trace.TracerProvider) (compute.Client, error) {
 	computeOptions := []option.ClientOption{option.WithCredentialsFile(oauth)}
 	if ce != "" {
 		computeOptions = append(computeOptions, option.WithEndpoint(ce))
 	}
 
-	computeClient, err := compute.NewClient(*ctx, computeOptions...)
+	computeClient, err := compute.NewClientWithConfig(*ctx,
+		compute.ClientConfig{TracerProvider: tracerProvider}, computeOptions...)
 	if err != nil {
 		return nil, daisy.Errf("failed to create compute client: %v", err)
 	}
@@ -173,37 +173,54 @@ func (b *BufferedWriter) Close() error {
 	}
 	defer client.Close()
 
-	// Compose the object.
+	return composeObjects(client, b.bkt, b.obj, b.id, b.tmpObjs)
+}
+
+// composeObjects composes parts, in order, into bkt/obj and deletes them.
+// A compose takes at most 32 objects, so parts are first composed into
+// intermediate objects named after id.
+func composeObjects(client domain.StorageClientInterface, bkt, obj, id string, parts []string) error {
+	return compose(client, bkt, obj, id, parts, true)
+}
+
+// compose composes parts like composeObjects, but only deletes them when
+// deleteParts is set. Intermediate objects are always deleted.
+func compose(client domain.StorageClientInterface, bkt, obj, id string, parts []string, deleteParts bool) error {
+	intermediate := map[string]bool{}
+	deleteComposed := func(objs []domain.StorageObject) error {
+		for _, o := range objs {
+			if deleteParts || intermediate[o.ObjectName()] {
+				if err := o.Delete(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
 	for i := 0; ; i++ {
 		var objs []domain.StorageObject
 		// Max 32 components in a single compose.
-		l := math.Min(float64(32), float64(len(b.tmpObjs)))
-		for _, obj := range b.tmpObjs[:int(l)] {
-			objs = append(objs, client.GetObject(b.bkt, obj))
+		l := math.Min(float64(32), float64(len(parts)))
+		for _, part := range parts[:int(l)] {
+			objs = append(objs, client.GetObject(bkt, part))
 		}
 		if len(objs) == 1 {
-			if _, err := client.GetObject(b.bkt, b.obj).CopyFrom(objs[0]); err != nil {
+			if _, err := client.GetObject(bkt, obj).CopyFrom(objs[0]); err != nil {
 				return err
 			}
-			err = objs[0].Delete()
-			if err != nil {
-				return err
-			}
-			break
+			return deleteComposed(objs)
 		}
-		newObj := client.GetObject(b.bkt, path.Join(b.obj, b.id+"_compose_"+strconv.Itoa(i)))
-		b.tmpObjs = append([]string{newObj.ObjectName()}, b.tmpObjs[int(l):]...)
+		newObj := client.GetObject(bkt, path.Join(obj, id+"_compose_"+strconv.Itoa(i)))
+		newName := newObj.ObjectName()
+		intermediate[newName] = true
+		parts = append([]string{newName}, parts[int(l):]...)
 		if _, err := newObj.Compose(objs...); err != nil {
 			return err
 		}
-		for _, o := range objs {
-			err = o.Delete()
-			if err != nil {
-				return err
-			}
+		if err := deleteComposed(objs); err != nil {
+			return err
 		}
 	}
-	return nil
 }
 
 // Write writes the passed in bytes to buffer.
@@ -0,0 +1,289 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/domain"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
+	pathutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/path"
+)
+
+const (
+	// DefaultUploadChunkSize is the size of the chunks of a ParallelUploader.
+	DefaultUploadChunkSize = 256 * 1024 * 1024
+	// DefaultUploadWorkers is the number of chunks a ParallelUploader uploads at once.
+	DefaultUploadWorkers = 4
+
+	uploadRetries = 8
+
+	// The chunks of a ResumableUpload and their manifest are kept in a folder
+	// named after the object with this suffix.
+	resumableChunksSuffix = "_chunks"
+	uploadManifestFile    = "manifest.json"
+)
+
+// RangeOpener opens a reader of length bytes of a file, starting at offset.
+type RangeOpener func(offset, length int64) (io.ReadCloser, error)
+
+// ParallelUploader uploads a file of known size to a GCS object. The file
+// is split into chunks, which are uploaded in parallel to temporary objects
+// and composed into the object. A chunk which fails to upload is retried on
+// its own, so the upload resumes where it failed rather than starting over.
+type ParallelUploader struct {
+	client    domain.StorageClientInterface
+	chunkSize int64
+	workers   int
+
+	// Overridden in tests.
+	retryWait func(attempt int) time.Duration
+}
+
+// NewParallelUploader creates a ParallelUploader.
+func NewParallelUploader(client domain.StorageClientInterface, chunkSize int64, workers int) *ParallelUploader {
+	return &ParallelUploader{
+		client:    client,
+		chunkSize: chunkSize,
+		workers:   workers,
+		retryWait: func(attempt int) time.Duration { return time.Duration(attempt) * time.Second },
+	}
+}
+
+// Upload uploads size bytes of a file to bkt/obj, reading each chunk with open.
+// The bytes of each uploaded chunk are added to progress, which may be nil.
+func (u *ParallelUploader) Upload(ctx context.Context, open RangeOpener, size int64, bkt, obj string,
+	progress *logging.TransferProgress) error {
+	if size <= u.chunkSize {
+		return u.uploadChunk(ctx, open, 0, size, bkt, obj, progress)
+	}
+
+	id := pathutils.RandString(5)
+	var parts []string
+	for offset := int64(0); offset < size; offset += u.chunkSize {
+		parts = append(parts, path.Join(obj, fmt.Sprintf("%s_part%d", id, len(parts))))
+	}
+
+	indices := make([]int, len(parts))
+	for i := range indices {
+		indices[i] = i
+	}
+	if err := u.uploadChunks(ctx, open, size, bkt, parts, indices, nil, progress); err != nil {
+		for _, p := range parts {
+			u.client.GetObject(bkt, p).Delete()
+		}
+		return err
+	}
+	return composeObjects(u.client, bkt, obj, id, parts)
+}
+
+// ResumableUpload uploads size bytes of a file to bkt/obj like Upload, but the
+// chunks are kept next to obj, along with a manifest of the uploaded chunks,
+// when the upload fails. A later upload of the same source to bkt/obj then
+// only uploads the missing chunks. source identifies the content of the file,
+// such as its path and version. The chunks that are already uploaded are added
+// to progress when the upload starts.
+func (u *ParallelUploader) ResumableUpload(ctx context.Context, open RangeOpener, size int64, source, bkt, obj string,
+	progress *logging.TransferProgress) error {
+	dir := obj + resumableChunksSuffix
+	manifest := readUploadManifest(u.client, bkt, obj)
+	if manifest == nil || manifest.Source != source || manifest.Size != size || manifest.ChunkSize != u.chunkSize {
+		if manifest != nil {
+			fmt.Printf("Discarding the interrupted upload of %v to 'gs://%v/%v', since the source changed.\n", manifest.Source, bkt, obj)
+			for _, i := range manifest.Completed {
+				u.client.GetObject(bkt, path.Join(dir, fmt.Sprintf("chunk%d", i))).Delete()
+			}
+		}
+		manifest = &uploadManifest{Source: source, Size: size, ChunkSize: u.chunkSize}
+		if err := writeUploadManifest(u.client, bkt, obj, manifest); err != nil {
+			return err
+		}
+	}
+
+	var parts []string
+	var pending []int
+	var uploaded int64
+	completed := map[int]bool{}
+	for _, i := range manifest.Completed {
+		completed[i] = true
+	}
+	for offset := int64(0); offset < size; offset += u.chunkSize {
+		if !completed[len(parts)] {
+			pending = append(pending, len(parts))
+		} else {
+			uploaded += u.chunkLength(len(parts), size)
+		}
+		parts = append(parts, path.Join(dir, fmt.Sprintf("chunk%d", len(parts))))
+	}
+	if len(pending) < len(parts) {
+		fmt.Printf("Resuming the upload of %v to 'gs://%v/%v': %v of %v chunks are already uploaded.\n",
+			source, bkt, obj, len(parts)-len(pending), len(parts))
+	}
+	progress.Add(uploaded)
+
+	err := u.uploadChunks(ctx, open, size, bkt, parts, pending, func(i int) error {
+		manifest.Completed = append(manifest.Completed, i)
+		return writeUploadManifest(u.client, bkt, obj, manifest)
+	}, progress)
+	if err != nil {
+		return err
+	}
+
+	// The chunks and manifest are kept until the chunks are composed, so a
+	// failed compose can be resumed.
+	if err := compose(u.client, bkt, obj, pathutils.RandString(5), parts, false); err != nil {
+		return err
+	}
+	if err := u.client.GetObject(bkt, path.Join(dir, uploadManifestFile)).Delete(); err != nil {
+		return err
+	}
+	for _, part := range parts {
+		if err := u.client.GetObject(bkt, part).Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InterruptedUploadSource returns the source of an interrupted ResumableUpload
+// to bkt/obj, or an empty string if there is none.
+func InterruptedUploadSource(client domain.StorageClientInterface, bkt, obj string) string {
+	if manifest := readUploadManifest(client, bkt, obj); manifest != nil {
+		return manifest.Source
+	}
+	return ""
+}
+
+// uploadChunks uploads the chunks of a file at indices to their parts in bkt.
+// done is called after each chunk is uploaded, one chunk at a time.
+func (u *ParallelUploader) uploadChunks(ctx context.Context, open RangeOpener, size int64, bkt string,
+	parts []string, indices []int, done func(i int) error, progress *logging.TransferProgress) error {
+	chunks := make(chan int)
+	errs := make(chan error, len(indices))
+	var wg sync.WaitGroup
+	var mx sync.Mutex
+	for w := 0; w < u.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range chunks {
+				err := u.uploadChunk(ctx, open, int64(i)*u.chunkSize, u.chunkLength(i, size), bkt, parts[i], progress)
+				if err == nil && done != nil {
+					mx.Lock()
+					err = done(i)
+					mx.Unlock()
+				}
+				if err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	for _, i := range indices {
+		chunks <- i
+	}
+	close(chunks)
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// chunkLength returns the length of chunk i of a file of size bytes.
+func (u *ParallelUploader) chunkLength(i int, size int64) int64 {
+	offset := int64(i) * u.chunkSize
+	if offset+u.chunkSize > size {
+		return size - offset
+	}
+	return u.chunkSize
+}
+
+// uploadManifest records the uploaded chunks of a ResumableUpload.
+type uploadManifest struct {
+	Source    string `json:"source"`
+	Size      int64  `json:"size"`
+	ChunkSize int64  `json:"chunkSize"`
+	Completed []int  `json:"completedChunks"`
+}
+
+func readUploadManifest(client domain.StorageClientInterface, bkt, obj string) *uploadManifest {
+	r, err := client.GetObject(bkt, path.Join(obj+resumableChunksSuffix, uploadManifestFile)).NewReader()
+	if err != nil {
+		return nil
+	}
+	defer r.Close()
+	var manifest uploadManifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return nil
+	}
+	return &manifest
+}
+
+func writeUploadManifest(client domain.StorageClientInterface, bkt, obj string, manifest *uploadManifest) error {
+	w := client.GetObject(bkt, path.Join(obj+resumableChunksSuffix, uploadManifestFile)).NewWriter()
+	if err := json.NewEncoder(w).Encode(manifest); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// uploadChunk uploads length bytes at offset to bkt/obj, retrying failures.
+// The length is added to progress once the chunk is uploaded.
+func (u *ParallelUploader) uploadChunk(ctx context.Context, open RangeOpener, offset, length int64, bkt, obj string,
+	progress *logging.TransferProgress) error {
+	var err error
+	for attempt := 1; attempt <= uploadRetries; attempt++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err = u.tryUploadChunk(open, offset, length, bkt, obj); err == nil {
+			progress.Add(length)
+			return nil
+		}
+		fmt.Printf("Failed %v time(s) to upload bytes %v-%v to 'gs://%v/%v', error: %v\n", attempt, offset, offset+length-1, bkt, obj, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(u.retryWait(attempt)):
+		}
+	}
+	return err
+}
+
+func (u *ParallelUploader) tryUploadChunk(open RangeOpener, offset, length int64, bkt, obj string) error {
+	r, err := open(offset, length)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	dst := u.client.GetObject(bkt, obj).NewWriter()
+	n, err := io.Copy(dst, io.LimitReader(r, length))
+	if err != nil {
+		dst.Close()
+		return err
+	}
+	if n != length {
+		dst.Close()
+		return fmt.Errorf("read %v bytes, expected %v", n, length)
+	}
+	return dst.Close()
+}
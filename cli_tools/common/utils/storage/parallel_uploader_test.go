@@ -0,0 +1,255 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/domain"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/mocks"
+)
+
+// memBucket keeps the content of fake objects in memory.
+type memBucket struct {
+	mx      sync.Mutex
+	objects map[string][]byte
+	// Returned by Compose when it's set.
+	composeErr error
+}
+
+type memObject struct {
+	b    *memBucket
+	name string
+}
+
+type memWriter struct {
+	bytes.Buffer
+	o *memObject
+}
+
+func (w *memWriter) Close() error {
+	w.o.b.mx.Lock()
+	defer w.o.b.mx.Unlock()
+	w.o.b.objects[w.o.name] = w.Bytes()
+	return nil
+}
+
+func (o *memObject) Delete() error {
+	o.b.mx.Lock()
+	defer o.b.mx.Unlock()
+	delete(o.b.objects, o.name)
+	return nil
+}
+
+func (o *memObject) GetObjectHandle() *storage.ObjectHandle { return nil }
+
+func (o *memObject) NewReader() (io.ReadCloser, error) {
+	o.b.mx.Lock()
+	defer o.b.mx.Unlock()
+	return ioutil.NopCloser(bytes.NewReader(o.b.objects[o.name])), nil
+}
+
+func (o *memObject) NewWriter() io.WriteCloser { return &memWriter{o: o} }
+
+func (o *memObject) ObjectName() string { return o.name }
+
+func (o *memObject) Compose(src ...domain.StorageObject) (*storage.ObjectAttrs, error) {
+	o.b.mx.Lock()
+	defer o.b.mx.Unlock()
+	if o.b.composeErr != nil {
+		return nil, o.b.composeErr
+	}
+	var d []byte
+	for _, s := range src {
+		d = append(d, o.b.objects[s.ObjectName()]...)
+	}
+	o.b.objects[o.name] = d
+	return nil, nil
+}
+
+func (o *memObject) CopyFrom(src domain.StorageObject) (*storage.ObjectAttrs, error) {
+	return o.Compose(src)
+}
+
+func newMemStorageClient(t *testing.T) (*mocks.MockStorageClientInterface, *memBucket) {
+	mockCtrl := gomock.NewController(t)
+	b := &memBucket{objects: map[string][]byte{}}
+	client := mocks.NewMockStorageClientInterface(mockCtrl)
+	client.EXPECT().GetObject("bkt", gomock.Any()).DoAndReturn(func(_, name string) domain.StorageObject {
+		return &memObject{b: b, name: name}
+	}).AnyTimes()
+	return client, b
+}
+
+func TestParallelUploaderComposesChunks(t *testing.T) {
+	client, b := newMemStorageClient(t)
+	data := strings.Repeat("0123456789", 10)
+	failures := 0
+	open := func(offset, length int64) (io.ReadCloser, error) {
+		if offset == 42 && failures == 0 {
+			failures++
+			return nil, errors.New("connection reset")
+		}
+		return ioutil.NopCloser(strings.NewReader(data[offset : offset+length])), nil
+	}
+
+	u := NewParallelUploader(client, 7, 3)
+	u.retryWait = func(int) time.Duration { return 0 }
+	assert.NoError(t, u.Upload(context.Background(), open, int64(len(data)), "bkt", "dir/disk.vmdk", nil))
+	assert.Equal(t, 1, failures)
+	assert.Equal(t, data, string(b.objects["dir/disk.vmdk"]))
+	assert.Len(t, b.objects, 1, "temporary objects should be deleted")
+}
+
+func TestParallelUploaderSingleChunk(t *testing.T) {
+	client, b := newMemStorageClient(t)
+	open := func(offset, length int64) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("disk")), nil
+	}
+	assert.NoError(t, NewParallelUploader(client, 7, 3).Upload(context.Background(), open, 4, "bkt", "disk.vmdk", nil))
+	assert.Equal(t, map[string][]byte{"disk.vmdk": []byte("disk")}, b.objects)
+}
+
+func TestParallelUploaderFailsWhenChunkFails(t *testing.T) {
+	client, b := newMemStorageClient(t)
+	open := func(offset, length int64) (io.ReadCloser, error) {
+		if offset == 0 {
+			return nil, errors.New("file is gone")
+		}
+		return ioutil.NopCloser(strings.NewReader(strings.Repeat("a", int(length)))), nil
+	}
+	u := NewParallelUploader(client, 2, 2)
+	u.retryWait = func(int) time.Duration { return 0 }
+	err := u.Upload(context.Background(), open, 6, "bkt", "disk.vmdk", nil)
+	assert.EqualError(t, err, "file is gone")
+	assert.Empty(t, b.objects, "uploaded chunks should be deleted")
+}
+
+func TestParallelUploaderStopsRetryingWhenCanceled(t *testing.T) {
+	client, _ := newMemStorageClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	open := func(offset, length int64) (io.ReadCloser, error) {
+		cancel()
+		return nil, errors.New("file is gone")
+	}
+	u := NewParallelUploader(client, 8, 1)
+	u.retryWait = func(int) time.Duration { return time.Hour }
+	err := u.Upload(ctx, open, 4, "bkt", "disk.vmdk", nil)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestParallelUploaderFailsOnShortRead(t *testing.T) {
+	client, _ := newMemStorageClient(t)
+	open := func(offset, length int64) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("a")), nil
+	}
+	u := NewParallelUploader(client, 8, 1)
+	u.retryWait = func(int) time.Duration { return 0 }
+	err := u.Upload(context.Background(), open, 4, "bkt", "disk.vmdk", nil)
+	assert.EqualError(t, err, "read 1 bytes, expected 4")
+}
+
+func TestParallelUploaderResumesInterruptedUpload(t *testing.T) {
+	client, b := newMemStorageClient(t)
+	data := strings.Repeat("0123456789", 10)
+	var opened []int64
+	interrupted := true
+	open := func(offset, length int64) (io.ReadCloser, error) {
+		opened = append(opened, offset)
+		if offset == 42 && interrupted {
+			return nil, errors.New("connection reset")
+		}
+		return ioutil.NopCloser(strings.NewReader(data[offset : offset+length])), nil
+	}
+	u := NewParallelUploader(client, 7, 1)
+	u.retryWait = func(int) time.Duration { return 0 }
+
+	err := u.ResumableUpload(context.Background(), open, int64(len(data)), "s3://bucket/disk.vmdk#1", "bkt", "dir/disk.vmdk", nil)
+	assert.EqualError(t, err, "connection reset")
+	assert.NotContains(t, b.objects, "dir/disk.vmdk")
+	assert.Equal(t, []byte("0123456"), b.objects["dir/disk.vmdk_chunks/chunk0"], "uploaded chunks should be kept")
+	assert.Equal(t, "s3://bucket/disk.vmdk#1", InterruptedUploadSource(client, "bkt", "dir/disk.vmdk"))
+
+	opened, interrupted = nil, false
+	logger := logging.NewToolLogger(t.Name())
+	var events bytes.Buffer
+	logger.SetProgressOutput(&events)
+	progress := logging.NewTransferProgress(logger, logging.PhaseCopy, int64(len(data)))
+	assert.NoError(t, u.ResumableUpload(context.Background(), open, int64(len(data)), "s3://bucket/disk.vmdk#1", "bkt", "dir/disk.vmdk", progress))
+	assert.Equal(t, []int64{42}, opened, "only the missing chunk should be uploaded")
+	assert.Contains(t, events.String(), `"phase":"copy","percent":100}`, "uploaded chunks should count as progress")
+	assert.Equal(t, map[string][]byte{"dir/disk.vmdk": []byte(data)}, b.objects, "chunks and manifest should be deleted")
+	assert.Empty(t, InterruptedUploadSource(client, "bkt", "dir/disk.vmdk"))
+}
+
+func TestParallelUploaderResumesFailedCompose(t *testing.T) {
+	client, b := newMemStorageClient(t)
+	data := strings.Repeat("0123456789", 10)
+	var opened int32
+	open := func(offset, length int64) (io.ReadCloser, error) {
+		atomic.AddInt32(&opened, 1)
+		return ioutil.NopCloser(strings.NewReader(data[offset : offset+length])), nil
+	}
+	u := NewParallelUploader(client, 7, 2)
+	u.retryWait = func(int) time.Duration { return 0 }
+
+	b.composeErr = errors.New("compose failed")
+	err := u.ResumableUpload(context.Background(), open, int64(len(data)), "s3://bucket/disk.vmdk#1", "bkt", "dir/disk.vmdk", nil)
+	assert.EqualError(t, err, "compose failed")
+	assert.Equal(t, []byte("0123456"), b.objects["dir/disk.vmdk_chunks/chunk0"], "chunks should be kept")
+	assert.Equal(t, "s3://bucket/disk.vmdk#1", InterruptedUploadSource(client, "bkt", "dir/disk.vmdk"))
+
+	atomic.StoreInt32(&opened, 0)
+	b.composeErr = nil
+	assert.NoError(t, u.ResumableUpload(context.Background(), open, int64(len(data)), "s3://bucket/disk.vmdk#1", "bkt", "dir/disk.vmdk", nil))
+	assert.Zero(t, opened, "no chunk should be uploaded again")
+	assert.Equal(t, map[string][]byte{"dir/disk.vmdk": []byte(data)}, b.objects, "chunks and manifest should be deleted")
+}
+
+func TestParallelUploaderRestartsUploadWhenSourceChanged(t *testing.T) {
+	client, b := newMemStorageClient(t)
+	u := NewParallelUploader(client, 2, 2)
+	u.retryWait = func(int) time.Duration { return 0 }
+	failing := func(offset, length int64) (io.ReadCloser, error) {
+		if offset == 4 {
+			return nil, errors.New("file is gone")
+		}
+		return ioutil.NopCloser(strings.NewReader("aa")), nil
+	}
+	assert.Error(t, u.ResumableUpload(context.Background(), failing, 6, "s3://bucket/disk.vmdk#1", "bkt", "disk.vmdk", nil))
+
+	var opened int32
+	open := func(offset, length int64) (io.ReadCloser, error) {
+		atomic.AddInt32(&opened, 1)
+		return ioutil.NopCloser(strings.NewReader("bb")), nil
+	}
+	assert.NoError(t, u.ResumableUpload(context.Background(), open, 6, "s3://bucket/disk.vmdk#2", "bkt", "disk.vmdk", nil))
+	assert.Equal(t, int32(3), opened, "all chunks should be uploaded")
+	assert.Equal(t, map[string][]byte{"disk.vmdk": []byte("bbbbbb")}, b.objects)
+}
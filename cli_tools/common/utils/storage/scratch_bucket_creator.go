@@ -37,11 +37,15 @@ type ScratchBucketCreator struct {
 	StorageClient         domain.StorageClientInterface
 	Ctx                   context.Context
 	BucketIteratorCreator domain.BucketIteratorCreatorInterface
+
+	// KmsKey, when set, is the default Cloud KMS key of a newly created bucket.
+	// An existing bucket isn't changed.
+	KmsKey string
 }
 
 // NewScratchBucketCreator creates a ScratchBucketCreator
 func NewScratchBucketCreator(ctx context.Context, storageClient domain.StorageClientInterface) *ScratchBucketCreator {
-	return &ScratchBucketCreator{storageClient, ctx, &BucketIteratorCreator{}}
+	return &ScratchBucketCreator{StorageClient: storageClient, Ctx: ctx, BucketIteratorCreator: &BucketIteratorCreator{}}
 }
 
 // CreateScratchBucket creates scratch bucket in the same region as sourceFileFlag. If failed to
@@ -125,6 +129,9 @@ func (c *ScratchBucketCreator) createBucketIfNotExisting(project string,
 	if foundBucketAttrs != nil {
 		return foundBucketAttrs.Location, nil
 	}
+	if c.KmsKey != "" {
+		bucketAttrs.Encryption = &storage.BucketEncryption{DefaultKMSKeyName: c.KmsKey}
+	}
 	log.Printf("Creating scratch bucket `%v` in %v region", bucketAttrs.Name, bucketAttrs.Location)
 	if err := c.StorageClient.CreateBucket(bucketAttrs.Name, project, bucketAttrs); err != nil {
 		return "", err
@@ -35,7 +35,7 @@ func TestCreateScratchBucketErrorWhenProjectNotProvided(t *testing.T) {
 	ctx := context.Background()
 	mockStorageClient := mocks.NewMockStorageClientInterface(mockCtrl)
 
-	c := ScratchBucketCreator{mockStorageClient, ctx, nil}
+	c := ScratchBucketCreator{mockStorageClient, ctx, nil, ""}
 	bucket, region, err := c.CreateScratchBucket("", "", "")
 	assertErrorFromCreateScratchBucket(t, bucket, region, err)
 }
@@ -56,13 +56,36 @@ func TestCreateScratchBucketNoSourceFileDefaultBucketCreatedBasedOnDefaultRegion
 		StorageClass: defaultStorageClass,
 	}).Return(nil)
 
-	c := ScratchBucketCreator{mockStorageClient, ctx, createMockBucketIteratorWithRandomBuckets(mockCtrl, &ctx, mockStorageClient, project)}
+	c := ScratchBucketCreator{mockStorageClient, ctx, createMockBucketIteratorWithRandomBuckets(mockCtrl, &ctx, mockStorageClient, project), ""}
 	bucket, region, err := c.CreateScratchBucket("", project, "")
 	assert.Equal(t, expectedBucket, bucket)
 	assert.Equal(t, expectedRegion, region)
 	assert.Nil(t, err)
 }
 
+func TestCreateScratchBucketNewBucketEncryptedWithKmsKey(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	project := "proJect1"
+	expectedBucket := "project1-daisy-bkt-us"
+	kmsKey := "projects/p/locations/us/keyRings/ring/cryptoKeys/key"
+	ctx := context.Background()
+
+	mockStorageClient := mocks.NewMockStorageClientInterface(mockCtrl)
+	mockStorageClient.EXPECT().CreateBucket(expectedBucket, project, &storage.BucketAttrs{
+		Name:         expectedBucket,
+		Location:     defaultRegion,
+		StorageClass: defaultStorageClass,
+		Encryption:   &storage.BucketEncryption{DefaultKMSKeyName: kmsKey},
+	}).Return(nil)
+
+	c := ScratchBucketCreator{mockStorageClient, ctx, createMockBucketIteratorWithRandomBuckets(mockCtrl, &ctx, mockStorageClient, project), kmsKey}
+	bucket, _, err := c.CreateScratchBucket("", project, "")
+	assert.Equal(t, expectedBucket, bucket)
+	assert.Nil(t, err)
+}
+
 func TestCreateScratchBucketNoSourceFileTranslateGoogleDomainDefaultBucketCreatedBasedOnDefaultRegion(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
@@ -79,7 +102,7 @@ func TestCreateScratchBucketNoSourceFileTranslateGoogleDomainDefaultBucketCreate
 		StorageClass: defaultStorageClass,
 	}).Return(nil)
 
-	c := ScratchBucketCreator{mockStorageClient, ctx, createMockBucketIteratorWithRandomBuckets(mockCtrl, &ctx, mockStorageClient, project)}
+	c := ScratchBucketCreator{mockStorageClient, ctx, createMockBucketIteratorWithRandomBuckets(mockCtrl, &ctx, mockStorageClient, project), ""}
 	bucket, region, err := c.CreateScratchBucket("", project, "")
 	assert.Equal(t, expectedBucket, bucket)
 	assert.Equal(t, expectedRegion, region)
@@ -102,7 +125,7 @@ func TestCreateScratchBucketNoSourceFileBucketCreatedBasedOnInputZone(t *testing
 		StorageClass: regionalStorageClass,
 	}).Return(nil)
 
-	c := ScratchBucketCreator{mockStorageClient, ctx, createMockBucketIteratorWithRandomBuckets(mockCtrl, &ctx, mockStorageClient, project)}
+	c := ScratchBucketCreator{mockStorageClient, ctx, createMockBucketIteratorWithRandomBuckets(mockCtrl, &ctx, mockStorageClient, project), ""}
 	bucket, region, err := c.CreateScratchBucket("", project, "asia-east1-b")
 	assert.Equal(t, expectedBucket, bucket)
 	assert.Equal(t, expectedRegion, region)
@@ -124,7 +147,7 @@ func TestCreateScratchBucketNoSourceErrorCreatingDefaultBucket(t *testing.T) {
 		StorageClass: defaultStorageClass,
 	}).Return(fmt.Errorf("some error"))
 
-	c := ScratchBucketCreator{mockStorageClient, ctx, createMockBucketIteratorWithRandomBuckets(mockCtrl, &ctx, mockStorageClient, project)}
+	c := ScratchBucketCreator{mockStorageClient, ctx, createMockBucketIteratorWithRandomBuckets(mockCtrl, &ctx, mockStorageClient, project), ""}
 	bucket, region, err := c.CreateScratchBucket("", project, "")
 	assert.Equal(t, "", bucket)
 	assert.Equal(t, "", region)
@@ -177,7 +200,7 @@ func TestCreateScratchBucketNewBucketCreatedProject(t *testing.T) {
 		Return(mockBucketIterator).
 		Times(1)
 
-	c := ScratchBucketCreator{mockStorageClient, ctx, mockBucketIteratorCreator}
+	c := ScratchBucketCreator{mockStorageClient, ctx, mockBucketIteratorCreator, ""}
 	bucket, region, err := c.CreateScratchBucket("gs://sourcebucket/sourcefile", project, "")
 	assert.Equal(t, "project1-daisy-bkt-us-west2", bucket)
 	assert.Equal(t, "us-west2", region)
@@ -214,7 +237,7 @@ func TestCreateScratchBucketErrorRetrievingSourceFileBucketMetadataDefaultBucket
 		StorageClass: defaultStorageClass,
 	}).Return(nil)
 
-	c := ScratchBucketCreator{mockStorageClient, ctx, createMockBucketIteratorWithRandomBuckets(mockCtrl, &ctx, mockStorageClient, project)}
+	c := ScratchBucketCreator{mockStorageClient, ctx, createMockBucketIteratorWithRandomBuckets(mockCtrl, &ctx, mockStorageClient, project), ""}
 	bucket, region, err := c.CreateScratchBucket("gs://sourcebucket/sourcefile", project, "")
 	assert.Equal(t, expectedBucket, bucket)
 	assert.Equal(t, expectedRegion, region)
@@ -238,7 +261,7 @@ func TestCreateScratchBucketErrorRetrievingSourceFileBucketMetadataBucketCreated
 		StorageClass: regionalStorageClass,
 	}).Return(nil)
 
-	c := ScratchBucketCreator{mockStorageClient, ctx, createMockBucketIteratorWithRandomBuckets(mockCtrl, &ctx, mockStorageClient, project)}
+	c := ScratchBucketCreator{mockStorageClient, ctx, createMockBucketIteratorWithRandomBuckets(mockCtrl, &ctx, mockStorageClient, project), ""}
 	bucket, region, err := c.CreateScratchBucket("gs://sourcebucket/sourcefile", project, "asia-east1-b")
 	assert.Equal(t, expectedBucket, bucket)
 	assert.Equal(t, expectedRegion, region)
@@ -262,7 +285,7 @@ func TestCreateScratchBucketNilSourceFileBucketMetadataDefaultBucketCreated(t *t
 		StorageClass: defaultStorageClass,
 	}).Return(nil)
 
-	c := ScratchBucketCreator{mockStorageClient, ctx, createMockBucketIteratorWithRandomBuckets(mockCtrl, &ctx, mockStorageClient, project)}
+	c := ScratchBucketCreator{mockStorageClient, ctx, createMockBucketIteratorWithRandomBuckets(mockCtrl, &ctx, mockStorageClient, project), ""}
 	bucket, region, err := c.CreateScratchBucket("gs://sourcebucket/sourcefile", project, "")
 	assert.Equal(t, expectedBucket, bucket)
 	assert.Equal(t, expectedRegion, region)
@@ -291,7 +314,7 @@ func TestCreateScratchBucketErrorWhenIteratingOverProjectBucketsWhileCreatingBuc
 	mockBucketIteratorCreator.EXPECT().CreateBucketIterator(ctx, mockStorageClient, projectID).
 		Return(mockBucketIterator)
 
-	c := ScratchBucketCreator{mockStorageClient, ctx, mockBucketIteratorCreator}
+	c := ScratchBucketCreator{mockStorageClient, ctx, mockBucketIteratorCreator, ""}
 	_, _, err := c.CreateScratchBucket("gs://sourcebucket/sourcefile", projectID, "")
 	assert.NotNil(t, err)
 	assert.Equal(t, "iterator error", err.Error())
@@ -336,7 +359,7 @@ func TestCreateScratchBucketReturnsExistingScratchBucketNoCreate(t *testing.T) {
 		Return(mockBucketIterator).
 		Times(1)
 
-	c := ScratchBucketCreator{mockStorageClient, ctx, mockBucketIteratorCreator}
+	c := ScratchBucketCreator{mockStorageClient, ctx, mockBucketIteratorCreator, ""}
 	bucket, region, err := c.CreateScratchBucket("gs://sourcebucket/sourcefile", projectID, "")
 	assert.Equal(t, "project1-daisy-bkt-us-west2", bucket)
 	assert.Equal(t, "us-west2", region)
@@ -388,7 +411,7 @@ func TestCreateScratchBucketErrorWhenCreatingBucket(t *testing.T) {
 		CreateBucketIterator(ctx, mockStorageClient, project).
 		Return(mockBucketIterator)
 
-	c := ScratchBucketCreator{mockStorageClient, ctx, mockBucketIteratorCreator}
+	c := ScratchBucketCreator{mockStorageClient, ctx, mockBucketIteratorCreator, ""}
 	_, _, err := c.CreateScratchBucket("gs://sourcebucket/sourcefile", project, "")
 	assert.NotNil(t, err)
 	assert.Equal(t, "error creating a bucket", err.Error())
@@ -424,7 +447,7 @@ func TestIsBucketInProjectTrue(t *testing.T) {
 		Return(mockBucketIterator).
 		Times(1)
 
-	c := ScratchBucketCreator{mockStorageClient, ctx, mockBucketIteratorCreator}
+	c := ScratchBucketCreator{mockStorageClient, ctx, mockBucketIteratorCreator, ""}
 	result := c.IsBucketInProject(projectID, "project1-daisy-bkt-us-west2")
 	assert.True(t, result)
 }
@@ -459,7 +482,7 @@ func TestIsBucketInProjectFalseOnNoBucket(t *testing.T) {
 		Return(mockBucketIterator).
 		Times(1)
 
-	c := ScratchBucketCreator{mockStorageClient, ctx, mockBucketIteratorCreator}
+	c := ScratchBucketCreator{mockStorageClient, ctx, mockBucketIteratorCreator, ""}
 	result := c.IsBucketInProject(projectID, "project1-daisy-bkt-us-west2")
 	assert.False(t, result)
 }
@@ -482,7 +505,7 @@ func TestIsBucketInProjectFalseOnIteratorError(t *testing.T) {
 		Return(mockBucketIterator).
 		Times(1)
 
-	c := ScratchBucketCreator{mockStorageClient, ctx, mockBucketIteratorCreator}
+	c := ScratchBucketCreator{mockStorageClient, ctx, mockBucketIteratorCreator, ""}
 	result := c.IsBucketInProject(projectID, "project1-daisy-bkt-us-west2")
 	assert.False(t, result)
 }
@@ -281,6 +281,24 @@ func GetBucketNameFromGCSPath(p string) (string, error) {
 	return bucket, err
 }
 
+// RewriteWithKmsKey rewrites the object at gcsPath, so that it's encrypted with
+// kmsKey. The rewrite is done by Cloud Storage, without downloading the object.
+func RewriteWithKmsKey(ctx context.Context, storageClient domain.StorageClientInterface,
+	gcsPath string, kmsKey string) error {
+
+	bucket, object, err := GetGCSObjectPathElements(gcsPath)
+	if err != nil {
+		return err
+	}
+	handle := storageClient.GetObject(bucket, object).GetObjectHandle()
+	copier := handle.CopierFrom(handle)
+	copier.DestinationKMSKeyName = kmsKey
+	if _, err := copier.Run(ctx); err != nil {
+		return daisy.Errf("failed to encrypt %s with KMS key %s: %v", gcsPath, kmsKey, err)
+	}
+	return nil
+}
+
 // HTTPClient implements domain.HTTPClientInterface which abstracts HTTP functionality used by
 // image import features.
 type HTTPClient struct {
@@ -15,6 +15,7 @@
 package storage
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"testing"
@@ -23,6 +24,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
 
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/mocks"
@@ -66,6 +68,11 @@ func TestDeleteGcsPathErrorWhenInvalidGCSPath(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestRewriteWithKmsKeyErrorWhenInvalidGCSPath(t *testing.T) {
+	err := RewriteWithKmsKey(context.Background(), &Client{}, "gs://bucket/", "key")
+	assert.NotNil(t, err)
+}
+
 func TestDeleteGcsPathErrorWhenIteratorReturnsError(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
@@ -77,7 +84,8 @@ func TestDeleteGcsPathErrorWhenIteratorReturnsError(t *testing.T) {
 	mockObjectIteratorCreator.EXPECT().CreateObjectIterator(
 		"sourcebucket", "sourcepath/furtherpath").Return(mockObjectIterator)
 
-	sc := Client{Oic: mockObjectIteratorCreator, Logger: logging.NewToolLogger("[test]")}
+	sc := Client{Oic: mockObjectIteratorCreator, StorageClient: newTestStorageClient(t),
+		Logger: logging.NewToolLogger("[test]")}
 	err := sc.DeleteGcsPath("gs://sourcebucket/sourcepath/furtherpath")
 	assert.NotNil(t, err)
 }
@@ -205,7 +213,8 @@ func doTestFindGcsFile(t *testing.T, bucket, lookupPath string) {
 		CreateObjectIterator(bucket, lookupPath).
 		Return(mockObjectIterator)
 
-	sc := Client{Oic: mockObjectIteratorCreator, Logger: logging.NewToolLogger("[test]")}
+	sc := Client{Oic: mockObjectIteratorCreator, StorageClient: newTestStorageClient(t),
+		Logger: logging.NewToolLogger("[test]")}
 	storageObject, err := sc.FindGcsFile(
 		fmt.Sprintf("gs://%v/%v", bucket, lookupPath), ".ovf")
 
@@ -240,7 +249,8 @@ func TestFindGcsFileNoFileFound(t *testing.T) {
 		CreateObjectIterator("sourcebucket", "sourcepath/furtherpath").
 		Return(mockObjectIterator)
 
-	sc := Client{Oic: mockObjectIteratorCreator, Logger: logging.NewToolLogger("[test]")}
+	sc := Client{Oic: mockObjectIteratorCreator, StorageClient: newTestStorageClient(t),
+		Logger: logging.NewToolLogger("[test]")}
 	storageObject, err := sc.FindGcsFile("gs://sourcebucket/sourcepath/furtherpath", ".ovf")
 	assert.Nil(t, storageObject)
 	assert.NotNil(t, err)
@@ -278,7 +288,8 @@ func TestFindGcsFileErrorWhileIteratingThroughFilesInPath(t *testing.T) {
 		CreateObjectIterator("sourcebucket", "sourcepath/furtherpath").
 		Return(mockObjectIterator)
 
-	sc := Client{Oic: mockObjectIteratorCreator, Logger: logging.NewToolLogger("[test]")}
+	sc := Client{Oic: mockObjectIteratorCreator, StorageClient: newTestStorageClient(t),
+		Logger: logging.NewToolLogger("[test]")}
 	storageObject, err := sc.
 		FindGcsFile("gs://sourcebucket/sourcepath/furtherpath", ".ovf")
 	assert.Nil(t, storageObject)
@@ -303,7 +314,8 @@ func TestFindGcsFileDepthLimitedFileInRoot(t *testing.T) {
 		CreateObjectIterator("sourcebucket", "").
 		Return(mockObjectIterator)
 
-	sc := Client{Oic: mockObjectIteratorCreator, Logger: logging.NewToolLogger("[test]")}
+	sc := Client{Oic: mockObjectIteratorCreator, StorageClient: newTestStorageClient(t),
+		Logger: logging.NewToolLogger("[test]")}
 	storageObject, err := sc.FindGcsFileDepthLimited(
 		"gs://sourcebucket/", ".ovf", 0)
 
@@ -334,7 +346,8 @@ func TestFindGcsFileDepthLimitedFileNotFoundInRoot(t *testing.T) {
 		CreateObjectIterator("sourcebucket", "").
 		Return(mockObjectIterator)
 
-	sc := Client{Oic: mockObjectIteratorCreator, Logger: logging.NewToolLogger("[test]")}
+	sc := Client{Oic: mockObjectIteratorCreator, StorageClient: newTestStorageClient(t),
+		Logger: logging.NewToolLogger("[test]")}
 	storageObject, err := sc.FindGcsFileDepthLimited(
 		"gs://sourcebucket/", ".ovf", 0)
 
@@ -372,7 +385,8 @@ func doTestFindGcsFileDepthLimitedFileInSubFolderlookupFromRoot(t *testing.T, gc
 		CreateObjectIterator("sourcebucket", "").
 		Return(mockObjectIterator)
 
-	sc := Client{Oic: mockObjectIteratorCreator, Logger: logging.NewToolLogger("[test]")}
+	sc := Client{Oic: mockObjectIteratorCreator, StorageClient: newTestStorageClient(t),
+		Logger: logging.NewToolLogger("[test]")}
 	storageObject, err := sc.FindGcsFileDepthLimited(
 		gcsDirectoryPath, ".ovf", 2)
 
@@ -410,7 +424,8 @@ func doTestFindGcsFileDepthLimitedFileInSubFolderlookupFromSubfolder(t *testing.
 		CreateObjectIterator(bucket, lookupPath).
 		Return(mockObjectIterator)
 
-	sc := Client{Oic: mockObjectIteratorCreator, Logger: logging.NewToolLogger("[test]")}
+	sc := Client{Oic: mockObjectIteratorCreator, StorageClient: newTestStorageClient(t),
+		Logger: logging.NewToolLogger("[test]")}
 	storageObject, err := sc.FindGcsFileDepthLimited(
 		fmt.Sprintf("gs://%v/%v", bucket, lookupPath), ".ovf", 0)
 
@@ -443,7 +458,8 @@ func TestFindGcsFileDepthLimitedFileNotFoundInSubFolder(t *testing.T) {
 		CreateObjectIterator("sourcebucket", "").
 		Return(mockObjectIterator)
 
-	sc := Client{Oic: mockObjectIteratorCreator, Logger: logging.NewToolLogger("[test]")}
+	sc := Client{Oic: mockObjectIteratorCreator, StorageClient: newTestStorageClient(t),
+		Logger: logging.NewToolLogger("[test]")}
 	storageObject, err := sc.FindGcsFileDepthLimited(
 		"gs://sourcebucket/", ".ovf", 2)
 
@@ -589,3 +605,11 @@ func TestGetGCSObjectPathElementsErrorOnInvalidPath(t *testing.T) {
 	_, _, err := GetGCSObjectPathElements("NOT_GCS_PATH")
 	assert.NotNil(t, err)
 }
+
+// newTestStorageClient returns a client for tests that create object handles,
+// but don't send requests.
+func newTestStorageClient(t *testing.T) *storage.Client {
+	client, err := storage.NewClient(context.Background(), option.WithoutAuthentication())
+	assert.NoError(t, err)
+	return client
+}
@@ -16,6 +16,7 @@ package daisycommon
 
 import (
 	"github.com/GoogleCloudPlatform/compute-image-tools/daisy"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ParseWorkflow parses Daisy workflow file and returns Daisy workflow object or error in case of failure
@@ -59,6 +60,10 @@ type EnvironmentSettings struct {
 	Project, Zone, GCSPath, OAuth, Timeout, ComputeEndpoint string
 	DisableGCSLogs, DisableCloudLogs, DisableStdoutLogs     bool
 
+	// Optional Cloud KMS key that encrypts the disks, images, and snapshots
+	// created by the workflow.
+	KmsKey string
+
 	// An optional prefix to include in the bracketed portion of daisy's stdout logs.
 	// Gcloud does a prefix match to determine whether to show a log line to a user.
 	//
@@ -70,6 +75,9 @@ type EnvironmentSettings struct {
 	Network, Subnet       string
 	ComputeServiceAccount string
 	NoExternalIP          bool
+
+	// Optional TracerProvider used to trace workflows and their Compute API calls.
+	TracerProvider trace.TracerProvider
 }
 
 // ApplyWorkerCustomizations sets variables on daisy.Workflow that
@@ -98,6 +106,9 @@ func (env EnvironmentSettings) ApplyToWorkflow(w *daisy.Workflow) {
 	if env.ComputeEndpoint != "" {
 		w.ComputeEndpoint = env.ComputeEndpoint
 	}
+	if env.KmsKey != "" {
+		w.KmsKey = env.KmsKey
+	}
 	if env.DisableGCSLogs {
 		w.DisableGCSLogging()
 	}
@@ -107,4 +118,7 @@ func (env EnvironmentSettings) ApplyToWorkflow(w *daisy.Workflow) {
 	if env.DisableStdoutLogs {
 		w.DisableStdoutLogging()
 	}
+	if env.TracerProvider != nil {
+		w.TracerProvider = env.TracerProvider
+	}
 }
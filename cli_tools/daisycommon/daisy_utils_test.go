@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/GoogleCloudPlatform/compute-image-tools/daisy"
 )
@@ -150,6 +151,8 @@ func Test_ApplyToWorkflow(t *testing.T) {
 				OAuth:           "new-oauth",
 				Timeout:         "new-timeout",
 				ComputeEndpoint: "new-endpoint",
+				KmsKey:          "new-key",
+				TracerProvider:  trace.NewNoopTracerProvider(),
 			},
 			original: &daisy.Workflow{
 				Project:         "original-project",
@@ -166,6 +169,8 @@ func Test_ApplyToWorkflow(t *testing.T) {
 				OAuthPath:       "new-oauth",
 				DefaultTimeout:  "new-timeout",
 				ComputeEndpoint: "new-endpoint",
+				KmsKey:          "new-key",
+				TracerProvider:  trace.NewNoopTracerProvider(),
 			},
 		},
 		{
@@ -79,6 +79,10 @@ func initLoggingParams(args *importer.OneStepImportArguments) service.InputParam
 			Family:                args.Family,
 			Description:           args.Description,
 			NoExternalIP:          args.NoExternalIP,
+			HasKmsKey:             args.KmsFlags.Key != "",
+			HasKmsKeyring:         args.KmsFlags.Keyring != "",
+			HasKmsLocation:        args.KmsFlags.Location != "",
+			HasKmsProject:         args.KmsFlags.Project != "",
 			StorageLocation:       args.StorageLocation,
 			ComputeServiceAccount: args.ComputeServiceAccount,
 			AWSAMIID:              args.AWSAMIID,
@@ -19,6 +19,9 @@ import (
 	"regexp"
 	"strings"
 
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/param"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/validation"
 	"github.com/GoogleCloudPlatform/compute-image-tools/daisy"
@@ -32,6 +35,7 @@ type awsImportArguments struct {
 	amiID              string
 	clientID           string
 	executablePath     string
+	executionID        string
 	exportLocation     string
 	sourceFilePath     string
 	gcsComputeEndpoint string
@@ -40,15 +44,20 @@ type awsImportArguments struct {
 	gcsRegion          string
 	gcsScratchBucket   string
 	gcsStorageLocation string
+	instanceType       string
 	region             string
 	secretAccessKey    string
 	sessionToken       string
+	progressLogger     logging.Logger
+	kmsKey             string
+	tracerProvider     trace.TracerProvider
 
 	// Internal generated
 	exportBucket   string
 	exportFolder   string
 	exportKey      string
 	exportFileSize int64
+	exportETag     string
 }
 
 // Flags
@@ -60,6 +69,7 @@ const (
 	awsSessionTokenFlag      = "aws_session_token"
 	awsRegionFlag            = "aws_region"
 	awsSourceAMIFilePathFlag = "aws_source_ami_file_path"
+	awsInstanceTypeFlag      = "aws_instance_type"
 )
 
 var (
@@ -74,6 +84,7 @@ func newAWSImportArguments(args *OneStepImportArguments) *awsImportArguments {
 		amiID:              args.AWSAMIID,
 		clientID:           args.ClientID,
 		executablePath:     args.ExecutablePath,
+		executionID:        args.ExecutionID,
 		exportLocation:     args.AWSAMIExportLocation,
 		sourceFilePath:     args.AWSSourceAMIFilePath,
 		gcsComputeEndpoint: args.ComputeEndpoint,
@@ -82,9 +93,13 @@ func newAWSImportArguments(args *OneStepImportArguments) *awsImportArguments {
 		gcsRegion:          args.Region,
 		gcsScratchBucket:   args.ScratchBucketGcsPath,
 		gcsStorageLocation: args.StorageLocation,
+		instanceType:       args.AWSInstanceType,
 		region:             args.AWSRegion,
 		secretAccessKey:    args.AWSSecretAccessKey,
 		sessionToken:       args.AWSSessionToken,
+		progressLogger:     args.progressLogger,
+		kmsKey:             args.kmsKey,
+		tracerProvider:     args.tracerProvider,
 	}
 }
 
@@ -20,9 +20,7 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
-	"runtime"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/storage"
@@ -33,14 +31,16 @@ import (
 	pathutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/path"
 	storageutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/storage"
 	"github.com/GoogleCloudPlatform/compute-image-tools/daisy"
+	daisycompute "github.com/GoogleCloudPlatform/compute-image-tools/daisy/compute"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ebs"
+	"github.com/aws/aws-sdk-go/service/ebs/ebsiface"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
-	"github.com/dustin/go-humanize"
 	"google.golang.org/api/option"
 	htransport "google.golang.org/api/transport/http"
 )
@@ -56,23 +56,30 @@ const (
 type awsImporter struct {
 	args           *awsImportArguments
 	gcsClient      domain.StorageClientInterface
+	computeClient  daisycompute.Client
 	ctx            context.Context
 	oauth          string
 	paramPopulator param.Populator
 	timeoutChan    chan struct{}
-	uploader       *uploader
+
+	// Uploads the S3 file in chunks, resuming interrupted uploads.
+	resumableUploader resumableUploader
 
 	// AWS clients for SDK
+	ebsClient ebsiface.EBSAPI
 	ec2Client ec2iface.EC2API
 	s3Client  s3iface.S3API
 
 	// Impl of the functions
+	getAMIVolumesFn             func() ([]awsVolume, error)
+	copyFromEBSToGCSFn          func(volume awsVolume, index int) (string, error)
+	createInstanceFn            func(imageNames []string) error
 	exportAWSImageFn            func() error
+	resumeAWSExportFn           func() bool
 	monitorAWSExportImageTaskFn func() error
 	getAWSFileSizeFn            func() error
 	copyFromS3ToGCSFn           func() (string, error)
 	transferFileFn              func() error
-	getUploaderFn               func() *uploader
 	importImageFn               func() error
 	cleanUpFn                   func()
 }
@@ -86,17 +93,19 @@ func newAWSImporter(oauth string, timeoutChan chan struct{}, args *awsImportArgu
 		return nil, err
 	}
 
-	computeClient, err := param.CreateComputeClient(&ctx, oauth, args.gcsComputeEndpoint)
+	computeClient, err := param.CreateComputeClient(&ctx, oauth, args.gcsComputeEndpoint, args.tracerProvider)
 	if err != nil {
 		return nil, err
 	}
 
 	metadataGCE := &compute.MetadataGCE{}
+	scratchBucketCreator := storageutils.NewScratchBucketCreator(ctx, client)
+	scratchBucketCreator.KmsKey = args.kmsKey
 	paramPopulator := param.NewPopulator(
 		metadataGCE,
 		client,
 		storageutils.NewResourceLocationRetriever(metadataGCE, computeClient),
-		storageutils.NewScratchBucketCreator(ctx, client),
+		scratchBucketCreator,
 	)
 
 	awsSession, err := createAWSSession(args.region, args.accessKeyID, args.secretAccessKey, args.sessionToken)
@@ -105,14 +114,17 @@ func newAWSImporter(oauth string, timeoutChan chan struct{}, args *awsImportArgu
 	}
 
 	importer := &awsImporter{
-		args:           args,
-		gcsClient:      client,
-		s3Client:       s3.New(awsSession),
-		ec2Client:      ec2.New(awsSession),
-		ctx:            ctx,
-		oauth:          oauth,
-		paramPopulator: paramPopulator,
-		timeoutChan:    timeoutChan,
+		args:              args,
+		gcsClient:         client,
+		computeClient:     computeClient,
+		ebsClient:         ebs.New(awsSession),
+		s3Client:          s3.New(awsSession),
+		ec2Client:         ec2.New(awsSession),
+		ctx:               ctx,
+		oauth:             oauth,
+		paramPopulator:    paramPopulator,
+		timeoutChan:       timeoutChan,
+		resumableUploader: storageutils.NewParallelUploader(client, storageutils.DefaultUploadChunkSize, storageutils.DefaultUploadWorkers),
 	}
 
 	return importer, nil
@@ -165,14 +177,32 @@ func createAWSSession(region, accessKeyID, secretAccessKey, sessionToken string)
 func (importer *awsImporter) run(importArgs *OneStepImportArguments) error {
 	needsExport := importer.args.isExportRequired()
 	startTime := time.Now()
+	deadline := startTime.Add(importArgs.Timeout)
 	//1. validate AWS args
 	err := importer.args.validateAndPopulate(importer.paramPopulator)
 	if err != nil {
 		return err
 	}
 
-	// 2. export AMI to AWS S3 if user did not specify an exported AMI path.
+	// An AMI with multiple EBS volumes is imported volume by volume from their
+	// snapshots, so that each volume is imported to its own image.
 	if needsExport {
+		volumes, err := importer.getAMIVolumes()
+		if err != nil {
+			return err
+		}
+		if len(volumes) > 1 {
+			imageNames, err := importer.importAMIVolumes(importArgs, startTime, volumes)
+			if err != nil {
+				return err
+			}
+			return importer.createInstance(importArgs, deadline, imageNames)
+		}
+	}
+
+	// 2. export AMI to AWS S3 if user did not specify an exported AMI path, unless
+	// an interrupted import already exported it.
+	if needsExport && !importer.resumeAWSExport() {
 		log.Println("Starting to export image ...")
 		err = importer.exportAWSImage()
 		if err != nil {
@@ -200,23 +230,26 @@ func (importer *awsImporter) run(importArgs *OneStepImportArguments) error {
 
 	// 5. clean up temporary image files created in AWS and GCS
 	log.Println("Cleaning up ...")
-	importer.cleanUp(gcsFilePath, needsExport)
+	importer.cleanUp([]string{gcsFilePath}, needsExport)
 
-	return nil
+	// 6. create the instance or machine image, if specified
+	return importer.createInstance(importArgs, deadline, []string{importArgs.ImageName})
 }
 
 // cleanUp deletes temporary files created during image import, and closes GCS client.
-func (importer *awsImporter) cleanUp(gcsFilePath string, shouldDeleteS3File bool) {
+func (importer *awsImporter) cleanUp(gcsFilePaths []string, shouldDeleteS3File bool) {
 	if importer.cleanUpFn != nil {
 		importer.cleanUpFn()
 		return
 	}
 
-	err := importer.gcsClient.DeleteGcsPath(gcsFilePath)
-	if err != nil {
-		log.Printf("Could not delete image file %v: %v. "+
-			"To avoid incurring charges to your billing account, "+
-			"you must manually delete the file from the storage location.\n", gcsFilePath, err.Error())
+	for _, gcsFilePath := range gcsFilePaths {
+		err := importer.gcsClient.DeleteGcsPath(gcsFilePath)
+		if err != nil {
+			log.Printf("Could not delete image file %v: %v. "+
+				"To avoid incurring charges to your billing account, "+
+				"you must manually delete the file from the storage location.\n", gcsFilePath, err.Error())
+		}
 	}
 
 	importer.gcsClient.Close()
@@ -224,7 +257,7 @@ func (importer *awsImporter) cleanUp(gcsFilePath string, shouldDeleteS3File bool
 	// Only delete s3 file if the file is not pased
 	if shouldDeleteS3File {
 		log.Printf("Deleting %v.\n", importer.args.sourceFilePath)
-		_, err = importer.s3Client.DeleteObject(&s3.DeleteObjectInput{
+		_, err := importer.s3Client.DeleteObject(&s3.DeleteObjectInput{
 			Bucket: aws.String(importer.args.exportBucket),
 			Key:    aws.String(importer.args.exportKey),
 		})
@@ -243,29 +276,7 @@ func (importer *awsImporter) importImage(importArgs *OneStepImportArguments, sta
 		return importer.importImageFn()
 	}
 
-	// update source file flag to copied GCS destination
-	importArgs.SourceFile = gcsFilePath
-
-	// adjust timeout to pass into image import
-	importArgs.Timeout = importArgs.Timeout - time.Since(startTime)
-	if importArgs.Timeout <= 0 {
-		return daisy.Errf("timeout exceeded")
-	}
-
-	// add label to indicate the image import is run from onestep import
-	if importArgs.Labels == nil {
-		importArgs.Labels = make(map[string]string)
-	}
-	importArgs.Labels["onestep-image-import"] = "aws"
-
-	err := runImageImport(importArgs)
-	if err != nil {
-		log.Printf("Failed to import image. "+
-			"The image file is copied to Cloud Storage, located at %v.\n", gcsFilePath)
-		return err
-	}
-
-	return nil
+	return importFromGCS(importArgs, startTime, gcsFilePath, "aws")
 }
 
 // getAWSFileSize gets the size of the file to copy from S3 to GCS.
@@ -287,6 +298,7 @@ func (importer *awsImporter) getAWSFileSize() error {
 	}
 
 	importer.args.exportFileSize = fileSize
+	importer.args.exportETag = aws.StringValue(resp.ETag)
 	return nil
 }
 
@@ -390,7 +402,8 @@ func (importer *awsImporter) cancelAWSExportImageTask(taskID string) {
 	importer.ec2Client.CancelExportTask(&ec2.CancelExportTaskInput{ExportTaskId: aws.String(taskID)})
 }
 
-// copyFromS3ToGCS copies VMDK file from S3 to GCS.
+// copyFromS3ToGCS copies VMDK file from S3 to GCS. The copy is resumed if an
+// import with the same execution ID was interrupted while copying the file.
 func (importer *awsImporter) copyFromS3ToGCS() (string, error) {
 	if importer.copyFromS3ToGCSFn != nil {
 		return importer.copyFromS3ToGCSFn()
@@ -398,34 +411,17 @@ func (importer *awsImporter) copyFromS3ToGCS() (string, error) {
 
 	start := time.Now()
 	// 1. get GCS path as copy destination.
-	gcsFilePath := pathutils.JoinURL(importer.args.gcsScratchBucket,
-		fmt.Sprintf("onestep-image-import-aws-%v.vmdk", pathutils.RandString(5)))
-
-	log.Printf("Copying %v to %v.\n", importer.args.sourceFilePath, gcsFilePath)
-
-	// 2. create a new folder for local buffer
-	path := filepath.Join(filepath.Dir(importer.args.executablePath), fmt.Sprint("upload", pathutils.RandString(5)))
-
-	err := os.Mkdir(path, 0755)
-	if err != nil {
-		return "", daisy.ToDError(err)
-	}
-	defer os.RemoveAll(path)
-
-	// 3. get writer
-	bs, err := humanize.ParseBytes(uploadBufSize)
-	if err != nil {
-		return "", daisy.ToDError(err)
-	}
+	gcsFilePath := importer.getGCSFilePath()
 	bkt, obj, err := storageutils.GetGCSObjectPathElements(gcsFilePath)
 	if err != nil {
 		return "", err
 	}
-	workers := int64(runtime.NumCPU())
-	writer := storageutils.NewBufferedWriter(importer.ctx, int64(bs), workers, createGCSClient, importer.oauth, path, bkt, obj)
 
-	// 4. Transfer file from S3 to GCS
-	if err := importer.transferFile(writer); err != nil {
+	log.Printf("Copying %v to %v. If the copy is interrupted, rerun the import with "+
+		"-execution_id=%v to resume it.\n", importer.args.sourceFilePath, gcsFilePath, importer.args.executionID)
+
+	// 2. Transfer file from S3 to GCS
+	if err := importer.transferFile(bkt, obj); err != nil {
 		return gcsFilePath, err
 	}
 	log.Printf("Successfully copied to %v in %v.\n", gcsFilePath, time.Since(start))
@@ -433,89 +429,98 @@ func (importer *awsImporter) copyFromS3ToGCS() (string, error) {
 	return gcsFilePath, nil
 }
 
-func (importer *awsImporter) getUploader(writer io.WriteCloser) *uploader {
-	if importer.getUploaderFn != nil {
-		return importer.getUploaderFn()
-	}
+// getGCSFilePath returns the GCS path the S3 file is copied to.
+func (importer *awsImporter) getGCSFilePath() string {
+	return pathutils.JoinURL(importer.args.gcsScratchBucket,
+		fmt.Sprintf("onestep-image-import-aws-%v.vmdk", importer.args.executionID))
+}
 
-	return &uploader{
-		readerChan:    make(chan io.ReadCloser, downloadBufNum),
-		writer:        writer,
-		totalUploaded: 0,
-		totalFileSize: importer.args.exportFileSize,
-		uploadErrChan: make(chan error),
-	}
+// getSourceVersion returns the S3 file with its ETag, which identifies the
+// content of the file for resuming its copy.
+func (importer *awsImporter) getSourceVersion() string {
+	return fmt.Sprintf("%v#%v", importer.args.sourceFilePath, importer.args.exportETag)
 }
 
-// transferFile downloads S3 file and uploads to GCS concurrently.
-func (importer *awsImporter) transferFile(writer io.WriteCloser) error {
+// transferFile downloads S3 file in chunks with ranged requests, and uploads
+// them to bkt/obj concurrently. The chunks uploaded by an interrupted transfer
+// are kept, so that a rerun only transfers the missing chunks.
+func (importer *awsImporter) transferFile(bkt, obj string) error {
 	if importer.transferFileFn != nil {
 		return importer.transferFileFn()
 	}
-	// 1. Set up download size and get number of chunks to download
-	output, err := humanize.ParseBytes(downloadBufSize)
-	if err != nil {
-		return daisy.ToDError(err)
-	}
-	readSize := int64(output)
-	// Take ceiling to get number of chunks to download.
-	readers := (importer.args.exportFileSize-1)/readSize + 1
-	// Set up download retry delay interval
-	delayTime := []int{1, 2, 4, 8, 8}
-	maxRetryTimes := len(delayTime)
-
-	// 2. Set up upload info
-	importer.uploader = importer.getUploader(writer)
-	importer.uploader.Add(1)
-	go importer.uploader.uploadFile()
-
-	// 3. Range download
-	for i := int64(0); i < readers; i++ {
-		startRange := i * readSize
-		endRange := startRange + readSize - 1
-		for retryAttempt := 0; ; retryAttempt++ {
-			res, err := importer.s3Client.GetObject(&s3.GetObjectInput{
-				Bucket: aws.String(importer.args.exportBucket),
-				Key:    aws.String(importer.args.exportKey),
-				Range:  aws.String(fmt.Sprintf("bytes=%v-%v", startRange, endRange)),
-			})
-			if err != nil {
-				if retryAttempt >= maxRetryTimes {
-					return daisy.Errf("error in downloading from %v: %v", importer.args.sourceFilePath, err)
-				}
-				time.Sleep(time.Duration(delayTime[retryAttempt]) * time.Second)
-				continue
-			}
-			importer.uploader.readerChan <- res.Body
-			break
-		}
 
-		// Stop downloading as soon as one of the upload fails.
-		select {
-		case err := <-importer.uploader.uploadErrChan:
-			importer.uploader.cleanup()
-			return err
-		default:
-			// No error, continue to download.
+	ctx, cancel := importer.newTransferContext()
+	defer cancel()
+	err := importer.resumableUploader.ResumableUpload(ctx, func(offset, length int64) (io.ReadCloser, error) {
+		res, err := importer.s3Client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(importer.args.exportBucket),
+			Key:    aws.String(importer.args.exportKey),
+			Range:  aws.String(fmt.Sprintf("bytes=%v-%v", offset, offset+length-1)),
+		})
+		if err != nil {
+			return nil, err
 		}
+		return res.Body, nil
+	}, importer.args.exportFileSize, importer.getSourceVersion(), bkt, obj,
+		logging.NewTransferProgress(importer.args.progressLogger, logging.PhaseCopy, importer.args.exportFileSize))
+	if err == nil {
+		return nil
+	}
+
+	log.Printf("The copy to gs://%v/%v is interrupted. Rerun the import with -execution_id=%v to resume it, "+
+		"or delete gs://%v/%v_chunks to avoid incurring charges to your billing account.\n",
+		bkt, obj, importer.args.executionID, bkt, obj)
+	select {
+	case <-importer.timeoutChan:
+		return daisy.Errf("timeout exceeded during transfer file")
+	default:
+		return daisy.Errf("error in downloading from %v: %v", importer.args.sourceFilePath, err)
+	}
+}
 
-		// Stop downloading if timeout exceeded.
+// newTransferContext returns a context to transfer files with, which is
+// cancelled when timeout exceeded.
+func (importer *awsImporter) newTransferContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(importer.ctx)
+	go func() {
 		select {
 		case <-importer.timeoutChan:
-			importer.uploader.cleanup()
-			return daisy.Errf("timeout exceeded during transfer file")
-		default:
-			// Did not timeout, continue to download.
+			cancel()
+		case <-ctx.Done():
 		}
-	}
+	}()
+	return ctx, cancel
+}
 
-	// All file chunks are downloaded, wait for upload to finish.
-	close(importer.uploader.readerChan)
-	importer.uploader.Wait()
+// resumeAWSExport returns true if an import with the same execution ID was
+// interrupted while copying an image exported to the export location, and the
+// exported image is unchanged. The exported image is then copied instead of
+// exporting the AMI again.
+func (importer *awsImporter) resumeAWSExport() bool {
+	if importer.resumeAWSExportFn != nil {
+		return importer.resumeAWSExportFn()
+	}
 
-	err = importer.uploader.writer.Close()
+	bkt, obj, err := storageutils.GetGCSObjectPathElements(importer.getGCSFilePath())
 	if err != nil {
-		return daisy.ToDError(err)
+		return false
 	}
-	return nil
+	source := storageutils.InterruptedUploadSource(importer.gcsClient, bkt, obj)
+	i := strings.LastIndex(source, "#")
+	if i < 0 {
+		return false
+	}
+	sourceFilePath, etag := source[:i], source[i+1:]
+	exportBucket, exportKey, err := splitS3Path(sourceFilePath)
+	if err != nil || exportBucket != importer.args.exportBucket || !strings.HasPrefix(exportKey, importer.args.exportFolder) {
+		return false
+	}
+
+	importer.args.exportKey, importer.args.sourceFilePath = exportKey, sourceFilePath
+	if err := importer.getAWSFileSize(); err != nil || importer.args.exportETag != etag {
+		importer.args.exportKey, importer.args.sourceFilePath = "", ""
+		return false
+	}
+	log.Printf("Resuming the copy of %v, exported by an interrupted import.\n", sourceFilePath)
+	return true
 }
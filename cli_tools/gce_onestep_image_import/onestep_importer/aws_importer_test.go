@@ -15,16 +15,18 @@
 package importer
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -32,11 +34,16 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/domain"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
+	storageutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/storage"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/mocks"
 )
 
 var (
-	shouldSetGetObjectDelay = false
+	// Content of the S3 object served in ranges, instead of getObjectResp.
+	s3ObjectContent string
+	getObjectRanges []string
 
 	getObjectResp struct {
 		output *s3.GetObjectOutput
@@ -61,6 +68,7 @@ var (
 )
 
 func resetAPIOutput() {
+	s3ObjectContent, getObjectRanges = "", nil
 	getObjectResp.output, getObjectResp.err = &s3.GetObjectOutput{}, nil
 	headObjectResp.output, headObjectResp.err = &s3.HeadObjectOutput{ContentLength: aws.Int64(10)}, nil
 	deleteObjectResp.output, deleteObjectResp.err = &s3.DeleteObjectOutput{}, nil
@@ -76,10 +84,12 @@ func (m *mockS3Client) HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjectOutp
 	return headObjectResp.output, headObjectResp.err
 }
 
-func (m *mockS3Client) GetObject(*s3.GetObjectInput) (*s3.GetObjectOutput, error) {
-	// set delay so uploader has time to set values for tests
-	if shouldSetGetObjectDelay {
-		time.Sleep(time.Second * 3)
+func (m *mockS3Client) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, _ ...request.Option) (*s3.GetObjectOutput, error) {
+	getObjectRanges = append(getObjectRanges, aws.StringValue(input.Range))
+	if getObjectResp.err == nil && s3ObjectContent != "" {
+		var start, end int
+		fmt.Sscanf(aws.StringValue(input.Range), "bytes=%d-%d", &start, &end)
+		return &s3.GetObjectOutput{Body: ioutil.NopCloser(strings.NewReader(s3ObjectContent[start : end+1]))}, nil
 	}
 	return getObjectResp.output, getObjectResp.err
 }
@@ -447,123 +457,132 @@ func TestCopyS3FileReturnGCSPath(t *testing.T) {
 	assert.NoError(t, err)
 }
 
-func TestTransferFileDownloadError(t *testing.T) {
+func TestCopyS3FileToExecutionIDPath(t *testing.T) {
 	resetAPIOutput()
-	var output bytes.Buffer
-	writer := testWriteCloser{Writer: bufio.NewWriter(&output)}
+	args := setUpAWSArgs("", false, "-execution_id=ABC12")
+	awsImporter := getAWSImporter(t, args)
+	awsImporter.copyFromS3ToGCSFn = nil
+	awsImporter.args.gcsScratchBucket = "gs://bucket/dir"
+	path, err := awsImporter.copyFromS3ToGCS()
+	assert.NoError(t, err)
+	assert.Equal(t, "gs://bucket/dir/onestep-image-import-aws-abc12.vmdk", path)
+}
 
+func TestTransferFileDownloadError(t *testing.T) {
+	resetAPIOutput()
 	args := setUpAWSArgs("", false)
 	awsImporter := getAWSImporter(t, args)
 	awsImporter.transferFileFn = nil
+	awsImporter.args.exportFileSize = 10
 	getObjectResp.err = fmt.Errorf("download file failed")
 
-	err := awsImporter.transferFile(writer)
-	assert.Contains(t, err.Error(), "download file failed")
+	err := awsImporter.transferFile("bucket", "disk.vmdk")
+	assert.EqualError(t, err, "error in downloading from s3://bucket/object: download file failed")
 }
 
-func TestTransferFileDownloadToReaderChan(t *testing.T) {
+func TestTransferFileUploadsRangesOfS3File(t *testing.T) {
 	resetAPIOutput()
-
-	var output bytes.Buffer
-	writer := testWriteCloser{Writer: bufio.NewWriter(&output)}
-
 	args := setUpAWSArgs("", false)
 	awsImporter := getAWSImporter(t, args)
 	awsImporter.transferFileFn = nil
-	getObjectResp.output = &s3.GetObjectOutput{
-		Body: ioutil.NopCloser(bytes.NewReader([]byte("file data"))),
-	}
-
-	awsImporter.getUploaderFn = func() *uploader {
-		u := getTestUploader(writer)
-		u.uploadFileFn = func() {
-			awsImporter.uploader.Done()
-		}
-		return u
-	}
-
-	err := awsImporter.transferFile(writer)
-	assert.NoError(t, err)
-	assert.Len(t, awsImporter.uploader.readerChan, 1)
+	awsImporter.args.exportFileSize = 10
+	awsImporter.args.exportETag = "etag"
+	s3ObjectContent = "0123456789"
+	uploader := &fakeResumableUploader{chunkSize: 4}
+	awsImporter.resumableUploader = uploader
 
-	reader := <-awsImporter.uploader.readerChan
-	io.Copy(writer, reader)
-	assert.Contains(t, output.String(), "file data")
+	assert.NoError(t, awsImporter.transferFile("bucket", "disk.vmdk"))
+	assert.Equal(t, "0123456789", uploader.content)
+	assert.Equal(t, []string{"bytes=0-3", "bytes=4-7", "bytes=8-9"}, getObjectRanges)
+	assert.Equal(t, "s3://bucket/object#etag", uploader.source, "the source should identify the version of the S3 file")
+	assert.Equal(t, "bucket", uploader.bkt)
+	assert.Equal(t, "disk.vmdk", uploader.obj)
 }
 
-func TestTransferFileErrorWhenUploadError(t *testing.T) {
+func TestTransferFileReturnErrorWhenUploadError(t *testing.T) {
 	resetAPIOutput()
-
-	var output bytes.Buffer
-	writer := testWriteCloser{Writer: bufio.NewWriter(&output)}
-
 	args := setUpAWSArgs("", false)
 	awsImporter := getAWSImporter(t, args)
 	awsImporter.transferFileFn = nil
-	getObjectResp.output = &s3.GetObjectOutput{
-		Body: ioutil.NopCloser(bytes.NewReader([]byte("file data"))),
-	}
-
-	awsImporter.getUploaderFn = func() *uploader {
-		u := getTestUploader(writer)
-		u.uploadFileFn = func() {
-			awsImporter.uploader.uploadErrChan <- fmt.Errorf("upload error")
-			awsImporter.uploader.Done()
-		}
-		return u
-	}
+	awsImporter.resumableUploader = &fakeResumableUploader{err: fmt.Errorf("upload error")}
 
-	// delay during getObject so uploadFileFn has time to finish updating uploadErr
-	shouldSetGetObjectDelay = true
-	err := awsImporter.transferFile(writer)
-	assert.NotNil(t, err)
+	err := awsImporter.transferFile("bucket", "disk.vmdk")
+	assert.EqualError(t, err, "error in downloading from s3://bucket/object: upload error")
 }
 
-func TestTransferFileCallsCleanupWhenTimeout(t *testing.T) {
+func TestTransferFileReturnErrorWhenTimeout(t *testing.T) {
 	resetAPIOutput()
-
-	var output bytes.Buffer
-	writer := testWriteCloser{Writer: bufio.NewWriter(&output)}
-
 	args := setUpAWSArgs("", false)
 	awsImporter := getAWSImporter(t, args)
 	close(awsImporter.timeoutChan)
 	awsImporter.transferFileFn = nil
-	getObjectResp.output = &s3.GetObjectOutput{
-		Body: ioutil.NopCloser(bytes.NewReader([]byte("file data"))),
-	}
+	awsImporter.args.exportFileSize = 10
+	awsImporter.resumableUploader = &fakeResumableUploader{chunkSize: 4, waitForCancel: true}
 
-	var isCleanupCalled = false
-	awsImporter.getUploaderFn = func() *uploader {
-		u := getTestUploader(writer)
-		u.cleanupFn = func() {
-			isCleanupCalled = true
-		}
-		return u
-	}
+	err := awsImporter.transferFile("bucket", "disk.vmdk")
+	assert.EqualError(t, err, "timeout exceeded during transfer file")
+}
 
-	err := awsImporter.transferFile(writer)
-	assert.NotNil(t, err)
-	assert.Contains(t, err.Error(), "timeout exceeded during transfer file")
-	assert.True(t, isCleanupCalled)
+func TestResumeAWSExportOfInterruptedCopy(t *testing.T) {
+	resetAPIOutput()
+	awsImporter := getResumingAWSImporter(t, "s3://bucket/folder/task-id.vmdk#etag")
+	headObjectResp.output.ETag = aws.String("etag")
+
+	assert.True(t, awsImporter.resumeAWSExport())
+	assert.Equal(t, "folder/task-id.vmdk", awsImporter.args.exportKey)
+	assert.Equal(t, "s3://bucket/folder/task-id.vmdk", awsImporter.args.sourceFilePath)
+	assert.EqualValues(t, 10, awsImporter.args.exportFileSize)
 }
 
-func TestTransferFileReturnErrorWhenWriterCloseFail(t *testing.T) {
+func TestResumeAWSExportFailsWhenExportedFileChanged(t *testing.T) {
 	resetAPIOutput()
+	awsImporter := getResumingAWSImporter(t, "s3://bucket/folder/task-id.vmdk#etag")
+	headObjectResp.output.ETag = aws.String("other-etag")
 
-	var output bytes.Buffer
-	writer := testWriteCloser{Writer: bufio.NewWriter(&output)}
-	writer.closeReturnVal = fmt.Errorf("close writer failed")
+	assert.False(t, awsImporter.resumeAWSExport())
+	assert.Empty(t, awsImporter.args.exportKey)
+	assert.Empty(t, awsImporter.args.sourceFilePath)
+}
 
-	args := setUpAWSArgs("", false)
-	awsImporter := getAWSImporter(t, args)
-	awsImporter.transferFileFn = nil
-	getObjectResp.output = &s3.GetObjectOutput{
-		Body: ioutil.NopCloser(bytes.NewReader([]byte("file data"))),
-	}
-	err := awsImporter.transferFile(writer)
-	assert.NotNil(t, err)
-	assert.Contains(t, err.Error(), "close writer failed")
+func TestResumeAWSExportFailsWhenExportLocationChanged(t *testing.T) {
+	resetAPIOutput()
+	awsImporter := getResumingAWSImporter(t, "s3://bucket/other-folder/task-id.vmdk#etag")
+	headObjectResp.output.ETag = aws.String("etag")
+
+	assert.False(t, awsImporter.resumeAWSExport())
+}
+
+func TestResumeAWSExportFailsWithoutInterruptedCopy(t *testing.T) {
+	resetAPIOutput()
+	awsImporter := getResumingAWSImporter(t, "")
+
+	assert.False(t, awsImporter.resumeAWSExport())
+}
+
+// getResumingAWSImporter returns an awsImporter exporting to s3://bucket/folder,
+// whose previous copy was interrupted while copying source.
+func getResumingAWSImporter(t *testing.T, source string) *awsImporter {
+	awsImporter := getAWSImporter(t, setUpAWSArgs(awsAMIExportLocationFlag, true, "-aws_ami_export_location=s3://bucket/folder"))
+	awsImporter.args.gcsScratchBucket = "gs://scratch"
+	assert.NoError(t, awsImporter.args.generateS3PathElements())
+	awsImporter.resumeAWSExportFn = nil
+	awsImporter.getAWSFileSizeFn = nil
+
+	mockCtrl := gomock.NewController(t)
+	mockStorageClient := mocks.NewMockStorageClientInterface(mockCtrl)
+	mockStorageClient.EXPECT().GetObject("scratch", fmt.Sprintf("onestep-image-import-aws-%v.vmdk_chunks/manifest.json",
+		awsImporter.args.executionID)).DoAndReturn(func(_, _ string) domain.StorageObject {
+		manifest := mocks.NewMockStorageObject(mockCtrl)
+		manifest.EXPECT().NewReader().DoAndReturn(func() (io.ReadCloser, error) {
+			if source == "" {
+				return nil, fmt.Errorf("object doesn't exist")
+			}
+			return ioutil.NopCloser(strings.NewReader(fmt.Sprintf(`{"source": %q}`, source))), nil
+		})
+		return manifest
+	})
+	awsImporter.gcsClient = mockStorageClient
+	return awsImporter
 }
 
 func TestImportImageUpdateImporterArgs(t *testing.T) {
@@ -635,7 +654,7 @@ func TestCleanupDeleteGCSPath(t *testing.T) {
 	awsImporter := getAWSImporter(t, args)
 	awsImporter.gcsClient = mockStorageClient
 	awsImporter.cleanUpFn = nil
-	awsImporter.cleanUp(gcsPath, false)
+	awsImporter.cleanUp([]string{gcsPath}, false)
 }
 
 func TestCleanupDeleteGCSPathError(t *testing.T) {
@@ -655,7 +674,7 @@ func TestCleanupDeleteGCSPathError(t *testing.T) {
 	awsImporter := getAWSImporter(t, args)
 	awsImporter.gcsClient = mockStorageClient
 	awsImporter.cleanUpFn = nil
-	awsImporter.cleanUp(gcsPath, false)
+	awsImporter.cleanUp([]string{gcsPath}, false)
 
 	assert.Contains(t, buf.String(), fmt.Sprintf("Could not delete image file %v: %v. "+
 		"To avoid incurring charges to your billing account, "+
@@ -679,23 +698,72 @@ func TestCleanupDeleteS3PathError(t *testing.T) {
 	awsImporter := getAWSImporter(t, args)
 	awsImporter.gcsClient = mockStorageClient
 	awsImporter.cleanUpFn = nil
-	awsImporter.cleanUp("", true)
+	awsImporter.cleanUp([]string{""}, true)
 
 	assert.Contains(t, buf.String(), fmt.Sprintf("Could not delete image file %v: %v. "+
 		"To avoid incurring charges to your billing account, "+
 		"you must manually delete the file from the storage location.", "s3://bucket/object", errMsg))
 }
 
+// fakeResumableUploader reads the chunks of an upload, in order, into content.
+type fakeResumableUploader struct {
+	chunkSize        int64
+	source, bkt, obj string
+	content          string
+	err              error
+	// Waits for the upload to be cancelled before reading chunks.
+	waitForCancel bool
+}
+
+func (u *fakeResumableUploader) ResumableUpload(ctx context.Context, open storageutils.RangeOpener, size int64, source, bkt, obj string,
+	progress *logging.TransferProgress) error {
+	u.source, u.bkt, u.obj = source, bkt, obj
+	if u.err != nil {
+		return u.err
+	}
+	if u.waitForCancel {
+		<-ctx.Done()
+	}
+	for offset := int64(0); offset < size; offset += u.chunkSize {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		length := u.chunkSize
+		if offset+length > size {
+			length = size - offset
+		}
+		r, err := open(offset, length)
+		if err != nil {
+			return err
+		}
+		b, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		u.content += string(b)
+	}
+	return nil
+}
+
 func getAWSImporter(t *testing.T, args []string) *awsImporter {
 	awsArgs := getAWSImportArgs(args)
 	awsImporter, err := newAWSImporter("", make(chan struct{}), awsArgs)
 	assert.Nil(t, err)
 
+	awsImporter.ebsClient = &mockEBSClient{}
 	awsImporter.ec2Client = &mockEC2Client{}
 	awsImporter.s3Client = &mockS3Client{}
 	awsImporter.paramPopulator = mockPopulator{}
 
+	awsImporter.resumableUploader = &fakeResumableUploader{chunkSize: 4}
+	awsImporter.getAMIVolumesFn = func() ([]awsVolume, error) {
+		return []awsVolume{{deviceName: "/dev/sda1", snapshotID: "snap-boot", isBoot: true}}, nil
+	}
+	awsImporter.copyFromEBSToGCSFn = func(awsVolume, int) (string, error) { return "", nil }
+	awsImporter.createInstanceFn = func([]string) error { return nil }
 	awsImporter.exportAWSImageFn = func() error { return nil }
+	awsImporter.resumeAWSExportFn = func() bool { return false }
 	awsImporter.monitorAWSExportImageTaskFn = func() error { return nil }
 	awsImporter.getAWSFileSizeFn = func() error { return nil }
 	awsImporter.copyFromS3ToGCSFn = func() (string, error) { return "", nil }
@@ -0,0 +1,337 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ebs"
+	"github.com/aws/aws-sdk-go/service/ebs/ebsiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
+	pathutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/path"
+	storageutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/storage"
+	ovfgceutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/gce_ovf_import/gce_utils"
+	"github.com/GoogleCloudPlatform/compute-image-tools/daisy"
+)
+
+// awsVolume is an EBS volume in the block device mappings of an AMI.
+type awsVolume struct {
+	deviceName string
+	snapshotID string
+	isBoot     bool
+}
+
+// getAMIVolumes returns the EBS volumes of the AMI, starting with its boot volume.
+func (importer *awsImporter) getAMIVolumes() ([]awsVolume, error) {
+	if importer.getAMIVolumesFn != nil {
+		return importer.getAMIVolumesFn()
+	}
+
+	resp, err := importer.ec2Client.DescribeImages(&ec2.DescribeImagesInput{
+		ImageIds: []*string{aws.String(importer.args.amiID)},
+	})
+	if err != nil {
+		return nil, daisy.Errf("failed to describe AWS image: %v", err)
+	}
+	if len(resp.Images) != 1 {
+		return nil, daisy.Errf("failed to describe AWS image: unexpected response")
+	}
+
+	image := resp.Images[0]
+	var volumes []awsVolume
+	for _, mapping := range image.BlockDeviceMappings {
+		// Instance store volumes have no snapshot to import.
+		if mapping.Ebs == nil || aws.StringValue(mapping.Ebs.SnapshotId) == "" {
+			continue
+		}
+		volume := awsVolume{
+			deviceName: aws.StringValue(mapping.DeviceName),
+			snapshotID: aws.StringValue(mapping.Ebs.SnapshotId),
+			isBoot:     aws.StringValue(mapping.DeviceName) == aws.StringValue(image.RootDeviceName),
+		}
+		if volume.isBoot {
+			volumes = append([]awsVolume{volume}, volumes...)
+		} else {
+			volumes = append(volumes, volume)
+		}
+	}
+	if len(volumes) == 0 || !volumes[0].isBoot {
+		return nil, daisy.Errf("AWS image %v has no EBS boot volume", importer.args.amiID)
+	}
+	return volumes, nil
+}
+
+// importAMIVolumes copies each EBS volume of the AMI from its snapshot to GCS,
+// and imports it to an image. The boot volume is translated to the image
+// named by importArgs, and the other volumes are imported as data disks to
+// images with a -data-N suffix. The names of the images are returned, the
+// boot image first.
+func (importer *awsImporter) importAMIVolumes(importArgs *OneStepImportArguments, startTime time.Time,
+	volumes []awsVolume) ([]string, error) {
+	var imageNames, gcsFilePaths []string
+	for i, volume := range volumes {
+		log.Printf("Starting to copy volume %v ...\n", volume.deviceName)
+		gcsFilePath, err := importer.copyFromEBSToGCS(volume, i)
+		if err != nil {
+			importer.cleanUp(gcsFilePaths, false)
+			return nil, err
+		}
+		gcsFilePaths = append(gcsFilePaths, gcsFilePath)
+
+		diskArgs := importArgs
+		if !volume.isBoot {
+			dataDiskArgs := *importArgs
+			dataDiskArgs.DataDisk = true
+			dataDiskArgs.ImageName = fmt.Sprintf("%v-data-%v", importArgs.ImageName, i)
+			diskArgs = &dataDiskArgs
+		}
+		log.Printf("Starting to import volume %v to image %v ...\n", volume.deviceName, diskArgs.ImageName)
+		if err := importer.importImage(diskArgs, startTime, gcsFilePath); err != nil {
+			importer.cleanUp(gcsFilePaths, false)
+			return nil, err
+		}
+		imageNames = append(imageNames, diskArgs.ImageName)
+	}
+	log.Println("Volume import from AWS finished successfully!")
+
+	log.Println("Cleaning up ...")
+	importer.cleanUp(gcsFilePaths, false)
+	return imageNames, nil
+}
+
+// copyFromEBSToGCS copies the content of volume from its snapshot to GCS, with
+// the EBS direct APIs, as a raw disk file. The copy is resumed if an import
+// with the same execution ID was interrupted while copying the volume.
+func (importer *awsImporter) copyFromEBSToGCS(volume awsVolume, index int) (string, error) {
+	if importer.copyFromEBSToGCSFn != nil {
+		return importer.copyFromEBSToGCSFn(volume, index)
+	}
+
+	start := time.Now()
+	gcsFilePath := pathutils.JoinURL(importer.args.gcsScratchBucket,
+		fmt.Sprintf("onestep-image-import-aws-%v-disk%v.raw", importer.args.executionID, index))
+	bkt, obj, err := storageutils.GetGCSObjectPathElements(gcsFilePath)
+	if err != nil {
+		return "", err
+	}
+	snapshot, err := importer.listSnapshotBlocks(volume.snapshotID)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("Copying snapshot %v of volume %v to %v. If the copy is interrupted, rerun the import with "+
+		"-execution_id=%v to resume it.\n", volume.snapshotID, volume.deviceName, gcsFilePath, importer.args.executionID)
+
+	ctx, cancel := importer.newTransferContext()
+	defer cancel()
+	// Snapshots are immutable, so the ID identifies the content to resume the copy of.
+	err = importer.resumableUploader.ResumableUpload(ctx, snapshot.rangeOpener(ctx), snapshot.size,
+		volume.snapshotID, bkt, obj, logging.NewTransferProgress(importer.args.progressLogger, logging.PhaseCopy, snapshot.size))
+	if err != nil {
+		log.Printf("The copy to %v is interrupted. Rerun the import with -execution_id=%v to resume it, "+
+			"or delete %v_chunks to avoid incurring charges to your billing account.\n",
+			gcsFilePath, importer.args.executionID, gcsFilePath)
+		select {
+		case <-importer.timeoutChan:
+			return "", daisy.Errf("timeout exceeded during transfer file")
+		default:
+			return "", daisy.Errf("error in copying snapshot %v: %v", volume.snapshotID, err)
+		}
+	}
+	log.Printf("Successfully copied to %v in %v.\n", gcsFilePath, time.Since(start))
+	return gcsFilePath, nil
+}
+
+// ebsSnapshot is an EBS snapshot read with the EBS direct APIs.
+type ebsSnapshot struct {
+	client     ebsiface.EBSAPI
+	snapshotID string
+	size       int64
+	blockSize  int64
+
+	// Tokens to read the blocks holding data, by block index. The other
+	// blocks of the volume are zeroed.
+	blockTokens map[int64]string
+	zeroBlock   []byte
+}
+
+// listSnapshotBlocks lists the blocks of snapshotID holding data.
+func (importer *awsImporter) listSnapshotBlocks(snapshotID string) (*ebsSnapshot, error) {
+	snapshot := &ebsSnapshot{
+		client:      importer.ebsClient,
+		snapshotID:  snapshotID,
+		blockTokens: map[int64]string{},
+	}
+	err := importer.ebsClient.ListSnapshotBlocksPages(&ebs.ListSnapshotBlocksInput{
+		SnapshotId: aws.String(snapshotID),
+	}, func(page *ebs.ListSnapshotBlocksOutput, lastPage bool) bool {
+		snapshot.blockSize = aws.Int64Value(page.BlockSize)
+		// The volume size is in GiB.
+		snapshot.size = aws.Int64Value(page.VolumeSize) << 30
+		for _, block := range page.Blocks {
+			snapshot.blockTokens[aws.Int64Value(block.BlockIndex)] = aws.StringValue(block.BlockToken)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, daisy.Errf("failed to list blocks of snapshot %v: %v", snapshotID, err)
+	}
+	if snapshot.blockSize <= 0 || snapshot.size <= 0 {
+		return nil, daisy.Errf("snapshot %v is empty", snapshotID)
+	}
+	snapshot.zeroBlock = make([]byte, snapshot.blockSize)
+	return snapshot, nil
+}
+
+// rangeOpener returns a RangeOpener of the content of the volume.
+func (snapshot *ebsSnapshot) rangeOpener(ctx context.Context) storageutils.RangeOpener {
+	return func(offset, length int64) (io.ReadCloser, error) {
+		return &ebsSnapshotReader{
+			ctx:        ctx,
+			snapshot:   snapshot,
+			offset:     offset,
+			end:        offset + length,
+			blockIndex: -1,
+		}, nil
+	}
+}
+
+// readBlock reads the block at index.
+func (snapshot *ebsSnapshot) readBlock(ctx context.Context, index int64) ([]byte, error) {
+	token, found := snapshot.blockTokens[index]
+	if !found {
+		return snapshot.zeroBlock, nil
+	}
+	resp, err := snapshot.client.GetSnapshotBlockWithContext(ctx, &ebs.GetSnapshotBlockInput{
+		SnapshotId: aws.String(snapshot.snapshotID),
+		BlockIndex: aws.Int64(index),
+		BlockToken: aws.String(token),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.BlockData.Close()
+	block := make([]byte, snapshot.blockSize)
+	if _, err := io.ReadFull(resp.BlockData, block); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// ebsSnapshotReader reads the bytes of a snapshot from offset to end,
+// exclusive, one block at a time.
+type ebsSnapshotReader struct {
+	ctx         context.Context
+	snapshot    *ebsSnapshot
+	offset, end int64
+	block       []byte
+	blockIndex  int64
+}
+
+func (r *ebsSnapshotReader) Read(p []byte) (int, error) {
+	if r.offset >= r.end {
+		return 0, io.EOF
+	}
+	index := r.offset / r.snapshot.blockSize
+	if index != r.blockIndex {
+		block, err := r.snapshot.readBlock(r.ctx, index)
+		if err != nil {
+			return 0, err
+		}
+		r.block, r.blockIndex = block, index
+	}
+	start := r.offset - index*r.snapshot.blockSize
+	end := int64(len(r.block))
+	if remaining := r.end - r.offset; start+remaining < end {
+		end = start + remaining
+	}
+	n := copy(p, r.block[start:end])
+	r.offset += int64(n)
+	return n, nil
+}
+
+func (r *ebsSnapshotReader) Close() error {
+	return nil
+}
+
+// createInstance creates the instance or machine image specified by importArgs
+// from the imported images, if one is specified.
+func (importer *awsImporter) createInstance(importArgs *OneStepImportArguments, deadline time.Time,
+	imageNames []string) error {
+	if !importArgs.isInstanceImport() && !importArgs.isMachineImageImport() {
+		return nil
+	}
+	if importer.createInstanceFn != nil {
+		return importer.createInstanceFn(imageNames)
+	}
+
+	machineType, err := importer.getMachineType(importArgs)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Starting to create %v ...\n", getInstanceImportTarget(importArgs))
+	err = createInstanceFromImages(importer.ctx, importArgs, instanceCreationParams{
+		project:       *importer.args.gcsProjectPtr,
+		zone:          importer.args.gcsZone,
+		scratchBucket: importer.args.gcsScratchBucket,
+		machineType:   machineType,
+		deadline:      deadline,
+		imageNames:    imageNames,
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Successfully created %v.\n", getInstanceImportTarget(importArgs))
+	return nil
+}
+
+// getMachineType returns the machine type set by -machine_type, or else the
+// machine type with the fewest vCPUs and least memory that are at least those
+// of the EC2 instance type. An empty machine type is returned if neither is
+// specified, so that the default of the workflow is used.
+func (importer *awsImporter) getMachineType(importArgs *OneStepImportArguments) (string, error) {
+	if importArgs.MachineType != "" || importer.args.instanceType == "" {
+		return importArgs.MachineType, nil
+	}
+
+	resp, err := importer.ec2Client.DescribeInstanceTypes(&ec2.DescribeInstanceTypesInput{
+		InstanceTypes: []*string{aws.String(importer.args.instanceType)},
+	})
+	if err != nil {
+		return "", daisy.Errf("failed to describe AWS instance type %v: %v", importer.args.instanceType, err)
+	}
+	if len(resp.InstanceTypes) != 1 || resp.InstanceTypes[0].VCpuInfo == nil || resp.InstanceTypes[0].MemoryInfo == nil {
+		return "", daisy.Errf("failed to describe AWS instance type %v: unexpected response", importer.args.instanceType)
+	}
+	cpuCount := aws.Int64Value(resp.InstanceTypes[0].VCpuInfo.DefaultVCpus)
+	memoryMB := aws.Int64Value(resp.InstanceTypes[0].MemoryInfo.SizeInMiB)
+
+	machineType, err := ovfgceutils.GetMachineTypeForResources(importer.computeClient,
+		*importer.args.gcsProjectPtr, importer.args.gcsZone, cpuCount, memoryMB)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("Using machine type %v for AWS instance type %v, with %v vCPUs and %v MiB of memory.\n",
+		machineType, importer.args.instanceType, cpuCount, memoryMB)
+	return machineType, nil
+}
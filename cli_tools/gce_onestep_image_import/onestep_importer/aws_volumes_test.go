@@ -0,0 +1,346 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ebs"
+	"github.com/aws/aws-sdk-go/service/ebs/ebsiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/compute/v1"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/mocks"
+)
+
+var (
+	describeImagesResp struct {
+		output *ec2.DescribeImagesOutput
+		err    error
+	}
+	describeInstanceTypesResp struct {
+		output *ec2.DescribeInstanceTypesOutput
+		err    error
+	}
+)
+
+func (m *mockEC2Client) DescribeImages(*ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error) {
+	return describeImagesResp.output, describeImagesResp.err
+}
+
+func (m *mockEC2Client) DescribeInstanceTypes(*ec2.DescribeInstanceTypesInput) (*ec2.DescribeInstanceTypesOutput, error) {
+	return describeInstanceTypesResp.output, describeInstanceTypesResp.err
+}
+
+// mockEBSClient serves the pages of blocks of a snapshot, and the content of
+// each block.
+type mockEBSClient struct {
+	ebsiface.EBSAPI
+	pages  []*ebs.ListSnapshotBlocksOutput
+	blocks map[string]string
+	err    error
+}
+
+func (m *mockEBSClient) ListSnapshotBlocksPages(input *ebs.ListSnapshotBlocksInput, fn func(*ebs.ListSnapshotBlocksOutput, bool) bool) error {
+	if m.err != nil {
+		return m.err
+	}
+	for i, page := range m.pages {
+		if !fn(page, i == len(m.pages)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func (m *mockEBSClient) GetSnapshotBlockWithContext(ctx aws.Context, input *ebs.GetSnapshotBlockInput, _ ...request.Option) (*ebs.GetSnapshotBlockOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &ebs.GetSnapshotBlockOutput{
+		BlockData: ioutil.NopCloser(strings.NewReader(m.blocks[aws.StringValue(input.BlockToken)])),
+	}, nil
+}
+
+func newBlockDeviceMapping(deviceName, snapshotID string) *ec2.BlockDeviceMapping {
+	mapping := &ec2.BlockDeviceMapping{DeviceName: aws.String(deviceName)}
+	if snapshotID != "" {
+		mapping.Ebs = &ec2.EbsBlockDevice{SnapshotId: aws.String(snapshotID)}
+	}
+	return mapping
+}
+
+func TestGetAMIVolumesReturnsBootVolumeFirst(t *testing.T) {
+	describeImagesResp.output, describeImagesResp.err = &ec2.DescribeImagesOutput{Images: []*ec2.Image{{
+		RootDeviceName: aws.String("/dev/sda1"),
+		BlockDeviceMappings: []*ec2.BlockDeviceMapping{
+			newBlockDeviceMapping("/dev/sdb", "snap-data"),
+			newBlockDeviceMapping("/dev/sdc", ""),
+			newBlockDeviceMapping("/dev/sda1", "snap-boot"),
+		},
+	}}}, nil
+	awsImporter := getAWSImporter(t, setUpAWSArgs("", true))
+	awsImporter.getAMIVolumesFn = nil
+
+	volumes, err := awsImporter.getAMIVolumes()
+	assert.NoError(t, err)
+	assert.Equal(t, []awsVolume{
+		{deviceName: "/dev/sda1", snapshotID: "snap-boot", isBoot: true},
+		{deviceName: "/dev/sdb", snapshotID: "snap-data"},
+	}, volumes)
+}
+
+func TestGetAMIVolumesReturnErrorWithoutBootVolume(t *testing.T) {
+	describeImagesResp.output, describeImagesResp.err = &ec2.DescribeImagesOutput{Images: []*ec2.Image{{
+		RootDeviceName:      aws.String("/dev/sda1"),
+		BlockDeviceMappings: []*ec2.BlockDeviceMapping{newBlockDeviceMapping("/dev/sdb", "snap-data")},
+	}}}, nil
+	awsImporter := getAWSImporter(t, setUpAWSArgs("", true))
+	awsImporter.getAMIVolumesFn = nil
+
+	_, err := awsImporter.getAMIVolumes()
+	assert.EqualError(t, err, "AWS image my-ami-id has no EBS boot volume")
+}
+
+func TestGetAMIVolumesReturnErrorWhenCallError(t *testing.T) {
+	describeImagesResp.output, describeImagesResp.err = nil, fmt.Errorf("error")
+	awsImporter := getAWSImporter(t, setUpAWSArgs("", true))
+	awsImporter.getAMIVolumesFn = nil
+
+	_, err := awsImporter.getAMIVolumes()
+	assert.EqualError(t, err, "failed to describe AWS image: error")
+}
+
+func TestRunImporterImportsEachVolume(t *testing.T) {
+	args := setUpAWSArgs("", true, "-instance_name=instance")
+	awsImporter := getAWSImporter(t, args)
+	importArgs, err := NewOneStepImportArguments(args)
+	assert.Nil(t, err)
+
+	awsImporter.getAMIVolumesFn = func() ([]awsVolume, error) {
+		return []awsVolume{
+			{deviceName: "/dev/sda1", snapshotID: "snap-boot", isBoot: true},
+			{deviceName: "/dev/sdb", snapshotID: "snap-data1"},
+			{deviceName: "/dev/sdc", snapshotID: "snap-data2"},
+		}, nil
+	}
+	var copiedSnapshots []string
+	awsImporter.copyFromEBSToGCSFn = func(volume awsVolume, index int) (string, error) {
+		copiedSnapshots = append(copiedSnapshots, volume.snapshotID)
+		return fmt.Sprintf("gs://bucket/disk%v.raw", index), nil
+	}
+	awsImporter.exportAWSImageFn = func() error {
+		return fmt.Errorf("AMI shouldn't be exported")
+	}
+	var createdImages []string
+	awsImporter.createInstanceFn = func(imageNames []string) error {
+		createdImages = imageNames
+		return nil
+	}
+
+	assert.NoError(t, awsImporter.run(importArgs))
+	assert.Equal(t, []string{"snap-boot", "snap-data1", "snap-data2"}, copiedSnapshots)
+	assert.Equal(t, []string{"name", "name-data-1", "name-data-2"}, createdImages)
+}
+
+func TestRunImporterCreatesInstanceFromSingleVolume(t *testing.T) {
+	args := setUpAWSArgs("", false, "-machine_image_name=machine-image")
+	awsImporter := getAWSImporter(t, args)
+	importArgs, err := NewOneStepImportArguments(args)
+	assert.Nil(t, err)
+
+	var createdImages []string
+	awsImporter.createInstanceFn = func(imageNames []string) error {
+		createdImages = imageNames
+		return fmt.Errorf("failed")
+	}
+
+	assert.EqualError(t, awsImporter.run(importArgs), "failed")
+	assert.Equal(t, []string{"name"}, createdImages)
+}
+
+func TestImportVolumesReturnErrorWhenCopyFails(t *testing.T) {
+	args := setUpAWSArgs("", true)
+	awsImporter := getAWSImporter(t, args)
+	importArgs, err := NewOneStepImportArguments(args)
+	assert.Nil(t, err)
+
+	awsImporter.copyFromEBSToGCSFn = func(volume awsVolume, index int) (string, error) {
+		return "", fmt.Errorf("copy failed")
+	}
+	_, err = awsImporter.importAMIVolumes(importArgs, time.Now(), []awsVolume{
+		{deviceName: "/dev/sda1", snapshotID: "snap-boot", isBoot: true},
+		{deviceName: "/dev/sdb", snapshotID: "snap-data"},
+	})
+	assert.EqualError(t, err, "copy failed")
+}
+
+func TestImportVolumesCleansUpCopiedVolumesWhenCopyFails(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockStorageClient := mocks.NewMockStorageClientInterface(mockCtrl)
+	mockStorageClient.EXPECT().DeleteGcsPath("gs://bucket/disk0.raw")
+	mockStorageClient.EXPECT().Close()
+
+	args := setUpAWSArgs("", true)
+	awsImporter := getAWSImporter(t, args)
+	awsImporter.gcsClient = mockStorageClient
+	awsImporter.cleanUpFn = nil
+	importArgs, err := NewOneStepImportArguments(args)
+	assert.Nil(t, err)
+
+	awsImporter.copyFromEBSToGCSFn = func(volume awsVolume, index int) (string, error) {
+		if volume.isBoot {
+			return fmt.Sprintf("gs://bucket/disk%v.raw", index), nil
+		}
+		return "", fmt.Errorf("copy failed")
+	}
+	_, err = awsImporter.importAMIVolumes(importArgs, time.Now(), []awsVolume{
+		{deviceName: "/dev/sda1", snapshotID: "snap-boot", isBoot: true},
+		{deviceName: "/dev/sdb", snapshotID: "snap-data"},
+	})
+	assert.EqualError(t, err, "copy failed")
+}
+
+func TestListSnapshotBlocks(t *testing.T) {
+	awsImporter := getAWSImporter(t, setUpAWSArgs("", true))
+	awsImporter.ebsClient = &mockEBSClient{pages: []*ebs.ListSnapshotBlocksOutput{
+		{BlockSize: aws.Int64(512), VolumeSize: aws.Int64(8), Blocks: []*ebs.Block{
+			{BlockIndex: aws.Int64(0), BlockToken: aws.String("token0")},
+		}},
+		{BlockSize: aws.Int64(512), VolumeSize: aws.Int64(8), Blocks: []*ebs.Block{
+			{BlockIndex: aws.Int64(3), BlockToken: aws.String("token3")},
+		}},
+	}}
+
+	snapshot, err := awsImporter.listSnapshotBlocks("snap-boot")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 8<<30, snapshot.size)
+	assert.EqualValues(t, 512, snapshot.blockSize)
+	assert.Equal(t, map[int64]string{0: "token0", 3: "token3"}, snapshot.blockTokens)
+}
+
+func TestListSnapshotBlocksReturnErrorWhenCallError(t *testing.T) {
+	awsImporter := getAWSImporter(t, setUpAWSArgs("", true))
+	awsImporter.ebsClient = &mockEBSClient{err: fmt.Errorf("error")}
+
+	_, err := awsImporter.listSnapshotBlocks("snap-boot")
+	assert.EqualError(t, err, "failed to list blocks of snapshot snap-boot: error")
+}
+
+func TestSnapshotRangeReadsZerosForBlocksWithoutData(t *testing.T) {
+	snapshot := &ebsSnapshot{
+		client:      &mockEBSClient{blocks: map[string]string{"token0": "abcd", "token2": "ijkl"}},
+		snapshotID:  "snap-boot",
+		size:        16,
+		blockSize:   4,
+		blockTokens: map[int64]string{0: "token0", 2: "token2"},
+		zeroBlock:   make([]byte, 4),
+	}
+
+	for _, tc := range []struct {
+		offset, length int64
+		expected       string
+	}{
+		{0, 16, "abcd\x00\x00\x00\x00ijkl\x00\x00\x00\x00"},
+		{2, 8, "cd\x00\x00\x00\x00ij"},
+		{9, 2, "jk"},
+	} {
+		r, err := snapshot.rangeOpener(context.Background())(tc.offset, tc.length)
+		assert.NoError(t, err)
+		content, err := ioutil.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, string(content))
+	}
+}
+
+func TestCopyFromEBSToGCSResumesSnapshotCopy(t *testing.T) {
+	awsImporter := getAWSImporter(t, setUpAWSArgs("", true, "-execution_id=abcde"))
+	awsImporter.args.gcsScratchBucket = "gs://scratch"
+	awsImporter.copyFromEBSToGCSFn = nil
+	awsImporter.ebsClient = &mockEBSClient{pages: []*ebs.ListSnapshotBlocksOutput{
+		{BlockSize: aws.Int64(512), VolumeSize: aws.Int64(1)},
+	}}
+	uploader := &fakeResumableUploader{err: fmt.Errorf("upload failed")}
+	awsImporter.resumableUploader = uploader
+
+	_, err := awsImporter.copyFromEBSToGCS(awsVolume{deviceName: "/dev/sdb", snapshotID: "snap-data"}, 1)
+	assert.EqualError(t, err, "error in copying snapshot snap-data: upload failed")
+	assert.Equal(t, "snap-data", uploader.source)
+	assert.Equal(t, "scratch", uploader.bkt)
+	assert.Equal(t, "onestep-image-import-aws-abcde-disk1.raw", uploader.obj)
+}
+
+func TestGetMachineTypeMapsAWSInstanceType(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	describeInstanceTypesResp.output, describeInstanceTypesResp.err = &ec2.DescribeInstanceTypesOutput{
+		InstanceTypes: []*ec2.InstanceTypeInfo{{
+			VCpuInfo:   &ec2.VCpuInfo{DefaultVCpus: aws.Int64(4)},
+			MemoryInfo: &ec2.MemoryInfo{SizeInMiB: aws.Int64(16384)},
+		}},
+	}, nil
+	args := setUpAWSArgs("", true, "-instance_name=instance", "-aws_instance_type=m5.xlarge")
+	awsImporter := getAWSImporter(t, args)
+	*awsImporter.args.gcsProjectPtr = "project"
+	awsImporter.args.gcsZone = "us-west1-a"
+	mockComputeClient := mocks.NewMockClient(mockCtrl)
+	mockComputeClient.EXPECT().ListMachineTypes("project", "us-west1-a").Return([]*compute.MachineType{
+		{Name: "n1-standard-2", GuestCpus: 2, MemoryMb: 7680},
+		{Name: "n1-highmem-4", GuestCpus: 4, MemoryMb: 26624},
+		{Name: "n1-standard-4", GuestCpus: 4, MemoryMb: 15360},
+		{Name: "n2-standard-4", GuestCpus: 4, MemoryMb: 16384},
+	}, nil)
+	awsImporter.computeClient = mockComputeClient
+	importArgs, err := NewOneStepImportArguments(args)
+	assert.Nil(t, err)
+
+	machineType, err := awsImporter.getMachineType(importArgs)
+	assert.NoError(t, err)
+	assert.Equal(t, "n2-standard-4", machineType)
+}
+
+func TestGetMachineTypeUsesMachineTypeFlag(t *testing.T) {
+	describeInstanceTypesResp.output, describeInstanceTypesResp.err = nil, fmt.Errorf("shouldn't be called")
+	args := setUpAWSArgs("", true, "-instance_name=instance", "-aws_instance_type=m5.xlarge", "-machine_type=e2-medium")
+	awsImporter := getAWSImporter(t, args)
+	importArgs, err := NewOneStepImportArguments(args)
+	assert.Nil(t, err)
+
+	machineType, err := awsImporter.getMachineType(importArgs)
+	assert.NoError(t, err)
+	assert.Equal(t, "e2-medium", machineType)
+}
+
+func TestGetMachineTypeReturnErrorWhenCallError(t *testing.T) {
+	describeInstanceTypesResp.output, describeInstanceTypesResp.err = nil, fmt.Errorf("error")
+	args := setUpAWSArgs("", true, "-instance_name=instance", "-aws_instance_type=m5.xlarge")
+	awsImporter := getAWSImporter(t, args)
+	importArgs, err := NewOneStepImportArguments(args)
+	assert.Nil(t, err)
+
+	_, err = awsImporter.getMachineType(importArgs)
+	assert.EqualError(t, err, "failed to describe AWS instance type m5.xlarge: error")
+}
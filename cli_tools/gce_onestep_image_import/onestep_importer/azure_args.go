@@ -0,0 +1,173 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package importer
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/param"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/validation"
+	"github.com/GoogleCloudPlatform/compute-image-tools/daisy"
+)
+
+// azureImportArguments holds the structured results of parsing CLI arguments
+// related to import from Azure, and optionally allows for validating and
+// populating the arguments.
+type azureImportArguments struct {
+	// Passed in by user
+	clientID           string
+	executablePath     string
+	gcsComputeEndpoint string
+	gcsProjectPtr      *string
+	gcsZone            string
+	gcsRegion          string
+	gcsScratchBucket   string
+	gcsStorageLocation string
+	managedDiskID      string
+	vhdURL             string
+	tenantID           string
+	azureClientID      string
+	azureClientSecret  string
+	loginEndpoint      string
+	managementEndpoint string
+	timeout            time.Duration
+	progressLogger     logging.Logger
+	kmsKey             string
+	tracerProvider     trace.TracerProvider
+
+	// Internal generated
+	sourceFileSize int64
+}
+
+// Flags
+const (
+	azureManagedDiskIDFlag      = "azure_managed_disk_id"
+	azureVHDURLFlag             = "azure_vhd_url"
+	azureTenantIDFlag           = "azure_tenant_id"
+	azureClientIDFlag           = "azure_client_id"
+	azureClientSecretFlag       = "azure_client_secret"
+	azureLoginEndpointFlag      = "azure_login_endpoint_override"
+	azureManagementEndpointFlag = "azure_management_endpoint_override"
+)
+
+// Default endpoints of the Azure public cloud.
+const (
+	defaultAzureLoginEndpoint      = "https://login.microsoftonline.com"
+	defaultAzureManagementEndpoint = "https://management.azure.com"
+)
+
+var managedDiskIDRegex = regexp.MustCompile(
+	`(?i)^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.Compute/disks/[^/]+$`)
+
+// newAzureImportArguments creates a new azureImportArguments instance.
+func newAzureImportArguments(args *OneStepImportArguments) *azureImportArguments {
+	azureArgs := &azureImportArguments{
+		clientID:           args.ClientID,
+		executablePath:     args.ExecutablePath,
+		gcsComputeEndpoint: args.ComputeEndpoint,
+		gcsProjectPtr:      args.ProjectPtr,
+		gcsZone:            args.Zone,
+		gcsRegion:          args.Region,
+		gcsScratchBucket:   args.ScratchBucketGcsPath,
+		gcsStorageLocation: args.StorageLocation,
+		managedDiskID:      args.AzureManagedDiskID,
+		vhdURL:             args.AzureVHDURL,
+		tenantID:           args.AzureTenantID,
+		azureClientID:      args.AzureClientID,
+		azureClientSecret:  args.AzureClientSecret,
+		loginEndpoint:      strings.TrimSuffix(args.AzureLoginEndpoint, "/"),
+		managementEndpoint: strings.TrimSuffix(args.AzureManagementEndpoint, "/"),
+		timeout:            args.Timeout,
+		progressLogger:     args.progressLogger,
+		kmsKey:             args.kmsKey,
+		tracerProvider:     args.tracerProvider,
+	}
+	if azureArgs.loginEndpoint == "" {
+		azureArgs.loginEndpoint = defaultAzureLoginEndpoint
+	}
+	if azureArgs.managementEndpoint == "" {
+		azureArgs.managementEndpoint = defaultAzureManagementEndpoint
+	}
+	return azureArgs
+}
+
+// validateAndPopulate validates args related to import from Azure, and populates
+// any missing parameters.
+func (args *azureImportArguments) validateAndPopulate(populator param.Populator) error {
+	err := args.validate()
+	if err != nil {
+		return err
+	}
+
+	return populator.PopulateMissingParameters(args.gcsProjectPtr, args.clientID, &args.gcsZone,
+		&args.gcsRegion, &args.gcsScratchBucket, "", &args.gcsStorageLocation)
+}
+
+func (args *azureImportArguments) validate() error {
+	if (args.managedDiskID == "") == (args.vhdURL == "") {
+		return daisy.Errf("specify -%v to import from a managed disk, "+
+			"or -%v to import from a VHD file", azureManagedDiskIDFlag, azureVHDURLFlag)
+	}
+
+	if !args.isExportRequired() {
+		u, err := url.Parse(args.vhdURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return daisy.Errf("-%v must be an HTTP(S) URL of a blob", azureVHDURLFlag)
+		}
+		return nil
+	}
+
+	if !managedDiskIDRegex.MatchString(args.managedDiskID) {
+		return daisy.Errf("%v is not a valid Azure managed disk ID. Expected format: "+
+			"/subscriptions/SUBSCRIPTION/resourceGroups/RESOURCE_GROUP/providers/Microsoft.Compute/disks/DISK",
+			args.managedDiskID)
+	}
+	if err := validation.ValidateStringFlagNotEmpty(args.tenantID, azureTenantIDFlag); err != nil {
+		return err
+	}
+	if err := validation.ValidateStringFlagNotEmpty(args.azureClientID, azureClientIDFlag); err != nil {
+		return err
+	}
+	return validation.ValidateStringFlagNotEmpty(args.azureClientSecret, azureClientSecretFlag)
+}
+
+// isExportRequired returns true if a managed disk needs to be exported, false
+// if a VHD file is imported.
+func (args *azureImportArguments) isExportRequired() bool {
+	return args.vhdURL == ""
+}
+
+// sourceName returns the imported source for logs, without the SAS token of a
+// VHD URL.
+func (args *azureImportArguments) sourceName() string {
+	if args.isExportRequired() {
+		return args.managedDiskID
+	}
+	return redactURL(args.vhdURL)
+}
+
+// redactURL removes the query of rawURL, which holds the signature of a SAS URL.
+func redactURL(rawURL string) string {
+	if i := strings.Index(rawURL, "?"); i >= 0 {
+		return rawURL[:i]
+	}
+	return rawURL
+}
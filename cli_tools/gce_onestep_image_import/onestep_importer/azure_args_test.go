@@ -0,0 +1,124 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package importer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testManagedDiskID       = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/disks/my-disk"
+	azureSourceFlagErrorMsg = "specify -azure_managed_disk_id to import from a managed disk, or -azure_vhd_url to import from a VHD file"
+)
+
+func TestValidateExportManagedDisk(t *testing.T) {
+	azureArgs := getAzureImportArgs(setUpAzureArgs("", true))
+	assert.NoError(t, azureArgs.validate())
+	assert.True(t, azureArgs.isExportRequired())
+}
+
+func TestValidateVHDURL(t *testing.T) {
+	azureArgs := getAzureImportArgs(setUpAzureArgs("", false))
+	assert.NoError(t, azureArgs.validate())
+	assert.False(t, azureArgs.isExportRequired())
+}
+
+func TestFailWhenNoAzureSourceProvided(t *testing.T) {
+	args := setUpAzureArgs(azureVHDURLFlag, false)
+	assert.EqualError(t, getAzureImportArgs(args).validate(), azureSourceFlagErrorMsg)
+}
+
+func TestFailWhenBothAzureSourcesProvided(t *testing.T) {
+	args := setUpAzureArgs("", true, "-azure_vhd_url=https://account.blob.core.windows.net/vhds/disk.vhd")
+	assert.EqualError(t, getAzureImportArgs(args).validate(), azureSourceFlagErrorMsg)
+}
+
+func TestFailWhenManagedDiskIDInvalid(t *testing.T) {
+	args := setUpAzureArgs(azureManagedDiskIDFlag, true, "-azure_managed_disk_id=my-disk")
+	assert.Contains(t, getAzureImportArgs(args).validate().Error(), "my-disk is not a valid Azure managed disk ID")
+}
+
+func TestManagedDiskIDIsCaseInsensitive(t *testing.T) {
+	args := setUpAzureArgs(azureManagedDiskIDFlag, true,
+		"-azure_managed_disk_id=/SUBSCRIPTIONS/sub/resourcegroups/rg/providers/microsoft.compute/Disks/my-disk")
+	assert.NoError(t, getAzureImportArgs(args).validate())
+}
+
+func TestFailWhenVHDURLInvalid(t *testing.T) {
+	for _, vhdURL := range []string{"disk.vhd", "gs://bucket/disk.vhd", "https:///disk.vhd"} {
+		args := setUpAzureArgs(azureVHDURLFlag, false, "-azure_vhd_url="+vhdURL)
+		assert.EqualError(t, getAzureImportArgs(args).validate(), "-azure_vhd_url must be an HTTP(S) URL of a blob", vhdURL)
+	}
+}
+
+func TestFailWhenServicePrincipalNotProvided(t *testing.T) {
+	for _, flag := range []string{azureTenantIDFlag, azureClientIDFlag, azureClientSecretFlag} {
+		args := setUpAzureArgs(flag, true)
+		assert.EqualError(t, getAzureImportArgs(args).validate(), fmt.Sprintf("The flag -%v must be provided", flag))
+	}
+}
+
+func TestServicePrincipalNotRequiredForVHDURL(t *testing.T) {
+	azureArgs := getAzureImportArgs(setUpAzureArgs("", false))
+	assert.Empty(t, azureArgs.tenantID)
+	assert.NoError(t, azureArgs.validate())
+}
+
+func TestAzureEndpointsHaveDefaultValues(t *testing.T) {
+	azureArgs := getAzureImportArgs(setUpAzureArgs("", true))
+	assert.Equal(t, "https://login.microsoftonline.com", azureArgs.loginEndpoint)
+	assert.Equal(t, "https://management.azure.com", azureArgs.managementEndpoint)
+}
+
+func TestAzureEndpointsAreOverridable(t *testing.T) {
+	azureArgs := getAzureImportArgs(setUpAzureArgs("", true,
+		"-azure_login_endpoint_override=https://login.chinacloudapi.cn/",
+		"-azure_management_endpoint_override=https://management.chinacloudapi.cn/"))
+	assert.Equal(t, "https://login.chinacloudapi.cn", azureArgs.loginEndpoint)
+	assert.Equal(t, "https://management.chinacloudapi.cn", azureArgs.managementEndpoint)
+}
+
+func TestAzureValidateAndPopulateErrorWhenValidateFailed(t *testing.T) {
+	azureArgs := getAzureImportArgs(setUpAzureArgs(azureTenantIDFlag, true))
+	err := azureArgs.validateAndPopulate(mockPopulator{})
+	assert.EqualError(t, err, "The flag -azure_tenant_id must be provided")
+}
+
+func TestAzureValidateAndPopulateErrorWhenPopulateFailed(t *testing.T) {
+	azureArgs := getAzureImportArgs(setUpAzureArgs("", false))
+	err := azureArgs.validateAndPopulate(mockPopulator{err: fmt.Errorf("populate failed")})
+	assert.EqualError(t, err, "populate failed")
+}
+
+func TestAzurePopulateParam(t *testing.T) {
+	azureArgs := getAzureImportArgs(setUpAzureArgs("", false))
+	err := azureArgs.validateAndPopulate(mockPopulator{
+		zone:          "us-west2-a",
+		region:        "us-west2",
+		scratchBucket: "gs://bucket",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "us-west2-a", azureArgs.gcsZone)
+	assert.Equal(t, "gs://bucket", azureArgs.gcsScratchBucket)
+}
+
+func TestAzureSourceNameRedactsSASToken(t *testing.T) {
+	assert.Equal(t, "https://account.blob.core.windows.net/vhds/disk.vhd",
+		getAzureImportArgs(setUpAzureArgs("", false)).sourceName())
+	assert.Equal(t, testManagedDiskID, getAzureImportArgs(setUpAzureArgs("", true)).sourceName())
+}
@@ -0,0 +1,418 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/domain"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/compute"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/param"
+	pathutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/path"
+	storageutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/storage"
+	"github.com/GoogleCloudPlatform/compute-image-tools/daisy"
+)
+
+const (
+	// API version of the Azure compute resource provider used for disk access.
+	azureDiskAPIVersion = "2020-12-01"
+	// Interval between polls of the Azure disk export operation, unless the
+	// operation specifies one.
+	azurePollInterval = 10 * time.Second
+)
+
+// azureImporter is responsible for importing image from Azure.
+type azureImporter struct {
+	args           *azureImportArguments
+	gcsClient      domain.StorageClientInterface
+	ctx            context.Context
+	oauth          string
+	paramPopulator param.Populator
+	timeoutChan    chan struct{}
+	uploader       *uploader
+
+	// HTTP client for Azure REST APIs and blob downloads.
+	httpClient   *http.Client
+	pollInterval time.Duration
+
+	// Impl of the functions
+	copyFromAzureToGCSFn func() (string, error)
+	getUploaderFn        func() *uploader
+	importImageFn        func() error
+	cleanUpFn            func()
+}
+
+// newAzureImporter creates an new azureImporter instance.
+// Automatically populating dependencies, such as compute/storage clients.
+func newAzureImporter(oauth string, timeoutChan chan struct{}, args *azureImportArguments) (*azureImporter, error) {
+	ctx := context.Background()
+	client, err := createGCSClient(ctx, oauth)
+	if err != nil {
+		return nil, err
+	}
+
+	computeClient, err := param.CreateComputeClient(&ctx, oauth, args.gcsComputeEndpoint, args.tracerProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	metadataGCE := &compute.MetadataGCE{}
+	scratchBucketCreator := storageutils.NewScratchBucketCreator(ctx, client)
+	scratchBucketCreator.KmsKey = args.kmsKey
+	paramPopulator := param.NewPopulator(
+		metadataGCE,
+		client,
+		storageutils.NewResourceLocationRetriever(metadataGCE, computeClient),
+		scratchBucketCreator,
+	)
+
+	importer := &azureImporter{
+		args:           args,
+		gcsClient:      client,
+		ctx:            ctx,
+		oauth:          oauth,
+		paramPopulator: paramPopulator,
+		timeoutChan:    timeoutChan,
+		httpClient:     http.DefaultClient,
+		pollInterval:   azurePollInterval,
+	}
+
+	return importer, nil
+}
+
+// run runs the azure importer to import a managed disk or a VHD file.
+func (importer *azureImporter) run(importArgs *OneStepImportArguments) error {
+	startTime := time.Now()
+	// 1. validate Azure args
+	err := importer.args.validateAndPopulate(importer.paramPopulator)
+	if err != nil {
+		return err
+	}
+
+	// 2. copy from Azure to GCS, exporting the managed disk if needed.
+	log.Println("Starting to copy ...")
+	gcsFilePath, err := importer.copyFromAzureToGCS()
+	if err != nil {
+		return err
+	}
+
+	// 3. run image import
+	log.Println("Starting to import image ...")
+	err = importer.importImage(importArgs, startTime, gcsFilePath)
+	if err != nil {
+		return err
+	}
+	log.Println("Image import from Azure finished successfully!")
+
+	// 4. clean up temporary image files created in GCS
+	log.Println("Cleaning up ...")
+	importer.cleanUp(gcsFilePath)
+
+	return nil
+}
+
+// cleanUp deletes temporary files created during image import, and closes GCS client.
+func (importer *azureImporter) cleanUp(gcsFilePath string) {
+	if importer.cleanUpFn != nil {
+		importer.cleanUpFn()
+		return
+	}
+
+	err := importer.gcsClient.DeleteGcsPath(gcsFilePath)
+	if err != nil {
+		log.Printf("Could not delete image file %v: %v. "+
+			"To avoid incurring charges to your billing account, "+
+			"you must manually delete the file from the storage location.\n", gcsFilePath, err.Error())
+	}
+
+	importer.gcsClient.Close()
+}
+
+// importImage runs image import to import from gcsFilePath to Compute Engine.
+func (importer *azureImporter) importImage(importArgs *OneStepImportArguments, startTime time.Time, gcsFilePath string) error {
+	if importer.importImageFn != nil {
+		return importer.importImageFn()
+	}
+	return importFromGCS(importArgs, startTime, gcsFilePath, "azure")
+}
+
+// copyFromAzureToGCS copies the VHD file of the managed disk or the VHD blob to GCS.
+// Read access to the managed disk is granted for the copy only.
+func (importer *azureImporter) copyFromAzureToGCS() (string, error) {
+	if importer.copyFromAzureToGCSFn != nil {
+		return importer.copyFromAzureToGCSFn()
+	}
+
+	start := time.Now()
+	// 1. get URL of the VHD file
+	sourceURL := importer.args.vhdURL
+	if importer.args.isExportRequired() {
+		log.Println("Starting to export disk ...")
+		token, err := importer.getAccessToken()
+		if err != nil {
+			return "", err
+		}
+		// Access may be granted even if the export fails or times out.
+		defer importer.revokeDiskAccess(token)
+		sourceURL, err = importer.grantDiskAccess(token)
+		if err != nil {
+			return "", err
+		}
+	}
+	if err := importer.getAzureFileSize(sourceURL); err != nil {
+		return "", err
+	}
+
+	// 2. get GCS path as copy destination.
+	gcsFilePath := pathutils.JoinURL(importer.args.gcsScratchBucket,
+		fmt.Sprintf("onestep-image-import-azure-%v.vhd", pathutils.RandString(5)))
+
+	log.Printf("Copying %v to %v.\n", importer.args.sourceName(), gcsFilePath)
+
+	// 3. get writer
+	writer, cleanupWriter, err := newGCSBufferedWriter(importer.ctx, importer.oauth, importer.args.executablePath, gcsFilePath)
+	if err != nil {
+		return "", err
+	}
+	defer cleanupWriter()
+
+	// 4. Transfer file from Azure to GCS
+	if err := importer.transferFile(sourceURL, writer); err != nil {
+		return gcsFilePath, err
+	}
+	log.Printf("Successfully copied to %v in %v.\n", gcsFilePath, time.Since(start))
+
+	return gcsFilePath, nil
+}
+
+func (importer *azureImporter) getUploader(writer io.WriteCloser) *uploader {
+	if importer.getUploaderFn != nil {
+		return importer.getUploaderFn()
+	}
+	return newUploader(writer, importer.args.sourceFileSize,
+		logging.NewTransferProgress(importer.args.progressLogger, logging.PhaseCopy, importer.args.sourceFileSize))
+}
+
+// transferFile downloads the file at sourceURL and uploads to GCS concurrently.
+func (importer *azureImporter) transferFile(sourceURL string, writer io.WriteCloser) error {
+	importer.uploader = importer.getUploader(writer)
+	return transferInRanges(importer.uploader, importer.args.sourceFileSize, importer.args.sourceName(),
+		importer.timeoutChan, func(startRange, endRange int64) (io.ReadCloser, error) {
+			return importer.downloadRange(sourceURL, startRange, endRange)
+		})
+}
+
+// downloadRange opens the bytes of the file at sourceURL from startRange to
+// endRange, inclusive. The last range is truncated to the end of the file.
+func (importer *azureImporter) downloadRange(sourceURL string, startRange, endRange int64) (io.ReadCloser, error) {
+	if last := importer.args.sourceFileSize - 1; endRange > last {
+		endRange = last
+	}
+	req, err := http.NewRequest(http.MethodGet, sourceURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%v-%v", startRange, endRange))
+	resp, err := importer.httpClient.Do(req)
+	if err != nil {
+		return nil, redactURLError(err)
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %q", resp.Status)
+	}
+	if err := checkContentRange(resp, startRange, endRange); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// checkContentRange fails unless resp holds exactly the bytes from
+// startRange to endRange, inclusive.
+func checkContentRange(resp *http.Response, startRange, endRange int64) error {
+	contentRange := resp.Header.Get("Content-Range")
+	var start, end int64
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/", &start, &end); err != nil || start != startRange || end != endRange {
+		return fmt.Errorf("unexpected Content-Range %q for bytes %v-%v", contentRange, startRange, endRange)
+	}
+	if resp.ContentLength >= 0 && resp.ContentLength != endRange-startRange+1 {
+		return fmt.Errorf("unexpected length %v for bytes %v-%v", resp.ContentLength, startRange, endRange)
+	}
+	return nil
+}
+
+// getAzureFileSize gets the size of the file to copy from Azure to GCS.
+func (importer *azureImporter) getAzureFileSize(sourceURL string) error {
+	resp, err := importer.httpClient.Head(sourceURL)
+	if err != nil {
+		return daisy.Errf("failed to get file size: %v", redactURLError(err))
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return daisy.Errf("failed to get file size: unexpected status %q", resp.Status)
+	}
+	if resp.ContentLength <= 0 {
+		return daisy.Errf("file is empty")
+	}
+
+	importer.args.sourceFileSize = resp.ContentLength
+	return nil
+}
+
+// getAccessToken gets an Azure Resource Manager access token for the service principal.
+func (importer *azureImporter) getAccessToken() (string, error) {
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {importer.args.azureClientID},
+		"client_secret": {importer.args.azureClientSecret},
+		"scope":         {importer.args.managementEndpoint + "/.default"},
+	}
+	resp, err := importer.httpClient.PostForm(fmt.Sprintf("%v/%v/oauth2/v2.0/token",
+		importer.args.loginEndpoint, url.PathEscape(importer.args.tenantID)), form)
+	if err != nil {
+		return "", daisy.Errf("failed to authenticate to Azure: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil && resp.StatusCode == http.StatusOK {
+		return "", daisy.Errf("failed to authenticate to Azure: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return "", daisy.Errf("failed to authenticate to Azure: unexpected status %q: %v", resp.Status, token.ErrorDescription)
+	}
+	return token.AccessToken, nil
+}
+
+// grantDiskAccess grants read access to the managed disk, and returns the SAS
+// URL of its VHD file once the operation is completed.
+func (importer *azureImporter) grantDiskAccess(token string) (string, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"access":            "Read",
+		"durationInSeconds": int64(importer.args.timeout.Seconds()),
+	})
+	resp, err := importer.callManagementAPI(token, http.MethodPost,
+		importer.diskActionURL("beginGetAccess"), bytes.NewReader(body))
+	if err != nil {
+		return "", daisy.Errf("failed to begin export Azure disk: %v", err)
+	}
+
+	// The operation is completed when its Location URL returns the SAS URL.
+	for resp.StatusCode == http.StatusAccepted {
+		resp.Body.Close()
+		location := resp.Header.Get("Location")
+		if location == "" {
+			return "", daisy.Errf("failed to get export status: unexpected response")
+		}
+		log.Println("Azure disk export is in progress.")
+
+		select {
+		case <-importer.timeoutChan:
+			return "", daisy.Errf("timeout exceeded during disk export")
+		case <-time.After(importer.retryAfter(resp)):
+			// Did not timeout, continue to check the operation status.
+		}
+
+		resp, err = importer.callManagementAPI(token, http.MethodGet, location, nil)
+		if err != nil {
+			return "", daisy.Errf("failed to get export status: %v", err)
+		}
+	}
+	defer resp.Body.Close()
+
+	var access struct {
+		AccessSAS string `json:"accessSAS"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&access); err != nil || access.AccessSAS == "" {
+		return "", daisy.Errf("Azure disk export wasn't completed successfully: no SAS URL returned")
+	}
+	log.Println("Azure disk export is completed!")
+	return access.AccessSAS, nil
+}
+
+// revokeDiskAccess revokes the access granted to the managed disk, so that it
+// can be attached to VMs again.
+func (importer *azureImporter) revokeDiskAccess(token string) {
+	log.Printf("Revoking access to %v.\n", importer.args.managedDiskID)
+	resp, err := importer.callManagementAPI(token, http.MethodPost, importer.diskActionURL("endAccess"), nil)
+	if err != nil {
+		log.Printf("Could not revoke access to %v: %v. "+
+			"You must manually revoke access before attaching the disk to a VM.\n", importer.args.managedDiskID, err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// diskActionURL returns the Azure Resource Manager URL of an action on the managed disk.
+func (importer *azureImporter) diskActionURL(action string) string {
+	return fmt.Sprintf("%v%v/%v?api-version=%v",
+		importer.args.managementEndpoint, importer.args.managedDiskID, action, azureDiskAPIVersion)
+}
+
+// callManagementAPI sends a request to Azure Resource Manager. Responses with
+// an error status are returned as errors.
+func (importer *azureImporter) callManagementAPI(token, method, apiURL string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, apiURL, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := importer.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%v %v: unexpected status %q: %s", method, redactURL(apiURL), resp.Status,
+			strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// retryAfter returns the interval to wait before polling an operation again.
+func (importer *azureImporter) retryAfter(resp *http.Response) time.Duration {
+	var seconds int
+	if _, err := fmt.Sscan(resp.Header.Get("Retry-After"), &seconds); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return importer.pollInterval
+}
+
+// redactURLError removes the URL from err, to avoid logging SAS tokens.
+func redactURLError(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		return fmt.Errorf("%v %v: %v", urlErr.Op, redactURL(urlErr.URL), urlErr.Err)
+	}
+	return err
+}
@@ -0,0 +1,327 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/mocks"
+)
+
+const azureDiskContent = "azure disk content"
+
+// fakeAzure is a local stand-in for the Azure endpoints used by azureImporter.
+type fakeAzure struct {
+	*httptest.Server
+
+	// Number of polls before the export operation is completed; a negative
+	// value never completes it.
+	pendingPolls int
+	// Status returned when the VHD file is read.
+	blobStatus int
+	// When set, ranged reads of the VHD file return the whole file.
+	ignoreRanges bool
+
+	mx       sync.Mutex
+	requests []string
+	tokens   []string
+}
+
+func newFakeAzure(t *testing.T) *fakeAzure {
+	f := &fakeAzure{blobStatus: http.StatusOK}
+	diskPath := testManagedDiskID
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mx.Lock()
+		f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			f.tokens = append(f.tokens, auth)
+		}
+		f.mx.Unlock()
+
+		switch {
+		case r.URL.Path == "/my-tenant/oauth2/v2.0/token":
+			assert.NoError(t, r.ParseForm())
+			if r.PostForm.Get("client_secret") != "my-secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(w, `{"error_description": "invalid client secret"}`)
+				return
+			}
+			assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+			assert.Equal(t, "my-client", r.PostForm.Get("client_id"))
+			assert.Equal(t, f.URL+"/.default", r.PostForm.Get("scope"))
+			fmt.Fprint(w, `{"access_token": "my-token"}`)
+		case r.URL.Path == diskPath+"/beginGetAccess":
+			assert.Equal(t, azureDiskAPIVersion, r.URL.Query().Get("api-version"))
+			var body map[string]interface{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "Read", body["access"])
+			w.Header().Set("Location", f.URL+"/operations/export")
+			w.WriteHeader(http.StatusAccepted)
+		case r.URL.Path == "/operations/export":
+			if f.pendingPolls != 0 {
+				f.pendingPolls--
+				w.Header().Set("Location", f.URL+"/operations/export")
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			fmt.Fprintf(w, `{"accessSAS": "%v/vhds/abcd?sig=secret"}`, f.URL)
+		case r.URL.Path == diskPath+"/endAccess":
+			w.WriteHeader(http.StatusAccepted)
+		case strings.HasPrefix(r.URL.Path, "/vhds/"):
+			if f.blobStatus != http.StatusOK {
+				w.WriteHeader(f.blobStatus)
+				return
+			}
+			if f.ignoreRanges && r.Method == http.MethodGet {
+				fmt.Fprint(w, azureDiskContent)
+				return
+			}
+			http.ServeContent(w, r, "abcd", time.Time{}, strings.NewReader(azureDiskContent))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return f
+}
+
+func (f *fakeAzure) hasRequest(request string) bool {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	for _, r := range f.requests {
+		if r == request {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAzureCopyManagedDisk(t *testing.T) {
+	azure := newFakeAzure(t)
+	defer azure.Close()
+	azure.pendingPolls = 2
+	var output bytes.Buffer
+	azureImporter := getAzureImporter(t, azure, &output, setUpAzureArgs("", true)...)
+
+	gcsFilePath, err := azureImporter.copyFromAzureToGCS()
+	assert.NoError(t, err)
+	assert.Regexp(t, "^gs://bucket/onestep-image-import-azure-.*\\.vhd$", gcsFilePath)
+	assert.Equal(t, azureDiskContent, output.String())
+	assert.EqualValues(t, len(azureDiskContent), azureImporter.args.sourceFileSize)
+	assert.True(t, azure.hasRequest("POST "+testManagedDiskID+"/endAccess"), "access to the disk should be revoked")
+	for _, token := range azure.tokens {
+		assert.Equal(t, "Bearer my-token", token)
+	}
+}
+
+func TestAzureCopyVHDURL(t *testing.T) {
+	azure := newFakeAzure(t)
+	defer azure.Close()
+	var output bytes.Buffer
+	azureImporter := getAzureImporter(t, azure, &output,
+		setUpAzureArgs(azureVHDURLFlag, false, "-azure_vhd_url="+azure.URL+"/vhds/disk.vhd?sig=secret")...)
+
+	_, err := azureImporter.copyFromAzureToGCS()
+	assert.NoError(t, err)
+	assert.Equal(t, azureDiskContent, output.String())
+	assert.False(t, azure.hasRequest("POST /my-tenant/oauth2/v2.0/token"), "no credential is needed")
+	assert.Empty(t, azure.tokens)
+}
+
+func TestAzureCopyRevokesAccessWhenDownloadFails(t *testing.T) {
+	azure := newFakeAzure(t)
+	defer azure.Close()
+	azure.blobStatus = http.StatusForbidden
+	azureImporter := getAzureImporter(t, azure, &bytes.Buffer{}, setUpAzureArgs("", true)...)
+
+	_, err := azureImporter.copyFromAzureToGCS()
+	assert.EqualError(t, err, "failed to get file size: unexpected status \"403 Forbidden\"")
+	assert.True(t, azure.hasRequest("POST "+testManagedDiskID+"/endAccess"), "access to the disk should be revoked")
+}
+
+func TestAzureDownloadRange(t *testing.T) {
+	azure := newFakeAzure(t)
+	defer azure.Close()
+	azureImporter := getAzureImporter(t, azure, &bytes.Buffer{}, setUpAzureArgs("", true)...)
+	azureImporter.args.sourceFileSize = int64(len(azureDiskContent))
+
+	// The last range is truncated to the end of the file.
+	body, err := azureImporter.downloadRange(azure.URL+"/vhds/abcd", 6, 99)
+	assert.NoError(t, err)
+	content, _ := ioutil.ReadAll(body)
+	body.Close()
+	assert.Equal(t, azureDiskContent[6:], string(content))
+
+	// Servers which ignore the range fail the download.
+	azure.ignoreRanges = true
+	_, err = azureImporter.downloadRange(azure.URL+"/vhds/abcd", 0, 5)
+	assert.EqualError(t, err, "unexpected status \"200 OK\"")
+}
+
+func TestCheckContentRange(t *testing.T) {
+	for _, tt := range []struct {
+		contentRange  string
+		contentLength int64
+		wantErr       string
+	}{
+		{"bytes 8-15/19", 8, ""},
+		{"bytes 8-15/*", -1, ""},
+		{"bytes 0-18/19", 19, "unexpected Content-Range \"bytes 0-18/19\" for bytes 8-15"},
+		{"", 8, "unexpected Content-Range \"\" for bytes 8-15"},
+		{"bytes 8-15/19", 4, "unexpected length 4 for bytes 8-15"},
+	} {
+		resp := &http.Response{Header: http.Header{}, ContentLength: tt.contentLength}
+		if tt.contentRange != "" {
+			resp.Header.Set("Content-Range", tt.contentRange)
+		}
+		err := checkContentRange(resp, 8, 15)
+		if tt.wantErr == "" {
+			assert.NoError(t, err, tt.contentRange)
+		} else {
+			assert.EqualError(t, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAzureCopyFailsWhenAuthenticationFails(t *testing.T) {
+	azure := newFakeAzure(t)
+	defer azure.Close()
+	azureImporter := getAzureImporter(t, azure, &bytes.Buffer{},
+		setUpAzureArgs(azureClientSecretFlag, true, "-azure_client_secret=wrong")...)
+
+	_, err := azureImporter.copyFromAzureToGCS()
+	assert.EqualError(t, err, "failed to authenticate to Azure: unexpected status \"401 Unauthorized\": invalid client secret")
+	assert.False(t, azure.hasRequest("POST "+testManagedDiskID+"/beginGetAccess"))
+}
+
+func TestAzureCopyFailsWhenDiskNotFound(t *testing.T) {
+	azure := newFakeAzure(t)
+	defer azure.Close()
+	otherDisk := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/disks/other-disk"
+	azureImporter := getAzureImporter(t, azure, &bytes.Buffer{},
+		setUpAzureArgs(azureManagedDiskIDFlag, true, "-azure_managed_disk_id="+otherDisk)...)
+
+	_, err := azureImporter.copyFromAzureToGCS()
+	assert.Contains(t, err.Error(), fmt.Sprintf("failed to begin export Azure disk: POST %v%v/beginGetAccess: "+
+		"unexpected status \"404 Not Found\"", azure.URL, otherDisk))
+}
+
+func TestAzureExportReturnErrorWhenTimeout(t *testing.T) {
+	azure := newFakeAzure(t)
+	defer azure.Close()
+	azure.pendingPolls = -1
+	azureImporter := getAzureImporter(t, azure, &bytes.Buffer{}, setUpAzureArgs("", true)...)
+	close(azureImporter.timeoutChan)
+
+	_, err := azureImporter.copyFromAzureToGCS()
+	assert.EqualError(t, err, "timeout exceeded during disk export")
+	assert.True(t, azure.hasRequest("POST "+testManagedDiskID+"/endAccess"), "access to the disk should be revoked")
+}
+
+func TestAzureGetFileSizeDoesNotExposeSASToken(t *testing.T) {
+	azure := newFakeAzure(t)
+	defer azure.Close()
+	azureImporter := getAzureImporter(t, azure, &bytes.Buffer{}, setUpAzureArgs("", false)...)
+
+	err := azureImporter.getAzureFileSize("http://127.0.0.1:0/vhds/disk.vhd?sig=secret")
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "secret")
+	assert.Contains(t, err.Error(), "http://127.0.0.1:0/vhds/disk.vhd")
+}
+
+func TestAzureRunImportsCopiedFile(t *testing.T) {
+	azure := newFakeAzure(t)
+	defer azure.Close()
+	args := setUpAzureArgs("", false)
+	importArgs, err := NewOneStepImportArguments(args)
+	assert.NoError(t, err)
+	azureImporter := getAzureImporter(t, azure, &bytes.Buffer{}, args...)
+	azureImporter.copyFromAzureToGCSFn = func() (string, error) { return "gs://bucket/disk.vhd", nil }
+	cleanedUp := false
+	azureImporter.cleanUpFn = func() { cleanedUp = true }
+
+	assert.NoError(t, azureImporter.run(importArgs))
+	assert.True(t, cleanedUp)
+}
+
+func TestAzureImportImageUpdateImporterArgs(t *testing.T) {
+	azure := newFakeAzure(t)
+	defer azure.Close()
+	args := setUpAzureArgs("", false)
+	importArgs, err := NewOneStepImportArguments(args)
+	assert.NoError(t, err)
+	azureImporter := getAzureImporter(t, azure, &bytes.Buffer{}, args...)
+	azureImporter.importImageFn = nil
+
+	originalTimeout := importArgs.Timeout
+	azureImporter.importImage(importArgs, time.Now(), "gs://bucket/disk.vhd")
+
+	assert.Equal(t, "gs://bucket/disk.vhd", importArgs.SourceFile)
+	assert.Equal(t, "azure", importArgs.Labels["onestep-image-import"])
+	assert.NotEqual(t, originalTimeout, importArgs.Timeout)
+}
+
+func TestAzureCleanupDeleteGCSPath(t *testing.T) {
+	azure := newFakeAzure(t)
+	defer azure.Close()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorageClient := mocks.NewMockStorageClientInterface(mockCtrl)
+	mockStorageClient.EXPECT().DeleteGcsPath("gs://bucket/disk.vhd")
+	mockStorageClient.EXPECT().Close()
+
+	azureImporter := getAzureImporter(t, azure, &bytes.Buffer{}, setUpAzureArgs("", false)...)
+	azureImporter.gcsClient = mockStorageClient
+	azureImporter.cleanUpFn = nil
+	azureImporter.cleanUp("gs://bucket/disk.vhd")
+}
+
+// getAzureImporter returns an azureImporter using the fake Azure endpoints,
+// which uploads the copied file to output.
+func getAzureImporter(t *testing.T, azure *fakeAzure, output *bytes.Buffer, args ...string) *azureImporter {
+	args = append(args,
+		"-azure_login_endpoint_override="+azure.URL,
+		"-azure_management_endpoint_override="+azure.URL,
+		"-scratch_bucket_gcs_path=gs://bucket")
+	azureArgs := getAzureImportArgs(args)
+	azureImporter, err := newAzureImporter("", make(chan struct{}), azureArgs)
+	assert.Nil(t, err)
+
+	azureImporter.paramPopulator = mockPopulator{}
+	azureImporter.httpClient = azure.Client()
+	azureImporter.pollInterval = time.Millisecond
+	azureImporter.getUploaderFn = func() *uploader {
+		u := getTestUploader(testWriteCloser{Writer: bufio.NewWriter(output)})
+		u.totalFileSize = azureImporter.args.sourceFileSize
+		return u
+	}
+	azureImporter.importImageFn = func() error { return nil }
+	azureImporter.cleanUpFn = func() {}
+
+	return azureImporter
+}
@@ -15,15 +15,24 @@
 package importer
 
 import (
+	"context"
 	"flag"
 	"io/ioutil"
 	"os"
 	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/domain"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/compute"
 	daisyUtils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/daisy"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/flags"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/kms"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging/service"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/param"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/path"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/validation"
 	"github.com/GoogleCloudPlatform/compute-image-tools/daisy"
 )
@@ -40,15 +49,21 @@ type OneStepImportArguments struct {
 	DataDisk              bool
 	Description           string
 	ExecutablePath        string
+	ExecutionID           string
 	Family                string
 	GcsLogsDisabled       bool
 	ImageName             string
+	InstanceName          string
+	KmsFlags              kms.KeyFlags
 	Labels                map[string]string
+	MachineImageName      string
+	MachineType           string
 	Network               string
 	NoExternalIP          bool
 	NoGuestEnvironment    bool
 	Oauth                 string
 	OS                    string
+	ProgressOutput        string
 	ProjectPtr            *string
 	Region                string
 	ScratchBucketGcsPath  string
@@ -59,9 +74,21 @@ type OneStepImportArguments struct {
 	SysprepWindows        bool
 	Timeout               time.Duration
 	TimeoutChan           chan struct{}
+	TraceExporter         string
+	TraceFile             string
 	UefiCompatible        bool
 	Zone                  string
 
+	// progressLogger receives the progress events of copying the source.
+	progressLogger logging.Logger
+
+	// kmsKey is the fully qualified name of the key identified by KmsFlags.
+	kmsKey string
+
+	// tracerProvider traces the import's workflows and Compute API calls,
+	// when TraceExporter is set.
+	tracerProvider trace.TracerProvider
+
 	AWSAccessKeyID       string
 	AWSSecretAccessKey   string
 	AWSSessionToken      string
@@ -69,13 +96,25 @@ type OneStepImportArguments struct {
 	AWSAMIID             string
 	AWSAMIExportLocation string
 	AWSSourceAMIFilePath string
+	AWSInstanceType      string
+
+	AzureManagedDiskID      string
+	AzureVHDURL             string
+	AzureTenantID           string
+	AzureClientID           string
+	AzureClientSecret       string
+	AzureLoginEndpoint      string
+	AzureManagementEndpoint string
 }
 
 // Flags that are validated.
 const (
-	clientFlag    = "client_id"
-	imageNameFlag = "image_name"
-	osFlag        = "os"
+	clientFlag           = "client_id"
+	imageNameFlag        = "image_name"
+	instanceNameFlag     = "instance_name"
+	machineImageNameFlag = "machine_image_name"
+	machineTypeFlag      = "machine_type"
+	osFlag               = "os"
 )
 
 // NewOneStepImportArguments parse the provides cli arguments and creates a new ImportArguments instance.
@@ -88,6 +127,9 @@ func NewOneStepImportArguments(args []string) (*OneStepImportArguments, error) {
 	if err := flagSet.Parse(args); err != nil {
 		return nil, daisy.ToDError(err)
 	}
+	if importArgs.ExecutionID == "" {
+		importArgs.ExecutionID = path.RandString(5)
+	}
 
 	return importArgs, nil
 }
@@ -129,6 +171,35 @@ func (args *OneStepImportArguments) registerFlags(flagSet *flag.FlagSet) {
 			"This credential is associated with an IAM user or role. "+
 			"This IAM user must have permissions to import images.")
 
+	flagSet.Var((*flags.TrimmedString)(&args.AWSInstanceType), awsInstanceTypeFlag,
+		"The EC2 instance type, such as m5.xlarge, to map to the machine type of the instance "+
+			"or machine image to create. The machine type with the fewest vCPUs and least memory "+
+			"that are at least those of the instance type is used.")
+
+	flagSet.Var((*flags.TrimmedString)(&args.AzureManagedDiskID), azureManagedDiskIDFlag,
+		"The resource ID of the Azure managed disk to import, for example "+
+			"/subscriptions/SUBSCRIPTION/resourceGroups/RESOURCE_GROUP/providers/Microsoft.Compute/disks/DISK.")
+
+	flagSet.Var((*flags.TrimmedString)(&args.AzureVHDURL), azureVHDURLFlag,
+		"The URL of the Azure blob of the VHD file to import, including a SAS token "+
+			"if the blob is not public.")
+
+	flagSet.Var((*flags.TrimmedString)(&args.AzureTenantID), azureTenantIDFlag,
+		"The Azure Active Directory tenant of the service principal used to export the managed disk.")
+
+	flagSet.Var((*flags.TrimmedString)(&args.AzureClientID), azureClientIDFlag,
+		"The application ID of the Azure service principal used to export the managed disk. "+
+			"The service principal must have permissions to begin and end access to the disk.")
+
+	flagSet.Var((*flags.TrimmedString)(&args.AzureClientSecret), azureClientSecretFlag,
+		"The client secret of the Azure service principal used to export the managed disk.")
+
+	flagSet.Var((*flags.TrimmedString)(&args.AzureLoginEndpoint), azureLoginEndpointFlag,
+		"Azure Active Directory endpoint to override default.")
+
+	flagSet.Var((*flags.TrimmedString)(&args.AzureManagementEndpoint), azureManagementEndpointFlag,
+		"Azure Resource Manager endpoint to override default.")
+
 	flagSet.Var((*flags.LowerTrimmedString)(&args.ClientID), clientFlag,
 		"Identifies the client of the importer, e.g. 'gcloud', 'pantheon', or 'api'.")
 
@@ -166,6 +237,10 @@ func (args *OneStepImportArguments) registerFlags(flagSet *flag.FlagSet) {
 	flagSet.Var((*flags.TrimmedString)(&args.ComputeServiceAccount), "compute_service_account",
 		"Compute service account to be used by importer Virtual Machine. When empty, the Compute Engine default service account is used.")
 
+	flagSet.Var((*flags.LowerTrimmedString)(&args.ExecutionID), "execution_id",
+		"The execution ID to differentiate the files copied by each import. "+
+			"Rerun an interrupted import with the same execution ID to resume copying its image file.")
+
 	flagSet.BoolVar(&args.GcsLogsDisabled, "disable_gcs_logging", false,
 		"Do not store logs in GCS.")
 
@@ -177,15 +252,42 @@ func (args *OneStepImportArguments) registerFlags(flagSet *flag.FlagSet) {
 
 	flagSet.BoolVar(&args.NoExternalIP, "no_external_ip", false,
 		"VPC doesn't allow external IPs.")
-
-	flagSet.Bool("kms_key", false, "Reserved for future use.")
-	flagSet.Bool("kms_keyring", false, "Reserved for future use.")
-	flagSet.Bool("kms_location", false, "Reserved for future use.")
-	flagSet.Bool("kms_project", false, "Reserved for future use.")
+	flagSet.Var((*flags.TrimmedString)(&args.ProgressOutput), "progress_output",
+		"Where to write progress events as JSON lines: stdout, or the path of a "+
+			"local file that they're appended to.")
+
+	flagSet.Var((*flags.TrimmedString)(&args.KmsFlags.Key), "kms_key",
+		"The Cloud KMS key that encrypts the image, and the disks, instance, and scratch bucket "+
+			"created during the import. Either the fully qualified name of the key, or its ID "+
+			"with -kms_keyring and -kms_location.")
+	flagSet.Var((*flags.TrimmedString)(&args.KmsFlags.Keyring), "kms_keyring",
+		"The Cloud KMS keyring of -kms_key.")
+	flagSet.Var((*flags.TrimmedString)(&args.KmsFlags.Location), "kms_location",
+		"The Cloud location of -kms_keyring.")
+	flagSet.Var((*flags.TrimmedString)(&args.KmsFlags.Project), "kms_project",
+		"The project of -kms_keyring. Defaults to -project.")
+
+	flagSet.Var((*flags.LowerTrimmedString)(&args.TraceExporter), "trace_exporter",
+		"Export OpenTelemetry spans of the import's workflows, steps and Compute API calls: "+
+			"none, stdout or file. Defaults to none.")
+	flagSet.Var((*flags.TrimmedString)(&args.TraceFile), "trace_file",
+		"The local file that spans are appended to as JSON when -trace_exporter=file.")
 
 	flagSet.Var((*flags.LowerTrimmedString)(&args.ImageName), imageNameFlag,
 		"Name of the disk image to create.")
 
+	flagSet.Var((*flags.LowerTrimmedString)(&args.InstanceName), instanceNameFlag,
+		"Name of the instance to create from the imported disks. The imported images "+
+			"are deleted once the instance is created.")
+
+	flagSet.Var((*flags.LowerTrimmedString)(&args.MachineImageName), machineImageNameFlag,
+		"Name of the machine image to create from the imported disks. The imported images "+
+			"are deleted once the machine image is created.")
+
+	flagSet.Var((*flags.TrimmedString)(&args.MachineType), machineTypeFlag,
+		"Machine type of the instance or machine image to create. Overrides the machine type "+
+			"mapped from -"+awsInstanceTypeFlag+". n1-standard-1 is used if neither is specified.")
+
 	flagSet.Var((*flags.TrimmedString)(&args.Family), "family",
 		"Family to set for the imported image.")
 
@@ -238,10 +340,48 @@ func (args *OneStepImportArguments) validate() error {
 	if err := validation.ValidateStringFlagNotEmpty(args.ClientID, clientFlag); err != nil {
 		return err
 	}
+	if args.InstanceName != "" && args.MachineImageName != "" {
+		return daisy.Errf("-%v and -%v can't be both specified", instanceNameFlag, machineImageNameFlag)
+	}
+	if !args.isInstanceImport() && !args.isMachineImageImport() && (args.MachineType != "" || args.AWSInstanceType != "") {
+		return daisy.Errf("-%v and -%v require -%v or -%v to be specified",
+			machineTypeFlag, awsInstanceTypeFlag, instanceNameFlag, machineImageNameFlag)
+	}
 
 	return nil
 }
 
+// isInstanceImport returns true if an instance is created from the imported disks.
+func (args *OneStepImportArguments) isInstanceImport() bool {
+	return args.InstanceName != ""
+}
+
+// isMachineImageImport returns true if a machine image is created from the imported disks.
+func (args *OneStepImportArguments) isMachineImageImport() bool {
+	return args.MachineImageName != ""
+}
+
+// populateKmsKey sets kmsKey to the fully qualified name of the key identified
+// by KmsFlags, and checks that it can be used. The key is in the project by
+// default, so the project is populated first when a key is specified.
+func (args *OneStepImportArguments) populateKmsKey(metadataClient domain.MetadataGCEInterface,
+	newValidator func() (kms.KeyValidator, error)) (err error) {
+	if args.KmsFlags.Key != "" {
+		if err := param.PopulateProjectIfMissing(metadataClient, args.ProjectPtr); err != nil {
+			return err
+		}
+	}
+	args.kmsKey, err = args.KmsFlags.Name(*args.ProjectPtr)
+	if err != nil || args.kmsKey == "" {
+		return err
+	}
+	validator, err := newValidator()
+	if err != nil {
+		return err
+	}
+	return validator.Validate(args.kmsKey)
+}
+
 // Run performs onestep image import.
 func Run(args *OneStepImportArguments) (service.Loggable, error) {
 	// validate required flags that are not cloud-provider specific.
@@ -249,5 +389,30 @@ func Run(args *OneStepImportArguments) (service.Loggable, error) {
 		return nil, err
 	}
 
+	// The key is checked before the source is copied, since the copy can take hours.
+	if err := args.populateKmsKey(&compute.MetadataGCE{}, func() (kms.KeyValidator, error) {
+		return kms.NewKeyValidator(context.Background(), args.Oauth)
+	}); err != nil {
+		return nil, err
+	}
+
+	tracerProvider, shutdownTracing, err := daisy.NewTracerProvider(args.TraceExporter, args.TraceFile)
+	if err != nil {
+		return nil, err
+	}
+	defer shutdownTracing(context.Background())
+	args.tracerProvider = tracerProvider
+
+	progressOutput, err := logging.OpenProgressOutput(args.ProgressOutput)
+	if err != nil {
+		return nil, daisy.Errf("failed to open progress output: %v", err)
+	}
+	if progressOutput != nil {
+		defer progressOutput.Close()
+		logger := logging.NewToolLogger("[onestep-import]")
+		logger.SetProgressOutput(progressOutput)
+		args.progressLogger = logger
+	}
+
 	return nil, importFromCloudProvider(args)
 }
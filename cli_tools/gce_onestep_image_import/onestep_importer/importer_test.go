@@ -19,7 +19,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/kms"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/mocks"
 )
 
 func TestFailWhenImageNameNotProvided(t *testing.T) {
@@ -92,6 +96,14 @@ func TestTrimAMIExportLocation(t *testing.T) {
 	assert.Equal(t, "my-ami_export-location", expectSuccessfulParse(t, args...).AWSAMIExportLocation)
 }
 
+func TestTrimAndLowerExecutionID(t *testing.T) {
+	assert.Equal(t, "abc12", expectSuccessfulParse(t, "-execution_id=  ABC12  ").ExecutionID)
+}
+
+func TestExecutionIDGeneratedWhenNotProvided(t *testing.T) {
+	assert.Len(t, expectSuccessfulParse(t).ExecutionID, 5)
+}
+
 func TestTrimFamily(t *testing.T) {
 	assert.Equal(t, "ubuntu", expectSuccessfulParse(t, "-family=  ubuntu  ").Family)
 }
@@ -104,6 +116,47 @@ func TestTrimAndLowerStorageLocation(t *testing.T) {
 	assert.Equal(t, "ubuntu", expectSuccessfulParse(t, "-storage_location=  ubUntu  ").StorageLocation)
 }
 
+func TestTrimProgressOutput(t *testing.T) {
+	assert.Equal(t, "stdout", expectSuccessfulParse(t, "-progress_output=  stdout  ").ProgressOutput)
+}
+
+func TestTrimTraceFlags(t *testing.T) {
+	args := expectSuccessfulParse(t, "-trace_exporter=  File  ", "-trace_file=  /tmp/spans.json  ")
+	assert.Equal(t, "file", args.TraceExporter)
+	assert.Equal(t, "/tmp/spans.json", args.TraceFile)
+}
+
+func TestTrimKmsFlags(t *testing.T) {
+	args := expectSuccessfulParse(t, "-kms_key=  key  ", "-kms_keyring=  ring  ",
+		"-kms_location=  us-west1  ", "-kms_project=  kms-project  ")
+	assert.Equal(t, kms.KeyFlags{Key: "key", Keyring: "ring", Location: "us-west1", Project: "kms-project"},
+		args.KmsFlags)
+}
+
+func TestPopulateKmsKeyUsesInferredProject(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockMetadata := mocks.NewMockMetadataGCEInterface(mockCtrl)
+	mockMetadata.EXPECT().OnGCE().Return(true)
+	mockMetadata.EXPECT().ProjectID().Return("inferred-project", nil)
+
+	args := expectSuccessfulParse(t, "-kms_key=key", "-kms_keyring=ring", "-kms_location=us-west1")
+	validator := &mockKeyValidator{}
+	assert.NoError(t, args.populateKmsKey(mockMetadata, validator.new))
+	assert.Equal(t, "inferred-project", *args.ProjectPtr)
+	assert.Equal(t, "projects/inferred-project/locations/us-west1/keyRings/ring/cryptoKeys/key", args.kmsKey)
+	assert.Equal(t, []string{args.kmsKey}, validator.validated)
+}
+
+func TestPopulateKmsKeyDoesntInferProjectWhenKeyIsNotSpecified(t *testing.T) {
+	args := expectSuccessfulParse(t)
+	validator := &mockKeyValidator{}
+	assert.NoError(t, args.populateKmsKey(nil, validator.new))
+	assert.Empty(t, *args.ProjectPtr)
+	assert.Empty(t, args.kmsKey)
+	assert.Empty(t, validator.validated)
+}
+
 func TestTrimProject(t *testing.T) {
 	assert.Equal(t, "ubuntu", *expectSuccessfulParse(t, "-project=  ubuntu  ").ProjectPtr)
 }
@@ -199,6 +252,32 @@ func TestSysprepSettable(t *testing.T) {
 	assert.True(t, expectSuccessfulParse(t, "-sysprep_windows").SysprepWindows)
 }
 
+func TestTrimAndLowerInstanceName(t *testing.T) {
+	assert.Equal(t, "instance", expectSuccessfulParse(t, "-instance_name=  INSTANCE ").InstanceName)
+}
+
+func TestTrimAndLowerMachineImageName(t *testing.T) {
+	assert.Equal(t, "machine-image", expectSuccessfulParse(t, "-machine_image_name=  Machine-Image ").MachineImageName)
+}
+
+func TestTrimMachineTypeAndAWSInstanceType(t *testing.T) {
+	args := expectSuccessfulParse(t, "-instance_name=instance", "-machine_type=  n1-standard-4 ", "-aws_instance_type=  m5.xlarge ")
+	assert.Equal(t, "n1-standard-4", args.MachineType)
+	assert.Equal(t, "m5.xlarge", args.AWSInstanceType)
+}
+
+func TestFailWhenInstanceAndMachineImageNamesProvided(t *testing.T) {
+	err := expectFailedValidation(t, setUpArgs("", "-instance_name=instance", "-machine_image_name=machine-image"))
+	assert.EqualError(t, err, "-instance_name and -machine_image_name can't be both specified")
+}
+
+func TestFailWhenMachineTypeProvidedWithoutInstance(t *testing.T) {
+	for _, arg := range []string{"-machine_type=n1-standard-4", "-aws_instance_type=m5.xlarge"} {
+		err := expectFailedValidation(t, setUpArgs("", arg))
+		assert.EqualError(t, err, "-machine_type and -aws_instance_type require -instance_name or -machine_image_name to be specified")
+	}
+}
+
 func TestRunReturnErrorWhenInvalidArgs(t *testing.T) {
 	args := setUpArgs(osFlag)
 	importArgs, _ := NewOneStepImportArguments(args)
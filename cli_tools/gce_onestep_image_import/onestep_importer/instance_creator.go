@@ -0,0 +1,166 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package importer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	computeBeta "google.golang.org/api/compute/v0.beta"
+	"google.golang.org/api/compute/v1"
+
+	daisyutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/daisy"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/path"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/daisycommon"
+	daisyovfutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/gce_ovf_import/daisy_utils"
+	"github.com/GoogleCloudPlatform/compute-image-tools/daisy"
+)
+
+// Workflows of OVF import, which create an instance or a machine image from
+// imported images. See the workflows for the names of their vars and steps.
+const (
+	createInstanceWorkflow = "daisy_workflows/ovf_import/create_instance.wf.json"
+	createGMIWorkflow      = "daisy_workflows/ovf_import/create_gmi.wf.json"
+	createInstanceStep     = "create-instance"
+	cleanupStep            = "cleanup"
+)
+
+// instanceCreationParams holds the populated parameters to create an instance
+// or a machine image from imported images.
+type instanceCreationParams struct {
+	project       string
+	zone          string
+	scratchBucket string
+	machineType   string
+	deadline      time.Time
+
+	// The boot disk is created from the first image, and data disks from the
+	// others. The images are deleted once the instance is created.
+	imageNames []string
+}
+
+// createInstanceFromImages creates the instance or machine image specified by
+// args from imported images.
+func createInstanceFromImages(ctx context.Context, args *OneStepImportArguments, params instanceCreationParams) error {
+	w, err := newCreateInstanceWorkflow(args, params)
+	if err != nil {
+		return err
+	}
+
+	// Stop creating the instance if timeout exceeded.
+	go func() {
+		select {
+		case <-args.TimeoutChan:
+			w.CancelWithReason("timed-out")
+		case <-w.Cancel:
+		}
+	}()
+
+	dataDiskPrefix := args.InstanceName
+	if args.isMachineImageImport() {
+		dataDiskPrefix = args.MachineImageName
+	}
+	var dataImageURIs []string
+	for _, imageName := range params.imageNames[1:] {
+		dataImageURIs = append(dataImageURIs, getImageURI(params.project, imageName))
+	}
+
+	modifyWorkflowPreValidate := func(w *daisy.Workflow) {
+		w.SetLogProcessHook(daisyutils.RemovePrivacyLogTag)
+		daisyovfutils.CreateDisksOnInstance(
+			w.Steps[createInstanceStep].CreateInstances.Instances[0], dataDiskPrefix, dataImageURIs)
+		w.Steps[cleanupStep].DeleteResources.Images = append(
+			w.Steps[cleanupStep].DeleteResources.Images, dataImageURIs...)
+		// The instance runs without a service account, as in OVF import without
+		// an instance service account.
+		w.Steps[createInstanceStep].CreateInstances.Instances[0].ServiceAccounts = []*compute.ServiceAccount{}
+		w.Steps[createInstanceStep].CreateInstances.InstancesBeta[0].ServiceAccounts = []*computeBeta.ServiceAccount{}
+	}
+	modifyWorkflowPostValidate := func(w *daisy.Workflow) {
+		daisyutils.UpdateAllInstanceNoExternalIP(w, args.NoExternalIP)
+		if args.UefiCompatible {
+			daisyutils.UpdateToUEFICompatible(w)
+		}
+	}
+
+	if err := w.RunWithModifiers(ctx, modifyWorkflowPreValidate, modifyWorkflowPostValidate); err != nil {
+		daisyutils.PostProcessDErrorForNetworkFlag("instance import", err, args.Network, w)
+		return err
+	}
+	return nil
+}
+
+// newCreateInstanceWorkflow parses the OVF import workflow that creates the
+// instance or machine image specified by args.
+func newCreateInstanceWorkflow(args *OneStepImportArguments, params instanceCreationParams) (*daisy.Workflow, error) {
+	timeout := time.Until(params.deadline)
+	if timeout <= 0 {
+		return nil, daisy.Errf("timeout exceeded")
+	}
+
+	workflowPath := path.ToWorkingDir(createInstanceWorkflow, args.ExecutablePath)
+	varMap := map[string]string{
+		"boot_disk_image_uri": getImageURI(params.project, params.imageNames[0]),
+	}
+	if args.isMachineImageImport() {
+		workflowPath = path.ToWorkingDir(createGMIWorkflow, args.ExecutablePath)
+		varMap["machine_image_name"] = args.MachineImageName
+	} else {
+		varMap["instance_name"] = args.InstanceName
+	}
+	if params.machineType != "" {
+		varMap["machine_type"] = params.machineType
+	}
+	if args.Description != "" {
+		varMap["description"] = args.Description
+	}
+	if args.Subnet != "" {
+		varMap["subnet"] = args.Subnet
+		// When subnet is set, we need to grant a value to network to avoid fallback to default
+		if args.Network == "" {
+			varMap["network"] = ""
+		}
+	}
+	if args.Network != "" {
+		varMap["network"] = args.Network
+	}
+
+	w, err := daisycommon.ParseWorkflow(workflowPath, varMap, params.project, params.zone,
+		params.scratchBucket, args.Oauth, timeout.String(), args.ComputeEndpoint,
+		args.GcsLogsDisabled, args.CloudLogsDisabled, args.StdoutLogsDisabled)
+	if err != nil {
+		return nil, daisy.Errf("error parsing workflow %q: %v", workflowPath, err)
+	}
+	w.ForceCleanupOnError = true
+	w.KmsKey = args.kmsKey
+	w.TracerProvider = args.tracerProvider
+	log.Printf("Creating %v from images %v.\n", getInstanceImportTarget(args), params.imageNames)
+	return w, nil
+}
+
+// getInstanceImportTarget returns the instance or machine image to create, for logs.
+func getInstanceImportTarget(args *OneStepImportArguments) string {
+	if args.isMachineImageImport() {
+		return fmt.Sprintf("machine image %v", args.MachineImageName)
+	}
+	return fmt.Sprintf("instance %v", args.InstanceName)
+}
+
+// getImageURI returns the URI of imageName in project.
+func getImageURI(project, imageName string) string {
+	return fmt.Sprintf("projects/%v/global/images/%v", project, imageName)
+}
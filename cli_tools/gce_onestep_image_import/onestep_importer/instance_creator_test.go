@@ -0,0 +1,99 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package importer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The workflows are found next to the executable, at the root of the repo.
+const testExecutablePath = "../../../gce_onestep_image_import"
+
+func getInstanceCreationParams() instanceCreationParams {
+	return instanceCreationParams{
+		project:       "project",
+		zone:          "us-west1-a",
+		scratchBucket: "gs://bucket",
+		machineType:   "n2-standard-4",
+		deadline:      time.Now().Add(time.Hour),
+		imageNames:    []string{"name", "name-data-1"},
+	}
+}
+
+func TestNewCreateInstanceWorkflowForInstance(t *testing.T) {
+	args := expectSuccessfulParse(t, "-instance_name=instance", "-description=desc", "-subnet=subnet")
+	args.ExecutablePath = testExecutablePath
+
+	w, err := newCreateInstanceWorkflow(args, getInstanceCreationParams())
+	assert.NoError(t, err)
+	assert.Equal(t, "projects/project/global/images/name", w.Vars["boot_disk_image_uri"].Value)
+	assert.Equal(t, "instance", w.Vars["instance_name"].Value)
+	assert.Equal(t, "n2-standard-4", w.Vars["machine_type"].Value)
+	assert.Equal(t, "desc", w.Vars["description"].Value)
+	assert.Equal(t, "subnet", w.Vars["subnet"].Value)
+	assert.Equal(t, "", w.Vars["network"].Value)
+	assert.Equal(t, "project", w.Project)
+	assert.Equal(t, "us-west1-a", w.Zone)
+	assert.True(t, w.ForceCleanupOnError)
+	assert.NotContains(t, w.Steps, "create-machine-image")
+}
+
+func TestNewCreateInstanceWorkflowForMachineImage(t *testing.T) {
+	args := expectSuccessfulParse(t, "-machine_image_name=machine-image")
+	args.ExecutablePath = testExecutablePath
+	params := getInstanceCreationParams()
+	params.machineType = ""
+
+	w, err := newCreateInstanceWorkflow(args, params)
+	assert.NoError(t, err)
+	assert.Equal(t, "machine-image", w.Vars["machine_image_name"].Value)
+	assert.Equal(t, "n1-standard-1", w.Vars["machine_type"].Value)
+	assert.Equal(t, "global/networks/default", w.Vars["network"].Value)
+	assert.Contains(t, w.Steps, "create-machine-image")
+}
+
+func TestNewCreateInstanceWorkflowUsesKmsKey(t *testing.T) {
+	args := expectSuccessfulParse(t, "-instance_name=instance")
+	args.ExecutablePath = testExecutablePath
+	args.kmsKey = "projects/p/locations/l/keyRings/r/cryptoKeys/k"
+
+	w, err := newCreateInstanceWorkflow(args, getInstanceCreationParams())
+	assert.NoError(t, err)
+	assert.Equal(t, args.kmsKey, w.KmsKey)
+}
+
+func TestNewCreateInstanceWorkflowUsesTracerProvider(t *testing.T) {
+	args := expectSuccessfulParse(t, "-instance_name=instance")
+	args.ExecutablePath = testExecutablePath
+	args.tracerProvider = trace.NewNoopTracerProvider()
+
+	w, err := newCreateInstanceWorkflow(args, getInstanceCreationParams())
+	assert.NoError(t, err)
+	assert.Equal(t, args.tracerProvider, w.TracerProvider)
+}
+
+func TestNewCreateInstanceWorkflowReturnErrorWhenTimeout(t *testing.T) {
+	args := expectSuccessfulParse(t, "-instance_name=instance")
+	args.ExecutablePath = testExecutablePath
+	params := getInstanceCreationParams()
+	params.deadline = time.Now()
+
+	_, err := newCreateInstanceWorkflow(args, params)
+	assert.EqualError(t, err, "timeout exceeded")
+}
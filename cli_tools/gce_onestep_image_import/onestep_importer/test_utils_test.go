@@ -20,6 +20,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/kms"
 )
 
 type testWriteCloser struct {
@@ -64,6 +66,20 @@ func (m mockPopulator) PopulateMissingParameters(project *string, clientID strin
 	return nil
 }
 
+type mockKeyValidator struct {
+	validated []string
+	err       error
+}
+
+func (m *mockKeyValidator) new() (kms.KeyValidator, error) {
+	return m, nil
+}
+
+func (m *mockKeyValidator) Validate(keyName string) error {
+	m.validated = append(m.validated, keyName)
+	return m.err
+}
+
 func expectSuccessfulParse(t *testing.T, input ...string) *OneStepImportArguments {
 	args := setUpArgs("", input...)
 	importArgs, err := NewOneStepImportArguments(args)
@@ -105,6 +121,35 @@ func setUpAWSArgs(requiredFlagToTest string, needsExport bool, args ...string) [
 	return args
 }
 
+func setUpAzureArgs(requiredFlagToTest string, needsExport bool, args ...string) []string {
+	args = setUpArgs("", args...)
+
+	if needsExport {
+		if requiredFlagToTest != azureManagedDiskIDFlag {
+			args = append(args, "-azure_managed_disk_id="+testManagedDiskID)
+		}
+		if requiredFlagToTest != azureTenantIDFlag {
+			args = append(args, "-azure_tenant_id=my-tenant")
+		}
+		if requiredFlagToTest != azureClientIDFlag {
+			args = append(args, "-azure_client_id=my-client")
+		}
+		if requiredFlagToTest != azureClientSecretFlag {
+			args = append(args, "-azure_client_secret=my-secret")
+		}
+	} else {
+		if requiredFlagToTest != azureVHDURLFlag {
+			args = append(args, "-azure_vhd_url=https://account.blob.core.windows.net/vhds/disk.vhd?sig=secret")
+		}
+	}
+	return args
+}
+
+func getAzureImportArgs(args []string) *azureImportArguments {
+	importerArgs, _ := NewOneStepImportArguments(args)
+	return newAzureImportArguments(importerArgs)
+}
+
 func getAWSImportArgs(args []string) *awsImportArguments {
 	importerArgs, _ := NewOneStepImportArguments(args)
 	return newAWSImportArguments(importerArgs)
@@ -15,17 +15,22 @@
 package importer
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/flags"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/path"
+	storageutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/storage"
 	"github.com/GoogleCloudPlatform/compute-image-tools/daisy"
 	"github.com/dustin/go-humanize"
 )
@@ -47,15 +52,32 @@ func handleTimeout(timeout time.Duration, timeoutChan chan struct{}) {
 	close(timeoutChan)
 }
 
+// resumableUploader uploads a file in chunks, and resumes the interrupted
+// uploads of the same source to the same object.
+type resumableUploader interface {
+	ResumableUpload(ctx context.Context, open storageutils.RangeOpener, size int64, source, bkt, obj string,
+		progress *logging.TransferProgress) error
+}
+
 // cloudProviderImporter represents the importer for various cloud providers.
 type cloudProviderImporter interface {
 	run(args *OneStepImportArguments) error
 }
 
 // newImporterFormCloudProvider evaluates the cloud provider of the source image
-// and creates a new instance of cloudProviderImporter. Currently, only AWS cloud
-// provider is supported.
+// and creates a new instance of cloudProviderImporter. The source is imported
+// from Azure when an Azure source is specified, and from AWS otherwise.
 func newImporterForCloudProvider(args *OneStepImportArguments) (cloudProviderImporter, error) {
+	if args.AzureManagedDiskID != "" || args.AzureVHDURL != "" {
+		if args.AWSAMIID != "" || args.AWSSourceAMIFilePath != "" {
+			return nil, daisy.Errf("specify either an AWS or an Azure source to import, not both")
+		}
+		if args.isInstanceImport() || args.isMachineImageImport() {
+			return nil, daisy.Errf("-%v and -%v are only supported when importing from AWS",
+				instanceNameFlag, machineImageNameFlag)
+		}
+		return newAzureImporter(args.Oauth, args.TimeoutChan, newAzureImportArguments(args))
+	}
 	return newAWSImporter(args.Oauth, args.TimeoutChan, newAWSImportArguments(args))
 }
 
@@ -95,14 +117,19 @@ func importFromCloudProvider(args *OneStepImportArguments) error {
 	}
 }
 
-// runImageImport calls image import
+// runImageImport calls image import. The image is imported as a data disk,
+// without translation, if args.DataDisk is set.
 func runImageImport(args *OneStepImportArguments) error {
 	imageImportPath := path.ToWorkingDir("gce_vm_image_import", args.ExecutablePath)
+	osArg := fmt.Sprintf("-os=%v", args.OS)
+	if args.DataDisk {
+		osArg = "-data_disk"
+	}
 	err := runCmd(imageImportPath, []string{
 		fmt.Sprintf("-image_name=%v", args.ImageName),
 		fmt.Sprintf("-client_id=%v", args.ClientID),
 		fmt.Sprintf("-client_version=%v", args.ClientVersion),
-		fmt.Sprintf("-os=%v", args.OS),
+		osArg,
 		fmt.Sprintf("-source_file=%v", args.SourceFile),
 		fmt.Sprintf("-no_guest_environment=%v", args.NoGuestEnvironment),
 		fmt.Sprintf("-family=%v", args.Family),
@@ -121,19 +148,150 @@ func runImageImport(args *OneStepImportArguments) error {
 		fmt.Sprintf("-disable_stdout_logging=%v", args.StdoutLogsDisabled),
 		fmt.Sprintf("-no_external_ip=%v", args.NoExternalIP),
 		fmt.Sprintf("-labels=%v", flags.KeyValueString(args.Labels).String()),
-		fmt.Sprintf("-storage_location=%v", args.StorageLocation)})
+		fmt.Sprintf("-storage_location=%v", args.StorageLocation),
+		fmt.Sprintf("-kms_key=%v", args.KmsFlags.Key),
+		fmt.Sprintf("-kms_keyring=%v", args.KmsFlags.Keyring),
+		fmt.Sprintf("-kms_location=%v", args.KmsFlags.Location),
+		fmt.Sprintf("-kms_project=%v", args.KmsFlags.Project),
+		fmt.Sprintf("-progress_output=%v", args.ProgressOutput),
+		fmt.Sprintf("-trace_exporter=%v", args.TraceExporter),
+		fmt.Sprintf("-trace_file=%v", args.TraceFile)})
 	if err != nil {
 		return daisy.Errf("failed to import image: %v", err)
 	}
 	return nil
 }
 
+// importFromGCS updates importArgs to contain the image source file and updated timeout duration.
+// It runs image import to import from gcsFilePath, copied from cloudProvider, to Compute Engine.
+func importFromGCS(importArgs *OneStepImportArguments, startTime time.Time, gcsFilePath, cloudProvider string) error {
+	// update source file flag to copied GCS destination
+	importArgs.SourceFile = gcsFilePath
+
+	// adjust timeout to pass into image import
+	importArgs.Timeout = importArgs.Timeout - time.Since(startTime)
+	if importArgs.Timeout <= 0 {
+		return daisy.Errf("timeout exceeded")
+	}
+
+	// add label to indicate the image import is run from onestep import
+	if importArgs.Labels == nil {
+		importArgs.Labels = make(map[string]string)
+	}
+	importArgs.Labels["onestep-image-import"] = cloudProvider
+
+	err := runImageImport(importArgs)
+	if err != nil {
+		log.Printf("Failed to import image. "+
+			"The image file is copied to Cloud Storage, located at %v.\n", gcsFilePath)
+		return err
+	}
+
+	return nil
+}
+
+// newGCSBufferedWriter creates a writer to gcsFilePath, which buffers chunks in a
+// local folder next to the executable. The returned function removes the folder.
+func newGCSBufferedWriter(ctx context.Context, oauth, executablePath, gcsFilePath string) (io.WriteCloser, func(), error) {
+	// 1. create a new folder for local buffer
+	bufferDir := filepath.Join(filepath.Dir(executablePath), fmt.Sprint("upload", path.RandString(5)))
+
+	err := os.Mkdir(bufferDir, 0755)
+	if err != nil {
+		return nil, nil, daisy.ToDError(err)
+	}
+	cleanup := func() { os.RemoveAll(bufferDir) }
+
+	// 2. get writer
+	bs, err := humanize.ParseBytes(uploadBufSize)
+	if err != nil {
+		cleanup()
+		return nil, nil, daisy.ToDError(err)
+	}
+	bkt, obj, err := storageutils.GetGCSObjectPathElements(gcsFilePath)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	workers := int64(runtime.NumCPU())
+	return storageutils.NewBufferedWriter(ctx, int64(bs), workers, createGCSClient, oauth, bufferDir, bkt, obj), cleanup, nil
+}
+
+// transferInRanges downloads a file of fileSize bytes from sourcePath in ranges of
+// downloadBufSize, and uploads the ranges with uploader concurrently. download opens
+// the bytes from startRange to endRange, inclusive.
+func transferInRanges(uploader *uploader, fileSize int64, sourcePath string, timeoutChan chan struct{},
+	download func(startRange, endRange int64) (io.ReadCloser, error)) error {
+	// 1. Set up download size and get number of chunks to download
+	output, err := humanize.ParseBytes(downloadBufSize)
+	if err != nil {
+		return daisy.ToDError(err)
+	}
+	readSize := int64(output)
+	// Take ceiling to get number of chunks to download.
+	readers := (fileSize-1)/readSize + 1
+	// Set up download retry delay interval
+	delayTime := []int{1, 2, 4, 8, 8}
+	maxRetryTimes := len(delayTime)
+
+	// 2. Set up upload info
+	uploader.Add(1)
+	go uploader.uploadFile()
+
+	// 3. Range download
+	for i := int64(0); i < readers; i++ {
+		startRange := i * readSize
+		endRange := startRange + readSize - 1
+		for retryAttempt := 0; ; retryAttempt++ {
+			body, err := download(startRange, endRange)
+			if err != nil {
+				if retryAttempt >= maxRetryTimes {
+					return daisy.Errf("error in downloading from %v: %v", sourcePath, err)
+				}
+				time.Sleep(time.Duration(delayTime[retryAttempt]) * time.Second)
+				continue
+			}
+			uploader.readerChan <- body
+			break
+		}
+
+		// Stop downloading as soon as one of the upload fails.
+		select {
+		case err := <-uploader.uploadErrChan:
+			uploader.cleanup()
+			return err
+		default:
+			// No error, continue to download.
+		}
+
+		// Stop downloading if timeout exceeded.
+		select {
+		case <-timeoutChan:
+			uploader.cleanup()
+			return daisy.Errf("timeout exceeded during transfer file")
+		default:
+			// Did not timeout, continue to download.
+		}
+	}
+
+	// All file chunks are downloaded, wait for upload to finish.
+	close(uploader.readerChan)
+	uploader.Wait()
+
+	err = uploader.writer.Close()
+	if err != nil {
+		return daisy.ToDError(err)
+	}
+	return nil
+}
+
 // uploader is responsible for receiving file chunks and upload it to a destination
 type uploader struct {
 	readerChan    chan io.ReadCloser
 	writer        io.WriteCloser
 	totalUploaded int64
 	totalFileSize int64
+	progress      *logging.TransferProgress
 	uploadErrChan chan error
 	sync.Mutex
 	sync.WaitGroup
@@ -142,6 +300,19 @@ type uploader struct {
 	cleanupFn    func()
 }
 
+// newUploader creates an uploader of a file of fileSize bytes to writer,
+// which reports the uploaded bytes to progress.
+func newUploader(writer io.WriteCloser, fileSize int64, progress *logging.TransferProgress) *uploader {
+	return &uploader{
+		readerChan:    make(chan io.ReadCloser, downloadBufNum),
+		writer:        writer,
+		totalUploaded: 0,
+		totalFileSize: fileSize,
+		progress:      progress,
+		uploadErrChan: make(chan error),
+	}
+}
+
 // uploadFile uploads file chunks to writer
 func (uploader *uploader) uploadFile() {
 	if uploader.uploadFileFn != nil {
@@ -158,6 +329,7 @@ func (uploader *uploader) uploadFile() {
 			uploader.uploadErrChan <- err
 		}
 		uploader.totalUploaded += n
+		uploader.progress.Add(n)
 		log.Printf("Total written size: %v of %v.", humanize.IBytes(uint64(uploader.totalUploaded)), humanize.IBytes(uint64(uploader.totalFileSize)))
 	}
 }
@@ -25,6 +25,8 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
 )
 
 func TestHandleTimeout(t *testing.T) {
@@ -45,6 +47,24 @@ func TestNewImporter(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestNewImporterForAzureSource(t *testing.T) {
+	importer, err := newImporterForCloudProvider(expectSuccessfulParse(t, setUpAzureArgs("", false)...))
+	assert.Nil(t, err)
+	assert.IsType(t, &azureImporter{}, importer)
+}
+
+func TestNewImporterFailsWhenAWSAndAzureSourcesProvided(t *testing.T) {
+	args := expectSuccessfulParse(t, setUpAzureArgs("", true, "-aws_ami_id=my-ami-id")...)
+	_, err := newImporterForCloudProvider(args)
+	assert.EqualError(t, err, "specify either an AWS or an Azure source to import, not both")
+}
+
+func TestNewImporterFailsWhenInstanceImportedFromAzure(t *testing.T) {
+	args := expectSuccessfulParse(t, setUpAzureArgs("", false, "-instance_name=instance")...)
+	_, err := newImporterForCloudProvider(args)
+	assert.EqualError(t, err, "-instance_name and -machine_image_name are only supported when importing from AWS")
+}
+
 func TestImportReturnOnTimeoutLessThan3Minutes(t *testing.T) {
 	args := expectSuccessfulParse(t, "-timeout=0s")
 	err := importFromCloudProvider(args)
@@ -104,6 +124,25 @@ func TestUploaderOutputsProgress(t *testing.T) {
 	assert.Contains(t, buf.String(), "Total written size: 4 B of 100 B.")
 }
 
+func TestUploaderReportsProgressEvents(t *testing.T) {
+	logger := logging.NewToolLogger(t.Name())
+	var events bytes.Buffer
+	logger.SetProgressOutput(&events)
+
+	var output bytes.Buffer
+	writerCloser := testWriteCloser{Writer: bufio.NewWriter(&output)}
+	r := ioutil.NopCloser(bytes.NewReader([]byte("test")))
+
+	uploader := newUploader(writerCloser, 4, logging.NewTransferProgress(logger, logging.PhaseCopy, 4))
+	uploader.Add(1)
+	go uploader.uploadFile()
+
+	uploader.readerChan <- r
+	close(uploader.readerChan)
+	uploader.Wait()
+	assert.Contains(t, events.String(), `"phase":"copy","percent":100}`)
+}
+
 func TestUploaderHasErrorWhenCopyFail(t *testing.T) {
 	var output bytes.Buffer
 	writerCloser := testWriteCloser{Writer: bufio.NewWriter(&output)}
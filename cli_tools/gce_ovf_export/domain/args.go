@@ -24,8 +24,11 @@ import (
 	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/daisy"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/flags"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/kms"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/daisycommon"
 )
 
@@ -90,6 +93,10 @@ type OVFExportArgs struct {
 	ReleaseTrack          string
 	BuildID               string
 	ComputeServiceAccount string
+	ProgressOutput        string
+	KmsFlags              kms.KeyFlags
+	TraceExporter         string
+	TraceFile             string
 
 	// Non-args
 	WorkflowDir string
@@ -101,6 +108,11 @@ type OVFExportArgs struct {
 	//`gs://my-bucket/my-folder/vm.ovf`, OvfName will be `vm`. If a directory is
 	// provided for DestinationURI, instance name will be used for OVF name
 	OvfName string
+	// KmsKey is the fully qualified name of the key identified by KmsFlags.
+	KmsKey string
+	// TracerProvider traces the export's workflows and Compute API calls.
+	// The global TracerProvider is used when it's nil.
+	TracerProvider trace.TracerProvider
 }
 
 // NewOVFExportArgs parses args to create an NewOVFExportArgs instance.
@@ -168,6 +180,8 @@ func (args *OVFExportArgs) EnvironmentSettings() daisycommon.EnvironmentSettings
 		Network:               args.Network,
 		Subnet:                args.Subnet,
 		ComputeServiceAccount: args.ComputeServiceAccount,
+		KmsKey:                args.KmsKey,
+		TracerProvider:        args.TracerProvider,
 	}
 }
 
@@ -217,5 +231,12 @@ func (args *OVFExportArgs) registerFlags(cliArgs []string) error {
 	flagSet.Var((*flags.TrimmedString)(&args.BuildID), "build-id",
 		"Cloud Build ID override. This flag should be used if auto-generated or build ID provided by Cloud Build is not appropriate. For example, if running multiple exports in parallel in a single Cloud Build run, sharing build ID could cause premature temporary resource clean-up resulting in export failures.")
 	flagSet.Var((*flags.TrimmedString)(&args.ComputeServiceAccount), "compute-service-account", "Compute service account to be used by exporter Virtual Machine. When empty, the Compute Engine default service account is used.")
+	flagSet.Var((*flags.TrimmedString)(&args.ProgressOutput), "progress-output", "Where to write progress events as JSON lines: stdout, or the path of a local file that they're appended to.")
+	flagSet.Var((*flags.TrimmedString)(&args.KmsFlags.Key), "kms-key", "The Cloud KMS key that encrypts the exported files, and the disks and scratch bucket created during the export. Either the fully qualified name of the key, or its ID with -kms-keyring and -kms-location.")
+	flagSet.Var((*flags.TrimmedString)(&args.KmsFlags.Keyring), "kms-keyring", "The Cloud KMS keyring of -kms-key.")
+	flagSet.Var((*flags.TrimmedString)(&args.KmsFlags.Location), "kms-location", "The Cloud location of -kms-keyring.")
+	flagSet.Var((*flags.TrimmedString)(&args.KmsFlags.Project), "kms-project", "The project of -kms-keyring. Defaults to -project.")
+	flagSet.Var((*flags.LowerTrimmedString)(&args.TraceExporter), "trace-exporter", "Export OpenTelemetry spans of the export's workflows, steps and Compute API calls: none, stdout or file. Defaults to none.")
+	flagSet.Var((*flags.TrimmedString)(&args.TraceFile), "trace-file", "The local file that spans are appended to as JSON when -trace-exporter=file.")
 	return flagSet.Parse(cliArgs)
 }
@@ -18,7 +18,9 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
 
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/kms"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/daisycommon"
 )
 
@@ -34,6 +36,8 @@ func TestIsMachineImageExport(t *testing.T) {
 
 func TestDaisyAttrs(t *testing.T) {
 	params := GetAllInstanceExportArgs()
+	params.KmsKey = "projects/p/locations/l/keyRings/r/cryptoKeys/k"
+	params.TracerProvider = trace.NewNoopTracerProvider()
 	assert.Equal(t,
 		daisycommon.EnvironmentSettings{
 			Project:               params.Project,
@@ -50,6 +54,23 @@ func TestDaisyAttrs(t *testing.T) {
 			Network:               params.Network,
 			Subnet:                params.Subnet,
 			ComputeServiceAccount: params.ComputeServiceAccount,
+			KmsKey:                params.KmsKey,
+			TracerProvider:        params.TracerProvider,
 		},
 		params.EnvironmentSettings())
 }
+
+func TestNewOVFExportArgs_SupportsKmsFlags(t *testing.T) {
+	args, err := NewOVFExportArgs([]string{"-kms-key", " key ", "-kms-keyring", "ring",
+		"-kms-location", "us-west1", "-kms-project", "kms-project"})
+	assert.NoError(t, err)
+	assert.Equal(t, kms.KeyFlags{Key: "key", Keyring: "ring", Location: "us-west1", Project: "kms-project"},
+		args.KmsFlags)
+}
+
+func TestNewOVFExportArgs_SupportsTraceFlags(t *testing.T) {
+	args, err := NewOVFExportArgs([]string{"-trace-exporter", " File ", "-trace-file", " /tmp/spans.json "})
+	assert.NoError(t, err)
+	assert.Equal(t, "file", args.TraceExporter)
+	assert.Equal(t, "/tmp/spans.json", args.TraceFile)
+}
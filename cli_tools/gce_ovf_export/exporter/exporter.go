@@ -25,8 +25,10 @@ import (
 	commondisk "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/disk"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/domain"
 	computeutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/compute"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/kms"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging/service"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/param"
 	storageutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/storage"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/gce_ovf_export/domain"
 	"github.com/GoogleCloudPlatform/compute-image-tools/daisy"
@@ -78,10 +80,18 @@ func NewOVFExporter(params *ovfexportdomain.OVFExportArgs, logger logging.ToolLo
 	}
 	metadataGCE := &computeutils.MetadataGCE{}
 
+	if err := populateKmsKey(params, metadataGCE, func() (kms.KeyValidator, error) {
+		return kms.NewKeyValidator(ctx, params.Oauth)
+	}); err != nil {
+		return nil, err
+	}
+	scratchBucketCreator := storageutils.NewScratchBucketCreator(ctx, storageClient)
+	scratchBucketCreator.KmsKey = params.KmsKey
+
 	paramValidator := NewOvfExportParamValidator(computeClient)
 	paramPopulator := NewPopulator(metadataGCE, storageClient,
 		storageutils.NewResourceLocationRetriever(metadataGCE, computeClient),
-		storageutils.NewScratchBucketCreator(ctx, storageClient),
+		scratchBucketCreator,
 	)
 	if err := validateAndPopulateParams(params, paramValidator, paramPopulator); err != nil {
 		return nil, err
@@ -107,6 +117,28 @@ func NewOVFExporter(params *ovfexportdomain.OVFExportArgs, logger logging.ToolLo
 	}, nil
 }
 
+// populateKmsKey sets KmsKey to the fully qualified name of the key identified
+// by KmsFlags, and checks that it can be used. The key is in Project by default,
+// so Project is populated first when a key is specified. It's called before
+// the scratch bucket is created, since the bucket is encrypted with the key.
+func populateKmsKey(params *ovfexportdomain.OVFExportArgs, metadataClient domain.MetadataGCEInterface,
+	newValidator func() (kms.KeyValidator, error)) (err error) {
+	if params.KmsFlags.Key != "" {
+		if err := param.PopulateProjectIfMissing(metadataClient, &params.Project); err != nil {
+			return err
+		}
+	}
+	params.KmsKey, err = params.KmsFlags.Name(params.Project)
+	if err != nil || params.KmsKey == "" {
+		return err
+	}
+	validator, err := newValidator()
+	if err != nil {
+		return err
+	}
+	return validator.Validate(params.KmsKey)
+}
+
 // validateAndPopulateParams validate and populate OVF export params
 func validateAndPopulateParams(params *ovfexportdomain.OVFExportArgs,
 	paramValidator ovfexportdomain.OvfExportParamValidator,
@@ -128,7 +160,8 @@ func createComputeClient(ctx *context.Context, params *ovfexportdomain.OVFExport
 		computeOptions = append(computeOptions, option.WithEndpoint(params.Ce))
 	}
 
-	computeClient, err := daisycompute.NewClient(*ctx, computeOptions...)
+	computeClient, err := daisycompute.NewClientWithConfig(*ctx,
+		daisycompute.ClientConfig{TracerProvider: params.TracerProvider}, computeOptions...)
 	if err != nil {
 		return nil, err
 	}
@@ -172,6 +205,9 @@ func (oe *OVFExporter) run(ctx context.Context) error {
 	if err = oe.generateManifest(ctx); err != nil {
 		return err
 	}
+	if err = oe.encryptFiles(ctx); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -22,6 +22,7 @@ import (
 
 	"cloud.google.com/go/storage"
 	mock_disk "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/disk/mocks"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/kms"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging/service"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/gce_ovf_export/domain"
 	ovfexportmocks "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/gce_ovf_export/domain/mocks"
@@ -483,3 +484,43 @@ func TestValidateAndPopulateParams_ErrorOnPopulate(t *testing.T) {
 	err := validateAndPopulateParams(params, paramValidator, paramPopulator)
 	assert.Equal(t, populatorError, err)
 }
+
+func TestPopulateKmsKey_UsesInferredProject(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockMetadataGce := mocks.NewMockMetadataGCEInterface(mockCtrl)
+	mockMetadataGce.EXPECT().OnGCE().Return(true)
+	mockMetadataGce.EXPECT().ProjectID().Return("inferred-project", nil)
+
+	params := &ovfexportdomain.OVFExportArgs{
+		KmsFlags: kms.KeyFlags{Key: "key", Keyring: "ring", Location: "us-west1"},
+	}
+	validator := &mockKeyValidator{}
+	assert.NoError(t, populateKmsKey(params, mockMetadataGce, validator.new))
+	assert.Equal(t, "inferred-project", params.Project)
+	assert.Equal(t, "projects/inferred-project/locations/us-west1/keyRings/ring/cryptoKeys/key", params.KmsKey)
+	assert.Equal(t, []string{params.KmsKey}, validator.validated)
+}
+
+func TestPopulateKmsKey_DoesntInferProject_WhenKeyIsNotSpecified(t *testing.T) {
+	params := &ovfexportdomain.OVFExportArgs{}
+	validator := &mockKeyValidator{}
+	assert.NoError(t, populateKmsKey(params, nil, validator.new))
+	assert.Empty(t, params.Project)
+	assert.Empty(t, params.KmsKey)
+	assert.Empty(t, validator.validated)
+}
+
+type mockKeyValidator struct {
+	validated []string
+	err       error
+}
+
+func (m *mockKeyValidator) new() (kms.KeyValidator, error) {
+	return m, nil
+}
+
+func (m *mockKeyValidator) Validate(keyName string) error {
+	m.validated = append(m.validated, keyName)
+	return m.err
+}
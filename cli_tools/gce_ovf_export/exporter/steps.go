@@ -22,6 +22,7 @@ import (
 	"google.golang.org/api/compute/v1"
 
 	daisyutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/daisy"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
 	storageutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/storage"
 	ovfexportdomain "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/gce_ovf_export/domain"
 	"github.com/GoogleCloudPlatform/compute-image-tools/daisy"
@@ -39,6 +40,7 @@ func (oe *OVFExporter) prepare(ctx context.Context, instance *compute.Instance)
 func (oe *OVFExporter) exportDisks(ctx context.Context, instance *compute.Instance) error {
 	return oe.runStep(ctx, func() error {
 		oe.Logger.User("Exporting the disks.")
+		logging.ReportPhase(oe.Logger, logging.PhaseExport)
 		var err error
 		oe.exportedDisks, err = oe.instanceDisksExporter.Export(instance, oe.params)
 		return err
@@ -48,6 +50,7 @@ func (oe *OVFExporter) exportDisks(ctx context.Context, instance *compute.Instan
 func (oe *OVFExporter) inspectBootDisk(ctx context.Context) error {
 	return oe.runStep(ctx, func() error {
 		oe.Logger.User("Inspecting the boot disk.")
+		logging.ReportPhase(oe.Logger, logging.PhaseInspect)
 		bootDisk := getBootDisk(oe.exportedDisks)
 		if bootDisk == nil {
 			return nil
@@ -84,6 +87,29 @@ func (oe *OVFExporter) generateManifest(ctx context.Context) error {
 	}, oe.manifestFileGenerator.Cancel)
 }
 
+// encryptFiles rewrites the exported files with the KMS key, when it's
+// specified. The disk files are written by the export workers, and the
+// descriptor and manifest by the storage client, neither of which use the key.
+func (oe *OVFExporter) encryptFiles(ctx context.Context) error {
+	if oe.params.KmsKey == "" {
+		return nil
+	}
+	oe.Logger.User("Encrypting exported files.")
+	var gcsPaths []string
+	for _, exportedDisk := range oe.exportedDisks {
+		gcsPaths = append(gcsPaths, exportedDisk.GcsPath)
+	}
+	gcsPaths = append(gcsPaths,
+		fmt.Sprintf("%v%v.ovf", oe.params.DestinationDirectory, oe.params.OvfName),
+		fmt.Sprintf("%v%v.mf", oe.params.DestinationDirectory, oe.params.OvfName))
+	for _, gcsPath := range gcsPaths {
+		if err := storageutils.RewriteWithKmsKey(ctx, oe.storageClient, gcsPath, oe.params.KmsKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (oe *OVFExporter) cleanup(instance *compute.Instance, exportError error) error {
 	// cleanup shouldn't react to time out as it's necessary to perform this step.
 	// Otherwise, instance being exported would be left shut down and disks detached.
@@ -24,6 +24,7 @@ import (
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging/service"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/gce_ovf_export/domain"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/gce_ovf_export/exporter"
+	"github.com/GoogleCloudPlatform/compute-image-tools/daisy"
 )
 
 func createInstanceExportInputParams(args ovfexportdomain.OVFExportArgs) service.InputParams {
@@ -99,6 +100,26 @@ func runExport(args []string) error {
 		logFailure(*exportArgs, err)
 		return err
 	}
+	progressOutput, err := logging.OpenProgressOutput(exportArgs.ProgressOutput)
+	if err != nil {
+		logFailure(*exportArgs, err)
+		return err
+	}
+	if progressOutput != nil {
+		defer progressOutput.Close()
+		logger.SetProgressOutput(progressOutput)
+	}
+	tracerProvider, shutdownTracing, err := daisy.NewTracerProvider(exportArgs.TraceExporter, exportArgs.TraceFile)
+	if err != nil {
+		logFailure(*exportArgs, err)
+		return err
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Printf("error exporting spans: %v", err)
+		}
+	}()
+	exportArgs.TracerProvider = tracerProvider
 
 	var oe *ovfexporter.OVFExporter
 	if oe, err = ovfexporter.NewOVFExporter(exportArgs, logger); err != nil {
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	computeBeta "google.golang.org/api/compute/v0.beta"
 	"google.golang.org/api/compute/v1"
 
@@ -87,12 +88,22 @@ type OVFImportParams struct {
 	UefiCompatible              bool
 	Hostname                    string
 	BuildID                     string
+	ProgressOutput              string
 
 	// Non-flags
 
 	// Deadline of when timeout will occur.
 	Deadline time.Time
 
+	// KmsKey is the fully qualified name of the key identified by the
+	// BootDiskKms flags. It encrypts the disks, images, and instance
+	// created by the import.
+	KmsKey string
+
+	// TracerProvider traces the import's workflows and Compute API calls.
+	// The global TracerProvider is used when it's nil.
+	TracerProvider trace.TracerProvider
+
 	UserLabels            map[string]string
 	UserTags              []string
 	NodeAffinities        []*compute.SchedulingNodeAffinity
@@ -56,7 +56,15 @@ func (mp *MachineTypeProvider) GetMachineType() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	machineTypes, err := mp.ComputeClient.ListMachineTypes(mp.Project, mp.Zone)
+	return GetMachineTypeForResources(mp.ComputeClient, mp.Project, mp.Zone, cpuCount, memoryMB)
+}
+
+// GetMachineTypeForResources returns the machine type in zone with the fewest
+// vCPUs, and then the least memory, that has at least cpuCount vCPUs and
+// memoryMB MBs of memory.
+func GetMachineTypeForResources(computeClient daisycompute.Client, project string, zone string,
+	cpuCount int64, memoryMB int64) (string, error) {
+	machineTypes, err := computeClient.ListMachineTypes(project, zone)
 	if err != nil {
 		return "", err
 	}
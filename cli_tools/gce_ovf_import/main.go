@@ -16,18 +16,23 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"log"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
 
-	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/daisy"
+	"go.opentelemetry.io/otel/trace"
+
+	daisyutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/daisy"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/flags"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging/service"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/gce_ovf_import/domain"
 	ovfimporter "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/gce_ovf_import/ovf_importer"
+	"github.com/GoogleCloudPlatform/compute-image-tools/daisy"
 )
 
 var (
@@ -48,7 +53,7 @@ var (
 	privateNetworkIP            = flag.String("private-network-ip", "", "Specifies the RFC1918 IP to assign to the instance. The IP should be in the subnet or legacy network IP range.")
 	noExternalIP                = flag.Bool("no-external-ip", false, "Specifies that VPC into which instances is being imported doesn't allow external IPs.")
 	noRestartOnFailure          = flag.Bool("no-restart-on-failure", false, "the instance will not be restarted if it’s terminated by Compute Engine. This does not affect terminations performed by the user.")
-	osID                        = flag.String("os", "", "Specifies the OS of the image being imported. OS must be one of: "+strings.Join(daisy.GetSortedOSIDs(), ", ")+".")
+	osID                        = flag.String("os", "", "Specifies the OS of the image being imported. OS must be one of: "+strings.Join(daisyutils.GetSortedOSIDs(), ", ")+".")
 	byol                        = flag.Bool("byol", false, "Import using an existing license. These are equivalent: `-os=rhel-8 -byol`, `-os=rhel-8-byol -byol`, and `-os=rhel-8-byol`")
 	shieldedIntegrityMonitoring = flag.Bool("shielded-integrity-monitoring", false, "Enables monitoring and attestation of the boot integrity of the instance. The attestation is performed against the integrity policy baseline. This baseline is initially derived from the implicitly trusted boot image when the instance is created. This baseline can be updated by using --shielded-vm-learn-integrity-policy.")
 	shieldedSecureBoot          = flag.Bool("shielded-secure-boot", false, "The instance will boot with secure boot enabled.")
@@ -75,8 +80,12 @@ var (
 	hostname                    = flag.String(ovfimporter.HostnameFlagKey, "", "Specify the hostname of the instance to be created. The specified hostname must be RFC1035 compliant.")
 	machineImageStorageLocation = flag.String(ovfimporter.MachineImageStorageLocationFlagKey, "", "GCS bucket storage location of the machine image being imported (regional or multi-regional)")
 	buildID                     = flag.String("build-id", "", "Cloud Build ID override. This flag should be used if auto-generated or build ID provided by Cloud Build is not appropriate. For example, if running multiple imports in parallel in a single Cloud Build run, sharing build ID could cause premature temporary resource clean-up resulting in import failures.")
+	progressOutput              = flag.String("progress-output", "", "Where to write progress events as JSON lines: stdout, or the path of a local file that they're appended to.")
+	traceExporter               = flag.String("trace-exporter", "none", "Export OpenTelemetry spans of the import's workflows, steps and Compute API calls: none, stdout or file.")
+	traceFile                   = flag.String("trace-file", "", "The local file that spans are appended to as JSON when -trace-exporter=file.")
 	nodeAffinityLabelsFlag      flags.StringArrayFlag
 	currentExecutablePath       string
+	tracerProvider              trace.TracerProvider
 )
 
 func init() {
@@ -110,7 +119,7 @@ func buildOVFImportParams() *domain.OVFImportParams {
 		CurrentExecutablePath: currentExecutablePath, ReleaseTrack: *releaseTrack,
 		UefiCompatible: *uefiCompatible, Hostname: *hostname,
 		MachineImageStorageLocation: *machineImageStorageLocation, BuildID: *buildID,
-		WorkflowDir: workflowDir,
+		ProgressOutput: *progressOutput, WorkflowDir: workflowDir, TracerProvider: tracerProvider,
 	}
 }
 
@@ -134,6 +143,12 @@ func runImport() (service.Loggable, error) {
 func main() {
 	flag.Parse()
 
+	var shutdownTracing func(context.Context) error
+	var err error
+	if tracerProvider, shutdownTracing, err = daisy.NewTracerProvider(*traceExporter, *traceFile); err != nil {
+		log.Fatal(err)
+	}
+
 	var paramLog service.InputParams
 	var action string
 
@@ -146,7 +161,11 @@ func main() {
 		action = service.MachineImageImportAction
 	}
 
-	if err := service.RunWithServerLogging(action, paramLog, project, runImport); err != nil {
+	err = service.RunWithServerLogging(action, paramLog, project, runImport)
+	if tErr := shutdownTracing(context.Background()); tErr != nil {
+		log.Printf("error exporting spans: %v", tErr)
+	}
+	if err != nil {
 		os.Exit(1)
 	}
 }
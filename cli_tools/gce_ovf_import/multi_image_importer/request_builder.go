@@ -46,6 +46,7 @@ func (r *requestBuilder) buildRequests(params *ovfdomain.OVFImportParams, fileUR
 			DaisyLogLinePrefix:    fmt.Sprintf("disk-%d", i+1),
 			GcsLogsDisabled:       params.GcsLogsDisabled,
 			ImageName:             imageName,
+			KmsKey:                params.KmsKey,
 			Network:               params.Network,
 			NoExternalIP:          params.NoExternalIP,
 			NoGuestEnvironment:    params.NoGuestEnvironment,
@@ -56,6 +57,7 @@ func (r *requestBuilder) buildRequests(params *ovfdomain.OVFImportParams, fileUR
 			StdoutLogsDisabled:    params.StdoutLogsDisabled,
 			Subnet:                params.Subnet,
 			Timeout:               params.Deadline.Sub(time.Now()),
+			TracerProvider:        params.TracerProvider,
 			UefiCompatible:        params.UefiCompatible,
 			Zone:                  params.Zone,
 		}
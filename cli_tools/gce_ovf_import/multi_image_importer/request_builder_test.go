@@ -22,6 +22,7 @@ import (
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/image/importer"
 	imagemocks "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/image/importer/mocks"
@@ -80,6 +81,8 @@ func TestBuildRequests_InitsFields(t *testing.T) {
 	assertAllEqual(t, params.Subnet, boot.Subnet, data.Subnet)
 	assertAllEqual(t, params.Zone, boot.Zone, data.Zone)
 	assertAllEqual(t, *params.Project, boot.Project, data.Project)
+	assertAllEqual(t, params.KmsKey, boot.KmsKey, data.KmsKey)
+	assertAllEqual(t, params.TracerProvider, boot.TracerProvider, data.TracerProvider)
 	assertAllEqual(t, builder.workflowDir, boot.WorkflowDir, data.WorkflowDir)
 }
 
@@ -177,6 +180,7 @@ func makeDefaultParams() *ovfdomain.OVFImportParams {
 		CloudLogsDisabled:    true,
 		Ce:                   "https://compute-endpoint",
 		GcsLogsDisabled:      true,
+		KmsKey:               "projects/p/locations/l/keyRings/r/cryptoKeys/k",
 		Network:              "global/network",
 		NoExternalIP:         true,
 		NoGuestEnvironment:   true,
@@ -184,6 +188,7 @@ func makeDefaultParams() *ovfdomain.OVFImportParams {
 		OsID:                 "ubuntu-1804",
 		StdoutLogsDisabled:   true,
 		Subnet:               "regional/subnet",
+		TracerProvider:       trace.NewNoopTracerProvider(),
 		UefiCompatible:       true,
 		Zone:                 "us-central1-a",
 	}
@@ -17,6 +17,7 @@ package ovfimporter
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"path"
 	"path/filepath"
@@ -31,6 +32,7 @@ import (
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/domain"
 	computeutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/compute"
 	daisyutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/daisy"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/kms"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
 	pathutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/path"
 	storageutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/storage"
@@ -83,6 +85,7 @@ type OVFImporter struct {
 	params              *ovfdomain.OVFImportParams
 	imageLocation       string
 	paramValidator      *ParamValidatorAndPopulator
+	progressOutput      io.Closer
 
 	// Populated when disk file import finishes.
 	imageURIs []string
@@ -95,6 +98,13 @@ func NewOVFImporter(params *ovfdomain.OVFImportParams) (*OVFImporter, error) {
 	log.SetPrefix(logPrefix + " ")
 	logger := logging.NewToolLogger(logPrefix)
 	logging.RedirectGlobalLogsToUser(logger)
+	progressOutput, err := logging.OpenProgressOutput(params.ProgressOutput)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open progress output: %v", err)
+	}
+	if progressOutput != nil {
+		logger.SetProgressOutput(progressOutput)
+	}
 	storageClient, err := storageutils.NewStorageClient(ctx, logger)
 	if err != nil {
 		return nil, err
@@ -103,6 +113,12 @@ func NewOVFImporter(params *ovfdomain.OVFImportParams) (*OVFImporter, error) {
 	if err != nil {
 		return nil, err
 	}
+	var kmsKeyValidator kms.KeyValidator
+	if params.BootDiskKmskey != "" {
+		if kmsKeyValidator, err = kms.NewKeyValidator(ctx, params.Oauth); err != nil {
+			return nil, err
+		}
+	}
 	tarGcsExtractor := storageutils.NewTarGcsExtractor(ctx, storageClient, logger)
 	workingDirOVFImportWorkflow := toWorkingDir(getImportWorkflowPath(params), params)
 	ovfImporter := &OVFImporter{
@@ -115,12 +131,14 @@ func NewOVFImporter(params *ovfdomain.OVFImportParams) (*OVFImporter, error) {
 		ovfDescriptorLoader: ovfutils.NewOvfDescriptorLoader(storageClient),
 		Logger:              logger,
 		params:              params,
+		progressOutput:      progressOutput,
 		paramValidator: &ParamValidatorAndPopulator{
 			&computeutils.MetadataGCE{},
 			&computeutils.ZoneValidator{ComputeClient: computeClient},
 			&storageutils.BucketIteratorCreator{},
 			storageClient,
 			logger,
+			kmsKeyValidator,
 		},
 	}
 	return ovfImporter, nil
@@ -242,7 +260,8 @@ func createComputeClient(ctx *context.Context, params *ovfdomain.OVFImportParams
 		computeOptions = append(computeOptions, option.WithEndpoint(params.Ce))
 	}
 
-	computeClient, err := daisycompute.NewClient(*ctx, computeOptions...)
+	computeClient, err := daisycompute.NewClientWithConfig(*ctx,
+		daisycompute.ClientConfig{TracerProvider: params.TracerProvider}, computeOptions...)
 	if err != nil {
 		return nil, err
 	}
@@ -386,6 +405,8 @@ func (oi *OVFImporter) setUpImportWorkflow() (workflow *daisy.Workflow, err erro
 		return nil, fmt.Errorf("error parsing workflow %q: %v", oi.workflowPath, err)
 	}
 	workflow.ForceCleanupOnError = true
+	workflow.KmsKey = oi.params.KmsKey
+	workflow.TracerProvider = oi.params.TracerProvider
 	return workflow, nil
 }
 
@@ -405,6 +426,7 @@ func (oi *OVFImporter) Import() (*daisy.Workflow, error) {
 
 	go oi.handleTimeout(w)
 
+	logging.ReportPhase(oi.Logger, logging.PhaseCreateInstance)
 	if err := w.RunWithModifiers(oi.ctx, oi.modifyWorkflowPreValidate, oi.modifyWorkflowPostValidate); err != nil {
 		oi.Logger.User(err.Error())
 		daisyutils.PostProcessDErrorForNetworkFlag("instance import", err, oi.params.Network, w)
@@ -437,6 +459,9 @@ func (oi *OVFImporter) CleanUp() {
 			oi.Logger.User(fmt.Sprintf("couldn't close storage client: %v", err.Error()))
 		}
 	}
+	if oi.progressOutput != nil {
+		oi.progressOutput.Close()
+	}
 }
 
 func (oi *OVFImporter) importDisks(osID string, diskInfos *[]ovfutils.DiskInfo) error {
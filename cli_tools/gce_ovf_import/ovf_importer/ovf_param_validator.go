@@ -27,6 +27,7 @@ import (
 
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/domain"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/compute"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/kms"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/param"
 	pathutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/path"
@@ -66,6 +67,7 @@ type ParamValidatorAndPopulator struct {
 	bucketIteratorCreator domain.BucketIteratorCreatorInterface
 	storageClient         domain.StorageClientInterface
 	logger                logging.Logger
+	kmsKeyValidator       kms.KeyValidator
 }
 
 // ValidateAndPopulate validates OVFImportParams, and populates values that are missing.
@@ -93,8 +95,12 @@ func (p *ParamValidatorAndPopulator) ValidateAndPopulate(params *ovfdomain.OVFIm
 		return err
 	}
 
+	if params.KmsKey, err = p.resolveKmsKey(params); err != nil {
+		return err
+	}
+
 	if params.ScratchBucketGcsPath, err = p.createScratchBucketIfMissing(
-		params.ScratchBucketGcsPath, *params.Project, params.Region, params.BuildID); err != nil {
+		params.ScratchBucketGcsPath, *params.Project, params.Region, params.BuildID, params.KmsKey); err != nil {
 		return err
 	}
 
@@ -188,6 +194,21 @@ func (p *ParamValidatorAndPopulator) ValidateAndPopulate(params *ovfdomain.OVFIm
 	return nil
 }
 
+// resolveKmsKey returns the fully qualified name of the key identified by the
+// BootDiskKms flags, after checking that it can be used.
+func (p *ParamValidatorAndPopulator) resolveKmsKey(params *ovfdomain.OVFImportParams) (string, error) {
+	keyName, err := kms.KeyFlags{
+		Key:      strings.TrimSpace(params.BootDiskKmskey),
+		Keyring:  strings.TrimSpace(params.BootDiskKmsKeyring),
+		Location: strings.TrimSpace(params.BootDiskKmsLocation),
+		Project:  strings.TrimSpace(params.BootDiskKmsProject),
+	}.Name(*params.Project)
+	if err != nil || keyName == "" {
+		return keyName, err
+	}
+	return keyName, p.kmsKeyValidator.Validate(keyName)
+}
+
 func (p *ParamValidatorAndPopulator) lookupProjectIfMissing(originalProject string) (*string, error) {
 	project, err := param.GetProjectID(p.metadataClient, strings.TrimSpace(originalProject))
 	return &project, err
@@ -220,7 +241,8 @@ func (p *ParamValidatorAndPopulator) lookupZoneIfMissing(project, originalZone s
 	return zone, nil
 }
 
-func (p *ParamValidatorAndPopulator) createScratchBucketIfMissing(originalBucket, project, region, buildID string) (scratchBucket string, err error) {
+func (p *ParamValidatorAndPopulator) createScratchBucketIfMissing(originalBucket, project, region, buildID,
+	kmsKey string) (scratchBucket string, err error) {
 	if originalBucket != "" {
 		scratchBucket = originalBucket
 	} else {
@@ -243,9 +265,11 @@ func (p *ParamValidatorAndPopulator) createScratchBucketIfMissing(originalBucket
 		}
 		if !projectHasBucket {
 			p.logger.User(fmt.Sprintf("Creating scratch bucket `%v` in %v region", bucket, region))
-			if err := p.storageClient.CreateBucket(
-				bucket, project,
-				&storage.BucketAttrs{Name: bucket, Location: region}); err != nil {
+			bucketAttrs := &storage.BucketAttrs{Name: bucket, Location: region}
+			if kmsKey != "" {
+				bucketAttrs.Encryption = &storage.BucketEncryption{DefaultKMSKeyName: kmsKey}
+			}
+			if err := p.storageClient.CreateBucket(bucket, project, bucketAttrs); err != nil {
 				return "", err
 			}
 		}
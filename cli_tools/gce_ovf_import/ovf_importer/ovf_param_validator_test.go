@@ -188,6 +188,74 @@ func Test_ValidateAndParseParams_CreateScratchBucket_WhenGeneratedDoesntExist(t
 	assert.Equal(t, fmt.Sprintf("gs://%s/%s", expectedBucketName, params.BuildID), params.ScratchBucketGcsPath)
 }
 
+func Test_ValidateAndParseParams_EncryptsScratchBucketWithKmsKey(t *testing.T) {
+	projectName := "test"
+	params := getAllInstanceImportParams()
+	params.Zone = "us-west2-a"
+	params.ScratchBucketGcsPath = ""
+	params.Project = &projectName
+	params.BootDiskKmskey = "aKey"
+	params.BootDiskKmsKeyring = "aKeyring"
+	params.BootDiskKmsLocation = "us-west2"
+	expectedKey := "projects/test/locations/us-west2/keyRings/aKeyring/cryptoKeys/aKey"
+	expectedBucketName := "test-ovf-import-bkt-us-west2"
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockZoneValidator := mocks.NewMockZoneValidatorInterface(mockCtrl)
+	mockZoneValidator.EXPECT().ZoneValid(projectName, params.Zone).Return(nil)
+
+	mockBucketIterator := mocks.NewMockBucketIteratorInterface(mockCtrl)
+	mockBucketIterator.EXPECT().Next().Return(nil, iterator.Done)
+	mockBucketIteratorCreator := mocks.NewMockBucketIteratorCreatorInterface(mockCtrl)
+	mockBucketIteratorCreator.EXPECT().CreateBucketIterator(gomock.Any(), gomock.Any(), projectName).Return(mockBucketIterator)
+
+	mockStorage := mocks.NewMockStorageClientInterface(mockCtrl)
+	mockStorage.EXPECT().CreateBucket(expectedBucketName, projectName, &storage.BucketAttrs{
+		Name:       expectedBucketName,
+		Location:   "us-west2",
+		Encryption: &storage.BucketEncryption{DefaultKMSKeyName: expectedKey},
+	})
+	validator := &fakeKeyValidator{}
+	err := (&ParamValidatorAndPopulator{
+		zoneValidator:         mockZoneValidator,
+		bucketIteratorCreator: mockBucketIteratorCreator,
+		logger:                logging.NewToolLogger("test"),
+		storageClient:         mockStorage,
+		kmsKeyValidator:       validator,
+	}).ValidateAndPopulate(params)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedKey, params.KmsKey)
+	assert.Equal(t, []string{expectedKey}, validator.validated)
+}
+
+func Test_ValidateAndParseParams_Fail_WhenKmsKeyIsInvalid(t *testing.T) {
+	params := getAllInstanceImportParams()
+	params.BootDiskKmskey = "projects/p/locations/l/keyRings/r/cryptoKeys/k"
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockZoneValidator := mocks.NewMockZoneValidatorInterface(mockCtrl)
+	mockZoneValidator.EXPECT().ZoneValid(defaultProject, params.Zone).Return(nil)
+
+	err := (&ParamValidatorAndPopulator{
+		zoneValidator:   mockZoneValidator,
+		kmsKeyValidator: &fakeKeyValidator{err: errors.New("key is disabled")},
+	}).ValidateAndPopulate(params)
+	assert.EqualError(t, err, "key is disabled")
+}
+
+type fakeKeyValidator struct {
+	validated []string
+	err       error
+}
+
+func (v *fakeKeyValidator) Validate(keyName string) error {
+	v.validated = append(v.validated, keyName)
+	return v.err
+}
+
 func Test_ValidateAndParseParams_ErrorMessages(t *testing.T) {
 	type testCase struct {
 		name                 string
@@ -599,10 +667,6 @@ func getAllInstanceImportParams() *domain.OVFImportParams {
 		ShieldedVtpm:                true,
 		Tags:                        "tag1=val1",
 		Zone:                        defaultZone,
-		BootDiskKmskey:              "aKey",
-		BootDiskKmsKeyring:          "aKeyring",
-		BootDiskKmsLocation:         "aKmsLocation",
-		BootDiskKmsProject:          "aKmsProject",
 		Timeout:                     "3h",
 		Deadline:                    time.Now().Add(time.Hour * 3),
 		Project:                     &project,
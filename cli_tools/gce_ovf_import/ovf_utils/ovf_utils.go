@@ -15,6 +15,7 @@
 package ovfutils
 
 import (
+	"fmt"
 	"sort"
 	"strconv"
 	"strings"
@@ -176,7 +177,7 @@ var ovfOSIDToImporterOSID = map[int16]OsInfo{
 	106: {description: "CentOS 32-bit", importerOSIDs: []string{}},
 	107: {description: "CentOS 64-bit", importerOSIDs: []string{"centos-7", "centos-8"}},
 	108: {description: "Oracle Linux 32-bit", importerOSIDs: []string{}},
-	109: {description: "Oracle Linux 64-bit", importerOSIDs: []string{}},
+	109: {description: "Oracle Linux 64-bit", importerOSIDs: []string{"oracle-7", "oracle-8"}},
 	110: {description: "eComStation 32-bitx", importerOSIDs: []string{}},
 	111: {description: "Microsoft Windows Server 2011", importerOSIDs: []string{}},
 	113: {description: "Microsoft Windows Server 2012", importerOSIDs: []string{"windows-2012", "windows-2012-byol"}},
@@ -199,7 +200,7 @@ func GetOSInfoForInspectionResults(ir *pb.InspectionResults) (*OsInfo, int16) {
 		pb.Architecture_name[int32(ir.GetOsRelease().GetArchitecture())],
 	)
 	if err != nil {
-		return nil, 0
+		return getGenericLinuxOSInfo(ir)
 	}
 	osGcloudArg := release.AsGcloudArg()
 	for osID, osInfo := range ovfOSIDToImporterOSID {
@@ -209,7 +210,44 @@ func GetOSInfoForInspectionResults(ir *pb.InspectionResults) (*OsInfo, int16) {
 			}
 		}
 	}
-	return nil, 0
+	return getGenericLinuxOSInfo(ir)
+}
+
+// getGenericLinuxOSInfo returns the generic Linux OS info that matches the newest
+// kernel in the inspection results. It's used for distros that don't have a
+// dedicated OVF OS ID.
+func getGenericLinuxOSInfo(ir *pb.InspectionResults) (*OsInfo, int16) {
+	var major, minor int
+	for _, kernel := range ir.GetKernelVersions() {
+		var kernelMajor, kernelMinor int
+		if _, err := fmt.Sscanf(kernel, "%d.%d", &kernelMajor, &kernelMinor); err != nil {
+			continue
+		}
+		if kernelMajor > major || (kernelMajor == major && kernelMinor > minor) {
+			major, minor = kernelMajor, kernelMinor
+		}
+	}
+	arch := ir.GetOsRelease().GetArchitecture()
+	is64Bit := arch == pb.Architecture_X64 || arch == pb.Architecture_ARM64
+	var osID int16
+	switch {
+	case major >= 3 && is64Bit:
+		osID = 101
+	case major >= 3:
+		osID = 36
+	case major == 2 && minor == 6 && is64Bit:
+		osID = 100
+	case major == 2 && minor == 6:
+		osID = 99
+	case major == 2 && minor == 4 && is64Bit:
+		osID = 98
+	case major == 2 && minor == 4:
+		osID = 97
+	default:
+		return nil, 0
+	}
+	osInfo := ovfOSIDToImporterOSID[osID]
+	return &osInfo, osID
 }
 
 //Mapping OVF osType attribute to importer OS ID
@@ -225,8 +263,14 @@ var ovfOSTypeToOSID = map[string]OsInfo{
 	"centos8_64Guest":       OsInfo{importerOSIDs: []string{"centos-8"}},
 	"rhel6_64Guest":         OsInfo{importerOSIDs: []string{"rhel-6"}},
 	"rhel7_64Guest":         OsInfo{importerOSIDs: []string{"rhel-7"}},
+	"oracleLinux7_64Guest":  OsInfo{importerOSIDs: []string{"oracle-7"}},
+	"oracleLinux8_64Guest":  OsInfo{importerOSIDs: []string{"oracle-8"}},
+	"rockylinux_64Guest":    OsInfo{importerOSIDs: []string{"rocky-8"}},
+	"almalinux_64Guest":     OsInfo{importerOSIDs: []string{"almalinux-8"}},
+	"amazonlinux2_64Guest":  OsInfo{importerOSIDs: []string{"amazon-2"}},
 	"windows7Server64Guest": OsInfo{importerOSIDs: []string{"windows-2008r2"}},
 	"ubuntu64Guest":         {importerOSIDs: []string{"ubuntu-1404", "ubuntu-1604", "ubuntu-1804"}, nonDeterministic: true},
+	"oracleLinux64Guest":    {importerOSIDs: []string{"oracle-7", "oracle-8"}},
 	"windows7Guest":         {importerOSIDs: []string{"windows-7-x86-byol"}, nonDeterministic: true},
 	"windows7_64Guest":      {importerOSIDs: []string{"windows-7-x64-byol"}, nonDeterministic: true},
 	"windows8Guest":         {importerOSIDs: []string{"windows-8-x86-byol"}, nonDeterministic: true},
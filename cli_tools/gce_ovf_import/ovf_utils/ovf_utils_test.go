@@ -25,6 +25,7 @@ import (
 	"github.com/vmware/govmomi/ovf"
 
 	ovfdomainmocks "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/gce_ovf_import/domain/mocks"
+	"github.com/GoogleCloudPlatform/compute-image-tools/proto/go/pb"
 )
 
 var (
@@ -706,6 +707,62 @@ func TestGetOSIdNotSupported(t *testing.T) {
 		err.Error())
 }
 
+func TestGetOSInfoForInspectionResults(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		ir         *pb.InspectionResults
+		expectedID int16
+	}{
+		{
+			name: "supported distro",
+			ir: &pb.InspectionResults{OsRelease: &pb.OsRelease{
+				Distro: "centos", MajorVersion: "7", Architecture: pb.Architecture_X64}},
+			expectedID: 107,
+		},
+		{
+			name: "oracle linux",
+			ir: &pb.InspectionResults{OsRelease: &pb.OsRelease{
+				Distro: "oracle", MajorVersion: "8", MinorVersion: "4", Architecture: pb.Architecture_X64}},
+			expectedID: 109,
+		},
+		{
+			name: "unmapped distro falls back to kernel version",
+			ir: &pb.InspectionResults{
+				OsRelease:      &pb.OsRelease{Distro: "kali", MajorVersion: "2020", Architecture: pb.Architecture_X64},
+				KernelVersions: []string{"2.6.32-754.el6.x86_64", "5.9.0-kali1-amd64", "not-a-version"},
+			},
+			expectedID: 101,
+		},
+		{
+			name: "32-bit 2.6 kernel",
+			ir: &pb.InspectionResults{
+				OsRelease:      &pb.OsRelease{Distro: "kali", MajorVersion: "1", Architecture: pb.Architecture_X86},
+				KernelVersions: []string{"2.6.32-5-686"},
+			},
+			expectedID: 99,
+		},
+		{
+			name: "64-bit 2.4 kernel",
+			ir: &pb.InspectionResults{
+				OsRelease:      &pb.OsRelease{Distro: "kali", MajorVersion: "1", Architecture: pb.Architecture_X64},
+				KernelVersions: []string{"2.4.21-4.EL"},
+			},
+			expectedID: 98,
+		},
+		{
+			name: "unmapped distro without kernels",
+			ir: &pb.InspectionResults{OsRelease: &pb.OsRelease{
+				Distro: "kali", MajorVersion: "2020", Architecture: pb.Architecture_X64}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			osInfo, osID := GetOSInfoForInspectionResults(tt.ir)
+			assert.Equal(t, tt.expectedID, osID)
+			assert.Equal(t, tt.expectedID != 0, osInfo != nil)
+		})
+	}
+}
+
 func createOVFDescriptorWithOSType(osType string) *ovf.Envelope {
 	return createOVFDescriptorWithOSTypeAsReference(&osType)
 }
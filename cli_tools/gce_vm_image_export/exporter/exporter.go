@@ -21,10 +21,13 @@ import (
 	"os"
 	"strings"
 
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/api/option"
 
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/domain"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/compute"
 	daisyutils "github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/daisy"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/kms"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/param"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/path"
@@ -139,7 +142,7 @@ func buildDaisyVars(destinationURI string, sourceImage string, sourceDiskSnapsho
 func runExportWorkflow(ctx context.Context, exportWorkflowPath string, varMap map[string]string,
 	project string, zone string, timeout string, scratchBucketGcsPath string, oauth string, ce string,
 	gcsLogsDisabled bool, cloudLogsDisabled bool, stdoutLogsDisabled bool,
-	userLabels map[string]string) (*daisy.Workflow, error) {
+	userLabels map[string]string, kmsKey string, tracerProvider trace.TracerProvider) (*daisy.Workflow, error) {
 
 	workflow, err := daisycommon.ParseWorkflow(exportWorkflowPath, varMap,
 		project, zone, scratchBucketGcsPath, oauth, timeout, ce, gcsLogsDisabled,
@@ -147,6 +150,8 @@ func runExportWorkflow(ctx context.Context, exportWorkflowPath string, varMap ma
 	if err != nil {
 		return nil, err
 	}
+	workflow.KmsKey = kmsKey
+	workflow.TracerProvider = tracerProvider
 
 	preValidateWorkflowModifier := func(w *daisy.Workflow) {
 		w.SetLogProcessHook(daisyutils.RemovePrivacyLogTag)
@@ -172,11 +177,15 @@ func runExportWorkflow(ctx context.Context, exportWorkflowPath string, varMap ma
 	return workflow, err
 }
 
-// Run runs export workflow.
+// Run runs export workflow. Progress events are written to progressOutput,
+// when it's specified. When kmsFlags identifies a key, it encrypts the disks
+// and scratch bucket created by the export, and the exported file. The
+// export workflow is traced with tracerProvider, when it's not nil.
 func Run(clientID string, destinationURI string, sourceImage string, sourceDiskSnapshot string, format string,
 	project *string, network string, subnet string, zone string, timeout string,
 	scratchBucketGcsPath string, oauth string, ce string, computeServiceAccount string, gcsLogsDisabled bool,
-	cloudLogsDisabled bool, stdoutLogsDisabled bool, labels string, currentExecutablePath string) (*daisy.Workflow, error) {
+	cloudLogsDisabled bool, stdoutLogsDisabled bool, labels string, progressOutput string,
+	kmsFlags kms.KeyFlags, tracerProvider trace.TracerProvider, currentExecutablePath string) (*daisy.Workflow, error) {
 
 	log.SetPrefix(logPrefix + " ")
 
@@ -185,17 +194,35 @@ func Run(clientID string, destinationURI string, sourceImage string, sourceDiskS
 		return nil, err
 	}
 
+	logger := logging.NewToolLogger(logPrefix)
+	progressWriter, err := logging.OpenProgressOutput(progressOutput)
+	if err != nil {
+		return nil, err
+	}
+	if progressWriter != nil {
+		defer progressWriter.Close()
+		logger.SetProgressOutput(progressWriter)
+	}
+
 	ctx := context.Background()
 	metadataGCE := &compute.MetadataGCE{}
+	kmsKey, err := populateKmsKey(kmsFlags, project, metadataGCE, func() (kms.KeyValidator, error) {
+		return kms.NewKeyValidator(ctx, oauth)
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	storageClient, err := storage.NewStorageClient(
-		ctx, logging.NewToolLogger(logPrefix), option.WithCredentialsFile(oauth))
+		ctx, logger, option.WithCredentialsFile(oauth))
 	if err != nil {
 		return nil, err
 	}
 	defer storageClient.Close()
 
 	scratchBucketCreator := storage.NewScratchBucketCreator(ctx, storageClient)
-	computeClient, err := param.CreateComputeClient(&ctx, oauth, ce)
+	scratchBucketCreator.KmsKey = kmsKey
+	computeClient, err := param.CreateComputeClient(&ctx, oauth, ce, tracerProvider)
 	if err != nil {
 		return nil, err
 	}
@@ -213,18 +240,47 @@ func Run(clientID string, destinationURI string, sourceImage string, sourceDiskS
 	}
 	varMap := buildDaisyVars(destinationURI, sourceImage, sourceDiskSnapshot, format, network, subnet, *region, computeServiceAccount)
 
+	logger.Progress(logging.ProgressEvent{Phase: logging.PhaseExport})
 	var w *daisy.Workflow
 	if w, err = runExportWorkflow(ctx, getWorkflowPath(format, currentExecutablePath), varMap, *project,
 		zone, timeout, scratchBucketGcsPath, oauth, ce, gcsLogsDisabled, cloudLogsDisabled,
-		stdoutLogsDisabled, userLabels); err != nil {
+		stdoutLogsDisabled, userLabels, kmsKey, tracerProvider); err != nil {
 
 		daisyutils.PostProcessDErrorForNetworkFlag("image export", err, network, w)
 
 		return w, err
 	}
+	if kmsKey != "" {
+		// The file is written by the export workers, which don't use the key.
+		if err := storage.RewriteWithKmsKey(ctx, storageClient, destinationURI, kmsKey); err != nil {
+			return w, err
+		}
+	}
 	return w, nil
 }
 
+// populateKmsKey returns the fully qualified name of the key identified by
+// kmsFlags, and checks that it can be used. The key is in project by default,
+// so project is populated first when a key is specified. It's called before
+// the scratch bucket is created, since the bucket is encrypted with the key.
+func populateKmsKey(kmsFlags kms.KeyFlags, project *string, metadataClient domain.MetadataGCEInterface,
+	newValidator func() (kms.KeyValidator, error)) (string, error) {
+	if kmsFlags.Key != "" {
+		if err := param.PopulateProjectIfMissing(metadataClient, project); err != nil {
+			return "", err
+		}
+	}
+	kmsKey, err := kmsFlags.Name(*project)
+	if err != nil || kmsKey == "" {
+		return kmsKey, err
+	}
+	validator, err := newValidator()
+	if err != nil {
+		return "", err
+	}
+	return kmsKey, validator.Validate(kmsKey)
+}
+
 // validateImageExists checks whether imageName exists in the specified project.
 //
 // This validates when imageName is a valid image name, and skips validation if
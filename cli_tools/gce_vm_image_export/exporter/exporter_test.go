@@ -21,6 +21,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/kms"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/path"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/mocks"
 )
@@ -199,6 +200,33 @@ func TestValidateImageExists_ReturnsError_WhenImageNotFound(t *testing.T) {
 		"Image \"image\" not found")
 }
 
+func TestPopulateKmsKey_UsesInferredProject(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockMetadata := mocks.NewMockMetadataGCEInterface(mockCtrl)
+	mockMetadata.EXPECT().OnGCE().Return(true)
+	mockMetadata.EXPECT().ProjectID().Return("inferred-project", nil)
+
+	project := ""
+	validator := &mockKeyValidator{}
+	kmsKey, err := populateKmsKey(kms.KeyFlags{Key: "key", Keyring: "ring", Location: "us-west1"},
+		&project, mockMetadata, validator.new)
+	assert.NoError(t, err)
+	assert.Equal(t, "inferred-project", project)
+	assert.Equal(t, "projects/inferred-project/locations/us-west1/keyRings/ring/cryptoKeys/key", kmsKey)
+	assert.Equal(t, []string{kmsKey}, validator.validated)
+}
+
+func TestPopulateKmsKey_DoesntInferProject_WhenKeyIsNotSpecified(t *testing.T) {
+	project := ""
+	validator := &mockKeyValidator{}
+	kmsKey, err := populateKmsKey(kms.KeyFlags{}, &project, nil, validator.new)
+	assert.NoError(t, err)
+	assert.Empty(t, project)
+	assert.Empty(t, kmsKey)
+	assert.Empty(t, validator.validated)
+}
+
 func resetArgs() {
 	clientID = "aClient"
 	destinationURI = "gs://bucket/exported_image"
@@ -209,3 +237,17 @@ func resetArgs() {
 	subnet = "aSubnet"
 	labels = "userkey1=uservalue1,userkey2=uservalue2"
 }
+
+type mockKeyValidator struct {
+	validated []string
+	err       error
+}
+
+func (m *mockKeyValidator) new() (kms.KeyValidator, error) {
+	return m, nil
+}
+
+func (m *mockKeyValidator) Validate(keyName string) error {
+	m.validated = append(m.validated, keyName)
+	return m.err
+}
@@ -16,11 +16,16 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"log"
 	"os"
 
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/kms"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging/service"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/gce_vm_image_export/exporter"
+	"github.com/GoogleCloudPlatform/compute-image-tools/daisy"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -43,19 +48,36 @@ var (
 	cloudLogsDisabled     = flag.Bool("disable_cloud_logging", false, "do not stream logs to Cloud Logging.")
 	stdoutLogsDisabled    = flag.Bool("disable_stdout_logging", false, "do not display individual workflow logs on stdout.")
 	labels                = flag.String("labels", "", "List of label KEY=VALUE pairs to add. Keys must start with a lowercase character and contain only hyphens (-), underscores (_), lowercase characters, and numbers. Values must contain only hyphens (-), underscores (_), lowercase characters, and numbers.")
+	progressOutput        = flag.String("progress_output", "", "Where to write progress events as JSON lines: stdout, or the path of a local file that they're appended to.")
+	kmsKey                = flag.String("kms_key", "", "The Cloud KMS key that encrypts the exported file, and the disks and scratch bucket created during the export. Either the fully qualified name of the key, or its ID with -kms_keyring and -kms_location.")
+	kmsKeyring            = flag.String("kms_keyring", "", "The Cloud KMS keyring of -kms_key.")
+	kmsLocation           = flag.String("kms_location", "", "The Cloud location of -kms_keyring.")
+	kmsProject            = flag.String("kms_project", "", "The project of -kms_keyring. Defaults to -project.")
+	traceExporter         = flag.String("trace_exporter", "none", "Export OpenTelemetry spans of the export's workflows, steps and Compute API calls: none, stdout or file.")
+	traceFile             = flag.String("trace_file", "", "The local file that spans are appended to as JSON when -trace_exporter=file.")
+
+	tracerProvider trace.TracerProvider
 )
 
 func exportEntry() (service.Loggable, error) {
 	currentExecutablePath := string(os.Args[0])
 	wf, err := exporter.Run(*clientID, *destinationURI, *sourceImage, *sourceDiskSnapshot, *format, project,
 		*network, *subnet, *zone, *timeout, *scratchBucketGcsPath, *oauth, *ce, *computeServiceAccount,
-		*gcsLogsDisabled, *cloudLogsDisabled, *stdoutLogsDisabled, *labels, currentExecutablePath)
+		*gcsLogsDisabled, *cloudLogsDisabled, *stdoutLogsDisabled, *labels, *progressOutput,
+		kms.KeyFlags{Key: *kmsKey, Keyring: *kmsKeyring, Location: *kmsLocation, Project: *kmsProject},
+		tracerProvider, currentExecutablePath)
 	return service.NewLoggableFromWorkflow(wf), err
 }
 
 func main() {
 	flag.Parse()
 
+	var shutdownTracing func(context.Context) error
+	var err error
+	if tracerProvider, shutdownTracing, err = daisy.NewTracerProvider(*traceExporter, *traceFile); err != nil {
+		log.Fatal(err)
+	}
+
 	paramLog := service.InputParams{
 		ImageExportParams: &service.ImageExportParams{
 			CommonParams: &service.CommonParams{
@@ -79,10 +101,18 @@ func main() {
 			SourceImage:           *sourceImage,
 			Format:                *format,
 			ComputeServiceAccount: *computeServiceAccount,
+			HasKmsKey:             *kmsKey != "",
+			HasKmsKeyring:         *kmsKeyring != "",
+			HasKmsLocation:        *kmsLocation != "",
+			HasKmsProject:         *kmsProject != "",
 		},
 	}
 
-	if err := service.RunWithServerLogging(service.ImageExportAction, paramLog, project, exportEntry); err != nil {
+	err = service.RunWithServerLogging(service.ImageExportAction, paramLog, project, exportEntry)
+	if tErr := shutdownTracing(context.Background()); tErr != nil {
+		log.Printf("error exporting spans: %v", tErr)
+	}
+	if err != nil {
 		os.Exit(1)
 	}
 }
@@ -21,9 +21,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/domain"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/image/importer"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/daisy"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/flags"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/kms"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/param"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/path"
 )
@@ -37,9 +40,34 @@ type imageImportArgs struct {
 	SourceFile    string
 	SourceImage   string
 	Started       time.Time
+
+	// ProgressOutput is where progress events are written: stdout, or a
+	// file path. Events aren't written when it's empty.
+	ProgressOutput string
+
+	// TraceExporter and TraceFile configure where spans of the import's
+	// workflows are exported; see daisy.NewTracerProvider.
+	TraceExporter string
+	TraceFile     string
+
+	// KmsFlags identifies the Cloud KMS key that encrypts the resources
+	// created by the import. It's resolved to ImageImportRequest.KmsKey.
+	KmsFlags kms.KeyFlags
+
+	// When Manifest is set, the images it lists are imported, and the
+	// remaining arguments are used as defaults for each image.
+	Manifest             string
+	MaxConcurrentImports int
+	ReportFile           string
 	importer.ImageImportRequest
 }
 
+// Flags for batch imports.
+const (
+	manifestFlag             = "manifest"
+	maxConcurrentImportsFlag = "max_concurrent_imports"
+)
+
 // parseArgsFromUser creates an imageImportArgs instance from the arguments
 // passed by the user.
 func parseArgsFromUser(argsFromUser []string) (imageImportArgs, error) {
@@ -52,6 +80,28 @@ func parseArgsFromUser(argsFromUser []string) (imageImportArgs, error) {
 	return parsed, flagSet.Parse(argsFromUser)
 }
 
+// populateKmsKey sets KmsKey to the fully qualified name of the key identified
+// by KmsFlags, and checks that it can be used. The key is in Project by default,
+// so Project is populated first when a key is specified. It's called before
+// populateAndValidate, since the scratch bucket is encrypted with the key.
+func (args *imageImportArgs) populateKmsKey(metadataClient domain.MetadataGCEInterface,
+	newValidator func() (kms.KeyValidator, error)) (err error) {
+	if args.KmsFlags.Key != "" {
+		if err := param.PopulateProjectIfMissing(metadataClient, &args.Project); err != nil {
+			return err
+		}
+	}
+	args.KmsKey, err = args.KmsFlags.Name(args.Project)
+	if err != nil || args.KmsKey == "" {
+		return err
+	}
+	validator, err := newValidator()
+	if err != nil {
+		return err
+	}
+	return validator.Validate(args.KmsKey)
+}
+
 // populateAndValidate populates missing arguments, and validates specified
 // arguments. We depend on the importer module to validate *its* arguments,
 // so validation is limited to the fields that aren't used by that module.
@@ -75,8 +125,14 @@ func (args *imageImportArgs) populateAndValidate(populator param.Populator,
 	if err != nil {
 		return err
 	}
+	// The scratch bucket is created in the region of a source file in GCS.
+	// Other source files are staged in the scratch bucket.
+	gcsSourceFile := args.SourceFile
+	if _, staged := args.Source.(importer.StagedSource); staged {
+		gcsSourceFile = ""
+	}
 	if err := populator.PopulateMissingParameters(&args.Project, args.ClientID, &args.Zone, &args.Region,
-		&args.ScratchBucketGcsPath, args.SourceFile, &args.StorageLocation); err != nil {
+		&args.ScratchBucketGcsPath, gcsSourceFile, &args.StorageLocation); err != nil {
 		return err
 	}
 
@@ -148,13 +204,24 @@ func (args *imageImportArgs) registerFlags(flagSet *flag.FlagSet) {
 	flagSet.Var((*flags.TrimmedString)(&args.ExecutionID), "execution_id",
 		"The execution ID to differentiate GCE resources of each imports.")
 
-	flagSet.Bool("kms_key", false, "Reserved for future use.")
-	flagSet.Bool("kms_keyring", false, "Reserved for future use.")
-	flagSet.Bool("kms_location", false, "Reserved for future use.")
-	flagSet.Bool("kms_project", false, "Reserved for future use.")
+	flagSet.Var((*flags.TrimmedString)(&args.KmsFlags.Key), "kms_key",
+		"The Cloud KMS key that encrypts the image, and the disks, snapshots, and scratch bucket "+
+			"created during the import. Either the fully qualified name of the key, or its ID "+
+			"with -kms_keyring and -kms_location.")
+	flagSet.Var((*flags.TrimmedString)(&args.KmsFlags.Keyring), "kms_keyring",
+		"The Cloud KMS keyring of -kms_key.")
+	flagSet.Var((*flags.TrimmedString)(&args.KmsFlags.Location), "kms_location",
+		"The Cloud location of -kms_keyring.")
+	flagSet.Var((*flags.TrimmedString)(&args.KmsFlags.Project), "kms_project",
+		"The project of -kms_keyring. Defaults to -project.")
 
 	flagSet.Var((*flags.LowerTrimmedString)(&args.ImageName), importer.ImageFlag,
-		"Name of the disk image to create.")
+		"Name of the disk image to create. When -"+importer.OutputFlag+" is disk or snapshot, "+
+			"it's the name of the disk or snapshot.")
+
+	flagSet.Var((*flags.LowerTrimmedString)(&args.Output), importer.OutputFlag,
+		"The type of resource to create: "+importer.OutputImage+" (the default), "+importer.OutputDisk+", or "+
+			importer.OutputSnapshot+". A disk is created in -zone, and is ready to attach to an instance.")
 
 	flagSet.Var((*flags.TrimmedString)(&args.Family), "family",
 		"Family to set for the imported image.")
@@ -172,7 +239,9 @@ func (args *imageImportArgs) registerFlags(flagSet *flag.FlagSet) {
 			"location closest to the source is chosen automatically.")
 
 	flagSet.Var((*flags.TrimmedString)(&args.SourceFile), "source_file",
-		"The Cloud Storage URI of the virtual disk file to import.")
+		"The Cloud Storage URI of the virtual disk file to import. A local path or an "+
+			"HTTP(S) URL may be used instead, in which case the file is uploaded to the "+
+			"scratch bucket before the import, and deleted afterwards.")
 
 	flagSet.Var((*flags.TrimmedString)(&args.SourceImage), "source_image",
 		"An existing Compute Engine image from which to import.")
@@ -206,4 +275,53 @@ func (args *imageImportArgs) registerFlags(flagSet *flag.FlagSet) {
 
 	flagSet.BoolVar(&args.SysprepWindows, "sysprep_windows", false,
 		"Generalize image using Windows Sysprep. Only applicable to Windows.")
+
+	flagSet.BoolVar(&args.VerifyBoot, importer.VerifyBootFlag, false,
+		"After the image is created, boots a temporary instance from it, and waits for "+
+			"the guest environment to start. If the instance doesn't boot, or a verification "+
+			"script fails, the import fails and the image is deprecated or deleted.")
+
+	flagSet.Var((*flags.StringArrayFlag)(&args.VerifyBootScripts), importer.VerifyScriptFlag,
+		"A local script to run on the verification instance. The script fails when its "+
+			"exit code isn't zero. On Linux, the script is run as an executable, and on "+
+			"Windows, it's run with PowerShell. Can be specified multiple times.")
+
+	flagSet.StringVar(&args.VerifyBootFailureAction, importer.VerifyActionFlag, importer.VerifyBootFailureDeprecate,
+		"What to do with the image when boot verification fails: "+
+			importer.VerifyBootFailureDeprecate+" or "+importer.VerifyBootFailureDelete+".")
+
+	flagSet.DurationVar(&args.VerifyBootTimeout, "verify_boot_timeout", 10*time.Minute,
+		"Maximum time to wait for the verification instance to boot, and run its scripts.")
+
+	flagSet.BoolVar(&args.DryRun, importer.DryRunFlag, false,
+		"Validates the import and reports the resources it would use, "+
+			"without creating the image.")
+
+	flagSet.BoolVar(&args.DryRunInspectDisk, importer.DryRunInspectFlag, false,
+		"During a dry run, inflates the source to a temporary disk to detect "+
+			"its operating system. The disk is deleted when the dry run finishes. "+
+			"Local files are inspected in place, without creating a disk.")
+
+	flagSet.Var((*flags.TrimmedString)(&args.ProgressOutput), "progress_output",
+		"Where to write progress events as JSON lines: "+logging.ProgressOutputStdout+", or the path of "+
+			"a local file that they're appended to. Each event has the phase of the import, such as "+
+			"inflate or translate, and the percent done and ETA when they're known.")
+
+	flagSet.Var((*flags.LowerTrimmedString)(&args.TraceExporter), "trace_exporter",
+		"Export OpenTelemetry spans of the import's workflows, steps and Compute API calls: "+
+			"none, stdout or file. Defaults to none.")
+
+	flagSet.Var((*flags.TrimmedString)(&args.TraceFile), "trace_file",
+		"The local file that spans are appended to as JSON when -trace_exporter=file.")
+
+	flagSet.Var((*flags.TrimmedString)(&args.Manifest), manifestFlag,
+		"A local YAML or CSV file that lists images to import. Each image has an image_name, "+
+			"a source_file or source_image, and optionally a family, description, os, data_disk, "+
+			"and labels. Other flags apply to every image. Images that already exist are skipped.")
+
+	flagSet.IntVar(&args.MaxConcurrentImports, maxConcurrentImportsFlag, 4,
+		"When -"+manifestFlag+" is specified, the maximum number of images to import at once.")
+
+	flagSet.Var((*flags.TrimmedString)(&args.ReportFile), "report_file",
+		"When -"+manifestFlag+" is specified, a local file where the status of each image is written as JSON.")
 }
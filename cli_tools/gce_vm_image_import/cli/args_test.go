@@ -15,16 +15,23 @@
 package cli
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/image/importer"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/kms"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/mocks"
 )
 
 func Test_populateAndValidate_InitializesStarted(t *testing.T) {
@@ -164,6 +171,91 @@ func Test_populateAndValidate_SupportsDataDisk(t *testing.T) {
 	assert.True(t, parseAndPopulate(t, "-data_disk").DataDisk)
 }
 
+func Test_populateAndValidate_SupportsVerifyBoot(t *testing.T) {
+	defaults := parseAndPopulate(t)
+	assert.False(t, defaults.VerifyBoot)
+	assert.Equal(t, "deprecate", defaults.VerifyBootFailureAction)
+	assert.Equal(t, 10*time.Minute, defaults.VerifyBootTimeout)
+
+	script, err := ioutil.TempFile("", "verify*.sh")
+	assert.NoError(t, err)
+	defer os.Remove(script.Name())
+	actual := parseAndPopulate(t, "-verify_boot", "-verify_boot_script", script.Name(),
+		"-verify_boot_script", script.Name(), "-verify_boot_failure_action=delete", "-verify_boot_timeout=5m")
+	assert.True(t, actual.VerifyBoot)
+	assert.Equal(t, []string{script.Name(), script.Name()}, actual.VerifyBootScripts)
+	assert.Equal(t, "delete", actual.VerifyBootFailureAction)
+	assert.Equal(t, 5*time.Minute, actual.VerifyBootTimeout)
+}
+
+func Test_populateAndValidate_SupportsDryRun(t *testing.T) {
+	assert.False(t, parseAndPopulate(t).DryRun)
+	assert.True(t, parseAndPopulate(t, "-dry_run").DryRun)
+	assert.True(t, parseAndPopulate(t, "-dry_run", "-dry_run_inspect_disk").DryRunInspectDisk)
+}
+
+func Test_populateAndValidate_SupportsOutput(t *testing.T) {
+	assert.Equal(t, "", parseAndPopulate(t).Output)
+	assert.Equal(t, "snapshot", parseAndPopulate(t, "-output", " Snapshot ").Output)
+}
+
+func Test_populateAndValidate_SupportsProgressOutput(t *testing.T) {
+	assert.Equal(t, "", parseAndPopulate(t).ProgressOutput)
+	assert.Equal(t, "/tmp/progress.jsonl", parseAndPopulate(t, "-progress_output", " /tmp/progress.jsonl ").ProgressOutput)
+}
+
+func Test_populateAndValidate_SupportsTracing(t *testing.T) {
+	args := parseAndPopulate(t)
+	assert.Equal(t, "", args.TraceExporter)
+	assert.Equal(t, "", args.TraceFile)
+
+	args = parseAndPopulate(t, "-trace_exporter", " File ", "-trace_file", " /tmp/spans.json ")
+	assert.Equal(t, "file", args.TraceExporter)
+	assert.Equal(t, "/tmp/spans.json", args.TraceFile)
+}
+
+func Test_populateKmsKey(t *testing.T) {
+	args := addRequiredArgsAndParse(t, "-project", "project", "-kms_key", " key ",
+		"-kms_keyring", " ring ", "-kms_location", " us-west1 ")
+	validator := &mockKeyValidator{}
+	assert.NoError(t, args.populateKmsKey(nil, validator.new))
+	assert.Equal(t, "projects/project/locations/us-west1/keyRings/ring/cryptoKeys/key", args.KmsKey)
+	assert.Equal(t, []string{args.KmsKey}, validator.validated)
+
+	args = addRequiredArgsAndParse(t, "-project", "project", "-kms_key", "key")
+	assert.EqualError(t, args.populateKmsKey(nil, validator.new), `the KMS keyring and location are required `+
+		`when the KMS key "key" isn't a fully qualified name`)
+}
+
+func Test_populateKmsKey_UsesInferredProject(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockMetadata := mocks.NewMockMetadataGCEInterface(mockCtrl)
+	mockMetadata.EXPECT().OnGCE().Return(true)
+	mockMetadata.EXPECT().ProjectID().Return("inferred-project", nil)
+
+	args := addRequiredArgsAndParse(t, "-kms_key", "key", "-kms_keyring", "ring", "-kms_location", "us-west1")
+	validator := &mockKeyValidator{}
+	assert.NoError(t, args.populateKmsKey(mockMetadata, validator.new))
+	assert.Equal(t, "inferred-project", args.Project)
+	assert.Equal(t, "projects/inferred-project/locations/us-west1/keyRings/ring/cryptoKeys/key", args.KmsKey)
+	assert.Equal(t, []string{args.KmsKey}, validator.validated)
+}
+
+func Test_populateKmsKey_DoesntValidate_WhenKeyIsNotSpecified(t *testing.T) {
+	args := addRequiredArgsAndParse(t)
+	validator := &mockKeyValidator{}
+	assert.NoError(t, args.populateKmsKey(nil, validator.new))
+	assert.Empty(t, args.KmsKey)
+	assert.Empty(t, validator.validated)
+}
+
+func Test_populateKmsKey_ReturnsValidationError(t *testing.T) {
+	args := addRequiredArgsAndParse(t, "-project", "project", "-kms_key", "projects/p/locations/l/keyRings/r/cryptoKeys/k")
+	validator := &mockKeyValidator{err: errors.New("key is disabled")}
+	assert.EqualError(t, args.populateKmsKey(nil, validator.new), "key is disabled")
+}
+
 func Test_populateAndValidate_DefaultsBYOLToFalse(t *testing.T) {
 	assert.False(t, parseAndPopulate(t).BYOL)
 }
@@ -364,6 +456,26 @@ func Test_populateAndValidate_CreatesSourceObjectFromSourceFile(t *testing.T) {
 	assert.Equal(t, "gs://path/file", actual.Source.Path())
 }
 
+func Test_populateAndValidate_DoesntUseStagedSourceFileForScratchBucket(t *testing.T) {
+	args := []string{"-source_file", "/local/disk.vmdk", "-image_name=i", "-client_id=c", "-data_disk"}
+	actual, err := parseArgsFromUser(args)
+	assert.NoError(t, err)
+	gotFile := "not called"
+	err = actual.populateAndValidate(mockPopulator{
+		zone:          "us-west2-a",
+		region:        "us-west2",
+		scratchBucket: "gs://custom-bucket/",
+		gotFile:       &gotFile,
+	}, mockSourceFactory{
+		expectedFile: "/local/disk.vmdk",
+		staged:       true,
+		t:            t,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "", gotFile)
+	assert.Equal(t, "/local/disk.vmdk", actual.Source.Path())
+}
+
 func Test_populateAndValidate_FailsWhenSourceValidateFails(t *testing.T) {
 	args := []string{"-image_name=i", "-client_id=c", "-data_disk"}
 	actual, err := parseArgsFromUser(args)
@@ -421,12 +533,17 @@ type mockPopulator struct {
 	scratchBucket   string
 	storageLocation string
 	err             error
+	// If set, receives the file the scratch bucket is populated for.
+	gotFile *string
 }
 
 func (m mockPopulator) PopulateMissingParameters(project *string, client string, zone *string, region *string, scratchBucketGcsPath *string, file string, storageLocation *string) error {
 	if m.err != nil {
 		return m.err
 	}
+	if m.gotFile != nil {
+		*m.gotFile = file
+	}
 	if *project == "" {
 		*project = m.project
 	}
@@ -445,6 +562,20 @@ func (m mockPopulator) PopulateMissingParameters(project *string, client string,
 	return nil
 }
 
+type mockKeyValidator struct {
+	validated []string
+	err       error
+}
+
+func (m *mockKeyValidator) new() (kms.KeyValidator, error) {
+	return m, nil
+}
+
+func (m *mockKeyValidator) Validate(keyName string) error {
+	m.validated = append(m.validated, keyName)
+	return m.err
+}
+
 type mockSource struct {
 	sourcePath string
 }
@@ -453,9 +584,18 @@ func (m mockSource) Path() string {
 	return m.sourcePath
 }
 
+type mockStagedSource struct {
+	mockSource
+}
+
+func (m mockStagedSource) Stage(ctx context.Context, gcsDir string, logger logging.Logger) (importer.Source, func(), error) {
+	return mockSource{sourcePath: gcsDir + "/source"}, func() {}, nil
+}
+
 type mockSourceFactory struct {
 	err                         error
 	expectedFile, expectedImage string
+	staged                      bool
 	t                           *testing.T
 }
 
@@ -463,6 +603,9 @@ func (m mockSourceFactory) Init(sourceFile, sourceImage string) (importer.Source
 	// Skip parameter verification unless they were provided when mock was setup.
 	if m.expectedFile != "" {
 		assert.Equal(m.t, m.expectedFile, sourceFile)
+		if m.staged {
+			return mockStagedSource{mockSource{sourcePath: sourceFile}}, m.err
+		}
 		return mockSource{sourcePath: sourceFile}, m.err
 
 	}
@@ -0,0 +1,340 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/image/importer"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/flags"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/param"
+	daisyCompute "github.com/GoogleCloudPlatform/compute-image-tools/daisy/compute"
+)
+
+// Statuses of an image in the batch report.
+const (
+	batchSucceeded = "succeeded"
+	batchFailed    = "failed"
+	batchSkipped   = "skipped"
+)
+
+// manifestItem is an image in a batch manifest. Empty fields use the
+// values of the command line arguments; labels are merged with them.
+type manifestItem struct {
+	ImageName   string            `yaml:"image_name"`
+	SourceFile  string            `yaml:"source_file"`
+	SourceImage string            `yaml:"source_image"`
+	Family      string            `yaml:"family"`
+	Description string            `yaml:"description"`
+	OS          string            `yaml:"os"`
+	DataDisk    bool              `yaml:"data_disk"`
+	Labels      map[string]string `yaml:"labels"`
+}
+
+// batchResult is the outcome of importing a manifestItem.
+type batchResult struct {
+	ImageName   string `json:"image_name"`
+	Source      string `json:"source"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+	DurationSec int64  `json:"duration_sec"`
+}
+
+// batchImporter imports the images in a manifest, with at most
+// MaxConcurrentImports running at once.
+type batchImporter struct {
+	computeClient daisyCompute.Client
+	populator     param.Populator
+	sourceFactory importer.SourceFactory
+	importFunc    func(ctx context.Context, args imageImportArgs, logger logging.ToolLogger) error
+	logger        logging.ToolLogger
+}
+
+// runBatch imports the images in the manifest, and reports the status of each.
+// Images that already exist are skipped, which allows a failed batch to be
+// resumed by running it again. An error is returned when an image fails.
+// The percent of images that finished is reported as progress.
+func runBatch(ctx context.Context, baseArgs imageImportArgs, b *batchImporter) error {
+	if err := b.populateSharedArgs(&baseArgs); err != nil {
+		logFailure(baseArgs, err)
+		return err
+	}
+	items, err := readManifest(baseArgs.Manifest)
+	if err != nil {
+		logFailure(baseArgs, err)
+		return err
+	}
+
+	results := make([]batchResult, len(items))
+	sem := make(chan struct{}, baseArgs.MaxConcurrentImports)
+	var wg sync.WaitGroup
+	var finishedMx sync.Mutex
+	finished := 0
+	b.logger.Progress(logging.ProgressEvent{Phase: logging.PhaseImport})
+	for i, item := range items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, item manifestItem) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i] = b.importItem(ctx, baseArgs, i, item)
+			finishedMx.Lock()
+			finished++
+			b.logger.Progress(logging.ProgressEvent{
+				Phase:   logging.PhaseImport,
+				Percent: math.Round(float64(finished)*1000/float64(len(items))) / 10,
+			})
+			finishedMx.Unlock()
+		}(i, item)
+	}
+	wg.Wait()
+	return b.report(results, baseArgs.ReportFile)
+}
+
+// populateSharedArgs populates the arguments that are shared by all imports,
+// so that they use the same project, zone, and scratch bucket.
+func (b *batchImporter) populateSharedArgs(args *imageImportArgs) error {
+	if args.ImageName != "" || args.SourceFile != "" || args.SourceImage != "" {
+		return fmt.Errorf("-%s, -source_file, and -source_image can't be used with -%s",
+			importer.ImageFlag, manifestFlag)
+	}
+	if args.MaxConcurrentImports < 1 {
+		return fmt.Errorf("-%s must be at least 1", maxConcurrentImportsFlag)
+	}
+	if args.ClientID == "" {
+		return fmt.Errorf("%s has to be specified", importer.ClientFlag)
+	}
+	return b.populator.PopulateMissingParameters(&args.Project, args.ClientID, &args.Zone, &args.Region,
+		&args.ScratchBucketGcsPath, "", &args.StorageLocation)
+}
+
+func (b *batchImporter) importItem(ctx context.Context, baseArgs imageImportArgs, index int, item manifestItem) batchResult {
+	args := item.applyTo(baseArgs)
+	if baseArgs.ExecutionID != "" {
+		args.ExecutionID = fmt.Sprintf("%s-%d", baseArgs.ExecutionID, index)
+	}
+	result := batchResult{ImageName: item.ImageName, Source: item.SourceFile + item.SourceImage}
+	if b.outputExists(args) {
+		b.logger.User(fmt.Sprintf("Skipping %s, since it already exists.", args.ImageName))
+		result.Status = batchSkipped
+		return result
+	}
+
+	b.logger.User(fmt.Sprintf("Importing %s from %s.", args.ImageName, result.Source))
+	start := time.Now()
+	err := args.populateAndValidate(b.populator, b.sourceFactory)
+	if err != nil {
+		logFailure(args, err)
+	} else {
+		err = b.importFunc(ctx, args, logging.NewToolLogger(fmt.Sprintf("[import-%s]", args.ImageName)))
+	}
+	result.DurationSec = int64(time.Since(start).Seconds())
+	if err != nil {
+		b.logger.User(fmt.Sprintf("Failed to import %s: %v", args.ImageName, err))
+		result.Status, result.Error = batchFailed, err.Error()
+	} else {
+		b.logger.User(fmt.Sprintf("Imported %s.", args.ImageName))
+		result.Status = batchSucceeded
+	}
+	return result
+}
+
+// outputExists returns whether the image, disk, or snapshot that
+// the import creates already exists.
+func (b *batchImporter) outputExists(args imageImportArgs) bool {
+	var err error
+	switch args.Output {
+	case importer.OutputDisk:
+		_, err = b.computeClient.GetDisk(args.Project, args.Zone, args.ImageName)
+	case importer.OutputSnapshot:
+		_, err = b.computeClient.GetSnapshot(args.Project, args.ImageName)
+	default:
+		_, err = b.computeClient.GetImage(args.Project, args.ImageName)
+	}
+	return err == nil
+}
+
+// report writes a summary of the results to the user, and to reportFile
+// as JSON when it's specified.
+func (b *batchImporter) report(results []batchResult, reportFile string) error {
+	counts := map[string]int{}
+	for _, result := range results {
+		counts[result.Status]++
+		line := fmt.Sprintf("  %s: %s", result.ImageName, result.Status)
+		if result.Error != "" {
+			line += ": " + result.Error
+		}
+		b.logger.User(line)
+	}
+	b.logger.User(fmt.Sprintf("Batch import finished: %d succeeded, %d failed, %d skipped.",
+		counts[batchSucceeded], counts[batchFailed], counts[batchSkipped]))
+
+	if reportFile != "" {
+		content, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(reportFile, content, 0644); err != nil {
+			return fmt.Errorf("failed to write report %s: %v", reportFile, err)
+		}
+	}
+	if counts[batchFailed] > 0 {
+		return fmt.Errorf("%d of %d images failed to import", counts[batchFailed], len(results))
+	}
+	return nil
+}
+
+// applyTo returns a copy of args that imports this item.
+func (item manifestItem) applyTo(args imageImportArgs) imageImportArgs {
+	args.ImageName = item.ImageName
+	args.SourceFile = item.SourceFile
+	args.SourceImage = item.SourceImage
+	if item.Family != "" {
+		args.Family = item.Family
+	}
+	if item.Description != "" {
+		args.Description = item.Description
+	}
+	if item.OS != "" {
+		args.OS = item.OS
+	}
+	args.DataDisk = args.DataDisk || item.DataDisk
+
+	labels := map[string]string{}
+	for k, v := range args.Labels {
+		labels[k] = v
+	}
+	for k, v := range item.Labels {
+		labels[k] = v
+	}
+	args.Labels = labels
+	return args
+}
+
+// manifestColumns are the columns of a CSV manifest. Labels are
+// formatted as key1=value1,key2=value2.
+var manifestColumns = []string{
+	"image_name", "source_file", "source_image", "family", "description", "os", "data_disk", "labels"}
+
+// readManifest reads a YAML manifest, which is a list of manifestItem, or a
+// CSV manifest, whose first row contains the names of its columns.
+func readManifest(path string) ([]manifestItem, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var items []manifestItem
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(content, &items)
+	case ".csv":
+		items, err = parseCSVManifest(content)
+	default:
+		return nil, fmt.Errorf("manifest %s must be a .yaml, .yml, or .csv file", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %v", path, err)
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("manifest %s doesn't contain any images", path)
+	}
+	names := map[string]bool{}
+	for i, item := range items {
+		if item.ImageName == "" {
+			return nil, fmt.Errorf("image %d in manifest %s doesn't have an image_name", i+1, path)
+		}
+		if names[item.ImageName] {
+			return nil, fmt.Errorf("manifest %s contains image %s more than once", path, item.ImageName)
+		}
+		names[item.ImageName] = true
+	}
+	return items, nil
+}
+
+func parseCSVManifest(content []byte) ([]manifestItem, error) {
+	rows, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	header := rows[0]
+	for _, column := range header {
+		if !contains(manifestColumns, column) {
+			return nil, fmt.Errorf("unknown column %q. Columns must be one of: %s",
+				column, strings.Join(manifestColumns, ", "))
+		}
+	}
+	var items []manifestItem
+	for _, row := range rows[1:] {
+		var item manifestItem
+		for i, value := range row {
+			value = strings.TrimSpace(value)
+			switch header[i] {
+			case "image_name":
+				item.ImageName = value
+			case "source_file":
+				item.SourceFile = value
+			case "source_image":
+				item.SourceImage = value
+			case "family":
+				item.Family = value
+			case "description":
+				item.Description = value
+			case "os":
+				item.OS = value
+			case "data_disk":
+				if value != "" {
+					if item.DataDisk, err = strconv.ParseBool(value); err != nil {
+						return nil, fmt.Errorf("data_disk of %s must be true or false", item.ImageName)
+					}
+				}
+			case "labels":
+				if value != "" {
+					var labels flags.KeyValueString
+					if err := labels.Set(value); err != nil {
+						return nil, fmt.Errorf("labels of %s: %v", item.ImageName, err)
+					}
+					item.Labels = labels
+				}
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/mocks"
+)
+
+func Test_readManifest_YAML(t *testing.T) {
+	manifest := writeManifest(t, "*.yaml", `
+- image_name: web
+  source_file: gs://bucket/web.vmdk
+  os: centos-7
+  labels:
+    app: web
+- image_name: data
+  source_image: global/images/data
+  data_disk: true
+`)
+	defer os.Remove(manifest)
+
+	items, err := readManifest(manifest)
+	assert.NoError(t, err)
+	assert.Equal(t, []manifestItem{
+		{ImageName: "web", SourceFile: "gs://bucket/web.vmdk", OS: "centos-7", Labels: map[string]string{"app": "web"}},
+		{ImageName: "data", SourceImage: "global/images/data", DataDisk: true},
+	}, items)
+}
+
+func Test_readManifest_CSV(t *testing.T) {
+	manifest := writeManifest(t, "*.csv", `image_name,source_file,family,data_disk,labels
+web,gs://bucket/web.vmdk,web-images,,"app=web,tier=front"
+data, gs://bucket/data.vmdk ,,true,
+`)
+	defer os.Remove(manifest)
+
+	items, err := readManifest(manifest)
+	assert.NoError(t, err)
+	assert.Equal(t, []manifestItem{
+		{ImageName: "web", SourceFile: "gs://bucket/web.vmdk", Family: "web-images",
+			Labels: map[string]string{"app": "web", "tier": "front"}},
+		{ImageName: "data", SourceFile: "gs://bucket/data.vmdk", DataDisk: true},
+	}, items)
+}
+
+func Test_readManifest_Errors(t *testing.T) {
+	for _, tt := range []struct {
+		name, pattern, content, expectedError string
+	}{
+		{"unknown extension", "*.txt", "", "must be a .yaml, .yml, or .csv file"},
+		{"unknown field", "*.yaml", "- image_name: a\n  zone: us-west1-a\n", "field zone not found"},
+		{"unknown column", "*.csv", "image_name,zone\na,us-west1-a\n", `unknown column "zone"`},
+		{"invalid data_disk", "*.csv", "image_name,data_disk\na,maybe\n", "data_disk of a must be true or false"},
+		{"empty", "*.yml", "[]", "doesn't contain any images"},
+		{"missing name", "*.yaml", "- source_file: gs://bucket/a.vmdk\n", "image 1 in manifest"},
+		{"duplicate name", "*.csv", "image_name\na\na\n", "contains image a more than once"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := writeManifest(t, tt.pattern, tt.content)
+			defer os.Remove(manifest)
+
+			_, err := readManifest(manifest)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.expectedError)
+			}
+		})
+	}
+}
+
+func Test_runBatch_ImportsSkipsAndReportsEachImage(t *testing.T) {
+	manifest := writeManifest(t, "*.yaml", `
+- image_name: existing
+  source_file: gs://bucket/existing.vmdk
+- image_name: web
+  source_file: gs://bucket/web.vmdk
+  family: web-images
+  labels:
+    app: web
+- image_name: broken
+  source_image: global/images/broken
+`)
+	defer os.Remove(manifest)
+	report, err := ioutil.TempFile("", "report*.json")
+	assert.NoError(t, err)
+	assert.NoError(t, report.Close())
+	defer os.Remove(report.Name())
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockComputeClient := mocks.NewMockClient(mockCtrl)
+	mockComputeClient.EXPECT().GetImage("project", "existing").Return(nil, nil)
+	mockComputeClient.EXPECT().GetImage("project", "web").Return(nil, errors.New("not found"))
+	mockComputeClient.EXPECT().GetImage("project", "broken").Return(nil, errors.New("not found"))
+
+	var mu sync.Mutex
+	imported := map[string]imageImportArgs{}
+	baseArgs := imageImportArgs{
+		ClientID:             "test",
+		Manifest:             manifest,
+		MaxConcurrentImports: 2,
+		ReportFile:           report.Name(),
+	}
+	baseArgs.Family = "default-family"
+	baseArgs.Labels = map[string]string{"team": "migration"}
+	logger := logging.NewToolLogger(t.Name())
+	var events bytes.Buffer
+	logger.SetProgressOutput(&events)
+	err = runBatch(context.Background(), baseArgs, &batchImporter{
+		computeClient: mockComputeClient,
+		populator:     mockPopulator{project: "project", zone: "us-west2-a", scratchBucket: "gs://scratch"},
+		sourceFactory: mockSourceFactory{},
+		importFunc: func(ctx context.Context, args imageImportArgs, logger logging.ToolLogger) error {
+			mu.Lock()
+			defer mu.Unlock()
+			imported[args.ImageName] = args
+			if args.ImageName == "broken" {
+				return errors.New("translation failed")
+			}
+			return nil
+		},
+		logger: logger,
+	})
+	assert.EqualError(t, err, "1 of 3 images failed to import")
+	assert.Contains(t, events.String(), `"phase":"import","percent":33.3}`)
+	assert.Contains(t, events.String(), `"phase":"import","percent":100}`)
+
+	assert.Len(t, imported, 2)
+	web := imported["web"]
+	assert.Equal(t, "gs://bucket/web.vmdk", web.SourceFile)
+	assert.Equal(t, "web-images", web.Family)
+	assert.Equal(t, map[string]string{"team": "migration", "app": "web"}, web.Labels)
+	assert.True(t, strings.HasPrefix(web.ScratchBucketGcsPath, "gs://scratch/gce-image-import-"))
+	assert.Equal(t, "default-family", imported["broken"].Family)
+	assert.NotEqual(t, web.ExecutionID, imported["broken"].ExecutionID)
+	assert.Equal(t, map[string]string{"team": "migration"}, baseArgs.Labels)
+
+	content, err := ioutil.ReadFile(report.Name())
+	assert.NoError(t, err)
+	var results []batchResult
+	assert.NoError(t, json.Unmarshal(content, &results))
+	assert.Len(t, results, 3)
+	assert.Equal(t, batchResult{ImageName: "existing", Source: "gs://bucket/existing.vmdk", Status: batchSkipped}, results[0])
+	assert.Equal(t, "web", results[1].ImageName)
+	assert.Equal(t, batchSucceeded, results[1].Status)
+	assert.Equal(t, "broken", results[2].ImageName)
+	assert.Equal(t, batchFailed, results[2].Status)
+	assert.Equal(t, "translation failed", results[2].Error)
+}
+
+func Test_outputExists_ChecksTheOutputResource(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockComputeClient := mocks.NewMockClient(mockCtrl)
+	mockComputeClient.EXPECT().GetDisk("project", "us-west1-a", "web").Return(nil, nil)
+	mockComputeClient.EXPECT().GetSnapshot("project", "data").Return(nil, errors.New("not found"))
+	b := &batchImporter{computeClient: mockComputeClient}
+
+	args := imageImportArgs{}
+	args.Project, args.Zone = "project", "us-west1-a"
+	args.ImageName, args.Output = "web", "disk"
+	assert.True(t, b.outputExists(args))
+	args.ImageName, args.Output = "data", "snapshot"
+	assert.False(t, b.outputExists(args))
+}
+
+func Test_runBatch_FailsWhenImageNameIsSpecified(t *testing.T) {
+	baseArgs := imageImportArgs{ClientID: "test", Manifest: "images.yaml", MaxConcurrentImports: 1}
+	baseArgs.ImageName = "image"
+	err := runBatch(context.Background(), baseArgs, &batchImporter{logger: logging.NewToolLogger(t.Name())})
+	assert.EqualError(t, err, "-image_name, -source_file, and -source_image can't be used with -manifest")
+}
+
+func Test_runBatch_FailsWhenConcurrencyIsInvalid(t *testing.T) {
+	baseArgs := imageImportArgs{ClientID: "test", Manifest: "images.yaml"}
+	err := runBatch(context.Background(), baseArgs, &batchImporter{logger: logging.NewToolLogger(t.Name())})
+	assert.EqualError(t, err, "-max_concurrent_imports must be at least 1")
+}
+
+func writeManifest(t *testing.T, pattern, content string) string {
+	f, err := ioutil.TempFile("", pattern)
+	assert.NoError(t, err)
+	_, err = f.WriteString(content)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	return f.Name()
+}
@@ -21,12 +21,16 @@ import (
 
 	"google.golang.org/api/option"
 
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/domain"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/image/importer"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/compute"
+	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/kms"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/logging/service"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/param"
 	"github.com/GoogleCloudPlatform/compute-image-tools/cli_tools/common/utils/storage"
+	"github.com/GoogleCloudPlatform/compute-image-tools/daisy"
+	daisyCompute "github.com/GoogleCloudPlatform/compute-image-tools/daisy/compute"
 )
 
 // Main starts an image import.
@@ -47,6 +51,22 @@ func Main(args []string, toolLogger logging.ToolLogger, workflowDir string) erro
 		return err
 	}
 	importArgs.WorkflowDir = workflowDir
+	progressOutput, err := logging.OpenProgressOutput(importArgs.ProgressOutput)
+	if err != nil {
+		logFailure(importArgs, err)
+		return err
+	}
+	if progressOutput != nil {
+		defer progressOutput.Close()
+		toolLogger.SetProgressOutput(progressOutput)
+	}
+	tracerProvider, shutdownTracing, err := daisy.NewTracerProvider(importArgs.TraceExporter, importArgs.TraceFile)
+	if err != nil {
+		logFailure(importArgs, err)
+		return err
+	}
+	defer shutdownTracing(ctx)
+	importArgs.TracerProvider = tracerProvider
 
 	// 2. Setup dependencies.
 	storageClient, err := storage.NewStorageClient(
@@ -57,19 +77,40 @@ func Main(args []string, toolLogger logging.ToolLogger, workflowDir string) erro
 	}
 
 	computeClient, err := param.CreateComputeClient(
-		&ctx, importArgs.Oauth, importArgs.ComputeEndpoint)
+		&ctx, importArgs.Oauth, importArgs.ComputeEndpoint, importArgs.TracerProvider)
 	if err != nil {
 		logFailure(importArgs, err)
 		return err
 	}
 	metadataGCE := &compute.MetadataGCE{}
+	if err := importArgs.populateKmsKey(metadataGCE, func() (kms.KeyValidator, error) {
+		return kms.NewKeyValidator(ctx, importArgs.Oauth)
+	}); err != nil {
+		logFailure(importArgs, err)
+		return err
+	}
+	scratchBucketCreator := storage.NewScratchBucketCreator(ctx, storageClient)
+	scratchBucketCreator.KmsKey = importArgs.KmsKey
 	paramPopulator := param.NewPopulator(
 		metadataGCE,
 		storageClient,
 		storage.NewResourceLocationRetriever(metadataGCE, computeClient),
-		storage.NewScratchBucketCreator(ctx, storageClient),
+		scratchBucketCreator,
 	)
 
+	// Imports from a manifest use the remaining arguments as defaults.
+	if importArgs.Manifest != "" {
+		return runBatch(ctx, importArgs, &batchImporter{
+			computeClient: computeClient,
+			populator:     paramPopulator,
+			sourceFactory: importer.NewSourceFactory(storageClient),
+			importFunc: func(ctx context.Context, args imageImportArgs, logger logging.ToolLogger) error {
+				return runImport(ctx, args, computeClient, storageClient, logger)
+			},
+			logger: toolLogger,
+		})
+	}
+
 	// 3. Populate missing arguments.
 	err = importArgs.populateAndValidate(paramPopulator, importer.NewSourceFactory(storageClient))
 	if err != nil {
@@ -77,6 +118,25 @@ func Main(args []string, toolLogger logging.ToolLogger, workflowDir string) erro
 		return err
 	}
 
+	return runImport(ctx, importArgs, computeClient, storageClient, toolLogger)
+}
+
+// runImport stages the source, when required, and runs the import. The
+// import is logged to the server. Dry runs don't stage the source.
+func runImport(ctx context.Context, importArgs imageImportArgs, computeClient daisyCompute.Client,
+	storageClient domain.StorageClientInterface, toolLogger logging.ToolLogger) (err error) {
+	// Upload a source file from outside of GCS to the scratch bucket.
+	if staged, ok := importArgs.Source.(importer.StagedSource); ok && !importArgs.DryRun {
+		toolLogger.User(fmt.Sprintf("Uploading %s to the scratch bucket.", staged.Path()))
+		var cleanup func()
+		importArgs.Source, cleanup, err = staged.Stage(ctx, importArgs.ScratchBucketGcsPath, toolLogger)
+		if err != nil {
+			logFailure(importArgs, err)
+			return err
+		}
+		defer cleanup()
+	}
+
 	// Run the import.
 	importRunner, err := importer.NewImporter(importArgs.ImageImportRequest, computeClient, storageClient, toolLogger)
 	if err != nil {
@@ -152,6 +212,10 @@ func initLoggingParams(args imageImportArgs) service.InputParams {
 			Family:                args.Family,
 			Description:           args.Description,
 			NoExternalIP:          args.NoExternalIP,
+			HasKmsKey:             args.KmsFlags.Key != "",
+			HasKmsKeyring:         args.KmsFlags.Keyring != "",
+			HasKmsLocation:        args.KmsFlags.Location != "",
+			HasKmsProject:         args.KmsFlags.Project != "",
 			StorageLocation:       args.StorageLocation,
 			ComputeServiceAccount: args.ComputeServiceAccount,
 		},
@@ -5,6 +5,7 @@
 package mocks
 
 import (
+	io "io"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -158,6 +159,18 @@ func (mr *MockToolLoggerMockRecorder) NewLogger(userPrefix interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewLogger", reflect.TypeOf((*MockToolLogger)(nil).NewLogger), userPrefix)
 }
 
+// SetProgressOutput mocks base method
+func (m *MockToolLogger) SetProgressOutput(w io.Writer) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetProgressOutput", w)
+}
+
+// SetProgressOutput indicates an expected call of SetProgressOutput
+func (mr *MockToolLoggerMockRecorder) SetProgressOutput(w interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetProgressOutput", reflect.TypeOf((*MockToolLogger)(nil).SetProgressOutput), w)
+}
+
 // User mocks base method
 func (m *MockToolLogger) User(message string) {
 	m.ctrl.T.Helper()
@@ -206,6 +219,18 @@ func (mr *MockToolLoggerMockRecorder) Metric(metric interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Metric", reflect.TypeOf((*MockToolLogger)(nil).Metric), metric)
 }
 
+// Progress mocks base method
+func (m *MockToolLogger) Progress(event logging.ProgressEvent) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Progress", event)
+}
+
+// Progress indicates an expected call of Progress
+func (mr *MockToolLoggerMockRecorder) Progress(event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Progress", reflect.TypeOf((*MockToolLogger)(nil).Progress), event)
+}
+
 // ReadOutputInfo mocks base method
 func (m *MockToolLogger) ReadOutputInfo() *pb.OutputInfo {
 	m.ctrl.T.Helper()
@@ -16,6 +16,7 @@ package import_test
 
 import (
 	"context"
+	"io"
 	"strings"
 	"sync"
 	"testing"
@@ -176,3 +177,7 @@ func (b *bufferedLogger) Metric(metric *pb.OutputInfo) {
 func (b *bufferedLogger) NewLogger(userPrefix string) logging.Logger {
 	panic("not expected for this test")
 }
+
+func (b *bufferedLogger) SetProgressOutput(w io.Writer) {}
+
+func (b *bufferedLogger) Progress(event logging.ProgressEvent) {}
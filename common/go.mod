@@ -3,9 +3,9 @@ module github.com/GoogleCloudPlatform/compute-image-tools/common
 go 1.13
 
 require (
-	cloud.google.com/go/storage v1.10.0
+	cloud.google.com/go/storage v1.22.1
 	github.com/GoogleCloudPlatform/compute-image-tools/daisy v0.0.0
-	google.golang.org/api v0.31.0
+	google.golang.org/api v0.90.0
 )
 
 replace github.com/GoogleCloudPlatform/compute-image-tools/daisy => ../daisy
@@ -47,6 +47,10 @@ var (
 	gcsLogsDisabled    = flag.Bool("disable_gcs_logging", false, "do not stream logs to GCS")
 	cloudLogsDisabled  = flag.Bool("disable_cloud_logging", false, "do not stream logs to Cloud Logging")
 	stdoutLogsDisabled = flag.Bool("disable_stdout_logging", false, "do not display individual workflow logs on stdout")
+	traceExporter      = flag.String("trace_exporter", "none", "export OpenTelemetry spans of workflows, steps and Compute API calls: none, stdout or file")
+	traceFile          = flag.String("trace_file", "", "file spans are appended to as JSON when -trace_exporter=file")
+	serialLogsDir      = flag.String("serial_logs_dir", "", "local directory instance serial port output is written to as the workflow runs")
+	follow             = flag.String("follow", "", "name of an instance whose serial console output is printed to stdout as the workflow runs")
 )
 
 const (
@@ -241,6 +245,12 @@ func main() {
 
 	ctx := context.Background()
 
+	tp, shutdownTracing, err := daisy.NewTracerProvider(*traceExporter, *traceFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer shutdownTracing(ctx)
+
 	var ws []*daisy.Workflow
 	varMap := populateVars(*variables)
 
@@ -249,6 +259,9 @@ func main() {
 		if err != nil {
 			log.Fatalf("error parsing workflow %q: %v", path, err)
 		}
+		w.TracerProvider = tp
+		w.SerialLogsDir = *serialLogsDir
+		w.FollowInstance = *follow
 		ws = append(ws, w)
 	}
 
@@ -304,6 +317,7 @@ func main() {
 				fmt.Fprintln(os.Stderr, " ", err)
 				continue
 			default:
+				shutdownTracing(ctx)
 				os.Exit(1)
 			}
 		}
@@ -23,6 +23,7 @@ import (
 	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/oauth2"
 	computeAlpha "google.golang.org/api/compute/v0.alpha"
 	computeBeta "google.golang.org/api/compute/v0.beta"
@@ -161,6 +162,10 @@ func (o OrderBy) listCallOptionApply(i interface{}) interface{} {
 		return c.OrderBy(string(o))
 	case *compute.SubnetworksAggregatedListCall:
 		return c.OrderBy(string(o))
+	case *compute.SnapshotsListCall:
+		return c.OrderBy(string(o))
+	case *compute.ForwardingRulesListCall:
+		return c.OrderBy(string(o))
 	}
 	return i
 }
@@ -200,6 +205,10 @@ func (o Filter) listCallOptionApply(i interface{}) interface{} {
 		return c.Filter(string(o))
 	case *compute.SubnetworksAggregatedListCall:
 		return c.Filter(string(o))
+	case *compute.SnapshotsListCall:
+		return c.Filter(string(o))
+	case *compute.ForwardingRulesListCall:
+		return c.Filter(string(o))
 	}
 	return i
 }
@@ -226,6 +235,13 @@ func shouldRetryWithWait(tripper http.RoundTripper, err error, multiplier int) b
 		return false
 	}
 	tkValid := true
+	for {
+		u, ok := tripper.(interface{ unwrap() http.RoundTripper })
+		if !ok {
+			break
+		}
+		tripper = u.unwrap()
+	}
 	trans, ok := tripper.(*oauth2.Transport)
 	if ok {
 		if tk, err := trans.Source.Token(); err == nil {
@@ -267,8 +283,31 @@ func shouldRetryWithWait(tripper http.RoundTripper, err error, multiplier int) b
 	return true
 }
 
+// ClientConfig configures optional behavior of a client.
+type ClientConfig struct {
+	// Rate limits API calls if set. Can be shared between clients.
+	RateLimiter *RateLimiter
+	// Creates a span for each API call if set.
+	TracerProvider trace.TracerProvider
+	// Returns the context of the span API call spans are children of, since
+	// client methods don't take a context. Optional.
+	TraceParent func() context.Context
+}
+
 // NewClient creates a new Google Cloud Compute client.
 func NewClient(ctx context.Context, opts ...option.ClientOption) (Client, error) {
+	return NewClientWithConfig(ctx, ClientConfig{}, opts...)
+}
+
+// NewClientWithRateLimiter creates a new Google Cloud Compute client whose
+// API calls are rate limited by rl. rl can be shared between clients.
+func NewClientWithRateLimiter(ctx context.Context, rl *RateLimiter, opts ...option.ClientOption) (Client, error) {
+	return NewClientWithConfig(ctx, ClientConfig{RateLimiter: rl}, opts...)
+}
+
+// NewClientWithConfig creates a new Google Cloud Compute client configured
+// by cfg.
+func NewClientWithConfig(ctx context.Context, cfg ClientConfig, opts ...option.ClientOption) (Client, error) {
 	// Set these scopes to be align with compute.NewService
 	o := []option.ClientOption{
 		option.WithScopes(
@@ -285,6 +324,18 @@ func NewClient(ctx context.Context, opts ...option.ClientOption) (Client, error)
 	if err != nil {
 		return nil, fmt.Errorf("error creating HTTP API client: %v", err)
 	}
+	if cfg.RateLimiter != nil {
+		hc = cfg.RateLimiter.wrap(hc)
+	}
+	if cfg.TracerProvider != nil {
+		base := hc.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		traced := *hc
+		traced.Transport = &tracedTransport{base: base, tracer: cfg.TracerProvider.Tracer(tracerName), parent: cfg.TraceParent}
+		hc = &traced
+	}
 	rawService, err := compute.New(hc)
 	if err != nil {
 		return nil, fmt.Errorf("compute client: %v", err)
@@ -0,0 +1,274 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package compute
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MethodClass groups Compute API methods which share a rate limit.
+type MethodClass string
+
+const (
+	// ReadMethods are methods which don't modify resources, such as Get,
+	// List and GetSerialPortOutput.
+	ReadMethods MethodClass = "read"
+	// MutateMethods are methods which create, modify or delete resources.
+	MutateMethods MethodClass = "mutate"
+	// OperationWaitMethods are methods which wait on or poll operations.
+	OperationWaitMethods MethodClass = "operation-wait"
+)
+
+var methodClasses = []MethodClass{ReadMethods, MutateMethods, OperationWaitMethods}
+
+const (
+	// minRateFactor bounds how far adaptive backoff can lower a rate, as a
+	// fraction of the configured rate.
+	minRateFactor = 1.0 / 16
+	// recoveryFactor is the fraction of the configured rate regained after
+	// each call that isn't rejected by the API.
+	recoveryFactor = 0.05
+)
+
+// RateLimit configures a token bucket.
+type RateLimit struct {
+	// Requests per second, zero or less means no limit.
+	QPS float64 `json:",omitempty"`
+	// Maximum number of requests sent at once, defaults to QPS rounded up.
+	Burst int `json:",omitempty"`
+}
+
+// RateLimits configures client side rate limits of Compute API calls.
+// Unset method classes are not limited.
+type RateLimits struct {
+	Read          *RateLimit `json:",omitempty"`
+	Mutate        *RateLimit `json:",omitempty"`
+	OperationWait *RateLimit `json:",omitempty"`
+}
+
+func (rls *RateLimits) get(mc MethodClass) *RateLimit {
+	switch mc {
+	case ReadMethods:
+		return rls.Read
+	case MutateMethods:
+		return rls.Mutate
+	case OperationWaitMethods:
+		return rls.OperationWait
+	}
+	return nil
+}
+
+// RateLimiterStats are metrics of a rate limited method class.
+type RateLimiterStats struct {
+	// Number of calls made.
+	Calls int64
+	// Number of calls delayed by the client side limit.
+	Throttled int64
+	// Total time calls were delayed for.
+	Delay time.Duration
+	// Number of calls rejected by the API for exceeding the rate limit.
+	Rejected int64
+	// Current rate in requests per second, lower than the configured rate
+	// while backing off from rejected calls.
+	CurrentQPS float64
+}
+
+// RateLimiter rate limits Compute API calls with a token bucket per method
+// class. The rate of a class is lowered whenever the API rejects a call for
+// exceeding the rate limit and regained gradually as calls succeed.
+// A RateLimiter is safe for concurrent use and is typically shared by all
+// clients of a workflow and its sub-workflows.
+type RateLimiter struct {
+	buckets map[MethodClass]*tokenBucket
+}
+
+// NewRateLimiter creates a RateLimiter from rls.
+func NewRateLimiter(rls RateLimits) *RateLimiter {
+	rl := &RateLimiter{buckets: map[MethodClass]*tokenBucket{}}
+	for _, mc := range methodClasses {
+		if l := rls.get(mc); l != nil && l.QPS > 0 {
+			rl.buckets[mc] = newTokenBucket(l.QPS, l.Burst)
+		}
+	}
+	return rl
+}
+
+// Stats returns metrics of each rate limited method class.
+func (rl *RateLimiter) Stats() map[MethodClass]RateLimiterStats {
+	stats := map[MethodClass]RateLimiterStats{}
+	for mc, b := range rl.buckets {
+		stats[mc] = b.stats()
+	}
+	return stats
+}
+
+// wrap returns a copy of hc which rate limits its requests.
+func (rl *RateLimiter) wrap(hc *http.Client) *http.Client {
+	base := hc.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	wrapped := *hc
+	wrapped.Transport = &rateLimitedTransport{base: base, rl: rl}
+	return &wrapped
+}
+
+// classify returns the method class of a Compute API request.
+func classify(req *http.Request) MethodClass {
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	if strings.Contains(path, "/operations/") && (req.Method == http.MethodGet || strings.HasSuffix(path, "/wait")) {
+		return OperationWaitMethods
+	}
+	if req.Method == http.MethodGet {
+		return ReadMethods
+	}
+	return MutateMethods
+}
+
+type rateLimitedTransport struct {
+	base http.RoundTripper
+	rl   *RateLimiter
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	b, ok := t.rl.buckets[classify(req)]
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+	if err := b.wait(req.Context()); err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if isRateLimitResponse(resp) {
+		b.backoff()
+	} else {
+		b.recover()
+	}
+	return resp, nil
+}
+
+func (t *rateLimitedTransport) unwrap() http.RoundTripper {
+	return t.base
+}
+
+// isRateLimitResponse returns true if the API rejected the request for
+// exceeding a rate limit. Rate limit quota errors are reported as 403 with a
+// rateLimitExceeded reason, so the body of 403 responses is inspected and
+// replaced for the caller.
+func isRateLimitResponse(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		return err == nil && bytes.Contains(body, []byte("rateLimitExceeded"))
+	}
+	return false
+}
+
+type tokenBucket struct {
+	mx     sync.Mutex
+	limit  float64
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	sleep  func(ctx context.Context, d time.Duration) error
+
+	calls, throttled, rejected int64
+	delay                      time.Duration
+}
+
+func newTokenBucket(qps float64, burst int) *tokenBucket {
+	if burst <= 0 {
+		burst = int(math.Ceil(qps))
+	}
+	return &tokenBucket{
+		limit:  qps,
+		rate:   qps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+		sleep:  sleepContext,
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// wait takes a token, sleeping until it is available. Tokens are reserved
+// before sleeping so waiters are served in order.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	b.mx.Lock()
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	b.tokens--
+	b.calls++
+	var d time.Duration
+	if b.tokens < 0 {
+		d = time.Duration(-b.tokens / b.rate * float64(time.Second))
+		b.throttled++
+		b.delay += d
+	}
+	b.mx.Unlock()
+
+	if d == 0 {
+		return nil
+	}
+	return b.sleep(ctx, d)
+}
+
+func (b *tokenBucket) backoff() {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.rejected++
+	b.rate = math.Max(b.rate/2, b.limit*minRateFactor)
+}
+
+func (b *tokenBucket) recover() {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.rate = math.Min(b.limit, b.rate+b.limit*recoveryFactor)
+}
+
+func (b *tokenBucket) stats() RateLimiterStats {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	return RateLimiterStats{Calls: b.calls, Throttled: b.throttled, Delay: b.delay, Rejected: b.rejected, CurrentQPS: b.rate}
+}
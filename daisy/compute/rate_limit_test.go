@@ -0,0 +1,186 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package compute
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/option"
+)
+
+type fakeClock struct {
+	mx  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(_ context.Context, d time.Duration) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.now = c.now.Add(d)
+	return nil
+}
+
+func newFakeBucket(qps float64, burst int) (*tokenBucket, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	b := newTokenBucket(qps, burst)
+	b.now = clock.Now
+	b.sleep = clock.Sleep
+	return b, clock
+}
+
+func TestTokenBucketWait(t *testing.T) {
+	b, clock := newFakeBucket(2, 2)
+	ctx := context.Background()
+
+	// The burst is served immediately.
+	for i := 0; i < 2; i++ {
+		if err := b.wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := clock.Now(); !got.Equal(time.Unix(0, 0)) {
+		t.Errorf("burst should not have been delayed, clock at %v", got)
+	}
+
+	// Further calls are spaced at 1/QPS.
+	for i := 0; i < 4; i++ {
+		if err := b.wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := clock.Now(), time.Unix(2, 0); !got.Equal(want) {
+		t.Errorf("clock at %v, want %v", got, want)
+	}
+
+	st := b.stats()
+	if st.Calls != 6 || st.Throttled != 4 || st.Delay <= 0 {
+		t.Errorf("unexpected stats: %+v", st)
+	}
+}
+
+func TestTokenBucketAdaptiveBackoff(t *testing.T) {
+	b, _ := newFakeBucket(16, 0)
+	if b.burst != 16 {
+		t.Errorf("burst should default to QPS, got %v", b.burst)
+	}
+
+	b.backoff()
+	if got := b.stats().CurrentQPS; got != 8 {
+		t.Errorf("rate should halve on backoff, got %v", got)
+	}
+	for i := 0; i < 10; i++ {
+		b.backoff()
+	}
+	if got := b.stats().CurrentQPS; got != 1 {
+		t.Errorf("rate should not drop below the minimum, got %v", got)
+	}
+	for i := 0; i < 100; i++ {
+		b.recover()
+	}
+	st := b.stats()
+	if st.CurrentQPS != 16 {
+		t.Errorf("rate should recover up to the configured rate, got %v", st.CurrentQPS)
+	}
+	if st.Rejected != 11 {
+		t.Errorf("rejected should count backoffs, got %v", st.Rejected)
+	}
+}
+
+func TestTokenBucketWaitCanceled(t *testing.T) {
+	b := newTokenBucket(0.001, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := b.wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := b.wait(ctx); err != context.Canceled {
+		t.Errorf("wait should return the context error, got %v", err)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		method, path string
+		want         MethodClass
+	}{
+		{http.MethodGet, "/projects/p/zones/z/instances/i/serialPort", ReadMethods},
+		{http.MethodGet, "/projects/p/zones/z/disks", ReadMethods},
+		{http.MethodPost, "/projects/p/zones/z/instances", MutateMethods},
+		{http.MethodDelete, "/projects/p/global/images/i", MutateMethods},
+		{http.MethodPost, "/projects/p/zones/z/operations/op/wait", OperationWaitMethods},
+		{http.MethodPost, "/projects/p/regions/r/operations/op/wait", OperationWaitMethods},
+		{http.MethodGet, "/projects/p/global/operations/op", OperationWaitMethods},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, "https://compute.googleapis.com/compute/v1"+tt.path, nil)
+		if got := classify(req); got != tt.want {
+			t.Errorf("classify(%s %s) = %q, want %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestNewClientWithRateLimiter(t *testing.T) {
+	var mx sync.Mutex
+	rejections := 1
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mx.Lock()
+		defer mx.Unlock()
+		if rejections > 0 {
+			rejections--
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"error":{"code":403,"message":"Rate Limit Exceeded","errors":[{"reason":"rateLimitExceeded"}]}}`)
+			return
+		}
+		fmt.Fprint(w, `{"Contents":"foo","Next":"3"}`)
+	}))
+	defer svr.Close()
+
+	rl := NewRateLimiter(RateLimits{Read: &RateLimit{QPS: 1000}})
+	c, err := NewClientWithRateLimiter(context.Background(), rl, option.WithEndpoint(svr.URL), option.WithHTTPClient(http.DefaultClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := http.DefaultClient.Transport.(*rateLimitedTransport); ok {
+		t.Fatal("the HTTP client passed as option should not be modified")
+	}
+
+	// The rejected call is retried by the client.
+	if _, err := c.GetSerialPortOutput(testProject, testZone, testInstance, 1, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stats := rl.Stats()
+	if _, ok := stats[MutateMethods]; ok {
+		t.Error("unset method classes should not be limited")
+	}
+	st := stats[ReadMethods]
+	if st.Calls != 2 || st.Rejected != 1 {
+		t.Errorf("unexpected stats: %+v", st)
+	}
+	if st.CurrentQPS >= 1000 {
+		t.Errorf("rate should have been lowered after a rejected call, got %v", st.CurrentQPS)
+	}
+}
@@ -0,0 +1,100 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package compute
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/GoogleCloudPlatform/compute-image-tools/daisy/compute"
+
+// MethodClassKey is the span attribute holding the MethodClass of a call.
+const MethodClassKey = attribute.Key("daisy.compute.method_class")
+
+// tracedTransport creates a span for each API call.
+type tracedTransport struct {
+	base   http.RoundTripper
+	tracer trace.Tracer
+	// parent returns the context of the span API calls are children of, when
+	// the request context doesn't carry a span.
+	parent func() context.Context
+}
+
+func (t *tracedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if !trace.SpanContextFromContext(ctx).IsValid() && t.parent != nil {
+		if p := t.parent(); p != nil {
+			ctx = trace.ContextWithSpan(ctx, trace.SpanFromContext(p))
+		}
+	}
+	ctx, span := t.tracer.Start(ctx, spanName(req),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPMethodKey.String(req.Method),
+			semconv.HTTPURLKey.String(req.URL.String()),
+			MethodClassKey.String(string(classify(req))),
+		))
+	defer span.End()
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+	return resp, nil
+}
+
+func (t *tracedTransport) unwrap() http.RoundTripper {
+	return t.base
+}
+
+// spanName names a span after the resource collection and method of an API
+// call, e.g. "compute.instances.serialPort GET" for
+// GET .../projects/p/zones/z/instances/i/serialPort.
+func spanName(req *http.Request) string {
+	segs := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	for i, s := range segs {
+		if s == "projects" && i+1 < len(segs) {
+			segs = segs[i+2:]
+			break
+		}
+	}
+	if len(segs) > 0 && segs[0] == "global" {
+		segs = segs[1:]
+	} else if len(segs) > 1 && (segs[0] == "zones" || segs[0] == "regions") {
+		segs = segs[2:]
+	}
+	// Drop resource names, keep collections and custom methods.
+	var parts []string
+	for i, s := range segs {
+		if i%2 == 0 {
+			parts = append(parts, s)
+		}
+	}
+	return fmt.Sprintf("compute.%s %s", strings.Join(parts, "."), req.Method)
+}
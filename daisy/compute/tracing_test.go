@@ -0,0 +1,95 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package compute
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"google.golang.org/api/option"
+)
+
+func TestSpanName(t *testing.T) {
+	tests := []struct {
+		method, path, want string
+	}{
+		{http.MethodGet, "/compute/v1/projects/p/zones/z/instances/i/serialPort", "compute.instances.serialPort GET"},
+		{http.MethodGet, "/compute/v1/projects/p/zones/z/disks", "compute.disks GET"},
+		{http.MethodPost, "/compute/v1/projects/p/regions/r/forwardingRules", "compute.forwardingRules POST"},
+		{http.MethodDelete, "/compute/v1/projects/p/global/images/i", "compute.images DELETE"},
+		{http.MethodPost, "/compute/v1/projects/p/zones/z/operations/op/wait", "compute.operations.wait POST"},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, "https://compute.googleapis.com"+tt.path, nil)
+		if got := spanName(req); got != tt.want {
+			t.Errorf("spanName(%s %s) = %q, want %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestNewClientWithConfigTracing(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"Contents":"foo","Next":"3"}`)
+	}))
+	defer svr.Close()
+
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	parentCtx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	cfg := ClientConfig{
+		RateLimiter:    NewRateLimiter(RateLimits{Read: &RateLimit{QPS: 1000}}),
+		TracerProvider: tp,
+		TraceParent:    func() context.Context { return parentCtx },
+	}
+	c, err := NewClientWithConfig(context.Background(), cfg, option.WithEndpoint(svr.URL), option.WithHTTPClient(http.DefaultClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetSerialPortOutput(testProject, testZone, testInstance, 1, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	parent.End()
+
+	spans := exp.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	s := spans[0]
+	if want := "compute.instances.serialPort GET"; s.Name != want {
+		t.Errorf("span name = %q, want %q", s.Name, want)
+	}
+	if s.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Error("API call span should be a child of the TraceParent span")
+	}
+	attrs := map[string]string{}
+	for _, kv := range s.Attributes {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if got := attrs[string(semconv.HTTPStatusCodeKey)]; got != "200" {
+		t.Errorf("status code attribute = %q, want 200", got)
+	}
+	if got := attrs[string(MethodClassKey)]; got != string(ReadMethods) {
+		t.Errorf("method class attribute = %q, want %q", got, ReadMethods)
+	}
+	if st := cfg.RateLimiter.Stats()[ReadMethods]; st.Calls != 1 {
+		t.Errorf("API call should also be rate limited, got stats %+v", st)
+	}
+}
@@ -0,0 +1,209 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/GoogleCloudPlatform/compute-image-tools/daisy"
+	daisyCompute "github.com/GoogleCloudPlatform/compute-image-tools/daisy/compute"
+)
+
+const serialOutput = "serial"
+
+// A TestFixture is a workflow preparing or cleaning up resources shared by
+// the tests of a suite.
+type TestFixture struct {
+	// Path to the daisy workflow to use.
+	Path string
+	// Vars to pass to the daisy workflow.
+	Vars map[string]string `json:",omitempty"`
+	// Values of the Setup workflow to pass as vars to the tests and the
+	// Teardown workflow, by var name. A value is either "serial:<key>", a
+	// serial-output value of the workflow, or "<type>:<name>", the real name
+	// of a resource of the workflow, e.g. "image:my-image". Resources used by
+	// tests need NoCleanup set so they are kept after the Setup workflow.
+	Outputs map[string]string `json:",omitempty"`
+
+	wfPath string
+}
+
+func (f *TestFixture) validateOutputs() error {
+	for v, o := range f.Outputs {
+		if parts := strings.SplitN(o, ":", 2); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("output %q: %q is not of the form \"serial:<key>\" or \"<type>:<name>\"", v, o)
+		}
+	}
+	return nil
+}
+
+// output returns the value of an output of a workflow which ran.
+func output(w *daisy.Workflow, o string) (string, bool) {
+	parts := strings.SplitN(o, ":", 2)
+	if parts[0] == serialOutput {
+		v := w.GetSerialConsoleOutputValue(parts[1])
+		return v, v != ""
+	}
+	return w.ResourceName(parts[0], parts[1])
+}
+
+// projectFixture is the Setup of a project.
+type projectFixture struct {
+	once sync.Once
+	vars map[string]string
+	logs string
+	err  error
+}
+
+// suiteFixtures runs the Setup workflow of a suite once in each project the
+// tests run in, before the first test, and the Teardown workflow in each of
+// these projects after all tests finished.
+type suiteFixtures struct {
+	setup, teardown                  *TestFixture
+	zone, oauthPath, computeEndpoint string
+	varMap                           map[string]string
+
+	mx       sync.Mutex
+	projects map[string]*projectFixture
+
+	// Overridden in tests.
+	newLocker func(daisyCompute.Client) *projectLocker
+	run       func(ctx context.Context, f *TestFixture, name, project string, vars map[string]string) (map[string]string, string, error)
+}
+
+func newSuiteFixtures(t *TestSuite, varMap map[string]string) *suiteFixtures {
+	s := &suiteFixtures{
+		setup:           t.Setup,
+		teardown:        t.Teardown,
+		zone:            t.Zone,
+		oauthPath:       t.OAuthPath,
+		computeEndpoint: t.ComputeEndpoint,
+		varMap:          varMap,
+		projects:        map[string]*projectFixture{},
+	}
+	s.run = s.runFixture
+	return s
+}
+
+// setupProject runs the Setup workflow in a project unless it already ran,
+// and returns the vars to pass to the tests in the project. If the Setup
+// workflow failed its logs are returned with the error.
+func (s *suiteFixtures) setupProject(ctx context.Context, project string) (map[string]string, string, error) {
+	s.mx.Lock()
+	pf, ok := s.projects[project]
+	if !ok {
+		pf = &projectFixture{}
+		s.projects[project] = pf
+	}
+	s.mx.Unlock()
+
+	pf.once.Do(func() {
+		if s.setup == nil {
+			return
+		}
+		pf.vars, pf.logs, pf.err = s.run(ctx, s.setup, "Setup", project, nil)
+		if pf.err != nil {
+			pf.err = fmt.Errorf("Setup workflow failed in project %q: %v", project, pf.err)
+		}
+	})
+	return pf.vars, pf.logs, pf.err
+}
+
+// teardownProjects runs the Teardown workflow in every project tests ran
+// in, with the outputs of the project's Setup workflow, whether tests
+// passed or not.
+func (s *suiteFixtures) teardownProjects(ctx context.Context) []error {
+	if s.teardown == nil {
+		return nil
+	}
+	s.mx.Lock()
+	var projects []string
+	vars := map[string]map[string]string{}
+	for p, pf := range s.projects {
+		projects = append(projects, p)
+		vars[p] = pf.vars
+	}
+	s.mx.Unlock()
+	sort.Strings(projects)
+
+	var errs []error
+	for _, p := range projects {
+		if _, logs, err := s.run(ctx, s.teardown, "Teardown", p, vars[p]); err != nil {
+			fmt.Printf("[TestRunner] Teardown workflow logs for project %q:\n%s", p, logs)
+			errs = append(errs, fmt.Errorf("Teardown workflow failed in project %q: %v", p, err))
+		}
+	}
+	return errs
+}
+
+// runFixture runs a fixture workflow in a project under a read lock, and
+// returns its outputs and logs. Outputs which are available are returned
+// even if the workflow failed, so they can be torn down.
+func (s *suiteFixtures) runFixture(ctx context.Context, f *TestFixture, name, project string, vars map[string]string) (map[string]string, string, error) {
+	varMap := map[string]string{}
+	for _, m := range []map[string]string{s.varMap, f.Vars, vars} {
+		for k, v := range m {
+			varMap[k] = v
+		}
+	}
+	l := &logger{}
+	w, err := createTestCase(ctx, l, f.wfPath, project, s.zone, s.oauthPath, s.computeEndpoint, varMap)
+	if err != nil || w == nil {
+		return nil, "", err
+	}
+
+	locker := s.newLocker(w.ComputeClient)
+	lock, err := locker.readLock(project, w.ID())
+	if err != nil {
+		return nil, "", err
+	}
+	lockLost := make(chan error, 1)
+	stopRenewal := locker.keepAlive(name, project, lock, func(err error) {
+		lockLost <- err
+		w.CancelWorkflow()
+	})
+	defer func() {
+		stopRenewal()
+		if err := locker.unlock(project, lock); err != nil {
+			fmt.Printf("[TestRunner] %s: Error unlocking project: %v\n", name, err)
+		}
+	}()
+
+	fmt.Printf("[TestRunner] Running %s workflow in project %q\n", name, project)
+	runErr := w.Run(ctx)
+	fmt.Printf("[TestRunner] %s workflow in project %q finished\n", name, project)
+	select {
+	case err := <-lockLost:
+		runErr = err
+	default:
+	}
+
+	outputs := map[string]string{}
+	for v, o := range f.Outputs {
+		val, ok := output(w, o)
+		if !ok {
+			if runErr == nil {
+				runErr = fmt.Errorf("output %q: workflow has no %q", v, o)
+			}
+			continue
+		}
+		outputs[v] = val
+	}
+	return outputs, l.buf.String(), runErr
+}
@@ -0,0 +1,92 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestSuiteFixtures(t *testing.T) {
+	setup := &TestFixture{Path: "setup.wf.json"}
+	teardown := &TestFixture{Path: "teardown.wf.json"}
+	s := newSuiteFixtures(&TestSuite{Setup: setup, Teardown: teardown}, nil)
+
+	var mx sync.Mutex
+	var runs []string
+	teardownVars := map[string]map[string]string{}
+	s.run = func(_ context.Context, f *TestFixture, name, project string, vars map[string]string) (map[string]string, string, error) {
+		mx.Lock()
+		defer mx.Unlock()
+		runs = append(runs, name+" "+project)
+		if f == teardown {
+			teardownVars[project] = vars
+			return nil, "", nil
+		}
+		if project == "p2" {
+			return map[string]string{"image": "partial"}, "setup logs", errors.New("boom")
+		}
+		return map[string]string{"image": "image-" + project}, "", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			vars, _, err := s.setupProject(context.Background(), "p1")
+			if err != nil || vars["image"] != "image-p1" {
+				t.Errorf("got (%v, %v), want Setup outputs of p1", vars, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, logs, err := s.setupProject(context.Background(), "p2"); err == nil || logs != "setup logs" {
+		t.Errorf("failed Setup should return its error and logs, got (%q, %v)", logs, err)
+	}
+	if _, _, err := s.setupProject(context.Background(), "p2"); err == nil {
+		t.Error("failed Setup should fail every test in the project")
+	}
+
+	if errs := s.teardownProjects(context.Background()); len(errs) != 0 {
+		t.Errorf("unexpected teardown errors: %v", errs)
+	}
+	wantRuns := []string{"Setup p1", "Setup p2", "Teardown p1", "Teardown p2"}
+	if !reflect.DeepEqual(runs, wantRuns) {
+		t.Errorf("got fixture runs %q, want %q", runs, wantRuns)
+	}
+	wantVars := map[string]map[string]string{"p1": {"image": "image-p1"}, "p2": {"image": "partial"}}
+	if !reflect.DeepEqual(teardownVars, wantVars) {
+		t.Errorf("got teardown vars %v, want %v", teardownVars, wantVars)
+	}
+}
+
+func TestFixtureValidateOutputs(t *testing.T) {
+	for o, valid := range map[string]bool{
+		"serial:key":     true,
+		"image:my-image": true,
+		"image":          false,
+		":name":          false,
+		"serial:":        false,
+	} {
+		err := (&TestFixture{Outputs: map[string]string{"v": o}}).validateOutputs()
+		if valid != (err == nil) {
+			t.Errorf("output %q: got error %v, want valid %t", o, err, valid)
+		}
+	}
+}
@@ -0,0 +1,589 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	daisyCompute "github.com/GoogleCloudPlatform/compute-image-tools/daisy/compute"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+const (
+	writeLock = "TestWriteLock-"
+	readLock  = "TestReadLock-"
+
+	lockBackendMetadata = "metadata"
+	lockBackendGCS      = "gcs"
+	lockBackendLocal    = "local"
+
+	// How long a local lock file may be held before it's considered
+	// abandoned by a crashed runner.
+	localLockFileTimeout = time.Minute
+)
+
+// errLockConflict is returned by a lockStore when the locks of a project
+// changed since they were loaded.
+var errLockConflict = errors.New("project locks were modified concurrently")
+
+// errLockLost is returned when a lock expired, or was removed by another
+// runner, while it was held.
+var errLockLost = errors.New("lock was lost")
+
+// A lockStore stores the test locks of projects, by lock name, with their
+// expiry. Stores use optimistic concurrency: store only succeeds if the
+// locks are still at the version they were loaded at.
+type lockStore interface {
+	// load returns the locks of a project and their version.
+	load(project string) (locks map[string]time.Time, version interface{}, err error)
+	// store replaces the locks of a project, it returns errLockConflict if
+	// they are no longer at version.
+	store(project string, locks map[string]time.Time, version interface{}) error
+}
+
+// newLockStore returns the lockStore of a backend. Metadata stores use the
+// Compute client of the test they lock for.
+func newLockStore(ctx context.Context, backend, gcsPath, dir, oauthPath string) (func(client daisyCompute.Client) lockStore, error) {
+	switch backend {
+	case lockBackendMetadata:
+		return func(client daisyCompute.Client) lockStore { return &metadataLockStore{client: client} }, nil
+	case lockBackendGCS:
+		s, err := newGCSLockStore(ctx, gcsPath, oauthPath)
+		if err != nil {
+			return nil, err
+		}
+		return func(daisyCompute.Client) lockStore { return s }, nil
+	case lockBackendLocal:
+		s := newLocalLockStore(dir)
+		return func(daisyCompute.Client) lockStore { return s }, nil
+	}
+	return nil, fmt.Errorf("unknown lock backend %q, want one of %q, %q or %q", backend, lockBackendMetadata, lockBackendGCS, lockBackendLocal)
+}
+
+// projectLocker holds read and write test locks on projects. Locks are held
+// for a lease which is renewed while the test runs, so the locks of a
+// runner which died expire soon after and are removed by the next runner
+// to lock the project.
+type projectLocker struct {
+	store lockStore
+	lease time.Duration
+
+	// Overridden in tests.
+	sleep func()
+	now   func() time.Time
+}
+
+func newProjectLocker(store lockStore, lease time.Duration) *projectLocker {
+	return &projectLocker{
+		store: store,
+		lease: lease,
+		sleep: func() { time.Sleep(time.Duration(rand.Intn(10)+5) * time.Second) },
+		now:   time.Now,
+	}
+}
+
+// update loads the locks of a project without the expired ones and applies
+// fn to them, storing them if they changed. It sleeps and tries again if fn
+// asks to wait or the locks were modified concurrently. Changes fn makes
+// before asking to wait, such as renewing a lease, are stored before it
+// sleeps.
+func (l *projectLocker) update(project string, fn func(locks map[string]time.Time) (wait bool, err error)) error {
+	for {
+		locks, version, err := l.store.load(project)
+		if err != nil {
+			return err
+		}
+		expired := map[string]time.Time{}
+		for k, t := range locks {
+			if !t.IsZero() && l.now().After(t) {
+				expired[k] = t
+				delete(locks, k)
+			}
+		}
+		before := copyLocks(locks)
+		wait, err := fn(locks)
+		if err != nil {
+			return err
+		}
+		if len(expired) != 0 || !sameLocks(before, locks) {
+			if err := l.store.store(project, locks, version); err == errLockConflict {
+				continue
+			} else if err != nil {
+				return err
+			}
+			for _, k := range sortedLocks(expired) {
+				fmt.Printf("[TestRunner] Lock %q in project %q expired at %s, removed stale lock\n", k, project, expired[k].Format(timeFormat))
+			}
+		}
+		if wait {
+			l.sleep()
+			continue
+		}
+		return nil
+	}
+}
+
+func hasLock(locks map[string]time.Time, prefix ...string) bool {
+	for k := range locks {
+		for _, p := range prefix {
+			if strings.HasPrefix(k, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// add returns an update which waits until there are no locks with any of
+// prefix and then adds lock.
+func (l *projectLocker) add(lock string, prefix ...string) func(map[string]time.Time) (bool, error) {
+	return func(locks map[string]time.Time) (bool, error) {
+		if hasLock(locks, prefix...) {
+			return true, nil
+		}
+		locks[lock] = l.now().Add(l.lease)
+		return false, nil
+	}
+}
+
+// readLock locks a project for a test which can share it with other tests.
+func (l *projectLocker) readLock(project, key string) (string, error) {
+	lock := readLock + key
+	return lock, l.update(project, l.add(lock, writeLock))
+}
+
+// customWriteLock locks a project for a test which can share it with any
+// test but those with the same custom lock.
+func (l *projectLocker) customWriteLock(project, custom, key string) (string, error) {
+	customLock := readLock + custom
+	lock := customLock + key
+	return lock, l.update(project, l.add(lock, writeLock, customLock))
+}
+
+// writeLock locks a project for a test which needs it to itself.
+func (l *projectLocker) writeLock(project, key string) (string, error) {
+	lock := writeLock + key
+	if err := l.update(project, l.add(lock, writeLock)); err != nil {
+		return "", err
+	}
+
+	// This means the project has no other write locks, wait till all current
+	// read locks are gone. Read locks can be held for longer than the lease,
+	// so the lease of the write lock is renewed while waiting.
+	if err := l.update(project, func(locks map[string]time.Time) (bool, error) {
+		if _, ok := locks[lock]; !ok {
+			return false, lockLostErr(project, lock)
+		}
+		if !hasLock(locks, readLock) {
+			return false, nil
+		}
+		locks[lock] = l.now().Add(l.lease)
+		return true, nil
+	}); err != nil {
+		// Attempt to unlock.
+		l.unlock(project, lock)
+		return "", err
+	}
+	return lock, nil
+}
+
+func lockLostErr(project, lock string) error {
+	return fmt.Errorf("lock %q in project %q: %w", lock, project, errLockLost)
+}
+
+// renew extends the lease of a lock, it fails with errLockLost if the lock
+// was lost.
+func (l *projectLocker) renew(project, lock string) error {
+	return l.update(project, func(locks map[string]time.Time) (bool, error) {
+		if _, ok := locks[lock]; !ok {
+			return false, lockLostErr(project, lock)
+		}
+		locks[lock] = l.now().Add(l.lease)
+		return false, nil
+	})
+}
+
+// keepAlive renews the lease of a lock until stop is called. If the lock is
+// lost, renewal stops and lost is called with the error.
+func (l *projectLocker) keepAlive(name, project, lock string, lost func(error)) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(l.lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := l.renew(project, lock)
+				if errors.Is(err, errLockLost) {
+					fmt.Printf("[TestRunner] Test %q: %v\n", name, err)
+					lost(err)
+					return
+				}
+				if err != nil {
+					fmt.Printf("[TestRunner] Test %q: Error renewing lock: %v\n", name, err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (l *projectLocker) unlock(project, lock string) error {
+	return l.update(project, func(locks map[string]time.Time) (bool, error) {
+		delete(locks, lock)
+		return false, nil
+	})
+}
+
+// holders counts the unexpired test locks in a project.
+func (l *projectLocker) holders(project string) (int, bool, error) {
+	locks, _, err := l.store.load(project)
+	if err != nil {
+		return 0, false, err
+	}
+	var holders int
+	var writeLocked bool
+	for k, t := range locks {
+		if !t.IsZero() && l.now().After(t) {
+			continue
+		}
+		holders++
+		if strings.HasPrefix(k, writeLock) {
+			writeLocked = true
+		}
+	}
+	return holders, writeLocked, nil
+}
+
+func copyLocks(locks map[string]time.Time) map[string]time.Time {
+	c := map[string]time.Time{}
+	for k, t := range locks {
+		c[k] = t
+	}
+	return c
+}
+
+func sameLocks(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, t := range a {
+		if bt, ok := b[k]; !ok || !bt.Equal(t) {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedLocks(locks map[string]time.Time) []string {
+	var keys []string
+	for k := range locks {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parseLockExpiry parses a lock's expiry, locks with an invalid expiry never
+// expire.
+func parseLockExpiry(val string) time.Time {
+	t, err := time.Parse(timeFormat, val)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func formatLocks(locks map[string]time.Time) map[string]string {
+	m := map[string]string{}
+	for k, t := range locks {
+		m[k] = t.Format(timeFormat)
+	}
+	return m
+}
+
+func parseLocks(m map[string]string) map[string]time.Time {
+	locks := map[string]time.Time{}
+	for k, v := range m {
+		locks[k] = parseLockExpiry(v)
+	}
+	return locks
+}
+
+func isPreconditionFailed(err error) bool {
+	gErr, ok := err.(*googleapi.Error)
+	return ok && gErr.Code == http.StatusPreconditionFailed
+}
+
+// metadataLockStore stores locks in the common instance metadata of the
+// project, using the metadata fingerprint as version.
+type metadataLockStore struct {
+	client daisyCompute.Client
+}
+
+func getCommonInstanceMetadata(client daisyCompute.Client, project string) (*compute.Metadata, error) {
+	proj, err := client.GetProject(project)
+	if err != nil {
+		return nil, fmt.Errorf("error getting project: %v", err)
+	}
+
+	return proj.CommonInstanceMetadata, nil
+}
+
+func isLockKey(key string) bool {
+	return strings.HasPrefix(key, writeLock) || strings.HasPrefix(key, readLock)
+}
+
+func (s *metadataLockStore) load(project string) (map[string]time.Time, interface{}, error) {
+	md, err := getCommonInstanceMetadata(s.client, project)
+	if err != nil {
+		return nil, nil, err
+	}
+	if md == nil {
+		md = &compute.Metadata{}
+	}
+	locks := map[string]time.Time{}
+	for _, mdi := range md.Items {
+		if mdi == nil || !isLockKey(mdi.Key) {
+			continue
+		}
+		var val string
+		if mdi.Value != nil {
+			val = *mdi.Value
+		}
+		locks[mdi.Key] = parseLockExpiry(val)
+	}
+	return locks, md, nil
+}
+
+func (s *metadataLockStore) store(project string, locks map[string]time.Time, version interface{}) error {
+	md := version.(*compute.Metadata)
+	var items []*compute.MetadataItems
+	for _, mdi := range md.Items {
+		if mdi != nil && !isLockKey(mdi.Key) {
+			items = append(items, mdi)
+		}
+	}
+	for _, k := range sortedLocks(locks) {
+		val := locks[k].Format(timeFormat)
+		items = append(items, &compute.MetadataItems{Key: k, Value: &val})
+	}
+	md.Items = items
+	// The fingerprint of the loaded metadata makes this fail if it was
+	// modified since.
+	if err := s.client.SetCommonInstanceMetadata(project, md); err != nil {
+		if isPreconditionFailed(err) {
+			return errLockConflict
+		}
+		return err
+	}
+	return nil
+}
+
+// gcsLockStore stores the locks of each project in a GCS object, using the
+// object generation as version.
+type gcsLockStore struct {
+	client *storage.Client
+	bucket string
+	prefix string
+}
+
+func newGCSLockStore(ctx context.Context, gcsPath, oauthPath string) (*gcsLockStore, error) {
+	if !strings.HasPrefix(gcsPath, "gs://") {
+		return nil, fmt.Errorf("-lock_gcs_path %q is not a gs:// path", gcsPath)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(gcsPath, "gs://"), "/", 2)
+	if parts[0] == "" {
+		return nil, fmt.Errorf("-lock_gcs_path %q has no bucket", gcsPath)
+	}
+	s := &gcsLockStore{bucket: parts[0]}
+	if len(parts) == 2 {
+		s.prefix = strings.Trim(parts[1], "/")
+	}
+	var opts []option.ClientOption
+	if oauthPath != "" {
+		opts = append(opts, option.WithCredentialsFile(oauthPath))
+	}
+	var err error
+	if s.client, err = storage.NewClient(ctx, opts...); err != nil {
+		return nil, fmt.Errorf("error creating storage client: %v", err)
+	}
+	return s, nil
+}
+
+func (s *gcsLockStore) object(project string) *storage.ObjectHandle {
+	return s.client.Bucket(s.bucket).Object(path.Join(s.prefix, project+".json"))
+}
+
+func (s *gcsLockStore) load(project string) (map[string]time.Time, interface{}, error) {
+	r, err := s.object(project).NewReader(context.Background())
+	if err == storage.ErrObjectNotExist {
+		return map[string]time.Time{}, int64(0), nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+	var m map[string]string
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, nil, fmt.Errorf("error reading locks of project %q: %v", project, err)
+	}
+	return parseLocks(m), r.Attrs.Generation, nil
+}
+
+func (s *gcsLockStore) store(project string, locks map[string]time.Time, version interface{}) error {
+	cond := storage.Conditions{GenerationMatch: version.(int64)}
+	if cond.GenerationMatch == 0 {
+		cond = storage.Conditions{DoesNotExist: true}
+	}
+	w := s.object(project).If(cond).NewWriter(context.Background())
+	w.ContentType = "application/json"
+	if err := json.NewEncoder(w).Encode(formatLocks(locks)); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		if isPreconditionFailed(err) {
+			return errLockConflict
+		}
+		return err
+	}
+	return nil
+}
+
+// localLockStore stores locks in memory, or in a JSON file per project in
+// a directory shared by runners on the same machine.
+type localLockStore struct {
+	dir string
+
+	mx  sync.Mutex
+	mem map[string]*localLocks
+}
+
+type localLocks struct {
+	Version int64
+	Locks   map[string]string
+}
+
+func newLocalLockStore(dir string) *localLockStore {
+	return &localLockStore{dir: dir, mem: map[string]*localLocks{}}
+}
+
+func (s *localLockStore) path(project string) string {
+	return filepath.Join(s.dir, project+".json")
+}
+
+func (s *localLockStore) read(project string) (*localLocks, error) {
+	if s.dir == "" {
+		if ll, ok := s.mem[project]; ok {
+			return ll, nil
+		}
+		return &localLocks{}, nil
+	}
+	d, err := ioutil.ReadFile(s.path(project))
+	if os.IsNotExist(err) {
+		return &localLocks{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ll localLocks
+	if err := json.Unmarshal(d, &ll); err != nil {
+		return nil, fmt.Errorf("error reading locks of project %q: %v", project, err)
+	}
+	return &ll, nil
+}
+
+func (s *localLockStore) load(project string) (map[string]time.Time, interface{}, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	ll, err := s.read(project)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parseLocks(ll.Locks), ll.Version, nil
+}
+
+func (s *localLockStore) store(project string, locks map[string]time.Time, version interface{}) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.dir != "" {
+		unlock, err := s.lockFile(project)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+	ll, err := s.read(project)
+	if err != nil {
+		return err
+	}
+	if ll.Version != version.(int64) {
+		return errLockConflict
+	}
+	ll = &localLocks{Version: ll.Version + 1, Locks: formatLocks(locks)}
+	if s.dir == "" {
+		s.mem[project] = ll
+		return nil
+	}
+	d, err := json.Marshal(ll)
+	if err != nil {
+		return err
+	}
+	tmp := s.path(project) + ".tmp"
+	if err := ioutil.WriteFile(tmp, d, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(project))
+}
+
+// lockFile excludes other runners from storing the locks of a project until
+// unlock is called.
+func (s *localLockStore) lockFile(project string) (unlock func(), err error) {
+	p := s.path(project) + ".lock"
+	for {
+		f, err := os.OpenFile(p, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(p) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if fi, err := os.Stat(p); err == nil && time.Since(fi.ModTime()) > localLockFileTimeout {
+			os.Remove(p)
+			continue
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
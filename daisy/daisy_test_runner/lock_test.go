@@ -0,0 +1,298 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	daisyCompute "github.com/GoogleCloudPlatform/compute-image-tools/daisy/compute"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+func newTestLocker(store lockStore) *projectLocker {
+	l := newProjectLocker(store, time.Minute)
+	l.sleep = func() { panic("unexpected wait for lock") }
+	return l
+}
+
+func TestLockerReadAndWriteLocks(t *testing.T) {
+	l := newTestLocker(newLocalLockStore(""))
+	r1, err := l.readLock("p", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.readLock("p", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if holders, writeLocked, _ := l.holders("p"); holders != 2 || writeLocked {
+		t.Errorf("got %d holders, write locked %t, want 2 holders, not write locked", holders, writeLocked)
+	}
+
+	// The write lock waits until the read locks are gone.
+	sleeps := 0
+	l.sleep = func() {
+		sleeps++
+		if sleeps == 1 {
+			l.unlock("p", r1)
+		} else {
+			l.unlock("p", readLock+"2")
+		}
+	}
+	w, err := l.writeLock("p", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sleeps != 2 {
+		t.Errorf("write lock should have waited for both read locks, waited %d times", sleeps)
+	}
+	if holders, writeLocked, _ := l.holders("p"); holders != 1 || !writeLocked {
+		t.Errorf("got %d holders, write locked %t, want 1 holder, write locked", holders, writeLocked)
+	}
+
+	// Read locks wait for the write lock.
+	l.sleep = func() { l.unlock("p", w) }
+	if _, err := l.readLock("p", "4"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockerWriteLockRenewsLeaseWhileWaiting(t *testing.T) {
+	s := newLocalLockStore("")
+	reader := newTestLocker(s)
+	writer := newTestLocker(s)
+	now := time.Now()
+	reader.now = func() time.Time { return now }
+	writer.now = func() time.Time { return now }
+	r, err := reader.readLock("p", "long-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The read lock is held for several leases, the reader renews it while
+	// its test runs.
+	sleeps := 0
+	writer.sleep = func() {
+		sleeps++
+		now = now.Add(40 * time.Second)
+		if sleeps < 5 {
+			if err := reader.renew("p", r); err != nil {
+				t.Fatalf("renewing read lock: %v", err)
+			}
+			return
+		}
+		reader.unlock("p", r)
+	}
+	w, err := writer.writeLock("p", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sleeps != 5 {
+		t.Errorf("write lock should have waited for the read lock, waited %d times", sleeps)
+	}
+	if err := writer.renew("p", w); err != nil {
+		t.Errorf("write lock should still be held after waiting: %v", err)
+	}
+	if holders, writeLocked, _ := writer.holders("p"); holders != 1 || !writeLocked {
+		t.Errorf("got %d holders, write locked %t, want 1 holder, write locked", holders, writeLocked)
+	}
+}
+
+func TestLockerWriteLockFailsWhenLostWhileWaiting(t *testing.T) {
+	l := newTestLocker(newLocalLockStore(""))
+	if _, err := l.readLock("p", "1"); err != nil {
+		t.Fatal(err)
+	}
+	l.sleep = func() { l.unlock("p", writeLock+"2") }
+	if _, err := l.writeLock("p", "2"); !errors.Is(err, errLockLost) {
+		t.Errorf("got error %v, want %v", err, errLockLost)
+	}
+}
+
+func TestLockerKeepAliveReportsLostLock(t *testing.T) {
+	l := newProjectLocker(newLocalLockStore(""), 30*time.Millisecond)
+	lock, err := l.readLock("p", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lost := make(chan error, 1)
+	stop := l.keepAlive("test", "p", lock, func(err error) { lost <- err })
+	defer stop()
+	if err := l.unlock("p", lock); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-lost:
+		if !errors.Is(err, errLockLost) {
+			t.Errorf("got error %v, want %v", err, errLockLost)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("lost lock wasn't reported")
+	}
+}
+
+func TestLockerCustomWriteLock(t *testing.T) {
+	l := newTestLocker(newLocalLockStore(""))
+	c, err := l.customWriteLock("p", "custom", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.customWriteLock("p", "other", "2"); err != nil {
+		t.Fatal(err)
+	}
+	waited := false
+	l.sleep = func() {
+		waited = true
+		l.unlock("p", c)
+	}
+	if _, err := l.customWriteLock("p", "custom", "3"); err != nil {
+		t.Fatal(err)
+	}
+	if !waited {
+		t.Error("custom lock should wait for locks with the same custom lock")
+	}
+}
+
+func TestLockerRemovesExpiredLocks(t *testing.T) {
+	s := newLocalLockStore("")
+	l := newTestLocker(s)
+	if _, err := l.writeLock("p", "dead-runner"); err != nil {
+		t.Fatal(err)
+	}
+	l.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if _, err := l.readLock("p", "1"); err != nil {
+		t.Fatal(err)
+	}
+	locks, _, _ := s.load("p")
+	if _, ok := locks[writeLock+"dead-runner"]; ok {
+		t.Error("expired lock should have been removed")
+	}
+	if err := l.renew("p", writeLock+"dead-runner"); !errors.Is(err, errLockLost) {
+		t.Errorf("got error %v renewing a lost lock, want %v", err, errLockLost)
+	}
+}
+
+func TestLockerRenew(t *testing.T) {
+	l := newTestLocker(newLocalLockStore(""))
+	lock, err := l.readLock("p", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(50 * time.Second)
+	l.now = func() time.Time { return later }
+	if err := l.renew("p", lock); err != nil {
+		t.Fatal(err)
+	}
+	locks, _, _ := l.store.load("p")
+	if got, want := locks[lock], later.Add(time.Minute).Truncate(time.Second); !got.Equal(want) {
+		t.Errorf("got lock expiry %v, want %v", got, want)
+	}
+}
+
+// conflictStore fails the first store as if another runner modified the
+// locks.
+type conflictStore struct {
+	lockStore
+	conflicts int
+}
+
+func (s *conflictStore) store(project string, locks map[string]time.Time, version interface{}) error {
+	if s.conflicts == 0 {
+		s.conflicts++
+		return errLockConflict
+	}
+	return s.lockStore.store(project, locks, version)
+}
+
+func TestLockerRetriesConflicts(t *testing.T) {
+	s := &conflictStore{lockStore: newLocalLockStore("")}
+	l := newTestLocker(s)
+	if _, err := l.readLock("p", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if holders, _, _ := l.holders("p"); s.conflicts != 1 || holders != 1 {
+		t.Errorf("lock should have been retried after the conflict, got %d conflicts and %d holders", s.conflicts, holders)
+	}
+}
+
+func TestLocalLockStoreFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "locks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s1, s2 := newLocalLockStore(dir), newLocalLockStore(dir)
+	locks, v, err := s1.load("p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, v2, _ := s2.load("p")
+	locks["l"] = time.Now().Truncate(time.Second)
+	if err := s1.store("p", locks, v); err != nil {
+		t.Fatal(err)
+	}
+	if err := s2.store("p", map[string]time.Time{}, v2); err != errLockConflict {
+		t.Errorf("got error %v, want %v", err, errLockConflict)
+	}
+	got, _, err := s2.load("p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameLocks(got, locks) {
+		t.Errorf("got locks %v, want %v", got, locks)
+	}
+}
+
+func TestMetadataLockStore(t *testing.T) {
+	val := "bar"
+	md := &compute.Metadata{Fingerprint: "1", Items: []*compute.MetadataItems{{Key: "foo", Value: &val}}}
+	_, c, err := daisyCompute.NewTestClient(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.GetProjectFn = func(project string) (*compute.Project, error) {
+		return &compute.Project{CommonInstanceMetadata: md}, nil
+	}
+	c.SetCommonInstanceMetadataFn = func(project string, m *compute.Metadata) error {
+		if m.Fingerprint != md.Fingerprint {
+			return &googleapi.Error{Code: 412}
+		}
+		md = &compute.Metadata{Fingerprint: md.Fingerprint + "1", Items: m.Items}
+		return nil
+	}
+
+	s := &metadataLockStore{client: c}
+	l := newTestLocker(s)
+	lock, err := l.readLock("p", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(md.Items) != 2 || md.Items[0].Key != "foo" || md.Items[1].Key != lock {
+		t.Errorf("metadata should keep other items and add the lock, got %+v", md.Items)
+	}
+
+	_, stale, _ := s.load("p")
+	if err := l.unlock("p", lock); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store("p", map[string]time.Time{}, stale); err != errLockConflict {
+		t.Errorf("got error %v, want %v", err, errLockConflict)
+	}
+}
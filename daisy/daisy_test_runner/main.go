@@ -30,6 +30,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"text/template"
@@ -38,7 +39,6 @@ import (
 	"github.com/GoogleCloudPlatform/compute-image-tools/daisy"
 	daisyCompute "github.com/GoogleCloudPlatform/compute-image-tools/daisy/compute"
 	"github.com/google/uuid"
-	"google.golang.org/api/compute/v1"
 )
 
 const (
@@ -57,6 +57,11 @@ var (
 	filter        = flag.String("filter", "", "test name filter")
 	outPath       = flag.String("out_path", "junit.xml", "junit xml path")
 	parallelCount = flag.Int("parallel_count", 0, "TestParallelCount")
+	retries       = flag.Int("retries", 0, "number of times failed tests are retried, for tests which don't set Retries")
+	lockBackend   = flag.String("lock_backend", lockBackendMetadata, "where project locks are kept: metadata (project metadata), gcs (objects in -lock_gcs_path) or local (files in -lock_dir, or in memory)")
+	lockGCSPath   = flag.String("lock_gcs_path", "", "gs:// path of the project lock objects of the gcs lock backend")
+	lockDir       = flag.String("lock_dir", "", "directory of the project lock files of the local lock backend, locks are kept in memory if not set")
+	lockLease     = flag.Duration("lock_lease", 10*time.Minute, "lease of project locks, renewed while tests run; locks of runners which died expire after it")
 
 	funcMap = map[string]interface{}{
 		"randItem": randItem,
@@ -106,6 +111,15 @@ type TestSuite struct {
 
 	OAuthPath       string
 	ComputeEndpoint string
+
+	// Workflow run once in each project before the first test in it, its
+	// Outputs are passed as vars to every test.
+	Setup *TestFixture `json:",omitempty"`
+	// Workflow run in each project tests ran in after all tests finished,
+	// even if they failed. It gets the Outputs of the Setup workflow as vars.
+	Teardown *TestFixture `json:",omitempty"`
+
+	fixtures *suiteFixtures
 }
 
 // A TestCase is a single test to run.
@@ -118,6 +132,9 @@ type TestCase struct {
 	logger *logger
 	// Vars to pass to the daisy workflow.
 	Vars map[string]string
+	// Settings to create new workflows of the test with.
+	wfPath, project, zone, oauthPath, computeEndpoint string
+	varMap                                            map[string]string
 	// Default timeout is 2 hours.
 	// Must be parsable by https://golang.org/pkg/time/#ParseDuration.
 	TestTimeout string
@@ -133,6 +150,29 @@ type TestCase struct {
 	// impact other concurrent test runs.
 	ProjectLock       bool
 	CustomProjectLock string
+
+	// Resources the test needs, the test runs in a project with enough free
+	// quota for them.
+	Resources TestResources
+
+	// How many times the test is retried if its workflow fails, overrides the
+	// -retries flag. Tests which pass on retry are reported as flaky.
+	Retries *int `json:",omitempty"`
+
+	// If set the test is expanded into a test case per combination of the
+	// matrix vars, named after the test and the combination.
+	Matrix *TestMatrix `json:",omitempty"`
+}
+
+// newWorkflow creates a new workflow and logger for the test.
+func (t *TestCase) newWorkflow(ctx context.Context) error {
+	t.logger = &logger{}
+	w, err := createTestCase(ctx, t.logger, t.wfPath, t.project, t.zone, t.oauthPath, t.computeEndpoint, t.varMap)
+	if err != nil {
+		return err
+	}
+	t.w = w
+	return nil
 }
 
 type logger struct {
@@ -241,6 +281,28 @@ func createTestSuite(ctx context.Context, path string, varMap map[string]string,
 		t.TestParallelCount = defaultParallelCount
 	}
 
+	if t.Tests, err = expandMatrices(t.Tests); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	fixtureVars := map[string]string{}
+	for k, v := range varMap {
+		fixtureVars[k] = v
+	}
+	for name, f := range map[string]*TestFixture{"Setup": t.Setup, "Teardown": t.Teardown} {
+		if f == nil {
+			continue
+		}
+		if err := f.validateOutputs(); err != nil {
+			return nil, fmt.Errorf("%s: %s: %v", path, name, err)
+		}
+		f.wfPath = filepath.Join(filepath.Dir(path), f.Path)
+		if _, err := daisy.NewFromFile(f.wfPath); err != nil {
+			return nil, fmt.Errorf("%s: %s: %v", path, name, err)
+		}
+	}
+	t.fixtures = newSuiteFixtures(&t, fixtureVars)
+
 	fmt.Printf("[TestRunner] Creating test cases for test suite %q\n", t.Name)
 
 	for name, test := range t.Tests {
@@ -280,13 +342,20 @@ func createTestSuite(ctx context.Context, path string, varMap map[string]string,
 			computeEndpoint = test.ComputeEndpoint
 		}
 
-		rand.Seed(time.Now().UnixNano())
-		test.logger = &logger{}
-		w, err := createTestCase(ctx, test.logger, wfPath, t.Projects[rand.Intn(len(t.Projects))], zone, oauthPath, computeEndpoint, varMap)
-		if err != nil {
+		if test.Retries == nil {
+			test.Retries = retries
+		}
+
+		// The project is chosen by the scheduler when the test starts, the first
+		// project is used for printing and validation.
+		test.wfPath, test.project, test.zone, test.oauthPath, test.computeEndpoint = wfPath, t.Projects[0], zone, oauthPath, computeEndpoint
+		test.varMap = map[string]string{}
+		for k, v := range varMap {
+			test.varMap[k] = v
+		}
+		if err := test.newWorkflow(ctx); err != nil {
 			return nil, err
 		}
-		test.w = w
 	}
 
 	return &t, nil
@@ -362,9 +431,14 @@ type junitTestCase struct {
 	ID        string        `xml:"id,attr"`
 	Name      string        `xml:"name,attr"`
 	Time      float64       `xml:"time,attr"`
+	Attempts  int           `xml:"attempts,attr,omitempty"`
 	Skipped   *junitSkipped `xml:"skipped,omitempty"`
 	Failure   *junitFailure `xml:"failure,omitempty"`
-	SystemOut string        `xml:"system-out,omitempty"`
+	// Failed attempts of a test which passed on retry.
+	FlakyFailures []*junitRerunFailure `xml:"flakyFailure,omitempty"`
+	// Failed attempts, other than the last one, of a test which failed.
+	RerunFailures []*junitRerunFailure `xml:"rerunFailure,omitempty"`
+	SystemOut     string               `xml:"system-out,omitempty"`
 }
 
 type junitSkipped struct {
@@ -376,207 +450,122 @@ type junitFailure struct {
 	FailType    string `xml:"type,attr"`
 }
 
+type junitRerunFailure struct {
+	Message   string `xml:"message,attr"`
+	Type      string `xml:"type,attr"`
+	SystemOut string `xml:"system-out,omitempty"`
+}
+
 type test struct {
 	name     string
 	testCase *TestCase
 }
 
-func getCommonInstanceMetadata(client daisyCompute.Client, project string) (*compute.Metadata, error) {
-	proj, err := client.GetProject(project)
-	if err != nil {
-		return nil, fmt.Errorf("error getting project: %v", err)
-	}
-
-	return proj.CommonInstanceMetadata, nil
-}
-
-func delItem(items []*compute.MetadataItems, i int) []*compute.MetadataItems {
-	// Delete the element.
-	// https://github.com/golang/go/wiki/SliceTricks
-	copy(items[i:], items[i+1:])
-	items[len(items)-1] = nil
-	return items[:len(items)-1]
-}
-
-func isExpired(val string) bool {
-	t, err := time.Parse(timeFormat, val)
-	if err != nil {
-		return false
-	}
-	return time.Now().After(t)
-}
+const defaultTimeout = 2 * time.Hour
 
-const (
-	writeLock      = "TestWriteLock-"
-	readLock       = "TestReadLock-"
-	defaultTimeout = 2 * time.Hour
-)
+var allowedChars = regexp.MustCompile("[^-_a-zA-Z0-9]+")
 
-func waitLock(client daisyCompute.Client, project string, prefix ...string) (*compute.Metadata, error) {
-	var md *compute.Metadata
-	var err error
-Loop:
-	for {
-		md, err = getCommonInstanceMetadata(client, project)
-		if err != nil {
-			return nil, err
+// runTestCase runs a test, retrying failed workflows up to the test's
+// retries. Each attempt runs a new workflow in a newly scheduled and locked
+// project. Tests which pass on retry are reported as flaky.
+func runTestCase(ctx context.Context, test *test, tc *junitTestCase, errors chan error, lockRetries int, scheduler *projectScheduler, newLocker func(daisyCompute.Client) *projectLocker, fixtures *suiteFixtures) {
+	attempts := *test.testCase.Retries + 1
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			if err := test.testCase.newWorkflow(ctx); err != nil {
+				errors <- fmt.Errorf("%s: %v", tc.Name, err)
+				tc.Failure = &junitFailure{FailMessage: err.Error(), FailType: "Error"}
+				return
+			}
 		}
-
-		for i, mdi := range md.Items {
-			if mdi != nil {
-				for _, p := range prefix {
-					if strings.HasPrefix(mdi.Key, p) {
-						if isExpired(*mdi.Value) {
-							md.Items = delItem(md.Items, i)
-						} else {
-							r := rand.Intn(10) + 5
-							time.Sleep(time.Duration(r) * time.Second)
-							continue Loop
-						}
-					}
-				}
+		tc.Attempts = attempt
+		f := runAttempt(ctx, test, tc, lockRetries, scheduler, newLocker, fixtures)
+		if f == nil {
+			if attempt > 1 {
+				fmt.Printf("[TestRunner] Test case %q passed on attempt %d, marking as flaky\n", tc.Name, attempt)
+				tc.FlakyFailures = tc.RerunFailures
+				tc.RerunFailures = nil
 			}
+			return
 		}
-		return md, nil
+		if f.Type != "Failure" || attempt >= attempts {
+			errors <- fmt.Errorf("%s: %s", tc.Name, f.Message)
+			tc.Failure = &junitFailure{FailMessage: f.Message, FailType: f.Type}
+			return
+		}
+		fmt.Printf("[TestRunner] Test case %q failed attempt %d of %d, retrying\n", tc.Name, attempt, attempts)
+		tc.RerunFailures = append(tc.RerunFailures, f)
 	}
 }
 
-func projectReadLock(client daisyCompute.Client, project, key string, timeout time.Duration) (string, error) {
-	md, err := waitLock(client, project, writeLock)
-	if err != nil {
-		return "", err
-	}
-
-	lock := readLock + key
-	val := time.Now().Add(timeout).Format(timeFormat)
-	md.Items = append(md.Items, &compute.MetadataItems{Key: lock, Value: &val})
-	if err := client.SetCommonInstanceMetadata(project, md); err != nil {
-		return "", err
+// runAttempt runs the current workflow of a test, returning the failure if
+// it didn't pass.
+func runAttempt(ctx context.Context, test *test, tc *junitTestCase, retries int, scheduler *projectScheduler, newLocker func(daisyCompute.Client) *projectLocker, fixtures *suiteFixtures) *junitRerunFailure {
+	if err := test.testCase.w.PopulateClients(ctx); err != nil {
+		return &junitRerunFailure{Message: err.Error(), Type: "Error"}
 	}
-	return lock, nil
-}
 
-func customProjectWriteLock(client daisyCompute.Client, project, custom, key string, timeout time.Duration) (string, error) {
-	customLock := readLock + custom
-	md, err := waitLock(client, project, writeLock, customLock)
+	project, release, err := scheduler.acquire(ctx, test.testCase.w.ComputeClient, test.name, test.testCase.w.Zone, test.testCase.Resources)
 	if err != nil {
-		return "", err
+		return &junitRerunFailure{Message: err.Error(), Type: "Error"}
 	}
+	defer release()
+	fmt.Printf("[TestRunner] Test case %q scheduled in project %q\n", tc.Name, project)
+	test.testCase.w.Project = project
 
-	lock := customLock + key
-	val := time.Now().Add(timeout).Format(timeFormat)
-	md.Items = append(md.Items, &compute.MetadataItems{Key: lock, Value: &val})
-	if err := client.SetCommonInstanceMetadata(project, md); err != nil {
-		return "", err
-	}
-
-	return lock, nil
-}
-
-func projectWriteLock(client daisyCompute.Client, project, key string, timeout time.Duration) (string, error) {
-	md, err := waitLock(client, project, writeLock)
+	// The Setup workflow takes its own lock, so it has to run before the test
+	// locks the project.
+	vars, logs, err := fixtures.setupProject(ctx, project)
 	if err != nil {
-		return "", err
+		return &junitRerunFailure{Message: err.Error(), Type: "Error", SystemOut: logs}
 	}
-
-	// This means the project has no current write locks, set the write lock
-	// now and then wait till all current read locks are gone.
-	lock := writeLock + key
-	val := time.Now().Add(timeout).Format(timeFormat)
-	md.Items = append(md.Items, &compute.MetadataItems{Key: lock, Value: &val})
-	if err := client.SetCommonInstanceMetadata(project, md); err != nil {
-		return "", err
-	}
-
-	if _, err := waitLock(client, project, readLock); err != nil {
-		// Attempt to unlock.
-		projectUnlock(client, project, lock)
-		return "", err
-	}
-	return lock, nil
-}
-
-func projectUnlock(client daisyCompute.Client, project, lock string) error {
-	md, err := getCommonInstanceMetadata(client, project)
-	if err != nil {
-		return err
-	}
-
-	for i, mdi := range md.Items {
-		if mdi != nil && lock == mdi.Key {
-			md.Items = delItem(md.Items, i)
-		}
-	}
-
-	return client.SetCommonInstanceMetadata(project, md)
-}
-
-var allowedChars = regexp.MustCompile("[^-_a-zA-Z0-9]+")
-
-func runTestCase(ctx context.Context, test *test, tc *junitTestCase, errors chan error, retries int) {
-	if err := test.testCase.w.PopulateClients(ctx); err != nil {
-		errors <- fmt.Errorf("%s: %v", tc.Name, err)
-		tc.Failure = &junitFailure{FailMessage: err.Error(), FailType: "Error"}
-		return
+	for k, v := range vars {
+		test.testCase.w.AddVar(k, v)
 	}
 
+	canceled := make(chan error, 1)
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
+	defer signal.Stop(c)
 	go func() {
 		select {
 		case <-c:
 			fmt.Printf("\nCtrl-C caught, sending cancel signal to %q...\n", test.name)
+			canceled <- fmt.Errorf("test case %q was canceled", test.name)
 			test.testCase.w.CancelWorkflow()
-			err := fmt.Errorf("test case %q was canceled", test.name)
-			errors <- err
-			tc.Failure = &junitFailure{FailMessage: err.Error(), FailType: "Canceled"}
 		case <-test.testCase.w.Cancel:
 		}
 	}()
-
-	project := test.testCase.w.Project
-	client := test.testCase.w.ComputeClient
+	locker := newLocker(test.testCase.w.ComputeClient)
 	key := test.testCase.w.ID()
 	var lock string
-	var err error
-	if test.testCase.CustomProjectLock != "" {
-		for i := 0; i < retries; i++ {
-			lock, err = customProjectWriteLock(client, project, allowedChars.ReplaceAllString(test.testCase.CustomProjectLock, "_"), key, test.testCase.timeout)
-			if err == nil {
-				break
-			}
-		}
-		if err != nil {
-			errors <- err
-			return
-		}
-	} else if test.testCase.ProjectLock {
-		for i := 0; i < retries; i++ {
-			lock, err = projectWriteLock(client, project, key, test.testCase.timeout)
-			if err == nil {
-				break
-			}
-		}
-		if err != nil {
-			errors <- err
-			return
-		}
-	} else {
-		for i := 0; i < retries; i++ {
-			lock, err = projectReadLock(client, project, key, test.testCase.timeout)
-			if err == nil {
-				break
-			}
+	for i := 0; i < retries; i++ {
+		if test.testCase.CustomProjectLock != "" {
+			lock, err = locker.customWriteLock(project, allowedChars.ReplaceAllString(test.testCase.CustomProjectLock, "_"), key)
+		} else if test.testCase.ProjectLock {
+			lock, err = locker.writeLock(project, key)
+		} else {
+			lock, err = locker.readLock(project, key)
 		}
-		if err != nil {
-			errors <- err
-			return
+		if err == nil {
+			break
 		}
 	}
+	if err != nil {
+		return &junitRerunFailure{Message: err.Error(), Type: "Error"}
+	}
+	// A test whose lock was lost may share the project with tests it should
+	// be isolated from, so it's stopped and fails.
+	lockLost := make(chan error, 1)
+	stopRenewal := locker.keepAlive(test.name, project, lock, func(err error) {
+		lockLost <- err
+		test.testCase.w.CancelWorkflow()
+	})
 	defer func() {
+		stopRenewal()
+		var err error
 		for i := 0; i < retries; i++ {
-			err := projectUnlock(client, project, lock)
-			if err == nil {
+			if err = locker.unlock(project, lock); err == nil {
 				break
 			}
 		}
@@ -587,19 +576,52 @@ func runTestCase(ctx context.Context, test *test, tc *junitTestCase, errors chan
 
 	select {
 	case <-test.testCase.w.Cancel:
-		return
+		return &junitRerunFailure{Message: fmt.Sprintf("test case %q was canceled", test.name), Type: "Canceled"}
 	default:
 	}
 
 	start := time.Now()
 	fmt.Printf("[TestRunner] Running test case %q\n", tc.Name)
-	if err := test.testCase.w.Run(ctx); err != nil {
-		errors <- fmt.Errorf("%s: %v", tc.Name, err)
-		tc.Failure = &junitFailure{FailMessage: err.Error(), FailType: "Failure"}
-	}
-	tc.Time = time.Since(start).Seconds()
+	runErr := test.testCase.w.Run(ctx)
+	tc.Time += time.Since(start).Seconds()
 	tc.SystemOut = test.testCase.logger.buf.String()
 	fmt.Printf("[TestRunner] Test case %q finished\n", tc.Name)
+	select {
+	case err := <-lockLost:
+		return &junitRerunFailure{Message: err.Error(), Type: "Error", SystemOut: tc.SystemOut}
+	case err := <-canceled:
+		return &junitRerunFailure{Message: err.Error(), Type: "Canceled", SystemOut: tc.SystemOut}
+	default:
+	}
+	if runErr != nil {
+		return &junitRerunFailure{Message: runErr.Error(), Type: "Failure", SystemOut: tc.SystemOut}
+	}
+	return nil
+}
+
+// printFlakeSummary prints the rate of failed attempts of each test which
+// was retried.
+func printFlakeSummary(tcs []*junitTestCase) {
+	var retried []*junitTestCase
+	for _, tc := range tcs {
+		if tc.Attempts > 1 {
+			retried = append(retried, tc)
+		}
+	}
+	if len(retried) == 0 {
+		return
+	}
+	sort.Slice(retried, func(i, j int) bool { return retried[i].Name < retried[j].Name })
+	fmt.Println("[TestRunner] Retried test cases:")
+	for _, tc := range retried {
+		failed := len(tc.FlakyFailures) + len(tc.RerunFailures)
+		status := "flaky"
+		if tc.Failure != nil {
+			failed++
+			status = "failed"
+		}
+		fmt.Printf("  - %s: %s, %d of %d attempts failed (flake rate %.0f%%)\n", tc.Name, status, failed, tc.Attempts, 100*float64(failed)/float64(tc.Attempts))
+	}
 }
 
 func main() {
@@ -637,9 +659,9 @@ func main() {
 		return
 	}
 
-	errors := make(chan error, len(ts.Tests))
+	errors := make(chan error, len(ts.Tests)+len(ts.Projects))
 	// Retry failed locks 2x as many tests in the test case.
-	retries := len(ts.Tests) * 2
+	lockRetries := len(ts.Tests) * 2
 	if len(ts.Tests) == 0 {
 		fmt.Println("[TestRunner] Nothing to do")
 		return
@@ -677,6 +699,15 @@ func main() {
 
 	junit := &junitTestSuite{Name: ts.Name, Tests: len(ts.Tests)}
 	tests := make(chan *test, len(ts.Tests))
+	newStore, err := newLockStore(ctx, *lockBackend, *lockGCSPath, *lockDir, ts.OAuthPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	newLocker := func(client daisyCompute.Client) *projectLocker {
+		return newProjectLocker(newStore(client), *lockLease)
+	}
+	scheduler := newProjectScheduler(ts.Projects, newLocker)
+	ts.fixtures.newLocker = newLocker
 	var wg sync.WaitGroup
 	for i := 0; i < ts.TestParallelCount; i++ {
 		wg.Add(1)
@@ -696,7 +727,7 @@ func main() {
 					continue
 				}
 
-				runTestCase(ctx, test, tc, errors, retries)
+				runTestCase(ctx, test, tc, errors, lockRetries, scheduler, newLocker, ts.fixtures)
 			}
 		}()
 	}
@@ -708,6 +739,12 @@ func main() {
 	close(tests)
 	wg.Wait()
 
+	for _, err := range ts.fixtures.teardownProjects(ctx) {
+		errors <- err
+	}
+
+	printFlakeSummary(junit.TestCase)
+
 	fmt.Printf("[TestRunner] Creating junit xml file: %q\n", *outPath)
 	junit.Time = time.Since(start).Seconds()
 	d, err := xml.MarshalIndent(junit, "  ", "   ")
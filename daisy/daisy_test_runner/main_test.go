@@ -0,0 +1,43 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package main
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestJUnitFlakyFailures(t *testing.T) {
+	tc := &junitTestCase{
+		Name:          "test",
+		Attempts:      2,
+		FlakyFailures: []*junitRerunFailure{{Message: "boot race", Type: "Failure", SystemOut: "attempt 1 logs"}},
+	}
+	b, err := xml.Marshal(tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<flakyFailure message="boot race" type="Failure"><system-out>attempt 1 logs</system-out></flakyFailure>`
+	if !strings.Contains(string(b), want) {
+		t.Errorf("junit test case %s doesn't contain %s", b, want)
+	}
+	if strings.Contains(string(b), "<failure") {
+		t.Errorf("flaky test case should not be a failure: %s", b)
+	}
+	if !strings.Contains(string(b), `attempts="2"`) {
+		t.Errorf("junit test case should record the number of attempts: %s", b)
+	}
+}
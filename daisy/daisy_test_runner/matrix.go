@@ -0,0 +1,148 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A TestMatrix expands a TestCase into a test case per combination of
+// values of its vars.
+type TestMatrix struct {
+	// Values of each var, every combination of them is a test case.
+	Vars map[string][]string
+	// Additional combinations to test.
+	Include []map[string]string `json:",omitempty"`
+	// Combinations not to test. A combination is excluded if it matches all
+	// vars of an entry.
+	Exclude []map[string]string `json:",omitempty"`
+}
+
+// combinations returns the combinations of var values of the matrix, in
+// order.
+func (m *TestMatrix) combinations() ([]map[string]string, error) {
+	var keys []string
+	for k, vs := range m.Vars {
+		if len(vs) == 0 {
+			return nil, fmt.Errorf("matrix var %q has no values", k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var combos []map[string]string
+	if len(keys) > 0 {
+		combos = []map[string]string{{}}
+	}
+	for _, k := range keys {
+		var next []map[string]string
+		for _, c := range combos {
+			for _, v := range m.Vars[k] {
+				nc := map[string]string{k: v}
+				for ck, cv := range c {
+					nc[ck] = cv
+				}
+				next = append(next, nc)
+			}
+		}
+		combos = next
+	}
+
+	var result []map[string]string
+	for _, c := range combos {
+		if !m.excluded(c) {
+			result = append(result, c)
+		}
+	}
+	for _, inc := range m.Include {
+		if len(inc) == 0 {
+			return nil, fmt.Errorf("matrix Include entries can't be empty")
+		}
+		result = append(result, inc)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("matrix has no combinations")
+	}
+	return result, nil
+}
+
+func (m *TestMatrix) excluded(c map[string]string) bool {
+Loop:
+	for _, ex := range m.Exclude {
+		if len(ex) == 0 {
+			continue
+		}
+		for k, v := range ex {
+			if c[k] != v {
+				continue Loop
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// matrixTestName names the test case of a combination, e.g.
+// "test-boot [machine_type=n1-standard-4,source_image=debian-10]".
+func matrixTestName(name string, c map[string]string) string {
+	var keys []string
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var kvs []string
+	for _, k := range keys {
+		kvs = append(kvs, fmt.Sprintf("%s=%s", k, c[k]))
+	}
+	return fmt.Sprintf("%s [%s]", name, strings.Join(kvs, ","))
+}
+
+// expandMatrices replaces each test with a Matrix by a test per combination
+// of its matrix. Matrix vars override the test's Vars.
+func expandMatrices(tests map[string]*TestCase) (map[string]*TestCase, error) {
+	expanded := map[string]*TestCase{}
+	for name, test := range tests {
+		if test.Matrix == nil {
+			expanded[name] = test
+			continue
+		}
+		combos, err := test.Matrix.combinations()
+		if err != nil {
+			return nil, fmt.Errorf("test %q: %v", name, err)
+		}
+		for _, c := range combos {
+			n := matrixTestName(name, c)
+			if _, ok := tests[n]; ok {
+				return nil, fmt.Errorf("test %q: matrix test case %q already exists", name, n)
+			}
+			if _, ok := expanded[n]; ok {
+				return nil, fmt.Errorf("test %q: duplicate matrix test case %q", name, n)
+			}
+			tc := *test
+			tc.Matrix = nil
+			tc.Vars = map[string]string{}
+			for k, v := range test.Vars {
+				tc.Vars[k] = v
+			}
+			for k, v := range c {
+				tc.Vars[k] = v
+			}
+			expanded[n] = &tc
+		}
+	}
+	return expanded, nil
+}
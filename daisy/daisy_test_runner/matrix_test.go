@@ -0,0 +1,84 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestExpandMatrices(t *testing.T) {
+	var tests map[string]*TestCase
+	if err := json.Unmarshal([]byte(`{
+		"test-boot": {
+			"Path": "./boot/boot.wf.json",
+			"Vars": {"min_cpu_platform": "skylake", "machine_type": "overridden"},
+			"Matrix": {
+				"Vars": {
+					"source_image": ["debian-10", "centos-8"],
+					"machine_type": ["f1-micro", "n1-standard-96"]
+				},
+				"Exclude": [{"source_image": "centos-8", "machine_type": "f1-micro"}],
+				"Include": [{"source_image": "rhel-8", "machine_type": "n1-standard-4"}]
+			}
+		},
+		"test-network": {"Path": "./network/network.wf.json"}
+	}`), &tests); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := expandMatrices(tests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for n := range got {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	want := []string{
+		"test-boot [machine_type=f1-micro,source_image=debian-10]",
+		"test-boot [machine_type=n1-standard-4,source_image=rhel-8]",
+		"test-boot [machine_type=n1-standard-96,source_image=centos-8]",
+		"test-boot [machine_type=n1-standard-96,source_image=debian-10]",
+		"test-network",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got test cases %q, want %q", names, want)
+	}
+
+	tc := got["test-boot [machine_type=f1-micro,source_image=debian-10]"]
+	wantVars := map[string]string{"min_cpu_platform": "skylake", "machine_type": "f1-micro", "source_image": "debian-10"}
+	if !reflect.DeepEqual(tc.Vars, wantVars) {
+		t.Errorf("got vars %v, want %v", tc.Vars, wantVars)
+	}
+	if tc.Matrix != nil || tc.Path != "./boot/boot.wf.json" {
+		t.Errorf("expanded test case should keep its settings but not its matrix: %+v", tc)
+	}
+}
+
+func TestExpandMatricesErrors(t *testing.T) {
+	for _, m := range []*TestMatrix{
+		{Vars: map[string][]string{"a": {}}},
+		{Vars: map[string][]string{"a": {"1"}}, Exclude: []map[string]string{{"a": "1"}}},
+		{Include: []map[string]string{{}}},
+	} {
+		if _, err := expandMatrices(map[string]*TestCase{"t": {Matrix: m}}); err == nil {
+			t.Errorf("expected error for matrix %+v", m)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	daisyCompute "github.com/GoogleCloudPlatform/compute-image-tools/daisy/compute"
+)
+
+const (
+	// How long a project is skipped for after its quota couldn't be checked.
+	quotaCooldown = 2 * time.Minute
+
+	cpuQuotaMetric  = "CPUS"
+	diskQuotaMetric = "DISKS_TOTAL_GB"
+)
+
+// TestResources are the resources a test needs at most at once, used to
+// choose a project with enough free quota.
+type TestResources struct {
+	// Number of vCPUs of the test's instances.
+	VCPUs float64 `json:",omitempty"`
+	// Total size in GB of the test's standard persistent disks.
+	DiskGB float64 `json:",omitempty"`
+}
+
+func (r TestResources) isZero() bool {
+	return r.VCPUs == 0 && r.DiskGB == 0
+}
+
+// projectLoad is the load this runner put on a project.
+type projectLoad struct {
+	tests int
+	TestResources
+}
+
+// projectScheduler chooses the project a test runs in when the test starts.
+// Projects are ranked by the number of lock holders, from this and any other
+// runner, and the resources of tests this runner started in them. Projects
+// whose quota can't be checked are skipped for a while.
+type projectScheduler struct {
+	mx       sync.Mutex
+	projects []string
+	load     map[string]*projectLoad
+	cooldown map[string]time.Time
+
+	// Overridden in tests.
+	lockHolders func(client daisyCompute.Client, project string) (holders int, writeLocked bool, err error)
+	quota       func(client daisyCompute.Client, project, region string) (free TestResources, limit TestResources, err error)
+	sleep       func()
+}
+
+func newProjectScheduler(projects []string, newLocker func(daisyCompute.Client) *projectLocker) *projectScheduler {
+	s := &projectScheduler{
+		projects: projects,
+		load:     map[string]*projectLoad{},
+		cooldown: map[string]time.Time{},
+		lockHolders: func(client daisyCompute.Client, project string) (int, bool, error) {
+			return newLocker(client).holders(project)
+		},
+		quota: regionQuota,
+		sleep: func() { time.Sleep(time.Duration(rand.Intn(10)+5) * time.Second) },
+	}
+	for _, p := range projects {
+		s.load[p] = &projectLoad{}
+	}
+	return s
+}
+
+type projectCandidate struct {
+	project     string
+	holders     int
+	writeLocked bool
+	load        projectLoad
+}
+
+// acquire chooses a project for a test in zone needing res, and reserves
+// res in it until release is called. It blocks until a project has enough
+// quota, and fails if no project could ever fit the test.
+func (s *projectScheduler) acquire(ctx context.Context, client daisyCompute.Client, name, zone string, res TestResources) (project string, release func(), err error) {
+	region := regionFromZone(zone)
+	tooSmall := map[string]bool{}
+	for {
+		select {
+		case <-ctx.Done():
+			return "", nil, ctx.Err()
+		default:
+		}
+
+		for _, c := range s.candidates(client) {
+			if res.isZero() {
+				return c.project, s.reserve(c.project, res), nil
+			}
+			free, limit, err := s.quota(client, c.project, region)
+			if err != nil {
+				fmt.Printf("[TestRunner] Test %q: quota check in project %q failed, trying another project: %v\n", name, c.project, err)
+				s.coolDown(c.project)
+				continue
+			}
+			if res.VCPUs > limit.VCPUs || res.DiskGB > limit.DiskGB {
+				tooSmall[c.project] = true
+			}
+			// Resources of tests this runner started may not be in use yet.
+			free.VCPUs -= c.load.VCPUs
+			free.DiskGB -= c.load.DiskGB
+			if res.VCPUs <= free.VCPUs && res.DiskGB <= free.DiskGB {
+				return c.project, s.reserve(c.project, res), nil
+			}
+			fmt.Printf("[TestRunner] Test %q: not enough quota in project %q (need %v vCPUs, %v GB disk; free %v vCPUs, %v GB disk), trying another project\n",
+				name, c.project, res.VCPUs, res.DiskGB, free.VCPUs, free.DiskGB)
+		}
+		if len(tooSmall) == len(s.projects) {
+			return "", nil, fmt.Errorf("test %q needs %v vCPUs and %v GB disk, which exceeds the quota of every project in region %q", name, res.VCPUs, res.DiskGB, region)
+		}
+		s.sleep()
+	}
+}
+
+// candidates returns the projects not cooling down, least loaded first.
+func (s *projectScheduler) candidates(client daisyCompute.Client) []*projectCandidate {
+	s.mx.Lock()
+	var cs []*projectCandidate
+	for _, p := range s.projects {
+		if until, ok := s.cooldown[p]; ok && time.Now().Before(until) {
+			continue
+		}
+		cs = append(cs, &projectCandidate{project: p, load: *s.load[p]})
+	}
+	s.mx.Unlock()
+
+	for _, c := range cs {
+		holders, writeLocked, err := s.lockHolders(client, c.project)
+		if err != nil {
+			// Locking will report the error, rank the project last.
+			writeLocked = true
+		}
+		c.holders, c.writeLocked = holders, writeLocked
+	}
+	sort.SliceStable(cs, func(i, j int) bool {
+		a, b := cs[i], cs[j]
+		if a.writeLocked != b.writeLocked {
+			return !a.writeLocked
+		}
+		if a.holders != b.holders {
+			return a.holders < b.holders
+		}
+		return a.load.VCPUs < b.load.VCPUs
+	})
+	return cs
+}
+
+func (s *projectScheduler) coolDown(project string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.cooldown[project] = time.Now().Add(quotaCooldown)
+}
+
+func (s *projectScheduler) reserve(project string, res TestResources) func() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	l := s.load[project]
+	l.tests++
+	l.VCPUs += res.VCPUs
+	l.DiskGB += res.DiskGB
+	return func() {
+		s.mx.Lock()
+		defer s.mx.Unlock()
+		l.tests--
+		l.VCPUs -= res.VCPUs
+		l.DiskGB -= res.DiskGB
+	}
+}
+
+// regionQuota returns the free and total CPU and disk quota of a region.
+func regionQuota(client daisyCompute.Client, project, region string) (TestResources, TestResources, error) {
+	rs, err := client.ListRegions(project, daisyCompute.Filter(fmt.Sprintf("name = %q", region)))
+	if err != nil {
+		return TestResources{}, TestResources{}, err
+	}
+	if len(rs) == 0 {
+		return TestResources{}, TestResources{}, fmt.Errorf("region %q not found", region)
+	}
+	var free, limit TestResources
+	for _, q := range rs[0].Quotas {
+		switch q.Metric {
+		case cpuQuotaMetric:
+			free.VCPUs, limit.VCPUs = q.Limit-q.Usage, q.Limit
+		case diskQuotaMetric:
+			free.DiskGB, limit.DiskGB = q.Limit-q.Usage, q.Limit
+		}
+	}
+	return free, limit, nil
+}
+
+func regionFromZone(zone string) string {
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		return zone[:i]
+	}
+	return zone
+}
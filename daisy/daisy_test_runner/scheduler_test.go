@@ -0,0 +1,105 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	daisyCompute "github.com/GoogleCloudPlatform/compute-image-tools/daisy/compute"
+)
+
+func newTestScheduler(holders map[string]int, free map[string]TestResources) *projectScheduler {
+	var projects []string
+	for _, p := range []string{"p1", "p2", "p3"} {
+		if _, ok := holders[p]; ok {
+			projects = append(projects, p)
+		}
+	}
+	s := newProjectScheduler(projects, nil)
+	s.lockHolders = func(_ daisyCompute.Client, project string) (int, bool, error) {
+		return holders[project], false, nil
+	}
+	s.quota = func(_ daisyCompute.Client, project, _ string) (TestResources, TestResources, error) {
+		f, ok := free[project]
+		if !ok {
+			return TestResources{}, TestResources{}, errors.New("quota error")
+		}
+		return f, TestResources{VCPUs: 100, DiskGB: 1000}, nil
+	}
+	return s
+}
+
+func TestSchedulerPrefersLeastLockHolders(t *testing.T) {
+	s := newTestScheduler(map[string]int{"p1": 3, "p2": 0, "p3": 1}, nil)
+	p, release, err := s.acquire(context.Background(), nil, "t", "us-central1-a", TestResources{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	if p != "p2" {
+		t.Errorf("got project %q, want %q", p, "p2")
+	}
+}
+
+func TestSchedulerChecksQuota(t *testing.T) {
+	s := newTestScheduler(
+		map[string]int{"p1": 0, "p2": 1, "p3": 2},
+		map[string]TestResources{"p2": {VCPUs: 4, DiskGB: 100}, "p3": {VCPUs: 12, DiskGB: 500}})
+	res := TestResources{VCPUs: 8, DiskGB: 200}
+
+	// p1's quota check fails and p2 doesn't have enough quota.
+	p, release, err := s.acquire(context.Background(), nil, "t", "us-central1-a", res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != "p3" {
+		t.Errorf("got project %q, want %q", p, "p3")
+	}
+	if _, ok := s.cooldown["p1"]; !ok {
+		t.Error("project with a failed quota check should cool down")
+	}
+
+	// The reservation of the first test leaves no room in p3.
+	sleeps := 0
+	s.sleep = func() {
+		sleeps++
+		if sleeps == 1 {
+			release()
+		}
+	}
+	p, release, err = s.acquire(context.Background(), nil, "t2", "us-central1-a", res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	if p != "p3" || sleeps != 1 {
+		t.Errorf("test should have waited for the first test to release p3, got project %q after %d sleeps", p, sleeps)
+	}
+}
+
+func TestSchedulerFailsWhenTestExceedsQuota(t *testing.T) {
+	s := newTestScheduler(map[string]int{"p1": 0, "p2": 0}, map[string]TestResources{"p1": {}, "p2": {}})
+	if _, _, err := s.acquire(context.Background(), nil, "t", "us-central1-a", TestResources{VCPUs: 200}); err == nil {
+		t.Error("expected error for a test exceeding the quota of every project")
+	}
+}
+
+func TestRegionFromZone(t *testing.T) {
+	if got := regionFromZone("us-central1-a"); got != "us-central1" {
+		t.Errorf("got %q, want %q", got, "us-central1")
+	}
+}
@@ -115,6 +115,9 @@ func (d *Disk) populate(ctx context.Context, s *Step) DError {
 	} else {
 		d.Type = fmt.Sprintf("projects/%s/zones/%s/diskTypes/%s", d.Project, d.Zone, d.Type)
 	}
+	if key := s.w.kmsKey(); key != "" && d.DiskEncryptionKey == nil {
+		d.DiskEncryptionKey = &compute.CustomerEncryptionKey{KmsKeyName: key}
+	}
 	d.link = fmt.Sprintf("projects/%s/zones/%s/disks/%s", d.Project, d.Zone, d.Name)
 	return errs
 }
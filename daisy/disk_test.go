@@ -104,6 +104,29 @@ func TestDiskPopulate(t *testing.T) {
 	}
 }
 
+func TestDiskPopulateKmsKey(t *testing.T) {
+	w := testWorkflow()
+	w.KmsKey = "projects/p/locations/l/keyRings/r/cryptoKeys/workflow"
+	s, _ := w.NewStep("s")
+
+	d := &Disk{Disk: compute.Disk{Name: "foo"}, SizeGb: "10"}
+	if err := d.populate(context.Background(), s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.DiskEncryptionKey == nil || d.DiskEncryptionKey.KmsKeyName != w.KmsKey {
+		t.Errorf("disk should be encrypted with the workflow key, got %+v", d.DiskEncryptionKey)
+	}
+
+	own := &compute.CustomerEncryptionKey{KmsKeyName: "projects/p/locations/l/keyRings/r/cryptoKeys/disk"}
+	d = &Disk{Disk: compute.Disk{Name: "bar", DiskEncryptionKey: own}, SizeGb: "10"}
+	if err := d.populate(context.Background(), s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.DiskEncryptionKey != own {
+		t.Errorf("disk key should be kept, got %+v", d.DiskEncryptionKey)
+	}
+}
+
 func TestDiskRegAttach(t *testing.T) {
 	// Test:
 	// - normal attachment
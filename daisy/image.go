@@ -115,6 +115,7 @@ type ImageInterface interface {
 	markCreatedInWorkflow()
 	delete(cc daisyCompute.Client) error
 	populateGuestOSFeatures()
+	populateEncryptionKey(kmsKey string)
 }
 
 //ImageBase is a base struct for GA/Beta/Alpha images. It holds the shared properties between them.
@@ -194,6 +195,12 @@ func (i *Image) delete(cc daisyCompute.Client) error {
 	return cc.DeleteImage(i.Project, i.Name)
 }
 
+func (i *Image) populateEncryptionKey(kmsKey string) {
+	if kmsKey != "" && i.ImageEncryptionKey == nil {
+		i.ImageEncryptionKey = &compute.CustomerEncryptionKey{KmsKeyName: kmsKey}
+	}
+}
+
 func (i *Image) populateGuestOSFeatures() {
 	if i.GuestOsFeatures == nil {
 		return
@@ -269,6 +276,12 @@ func (i *ImageBeta) delete(cc daisyCompute.Client) error {
 	return cc.DeleteImage(i.Project, i.Name)
 }
 
+func (i *ImageBeta) populateEncryptionKey(kmsKey string) {
+	if kmsKey != "" && i.ImageEncryptionKey == nil {
+		i.ImageEncryptionKey = &computeBeta.CustomerEncryptionKey{KmsKeyName: kmsKey}
+	}
+}
+
 func (i *ImageBeta) populateGuestOSFeatures() {
 	if i.GuestOsFeatures == nil {
 		return
@@ -344,6 +357,12 @@ func (i *ImageAlpha) delete(cc daisyCompute.Client) error {
 	return cc.DeleteImage(i.Project, i.Name)
 }
 
+func (i *ImageAlpha) populateEncryptionKey(kmsKey string) {
+	if kmsKey != "" && i.ImageEncryptionKey == nil {
+		i.ImageEncryptionKey = &computeAlpha.CustomerEncryptionKey{KmsKeyName: kmsKey}
+	}
+}
+
 func (i *ImageAlpha) populateGuestOSFeatures() {
 	if i.GuestOsFeatures == nil {
 		return
@@ -400,6 +419,7 @@ func (ib *ImageBase) populate(ctx context.Context, ii ImageInterface, s *Step) D
 	}
 	ib.link = fmt.Sprintf("projects/%s/global/images/%s", ib.Project, ii.getName())
 	ii.populateGuestOSFeatures()
+	ii.populateEncryptionKey(s.w.kmsKey())
 	return errs
 }
 
@@ -335,6 +335,28 @@ func TestImageAlphaPopulate(t *testing.T) {
 	}
 }
 
+func TestImagePopulateKmsKey(t *testing.T) {
+	w := testWorkflow()
+	w.KmsKey = "projects/p/locations/l/keyRings/r/cryptoKeys/workflow"
+	s, _ := w.NewStep("s")
+
+	i := &Image{Image: compute.Image{Name: "foo", SourceDisk: "d"}}
+	if err := (&i.ImageBase).populate(context.Background(), i, s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if i.ImageEncryptionKey == nil || i.ImageEncryptionKey.KmsKeyName != w.KmsKey {
+		t.Errorf("image should be encrypted with the workflow key, got %+v", i.ImageEncryptionKey)
+	}
+
+	ib := &ImageBeta{Image: computeBeta.Image{Name: "foo", SourceDisk: "d"}}
+	if err := (&ib.ImageBase).populate(context.Background(), ib, s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ib.ImageEncryptionKey == nil || ib.ImageEncryptionKey.KmsKeyName != w.KmsKey {
+		t.Errorf("beta image should be encrypted with the workflow key, got %+v", ib.ImageEncryptionKey)
+	}
+}
+
 func TestImageValidate(t *testing.T) {
 	ctx := context.Background()
 	w := testWorkflow()
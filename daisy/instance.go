@@ -389,6 +389,9 @@ func (i *Instance) populateDisks(w *Workflow) DError {
 			if imageURLRgx.MatchString(p.SourceImage) {
 				p.SourceImage = extendPartialURL(p.SourceImage, i.Project)
 			}
+			if key := w.kmsKey(); key != "" && d.DiskEncryptionKey == nil {
+				d.DiskEncryptionKey = &compute.CustomerEncryptionKey{KmsKeyName: key}
+			}
 
 			// Extend DiskType if short URL, or create extended URL.
 			p.DiskType = strOr(p.DiskType, defaultDiskType)
@@ -436,6 +439,9 @@ func (i *InstanceBeta) populateDisks(w *Workflow) DError {
 			if imageURLRgx.MatchString(p.SourceImage) {
 				p.SourceImage = extendPartialURL(p.SourceImage, i.Project)
 			}
+			if key := w.kmsKey(); key != "" && d.DiskEncryptionKey == nil {
+				d.DiskEncryptionKey = &computeBeta.CustomerEncryptionKey{KmsKeyName: key}
+			}
 
 			// Extend DiskType if short URL, or create extended URL.
 			p.DiskType = strOr(p.DiskType, defaultDiskType)
@@ -196,6 +196,27 @@ func TestInstancePopulateDisks(t *testing.T) {
 	}
 }
 
+func TestInstancePopulateDisksKmsKey(t *testing.T) {
+	parent := testWorkflow()
+	parent.KmsKey = "projects/p/locations/l/keyRings/r/cryptoKeys/workflow"
+	w := testWorkflow()
+	w.parent = parent
+
+	i := Instance{Instance: compute.Instance{Name: "foo", Zone: testZone, Disks: []*compute.AttachedDisk{
+		{InitializeParams: &compute.AttachedDiskInitializeParams{SourceImage: "i"}},
+		{Source: "d"},
+	}}, InstanceBase: InstanceBase{Resource: Resource{Project: testProject}}}
+	if err := i.populateDisks(w); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if key := i.Disks[0].DiskEncryptionKey; key == nil || key.KmsKeyName != parent.KmsKey {
+		t.Errorf("initialized disk should be encrypted with the key of the parent workflow, got %+v", key)
+	}
+	if key := i.Disks[1].DiskEncryptionKey; key != nil {
+		t.Errorf("existing disk shouldn't get a key, got %+v", key)
+	}
+}
+
 func TestInstancePopulateMachineType(t *testing.T) {
 	tests := []struct {
 		desc, mt, wantMt string
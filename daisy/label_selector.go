@@ -0,0 +1,171 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package daisy
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	daisyCompute "github.com/GoogleCloudPlatform/compute-image-tools/daisy/compute"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	labelSelectorInstances       = "Instances"
+	labelSelectorDisks           = "Disks"
+	labelSelectorImages          = "Images"
+	labelSelectorSnapshots       = "Snapshots"
+	labelSelectorForwardingRules = "ForwardingRules"
+)
+
+var labelSelectorTypes = []string{labelSelectorInstances, labelSelectorDisks, labelSelectorImages, labelSelectorSnapshots, labelSelectorForwardingRules}
+
+// LabelSelector selects existing GCE resources by their labels. Only
+// resources in the workflow project and zone (or its region, for regional
+// resources) are selected.
+type LabelSelector struct {
+	// Labels a resource must have for it to be selected. All labels must match.
+	Labels map[string]string
+	// Resource types to select, one or more of Instances, Disks, Images,
+	// Snapshots and ForwardingRules.
+	Types []string
+}
+
+type labeledResource struct {
+	typeName string
+	link     string
+	deleteFn func() error
+}
+
+func (ls *LabelSelector) validate() DError {
+	if len(ls.Labels) == 0 {
+		return Errf("cannot select resources by labels: no Labels given")
+	}
+	for k := range ls.Labels {
+		if k == "" {
+			return Errf("cannot select resources by labels: empty label key")
+		}
+	}
+	if len(ls.Types) == 0 {
+		return Errf("cannot select resources by labels: no Types given")
+	}
+	for _, t := range ls.Types {
+		if !strIn(t, labelSelectorTypes) {
+			return Errf("cannot select resources by labels: unknown type %q, must be one of %v", t, labelSelectorTypes)
+		}
+	}
+	return nil
+}
+
+// filter returns the GCE API list filter matching all labels.
+func (ls *LabelSelector) filter() daisyCompute.Filter {
+	var keys []string
+	for k := range ls.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var exprs []string
+	for _, k := range keys {
+		exprs = append(exprs, fmt.Sprintf("(labels.%s = %q)", k, ls.Labels[k]))
+	}
+	return daisyCompute.Filter(strings.Join(exprs, " "))
+}
+
+// list returns the resources in the workflow project and zone matching the
+// selector, keyed by type.
+func (ls *LabelSelector) list(w *Workflow) (map[string][]*labeledResource, DError) {
+	cc := w.ComputeClient
+	project, zone, region := w.Project, w.Zone, getRegionFromZone(w.Zone)
+	filter := ls.filter()
+	found := map[string][]*labeledResource{}
+	for _, t := range ls.Types {
+		var err error
+		switch t {
+		case labelSelectorInstances:
+			var is []*compute.Instance
+			if is, err = cc.ListInstances(project, zone, filter); err == nil {
+				for _, i := range is {
+					name := i.Name
+					found[t] = append(found[t], &labeledResource{"instance", fmt.Sprintf("projects/%s/zones/%s/instances/%s", project, zone, name),
+						func() error { return cc.DeleteInstance(project, zone, name) }})
+				}
+			}
+		case labelSelectorDisks:
+			var ds []*compute.Disk
+			if ds, err = cc.ListDisks(project, zone, filter); err == nil {
+				for _, d := range ds {
+					name := d.Name
+					found[t] = append(found[t], &labeledResource{"disk", fmt.Sprintf("projects/%s/zones/%s/disks/%s", project, zone, name),
+						func() error { return cc.DeleteDisk(project, zone, name) }})
+				}
+			}
+		case labelSelectorImages:
+			var is []*compute.Image
+			if is, err = cc.ListImages(project, filter); err == nil {
+				for _, i := range is {
+					name := i.Name
+					found[t] = append(found[t], &labeledResource{"image", fmt.Sprintf("projects/%s/global/images/%s", project, name),
+						func() error { return cc.DeleteImage(project, name) }})
+				}
+			}
+		case labelSelectorSnapshots:
+			var ss []*compute.Snapshot
+			if ss, err = cc.ListSnapshots(project, filter); err == nil {
+				for _, ss := range ss {
+					name := ss.Name
+					found[t] = append(found[t], &labeledResource{"snapshot", fmt.Sprintf("projects/%s/global/snapshots/%s", project, name),
+						func() error { return cc.DeleteSnapshot(project, name) }})
+				}
+			}
+		case labelSelectorForwardingRules:
+			var frs []*compute.ForwardingRule
+			if frs, err = cc.ListForwardingRules(project, region, filter); err == nil {
+				for _, fr := range frs {
+					name := fr.Name
+					found[t] = append(found[t], &labeledResource{"forwarding rule", fmt.Sprintf("projects/%s/regions/%s/forwardingRules/%s", project, region, name),
+						func() error { return cc.DeleteForwardingRule(project, region, name) }})
+				}
+			}
+		}
+		if err != nil {
+			return nil, typedErr(apiError, fmt.Sprintf("failed to list %s by labels", strings.ToLower(t)), err)
+		}
+	}
+	return found, nil
+}
+
+// deleteLabeled deletes resources selected by labels as part of wg, sending
+// any errors to e.
+func deleteLabeled(s *Step, wg *sync.WaitGroup, e chan DError, rs []*labeledResource) {
+	w := s.w
+	for _, r := range rs {
+		wg.Add(1)
+		go func(r *labeledResource) {
+			defer wg.Done()
+			w.LogStepInfo(s.name, "DeleteResources", "Deleting %s %q selected by labels.", r.typeName, r.link)
+			if err := r.deleteFn(); err != nil {
+				if gErr, ok := err.(*googleapi.Error); ok && gErr.Code == http.StatusNotFound {
+					w.LogStepInfo(s.name, "DeleteResources", "WARNING: Error deleting %s %q: %v", r.typeName, r.link, err)
+					return
+				}
+				e <- typedErr(apiError, fmt.Sprintf("failed to delete %s", r.typeName), err)
+			}
+		}(r)
+	}
+}
@@ -61,6 +61,10 @@ func (mi *MachineImage) populate(ctx context.Context, s *Step) DError {
 	mi.Description = strOr(mi.Description, fmt.Sprintf("Machine Image created by Daisy in workflow %q on behalf of %s.", s.w.Name, s.w.username))
 	mi.link = fmt.Sprintf("projects/%s/global/machineImages/%s", mi.Project, mi.Name)
 
+	if key := s.w.kmsKey(); key != "" && mi.MachineImageEncryptionKey == nil {
+		mi.MachineImageEncryptionKey = &computeBeta.CustomerEncryptionKey{KmsKeyName: key}
+	}
+
 	errs = addErrs(errs, mi.populateSourceInstance())
 	return errs
 }
@@ -46,6 +46,20 @@ func TestMachineImagePopulate(t *testing.T) {
 	}
 }
 
+func TestMachineImagePopulateKmsKey(t *testing.T) {
+	w := testWorkflow()
+	w.KmsKey = "projects/p/locations/l/keyRings/r/cryptoKeys/k"
+	s, _ := w.NewStep("s")
+
+	mi := &MachineImage{}
+	if err := mi.populate(context.Background(), s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mi.MachineImageEncryptionKey == nil || mi.MachineImageEncryptionKey.KmsKeyName != w.KmsKey {
+		t.Errorf("machine image should be encrypted with the workflow key, got %+v", mi.MachineImageEncryptionKey)
+	}
+}
+
 func TestMachineImagesValidate(t *testing.T) {
 	ctx := context.Background()
 	w := testWorkflow()
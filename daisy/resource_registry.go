@@ -33,6 +33,40 @@ type baseResourceRegistry struct {
 	urlRgx   *regexp.Regexp
 }
 
+// registries returns the resource registries of the workflow.
+func (w *Workflow) registries() []*baseResourceRegistry {
+	return []*baseResourceRegistry{
+		&w.disks.baseResourceRegistry,
+		&w.forwardingRules.baseResourceRegistry,
+		&w.firewallRules.baseResourceRegistry,
+		&w.images.baseResourceRegistry,
+		&w.machineImages.baseResourceRegistry,
+		&w.instances.baseResourceRegistry,
+		&w.networks.baseResourceRegistry,
+		&w.subnetworks.baseResourceRegistry,
+		&w.targetInstances.baseResourceRegistry,
+		&w.snapshots.baseResourceRegistry,
+	}
+}
+
+// ResourceName returns the real name of a resource the workflow created or
+// used, by resource type, e.g. "disk" or "image", and name in the workflow.
+func (w *Workflow) ResourceName(resourceType, name string) (string, bool) {
+	if w.disks == nil {
+		// Registries aren't initialized.
+		return "", false
+	}
+	for _, r := range w.registries() {
+		if r.typeName != resourceType {
+			continue
+		}
+		if res, ok := r.get(name); ok {
+			return res.RealName, true
+		}
+	}
+	return "", false
+}
+
 func (r *baseResourceRegistry) init() {
 	r.m = map[string]*Resource{}
 }
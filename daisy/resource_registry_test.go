@@ -320,6 +320,21 @@ func TestResourceRegistryGet(t *testing.T) {
 	}
 }
 
+func TestWorkflowResourceName(t *testing.T) {
+	w := testWorkflow()
+	w.images.m = map[string]*Resource{"i": {RealName: "i-real"}}
+
+	if got, ok := w.ResourceName("image", "i"); !ok || got != "i-real" {
+		t.Errorf("got (%q, %t), want (%q, true)", got, ok, "i-real")
+	}
+	if _, ok := w.ResourceName("disk", "i"); ok {
+		t.Error("resource of another type shouldn't be found")
+	}
+	if _, ok := (&Workflow{}).ResourceName("image", "i"); ok {
+		t.Error("workflow without registries shouldn't find resources")
+	}
+}
+
 func TestResourceRegistryRegCreate(t *testing.T) {
 	rr := &baseResourceRegistry{w: testWorkflow()}
 	rr.init()
@@ -0,0 +1,118 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package daisy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// serialLogMirror copies serial port output of an instance, as it is read, to
+// a local file and/or to the follow writer.
+type serialLogMirror struct {
+	file   *os.File
+	follow io.Writer
+}
+
+// newSerialLogMirror returns a mirror of the given serial port of an instance
+// according to the SerialLogsDir and FollowInstance settings of the top-level
+// workflow, or nil if the output isn't mirrored.
+func (w *Workflow) newSerialLogMirror(ib *InstanceBase, name string, port int64) (*serialLogMirror, error) {
+	root := w
+	for root.parent != nil {
+		root = root.parent
+	}
+
+	m := &serialLogMirror{}
+	if root.SerialLogsDir != "" {
+		if err := os.MkdirAll(root.SerialLogsDir, 0755); err != nil {
+			return nil, err
+		}
+		f, err := os.Create(filepath.Join(root.SerialLogsDir, fmt.Sprintf("%s-serial-port%d.log", name, port)))
+		if err != nil {
+			return nil, err
+		}
+		m.file = f
+	}
+	if root.FollowInstance != "" && port == 1 && (root.FollowInstance == ib.daisyName || root.FollowInstance == name) {
+		out := root.FollowWriter
+		if out == nil {
+			out = os.Stdout
+		}
+		m.follow = &prefixWriter{w: out, prefix: []byte(fmt.Sprintf("[%s]: ", name))}
+	}
+	if m.file == nil && m.follow == nil {
+		return nil, nil
+	}
+	return m, nil
+}
+
+func (m *serialLogMirror) write(contents string) error {
+	if m == nil {
+		return nil
+	}
+	if m.file != nil {
+		if _, err := m.file.WriteString(contents); err != nil {
+			return err
+		}
+	}
+	if m.follow != nil {
+		if _, err := io.WriteString(m.follow, contents); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *serialLogMirror) close() error {
+	if m == nil || m.file == nil {
+		return nil
+	}
+	return m.file.Close()
+}
+
+// prefixWriter writes prefix at the start of each line.
+type prefixWriter struct {
+	mx     sync.Mutex
+	w      io.Writer
+	prefix []byte
+	// midLine is true if the last write didn't end with a newline.
+	midLine bool
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	var buf bytes.Buffer
+	for _, line := range bytes.SplitAfter(b, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if !p.midLine {
+			buf.Write(p.prefix)
+		}
+		buf.Write(line)
+		p.midLine = line[len(line)-1] != '\n'
+	}
+	if _, err := p.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
@@ -0,0 +1,91 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package daisy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	daisyCompute "github.com/GoogleCloudPlatform/compute-image-tools/daisy/compute"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/compute/v1"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	var buf bytes.Buffer
+	p := &prefixWriter{w: &buf, prefix: []byte("[i]: ")}
+	for _, s := range []string{"boot", "ing\nline 2\n", "\nline 4"} {
+		n, err := p.Write([]byte(s))
+		assert.NoError(t, err)
+		assert.Equal(t, len(s), n)
+	}
+	assert.Equal(t, "[i]: booting\n[i]: line 2\n[i]: \n[i]: line 4", buf.String())
+}
+
+func TestLogSerialOutputMirrorsLocally(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w := testWorkflow()
+	var follow bytes.Buffer
+	w.SerialLogsDir = filepath.Join(dir, "serial")
+	w.FollowInstance = "i1"
+	w.FollowWriter = &follow
+
+	responses := []string{"hello\n", "world\n"}
+	w.ComputeClient.(*daisyCompute.TestClient).GetSerialPortOutputFn = func(_, _, _ string, _, next int64) (*compute.SerialPortOutput, error) {
+		if int(next) >= len(responses) {
+			return nil, errors.New("fail")
+		}
+		return &compute.SerialPortOutput{Contents: responses[next], Next: next + 1}, nil
+	}
+	w.ComputeClient.(*daisyCompute.TestClient).InstanceStatusFn = func(_, _, _ string) (string, error) {
+		return "TERMINATED", nil
+	}
+
+	i := &Instance{Instance: compute.Instance{Name: "real-i1"}}
+	i.daisyName = "i1"
+	s := &Step{name: "foo", w: w}
+	logSerialOutput(context.Background(), s, i, &i.InstanceBase, 1, time.Microsecond)
+	logSerialOutput(context.Background(), s, i, &i.InstanceBase, 2, time.Microsecond)
+
+	for _, port := range []string{"1", "2"} {
+		b, err := ioutil.ReadFile(filepath.Join(w.SerialLogsDir, "real-i1-serial-port"+port+".log"))
+		assert.NoError(t, err)
+		assert.Equal(t, "hello\nworld\n", string(b))
+	}
+	// Only the console, serial port 1, is followed.
+	assert.Equal(t, "[real-i1]: hello\n[real-i1]: world\n", follow.String())
+}
+
+func TestNewSerialLogMirrorDisabled(t *testing.T) {
+	w := testWorkflow()
+	w.FollowInstance = "other"
+	m, err := w.newSerialLogMirror(&InstanceBase{}, "i1", 1)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+	// A nil mirror is a no-op.
+	assert.NoError(t, m.write("foo"))
+	assert.NoError(t, m.close())
+}
@@ -63,6 +63,10 @@ func (ss *Snapshot) populate(ctx context.Context, s *Step) DError {
 		ss.SourceDisk = extendPartialURL(ss.SourceDisk, ss.Project)
 	}
 
+	if key := s.w.kmsKey(); key != "" && ss.SnapshotEncryptionKey == nil {
+		ss.SnapshotEncryptionKey = &compute.CustomerEncryptionKey{KmsKeyName: key}
+	}
+
 	// This link can be modified later if disk project is different. Here it's a placeholder.
 	ss.link = fmt.Sprintf("projects/%s/global/snapshots/%s", ss.Project, ss.Name)
 	return errs
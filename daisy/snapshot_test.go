@@ -75,6 +75,20 @@ func TestSnapshotPopulate(t *testing.T) {
 	}
 }
 
+func TestSnapshotPopulateKmsKey(t *testing.T) {
+	w := testWorkflow()
+	w.KmsKey = "projects/p/locations/l/keyRings/r/cryptoKeys/workflow"
+	s, _ := w.NewStep("s")
+
+	ss := &Snapshot{Snapshot: compute.Snapshot{Name: testSnapshot, SourceDisk: "aaa"}}
+	if err := ss.populate(context.Background(), s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ss.SnapshotEncryptionKey == nil || ss.SnapshotEncryptionKey.KmsKeyName != w.KmsKey {
+		t.Errorf("snapshot should be encrypted with the workflow key, got %+v", ss.SnapshotEncryptionKey)
+	}
+}
+
 func TestSnapshotValidate(t *testing.T) {
 	ctx := context.Background()
 	w := testWorkflow()
@@ -67,6 +67,7 @@ type Step struct {
 	SubWorkflow               *SubWorkflow               `json:",omitempty"`
 	WaitForInstancesSignal    *WaitForInstancesSignal    `json:",omitempty"`
 	WaitForAnyInstancesSignal *WaitForAnyInstancesSignal `json:",omitempty"`
+	WaitForInstancesHTTP      *WaitForInstancesHTTP      `json:",omitempty"`
 	UpdateInstancesMetadata   *UpdateInstancesMetadata   `json:",omitempty"`
 	// Used for unit tests.
 	testType stepImpl
@@ -177,6 +178,10 @@ func (s *Step) stepImpl() (stepImpl, DError) {
 		matchCount++
 		result = s.WaitForAnyInstancesSignal
 	}
+	if s.WaitForInstancesHTTP != nil {
+		matchCount++
+		result = s.WaitForInstancesHTTP
+	}
 	if s.UpdateInstancesMetadata != nil {
 		matchCount++
 		result = s.UpdateInstancesMetadata
@@ -287,6 +292,15 @@ func (s *Step) recordStepTime(startTime time.Time) {
 	s.w.recordStepTime(s.name, startTime, endTime)
 }
 
+// stepTypeName returns the name of the step type implemented by impl, e.g.
+// "CreateInstances".
+func stepTypeName(impl stepImpl) string {
+	if t := reflect.TypeOf(impl); t.Kind() == reflect.Ptr {
+		return t.Elem().Name()
+	}
+	return reflect.TypeOf(impl).Name()
+}
+
 func (s *Step) run(ctx context.Context) DError {
 	startTime := time.Now()
 	defer s.recordStepTime(startTime)
@@ -294,12 +308,7 @@ func (s *Step) run(ctx context.Context) DError {
 	if err != nil {
 		return s.wrapRunError(err)
 	}
-	var st string
-	if t := reflect.TypeOf(impl); t.Kind() == reflect.Ptr {
-		st = t.Elem().Name()
-	} else {
-		st = t.Name()
-	}
+	st := stepTypeName(impl)
 	s.w.LogWorkflowInfo("Running step %q (%s)", s.name, st)
 	if err = impl.run(ctx, s); err != nil {
 		return s.wrapRunError(err)
@@ -65,6 +65,12 @@ func logSerialOutput(ctx context.Context, s *Step, ii InstanceInterface, ib *Ins
 	var numErr int
 	tick := time.Tick(interval)
 
+	mirror, err := w.newSerialLogMirror(ib, ii.getName(), port)
+	if err != nil {
+		w.LogStepInfo(s.name, "CreateInstances", "Instance %q: error mirroring serial port %d output locally: %v", ii.getName(), port, err)
+	}
+	defer mirror.close()
+
 Loop:
 	for {
 		select {
@@ -96,6 +102,10 @@ Loop:
 			numErr = 0
 			start = resp.Next
 			buf.WriteString(resp.Contents)
+			if err := mirror.write(resp.Contents); err != nil {
+				w.LogStepInfo(s.name, "CreateInstances", "Instance %q: error mirroring serial port %d output locally: %v", ii.getName(), port, err)
+				mirror = nil
+			}
 			wc := w.StorageClient.Bucket(w.bucket).Object(logsObj).NewWriter(ctx)
 			wc.ContentType = "text/plain"
 			if _, err := wc.Write(buf.Bytes()); err != nil && !gcsErr {
@@ -28,14 +28,20 @@ import (
 
 // DeleteResources deletes GCE/GCS resources.
 type DeleteResources struct {
-	Disks         []string `json:",omitempty"`
-	Images        []string `json:",omitempty"`
-	MachineImages []string `json:",omitempty"`
-	Instances     []string `json:",omitempty"`
-	Networks      []string `json:",omitempty"`
-	Subnetworks   []string `json:",omitempty"`
-	GCSPaths      []string `json:",omitempty"`
-	Firewalls     []string `json:",omitempty"`
+	Disks           []string `json:",omitempty"`
+	Images          []string `json:",omitempty"`
+	MachineImages   []string `json:",omitempty"`
+	Instances       []string `json:",omitempty"`
+	Networks        []string `json:",omitempty"`
+	Subnetworks     []string `json:",omitempty"`
+	GCSPaths        []string `json:",omitempty"`
+	Firewalls       []string `json:",omitempty"`
+	Snapshots       []string `json:",omitempty"`
+	ForwardingRules []string `json:",omitempty"`
+	TargetInstances []string `json:",omitempty"`
+	// Delete existing resources matching a label selector, these don't need
+	// to have been created in the workflow.
+	ByLabels *LabelSelector `json:",omitempty"`
 }
 
 func (d *DeleteResources) populate(ctx context.Context, s *Step) DError {
@@ -74,6 +80,21 @@ func (d *DeleteResources) populate(ctx context.Context, s *Step) DError {
 			d.Firewalls[i] = extendPartialURL(firewall, s.w.Project)
 		}
 	}
+	for i, snapshot := range d.Snapshots {
+		if snapshotURLRgx.MatchString(snapshot) {
+			d.Snapshots[i] = extendPartialURL(snapshot, s.w.Project)
+		}
+	}
+	for i, forwardingRule := range d.ForwardingRules {
+		if forwardingRuleURLRegex.MatchString(forwardingRule) {
+			d.ForwardingRules[i] = extendPartialURL(forwardingRule, s.w.Project)
+		}
+	}
+	for i, targetInstance := range d.TargetInstances {
+		if targetInstanceURLRegex.MatchString(targetInstance) {
+			d.TargetInstances[i] = extendPartialURL(targetInstance, s.w.Project)
+		}
+	}
 	return nil
 }
 
@@ -185,6 +206,33 @@ func (d *DeleteResources) validate(ctx context.Context, s *Step) DError {
 		}
 	}
 
+	// Snapshot checking.
+	for _, ss := range d.Snapshots {
+		if err := s.w.snapshots.regDelete(ss, s); d.checkError(err, s) != nil {
+			return err
+		}
+	}
+
+	// Forwarding rule checking.
+	for _, fr := range d.ForwardingRules {
+		if err := s.w.forwardingRules.regDelete(fr, s); d.checkError(err, s) != nil {
+			return err
+		}
+	}
+
+	// Target instance checking.
+	for _, ti := range d.TargetInstances {
+		if err := s.w.targetInstances.regDelete(ti, s); d.checkError(err, s) != nil {
+			return err
+		}
+	}
+
+	if d.ByLabels != nil {
+		if err := d.ByLabels.validate(); err != nil {
+			return err
+		}
+	}
+
 	// GCS path checking
 	for _, p := range d.GCSPaths {
 		bkt, _, err := splitGCSPath(p)
@@ -258,6 +306,64 @@ func (d *DeleteResources) run(ctx context.Context, s *Step) DError {
 	w := s.w
 	e := make(chan DError)
 
+	var labeled map[string][]*labeledResource
+	if d.ByLabels != nil {
+		var err DError
+		if labeled, err = d.ByLabels.list(w); err != nil {
+			return err
+		}
+		for _, t := range d.ByLabels.Types {
+			var links []string
+			for _, r := range labeled[t] {
+				links = append(links, r.link)
+			}
+			w.LogStepInfo(s.name, "DeleteResources", "%d %s selected by labels %v: %v", len(links), strings.ToLower(t), d.ByLabels.Labels, links)
+		}
+	}
+
+	// Forwarding rules reference target instances, delete them first.
+	for _, fr := range d.ForwardingRules {
+		wg.Add(1)
+		go func(fr string) {
+			defer wg.Done()
+			w.LogStepInfo(s.name, "DeleteResources", "Deleting forwarding rule %q.", fr)
+			if err := w.forwardingRules.delete(fr); err != nil {
+				if err.etype() == resourceDNEError {
+					w.LogStepInfo(s.name, "DeleteResources", "WARNING: Error deleting forwarding rule %q: %v", fr, err)
+					return
+				}
+				e <- err
+			}
+		}(fr)
+	}
+	deleteLabeled(s, &wg, e, labeled[labelSelectorForwardingRules])
+
+	if abort, ret := waitGroup(&wg, e, w); abort {
+		return ret
+	}
+
+	// Target instances reference instances, delete them before instances.
+	e = make(chan DError)
+	for _, ti := range d.TargetInstances {
+		wg.Add(1)
+		go func(ti string) {
+			defer wg.Done()
+			w.LogStepInfo(s.name, "DeleteResources", "Deleting target instance %q.", ti)
+			if err := w.targetInstances.delete(ti); err != nil {
+				if err.etype() == resourceDNEError {
+					w.LogStepInfo(s.name, "DeleteResources", "WARNING: Error deleting target instance %q: %v", ti, err)
+					return
+				}
+				e <- err
+			}
+		}(ti)
+	}
+
+	if abort, ret := waitGroup(&wg, e, w); abort {
+		return ret
+	}
+
+	e = make(chan DError)
 	for _, i := range d.Instances {
 		wg.Add(1)
 		go func(i string) {
@@ -303,6 +409,25 @@ func (d *DeleteResources) run(ctx context.Context, s *Step) DError {
 		}(i)
 	}
 
+	for _, ss := range d.Snapshots {
+		wg.Add(1)
+		go func(ss string) {
+			defer wg.Done()
+			w.LogStepInfo(s.name, "DeleteResources", "Deleting snapshot %q.", ss)
+			if err := w.snapshots.delete(ss); err != nil {
+				if err.etype() == resourceDNEError {
+					w.LogStepInfo(s.name, "DeleteResources", "WARNING: Error deleting snapshot %q: %v", ss, err)
+					return
+				}
+				e <- err
+			}
+		}(ss)
+	}
+
+	deleteLabeled(s, &wg, e, labeled[labelSelectorInstances])
+	deleteLabeled(s, &wg, e, labeled[labelSelectorImages])
+	deleteLabeled(s, &wg, e, labeled[labelSelectorSnapshots])
+
 	for _, p := range d.GCSPaths {
 		wg.Add(1)
 		go func(p string) {
@@ -350,6 +475,7 @@ func (d *DeleteResources) run(ctx context.Context, s *Step) DError {
 			}
 		}(d)
 	}
+	deleteLabeled(s, &wg, e, labeled[labelSelectorDisks])
 
 	// Delete firewalls after instance have been deleted
 	for _, n := range d.Firewalls {
@@ -18,23 +18,32 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
 	daisyCompute "github.com/GoogleCloudPlatform/compute-image-tools/daisy/compute"
 	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
 )
 
 func TestDeleteResourcesPopulate(t *testing.T) {
 	w := testWorkflow()
 	s, _ := w.NewStep("s")
 	s.DeleteResources = &DeleteResources{
-		Disks:         []string{"d", "zones/z/disks/d"},
-		Images:        []string{"i", "global/images/i"},
-		MachineImages: []string{"i", "global/machineImages/i"},
-		Instances:     []string{"i", "zones/z/instances/i"},
-		Networks:      []string{"n", "global/networks/n"},
-		Firewalls:     []string{"n", "global/firewalls/n"},
+		Disks:           []string{"d", "zones/z/disks/d"},
+		Images:          []string{"i", "global/images/i"},
+		MachineImages:   []string{"i", "global/machineImages/i"},
+		Instances:       []string{"i", "zones/z/instances/i"},
+		Networks:        []string{"n", "global/networks/n"},
+		Firewalls:       []string{"n", "global/firewalls/n"},
+		Snapshots:       []string{"ss", "global/snapshots/ss"},
+		ForwardingRules: []string{"fr", "regions/r/forwardingRules/fr"},
+		TargetInstances: []string{"ti", "zones/z/targetInstances/ti"},
+		ByLabels:        &LabelSelector{Labels: map[string]string{"k": "v"}, Types: []string{"Disks"}},
 	}
 
 	if err := (s.DeleteResources).populate(context.Background(), s); err != nil {
@@ -42,12 +51,16 @@ func TestDeleteResourcesPopulate(t *testing.T) {
 	}
 
 	want := &DeleteResources{
-		Disks:         []string{"d", fmt.Sprintf("projects/%s/zones/z/disks/d", w.Project)},
-		Images:        []string{"i", fmt.Sprintf("projects/%s/global/images/i", w.Project)},
-		MachineImages: []string{"i", fmt.Sprintf("projects/%s/global/machineImages/i", w.Project)},
-		Instances:     []string{"i", fmt.Sprintf("projects/%s/zones/z/instances/i", w.Project)},
-		Networks:      []string{"n", fmt.Sprintf("projects/%s/global/networks/n", w.Project)},
-		Firewalls:     []string{"n", fmt.Sprintf("projects/%s/global/firewalls/n", w.Project)},
+		Disks:           []string{"d", fmt.Sprintf("projects/%s/zones/z/disks/d", w.Project)},
+		Images:          []string{"i", fmt.Sprintf("projects/%s/global/images/i", w.Project)},
+		MachineImages:   []string{"i", fmt.Sprintf("projects/%s/global/machineImages/i", w.Project)},
+		Instances:       []string{"i", fmt.Sprintf("projects/%s/zones/z/instances/i", w.Project)},
+		Networks:        []string{"n", fmt.Sprintf("projects/%s/global/networks/n", w.Project)},
+		Firewalls:       []string{"n", fmt.Sprintf("projects/%s/global/firewalls/n", w.Project)},
+		Snapshots:       []string{"ss", fmt.Sprintf("projects/%s/global/snapshots/ss", w.Project)},
+		ForwardingRules: []string{"fr", fmt.Sprintf("projects/%s/regions/r/forwardingRules/fr", w.Project)},
+		TargetInstances: []string{"ti", fmt.Sprintf("projects/%s/zones/z/targetInstances/ti", w.Project)},
+		ByLabels:        &LabelSelector{Labels: map[string]string{"k": "v"}, Types: []string{"Disks"}},
 	}
 	if diffRes := diff(s.DeleteResources, want, 0); diffRes != "" {
 		t.Errorf("DeleteResources not populated as expected: (-got,+want)\n%s", diffRes)
@@ -65,21 +78,30 @@ func TestDeleteResourcesRun(t *testing.T) {
 	ds := []*Resource{{RealName: "d0", link: "link"}, {RealName: "d1", link: "link"}}
 	ns := []*Resource{{RealName: "n0", link: "link"}, {RealName: "n1", link: "link"}}
 	fs := []*Resource{{RealName: "f0", link: "link"}, {RealName: "f1", link: "link"}}
+	sss := []*Resource{{RealName: "ss0", link: "link"}, {RealName: "ss1", link: "link"}}
+	frs := []*Resource{{RealName: "fr0", link: "link"}, {RealName: "fr1", link: "link"}}
+	tis := []*Resource{{RealName: "ti0", link: "link"}, {RealName: "ti1", link: "link"}}
 	w.instances.m = map[string]*Resource{"in0": ins[0], "in1": ins[1], "in2": ins[2]}
 	w.images.m = map[string]*Resource{"im0": ims[0], "im1": ims[1]}
 	w.machineImages.m = map[string]*Resource{"mi0": mis[0], "mi1": mis[1]}
 	w.disks.m = map[string]*Resource{"d0": ds[0], "d1": ds[1]}
 	w.networks.m = map[string]*Resource{"n0": ns[0], "n1": ns[1]}
 	w.firewallRules.m = map[string]*Resource{"f0": fs[0], "f1": fs[1]}
+	w.snapshots.m = map[string]*Resource{"ss0": sss[0], "ss1": sss[1]}
+	w.forwardingRules.m = map[string]*Resource{"fr0": frs[0], "fr1": frs[1]}
+	w.targetInstances.m = map[string]*Resource{"ti0": tis[0], "ti1": tis[1]}
 
 	dr := &DeleteResources{
-		Instances:     []string{"in0"},
-		Images:        []string{"im0"},
-		MachineImages: []string{"mi0"},
-		Disks:         []string{"d0"},
-		Networks:      []string{"n0"},
-		GCSPaths:      []string{"gs://foo/bar"},
-		Firewalls:     []string{"f0"},
+		Instances:       []string{"in0"},
+		Images:          []string{"im0"},
+		MachineImages:   []string{"mi0"},
+		Disks:           []string{"d0"},
+		Networks:        []string{"n0"},
+		GCSPaths:        []string{"gs://foo/bar"},
+		Firewalls:       []string{"f0"},
+		Snapshots:       []string{"ss0"},
+		ForwardingRules: []string{"fr0"},
+		TargetInstances: []string{"ti0"},
 	}
 	if err := dr.run(ctx, s); err != nil {
 		t.Fatalf("error running DeleteResources.run(): %v", err)
@@ -102,6 +124,12 @@ func TestDeleteResourcesRun(t *testing.T) {
 		{ns[1], false},
 		{fs[0], true},
 		{fs[1], false},
+		{sss[0], true},
+		{sss[1], false},
+		{frs[0], true},
+		{frs[1], false},
+		{tis[0], true},
+		{tis[1], false},
 	}
 	for _, c := range deletedChecks {
 		if c.shouldBeDeleted {
@@ -260,3 +288,130 @@ func TestDeleteResourcesValidate(t *testing.T) {
 	want[5].deleter = otherDeleter
 	CompareResources(got, want)
 }
+
+func TestDeleteResourcesValidateSnapshotsForwardingRulesTargetInstances(t *testing.T) {
+	ctx := context.Background()
+	w := testWorkflow()
+	ssC, _ := w.NewStep("ssCreator")
+	frC, _ := w.NewStep("frCreator")
+	tiC, _ := w.NewStep("tiCreator")
+	s, _ := w.NewStep("s")
+	w.AddDependency(s, ssC, frC, tiC)
+	independent, _ := w.NewStep("independent")
+	sss := []*Resource{{RealName: "ss0", link: "link", creator: ssC}, {RealName: "ss1", link: "link", creator: ssC}}
+	frs := []*Resource{{RealName: "fr0", link: "link", creator: frC}}
+	tis := []*Resource{{RealName: "ti0", link: "link", creator: tiC}}
+	w.snapshots.m = map[string]*Resource{"ss0": sss[0], "ss1": sss[1]}
+	w.forwardingRules.m = map[string]*Resource{"fr0": frs[0]}
+	w.targetInstances.m = map[string]*Resource{"ti0": tis[0]}
+
+	dr := &DeleteResources{Snapshots: []string{"ss0"}, ForwardingRules: []string{"fr0"}, TargetInstances: []string{"ti0"}}
+	if err := dr.validate(ctx, s); err != nil {
+		t.Errorf("validation should not have failed: %v", err)
+	}
+	for _, r := range []*Resource{sss[0], frs[0], tis[0]} {
+		if r.deleter != s {
+			t.Errorf("resource %q should have been registered for deletion", r.RealName)
+		}
+	}
+
+	// Bad cases: already deleted, DNE, no dependency on the creator.
+	if err := (&DeleteResources{Snapshots: []string{"ss0"}}).validate(ctx, s); err == nil {
+		t.Error("DeleteResources should have returned an error when deleting an already deleted snapshot")
+	}
+	if err := (&DeleteResources{ForwardingRules: []string{"dne"}}).validate(ctx, s); err == nil {
+		t.Error("DeleteResources should have returned an error when deleting a forwarding rule that DNE")
+	}
+	if err := (&DeleteResources{Snapshots: []string{"ss1"}}).validate(ctx, independent); err == nil {
+		t.Error("DeleteResources should have returned an error when not depending on the snapshot creator")
+	}
+	if err := (&DeleteResources{ByLabels: &LabelSelector{Types: []string{"Disks"}}}).validate(ctx, s); err == nil {
+		t.Error("DeleteResources should have returned an error when selecting by no labels")
+	}
+	if err := (&DeleteResources{ByLabels: &LabelSelector{Labels: map[string]string{"": "v"}, Types: []string{"Disks"}}}).validate(ctx, s); err == nil {
+		t.Error("DeleteResources should have returned an error when selecting by an empty label key")
+	}
+	if err := (&DeleteResources{ByLabels: &LabelSelector{Labels: map[string]string{"k": "v"}, Types: []string{"Networks"}}}).validate(ctx, s); err == nil {
+		t.Error("DeleteResources should have returned an error when selecting an unsupported type by labels")
+	}
+}
+
+func TestDeleteResourcesRunByLabels(t *testing.T) {
+	ctx := context.Background()
+	w := testWorkflow()
+	s, _ := w.NewStep("s")
+	tc := w.ComputeClient.(*daisyCompute.TestClient)
+
+	var filters []string
+	var deleted []string
+	var mx sync.Mutex
+	record := func(kind, name string) error {
+		mx.Lock()
+		defer mx.Unlock()
+		deleted = append(deleted, kind+"/"+name)
+		return nil
+	}
+	filterOf := func(opts []daisyCompute.ListCallOption) {
+		mx.Lock()
+		defer mx.Unlock()
+		for _, o := range opts {
+			if f, ok := o.(daisyCompute.Filter); ok {
+				filters = append(filters, string(f))
+			}
+		}
+	}
+	var scopes []string
+	tc.ListInstancesFn = func(p, z string, opts ...daisyCompute.ListCallOption) ([]*compute.Instance, error) {
+		filterOf(opts)
+		scopes = append(scopes, p+"/"+z)
+		return []*compute.Instance{{Name: "in0"}}, nil
+	}
+	tc.ListDisksFn = func(p, z string, opts ...daisyCompute.ListCallOption) ([]*compute.Disk, error) {
+		filterOf(opts)
+		scopes = append(scopes, p+"/"+z)
+		return []*compute.Disk{{Name: "d0"}, {Name: "d1"}}, nil
+	}
+	tc.ListSnapshotsFn = func(_ string, opts ...daisyCompute.ListCallOption) ([]*compute.Snapshot, error) {
+		filterOf(opts)
+		return []*compute.Snapshot{{Name: "ss0"}}, nil
+	}
+	tc.DeleteInstanceFn = func(_, _, n string) error { return record("instances", n) }
+	tc.DeleteDiskFn = func(_, _, n string) error {
+		if n == "d1" {
+			return &googleapi.Error{Code: http.StatusNotFound}
+		}
+		return record("disks", n)
+	}
+	tc.DeleteSnapshotFn = func(_, n string) error { return record("snapshots", n) }
+
+	dr := &DeleteResources{ByLabels: &LabelSelector{
+		Labels: map[string]string{"team": "images", "env": "test"},
+		Types:  []string{"Instances", "Disks", "Snapshots"},
+	}}
+	if err := dr.run(ctx, s); err != nil {
+		t.Fatalf("error running DeleteResources.run(): %v", err)
+	}
+	sort.Strings(deleted)
+	if want := []string{"disks/d0", "instances/in0", "snapshots/ss0"}; !reflect.DeepEqual(deleted, want) {
+		t.Errorf("unexpected deletions, got: %v, want: %v", deleted, want)
+	}
+	for _, sc := range scopes {
+		if want := testProject + "/" + testZone; sc != want {
+			t.Errorf("resources listed outside of the workflow project and zone, got: %q, want: %q", sc, want)
+		}
+	}
+	wantFilter := `(labels.env = "test") (labels.team = "images")`
+	for _, f := range filters {
+		if f != wantFilter {
+			t.Errorf("unexpected filter, got: %q, want: %q", f, wantFilter)
+		}
+	}
+
+	// Listing error.
+	tc.ListDisksFn = func(_, _ string, _ ...daisyCompute.ListCallOption) ([]*compute.Disk, error) {
+		return nil, errors.New("fail")
+	}
+	if err := dr.run(ctx, s); err == nil {
+		t.Error("run should have failed with a listing error")
+	}
+}
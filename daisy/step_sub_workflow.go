@@ -78,11 +78,7 @@ func (s *SubWorkflow) run(ctx context.Context, st *Step) DError {
 
 	swCleanup := func() {
 		s.Workflow.LogWorkflowInfo("SubWorkflow %q cleaning up (this may take up to 2 minutes).", s.Workflow.Name)
-		for _, hook := range s.Workflow.cleanupHooks {
-			if err := hook(); err != nil {
-				s.Workflow.LogWorkflowInfo("Error returned from SubWorkflow cleanup hook: %s", err)
-			}
-		}
+		s.Workflow.runCleanupHooks(s.Workflow.cleanupHooks, "Error returned from SubWorkflow cleanup hook")
 	}
 
 	defer swCleanup()
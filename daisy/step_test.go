@@ -210,6 +210,10 @@ func TestStepImpl(t *testing.T) {
 			Step{WaitForInstancesSignal: &WaitForInstancesSignal{}},
 			reflect.TypeOf(&WaitForInstancesSignal{}),
 		},
+		{
+			Step{WaitForInstancesHTTP: &WaitForInstancesHTTP{}},
+			reflect.TypeOf(&WaitForInstancesHTTP{}),
+		},
 	}
 
 	for _, tt := range tests {
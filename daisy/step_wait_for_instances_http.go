@@ -0,0 +1,261 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package daisy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHTTPPort           = 80
+	defaultHTTPPath           = "/"
+	defaultHTTPScheme         = "http"
+	defaultHTTPRequestTimeout = "5s"
+	maxHTTPBodySize           = 1 << 20
+)
+
+// WaitForInstancesHTTP is a Daisy WaitForInstancesHTTP workflow step.
+type WaitForInstancesHTTP []*InstanceHTTPSignal
+
+// InstanceHTTPSignal waits for an HTTP endpoint served by an instance to
+// become ready.
+// The instance's IP is resolved from the instance registry, then the URL is
+// polled until the response has StatusCode and a body matching SuccessMatch.
+// A body containing any of FailureMatch will cause the step to fail.
+type InstanceHTTPSignal struct {
+	// Instance name to wait for.
+	Name string
+	// Interval to poll the endpoint (default is 10s).
+	// Must be parsable by https://golang.org/pkg/time/#ParseDuration.
+	Interval string `json:",omitempty"`
+	interval time.Duration
+	// Time to wait for a single request to complete (default is 5s).
+	// Must be parsable by https://golang.org/pkg/time/#ParseDuration.
+	RequestTimeout string `json:",omitempty"`
+	requestTimeout time.Duration
+	// Scheme of the URL, "http" or "https" (default is "http").
+	Scheme string `json:",omitempty"`
+	// Port to connect to (default is 80).
+	Port int64 `json:",omitempty"`
+	// Path of the URL (default is "/").
+	Path string `json:",omitempty"`
+	// Connect to the instance's external IP instead of its internal IP.
+	ExternalIP bool `json:",omitempty"`
+	// Expected status code of the response (default is 200).
+	StatusCode int `json:",omitempty"`
+	// Regular expression the response body must match.
+	SuccessMatch string `json:",omitempty"`
+	successMatch *regexp.Regexp
+	// Strings which cause the step to fail when found in the response body.
+	FailureMatch FailureMatches `json:"failureMatch,omitempty"`
+}
+
+func (is *InstanceHTTPSignal) url(ip string) string {
+	return fmt.Sprintf("%s://%s%s", is.Scheme, net.JoinHostPort(ip, strconv.FormatInt(is.Port, 10)), is.Path)
+}
+
+func instanceIP(s *Step, project, zone, name string, external bool) (string, error) {
+	i, err := s.w.ComputeClient.GetInstance(project, zone, name)
+	if err != nil {
+		return "", err
+	}
+	for _, ni := range i.NetworkInterfaces {
+		if !external {
+			if ni.NetworkIP != "" {
+				return ni.NetworkIP, nil
+			}
+			continue
+		}
+		for _, ac := range ni.AccessConfigs {
+			if ac.NatIP != "" {
+				return ac.NatIP, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+func waitForHTTP(s *Step, project, zone, name string, is *InstanceHTTPSignal) DError {
+	w := s.w
+	msg := fmt.Sprintf("Instance %q: polling %s port %d path %q, StatusCode: %d", name, is.Scheme, is.Port, is.Path, is.StatusCode)
+	if is.SuccessMatch != "" {
+		msg += fmt.Sprintf(", SuccessMatch: %q", is.SuccessMatch)
+	}
+	if len(is.FailureMatch) > 0 {
+		msg += fmt.Sprintf(", FailureMatch: %q (this is not an error)", is.FailureMatch)
+	}
+	w.LogStepInfo(s.name, "WaitForInstancesHTTP", msg+".")
+	client := &http.Client{Timeout: is.requestTimeout}
+	var ip string
+	var errs int
+	tick := time.Tick(is.interval)
+	for {
+		select {
+		case <-s.w.Cancel:
+			return nil
+		case <-tick:
+			if ip == "" {
+				var err error
+				ip, err = instanceIP(s, project, zone, name, is.ExternalIP)
+				if err != nil {
+					// Retry up to 3 times in a row on any error.
+					if errs < 3 {
+						errs++
+						continue
+					}
+					return Errf("WaitForInstancesHTTP: instance %q: error getting instance: %v", name, err)
+				}
+				errs = 0
+				if ip == "" {
+					// The instance may not have been assigned an address yet.
+					continue
+				}
+				w.LogStepInfo(s.name, "WaitForInstancesHTTP", "Instance %q: polling %s", name, is.url(ip))
+			}
+
+			// Request errors are expected while the instance boots, keep polling.
+			resp, err := client.Get(is.url(ip))
+			if err != nil {
+				continue
+			}
+			body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxHTTPBodySize))
+			resp.Body.Close()
+			if err != nil {
+				continue
+			}
+			for _, failureMatch := range is.FailureMatch {
+				if i := strings.Index(string(body), failureMatch); i != -1 {
+					errMsg := strings.TrimSpace(firstLine(string(body[i:])))
+					format := "WaitForInstancesHTTP FailureMatch found for %q: %q"
+					return newErr(errMsg, fmt.Errorf(format, name, errMsg))
+				}
+			}
+			if resp.StatusCode != is.StatusCode {
+				continue
+			}
+			if is.successMatch != nil && !is.successMatch.Match(body) {
+				continue
+			}
+			w.LogStepInfo(s.name, "WaitForInstancesHTTP", "Instance %q: endpoint ready, status %d", name, resp.StatusCode)
+			return nil
+		}
+	}
+}
+
+func firstLine(s string) string {
+	if i := strings.Index(s, "\n"); i != -1 {
+		return s[:i]
+	}
+	return s
+}
+
+func (w *WaitForInstancesHTTP) populate(ctx context.Context, s *Step) DError {
+	for _, is := range *w {
+		if is.Interval == "" {
+			is.Interval = defaultInterval
+		}
+		if is.RequestTimeout == "" {
+			is.RequestTimeout = defaultHTTPRequestTimeout
+		}
+		var err error
+		is.interval, err = time.ParseDuration(is.Interval)
+		if err != nil {
+			return newErr("failed to parse duration for step wait_for_instances_http", err)
+		}
+		is.requestTimeout, err = time.ParseDuration(is.RequestTimeout)
+		if err != nil {
+			return newErr("failed to parse request timeout for step wait_for_instances_http", err)
+		}
+		is.Scheme = strings.ToLower(strOr(is.Scheme, defaultHTTPScheme))
+		is.Path = strOr(is.Path, defaultHTTPPath)
+		if !strings.HasPrefix(is.Path, "/") {
+			is.Path = "/" + is.Path
+		}
+		if is.Port == 0 {
+			is.Port = defaultHTTPPort
+		}
+		if is.StatusCode == 0 {
+			is.StatusCode = http.StatusOK
+		}
+		if is.SuccessMatch != "" {
+			is.successMatch, err = regexp.Compile(is.SuccessMatch)
+			if err != nil {
+				return newErr("failed to parse SuccessMatch for step wait_for_instances_http", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (w *WaitForInstancesHTTP) run(ctx context.Context, s *Step) DError {
+	var wg sync.WaitGroup
+	e := make(chan DError)
+	for _, is := range *w {
+		wg.Add(1)
+		go func(is *InstanceHTTPSignal) {
+			defer wg.Done()
+			i, ok := s.w.instances.get(is.Name)
+			if !ok {
+				e <- Errf("unresolved instance %q", is.Name)
+				return
+			}
+			m := NamedSubexp(instanceURLRgx, i.link)
+			if err := waitForHTTP(s, m["project"], m["zone"], m["instance"], is); err != nil {
+				e <- err
+			}
+		}(is)
+	}
+	go func() {
+		wg.Wait()
+		e <- nil
+	}()
+	select {
+	case err := <-e:
+		return err
+	case <-s.w.Cancel:
+		return nil
+	}
+}
+
+func (w *WaitForInstancesHTTP) validate(ctx context.Context, s *Step) DError {
+	for _, i := range *w {
+		if _, err := s.w.instances.regUse(i.Name, s); err != nil {
+			return err
+		}
+		if i.interval == 0*time.Second {
+			return Errf("%q: cannot wait for instance HTTP endpoint, no interval given", i.Name)
+		}
+		if i.Scheme != "http" && i.Scheme != "https" {
+			return Errf("%q: cannot wait for instance HTTP endpoint, unsupported scheme %q", i.Name, i.Scheme)
+		}
+		if i.Port < 1 || i.Port > 65535 {
+			return Errf("%q: cannot wait for instance HTTP endpoint, invalid port %d", i.Name, i.Port)
+		}
+		if i.StatusCode < 100 || i.StatusCode > 599 {
+			return Errf("%q: cannot wait for instance HTTP endpoint, invalid StatusCode %d", i.Name, i.StatusCode)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package daisy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	daisyCompute "github.com/GoogleCloudPlatform/compute-image-tools/daisy/compute"
+	compute "google.golang.org/api/compute/v1"
+)
+
+func TestWaitForInstancesHTTPPopulate(t *testing.T) {
+	got := &WaitForInstancesHTTP{{Name: "test", SuccessMatch: "ok.*"}, {Name: "test2", Scheme: "HTTPS", Port: 8443, Path: "healthz", StatusCode: 204}}
+	if err := got.populate(context.Background(), &Step{}); err != nil {
+		t.Fatalf("error running populate: %v", err)
+	}
+
+	want := &WaitForInstancesHTTP{
+		{Name: "test", Interval: "10s", interval: 10 * time.Second, RequestTimeout: "5s", requestTimeout: 5 * time.Second,
+			Scheme: "http", Port: 80, Path: "/", StatusCode: 200, SuccessMatch: "ok.*", successMatch: regexp.MustCompile("ok.*")},
+		{Name: "test2", Interval: "10s", interval: 10 * time.Second, RequestTimeout: "5s", requestTimeout: 5 * time.Second,
+			Scheme: "https", Port: 8443, Path: "/healthz", StatusCode: 204},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got != want:\ngot:  %+v\nwant: %+v", got, want)
+	}
+
+	bad := &WaitForInstancesHTTP{{Name: "test", SuccessMatch: "("}}
+	if err := bad.populate(context.Background(), &Step{}); err == nil {
+		t.Error("expected error for invalid SuccessMatch")
+	}
+}
+
+func TestWaitForInstancesHTTPRun(t *testing.T) {
+	ctx := context.Background()
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ready":
+			fmt.Fprint(w, `{"status":"ready"}`)
+		case "/failed":
+			fmt.Fprint(w, "booting\nstartup failed: disk not found\n")
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, "ready")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer svr.Close()
+	host, portStr, err := net.SplitHostPort(svr.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ := strconv.ParseInt(portStr, 10, 64)
+
+	w := testWorkflow()
+	w.ComputeClient.(*daisyCompute.TestClient).GetInstanceFn = func(_, _, n string) (*compute.Instance, error) {
+		switch n {
+		case w.genName("internal"):
+			return &compute.Instance{NetworkInterfaces: []*compute.NetworkInterface{{NetworkIP: host}}}, nil
+		case w.genName("external"):
+			return &compute.Instance{NetworkInterfaces: []*compute.NetworkInterface{{NetworkIP: "10.0.0.2", AccessConfigs: []*compute.AccessConfig{{NatIP: host}}}}}, nil
+		}
+		return nil, errors.New("fail")
+	}
+	s := &Step{w: w}
+	w.instances.m = map[string]*Resource{
+		"internal": {link: fmt.Sprintf("projects/%s/zones/%s/instances/%s", testProject, testZone, w.genName("internal"))},
+		"external": {link: fmt.Sprintf("projects/%s/zones/%s/instances/%s", testProject, testZone, w.genName("external"))},
+		"broken":   {link: fmt.Sprintf("projects/%s/zones/%s/instances/%s", testProject, testZone, w.genName("broken"))},
+	}
+	newSignal := func(name, path string) *InstanceHTTPSignal {
+		return &InstanceHTTPSignal{Name: name, interval: 1 * time.Microsecond, requestTimeout: 1 * time.Second,
+			Scheme: "http", Port: port, Path: path, StatusCode: http.StatusOK}
+	}
+
+	// Normal run, no error.
+	external := newSignal("external", "/ready")
+	external.ExternalIP = true
+	external.successMatch = regexp.MustCompile(`"status":\s*"ready"`)
+	ws := &WaitForInstancesHTTP{newSignal("internal", "/ready"), external}
+	if err := ws.run(ctx, s); err != nil {
+		t.Errorf("error running stepImpl.run(): %v", err)
+	}
+
+	// Failure match error.
+	failed := newSignal("internal", "/failed")
+	failed.FailureMatch = []string{"startup failed"}
+	ws = &WaitForInstancesHTTP{failed}
+	want := fmt.Sprintf("WaitForInstancesHTTP FailureMatch found for %q: %q", w.genName("internal"), "startup failed: disk not found")
+	if err := ws.run(ctx, s); err == nil || err.Error() != want {
+		t.Errorf("did not get expected error, got: %v, want: %q", err, want)
+	}
+
+	// Error from GetInstance.
+	ws = &WaitForInstancesHTTP{newSignal("broken", "/ready")}
+	if err := ws.run(ctx, s); err == nil {
+		t.Error("expected error")
+	}
+
+	// Unexpected status code keeps polling until cancelled.
+	ws = &WaitForInstancesHTTP{newSignal("internal", "/unavailable")}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(w.Cancel)
+	}()
+	if err := ws.run(ctx, s); err != nil {
+		t.Errorf("unexpected error after cancel: %v", err)
+	}
+
+	// Unresolved instance error.
+	w = testWorkflow()
+	ws = &WaitForInstancesHTTP{newSignal("i7", "/ready")}
+	want = "unresolved instance \"i7\""
+	if err := ws.run(ctx, &Step{w: w}); err == nil || err.Error() != want {
+		t.Errorf("did not get expected error, got: %v, want: %q", err, want)
+	}
+}
+
+func TestWaitForInstancesHTTPValidate(t *testing.T) {
+	// Set up.
+	w := testWorkflow()
+	s, _ := w.NewStep("s")
+	iCreator, _ := w.NewStep("iCreator")
+	iCreator.CreateInstances = &CreateInstances{Instances: []*Instance{&Instance{}}}
+	w.AddDependency(s, iCreator)
+	if err := w.instances.regCreate("instance1", &Resource{link: fmt.Sprintf("projects/%s/zones/%s/disks/d", testProject, testZone)}, false, iCreator); err != nil {
+		t.Fatal(err)
+	}
+
+	valid := func() *InstanceHTTPSignal {
+		return &InstanceHTTPSignal{Name: "instance1", interval: 1 * time.Second, Scheme: "http", Port: 80, StatusCode: 200}
+	}
+	tests := []struct {
+		desc      string
+		modify    func(*InstanceHTTPSignal)
+		shouldErr bool
+	}{
+		{"normal case", func(*InstanceHTTPSignal) {}, false},
+		{"https", func(i *InstanceHTTPSignal) { i.Scheme = "https" }, false},
+		{"instance DNE error check", func(i *InstanceHTTPSignal) { i.Name = "instance2" }, true},
+		{"no interval", func(i *InstanceHTTPSignal) { i.interval = 0 }, true},
+		{"bad scheme", func(i *InstanceHTTPSignal) { i.Scheme = "ftp" }, true},
+		{"bad port", func(i *InstanceHTTPSignal) { i.Port = 70000 }, true},
+		{"bad status code", func(i *InstanceHTTPSignal) { i.StatusCode = 42 }, true},
+	}
+
+	for _, tt := range tests {
+		is := valid()
+		tt.modify(is)
+		step := &WaitForInstancesHTTP{is}
+		if err := step.validate(context.Background(), s); (err != nil) != tt.shouldErr {
+			t.Errorf("fail: %s; step: %+v; error result: %s", tt.desc, is, err)
+		}
+	}
+}
@@ -27,7 +27,7 @@ import (
 )
 
 var (
-	targetInstanceURLRegex = regexp.MustCompile(fmt.Sprintf(`^(projects/(?P<project>%[1]s)/)?zones/(?P<zone>%[2]s)/TargetInstances/(?P<targetInstance>%[2]s)$`, projectRgxStr, rfc1035))
+	targetInstanceURLRegex = regexp.MustCompile(fmt.Sprintf(`^(projects/(?P<project>%[1]s)/)?zones/(?P<zone>%[2]s)/targetInstances/(?P<targetInstance>%[2]s)$`, projectRgxStr, rfc1035))
 )
 
 func (w *Workflow) targetInstanceExists(project, zone, targetInstance string) (bool, DError) {
@@ -58,7 +58,7 @@ func (ti *TargetInstance) populate(ctx context.Context, s *Step) DError {
 	}
 
 	ti.Description = strOr(ti.Description, defaultDescription("TargetInstance", s.w.Name, s.w.username))
-	ti.link = fmt.Sprintf("projects/%s/zones/%s/TargetInstances/%s", ti.Project, ti.Zone, ti.Name)
+	ti.link = fmt.Sprintf("projects/%s/zones/%s/targetInstances/%s", ti.Project, ti.Zone, ti.Name)
 	return errs
 }
 
@@ -0,0 +1,66 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package daisy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"google.golang.org/api/compute/v1"
+)
+
+func TestTargetInstancePopulateLink(t *testing.T) {
+	w := testWorkflow()
+	s, _ := w.NewStep("s")
+
+	ti := &TargetInstance{
+		Resource:       Resource{ExactName: true},
+		TargetInstance: compute.TargetInstance{Name: "ti", Instance: "i"},
+	}
+	if err := ti.populate(context.Background(), s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := fmt.Sprintf("projects/%s/zones/%s/targetInstances/ti", testProject, testZone)
+	if ti.link != want {
+		t.Errorf("unexpected link, got: %q, want: %q", ti.link, want)
+	}
+	if !targetInstanceURLRegex.MatchString(ti.link) {
+		t.Errorf("link %q does not match targetInstanceURLRegex", ti.link)
+	}
+}
+
+func TestTargetInstanceURLRegex(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"projects/p/zones/z/targetInstances/ti", true},
+		{"zones/z/targetInstances/ti", true},
+		{"projects/p/zones/z/TargetInstances/ti", false},
+		{"projects/p/zones/z/instances/ti", false},
+	}
+
+	for _, tt := range tests {
+		if got := targetInstanceURLRegex.MatchString(tt.url); got != tt.want {
+			t.Errorf("%q: got match %t, want %t", tt.url, got, tt.want)
+		}
+	}
+	m := NamedSubexp(targetInstanceURLRegex, "projects/p/zones/z/targetInstances/ti")
+	if m["project"] != "p" || m["zone"] != "z" || m["targetInstance"] != "ti" {
+		t.Errorf("unexpected subexpressions: %v", m)
+	}
+}
@@ -306,7 +306,7 @@ func newTestGCSClient() (*storage.Client, error) {
 	getObjRgx := regexp.MustCompile(`/b/.+/o/.+alt=json&projection=full`)
 	getBktRgx := regexp.MustCompile(`/b/.+alt=json&prettyPrint=false&projection=full`)
 	deleteObjRgx := regexp.MustCompile(`/b/.+/o/.+alt=json`)
-	listObjsRgx := regexp.MustCompile(`/b/.+/o\?alt=json&delimiter=&endOffset=&pageToken=&prefix=.+&projection=full.+`)
+	listObjsRgx := regexp.MustCompile(`/b/.+/o\?alt=json&delimiter=&endOffset=&includeTrailingDelimiter=false&pageToken=&prefix=.+&projection=full.+`)
 	listObjsNoPrefixRgx := regexp.MustCompile(`/b/.+/o\?alt=json&delimiter=&pageToken=&prefix=&projection=full&versions=false`)
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
@@ -378,13 +378,13 @@ func newTestGCSClient() (*storage.Client, error) {
 			fmt.Fprint(w, `{}`)
 		} else if m == "GET" && u == "/b?alt=json&pageToken=&prefix=&prettyPrint=false&project=bar-project&projection=full" {
 			fmt.Fprint(w, `{"items": [{"name": "bar-project-daisy-bkt"}]}`)
-		} else if m == "GET" && u == "/b/gcs/o?alt=json&delimiter=&endOffset=&pageToken=&prefix=folder%2F&prettyPrint=false&projection=full&startOffset=&versions=false" {
+		} else if m == "GET" && u == "/b/gcs/o?alt=json&delimiter=&endOffset=&includeTrailingDelimiter=false&pageToken=&prefix=folder%2F&prettyPrint=false&projection=full&startOffset=&versions=false" {
 			fmt.Fprint(w, `{}`)
-		} else if m == "GET" && u == "/b/bucket/o?alt=json&delimiter=&endOffset=&pageToken=&prefix=object%2F&prettyPrint=false&projection=full&startOffset=&versions=false" {
+		} else if m == "GET" && u == "/b/bucket/o?alt=json&delimiter=&endOffset=&includeTrailingDelimiter=false&pageToken=&prefix=object%2F&prettyPrint=false&projection=full&startOffset=&versions=false" {
 			fmt.Fprint(w, `{}`)
-		} else if m == "GET" && u == "/b/bucket/o?alt=json&delimiter=&endOffset=&pageToken=&prefix=&prettyPrint=false&projection=full&startOffset=&versions=false" {
+		} else if m == "GET" && u == "/b/bucket/o?alt=json&delimiter=&endOffset=&includeTrailingDelimiter=false&pageToken=&prefix=&prettyPrint=false&projection=full&startOffset=&versions=false" {
 			fmt.Fprint(w, `{}`)
-		} else if m == "GET" && u == "/b/foo/o?alt=json&delimiter=&endOffset=&pageToken=&prefix=bar&prettyPrint=false&projection=full&startOffset=&versions=false" {
+		} else if m == "GET" && u == "/b/foo/o?alt=json&delimiter=&endOffset=&includeTrailingDelimiter=false&pageToken=&prefix=bar&prettyPrint=false&projection=full&startOffset=&versions=false" {
 			fmt.Fprint(w, `{}`)
 		} else if m == "POST" && u == "/b?alt=json&prettyPrint=false&project=foo-project" {
 			fmt.Fprint(w, `{}`)
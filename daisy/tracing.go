@@ -0,0 +1,195 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package daisy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/GoogleCloudPlatform/compute-image-tools/daisy"
+
+// Span attributes set by daisy.
+const (
+	WorkflowNameKey     = attribute.Key("daisy.workflow.name")
+	WorkflowIDKey       = attribute.Key("daisy.workflow.id")
+	ProjectKey          = attribute.Key("daisy.project")
+	ZoneKey             = attribute.Key("daisy.zone")
+	StepNameKey         = attribute.Key("daisy.step.name")
+	StepTypeKey         = attribute.Key("daisy.step.type")
+	ResourcesCreatedKey = attribute.Key("daisy.resources.created")
+	ResourcesDeletedKey = attribute.Key("daisy.resources.deleted")
+)
+
+// Trace exporters supported by NewTracerProvider.
+const (
+	TraceExporterNone   = "none"
+	TraceExporterStdout = "stdout"
+	TraceExporterFile   = "file"
+)
+
+// NewTracerProvider creates a TracerProvider exporting spans with the named
+// exporter: "none" (or empty), "stdout", or "file", which appends spans as
+// JSON to path, so that processes can share it. The returned shutdown func flushes any buffered spans and must be
+// called before exiting. For "none" the TracerProvider is nil.
+func NewTracerProvider(exporter, path string) (trace.TracerProvider, func(context.Context) error, error) {
+	var w io.Writer
+	closeFn := func() error { return nil }
+	switch exporter {
+	case "", TraceExporterNone:
+		return nil, func(context.Context) error { return nil }, nil
+	case TraceExporterStdout:
+		w = os.Stdout
+	case TraceExporterFile:
+		if path == "" {
+			return nil, nil, fmt.Errorf("a file path is required for the %q trace exporter", exporter)
+		}
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error opening trace file: %v", err)
+		}
+		w, closeFn = f, f.Close
+	default:
+		return nil, nil, fmt.Errorf("unknown trace exporter %q, must be one of %q, %q or %q", exporter, TraceExporterNone, TraceExporterStdout, TraceExporterFile)
+	}
+
+	exp, err := stdouttrace.New(stdouttrace.WithWriter(w))
+	if err != nil {
+		closeFn()
+		return nil, nil, err
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp))
+	return tp, func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if cErr := closeFn(); err == nil {
+			err = cErr
+		}
+		return err
+	}, nil
+}
+
+// tracerProvider returns the TracerProvider of the top-level workflow, or the
+// global TracerProvider if it isn't set.
+func (w *Workflow) tracerProvider() trace.TracerProvider {
+	for cur := w; cur != nil; cur = cur.parent {
+		if cur.TracerProvider != nil {
+			return cur.TracerProvider
+		}
+	}
+	return otel.GetTracerProvider()
+}
+
+func (w *Workflow) tracer() trace.Tracer {
+	return w.tracerProvider().Tracer(tracerName)
+}
+
+// traceContext returns the context of the top-level workflow span, which
+// Compute API call spans are children of.
+func (w *Workflow) traceContext() context.Context {
+	root := w
+	for root.parent != nil {
+		root = root.parent
+	}
+	if root.traceCtx == nil {
+		return context.Background()
+	}
+	return root.traceCtx
+}
+
+func (w *Workflow) startSpan(ctx context.Context) (context.Context, trace.Span) {
+	return w.tracer().Start(ctx, "daisy.Workflow "+w.Name, trace.WithAttributes(
+		WorkflowNameKey.String(w.Name),
+		WorkflowIDKey.String(w.id),
+		ProjectKey.String(w.Project),
+		ZoneKey.String(w.Zone),
+	))
+}
+
+func (s *Step) startSpan(ctx context.Context) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{StepNameKey.String(s.name), WorkflowNameKey.String(s.w.Name)}
+	if impl, err := s.stepImpl(); err == nil {
+		attrs = append(attrs, StepTypeKey.String(stepTypeName(impl)))
+	}
+	return s.w.tracer().Start(ctx, "daisy.Step "+s.name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records the resources a step created and deleted, and err if set,
+// on span before ending it.
+func (s *Step) endSpan(span trace.Span, err DError) {
+	created, deleted := s.w.resourceLinks(s)
+	if len(created) > 0 {
+		span.SetAttributes(ResourcesCreatedKey.StringSlice(created))
+	}
+	if len(deleted) > 0 {
+		span.SetAttributes(ResourcesDeletedKey.StringSlice(deleted))
+	}
+	endSpan(span, err)
+}
+
+func endSpan(span trace.Span, err DError) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// resourceLinks returns the links of the resources created and deleted by s.
+func (w *Workflow) resourceLinks(s *Step) (created, deleted []string) {
+	if w.disks == nil {
+		// Registries aren't initialized.
+		return nil, nil
+	}
+	for _, r := range w.registries() {
+		r.mx.Lock()
+		for _, res := range r.m {
+			if res.creator == s {
+				created = append(created, res.link)
+			}
+			if res.deleter == s {
+				deleted = append(deleted, res.link)
+			}
+		}
+		r.mx.Unlock()
+	}
+	sort.Strings(created)
+	sort.Strings(deleted)
+	return created, deleted
+}
+
+// runCleanupHooks runs hooks, each in its own span, logging any errors with
+// errPrefix.
+func (w *Workflow) runCleanupHooks(hooks []func() DError, errPrefix string) {
+	ctx, span := w.tracer().Start(w.traceContext(), "daisy.Cleanup "+w.Name, trace.WithAttributes(WorkflowNameKey.String(w.Name)))
+	defer span.End()
+	for i, hook := range hooks {
+		_, hookSpan := w.tracer().Start(ctx, fmt.Sprintf("daisy.CleanupHook %d", i))
+		err := hook()
+		if err != nil {
+			w.LogWorkflowInfo("%s: %s", errPrefix, err)
+		}
+		endSpan(hookSpan, err)
+	}
+}
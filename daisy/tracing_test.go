@@ -0,0 +1,146 @@
+//  Copyright 2021 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package daisy
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func spanAttr(s tracetest.SpanStub, k attribute.Key) attribute.Value {
+	for _, kv := range s.Attributes {
+		if kv.Key == k {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestWorkflowTracing(t *testing.T) {
+	ctx := context.Background()
+	exp := tracetest.NewInMemoryExporter()
+	w := testWorkflow()
+	w.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+
+	create, _ := w.NewStep("create")
+	link := fmt.Sprintf("projects/%s/zones/%s/disks/%s", testProject, testZone, "traced-disk")
+	create.testType = &mockStep{validateImpl: func(ctx context.Context, s *Step) DError {
+		return w.disks.regCreate("traced-disk", &Resource{link: link}, s, false)
+	}}
+	fail, _ := w.NewStep("fail")
+	fail.testType = &mockStep{runImpl: func(ctx context.Context, s *Step) DError {
+		return Errf("failure")
+	}}
+	w.AddDependency(fail, create)
+
+	if err := w.Run(ctx); err == nil {
+		t.Fatal("expected error from failing step")
+	}
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, s := range exp.GetSpans() {
+		spans[s.Name] = s
+	}
+	wfSpan, ok := spans["daisy.Workflow "+testWf]
+	if !ok {
+		t.Fatalf("no workflow span in %v", reflect.ValueOf(spans).MapKeys())
+	}
+	if got := spanAttr(wfSpan, ProjectKey).AsString(); got != testProject {
+		t.Errorf("workflow span project = %q, want %q", got, testProject)
+	}
+	if wfSpan.Status.Code != codes.Error {
+		t.Errorf("workflow span status = %v, want %v", wfSpan.Status.Code, codes.Error)
+	}
+
+	createSpan := spans["daisy.Step create"]
+	if createSpan.Parent.SpanID() != wfSpan.SpanContext.SpanID() {
+		t.Error("step span should be a child of the workflow span")
+	}
+	if got := spanAttr(createSpan, StepTypeKey).AsString(); got != "mockStep" {
+		t.Errorf("step span type = %q, want %q", got, "mockStep")
+	}
+	want := []string{link}
+	if got := spanAttr(createSpan, ResourcesCreatedKey).AsStringSlice(); !reflect.DeepEqual(got, want) {
+		t.Errorf("step span created resources = %q, want %q", got, want)
+	}
+	if createSpan.Status.Code == codes.Error {
+		t.Error("successful step span should not have an error status")
+	}
+	if failSpan := spans["daisy.Step fail"]; failSpan.Status.Code != codes.Error || len(failSpan.Events) == 0 {
+		t.Errorf("failed step span should record the error, got status %v and events %v", failSpan.Status, failSpan.Events)
+	}
+
+	cleanupSpan, ok := spans["daisy.Cleanup "+testWf]
+	if !ok {
+		t.Fatal("no cleanup span")
+	}
+	if cleanupSpan.Parent.SpanID() != wfSpan.SpanContext.SpanID() {
+		t.Error("cleanup span should be a child of the workflow span")
+	}
+}
+
+func TestNewTracerProvider(t *testing.T) {
+	ctx := context.Background()
+	if tp, shutdown, err := NewTracerProvider("", ""); err != nil || tp != nil {
+		t.Errorf("expected no TracerProvider and no error, got %v, %v", tp, err)
+	} else if err := shutdown(ctx); err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+	if _, _, err := NewTracerProvider("foo", ""); err == nil {
+		t.Error("expected error for unknown exporter")
+	}
+	if _, _, err := NewTracerProvider(TraceExporterFile, ""); err == nil {
+		t.Error("expected error for missing trace file")
+	}
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := filepath.Join(dir, "trace.json")
+	for _, name := range []string{"first-span", "second-span"} {
+		tp, shutdown, err := NewTracerProvider(TraceExporterFile, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, span := tp.Tracer("test").Start(ctx, name)
+		span.End()
+		if err := shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := ioutil.ReadFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Spans are appended, so that processes can share the file.
+	for _, name := range []string{"first-span", "second-span"} {
+		if !strings.Contains(string(b), fmt.Sprintf(`"Name":%q`, name)) {
+			t.Errorf("trace file doesn't contain %s: %s", name, b)
+		}
+	}
+}
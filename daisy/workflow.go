@@ -20,11 +20,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -33,6 +35,7 @@ import (
 	"cloud.google.com/go/logging"
 	"cloud.google.com/go/storage"
 	"github.com/GoogleCloudPlatform/compute-image-tools/daisy/compute"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
@@ -139,10 +142,37 @@ type Workflow struct {
 	logProcessHook        func(string) string
 
 	// Optional compute endpoint override.stepWait
-	ComputeEndpoint    string          `json:",omitempty"`
-	ComputeClient      compute.Client  `json:"-"`
-	StorageClient      *storage.Client `json:"-"`
+	ComputeEndpoint string `json:",omitempty"`
+	// Optional Cloud KMS key, in the format
+	// projects/PROJECT/locations/LOCATION/keyRings/KEYRING/cryptoKeys/KEY, that
+	// encrypts the disks, images, snapshots, and machine images created by the
+	// workflow that don't set their own encryption key. Sub-workflows use the
+	// key of their parent when they don't set one.
+	KmsKey string `json:",omitempty"`
+	// Optional client side rate limits of Compute API calls. Sub-workflows
+	// share the rate limits of the top-level workflow. Can't be used with a
+	// preset ComputeClient, since the limits are applied when the client is
+	// created.
+	ComputeRateLimits  *compute.RateLimits `json:",omitempty"`
+	ComputeClient      compute.Client      `json:"-"`
+	StorageClient      *storage.Client     `json:"-"`
 	cloudLoggingClient *logging.Client
+	computeRateLimiter *compute.RateLimiter
+
+	// Optional TracerProvider used to trace the workflow, its steps and
+	// Compute API calls. Defaults to the global TracerProvider. Sub-workflows
+	// use the TracerProvider of the top-level workflow.
+	TracerProvider trace.TracerProvider `json:"-"`
+	traceCtx       context.Context
+
+	// Optional local directory serial port output of instances is written to
+	// while it is read, in addition to the GCS logs path.
+	SerialLogsDir string `json:"-"`
+	// Optional name of an instance whose serial port 1 output is printed to
+	// FollowWriter, defaulting to stdout, while it is read. Either the name of
+	// the instance in the workflow or its real name can be used.
+	FollowInstance string    `json:"-"`
+	FollowWriter   io.Writer `json:"-"`
 
 	// Resource registries.
 	disks           *diskRegistry
@@ -275,6 +305,10 @@ func (w *Workflow) RunWithModifiers(
 	preValidateWorkflowModifier WorkflowModifier,
 	postValidateWorkflowModifier WorkflowModifier) (err DError) {
 
+	ctx, span := w.startSpan(ctx)
+	w.traceCtx = ctx
+	defer func() { endSpan(span, err) }()
+
 	w.externalLogging = true
 	if preValidateWorkflowModifier != nil {
 		preValidateWorkflowModifier(w)
@@ -355,13 +389,43 @@ func (w *Workflow) cleanup() {
 	case <-time.After(4 * time.Second):
 	}
 
-	for _, hook := range w.cleanupHooks {
-		if err := hook(); err != nil {
-			w.LogWorkflowInfo("Error returned from cleanup hook: %s", err)
-		}
-	}
+	w.runCleanupHooks(w.cleanupHooks, "Error returned from cleanup hook")
 	w.LogWorkflowInfo("Workflow %q finished cleanup.", w.Name)
 	w.recordStepTime("workflow cleanup", startTime, time.Now())
+	w.logComputeRateLimiterStats()
+}
+
+// ComputeRateLimiterStats returns metrics of client side rate limiting of
+// Compute API calls, or nil if ComputeRateLimits isn't set.
+func (w *Workflow) ComputeRateLimiterStats() map[compute.MethodClass]compute.RateLimiterStats {
+	if w.computeRateLimiter == nil {
+		return nil
+	}
+	return w.computeRateLimiter.Stats()
+}
+
+func (w *Workflow) logComputeRateLimiterStats() {
+	stats := w.ComputeRateLimiterStats()
+	var mcs []string
+	for mc := range stats {
+		mcs = append(mcs, string(mc))
+	}
+	sort.Strings(mcs)
+	for _, mc := range mcs {
+		st := stats[compute.MethodClass(mc)]
+		w.LogWorkflowInfo("Compute API %s calls: %d, throttled: %d, total delay: %v, rejected by API: %d.", mc, st.Calls, st.Throttled, st.Delay, st.Rejected)
+	}
+}
+
+// kmsKey returns the KmsKey of the workflow, or of its closest parent
+// that sets one.
+func (w *Workflow) kmsKey() string {
+	for cur := w; cur != nil; cur = cur.parent {
+		if cur.KmsKey != "" {
+			return cur.KmsKey
+		}
+	}
+	return ""
 }
 
 func (w *Workflow) genName(n string) string {
@@ -397,8 +461,16 @@ func (w *Workflow) PopulateClients(ctx context.Context) error {
 		computeOptions = append(computeOptions, option.WithEndpoint(w.ComputeEndpoint))
 	}
 
+	if w.ComputeClient != nil && w.ComputeRateLimits != nil && w.parent == nil {
+		return Errf("workflow field 'ComputeRateLimits' can't be used with a preset ComputeClient")
+	}
 	if w.ComputeClient == nil {
-		w.ComputeClient, err = compute.NewClient(ctx, computeOptions...)
+		cfg := compute.ClientConfig{TracerProvider: w.tracerProvider(), TraceParent: w.traceContext}
+		if w.ComputeRateLimits != nil {
+			w.computeRateLimiter = compute.NewRateLimiter(*w.ComputeRateLimits)
+			cfg.RateLimiter = w.computeRateLimiter
+		}
+		w.ComputeClient, err = compute.NewClientWithConfig(ctx, cfg, computeOptions...)
 		if err != nil {
 			return typedErr(apiError, "failed to create compute client", err)
 		}
@@ -657,7 +729,10 @@ func (w *Workflow) run(ctx context.Context) DError {
 	})
 }
 
-func (w *Workflow) runStep(ctx context.Context, s *Step) DError {
+func (w *Workflow) runStep(ctx context.Context, s *Step) (err DError) {
+	ctx, span := s.startSpan(ctx)
+	defer func() { s.endSpan(span, err) }()
+
 	timeout := make(chan struct{})
 	go func() {
 		time.Sleep(s.timeout)
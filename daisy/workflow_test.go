@@ -32,6 +32,7 @@ import (
 	"time"
 
 	"cloud.google.com/go/storage"
+	daisyCompute "github.com/GoogleCloudPlatform/compute-image-tools/daisy/compute"
 	computeAlpha "google.golang.org/api/compute/v0.alpha"
 	computeBeta "google.golang.org/api/compute/v0.beta"
 	"google.golang.org/api/compute/v1"
@@ -351,8 +352,9 @@ func TestNewFromFile(t *testing.T) {
 					},
 					{
 						Instance: compute.Instance{
-							Name:        "postinstallBeta",
-							MachineType: "${machine_type}",
+							Name:               "postinstallBeta",
+							MachineType:        "${machine_type}",
+							SourceMachineImage: "source-machine-image",
 						},
 					},
 				},
@@ -1051,6 +1053,24 @@ func TestPopulateClients(t *testing.T) {
 	}
 }
 
+func TestPopulateClientsErrorsWhenComputeRateLimitsSetWithPresetComputeClient(t *testing.T) {
+	w := testWorkflow()
+	w.ComputeRateLimits = &daisyCompute.RateLimits{}
+
+	if err := w.PopulateClients(context.Background()); err == nil {
+		t.Error("expected an error when ComputeRateLimits is set with a preset ComputeClient")
+	}
+	if w.ComputeRateLimiterStats() != nil {
+		t.Error("should not create a rate limiter for a preset ComputeClient")
+	}
+
+	w.ComputeClient = nil
+	tryPopulateClients(t, w)
+	if w.ComputeRateLimiterStats() == nil {
+		t.Error("did not create a rate limiter for the populated ComputeClient")
+	}
+}
+
 func tryPopulateClients(t *testing.T, w *Workflow) {
 	if err := w.PopulateClients(context.Background()); err != nil {
 		t.Errorf("Failed to populate clients for workflow: %v", err)
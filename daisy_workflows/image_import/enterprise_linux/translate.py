@@ -17,7 +17,7 @@
 
 Parameters (retrieved from instance metadata):
 
-el_release: The version of the distro (6 or 7)
+el_release: The version of the distro (6, 7, or 8). Amazon Linux 2 uses 7.
 install_gce_packages: True if GCE agent and SDK should be installed
 use_rhel_gce_license: True if GCE RHUI package should be installed
 """
@@ -36,7 +36,7 @@ from utils.guestfsprocess import run
 repo_compute = '''
 [google-compute-engine]
 name=Google Compute Engine
-baseurl=https://packages.cloud.google.com/yum/repos/google-compute-engine-el%s-x86_64-stable
+baseurl=https://packages.cloud.google.com/yum/repos/google-compute-engine-el%s-$basearch-stable
 enabled=1
 gpgcheck=1
 repo_gpgcheck=0
@@ -47,7 +47,7 @@ gpgkey=https://packages.cloud.google.com/yum/doc/yum-key.gpg
 repo_sdk = '''
 [google-cloud-sdk]
 name=Google Cloud SDK
-baseurl=https://packages.cloud.google.com/yum/repos/cloud-sdk-el%s-x86_64
+baseurl=https://packages.cloud.google.com/yum/repos/cloud-sdk-el%s-$basearch
 enabled=1
 gpgcheck=1
 repo_gpgcheck=0
@@ -323,7 +323,10 @@ def yum_install(g, *packages):
 
 
 def run_translate(g: guestfs.GuestFS):
+  # Oracle Linux keeps a Red Hat /etc/redhat-release for compatibility,
+  # but it isn't registered with RHEL's subscription-manager.
   if (g.exists('/etc/redhat-release')
+      and not g.exists('/etc/oracle-release')
       and 'Red Hat' in g.cat('/etc/redhat-release')):
     distro = Distro.RHEL
   else:
@@ -32,12 +32,14 @@ and will remove support for defunct systems over time.
 import os
 import re
 import sys
+import typing
 
 from boot_inspect.inspectors.os import architecture, linux, windows
 import boot_inspect.system.filesystems
 from compute_image_tools_proto import inspect_pb2
 
 _LINUX = [
+    linux.Fingerprint(inspect_pb2.Distro.ALMALINUX),
     linux.Fingerprint(inspect_pb2.Distro.AMAZON,
                       aliases=['amzn', 'amazonlinux']),
     linux.Fingerprint(
@@ -86,6 +88,7 @@ _LINUX = [
     linux.Fingerprint(inspect_pb2.Distro.OPENSUSE, aliases=['opensuse-leap']),
     linux.Fingerprint(inspect_pb2.Distro.ORACLE,
                       aliases=['ol', 'oraclelinux']),
+    linux.Fingerprint(inspect_pb2.Distro.ROCKY),
     linux.Fingerprint(inspect_pb2.Distro.UBUNTU),
 ]
 
@@ -104,11 +107,12 @@ def inspect_device(g) -> inspect_pb2.InspectionResults:
     results = inspect_device(g, "/dev/sdb")
   """
 
+  encrypted_volumes = _encrypted_volumes(g)
   roots = g.inspect_os()
   if len(roots) == 0:
     return inspect_pb2.InspectionResults(
-        os_count=len(roots)
-
+        os_count=len(roots),
+        encrypted_volumes=encrypted_volumes,
     )
   root = roots[0]
   mount_points = g.inspect_get_mountpoints(root)
@@ -118,9 +122,16 @@ def inspect_device(g) -> inspect_pb2.InspectionResults:
     except RuntimeError as msg:
       print('%s (ignored)' % msg, file=sys.stderr)
   fs = boot_inspect.system.filesystems.GuestFSFilesystem(g)
+  kernel_versions, virtio_drivers, cloud_init_installed = [], [], False
   operating_system = linux.Inspector(fs, _LINUX).inspect()
-  if not operating_system:
+  if operating_system:
+    kernel_versions = linux.kernel_versions(fs)
+    virtio_drivers = linux.virtio_drivers(fs, kernel_versions)
+    cloud_init_installed = linux.cloud_init_installed(fs)
+  else:
     operating_system = windows.Inspector(g, root).inspect()
+    if operating_system:
+      virtio_drivers = windows.virtio_drivers(g)
   if operating_system:
     operating_system.architecture = architecture.Inspector(g, root).inspect()
 
@@ -129,9 +140,38 @@ def inspect_device(g) -> inspect_pb2.InspectionResults:
   return inspect_pb2.InspectionResults(
       os_release=operating_system,
       os_count=1 if operating_system else 0,
+      kernel_versions=kernel_versions,
+      virtio_drivers=virtio_drivers,
+      cloud_init_installed=cloud_init_installed,
+      root_on_lvm=bool(operating_system) and _is_lv(g, root),
+      encrypted_volumes=encrypted_volumes,
   )
 
 
+def _encrypted_volumes(g) -> typing.List[int]:
+  """Returns the encryption used by each encrypted volume.
+
+  Args:
+    g (guestfs.GuestFS): A launched, but unmounted, GuestFS instance.
+  """
+  encryption_types = {
+      'crypto_LUKS': inspect_pb2.InspectionResults.Encryption.LUKS,
+      'BitLocker': inspect_pb2.InspectionResults.Encryption.BITLOCKER,
+  }
+  volumes = []
+  for _, fs_type in sorted(g.list_filesystems().items()):
+    if fs_type in encryption_types:
+      volumes.append(encryption_types[fs_type])
+  return volumes
+
+
+def _is_lv(g, device: str) -> bool:
+  try:
+    return bool(g.is_lv(device))
+  except RuntimeError:
+    return False
+
+
 def inspect_boot_loader(g, device) -> inspect_pb2.InspectionResults:
   """Finds boot-loader properties for the device using offline inspection.
 
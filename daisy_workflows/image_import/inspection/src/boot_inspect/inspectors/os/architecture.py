@@ -34,4 +34,6 @@ class Inspector:
       return inspect_pb2.Architecture.X86
     elif inspected == 'x86_64':
       return inspect_pb2.Architecture.X64
+    elif inspected == 'aarch64':
+      return inspect_pb2.Architecture.ARM64
     return inspect_pb2.Architecture.UNKNOWN
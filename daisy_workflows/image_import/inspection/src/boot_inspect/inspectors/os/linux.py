@@ -196,3 +196,69 @@ def _parse_config_file(content: str) -> typing.Mapping[str, str]:
     k, v = line.split('=', 1)
     kv[k] = v.strip().strip('"\'')
   return kv
+
+
+# Kernel modules for GCE's paravirtualized devices.
+_virtio_modules = ['gve', 'virtio_blk', 'virtio_net', 'virtio_pci',
+                   'virtio_scsi']
+
+# Files that are installed by cloud-init's packages.
+_cloud_init_files = ['/usr/bin/cloud-init', '/etc/cloud/cloud.cfg']
+
+_modules_dir = '/lib/modules'
+
+
+def kernel_versions(
+    fs: boot_inspect.system.filesystems.Filesystem) -> typing.List[str]:
+  """Returns the versions of the kernels that have modules installed.
+
+  Directories without modules.dep are skipped, since they're typically
+  left behind when a kernel is removed.
+  """
+  if not fs.is_directory(_modules_dir):
+    return []
+  return [
+      name for name in fs.list_directory(_modules_dir)
+      if fs.is_file('%s/%s/modules.dep' % (_modules_dir, name))
+  ]
+
+
+def virtio_drivers(fs: boot_inspect.system.filesystems.Filesystem,
+                   kernels: typing.List[str]) -> typing.List[str]:
+  """Returns the virtio modules that are available to every kernel,
+
+  either as a loadable module or built in.
+  """
+  if not kernels:
+    return []
+  available = set(_virtio_modules)
+  for kernel in kernels:
+    available &= _kernel_modules(fs, kernel)
+  return [m for m in _virtio_modules if m in available]
+
+
+def _kernel_modules(fs: boot_inspect.system.filesystems.Filesystem,
+                    kernel: str) -> typing.Set[str]:
+  """Returns the names of the modules listed in a kernel's
+
+  modules.dep and modules.builtin. Names are normalized to use
+  underscores, matching the output of lsmod.
+  """
+  modules = set()
+  for index in ['modules.dep', 'modules.builtin']:
+    path = '%s/%s/%s' % (_modules_dir, kernel, index)
+    if not fs.is_file(path):
+      continue
+    # modules.dep lines are `{module}: {dependencies}`, and
+    # modules.builtin lines are `{module}`.
+    for line in fs.read_utf8(path).splitlines():
+      module = line.split(':', 1)[0].strip()
+      if '.ko' in module:
+        name = module[:module.index('.ko')].split('/')[-1]
+        modules.add(name.replace('-', '_'))
+  return modules
+
+
+def cloud_init_installed(
+    fs: boot_inspect.system.filesystems.Filesystem) -> bool:
+  return any(fs.is_file(f) for f in _cloud_init_files)
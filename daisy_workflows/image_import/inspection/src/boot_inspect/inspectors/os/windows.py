@@ -13,6 +13,7 @@
 # See the License for the specific language governing permissions and
 # limitations under the License.
 import re
+import typing
 
 from compute_image_tools_proto import inspect_pb2
 
@@ -40,6 +41,9 @@ _client_versions = {
     (10, 0): ('10', ''),
 }
 
+# Drivers for GCE's paravirtualized devices, excluding the .sys suffix.
+_virtio_drivers = ['gvnic', 'netkvm', 'vioscsi', 'viostor']
+
 
 class Inspector:
 
@@ -88,3 +92,21 @@ def _from_nt_version(
         minor_version=minor,
         distro_id=inspect_pb2.Distro.WINDOWS,
     )
+
+
+def virtio_drivers(g) -> typing.List[str]:
+  """Returns the virtio drivers that are installed.
+
+  Args:
+    g (guestfs.GuestFS): A guestfs instance that has been mounted.
+  """
+  drivers = []
+  for driver in _virtio_drivers:
+    try:
+      # NTFS is case-insensitive, while guestfs paths are case-sensitive.
+      path = g.case_sensitive_path('/Windows/System32/drivers/%s.sys' % driver)
+    except RuntimeError:
+      continue
+    if g.is_file(path):
+      drivers.append(driver)
+  return drivers
@@ -39,6 +39,15 @@ class Filesystem(object, metaclass=abc.ABCMeta):
     """Returns true if path exists and points to a directory."""
     pass
 
+  @abc.abstractmethod
+  def list_directory(self, path: str) -> typing.List[str]:
+    """Returns the sorted names of the entries in the directory at path.
+
+    Raises:
+      FileNotFoundError if path doesn't exist, or it isn't a directory.
+    """
+    pass
+
 
 class GuestFSFilesystem(Filesystem):
   """A Filesystem that delegates to an offline VM."""
@@ -61,6 +70,11 @@ class GuestFSFilesystem(Filesystem):
   def is_directory(self, path: str) -> bool:
     return self._g.is_dir(path)
 
+  def list_directory(self, path: str) -> typing.List[str]:
+    if not self.is_directory(path):
+      raise FileNotFoundError(path)
+    return sorted(self._g.ls(path))
+
 
 class DictBackedFilesystem(Filesystem):
   """A Filesystem that delegates to a dict.
@@ -92,3 +106,14 @@ class DictBackedFilesystem(Filesystem):
       if fs_path.startswith(path):
         return True
     return False
+
+  def list_directory(self, path: str) -> typing.List[str]:
+    if not self.is_directory(path):
+      raise FileNotFoundError(path)
+    if not path.endswith('/'):
+      path += '/'
+    names = set()
+    for fs_path in self.fs.keys():
+      if fs_path.startswith(path):
+        names.add(fs_path[len(path):].split('/')[0])
+    return sorted(names)
@@ -16,6 +16,7 @@
 import os
 
 from boot_inspect import inspection, model
+from boot_inspect.inspectors.os import linux
 from boot_inspect.system import filesystems
 from compute_image_tools_proto import inspect_pb2
 import pytest
@@ -48,3 +49,34 @@ def none_to_empty(v) -> str:
   if not v:
     return ''
   return str(v)
+
+
+def test_kernels_and_drivers():
+  fs = filesystems.DictBackedFilesystem({
+      '/lib/modules/5.4.0-42-generic/modules.dep':
+          'kernel/drivers/scsi/virtio_scsi.ko: kernel/drivers/virtio/virtio.ko\n'
+          'kernel/drivers/net/ethernet/google/gve/gve.ko:\n',
+      '/lib/modules/5.4.0-42-generic/modules.builtin':
+          'kernel/drivers/virtio/virtio_pci.ko\n'
+          'kernel/drivers/block/virtio_blk.ko\n',
+      # virtio_blk is missing from the second kernel.
+      '/lib/modules/5.8.0-1-generic/modules.dep':
+          'kernel/drivers/scsi/virtio_scsi.ko.xz:\n'
+          'kernel/drivers/net/ethernet/google/gve/gve.ko.xz:\n'
+          'kernel/drivers/virtio/virtio-pci.ko.xz:\n',
+      # Left behind after a kernel was removed.
+      '/lib/modules/4.15.0-1-generic/extra/module.ko': '',
+      '/etc/cloud/cloud.cfg': '',
+  })
+  kernels = linux.kernel_versions(fs)
+  assert kernels == ['5.4.0-42-generic', '5.8.0-1-generic']
+  assert linux.virtio_drivers(fs, kernels) == [
+      'gve', 'virtio_pci', 'virtio_scsi']
+  assert linux.cloud_init_installed(fs)
+
+
+def test_kernels_and_drivers_not_installed():
+  fs = filesystems.DictBackedFilesystem({'/etc/os-release': 'ID=ubuntu'})
+  assert linux.kernel_versions(fs) == []
+  assert linux.virtio_drivers(fs, []) == []
+  assert not linux.cloud_init_installed(fs)
@@ -41,17 +41,18 @@ const _ = proto.ProtoPackageIsVersion4
 
 // Distro denotes a product line of operating systems, using the following
 // test:
-//   If two operating systems at the same version and CPU architecture can be
-//   imported using the same logic, then they have the same Distro. For example,
-//   if Ubuntu 20.04 and xubuntu 20.04 are importable using
-//   the same logic, then they'd both be categorized as Distro.UBUNTU.
+//
+//	If two operating systems at the same version and CPU architecture can be
+//	imported using the same logic, then they have the same Distro. For example,
+//	if Ubuntu 20.04 and xubuntu 20.04 are importable using
+//	the same logic, then they'd both be categorized as Distro.UBUNTU.
 //
 // When adding new members, keep in mind:
-//  - Group distros by family, using buckets of size 1000.
-//  - The following properties are orthogonal and should not be encoded here:
-//      - CPU architecture
-//      - Major or minor versions
-//      - GCE licensing (such as BYOL)
+//   - Group distros by family, using buckets of size 1000.
+//   - The following properties are orthogonal and should not be encoded here:
+//   - CPU architecture
+//   - Major or minor versions
+//   - GCE licensing (such as BYOL)
 type Distro int32
 
 const (
@@ -68,6 +69,8 @@ const (
 	Distro_CENTOS         Distro = 4002
 	Distro_AMAZON         Distro = 4003
 	Distro_ORACLE         Distro = 4004
+	Distro_ROCKY          Distro = 4005
+	Distro_ALMALINUX      Distro = 4006
 )
 
 // Enum value maps for Distro.
@@ -86,6 +89,8 @@ var (
 		4002: "CENTOS",
 		4003: "AMAZON",
 		4004: "ORACLE",
+		4005: "ROCKY",
+		4006: "ALMALINUX",
 	}
 	Distro_value = map[string]int32{
 		"DISTRO_UNKNOWN": 0,
@@ -101,6 +106,8 @@ var (
 		"CENTOS":         4002,
 		"AMAZON":         4003,
 		"ORACLE":         4004,
+		"ROCKY":          4005,
+		"ALMALINUX":      4006,
 	}
 )
 
@@ -137,6 +144,7 @@ const (
 	Architecture_ARCHITECTURE_UNKNOWN Architecture = 0
 	Architecture_X86                  Architecture = 1
 	Architecture_X64                  Architecture = 2
+	Architecture_ARM64                Architecture = 3
 )
 
 // Enum value maps for Architecture.
@@ -145,11 +153,13 @@ var (
 		0: "ARCHITECTURE_UNKNOWN",
 		1: "X86",
 		2: "X64",
+		3: "ARM64",
 	}
 	Architecture_value = map[string]int32{
 		"ARCHITECTURE_UNKNOWN": 0,
 		"X86":                  1,
 		"X64":                  2,
+		"ARM64":                3,
 	}
 )
 
@@ -244,6 +254,55 @@ func (InspectionResults_ErrorWhen) EnumDescriptor() ([]byte, []int) {
 	return file_inspect_proto_rawDescGZIP(), []int{1, 0}
 }
 
+type InspectionResults_Encryption int32
+
+const (
+	InspectionResults_ENCRYPTION_UNKNOWN InspectionResults_Encryption = 0
+	InspectionResults_LUKS               InspectionResults_Encryption = 1
+	InspectionResults_BITLOCKER          InspectionResults_Encryption = 2
+)
+
+// Enum value maps for InspectionResults_Encryption.
+var (
+	InspectionResults_Encryption_name = map[int32]string{
+		0: "ENCRYPTION_UNKNOWN",
+		1: "LUKS",
+		2: "BITLOCKER",
+	}
+	InspectionResults_Encryption_value = map[string]int32{
+		"ENCRYPTION_UNKNOWN": 0,
+		"LUKS":               1,
+		"BITLOCKER":          2,
+	}
+)
+
+func (x InspectionResults_Encryption) Enum() *InspectionResults_Encryption {
+	p := new(InspectionResults_Encryption)
+	*p = x
+	return p
+}
+
+func (x InspectionResults_Encryption) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (InspectionResults_Encryption) Descriptor() protoreflect.EnumDescriptor {
+	return file_inspect_proto_enumTypes[3].Descriptor()
+}
+
+func (InspectionResults_Encryption) Type() protoreflect.EnumType {
+	return &file_inspect_proto_enumTypes[3]
+}
+
+func (x InspectionResults_Encryption) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use InspectionResults_Encryption.Descriptor instead.
+func (InspectionResults_Encryption) EnumDescriptor() ([]byte, []int) {
+	return file_inspect_proto_rawDescGZIP(), []int{1, 1}
+}
+
 // OsRelease records the name and version of an operating system.
 type OsRelease struct {
 	state         protoimpl.MessageState
@@ -378,6 +437,27 @@ type InspectionResults struct {
 	ElapsedTimeMs int64 `protobuf:"varint,6,opt,name=elapsed_time_ms,json=elapsedTimeMs,proto3" json:"elapsed_time_ms,omitempty"`
 	// Number of operating systems detected on the disk.
 	OsCount int32 `protobuf:"varint,7,opt,name=os_count,json=osCount,proto3" json:"os_count,omitempty"`
+	// Versions of the Linux kernels that are installed in the guest, as
+	// reported by `uname -r`. Examples:
+	//   - Ubuntu 20.04: 5.4.0-42-generic
+	//   - CentOS 7: 3.10.0-1160.el7.x86_64
+	KernelVersions []string `protobuf:"bytes,8,rep,name=kernel_versions,json=kernelVersions,proto3" json:"kernel_versions,omitempty"`
+	// Paravirtualized drivers that are installed in the guest. For Linux,
+	// these are the kernel modules that are available for every kernel
+	// in `kernel_versions`, either as modules or built in. For Windows,
+	// these are the driver files in System32\drivers. Examples:
+	//   - Linux: virtio_scsi, virtio_net, gve
+	//   - Windows: vioscsi, netkvm, gvnic
+	VirtioDrivers []string `protobuf:"bytes,9,rep,name=virtio_drivers,json=virtioDrivers,proto3" json:"virtio_drivers,omitempty"`
+	// cloud_init_installed indicates whether cloud-init is installed
+	// in the guest.
+	CloudInitInstalled bool `protobuf:"varint,10,opt,name=cloud_init_installed,json=cloudInitInstalled,proto3" json:"cloud_init_installed,omitempty"`
+	// root_on_lvm indicates whether the root filesystem of `os_release` is
+	// stored on an LVM logical volume.
+	RootOnLvm bool `protobuf:"varint,11,opt,name=root_on_lvm,json=rootOnLvm,proto3" json:"root_on_lvm,omitempty"`
+	// Encryption used by volumes that couldn't be inspected, since they're
+	// encrypted. One entry per volume.
+	EncryptedVolumes []InspectionResults_Encryption `protobuf:"varint,12,rep,packed,name=encrypted_volumes,json=encryptedVolumes,proto3,enum=InspectionResults_Encryption" json:"encrypted_volumes,omitempty"`
 }
 
 func (x *InspectionResults) Reset() {
@@ -461,6 +541,41 @@ func (x *InspectionResults) GetOsCount() int32 {
 	return 0
 }
 
+func (x *InspectionResults) GetKernelVersions() []string {
+	if x != nil {
+		return x.KernelVersions
+	}
+	return nil
+}
+
+func (x *InspectionResults) GetVirtioDrivers() []string {
+	if x != nil {
+		return x.VirtioDrivers
+	}
+	return nil
+}
+
+func (x *InspectionResults) GetCloudInitInstalled() bool {
+	if x != nil {
+		return x.CloudInitInstalled
+	}
+	return false
+}
+
+func (x *InspectionResults) GetRootOnLvm() bool {
+	if x != nil {
+		return x.RootOnLvm
+	}
+	return false
+}
+
+func (x *InspectionResults) GetEncryptedVolumes() []InspectionResults_Encryption {
+	if x != nil {
+		return x.EncryptedVolumes
+	}
+	return nil
+}
+
 var File_inspect_proto protoreflect.FileDescriptor
 
 var file_inspect_proto_rawDesc = []byte{
@@ -479,7 +594,7 @@ var file_inspect_proto_rawDesc = []byte{
 	0x68, 0x69, 0x74, 0x65, 0x63, 0x74, 0x75, 0x72, 0x65, 0x52, 0x0c, 0x61, 0x72, 0x63, 0x68, 0x69,
 	0x74, 0x65, 0x63, 0x74, 0x75, 0x72, 0x65, 0x12, 0x24, 0x0a, 0x09, 0x64, 0x69, 0x73, 0x74, 0x72,
 	0x6f, 0x5f, 0x69, 0x64, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x07, 0x2e, 0x44, 0x69, 0x73,
-	0x74, 0x72, 0x6f, 0x52, 0x08, 0x64, 0x69, 0x73, 0x74, 0x72, 0x6f, 0x49, 0x64, 0x22, 0xf0, 0x03,
+	0x74, 0x72, 0x6f, 0x52, 0x08, 0x64, 0x69, 0x73, 0x74, 0x72, 0x6f, 0x49, 0x64, 0x22, 0x9d, 0x06,
 	0x0a, 0x11, 0x49, 0x6e, 0x73, 0x70, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x75,
 	0x6c, 0x74, 0x73, 0x12, 0x29, 0x0a, 0x0a, 0x6f, 0x73, 0x5f, 0x72, 0x65, 0x6c, 0x65, 0x61, 0x73,
 	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x4f, 0x73, 0x52, 0x65, 0x6c, 0x65,
@@ -498,36 +613,57 @@ var file_inspect_proto_rawDesc = []byte{
 	0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x65, 0x6c, 0x61, 0x70, 0x73, 0x65, 0x64,
 	0x54, 0x69, 0x6d, 0x65, 0x4d, 0x73, 0x12, 0x19, 0x0a, 0x08, 0x6f, 0x73, 0x5f, 0x63, 0x6f, 0x75,
 	0x6e, 0x74, 0x18, 0x07, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x6f, 0x73, 0x43, 0x6f, 0x75, 0x6e,
-	0x74, 0x22, 0xcc, 0x01, 0x0a, 0x09, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x57, 0x68, 0x65, 0x6e, 0x12,
-	0x0c, 0x0a, 0x08, 0x4e, 0x4f, 0x5f, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x10, 0x00, 0x12, 0x13, 0x0a,
-	0x0f, 0x53, 0x54, 0x41, 0x52, 0x54, 0x49, 0x4e, 0x47, 0x5f, 0x57, 0x4f, 0x52, 0x4b, 0x45, 0x52,
-	0x10, 0x64, 0x12, 0x12, 0x0a, 0x0e, 0x52, 0x55, 0x4e, 0x4e, 0x49, 0x4e, 0x47, 0x5f, 0x57, 0x4f,
-	0x52, 0x4b, 0x45, 0x52, 0x10, 0x65, 0x12, 0x13, 0x0a, 0x0e, 0x4d, 0x4f, 0x55, 0x4e, 0x54, 0x49,
-	0x4e, 0x47, 0x5f, 0x47, 0x55, 0x45, 0x53, 0x54, 0x10, 0xc8, 0x01, 0x12, 0x12, 0x0a, 0x0d, 0x49,
-	0x4e, 0x53, 0x50, 0x45, 0x43, 0x54, 0x49, 0x4e, 0x47, 0x5f, 0x4f, 0x53, 0x10, 0xc9, 0x01, 0x12,
-	0x1a, 0x0a, 0x15, 0x49, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x54, 0x49, 0x4e, 0x47, 0x5f, 0x42, 0x4f,
-	0x4f, 0x54, 0x4c, 0x4f, 0x41, 0x44, 0x45, 0x52, 0x10, 0xca, 0x01, 0x12, 0x1d, 0x0a, 0x18, 0x44,
-	0x45, 0x43, 0x4f, 0x44, 0x49, 0x4e, 0x47, 0x5f, 0x57, 0x4f, 0x52, 0x4b, 0x45, 0x52, 0x5f, 0x52,
-	0x45, 0x53, 0x50, 0x4f, 0x4e, 0x53, 0x45, 0x10, 0xac, 0x02, 0x12, 0x24, 0x0a, 0x1f, 0x49, 0x4e,
-	0x54, 0x45, 0x52, 0x50, 0x52, 0x45, 0x54, 0x49, 0x4e, 0x47, 0x5f, 0x49, 0x4e, 0x53, 0x50, 0x45,
-	0x43, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x52, 0x45, 0x53, 0x55, 0x4c, 0x54, 0x53, 0x10, 0xad, 0x02,
-	0x2a, 0xb7, 0x01, 0x0a, 0x06, 0x44, 0x69, 0x73, 0x74, 0x72, 0x6f, 0x12, 0x12, 0x0a, 0x0e, 0x44,
-	0x49, 0x53, 0x54, 0x52, 0x4f, 0x5f, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12,
-	0x0c, 0x0a, 0x07, 0x57, 0x49, 0x4e, 0x44, 0x4f, 0x57, 0x53, 0x10, 0xe8, 0x07, 0x12, 0x0b, 0x0a,
-	0x06, 0x44, 0x45, 0x42, 0x49, 0x41, 0x4e, 0x10, 0xd0, 0x0f, 0x12, 0x0b, 0x0a, 0x06, 0x55, 0x42,
-	0x55, 0x4e, 0x54, 0x55, 0x10, 0xd1, 0x0f, 0x12, 0x09, 0x0a, 0x04, 0x4b, 0x41, 0x4c, 0x49, 0x10,
-	0xd2, 0x0f, 0x12, 0x0d, 0x0a, 0x08, 0x4f, 0x50, 0x45, 0x4e, 0x53, 0x55, 0x53, 0x45, 0x10, 0xb8,
-	0x17, 0x12, 0x09, 0x0a, 0x04, 0x53, 0x4c, 0x45, 0x53, 0x10, 0xb9, 0x17, 0x12, 0x0d, 0x0a, 0x08,
-	0x53, 0x4c, 0x45, 0x53, 0x5f, 0x53, 0x41, 0x50, 0x10, 0xba, 0x17, 0x12, 0x0b, 0x0a, 0x06, 0x46,
-	0x45, 0x44, 0x4f, 0x52, 0x41, 0x10, 0xa0, 0x1f, 0x12, 0x09, 0x0a, 0x04, 0x52, 0x48, 0x45, 0x4c,
-	0x10, 0xa1, 0x1f, 0x12, 0x0b, 0x0a, 0x06, 0x43, 0x45, 0x4e, 0x54, 0x4f, 0x53, 0x10, 0xa2, 0x1f,
-	0x12, 0x0b, 0x0a, 0x06, 0x41, 0x4d, 0x41, 0x5a, 0x4f, 0x4e, 0x10, 0xa3, 0x1f, 0x12, 0x0b, 0x0a,
-	0x06, 0x4f, 0x52, 0x41, 0x43, 0x4c, 0x45, 0x10, 0xa4, 0x1f, 0x2a, 0x3a, 0x0a, 0x0c, 0x41, 0x72,
-	0x63, 0x68, 0x69, 0x74, 0x65, 0x63, 0x74, 0x75, 0x72, 0x65, 0x12, 0x18, 0x0a, 0x14, 0x41, 0x52,
-	0x43, 0x48, 0x49, 0x54, 0x45, 0x43, 0x54, 0x55, 0x52, 0x45, 0x5f, 0x55, 0x4e, 0x4b, 0x4e, 0x4f,
-	0x57, 0x4e, 0x10, 0x00, 0x12, 0x07, 0x0a, 0x03, 0x58, 0x38, 0x36, 0x10, 0x01, 0x12, 0x07, 0x0a,
-	0x03, 0x58, 0x36, 0x34, 0x10, 0x02, 0x42, 0x06, 0x5a, 0x04, 0x2e, 0x3b, 0x70, 0x62, 0x62, 0x06,
-	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x74, 0x12, 0x27, 0x0a, 0x0f, 0x6b, 0x65, 0x72, 0x6e, 0x65, 0x6c, 0x5f, 0x76, 0x65, 0x72, 0x73,
+	0x69, 0x6f, 0x6e, 0x73, 0x18, 0x08, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0e, 0x6b, 0x65, 0x72, 0x6e,
+	0x65, 0x6c, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x25, 0x0a, 0x0e, 0x76, 0x69,
+	0x72, 0x74, 0x69, 0x6f, 0x5f, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x73, 0x18, 0x09, 0x20, 0x03,
+	0x28, 0x09, 0x52, 0x0d, 0x76, 0x69, 0x72, 0x74, 0x69, 0x6f, 0x44, 0x72, 0x69, 0x76, 0x65, 0x72,
+	0x73, 0x12, 0x30, 0x0a, 0x14, 0x63, 0x6c, 0x6f, 0x75, 0x64, 0x5f, 0x69, 0x6e, 0x69, 0x74, 0x5f,
+	0x69, 0x6e, 0x73, 0x74, 0x61, 0x6c, 0x6c, 0x65, 0x64, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x12, 0x63, 0x6c, 0x6f, 0x75, 0x64, 0x49, 0x6e, 0x69, 0x74, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6c,
+	0x6c, 0x65, 0x64, 0x12, 0x1e, 0x0a, 0x0b, 0x72, 0x6f, 0x6f, 0x74, 0x5f, 0x6f, 0x6e, 0x5f, 0x6c,
+	0x76, 0x6d, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x72, 0x6f, 0x6f, 0x74, 0x4f, 0x6e,
+	0x4c, 0x76, 0x6d, 0x12, 0x4a, 0x0a, 0x11, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64,
+	0x5f, 0x76, 0x6f, 0x6c, 0x75, 0x6d, 0x65, 0x73, 0x18, 0x0c, 0x20, 0x03, 0x28, 0x0e, 0x32, 0x1d,
+	0x2e, 0x49, 0x6e, 0x73, 0x70, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x75, 0x6c,
+	0x74, 0x73, 0x2e, 0x45, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x10, 0x65,
+	0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x56, 0x6f, 0x6c, 0x75, 0x6d, 0x65, 0x73, 0x22,
+	0xcc, 0x01, 0x0a, 0x09, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x57, 0x68, 0x65, 0x6e, 0x12, 0x0c, 0x0a,
+	0x08, 0x4e, 0x4f, 0x5f, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x10, 0x00, 0x12, 0x13, 0x0a, 0x0f, 0x53,
+	0x54, 0x41, 0x52, 0x54, 0x49, 0x4e, 0x47, 0x5f, 0x57, 0x4f, 0x52, 0x4b, 0x45, 0x52, 0x10, 0x64,
+	0x12, 0x12, 0x0a, 0x0e, 0x52, 0x55, 0x4e, 0x4e, 0x49, 0x4e, 0x47, 0x5f, 0x57, 0x4f, 0x52, 0x4b,
+	0x45, 0x52, 0x10, 0x65, 0x12, 0x13, 0x0a, 0x0e, 0x4d, 0x4f, 0x55, 0x4e, 0x54, 0x49, 0x4e, 0x47,
+	0x5f, 0x47, 0x55, 0x45, 0x53, 0x54, 0x10, 0xc8, 0x01, 0x12, 0x12, 0x0a, 0x0d, 0x49, 0x4e, 0x53,
+	0x50, 0x45, 0x43, 0x54, 0x49, 0x4e, 0x47, 0x5f, 0x4f, 0x53, 0x10, 0xc9, 0x01, 0x12, 0x1a, 0x0a,
+	0x15, 0x49, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x54, 0x49, 0x4e, 0x47, 0x5f, 0x42, 0x4f, 0x4f, 0x54,
+	0x4c, 0x4f, 0x41, 0x44, 0x45, 0x52, 0x10, 0xca, 0x01, 0x12, 0x1d, 0x0a, 0x18, 0x44, 0x45, 0x43,
+	0x4f, 0x44, 0x49, 0x4e, 0x47, 0x5f, 0x57, 0x4f, 0x52, 0x4b, 0x45, 0x52, 0x5f, 0x52, 0x45, 0x53,
+	0x50, 0x4f, 0x4e, 0x53, 0x45, 0x10, 0xac, 0x02, 0x12, 0x24, 0x0a, 0x1f, 0x49, 0x4e, 0x54, 0x45,
+	0x52, 0x50, 0x52, 0x45, 0x54, 0x49, 0x4e, 0x47, 0x5f, 0x49, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x54,
+	0x49, 0x4f, 0x4e, 0x5f, 0x52, 0x45, 0x53, 0x55, 0x4c, 0x54, 0x53, 0x10, 0xad, 0x02, 0x22, 0x3d,
+	0x0a, 0x0a, 0x45, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x12,
+	0x45, 0x4e, 0x43, 0x52, 0x59, 0x50, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x55, 0x4e, 0x4b, 0x4e, 0x4f,
+	0x57, 0x4e, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x4c, 0x55, 0x4b, 0x53, 0x10, 0x01, 0x12, 0x0d,
+	0x0a, 0x09, 0x42, 0x49, 0x54, 0x4c, 0x4f, 0x43, 0x4b, 0x45, 0x52, 0x10, 0x02, 0x2a, 0xd3, 0x01,
+	0x0a, 0x06, 0x44, 0x69, 0x73, 0x74, 0x72, 0x6f, 0x12, 0x12, 0x0a, 0x0e, 0x44, 0x49, 0x53, 0x54,
+	0x52, 0x4f, 0x5f, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x07,
+	0x57, 0x49, 0x4e, 0x44, 0x4f, 0x57, 0x53, 0x10, 0xe8, 0x07, 0x12, 0x0b, 0x0a, 0x06, 0x44, 0x45,
+	0x42, 0x49, 0x41, 0x4e, 0x10, 0xd0, 0x0f, 0x12, 0x0b, 0x0a, 0x06, 0x55, 0x42, 0x55, 0x4e, 0x54,
+	0x55, 0x10, 0xd1, 0x0f, 0x12, 0x09, 0x0a, 0x04, 0x4b, 0x41, 0x4c, 0x49, 0x10, 0xd2, 0x0f, 0x12,
+	0x0d, 0x0a, 0x08, 0x4f, 0x50, 0x45, 0x4e, 0x53, 0x55, 0x53, 0x45, 0x10, 0xb8, 0x17, 0x12, 0x09,
+	0x0a, 0x04, 0x53, 0x4c, 0x45, 0x53, 0x10, 0xb9, 0x17, 0x12, 0x0d, 0x0a, 0x08, 0x53, 0x4c, 0x45,
+	0x53, 0x5f, 0x53, 0x41, 0x50, 0x10, 0xba, 0x17, 0x12, 0x0b, 0x0a, 0x06, 0x46, 0x45, 0x44, 0x4f,
+	0x52, 0x41, 0x10, 0xa0, 0x1f, 0x12, 0x09, 0x0a, 0x04, 0x52, 0x48, 0x45, 0x4c, 0x10, 0xa1, 0x1f,
+	0x12, 0x0b, 0x0a, 0x06, 0x43, 0x45, 0x4e, 0x54, 0x4f, 0x53, 0x10, 0xa2, 0x1f, 0x12, 0x0b, 0x0a,
+	0x06, 0x41, 0x4d, 0x41, 0x5a, 0x4f, 0x4e, 0x10, 0xa3, 0x1f, 0x12, 0x0b, 0x0a, 0x06, 0x4f, 0x52,
+	0x41, 0x43, 0x4c, 0x45, 0x10, 0xa4, 0x1f, 0x12, 0x0a, 0x0a, 0x05, 0x52, 0x4f, 0x43, 0x4b, 0x59,
+	0x10, 0xa5, 0x1f, 0x12, 0x0e, 0x0a, 0x09, 0x41, 0x4c, 0x4d, 0x41, 0x4c, 0x49, 0x4e, 0x55, 0x58,
+	0x10, 0xa6, 0x1f, 0x2a, 0x45, 0x0a, 0x0c, 0x41, 0x72, 0x63, 0x68, 0x69, 0x74, 0x65, 0x63, 0x74,
+	0x75, 0x72, 0x65, 0x12, 0x18, 0x0a, 0x14, 0x41, 0x52, 0x43, 0x48, 0x49, 0x54, 0x45, 0x43, 0x54,
+	0x55, 0x52, 0x45, 0x5f, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12, 0x07, 0x0a,
+	0x03, 0x58, 0x38, 0x36, 0x10, 0x01, 0x12, 0x07, 0x0a, 0x03, 0x58, 0x36, 0x34, 0x10, 0x02, 0x12,
+	0x09, 0x0a, 0x05, 0x41, 0x52, 0x4d, 0x36, 0x34, 0x10, 0x03, 0x42, 0x06, 0x5a, 0x04, 0x2e, 0x3b,
+	0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -542,25 +678,27 @@ func file_inspect_proto_rawDescGZIP() []byte {
 	return file_inspect_proto_rawDescData
 }
 
-var file_inspect_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_inspect_proto_enumTypes = make([]protoimpl.EnumInfo, 4)
 var file_inspect_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
 var file_inspect_proto_goTypes = []interface{}{
-	(Distro)(0),                      // 0: Distro
-	(Architecture)(0),                // 1: Architecture
-	(InspectionResults_ErrorWhen)(0), // 2: InspectionResults.ErrorWhen
-	(*OsRelease)(nil),                // 3: OsRelease
-	(*InspectionResults)(nil),        // 4: InspectionResults
+	(Distro)(0),                       // 0: Distro
+	(Architecture)(0),                 // 1: Architecture
+	(InspectionResults_ErrorWhen)(0),  // 2: InspectionResults.ErrorWhen
+	(InspectionResults_Encryption)(0), // 3: InspectionResults.Encryption
+	(*OsRelease)(nil),                 // 4: OsRelease
+	(*InspectionResults)(nil),         // 5: InspectionResults
 }
 var file_inspect_proto_depIdxs = []int32{
 	1, // 0: OsRelease.architecture:type_name -> Architecture
 	0, // 1: OsRelease.distro_id:type_name -> Distro
-	3, // 2: InspectionResults.os_release:type_name -> OsRelease
+	4, // 2: InspectionResults.os_release:type_name -> OsRelease
 	2, // 3: InspectionResults.error_when:type_name -> InspectionResults.ErrorWhen
-	4, // [4:4] is the sub-list for method output_type
-	4, // [4:4] is the sub-list for method input_type
-	4, // [4:4] is the sub-list for extension type_name
-	4, // [4:4] is the sub-list for extension extendee
-	0, // [0:4] is the sub-list for field type_name
+	3, // 4: InspectionResults.encrypted_volumes:type_name -> InspectionResults.Encryption
+	5, // [5:5] is the sub-list for method output_type
+	5, // [5:5] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
 }
 
 func init() { file_inspect_proto_init() }
@@ -599,7 +737,7 @@ func file_inspect_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_inspect_proto_rawDesc,
-			NumEnums:      3,
+			NumEnums:      4,
 			NumMessages:   2,
 			NumExtensions: 0,
 			NumServices:   0,
@@ -39,6 +39,110 @@ const (
 // of the legacy proto package is being used.
 const _ = proto.ProtoPackageIsVersion4
 
+type BootVerification_Result int32
+
+const (
+	BootVerification_RESULT_UNKNOWN BootVerification_Result = 0
+	BootVerification_PASSED         BootVerification_Result = 1
+	// The instance didn't report that the guest environment was ready
+	// before the verification timed out.
+	BootVerification_BOOT_FAILED BootVerification_Result = 2
+	// The guest environment was ready, but a verification script failed.
+	BootVerification_SCRIPT_FAILED BootVerification_Result = 3
+)
+
+// Enum value maps for BootVerification_Result.
+var (
+	BootVerification_Result_name = map[int32]string{
+		0: "RESULT_UNKNOWN",
+		1: "PASSED",
+		2: "BOOT_FAILED",
+		3: "SCRIPT_FAILED",
+	}
+	BootVerification_Result_value = map[string]int32{
+		"RESULT_UNKNOWN": 0,
+		"PASSED":         1,
+		"BOOT_FAILED":    2,
+		"SCRIPT_FAILED":  3,
+	}
+)
+
+func (x BootVerification_Result) Enum() *BootVerification_Result {
+	p := new(BootVerification_Result)
+	*p = x
+	return p
+}
+
+func (x BootVerification_Result) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (BootVerification_Result) Descriptor() protoreflect.EnumDescriptor {
+	return file_output_info_proto_enumTypes[0].Descriptor()
+}
+
+func (BootVerification_Result) Type() protoreflect.EnumType {
+	return &file_output_info_proto_enumTypes[0]
+}
+
+func (x BootVerification_Result) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use BootVerification_Result.Descriptor instead.
+func (BootVerification_Result) EnumDescriptor() ([]byte, []int) {
+	return file_output_info_proto_rawDescGZIP(), []int{1, 0}
+}
+
+type BootVerification_ImageAction int32
+
+const (
+	BootVerification_IMAGE_ACTION_NONE BootVerification_ImageAction = 0
+	BootVerification_DEPRECATED        BootVerification_ImageAction = 1
+	BootVerification_DELETED           BootVerification_ImageAction = 2
+)
+
+// Enum value maps for BootVerification_ImageAction.
+var (
+	BootVerification_ImageAction_name = map[int32]string{
+		0: "IMAGE_ACTION_NONE",
+		1: "DEPRECATED",
+		2: "DELETED",
+	}
+	BootVerification_ImageAction_value = map[string]int32{
+		"IMAGE_ACTION_NONE": 0,
+		"DEPRECATED":        1,
+		"DELETED":           2,
+	}
+)
+
+func (x BootVerification_ImageAction) Enum() *BootVerification_ImageAction {
+	p := new(BootVerification_ImageAction)
+	*p = x
+	return p
+}
+
+func (x BootVerification_ImageAction) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (BootVerification_ImageAction) Descriptor() protoreflect.EnumDescriptor {
+	return file_output_info_proto_enumTypes[1].Descriptor()
+}
+
+func (BootVerification_ImageAction) Type() protoreflect.EnumType {
+	return &file_output_info_proto_enumTypes[1]
+}
+
+func (x BootVerification_ImageAction) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use BootVerification_ImageAction.Descriptor instead.
+func (BootVerification_ImageAction) EnumDescriptor() ([]byte, []int) {
+	return file_output_info_proto_rawDescGZIP(), []int{1, 1}
+}
+
 // OutputInfo records output info from the tools.
 type OutputInfo struct {
 	state         protoimpl.MessageState
@@ -78,6 +182,17 @@ type OutputInfo struct {
 	IsUefiDetected bool `protobuf:"varint,13,opt,name=is_uefi_detected,json=isUefiDetected,proto3" json:"is_uefi_detected,omitempty"`
 	// Inspection results. Ref to the def of 'InspectionResults' to see details.
 	InspectionResults *InspectionResults `protobuf:"bytes,14,opt,name=inspection_results,json=inspectionResults,proto3" json:"inspection_results,omitempty"`
+	// Compression or archive format of the import source: gz, xz, zst, tar,
+	// or a compressed tar archive, such as tar.gz. Empty when the source was
+	// imported as is.
+	SourceCompressionFormat string `protobuf:"bytes,15,opt,name=source_compression_format,json=sourceCompressionFormat,proto3" json:"source_compression_format,omitempty"`
+	// Size of compressed import sources, before decompression.
+	CompressedSourcesSizeGb []int64 `protobuf:"varint,16,rep,packed,name=compressed_sources_size_gb,json=compressedSourcesSizeGb,proto3" json:"compressed_sources_size_gb,omitempty"`
+	// Size of the import sources after decompression, in GB.
+	DecompressedSourcesSizeGb []int64 `protobuf:"varint,17,rep,packed,name=decompressed_sources_size_gb,json=decompressedSourcesSizeGb,proto3" json:"decompressed_sources_size_gb,omitempty"`
+	// Result of booting an instance from the imported image. Only populated
+	// when boot verification was requested.
+	BootVerification *BootVerification `protobuf:"bytes,18,opt,name=boot_verification,json=bootVerification,proto3" json:"boot_verification,omitempty"`
 }
 
 func (x *OutputInfo) Reset() {
@@ -210,12 +325,117 @@ func (x *OutputInfo) GetInspectionResults() *InspectionResults {
 	return nil
 }
 
+func (x *OutputInfo) GetSourceCompressionFormat() string {
+	if x != nil {
+		return x.SourceCompressionFormat
+	}
+	return ""
+}
+
+func (x *OutputInfo) GetCompressedSourcesSizeGb() []int64 {
+	if x != nil {
+		return x.CompressedSourcesSizeGb
+	}
+	return nil
+}
+
+func (x *OutputInfo) GetDecompressedSourcesSizeGb() []int64 {
+	if x != nil {
+		return x.DecompressedSourcesSizeGb
+	}
+	return nil
+}
+
+func (x *OutputInfo) GetBootVerification() *BootVerification {
+	if x != nil {
+		return x.BootVerification
+	}
+	return nil
+}
+
+// BootVerification records the result of booting a temporary instance from
+// an imported image, and running the user's verification scripts on it.
+type BootVerification struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Result BootVerification_Result `protobuf:"varint,1,opt,name=result,proto3,enum=BootVerification_Result" json:"result,omitempty"`
+	// Time from when the guest OS booted until the guest environment ran
+	// the verification startup script, as reported by the guest.
+	BootTimeMs int64 `protobuf:"varint,2,opt,name=boot_time_ms,json=bootTimeMs,proto3" json:"boot_time_ms,omitempty"`
+	// Names of the verification scripts that failed.
+	FailedScripts []string `protobuf:"bytes,3,rep,name=failed_scripts,json=failedScripts,proto3" json:"failed_scripts,omitempty"`
+	// What was done with the image after verification failed.
+	ImageAction BootVerification_ImageAction `protobuf:"varint,4,opt,name=image_action,json=imageAction,proto3,enum=BootVerification_ImageAction" json:"image_action,omitempty"`
+}
+
+func (x *BootVerification) Reset() {
+	*x = BootVerification{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_output_info_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BootVerification) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BootVerification) ProtoMessage() {}
+
+func (x *BootVerification) ProtoReflect() protoreflect.Message {
+	mi := &file_output_info_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BootVerification.ProtoReflect.Descriptor instead.
+func (*BootVerification) Descriptor() ([]byte, []int) {
+	return file_output_info_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *BootVerification) GetResult() BootVerification_Result {
+	if x != nil {
+		return x.Result
+	}
+	return BootVerification_RESULT_UNKNOWN
+}
+
+func (x *BootVerification) GetBootTimeMs() int64 {
+	if x != nil {
+		return x.BootTimeMs
+	}
+	return 0
+}
+
+func (x *BootVerification) GetFailedScripts() []string {
+	if x != nil {
+		return x.FailedScripts
+	}
+	return nil
+}
+
+func (x *BootVerification) GetImageAction() BootVerification_ImageAction {
+	if x != nil {
+		return x.ImageAction
+	}
+	return BootVerification_IMAGE_ACTION_NONE
+}
+
 var File_output_info_proto protoreflect.FileDescriptor
 
 var file_output_info_proto_rawDesc = []byte{
 	0x0a, 0x11, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x5f, 0x69, 0x6e, 0x66, 0x6f, 0x2e, 0x70, 0x72,
 	0x6f, 0x74, 0x6f, 0x1a, 0x0d, 0x69, 0x6e, 0x73, 0x70, 0x65, 0x63, 0x74, 0x2e, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x22, 0xce, 0x05, 0x0a, 0x0a, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x49, 0x6e, 0x66,
+	0x74, 0x6f, 0x22, 0xc8, 0x07, 0x0a, 0x0a, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x49, 0x6e, 0x66,
 	0x6f, 0x12, 0x26, 0x0a, 0x0f, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x73, 0x5f, 0x73, 0x69, 0x7a,
 	0x65, 0x5f, 0x67, 0x62, 0x18, 0x01, 0x20, 0x03, 0x28, 0x03, 0x52, 0x0d, 0x73, 0x6f, 0x75, 0x72,
 	0x63, 0x65, 0x73, 0x53, 0x69, 0x7a, 0x65, 0x47, 0x62, 0x12, 0x26, 0x0a, 0x0f, 0x74, 0x61, 0x72,
@@ -260,8 +480,45 @@ var file_output_info_proto_rawDesc = []byte{
 	0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x49,
 	0x6e, 0x73, 0x70, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73,
 	0x52, 0x11, 0x69, 0x6e, 0x73, 0x70, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x75,
-	0x6c, 0x74, 0x73, 0x42, 0x06, 0x5a, 0x04, 0x2e, 0x3b, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x33,
+	0x6c, 0x74, 0x73, 0x12, 0x3a, 0x0a, 0x19, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x5f, 0x63, 0x6f,
+	0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74,
+	0x18, 0x0f, 0x20, 0x01, 0x28, 0x09, 0x52, 0x17, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x43, 0x6f,
+	0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x46, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x12,
+	0x3b, 0x0a, 0x1a, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x65, 0x64, 0x5f, 0x73, 0x6f,
+	0x75, 0x72, 0x63, 0x65, 0x73, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x5f, 0x67, 0x62, 0x18, 0x10, 0x20,
+	0x03, 0x28, 0x03, 0x52, 0x17, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x65, 0x64, 0x53,
+	0x6f, 0x75, 0x72, 0x63, 0x65, 0x73, 0x53, 0x69, 0x7a, 0x65, 0x47, 0x62, 0x12, 0x3f, 0x0a, 0x1c,
+	0x64, 0x65, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x65, 0x64, 0x5f, 0x73, 0x6f, 0x75,
+	0x72, 0x63, 0x65, 0x73, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x5f, 0x67, 0x62, 0x18, 0x11, 0x20, 0x03,
+	0x28, 0x03, 0x52, 0x19, 0x64, 0x65, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x65, 0x64,
+	0x53, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x73, 0x53, 0x69, 0x7a, 0x65, 0x47, 0x62, 0x12, 0x3e, 0x0a,
+	0x11, 0x62, 0x6f, 0x6f, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x18, 0x12, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x42, 0x6f, 0x6f, 0x74, 0x56,
+	0x65, 0x72, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x10, 0x62, 0x6f, 0x6f,
+	0x74, 0x56, 0x65, 0x72, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0xe0, 0x02,
+	0x0a, 0x10, 0x42, 0x6f, 0x6f, 0x74, 0x56, 0x65, 0x72, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x12, 0x30, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0e, 0x32, 0x18, 0x2e, 0x42, 0x6f, 0x6f, 0x74, 0x56, 0x65, 0x72, 0x69, 0x66, 0x69, 0x63,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x52, 0x06, 0x72, 0x65,
+	0x73, 0x75, 0x6c, 0x74, 0x12, 0x20, 0x0a, 0x0c, 0x62, 0x6f, 0x6f, 0x74, 0x5f, 0x74, 0x69, 0x6d,
+	0x65, 0x5f, 0x6d, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x62, 0x6f, 0x6f, 0x74,
+	0x54, 0x69, 0x6d, 0x65, 0x4d, 0x73, 0x12, 0x25, 0x0a, 0x0e, 0x66, 0x61, 0x69, 0x6c, 0x65, 0x64,
+	0x5f, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0d,
+	0x66, 0x61, 0x69, 0x6c, 0x65, 0x64, 0x53, 0x63, 0x72, 0x69, 0x70, 0x74, 0x73, 0x12, 0x40, 0x0a,
+	0x0c, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x0e, 0x32, 0x1d, 0x2e, 0x42, 0x6f, 0x6f, 0x74, 0x56, 0x65, 0x72, 0x69, 0x66, 0x69,
+	0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x49, 0x6d, 0x61, 0x67, 0x65, 0x41, 0x63, 0x74, 0x69,
+	0x6f, 0x6e, 0x52, 0x0b, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x22,
+	0x4c, 0x0a, 0x06, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x12, 0x0a, 0x0e, 0x52, 0x45, 0x53,
+	0x55, 0x4c, 0x54, 0x5f, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12, 0x0a, 0x0a,
+	0x06, 0x50, 0x41, 0x53, 0x53, 0x45, 0x44, 0x10, 0x01, 0x12, 0x0f, 0x0a, 0x0b, 0x42, 0x4f, 0x4f,
+	0x54, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x02, 0x12, 0x11, 0x0a, 0x0d, 0x53, 0x43,
+	0x52, 0x49, 0x50, 0x54, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x03, 0x22, 0x41, 0x0a,
+	0x0b, 0x49, 0x6d, 0x61, 0x67, 0x65, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x15, 0x0a, 0x11,
+	0x49, 0x4d, 0x41, 0x47, 0x45, 0x5f, 0x41, 0x43, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x4e, 0x4f, 0x4e,
+	0x45, 0x10, 0x00, 0x12, 0x0e, 0x0a, 0x0a, 0x44, 0x45, 0x50, 0x52, 0x45, 0x43, 0x41, 0x54, 0x45,
+	0x44, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x44, 0x45, 0x4c, 0x45, 0x54, 0x45, 0x44, 0x10, 0x02,
+	0x42, 0x06, 0x5a, 0x04, 0x2e, 0x3b, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -276,18 +533,25 @@ func file_output_info_proto_rawDescGZIP() []byte {
 	return file_output_info_proto_rawDescData
 }
 
-var file_output_info_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_output_info_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_output_info_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
 var file_output_info_proto_goTypes = []interface{}{
-	(*OutputInfo)(nil),        // 0: OutputInfo
-	(*InspectionResults)(nil), // 1: InspectionResults
+	(BootVerification_Result)(0),      // 0: BootVerification.Result
+	(BootVerification_ImageAction)(0), // 1: BootVerification.ImageAction
+	(*OutputInfo)(nil),                // 2: OutputInfo
+	(*BootVerification)(nil),          // 3: BootVerification
+	(*InspectionResults)(nil),         // 4: InspectionResults
 }
 var file_output_info_proto_depIdxs = []int32{
-	1, // 0: OutputInfo.inspection_results:type_name -> InspectionResults
-	1, // [1:1] is the sub-list for method output_type
-	1, // [1:1] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	4, // 0: OutputInfo.inspection_results:type_name -> InspectionResults
+	3, // 1: OutputInfo.boot_verification:type_name -> BootVerification
+	0, // 2: BootVerification.result:type_name -> BootVerification.Result
+	1, // 3: BootVerification.image_action:type_name -> BootVerification.ImageAction
+	4, // [4:4] is the sub-list for method output_type
+	4, // [4:4] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
 }
 
 func init() { file_output_info_proto_init() }
@@ -309,19 +573,32 @@ func file_output_info_proto_init() {
 				return nil
 			}
 		}
+		file_output_info_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BootVerification); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_output_info_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   1,
+			NumEnums:      2,
+			NumMessages:   2,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
 		GoTypes:           file_output_info_proto_goTypes,
 		DependencyIndexes: file_output_info_proto_depIdxs,
+		EnumInfos:         file_output_info_proto_enumTypes,
 		MessageInfos:      file_output_info_proto_msgTypes,
 	}.Build()
 	File_output_info_proto = out.File
@@ -20,7 +20,7 @@ DESCRIPTOR = _descriptor.FileDescriptor(
   syntax='proto3',
   serialized_options=b'Z\004.;pb',
   create_key=_descriptor._internal_create_key,
-  serialized_pb=b'\n\rinspect.proto\"\xa1\x01\n\tOsRelease\x12\x15\n\rcli_formatted\x18\x01 \x01(\t\x12\x0e\n\x06\x64istro\x18\x02 \x01(\t\x12\x15\n\rmajor_version\x18\x03 \x01(\t\x12\x15\n\rminor_version\x18\x04 \x01(\t\x12#\n\x0c\x61rchitecture\x18\x05 \x01(\x0e\x32\r.Architecture\x12\x1a\n\tdistro_id\x18\x06 \x01(\x0e\x32\x07.Distro\"\x9e\x03\n\x11InspectionResults\x12\x1e\n\nos_release\x18\x01 \x01(\x0b\x32\n.OsRelease\x12\x15\n\rbios_bootable\x18\x02 \x01(\x08\x12\x15\n\ruefi_bootable\x18\x03 \x01(\x08\x12\x0f\n\x07root_fs\x18\x04 \x01(\t\x12\x30\n\nerror_when\x18\x05 \x01(\x0e\x32\x1c.InspectionResults.ErrorWhen\x12\x17\n\x0f\x65lapsed_time_ms\x18\x06 \x01(\x03\x12\x10\n\x08os_count\x18\x07 \x01(\x05\"\xcc\x01\n\tErrorWhen\x12\x0c\n\x08NO_ERROR\x10\x00\x12\x13\n\x0fSTARTING_WORKER\x10\x64\x12\x12\n\x0eRUNNING_WORKER\x10\x65\x12\x13\n\x0eMOUNTING_GUEST\x10\xc8\x01\x12\x12\n\rINSPECTING_OS\x10\xc9\x01\x12\x1a\n\x15INSPECTING_BOOTLOADER\x10\xca\x01\x12\x1d\n\x18\x44\x45\x43ODING_WORKER_RESPONSE\x10\xac\x02\x12$\n\x1fINTERPRETING_INSPECTION_RESULTS\x10\xad\x02*\xb7\x01\n\x06\x44istro\x12\x12\n\x0e\x44ISTRO_UNKNOWN\x10\x00\x12\x0c\n\x07WINDOWS\x10\xe8\x07\x12\x0b\n\x06\x44\x45\x42IAN\x10\xd0\x0f\x12\x0b\n\x06UBUNTU\x10\xd1\x0f\x12\t\n\x04KALI\x10\xd2\x0f\x12\r\n\x08OPENSUSE\x10\xb8\x17\x12\t\n\x04SLES\x10\xb9\x17\x12\r\n\x08SLES_SAP\x10\xba\x17\x12\x0b\n\x06\x46\x45\x44ORA\x10\xa0\x1f\x12\t\n\x04RHEL\x10\xa1\x1f\x12\x0b\n\x06\x43\x45NTOS\x10\xa2\x1f\x12\x0b\n\x06\x41MAZON\x10\xa3\x1f\x12\x0b\n\x06ORACLE\x10\xa4\x1f*:\n\x0c\x41rchitecture\x12\x18\n\x14\x41RCHITECTURE_UNKNOWN\x10\x00\x12\x07\n\x03X86\x10\x01\x12\x07\n\x03X64\x10\x02\x42\x06Z\x04.;pbb\x06proto3'
+  serialized_pb=b'\n\rinspect.proto\"\xa1\x01\n\tOsRelease\x12\x15\n\rcli_formatted\x18\x01 \x01(\t\x12\x0e\n\x06\x64istro\x18\x02 \x01(\t\x12\x15\n\rmajor_version\x18\x03 \x01(\t\x12\x15\n\rminor_version\x18\x04 \x01(\t\x12#\n\x0c\x61rchitecture\x18\x05 \x01(\x0e\x32\r.Architecture\x12\x1a\n\tdistro_id\x18\x06 \x01(\x0e\x32\x07.Distro\"\xfb\x04\n\x11InspectionResults\x12\x1e\n\nos_release\x18\x01 \x01(\x0b\x32\n.OsRelease\x12\x15\n\rbios_bootable\x18\x02 \x01(\x08\x12\x15\n\ruefi_bootable\x18\x03 \x01(\x08\x12\x0f\n\x07root_fs\x18\x04 \x01(\t\x12\x30\n\nerror_when\x18\x05 \x01(\x0e\x32\x1c.InspectionResults.ErrorWhen\x12\x17\n\x0f\x65lapsed_time_ms\x18\x06 \x01(\x03\x12\x10\n\x08os_count\x18\x07 \x01(\x05\x12\x17\n\x0fkernel_versions\x18\x08 \x03(\t\x12\x16\n\x0evirtio_drivers\x18\t \x03(\t\x12\x1c\n\x14\x63loud_init_installed\x18\n \x01(\x08\x12\x13\n\x0broot_on_lvm\x18\x0b \x01(\x08\x12\x38\n\x11\x65ncrypted_volumes\x18\x0c \x03(\x0e\x32\x1d.InspectionResults.Encryption\"\xcc\x01\n\tErrorWhen\x12\x0c\n\x08NO_ERROR\x10\x00\x12\x13\n\x0fSTARTING_WORKER\x10\x64\x12\x12\n\x0eRUNNING_WORKER\x10\x65\x12\x13\n\x0eMOUNTING_GUEST\x10\xc8\x01\x12\x12\n\rINSPECTING_OS\x10\xc9\x01\x12\x1a\n\x15INSPECTING_BOOTLOADER\x10\xca\x01\x12\x1d\n\x18\x44\x45\x43ODING_WORKER_RESPONSE\x10\xac\x02\x12$\n\x1fINTERPRETING_INSPECTION_RESULTS\x10\xad\x02\"=\n\nEncryption\x12\x16\n\x12\x45NCRYPTION_UNKNOWN\x10\x00\x12\x08\n\x04LUKS\x10\x01\x12\r\n\tBITLOCKER\x10\x02*\xd3\x01\n\x06\x44istro\x12\x12\n\x0e\x44ISTRO_UNKNOWN\x10\x00\x12\x0c\n\x07WINDOWS\x10\xe8\x07\x12\x0b\n\x06\x44\x45\x42IAN\x10\xd0\x0f\x12\x0b\n\x06UBUNTU\x10\xd1\x0f\x12\t\n\x04KALI\x10\xd2\x0f\x12\r\n\x08OPENSUSE\x10\xb8\x17\x12\t\n\x04SLES\x10\xb9\x17\x12\r\n\x08SLES_SAP\x10\xba\x17\x12\x0b\n\x06\x46\x45\x44ORA\x10\xa0\x1f\x12\t\n\x04RHEL\x10\xa1\x1f\x12\x0b\n\x06\x43\x45NTOS\x10\xa2\x1f\x12\x0b\n\x06\x41MAZON\x10\xa3\x1f\x12\x0b\n\x06ORACLE\x10\xa4\x1f\x12\n\n\x05ROCKY\x10\xa5\x1f\x12\x0e\n\tALMALINUX\x10\xa6\x1f*E\n\x0c\x41rchitecture\x12\x18\n\x14\x41RCHITECTURE_UNKNOWN\x10\x00\x12\x07\n\x03X86\x10\x01\x12\x07\n\x03X64\x10\x02\x12\t\n\x05\x41RM64\x10\x03\x42\x06Z\x04.;pbb\x06proto3'
 )
 
 _DISTRO = _descriptor.EnumDescriptor(
@@ -95,11 +95,21 @@ _DISTRO = _descriptor.EnumDescriptor(
       serialized_options=None,
       type=None,
       create_key=_descriptor._internal_create_key),
+    _descriptor.EnumValueDescriptor(
+      name='ROCKY', index=13, number=4005,
+      serialized_options=None,
+      type=None,
+      create_key=_descriptor._internal_create_key),
+    _descriptor.EnumValueDescriptor(
+      name='ALMALINUX', index=14, number=4006,
+      serialized_options=None,
+      type=None,
+      create_key=_descriptor._internal_create_key),
   ],
   containing_type=None,
   serialized_options=None,
-  serialized_start=599,
-  serialized_end=782,
+  serialized_start=820,
+  serialized_end=1031,
 )
 _sym_db.RegisterEnumDescriptor(_DISTRO)
 
@@ -126,11 +136,16 @@ _ARCHITECTURE = _descriptor.EnumDescriptor(
       serialized_options=None,
       type=None,
       create_key=_descriptor._internal_create_key),
+    _descriptor.EnumValueDescriptor(
+      name='ARM64', index=3, number=3,
+      serialized_options=None,
+      type=None,
+      create_key=_descriptor._internal_create_key),
   ],
   containing_type=None,
   serialized_options=None,
-  serialized_start=784,
-  serialized_end=842,
+  serialized_start=1033,
+  serialized_end=1102,
 )
 _sym_db.RegisterEnumDescriptor(_ARCHITECTURE)
 
@@ -148,9 +163,12 @@ RHEL = 4001
 CENTOS = 4002
 AMAZON = 4003
 ORACLE = 4004
+ROCKY = 4005
+ALMALINUX = 4006
 ARCHITECTURE_UNKNOWN = 0
 X86 = 1
 X64 = 2
+ARM64 = 3
 
 
 _INSPECTIONRESULTS_ERRORWHEN = _descriptor.EnumDescriptor(
@@ -203,11 +221,41 @@ _INSPECTIONRESULTS_ERRORWHEN = _descriptor.EnumDescriptor(
   ],
   containing_type=None,
   serialized_options=None,
-  serialized_start=392,
-  serialized_end=596,
+  serialized_start=550,
+  serialized_end=754,
 )
 _sym_db.RegisterEnumDescriptor(_INSPECTIONRESULTS_ERRORWHEN)
 
+_INSPECTIONRESULTS_ENCRYPTION = _descriptor.EnumDescriptor(
+  name='Encryption',
+  full_name='InspectionResults.Encryption',
+  filename=None,
+  file=DESCRIPTOR,
+  create_key=_descriptor._internal_create_key,
+  values=[
+    _descriptor.EnumValueDescriptor(
+      name='ENCRYPTION_UNKNOWN', index=0, number=0,
+      serialized_options=None,
+      type=None,
+      create_key=_descriptor._internal_create_key),
+    _descriptor.EnumValueDescriptor(
+      name='LUKS', index=1, number=1,
+      serialized_options=None,
+      type=None,
+      create_key=_descriptor._internal_create_key),
+    _descriptor.EnumValueDescriptor(
+      name='BITLOCKER', index=2, number=2,
+      serialized_options=None,
+      type=None,
+      create_key=_descriptor._internal_create_key),
+  ],
+  containing_type=None,
+  serialized_options=None,
+  serialized_start=756,
+  serialized_end=817,
+)
+_sym_db.RegisterEnumDescriptor(_INSPECTIONRESULTS_ENCRYPTION)
+
 
 _OSRELEASE = _descriptor.Descriptor(
   name='OsRelease',
@@ -333,12 +381,48 @@ _INSPECTIONRESULTS = _descriptor.Descriptor(
       message_type=None, enum_type=None, containing_type=None,
       is_extension=False, extension_scope=None,
       serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
+    _descriptor.FieldDescriptor(
+      name='kernel_versions', full_name='InspectionResults.kernel_versions', index=7,
+      number=8, type=9, cpp_type=9, label=3,
+      has_default_value=False, default_value=[],
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
+    _descriptor.FieldDescriptor(
+      name='virtio_drivers', full_name='InspectionResults.virtio_drivers', index=8,
+      number=9, type=9, cpp_type=9, label=3,
+      has_default_value=False, default_value=[],
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
+    _descriptor.FieldDescriptor(
+      name='cloud_init_installed', full_name='InspectionResults.cloud_init_installed', index=9,
+      number=10, type=8, cpp_type=7, label=1,
+      has_default_value=False, default_value=False,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
+    _descriptor.FieldDescriptor(
+      name='root_on_lvm', full_name='InspectionResults.root_on_lvm', index=10,
+      number=11, type=8, cpp_type=7, label=1,
+      has_default_value=False, default_value=False,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
+    _descriptor.FieldDescriptor(
+      name='encrypted_volumes', full_name='InspectionResults.encrypted_volumes', index=11,
+      number=12, type=14, cpp_type=8, label=3,
+      has_default_value=False, default_value=[],
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
   ],
   extensions=[
   ],
   nested_types=[],
   enum_types=[
     _INSPECTIONRESULTS_ERRORWHEN,
+    _INSPECTIONRESULTS_ENCRYPTION,
   ],
   serialized_options=None,
   is_extendable=False,
@@ -347,14 +431,16 @@ _INSPECTIONRESULTS = _descriptor.Descriptor(
   oneofs=[
   ],
   serialized_start=182,
-  serialized_end=596,
+  serialized_end=817,
 )
 
 _OSRELEASE.fields_by_name['architecture'].enum_type = _ARCHITECTURE
 _OSRELEASE.fields_by_name['distro_id'].enum_type = _DISTRO
 _INSPECTIONRESULTS.fields_by_name['os_release'].message_type = _OSRELEASE
 _INSPECTIONRESULTS.fields_by_name['error_when'].enum_type = _INSPECTIONRESULTS_ERRORWHEN
+_INSPECTIONRESULTS.fields_by_name['encrypted_volumes'].enum_type = _INSPECTIONRESULTS_ENCRYPTION
 _INSPECTIONRESULTS_ERRORWHEN.containing_type = _INSPECTIONRESULTS
+_INSPECTIONRESULTS_ENCRYPTION.containing_type = _INSPECTIONRESULTS
 DESCRIPTOR.message_types_by_name['OsRelease'] = _OSRELEASE
 DESCRIPTOR.message_types_by_name['InspectionResults'] = _INSPECTIONRESULTS
 DESCRIPTOR.enum_types_by_name['Distro'] = _DISTRO
@@ -6,6 +6,10 @@ from google.protobuf.descriptor import (
     FileDescriptor as google___protobuf___descriptor___FileDescriptor,
 )
 
+from google.protobuf.internal.containers import (
+    RepeatedScalarFieldContainer as google___protobuf___internal___containers___RepeatedScalarFieldContainer,
+)
+
 from google.protobuf.internal.enum_type_wrapper import (
     _EnumTypeWrapper as google___protobuf___internal___enum_type_wrapper____EnumTypeWrapper,
 )
@@ -15,6 +19,7 @@ from google.protobuf.message import (
 )
 
 from typing import (
+    Iterable as typing___Iterable,
     NewType as typing___NewType,
     Optional as typing___Optional,
     Text as typing___Text,
@@ -52,6 +57,8 @@ class _Distro(google___protobuf___internal___enum_type_wrapper____EnumTypeWrappe
     CENTOS = typing___cast(DistroValue, 4002)
     AMAZON = typing___cast(DistroValue, 4003)
     ORACLE = typing___cast(DistroValue, 4004)
+    ROCKY = typing___cast(DistroValue, 4005)
+    ALMALINUX = typing___cast(DistroValue, 4006)
 DISTRO_UNKNOWN = typing___cast(DistroValue, 0)
 WINDOWS = typing___cast(DistroValue, 1000)
 DEBIAN = typing___cast(DistroValue, 2000)
@@ -65,6 +72,8 @@ RHEL = typing___cast(DistroValue, 4001)
 CENTOS = typing___cast(DistroValue, 4002)
 AMAZON = typing___cast(DistroValue, 4003)
 ORACLE = typing___cast(DistroValue, 4004)
+ROCKY = typing___cast(DistroValue, 4005)
+ALMALINUX = typing___cast(DistroValue, 4006)
 type___Distro = Distro
 
 ArchitectureValue = typing___NewType('ArchitectureValue', builtin___int)
@@ -75,9 +84,11 @@ class _Architecture(google___protobuf___internal___enum_type_wrapper____EnumType
     ARCHITECTURE_UNKNOWN = typing___cast(ArchitectureValue, 0)
     X86 = typing___cast(ArchitectureValue, 1)
     X64 = typing___cast(ArchitectureValue, 2)
+    ARM64 = typing___cast(ArchitectureValue, 3)
 ARCHITECTURE_UNKNOWN = typing___cast(ArchitectureValue, 0)
 X86 = typing___cast(ArchitectureValue, 1)
 X64 = typing___cast(ArchitectureValue, 2)
+ARM64 = typing___cast(ArchitectureValue, 3)
 type___Architecture = Architecture
 
 class OsRelease(google___protobuf___message___Message):
@@ -125,6 +136,18 @@ class InspectionResults(google___protobuf___message___Message):
     DECODING_WORKER_RESPONSE = typing___cast(InspectionResults.ErrorWhenValue, 300)
     INTERPRETING_INSPECTION_RESULTS = typing___cast(InspectionResults.ErrorWhenValue, 301)
     type___ErrorWhen = ErrorWhen
+    EncryptionValue = typing___NewType('EncryptionValue', builtin___int)
+    type___EncryptionValue = EncryptionValue
+    Encryption: _Encryption
+    class _Encryption(google___protobuf___internal___enum_type_wrapper____EnumTypeWrapper[InspectionResults.EncryptionValue]):
+        DESCRIPTOR: google___protobuf___descriptor___EnumDescriptor = ...
+        ENCRYPTION_UNKNOWN = typing___cast(InspectionResults.EncryptionValue, 0)
+        LUKS = typing___cast(InspectionResults.EncryptionValue, 1)
+        BITLOCKER = typing___cast(InspectionResults.EncryptionValue, 2)
+    ENCRYPTION_UNKNOWN = typing___cast(InspectionResults.EncryptionValue, 0)
+    LUKS = typing___cast(InspectionResults.EncryptionValue, 1)
+    BITLOCKER = typing___cast(InspectionResults.EncryptionValue, 2)
+    type___Encryption = Encryption
 
     bios_bootable: builtin___bool = ...
     uefi_bootable: builtin___bool = ...
@@ -132,6 +155,11 @@ class InspectionResults(google___protobuf___message___Message):
     error_when: type___InspectionResults.ErrorWhenValue = ...
     elapsed_time_ms: builtin___int = ...
     os_count: builtin___int = ...
+    kernel_versions: google___protobuf___internal___containers___RepeatedScalarFieldContainer[typing___Text] = ...
+    virtio_drivers: google___protobuf___internal___containers___RepeatedScalarFieldContainer[typing___Text] = ...
+    cloud_init_installed: builtin___bool = ...
+    root_on_lvm: builtin___bool = ...
+    encrypted_volumes: google___protobuf___internal___containers___RepeatedScalarFieldContainer[type___InspectionResults.EncryptionValue] = ...
 
     @property
     def os_release(self) -> type___OsRelease: ...
@@ -145,7 +173,12 @@ class InspectionResults(google___protobuf___message___Message):
         error_when : typing___Optional[type___InspectionResults.ErrorWhenValue] = None,
         elapsed_time_ms : typing___Optional[builtin___int] = None,
         os_count : typing___Optional[builtin___int] = None,
+        kernel_versions : typing___Optional[typing___Iterable[typing___Text]] = None,
+        virtio_drivers : typing___Optional[typing___Iterable[typing___Text]] = None,
+        cloud_init_installed : typing___Optional[builtin___bool] = None,
+        root_on_lvm : typing___Optional[builtin___bool] = None,
+        encrypted_volumes : typing___Optional[typing___Iterable[type___InspectionResults.EncryptionValue]] = None,
         ) -> None: ...
     def HasField(self, field_name: typing_extensions___Literal[u"os_release",b"os_release"]) -> builtin___bool: ...
-    def ClearField(self, field_name: typing_extensions___Literal[u"bios_bootable",b"bios_bootable",u"elapsed_time_ms",b"elapsed_time_ms",u"error_when",b"error_when",u"os_count",b"os_count",u"os_release",b"os_release",u"root_fs",b"root_fs",u"uefi_bootable",b"uefi_bootable"]) -> None: ...
+    def ClearField(self, field_name: typing_extensions___Literal[u"bios_bootable",b"bios_bootable",u"cloud_init_installed",b"cloud_init_installed",u"elapsed_time_ms",b"elapsed_time_ms",u"encrypted_volumes",b"encrypted_volumes",u"error_when",b"error_when",u"kernel_versions",b"kernel_versions",u"os_count",b"os_count",u"os_release",b"os_release",u"root_fs",b"root_fs",u"root_on_lvm",b"root_on_lvm",u"uefi_bootable",b"uefi_bootable",u"virtio_drivers",b"virtio_drivers"]) -> None: ...
 type___InspectionResults = InspectionResults
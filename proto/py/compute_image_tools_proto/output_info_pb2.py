@@ -20,12 +20,77 @@ DESCRIPTOR = _descriptor.FileDescriptor(
   syntax='proto3',
   serialized_options=b'Z\004.;pb',
   create_key=_descriptor._internal_create_key,
-  serialized_pb=b'\n\x11output_info.proto\x1a\rinspect.proto\"\xbe\x03\n\nOutputInfo\x12\x17\n\x0fsources_size_gb\x18\x01 \x03(\x03\x12\x17\n\x0ftargets_size_gb\x18\x02 \x03(\x03\x12\x17\n\x0f\x66\x61ilure_message\x18\x03 \x01(\t\x12,\n$failure_message_without_privacy_info\x18\x04 \x01(\t\x12\x16\n\x0eserial_outputs\x18\x05 \x03(\t\x12\x1a\n\x12import_file_format\x18\x06 \x01(\t\x12 \n\x18\x64\x65tected_sources_size_gb\x18\x07 \x03(\x03\x12\x16\n\x0einflation_type\x18\x08 \x01(\t\x12\x19\n\x11inflation_time_ms\x18\t \x03(\x03\x12 \n\x18shadow_inflation_time_ms\x18\n \x03(\x03\x12 \n\x18shadow_disk_match_result\x18\x0b \x01(\t\x12 \n\x18is_uefi_compatible_image\x18\x0c \x01(\x08\x12\x18\n\x10is_uefi_detected\x18\r \x01(\x08\x12.\n\x12inspection_results\x18\x0e \x01(\x0b\x32\x12.InspectionResultsB\x06Z\x04.;pbb\x06proto3'
+  serialized_pb=b'\n\x11output_info.proto\x1a\rinspect.proto\"\xd9\x04\n\nOutputInfo\x12\x17\n\x0fsources_size_gb\x18\x01 \x03(\x03\x12\x17\n\x0ftargets_size_gb\x18\x02 \x03(\x03\x12\x17\n\x0f\x66\x61ilure_message\x18\x03 \x01(\t\x12,\n$failure_message_without_privacy_info\x18\x04 \x01(\t\x12\x16\n\x0eserial_outputs\x18\x05 \x03(\t\x12\x1a\n\x12import_file_format\x18\x06 \x01(\t\x12 \n\x18\x64\x65tected_sources_size_gb\x18\x07 \x03(\x03\x12\x16\n\x0einflation_type\x18\x08 \x01(\t\x12\x19\n\x11inflation_time_ms\x18\t \x03(\x03\x12 \n\x18shadow_inflation_time_ms\x18\n \x03(\x03\x12 \n\x18shadow_disk_match_result\x18\x0b \x01(\t\x12 \n\x18is_uefi_compatible_image\x18\x0c \x01(\x08\x12\x18\n\x10is_uefi_detected\x18\r \x01(\x08\x12.\n\x12inspection_results\x18\x0e \x01(\x0b\x32\x12.InspectionResults\x12!\n\x19source_compression_format\x18\x0f \x01(\t\x12\"\n\x1a\x63ompressed_sources_size_gb\x18\x10 \x03(\x03\x12$\n\x1c\x64\x65\x63ompressed_sources_size_gb\x18\x11 \x03(\x03\x12,\n\x11\x62oot_verification\x18\x12 \x01(\x0b\x32\x11.BootVerification\"\xb0\x02\n\x10\x42ootVerification\x12(\n\x06result\x18\x01 \x01(\x0e\x32\x18.BootVerification.Result\x12\x14\n\x0c\x62oot_time_ms\x18\x02 \x01(\x03\x12\x16\n\x0e\x66\x61iled_scripts\x18\x03 \x03(\t\x12\x33\n\x0cimage_action\x18\x04 \x01(\x0e\x32\x1d.BootVerification.ImageAction\"L\n\x06Result\x12\x12\n\x0eRESULT_UNKNOWN\x10\x00\x12\n\n\x06PASSED\x10\x01\x12\x0f\n\x0b\x42OOT_FAILED\x10\x02\x12\x11\n\rSCRIPT_FAILED\x10\x03\"A\n\x0bImageAction\x12\x15\n\x11IMAGE_ACTION_NONE\x10\x00\x12\x0e\n\nDEPRECATED\x10\x01\x12\x0b\n\x07\x44\x45LETED\x10\x02\x42\x06Z\x04.;pbb\x06proto3'
   ,
   dependencies=[inspect__pb2.DESCRIPTOR,])
 
 
 
+_BOOTVERIFICATION_RESULT = _descriptor.EnumDescriptor(
+  name='Result',
+  full_name='BootVerification.Result',
+  filename=None,
+  file=DESCRIPTOR,
+  create_key=_descriptor._internal_create_key,
+  values=[
+    _descriptor.EnumValueDescriptor(
+      name='RESULT_UNKNOWN', index=0, number=0,
+      serialized_options=None,
+      type=None,
+      create_key=_descriptor._internal_create_key),
+    _descriptor.EnumValueDescriptor(
+      name='PASSED', index=1, number=1,
+      serialized_options=None,
+      type=None,
+      create_key=_descriptor._internal_create_key),
+    _descriptor.EnumValueDescriptor(
+      name='BOOT_FAILED', index=2, number=2,
+      serialized_options=None,
+      type=None,
+      create_key=_descriptor._internal_create_key),
+    _descriptor.EnumValueDescriptor(
+      name='SCRIPT_FAILED', index=3, number=3,
+      serialized_options=None,
+      type=None,
+      create_key=_descriptor._internal_create_key),
+  ],
+  containing_type=None,
+  serialized_options=None,
+  serialized_start=802,
+  serialized_end=878,
+)
+_sym_db.RegisterEnumDescriptor(_BOOTVERIFICATION_RESULT)
+
+_BOOTVERIFICATION_IMAGEACTION = _descriptor.EnumDescriptor(
+  name='ImageAction',
+  full_name='BootVerification.ImageAction',
+  filename=None,
+  file=DESCRIPTOR,
+  create_key=_descriptor._internal_create_key,
+  values=[
+    _descriptor.EnumValueDescriptor(
+      name='IMAGE_ACTION_NONE', index=0, number=0,
+      serialized_options=None,
+      type=None,
+      create_key=_descriptor._internal_create_key),
+    _descriptor.EnumValueDescriptor(
+      name='DEPRECATED', index=1, number=1,
+      serialized_options=None,
+      type=None,
+      create_key=_descriptor._internal_create_key),
+    _descriptor.EnumValueDescriptor(
+      name='DELETED', index=2, number=2,
+      serialized_options=None,
+      type=None,
+      create_key=_descriptor._internal_create_key),
+  ],
+  containing_type=None,
+  serialized_options=None,
+  serialized_start=880,
+  serialized_end=945,
+)
+_sym_db.RegisterEnumDescriptor(_BOOTVERIFICATION_IMAGEACTION)
+
 
 _OUTPUTINFO = _descriptor.Descriptor(
   name='OutputInfo',
@@ -133,6 +198,34 @@ _OUTPUTINFO = _descriptor.Descriptor(
       message_type=None, enum_type=None, containing_type=None,
       is_extension=False, extension_scope=None,
       serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
+    _descriptor.FieldDescriptor(
+      name='source_compression_format', full_name='OutputInfo.source_compression_format', index=14,
+      number=15, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=b"".decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
+    _descriptor.FieldDescriptor(
+      name='compressed_sources_size_gb', full_name='OutputInfo.compressed_sources_size_gb', index=15,
+      number=16, type=3, cpp_type=2, label=3,
+      has_default_value=False, default_value=[],
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
+    _descriptor.FieldDescriptor(
+      name='decompressed_sources_size_gb', full_name='OutputInfo.decompressed_sources_size_gb', index=16,
+      number=17, type=3, cpp_type=2, label=3,
+      has_default_value=False, default_value=[],
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
+    _descriptor.FieldDescriptor(
+      name='boot_verification', full_name='OutputInfo.boot_verification', index=17,
+      number=18, type=11, cpp_type=10, label=1,
+      has_default_value=False, default_value=None,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
   ],
   extensions=[
   ],
@@ -146,11 +239,72 @@ _OUTPUTINFO = _descriptor.Descriptor(
   oneofs=[
   ],
   serialized_start=37,
-  serialized_end=483,
+  serialized_end=638,
+)
+
+
+_BOOTVERIFICATION = _descriptor.Descriptor(
+  name='BootVerification',
+  full_name='BootVerification',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  create_key=_descriptor._internal_create_key,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='result', full_name='BootVerification.result', index=0,
+      number=1, type=14, cpp_type=8, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
+    _descriptor.FieldDescriptor(
+      name='boot_time_ms', full_name='BootVerification.boot_time_ms', index=1,
+      number=2, type=3, cpp_type=2, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
+    _descriptor.FieldDescriptor(
+      name='failed_scripts', full_name='BootVerification.failed_scripts', index=2,
+      number=3, type=9, cpp_type=9, label=3,
+      has_default_value=False, default_value=[],
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
+    _descriptor.FieldDescriptor(
+      name='image_action', full_name='BootVerification.image_action', index=3,
+      number=4, type=14, cpp_type=8, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+    _BOOTVERIFICATION_RESULT,
+    _BOOTVERIFICATION_IMAGEACTION,
+  ],
+  serialized_options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=641,
+  serialized_end=945,
 )
 
 _OUTPUTINFO.fields_by_name['inspection_results'].message_type = inspect__pb2._INSPECTIONRESULTS
+_OUTPUTINFO.fields_by_name['boot_verification'].message_type = _BOOTVERIFICATION
+_BOOTVERIFICATION.fields_by_name['result'].enum_type = _BOOTVERIFICATION_RESULT
+_BOOTVERIFICATION.fields_by_name['image_action'].enum_type = _BOOTVERIFICATION_IMAGEACTION
+_BOOTVERIFICATION_RESULT.containing_type = _BOOTVERIFICATION
+_BOOTVERIFICATION_IMAGEACTION.containing_type = _BOOTVERIFICATION
 DESCRIPTOR.message_types_by_name['OutputInfo'] = _OUTPUTINFO
+DESCRIPTOR.message_types_by_name['BootVerification'] = _BOOTVERIFICATION
 _sym_db.RegisterFileDescriptor(DESCRIPTOR)
 
 OutputInfo = _reflection.GeneratedProtocolMessageType('OutputInfo', (_message.Message,), {
@@ -160,6 +314,13 @@ OutputInfo = _reflection.GeneratedProtocolMessageType('OutputInfo', (_message.Me
   })
 _sym_db.RegisterMessage(OutputInfo)
 
+BootVerification = _reflection.GeneratedProtocolMessageType('BootVerification', (_message.Message,), {
+  'DESCRIPTOR' : _BOOTVERIFICATION,
+  '__module__' : 'output_info_pb2'
+  # @@protoc_insertion_point(class_scope:BootVerification)
+  })
+_sym_db.RegisterMessage(BootVerification)
+
 
 DESCRIPTOR._options = None
 # @@protoc_insertion_point(module_scope)
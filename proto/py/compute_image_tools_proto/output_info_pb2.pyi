@@ -2,6 +2,7 @@
 import sys
 from google.protobuf.descriptor import (
     Descriptor as google___protobuf___descriptor___Descriptor,
+    EnumDescriptor as google___protobuf___descriptor___EnumDescriptor,
     FileDescriptor as google___protobuf___descriptor___FileDescriptor,
 )
 
@@ -9,6 +10,10 @@ from google.protobuf.internal.containers import (
     RepeatedScalarFieldContainer as google___protobuf___internal___containers___RepeatedScalarFieldContainer,
 )
 
+from google.protobuf.internal.enum_type_wrapper import (
+    _EnumTypeWrapper as google___protobuf___internal___enum_type_wrapper____EnumTypeWrapper,
+)
+
 from google.protobuf.message import (
     Message as google___protobuf___message___Message,
 )
@@ -19,8 +24,10 @@ from inspect_pb2 import (
 
 from typing import (
     Iterable as typing___Iterable,
+    NewType as typing___NewType,
     Optional as typing___Optional,
     Text as typing___Text,
+    cast as typing___cast,
 )
 
 from typing_extensions import (
@@ -51,10 +58,16 @@ class OutputInfo(google___protobuf___message___Message):
     shadow_disk_match_result: typing___Text = ...
     is_uefi_compatible_image: builtin___bool = ...
     is_uefi_detected: builtin___bool = ...
+    source_compression_format: typing___Text = ...
+    compressed_sources_size_gb: google___protobuf___internal___containers___RepeatedScalarFieldContainer[builtin___int] = ...
+    decompressed_sources_size_gb: google___protobuf___internal___containers___RepeatedScalarFieldContainer[builtin___int] = ...
 
     @property
     def inspection_results(self) -> inspect_pb2___InspectionResults: ...
 
+    @property
+    def boot_verification(self) -> type___BootVerification: ...
+
     def __init__(self,
         *,
         sources_size_gb : typing___Optional[typing___Iterable[builtin___int]] = None,
@@ -71,7 +84,56 @@ class OutputInfo(google___protobuf___message___Message):
         is_uefi_compatible_image : typing___Optional[builtin___bool] = None,
         is_uefi_detected : typing___Optional[builtin___bool] = None,
         inspection_results : typing___Optional[inspect_pb2___InspectionResults] = None,
+        source_compression_format : typing___Optional[typing___Text] = None,
+        compressed_sources_size_gb : typing___Optional[typing___Iterable[builtin___int]] = None,
+        decompressed_sources_size_gb : typing___Optional[typing___Iterable[builtin___int]] = None,
+        boot_verification : typing___Optional[type___BootVerification] = None,
         ) -> None: ...
-    def HasField(self, field_name: typing_extensions___Literal[u"inspection_results",b"inspection_results"]) -> builtin___bool: ...
-    def ClearField(self, field_name: typing_extensions___Literal[u"detected_sources_size_gb",b"detected_sources_size_gb",u"failure_message",b"failure_message",u"failure_message_without_privacy_info",b"failure_message_without_privacy_info",u"import_file_format",b"import_file_format",u"inflation_time_ms",b"inflation_time_ms",u"inflation_type",b"inflation_type",u"inspection_results",b"inspection_results",u"is_uefi_compatible_image",b"is_uefi_compatible_image",u"is_uefi_detected",b"is_uefi_detected",u"serial_outputs",b"serial_outputs",u"shadow_disk_match_result",b"shadow_disk_match_result",u"shadow_inflation_time_ms",b"shadow_inflation_time_ms",u"sources_size_gb",b"sources_size_gb",u"targets_size_gb",b"targets_size_gb"]) -> None: ...
+    def HasField(self, field_name: typing_extensions___Literal[u"boot_verification",b"boot_verification",u"inspection_results",b"inspection_results"]) -> builtin___bool: ...
+    def ClearField(self, field_name: typing_extensions___Literal[u"boot_verification",b"boot_verification",u"compressed_sources_size_gb",b"compressed_sources_size_gb",u"decompressed_sources_size_gb",b"decompressed_sources_size_gb",u"detected_sources_size_gb",b"detected_sources_size_gb",u"failure_message",b"failure_message",u"failure_message_without_privacy_info",b"failure_message_without_privacy_info",u"import_file_format",b"import_file_format",u"inflation_time_ms",b"inflation_time_ms",u"inflation_type",b"inflation_type",u"inspection_results",b"inspection_results",u"is_uefi_compatible_image",b"is_uefi_compatible_image",u"is_uefi_detected",b"is_uefi_detected",u"serial_outputs",b"serial_outputs",u"shadow_disk_match_result",b"shadow_disk_match_result",u"shadow_inflation_time_ms",b"shadow_inflation_time_ms",u"source_compression_format",b"source_compression_format",u"sources_size_gb",b"sources_size_gb",u"targets_size_gb",b"targets_size_gb"]) -> None: ...
 type___OutputInfo = OutputInfo
+
+class BootVerification(google___protobuf___message___Message):
+    DESCRIPTOR: google___protobuf___descriptor___Descriptor = ...
+    ResultValue = typing___NewType('ResultValue', builtin___int)
+    type___ResultValue = ResultValue
+    Result: _Result
+    class _Result(google___protobuf___internal___enum_type_wrapper____EnumTypeWrapper[BootVerification.ResultValue]):
+        DESCRIPTOR: google___protobuf___descriptor___EnumDescriptor = ...
+        RESULT_UNKNOWN = typing___cast(BootVerification.ResultValue, 0)
+        PASSED = typing___cast(BootVerification.ResultValue, 1)
+        BOOT_FAILED = typing___cast(BootVerification.ResultValue, 2)
+        SCRIPT_FAILED = typing___cast(BootVerification.ResultValue, 3)
+    RESULT_UNKNOWN = typing___cast(BootVerification.ResultValue, 0)
+    PASSED = typing___cast(BootVerification.ResultValue, 1)
+    BOOT_FAILED = typing___cast(BootVerification.ResultValue, 2)
+    SCRIPT_FAILED = typing___cast(BootVerification.ResultValue, 3)
+    type___Result = Result
+
+    ImageActionValue = typing___NewType('ImageActionValue', builtin___int)
+    type___ImageActionValue = ImageActionValue
+    ImageAction: _ImageAction
+    class _ImageAction(google___protobuf___internal___enum_type_wrapper____EnumTypeWrapper[BootVerification.ImageActionValue]):
+        DESCRIPTOR: google___protobuf___descriptor___EnumDescriptor = ...
+        IMAGE_ACTION_NONE = typing___cast(BootVerification.ImageActionValue, 0)
+        DEPRECATED = typing___cast(BootVerification.ImageActionValue, 1)
+        DELETED = typing___cast(BootVerification.ImageActionValue, 2)
+    IMAGE_ACTION_NONE = typing___cast(BootVerification.ImageActionValue, 0)
+    DEPRECATED = typing___cast(BootVerification.ImageActionValue, 1)
+    DELETED = typing___cast(BootVerification.ImageActionValue, 2)
+    type___ImageAction = ImageAction
+
+    result: type___BootVerification.ResultValue = ...
+    boot_time_ms: builtin___int = ...
+    failed_scripts: google___protobuf___internal___containers___RepeatedScalarFieldContainer[typing___Text] = ...
+    image_action: type___BootVerification.ImageActionValue = ...
+
+    def __init__(self,
+        *,
+        result : typing___Optional[type___BootVerification.ResultValue] = None,
+        boot_time_ms : typing___Optional[builtin___int] = None,
+        failed_scripts : typing___Optional[typing___Iterable[typing___Text]] = None,
+        image_action : typing___Optional[type___BootVerification.ImageActionValue] = None,
+        ) -> None: ...
+    def ClearField(self, field_name: typing_extensions___Literal[u"boot_time_ms",b"boot_time_ms",u"failed_scripts",b"failed_scripts",u"image_action",b"image_action",u"result",b"result"]) -> None: ...
+type___BootVerification = BootVerification